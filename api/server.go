@@ -23,6 +23,7 @@ package api
 import (
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/sirupsen/logrus"
 
@@ -46,6 +47,15 @@ func ListenAndServe(addr string, engine *core.Engine, logger logrus.FieldLogger)
 	return http.ListenAndServe(addr, withEngine(engine, newLogger(logger, mux)))
 }
 
+// ListenAndServeEngine is like ListenAndServe, for when the engine changes
+// while the server runs, like when the test is run again with --retries. The
+// v1 routes fail with 503 Service Unavailable while engine returns nil.
+func ListenAndServeEngine(addr string, engine func() *core.Engine, logger logrus.FieldLogger) error {
+	mux := newHandler(logger)
+
+	return http.ListenAndServe(addr, withEngineFunc(engine, newLogger(logger, mux)))
+}
+
 type wrappedResponseWriter struct {
 	http.ResponseWriter
 	status int
@@ -56,6 +66,13 @@ func (w wrappedResponseWriter) WriteHeader(status int) {
 	w.ResponseWriter.WriteHeader(status)
 }
 
+// Flush sends any buffered data to the client, for the streamed responses.
+func (w wrappedResponseWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
 // newLogger returns the middleware which logs response status for request.
 func newLogger(l logrus.FieldLogger, next http.Handler) http.HandlerFunc {
 	return func(rw http.ResponseWriter, r *http.Request) {
@@ -73,6 +90,18 @@ func withEngine(engine *core.Engine, next http.Handler) http.HandlerFunc {
 	})
 }
 
+func withEngineFunc(engine func() *core.Engine, next http.Handler) http.HandlerFunc {
+	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		e := engine()
+		if e == nil && strings.HasPrefix(r.URL.Path, "/v1/") {
+			http.Error(rw, "the test hasn't started yet", http.StatusServiceUnavailable)
+			return
+		}
+		r = r.WithContext(common.WithEngine(r.Context(), e))
+		next.ServeHTTP(rw, r)
+	})
+}
+
 func handlePing(logger logrus.FieldLogger) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
 		rw.Header().Add("Content-Type", "text/plain; charset=utf-8")
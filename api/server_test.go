@@ -84,7 +84,7 @@ func TestWithEngine(t *testing.T) {
 	require.NoError(t, err)
 	registry := metrics.NewRegistry()
 	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
-	engine, err := core.NewEngine(execScheduler, lib.Options{}, lib.RuntimeOptions{}, nil, logger, builtinMetrics)
+	engine, err := core.NewEngine(execScheduler, lib.Options{}, lib.RuntimeOptions{}, nil, logger, registry, builtinMetrics)
 	require.NoError(t, err)
 
 	rw := httptest.NewRecorder()
@@ -94,6 +94,35 @@ func TestWithEngine(t *testing.T) {
 	}))(rw, r)
 }
 
+func TestWithEngineFunc(t *testing.T) {
+	logger := logrus.New()
+	logger.SetOutput(testutils.NewTestOutput(t))
+	execScheduler, err := local.NewExecutionScheduler(&minirunner.MiniRunner{}, logger)
+	require.NoError(t, err)
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	engine, err := core.NewEngine(execScheduler, lib.Options{}, lib.RuntimeOptions{}, nil, logger, registry, builtinMetrics)
+	require.NoError(t, err)
+
+	var current *core.Engine
+	handler := withEngineFunc(func() *core.Engine { return current }, newHandler(logger))
+
+	rw := httptest.NewRecorder()
+	handler(rw, httptest.NewRequest("GET", "http://example.com/v1/status", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, rw.Result().StatusCode)
+
+	rw = httptest.NewRecorder()
+	handler(rw, httptest.NewRequest("GET", "http://example.com/ping", nil))
+	assert.Equal(t, http.StatusOK, rw.Result().StatusCode)
+
+	current = engine
+	handler = withEngineFunc(func() *core.Engine { return current }, http.HandlerFunc(
+		func(rw http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, engine, common.GetEngine(r.Context()))
+		}))
+	handler(httptest.NewRecorder(), httptest.NewRequest("GET", "http://example.com/v1/status", nil))
+}
+
 func TestPing(t *testing.T) {
 	logger := logrus.New()
 	logger.SetOutput(testutils.NewTestOutput(t))
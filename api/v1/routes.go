@@ -39,6 +39,14 @@ func NewHandler() http.Handler {
 		}
 	})
 
+	mux.HandleFunc("/v1/events", func(rw http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			rw.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		handleGetEvents(rw, r)
+	})
+
 	mux.HandleFunc("/v1/metrics", func(rw http.ResponseWriter, r *http.Request) {
 		if r.Method != http.MethodGet {
 			rw.WriteHeader(http.StatusMethodNotAllowed)
@@ -31,6 +31,7 @@ import (
 	"go.k6.io/k6/errext"
 	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/pkg/testrun"
 )
 
 func getArchiveCmd(logger *logrus.Logger, globalFlags *commandFlags) *cobra.Command {
@@ -61,7 +62,7 @@ An archive is a fully self-contained test run, and can be executed identically e
 
 			registry := metrics.NewRegistry()
 			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
-			r, err := newRunner(logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
+			r, err := testrun.NewRunner(logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
 			if err != nil {
 				return err
 			}
@@ -72,10 +73,12 @@ An archive is a fully self-contained test run, and can be executed identically e
 			}
 			conf, err := getConsolidatedConfig(
 				afero.NewOsFs(), Config{Options: cliOpts}, r.GetOptions(), buildEnvMap(os.Environ()), globalFlags,
+				runtimeOptions, logger,
 			)
 			if err != nil {
 				return err
 			}
+			conf = addMetricThresholds(conf, registry)
 
 			// Parse the thresholds, only if the --no-threshold flag is not set.
 			// If parsing the threshold expressions failed, consider it as an
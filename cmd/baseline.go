@@ -0,0 +1,107 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/stats"
+)
+
+// readBaseline returns the values of the metrics in the summary of a previous
+// test, by metric and aggregation method. The summary is either the JSON of
+// the data of handleSummary(), with the values of a metric in its values, or
+// the one of --summary-export, with them directly in the metric.
+func readBaseline(fs afero.Fs, path string) (map[string]map[string]float64, error) {
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read the baseline: %w", err)
+	}
+	var summary struct {
+		Metrics map[string]map[string]json.RawMessage `json:"metrics"`
+	}
+	if err = json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("the baseline %s isn't a JSON summary: %w", path, err)
+	}
+	if len(summary.Metrics) == 0 {
+		return nil, fmt.Errorf("the baseline %s has no metrics", path)
+	}
+
+	baseline := make(map[string]map[string]float64, len(summary.Metrics))
+	for name, metric := range summary.Metrics {
+		values := make(map[string]float64)
+		if rawValues, ok := metric["values"]; ok {
+			if err = json.Unmarshal(rawValues, &values); err != nil {
+				return nil, fmt.Errorf("the baseline %s has invalid values of the metric %s: %w", path, name, err)
+			}
+			baseline[name] = values
+			continue
+		}
+
+		// The values are next to the thresholds in the --summary-export
+		// format, where the rate of a rate metric is its value.
+		for method, raw := range metric {
+			var value float64
+			if json.Unmarshal(raw, &value) == nil {
+				values[method] = value
+			}
+		}
+		_, hasRate := values["rate"]
+		if _, isRate := values["passes"]; isRate && !hasRate {
+			values["rate"] = values["value"]
+		}
+		baseline[name] = values
+	}
+	return baseline, nil
+}
+
+// applyBaseline sets the values of the baseline in path, if there is one, to
+// the parsed thresholds which compare with it.
+func applyBaseline(fs afero.Fs, path string, thresholds map[string]stats.Thresholds) error {
+	var baseline map[string]map[string]float64
+	if path != "" {
+		var err error
+		if baseline, err = readBaseline(fs, path); err != nil {
+			return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+		}
+	}
+
+	for name, ts := range thresholds {
+		if !ts.UsesBaseline() {
+			continue
+		}
+		if baseline == nil {
+			err := fmt.Errorf("the thresholds of %s compare with a baseline, which requires --baseline", name)
+			return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+		}
+		if err := ts.SetBaseline(baseline[name]); err != nil {
+			err = fmt.Errorf("the baseline %s doesn't fit the metric %s: %w", path, name, err)
+			return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+		}
+		thresholds[name] = ts
+	}
+	return nil
+}
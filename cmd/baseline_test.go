@@ -0,0 +1,111 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/stats"
+)
+
+func TestReadBaseline(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/summary.json", []byte(`{"metrics": {
+		"http_req_duration": {"type": "trend", "contains": "time", "values": {"avg": 150, "p(95)": 290}},
+		"checks": {"type": "rate", "contains": "default", "values": {"rate": 0.5, "passes": 1, "fails": 1}}
+	}}`), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/export.json", []byte(`{"metrics": {
+		"http_req_duration": {"avg": 150, "p(95)": 290, "thresholds": {"p(95)<300": false}},
+		"checks": {"value": 0.5, "passes": 1, "fails": 1}
+	}}`), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/invalid.json", []byte(`{"metrics": {}}`), 0o644))
+
+	want := map[string]map[string]float64{
+		"http_req_duration": {"avg": 150, "p(95)": 290},
+		"checks":            {"rate": 0.5, "passes": 1, "fails": 1},
+	}
+	baseline, err := readBaseline(fs, "/summary.json")
+	require.NoError(t, err)
+	assert.Equal(t, want, baseline)
+
+	baseline, err = readBaseline(fs, "/export.json")
+	require.NoError(t, err)
+	want["checks"]["value"] = 0.5
+	assert.Equal(t, want, baseline)
+
+	_, err = readBaseline(fs, "/invalid.json")
+	assert.Error(t, err)
+	_, err = readBaseline(fs, "/missing.json")
+	assert.Error(t, err)
+}
+
+func TestApplyBaseline(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/summary.json", []byte(`{"metrics": {
+		"http_req_duration": {"type": "trend", "contains": "time", "values": {"avg": 150, "p(95)": 290}}
+	}}`), 0o644))
+
+	parse := func(sources map[string][]string) map[string]stats.Thresholds {
+		thresholds := make(map[string]stats.Thresholds, len(sources))
+		for name, s := range sources {
+			ts := stats.NewThresholds(s)
+			require.NoError(t, ts.Parse())
+			thresholds[name] = ts
+		}
+		return thresholds
+	}
+	assertInvalidConfig := func(err error) {
+		var ecerr errext.HasExitCode
+		require.True(t, errors.As(err, &ecerr), err)
+		assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+	}
+
+	thresholds := parse(map[string][]string{
+		"http_req_duration": {"p(95) < baseline * 1.1"},
+		"checks":            {"rate > 0.9"},
+	})
+	require.NoError(t, applyBaseline(fs, "/summary.json", thresholds))
+	ts := thresholds["http_req_duration"]
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	duration.Sink.Add(stats.Sample{Value: 300})
+	duration.Sink.Calc()
+	ok, err := ts.Run(duration.Sink, 0)
+	require.NoError(t, err)
+	assert.True(t, ok, "300 is less than 10% over the baseline")
+
+	avgBelowBaseline := map[string][]string{"http_req_duration": {"avg < baseline"}}
+	assertInvalidConfig(applyBaseline(fs, "", parse(avgBelowBaseline)))
+	maxBelowBaseline := map[string][]string{"http_req_duration": {"max < baseline"}}
+	assertInvalidConfig(applyBaseline(fs, "/summary.json", parse(maxBelowBaseline)))
+	notInBaseline := map[string][]string{"my_trend": {"avg < baseline"}}
+	assertInvalidConfig(applyBaseline(fs, "/summary.json", parse(notInBaseline)))
+	assertInvalidConfig(applyBaseline(fs, "/missing.json", nil))
+	assert.NoError(t, applyBaseline(fs, "", parse(map[string][]string{"checks": {"rate > 0.9"}})))
+}
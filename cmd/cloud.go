@@ -46,6 +46,7 @@ import (
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/pkg/testrun"
 	"go.k6.io/k6/ui/pb"
 )
 
@@ -98,7 +99,7 @@ This will execute the test on the k6 cloud service. Use "k6 login cloud" to auth
 			modifyAndPrintBar(progressBar, globalFlags, pb.WithConstProgress(0, "Getting script options"))
 			registry := metrics.NewRegistry()
 			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
-			r, err := newRunner(logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
+			r, err := testrun.NewRunner(logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
 			if err != nil {
 				return err
 			}
@@ -109,10 +110,12 @@ This will execute the test on the k6 cloud service. Use "k6 login cloud" to auth
 				return err
 			}
 			conf, err := getConsolidatedConfig(
-				afero.NewOsFs(), Config{Options: cliOpts}, r.GetOptions(), buildEnvMap(os.Environ()), globalFlags)
+				afero.NewOsFs(), Config{Options: cliOpts}, r.GetOptions(), buildEnvMap(os.Environ()), globalFlags,
+				runtimeOptions, logger)
 			if err != nil {
 				return err
 			}
+			conf = addMetricThresholds(conf, registry)
 
 			// Parse the thresholds, only if the --no-threshold flag is not set.
 			// If parsing the threshold expressions failed, consider it as an
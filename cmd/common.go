@@ -21,8 +21,6 @@
 package cmd
 
 import (
-	"archive/tar"
-	"bytes"
 	"fmt"
 	"io"
 	"os"
@@ -89,6 +87,15 @@ func exactArgsWithMsg(n int, msg string) cobra.PositionalArgs {
 	}
 }
 
+func minArgsWithMsg(n int, msg string) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if len(args) < n {
+			return fmt.Errorf("requires at least %d arg(s), received %d: %s", n, len(args), msg)
+		}
+		return nil
+	}
+}
+
 // readSource is a small wrapper around loader.ReadSource returning
 // result of the load and filesystems map
 func readSource(filename string, logger *logrus.Logger) (*loader.SourceData, map[string]afero.Fs, error) {
@@ -102,13 +109,6 @@ func readSource(filename string, logger *logrus.Logger) (*loader.SourceData, map
 	return src, filesystems, err
 }
 
-func detectType(data []byte) string {
-	if _, err := tar.NewReader(bytes.NewReader(data)).Next(); err == nil {
-		return typeArchive
-	}
-	return typeJS
-}
-
 // fprintf panics when where's an error writing to the supplied io.Writer
 func fprintf(w io.Writer, format string, a ...interface{}) (n int) {
 	n, err := fmt.Fprintf(w, format, a...)
@@ -37,8 +37,8 @@ import (
 	"go.k6.io/k6/errext"
 	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/lib"
-	"go.k6.io/k6/lib/executor"
-	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/pkg/testrun"
 	"go.k6.io/k6/stats"
 )
 
@@ -49,6 +49,10 @@ func configFlagSet() *pflag.FlagSet {
 	flags.StringArrayP("out", "o", []string{}, "`uri` for an external metrics database")
 	flags.BoolP("linger", "l", false, "keep the API server alive past test end")
 	flags.Bool("no-usage-report", false, "don't send anonymous stats to the developers")
+	flags.String("threshold-result-file", "",
+		"write whether each threshold passed, with the observed value and the limit, to a JSON `file` on exit")
+	flags.String("thresholds-file", "",
+		"add the thresholds of the metrics in a JSON `file`, in the format of the thresholds option")
 	return flags
 }
 
@@ -60,6 +64,11 @@ type Config struct {
 	Linger        null.Bool `json:"linger" envconfig:"K6_LINGER"`
 	NoUsageReport null.Bool `json:"noUsageReport" envconfig:"K6_NO_USAGE_REPORT"`
 
+	ThresholdResultFile null.String `json:"thresholdResultFile" envconfig:"K6_THRESHOLD_RESULT_FILE"`
+	ThresholdsFile      null.String `json:"thresholdsFile" envconfig:"K6_THRESHOLDS_FILE"`
+
+	ExitCodes ExitCodeMapping `json:"exitCodes" envconfig:"K6_EXIT_CODES"`
+
 	// TODO: deprecate
 	Collectors map[string]json.RawMessage `json:"collectors"`
 }
@@ -85,6 +94,15 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.NoUsageReport.Valid {
 		c.NoUsageReport = cfg.NoUsageReport
 	}
+	if cfg.ThresholdResultFile.Valid {
+		c.ThresholdResultFile = cfg.ThresholdResultFile
+	}
+	if cfg.ThresholdsFile.Valid {
+		c.ThresholdsFile = cfg.ThresholdsFile
+	}
+	if len(cfg.ExitCodes) > 0 {
+		c.ExitCodes = cfg.ExitCodes
+	}
 	if len(cfg.Collectors) > 0 {
 		c.Collectors = cfg.Collectors
 	}
@@ -106,6 +124,9 @@ func getConfig(flags *pflag.FlagSet) (Config, error) {
 		Out:           out,
 		Linger:        getNullBool(flags, "linger"),
 		NoUsageReport: getNullBool(flags, "no-usage-report"),
+
+		ThresholdResultFile: getNullString(flags, "threshold-result-file"),
+		ThresholdsFile:      getNullString(flags, "thresholds-file"),
 	}, nil
 }
 
@@ -116,6 +137,18 @@ func getConfig(flags *pflag.FlagSet) (Config, error) {
 // If there's no custom config specified and no file exists in the default config path, it will
 // return an empty config struct, the default config location and *no* error.
 func readDiskConfig(fs afero.Fs, globalFlags *commandFlags) (Config, string, error) {
+	data, realConfigFilePath, err := readDiskConfigData(fs, globalFlags)
+	if err != nil || data == nil {
+		return Config{}, realConfigFilePath, err
+	}
+	var conf Config
+	err = json.Unmarshal(data, &conf)
+	return conf, realConfigFilePath, err
+}
+
+// readDiskConfigData returns the content of the configuration file and its
+// path, like readDiskConfig, or nil if there's no file at the default path.
+func readDiskConfigData(fs afero.Fs, globalFlags *commandFlags) ([]byte, string, error) {
 	realConfigFilePath := globalFlags.configFilePath
 	if realConfigFilePath == "" {
 		// The user didn't specify K6_CONFIG or --config, use the default path
@@ -129,16 +162,11 @@ func readDiskConfig(fs afero.Fs, globalFlags *commandFlags) (Config, string, err
 			// didn't specify anything), silence the error
 			err = nil
 		}
-		return Config{}, realConfigFilePath, err
+		return nil, realConfigFilePath, err
 	}
 
 	data, err := afero.ReadFile(fs, realConfigFilePath)
-	if err != nil {
-		return Config{}, realConfigFilePath, err
-	}
-	var conf Config
-	err = json.Unmarshal(data, &conf)
-	return conf, realConfigFilePath, err
+	return data, realConfigFilePath, err
 }
 
 // Serializes the configuration to a JSON file and writes it in the supplied
@@ -174,17 +202,30 @@ func readEnvConfig(envMap map[string]string) (Config, error) {
 // - add the environment variables
 // - merge the user-supplied CLI flags back in on top, to give them the greatest priority
 // - set some defaults if they weren't previously specified
+// - add the thresholds of the thresholds file
+// The unknown keys of the config file are a warning, or an error with --strict.
 // TODO: add better validation, more explicit default values and improve consistency between formats
 // TODO: accumulate all errors and differentiate between the layers?
 func getConsolidatedConfig(
 	fs afero.Fs, cliConf Config, runnerOpts lib.Options, envMap map[string]string, globalFlags *commandFlags,
+	rtOpts lib.RuntimeOptions, logger logrus.FieldLogger,
 ) (conf Config, err error) {
 	// TODO: use errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig) where it makes sense?
 
-	fileConf, _, err := readDiskConfig(fs, globalFlags)
+	data, configPath, err := readDiskConfigData(fs, globalFlags)
 	if err != nil {
 		return conf, err
 	}
+	var fileConf Config
+	if data != nil {
+		if err = json.Unmarshal(data, &fileConf); err != nil {
+			return conf, err
+		}
+		err = lib.CheckUnknownOptions(logger, data, fileConf, "the config file "+configPath, rtOpts.StrictOptions.Bool)
+		if err != nil {
+			return conf, err
+		}
+	}
 	envConf, err := readEnvConfig(envMap)
 	if err != nil {
 		return conf, err
@@ -196,6 +237,9 @@ func getConsolidatedConfig(
 
 	conf = conf.Apply(envConf).Apply(cliConf)
 	conf = applyDefault(conf)
+	if conf, err = addFileThresholds(fs, conf); err != nil {
+		return conf, errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
 
 	// TODO(imiric): Move this validation where it makes sense in the configuration
 	// refactor of #883. This repeats the trend stats validation already done
@@ -209,28 +253,60 @@ func getConsolidatedConfig(
 	return conf, nil
 }
 
-// applyDefault applies the default options value if it is not specified.
-// This happens with types which are not supported by "gopkg.in/guregu/null.v3".
-//
-// Note that if you add option default value here, also add it in command line argument help text.
-func applyDefault(conf Config) Config {
-	if conf.Options.SystemTags == nil {
-		conf.Options.SystemTags = &stats.DefaultSystemTagSet
+// addFileThresholds adds the thresholds of the thresholdsFile option, which
+// has them by metric like the thresholds option, to the ones of the same
+// metrics, so the same ones can be used with many scripts.
+func addFileThresholds(fs afero.Fs, conf Config) (Config, error) {
+	path := conf.ThresholdsFile.String
+	if path == "" {
+		return conf, nil
 	}
-	if conf.Options.SummaryTrendStats == nil {
-		conf.Options.SummaryTrendStats = lib.DefaultSummaryTrendStats
+	data, err := afero.ReadFile(fs, path)
+	if err != nil {
+		return conf, fmt.Errorf("couldn't read the thresholds file: %w", err)
 	}
-	defDNS := types.DefaultDNSConfig()
-	if !conf.DNS.TTL.Valid {
-		conf.DNS.TTL = defDNS.TTL
+	var fileThresholds map[string]stats.Thresholds
+	if err = json.Unmarshal(data, &fileThresholds); err != nil {
+		return conf, fmt.Errorf("the thresholds file %s is invalid: %w", path, err)
 	}
-	if !conf.DNS.Select.Valid {
-		conf.DNS.Select = defDNS.Select
+
+	thresholds := make(map[string]stats.Thresholds, len(conf.Thresholds)+len(fileThresholds))
+	for name, ts := range conf.Thresholds {
+		thresholds[name] = ts
 	}
-	if !conf.DNS.Policy.Valid {
-		conf.DNS.Policy = defDNS.Policy
+	for name, ts := range fileThresholds {
+		merged := thresholds[name]
+		merged.Merge(ts)
+		thresholds[name] = merged
 	}
+	conf.Thresholds = thresholds
+	return conf, nil
+}
 
+// addMetricThresholds adds the thresholds declared with the custom metrics in
+// the script to the ones of the same metrics in the thresholds option.
+func addMetricThresholds(conf Config, registry *metrics.Registry) Config {
+	declared := registry.Thresholds()
+	if len(declared) == 0 {
+		return conf
+	}
+	thresholds := make(map[string]stats.Thresholds, len(conf.Thresholds)+len(declared))
+	for name, ts := range conf.Thresholds {
+		thresholds[name] = ts
+	}
+	for name, ts := range declared {
+		merged := thresholds[name]
+		merged.Merge(ts)
+		thresholds[name] = merged
+	}
+	conf.Thresholds = thresholds
+	return conf
+}
+
+// applyDefault applies the default options value if it is not specified.
+// This happens with types which are not supported by "gopkg.in/guregu/null.v3".
+func applyDefault(conf Config) Config {
+	conf.Options = testrun.ApplyDefaults(conf.Options)
 	return conf
 }
 
@@ -238,25 +314,10 @@ func deriveAndValidateConfig(
 	conf Config, isExecutable func(string) bool, logger logrus.FieldLogger,
 ) (result Config, err error) {
 	result = conf
-	result.Options, err = executor.DeriveScenariosFromShortcuts(conf.Options, logger)
-	if err == nil {
-		err = validateConfig(result, isExecutable)
-	}
+	result.Options, err = testrun.DeriveAndValidateOptions(conf.Options, isExecutable, logger)
 	return result, errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
 }
 
-func validateConfig(conf Config, isExecutable func(string) bool) error {
-	errList := conf.Validate()
-
-	for _, ec := range conf.Scenarios {
-		if err := validateScenarioConfig(ec, isExecutable); err != nil {
-			errList = append(errList, err)
-		}
-	}
-
-	return consolidateErrorMessage(errList, "There were problems with the specified script configuration:")
-}
-
 func consolidateErrorMessage(errList []error, title string) error {
 	if len(errList) == 0 {
 		return nil
@@ -269,11 +330,3 @@ func consolidateErrorMessage(errList []error, title string) error {
 
 	return errors.New(strings.Join(errMsgParts, "\n"))
 }
-
-func validateScenarioConfig(conf lib.ExecutorConfig, isExecutable func(string) bool) error {
-	execFn := conf.GetExec()
-	if !isExecutable(execFn) {
-		return fmt.Errorf("executor %s: function '%s' not found in exports", conf.GetName(), execFn)
-	}
-	return nil
-}
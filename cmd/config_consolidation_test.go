@@ -283,6 +283,7 @@ func getConfigConsolidationTestCases() []configConsolidationTestCase {
 		// Test if JSON configs work as expected
 		{opts{fs: defaultConfig(`{"iterations": 77, "vus": 7}`)}, exp{}, verifySharedIters(I(7), I(77))},
 		{opts{fs: defaultConfig(`wrong-json`)}, exp{consolidationError: true}, nil},
+		{opts{fs: defaultConfig(`{"iterations": 77, "vus": 7, "vuss": 8}`)}, exp{logWarning: true}, verifySharedIters(I(7), I(77))},
 		{opts{fs: getFS(nil), cli: []string{"--config", "/my/config.file"}}, exp{consolidationError: true}, nil},
 
 		// Test combinations between options and levels
@@ -373,7 +374,7 @@ func getConfigConsolidationTestCases() []configConsolidationTestCase {
 				require.True(t, ok)
 				assert.Equal(t, null.IntFrom(2), clvc.VUs)
 				assert.Equal(t, types.NullDurationFrom(24*time.Hour), clvc.Duration)
-				assert.Equal(t, types.NullDurationFrom(time.Second+500*time.Microsecond), clvc.StartTime)
+				assert.Equal(t, types.NullDurationFrom(time.Second+500*time.Microsecond), clvc.StartTime.NullDuration)
 				assert.Equal(t, types.NullDurationFrom(10*time.Second), clvc.GracefulStop)
 			},
 		},
@@ -413,6 +414,17 @@ func getConfigConsolidationTestCases() []configConsolidationTestCase {
 				)
 			},
 		},
+		// Test tag transforms
+		{
+			opts{cli: []string{"--tag-transform", "url=truncate(10)", "--tag-transform", "status=class"}},
+			exp{},
+			func(t *testing.T, c Config) {
+				require.Len(t, c.Options.TagTransforms, 2)
+				assert.Equal(t, "truncate(10)", c.Options.TagTransforms["url"].String())
+				assert.Equal(t, "class", c.Options.TagTransforms["status"].String())
+			},
+		},
+		{opts{cli: []string{"--tag-transform", "url=shorten"}}, exp{cliReadError: true}, nil},
 		// Test summary trend stats
 		{opts{}, exp{}, func(t *testing.T, c Config) {
 			assert.Equal(t, lib.DefaultSummaryTrendStats, c.Options.SummaryTrendStats)
@@ -587,7 +599,7 @@ func runTestCase(
 	}
 	consolidatedConfig, err := getConsolidatedConfig(testCase.options.fs, cliConf, runnerOpts,
 		// TODO: just make testcase.options.env in map[string]string
-		buildEnvMap(testCase.options.env), globalFlags)
+		buildEnvMap(testCase.options.env), globalFlags, lib.RuntimeOptions{}, logger)
 	if testCase.expected.consolidationError {
 		require.Error(t, err)
 		return
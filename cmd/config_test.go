@@ -25,14 +25,19 @@ import (
 	"time"
 
 	"github.com/mstoykov/envconfig"
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/errext"
 	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
 )
 
 type testCmdData struct {
@@ -104,6 +109,16 @@ func TestConfigEnv(t *testing.T) {
 			"true":  func(c Config) { assert.Equal(t, null.BoolFrom(true), c.NoUsageReport) },
 			"false": func(c Config) { assert.Equal(t, null.BoolFrom(false), c.NoUsageReport) },
 		},
+		{"ThresholdResultFile", "K6_THRESHOLD_RESULT_FILE"}: {
+			"":                func(c Config) { assert.Equal(t, null.String{}, c.ThresholdResultFile) },
+			"thresholds.json": func(c Config) { assert.Equal(t, null.StringFrom("thresholds.json"), c.ThresholdResultFile) },
+		},
+		{"ExitCodes", "K6_EXIT_CODES"}: {
+			"": func(c Config) { assert.Equal(t, ExitCodeMapping{}, c.ExitCodes) },
+			"threshold-failed:3,output-error:4": func(c Config) {
+				assert.Equal(t, ExitCodeMapping{"threshold-failed": 3, "output-error": 4}, c.ExitCodes)
+			},
+		},
 		{"Out", "K6_OUT"}: {
 			"":         func(c Config) { assert.Equal(t, []string{}, c.Out) },
 			"influxdb": func(c Config) { assert.Equal(t, []string{"influxdb"}, c.Out) },
@@ -143,6 +158,20 @@ func TestConfigApply(t *testing.T) {
 		conf := Config{}.Apply(Config{NoUsageReport: null.BoolFrom(true)})
 		assert.Equal(t, null.BoolFrom(true), conf.NoUsageReport)
 	})
+	t.Run("ThresholdResultFile", func(t *testing.T) {
+		t.Parallel()
+		conf := Config{}.Apply(Config{ThresholdResultFile: null.StringFrom("thresholds.json")})
+		assert.Equal(t, null.StringFrom("thresholds.json"), conf.ThresholdResultFile)
+		conf = conf.Apply(Config{})
+		assert.Equal(t, null.StringFrom("thresholds.json"), conf.ThresholdResultFile)
+	})
+	t.Run("ExitCodes", func(t *testing.T) {
+		t.Parallel()
+		conf := Config{}.Apply(Config{ExitCodes: ExitCodeMapping{"threshold-failed": 3}})
+		assert.Equal(t, ExitCodeMapping{"threshold-failed": 3}, conf.ExitCodes)
+		conf = conf.Apply(Config{})
+		assert.Equal(t, ExitCodeMapping{"threshold-failed": 3}, conf.ExitCodes)
+	})
 	t.Run("Out", func(t *testing.T) {
 		t.Parallel()
 		conf := Config{}.Apply(Config{Out: []string{"influxdb"}})
@@ -215,3 +244,88 @@ func TestDeriveAndValidateConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestAddMetricThresholds(t *testing.T) {
+	t.Parallel()
+	registry := metrics.NewRegistry()
+	_, err := registry.NewMetric("login_time", stats.Trend, stats.Time)
+	require.NoError(t, err)
+	require.NoError(t, registry.AddThresholds("login_time", stats.NewThresholds([]string{"p(95)<800", "avg<500"})))
+
+	conf := Config{Options: lib.Options{Thresholds: map[string]stats.Thresholds{
+		"login_time": stats.NewThresholds([]string{"avg<500"}),
+		"http_reqs":  stats.NewThresholds([]string{"count>10"}),
+	}}}
+	conf = addMetricThresholds(conf, registry)
+	sources := func(ts stats.Thresholds) (s []string) {
+		for _, t := range ts.Thresholds {
+			s = append(s, t.Source)
+		}
+		return s
+	}
+	assert.Equal(t, []string{"avg<500", "p(95)<800"}, sources(conf.Thresholds["login_time"]))
+	assert.Equal(t, []string{"count>10"}, sources(conf.Thresholds["http_reqs"]))
+
+	conf = addMetricThresholds(Config{}, registry)
+	assert.Equal(t, []string{"p(95)<800", "avg<500"}, sources(conf.Thresholds["login_time"]))
+}
+
+func TestConsolidatedConfigThresholdsFile(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/slo.json", []byte(
+		`{"http_req_duration": ["p(95)<500", "p(99)<1500"], "checks": [{"threshold": "rate>0.99"}]}`), 0o644))
+	globalFlags := newCommandFlags()
+	logger, _ := logtest.NewNullLogger()
+
+	sources := func(ts stats.Thresholds) (s []string) {
+		for _, t := range ts.Thresholds {
+			s = append(s, t.Source)
+		}
+		return s
+	}
+	runnerOpts := lib.Options{Thresholds: map[string]stats.Thresholds{
+		"http_req_duration": {Thresholds: []*stats.Threshold{{Source: "p(95)<500"}, {Source: "max<3000"}}},
+	}}
+	cliConf := Config{ThresholdsFile: null.StringFrom("/slo.json")}
+	conf, err := getConsolidatedConfig(fs, cliConf, runnerOpts, nil, globalFlags, lib.RuntimeOptions{}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"p(95)<500", "max<3000", "p(99)<1500"}, sources(conf.Thresholds["http_req_duration"]))
+	assert.Equal(t, []string{"rate>0.99"}, sources(conf.Thresholds["checks"]))
+
+	env := map[string]string{"K6_THRESHOLDS_FILE": "/missing.json"}
+	_, err = getConsolidatedConfig(fs, Config{}, lib.Options{}, env, globalFlags, lib.RuntimeOptions{}, logger)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "couldn't read the thresholds file")
+	var ecerr errext.HasExitCode
+	require.ErrorAs(t, err, &ecerr)
+	assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+
+	require.NoError(t, afero.WriteFile(fs, "/invalid.json", []byte(`["p(95)<500"]`), 0o644))
+	cliConf = Config{ThresholdsFile: null.StringFrom("/invalid.json")}
+	_, err = getConsolidatedConfig(fs, cliConf, lib.Options{}, nil, globalFlags, lib.RuntimeOptions{}, logger)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the thresholds file /invalid.json is invalid")
+}
+
+func TestConsolidatedConfigUnknownFields(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/config.json", []byte(`{"vus": 2, "iteratoins": 10}`), 0o644))
+	globalFlags := newCommandFlags()
+	globalFlags.configFilePath = "/config.json"
+
+	logger, hook := logtest.NewNullLogger()
+	conf, err := getConsolidatedConfig(fs, Config{}, lib.Options{}, nil, globalFlags, lib.RuntimeOptions{}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(2), conf.VUs)
+	require.Len(t, hook.AllEntries(), 1)
+	assert.Equal(t, "There were unknown fields in the config file /config.json", hook.LastEntry().Message)
+
+	strict := lib.RuntimeOptions{StrictOptions: null.BoolFrom(true)}
+	_, err = getConsolidatedConfig(fs, Config{}, lib.Options{}, nil, globalFlags, strict, logger)
+	var ecerr errext.HasExitCode
+	require.ErrorAs(t, err, &ecerr)
+	assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+	assert.Contains(t, err.Error(), `unknown field "iteratoins"`)
+}
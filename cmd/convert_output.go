@@ -0,0 +1,231 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/loader"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+func getConvertOutputCmd(ctx context.Context, logger *logrus.Logger, globalFlags *commandFlags) *cobra.Command {
+	convertOutputCmd := &cobra.Command{
+		Use:   "convert-output [file]",
+		Short: "Send the results of a past test to outputs",
+		Long: `Send the results of a past test to outputs.
+
+The results are the raw metric samples written by the json or the csv output,
+optionally gzipped, and they are sent with their original times to the outputs
+of --out, as fast as the outputs take them or faster than they were recorded
+with --speed. The outputs are configured like for k6 run, with their arguments,
+the config file and the environment variables.`,
+		Example: `
+  # Send the results of a test to an InfluxDB database.
+  k6 convert-output --out influxdb=http://localhost:8086/k6 results.json
+
+  # Send them 10 times faster than they were recorded.
+  k6 convert-output --speed 10 --out influxdb=http://localhost:8086/k6 results.json.gz`[1:],
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			flags := cmd.Flags()
+			outs, err := flags.GetStringArray("out")
+			if err != nil {
+				return err
+			}
+			if len(outs) == 0 {
+				return errext.WithExitCodeIfNone(errors.New("at least one output is required, use --out"),
+					exitcodes.InvalidConfig)
+			}
+			speed, err := flags.GetFloat64("speed")
+			if err != nil {
+				return err
+			}
+			batchSize, err := flags.GetInt("batch-size")
+			if err != nil {
+				return err
+			}
+			if speed < 0 || batchSize < 1 {
+				return errext.WithExitCodeIfNone(errors.New("the speed can't be negative and the batch size "+
+					"should be at least 1"), exitcodes.InvalidConfig)
+			}
+			format, err := flags.GetString("format")
+			if err != nil {
+				return err
+			}
+
+			conf, _, err := readDiskConfig(afero.NewOsFs(), globalFlags)
+			if err != nil {
+				return err
+			}
+			conf = applyDefault(conf)
+			conf.Out = outs
+			runtimeOptions, err := getRuntimeOptions(flags, buildEnvMap(os.Environ()))
+			if err != nil {
+				return err
+			}
+			path, err := filepath.Abs(args[0])
+			if err != nil {
+				return err
+			}
+			src := &loader.SourceData{URL: &url.URL{Scheme: "file", Path: filepath.ToSlash(path)}}
+			outputs, err := createOutputs(
+				conf.Out, src, conf, runtimeOptions, nil, buildEnvMap(os.Environ()), nil, logger, globalFlags)
+			if err != nil {
+				return errext.WithExitCodeIfNone(err, exitcodes.OutputFailed)
+			}
+
+			ctx, cancel := context.WithCancel(ctx)
+			defer cancel()
+			converter := &outputConverter{outputs: outputs, speed: speed, batchSize: batchSize}
+			if err = converter.start(cancel, logger); err != nil {
+				return errext.WithExitCodeIfNone(err, exitcodes.OutputFailed)
+			}
+			err = readResults(afero.NewOsFs(), args[0], format, func(sample stats.Sample) {
+				converter.add(ctx, sample)
+			})
+			converter.stop(logger)
+			if err != nil {
+				return err
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			descriptions := make([]string, len(outputs))
+			for i, out := range outputs {
+				descriptions[i] = out.Description()
+			}
+			fprintf(globalFlags.stdout, "Sent %d samples to %s\n", converter.sent, strings.Join(descriptions, ", "))
+			return nil
+		},
+	}
+
+	flags := convertOutputCmd.Flags()
+	flags.SortFlags = false
+	flags.StringArrayP("out", "o", nil, "`uri` for an external metrics database; can be repeated")
+	flags.Float64("speed", 0, "send the samples this many times faster than they were recorded, "+
+		"0 sends them as fast as possible")
+	flags.Int("batch-size", 1000, "send the samples to the outputs in batches of at most `n` samples")
+	flags.String("format", "", "the format of the results, json or csv (default from the file extension)")
+	flags.AddFlagSet(runtimeOptionFlagSet(false))
+	return convertOutputCmd
+}
+
+// outputConverter sends the samples of past results to outputs, in batches.
+type outputConverter struct {
+	outputs   []output.Output
+	speed     float64
+	batchSize int
+
+	batch []stats.SampleContainer
+	sent  int
+	// first is the time of the first sample, and started is when it was sent.
+	first, started time.Time
+}
+
+// start starts the outputs, and cancels the conversion if one of them asks to
+// stop the test.
+func (c *outputConverter) start(cancel func(), logger logrus.FieldLogger) error {
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	for i, out := range c.outputs {
+		if stopOut, ok := out.(output.WithTestRunStop); ok {
+			stopOut.SetTestRunStopCallback(func(err error) {
+				logger.WithError(err).Error("Received error to stop from output")
+				cancel()
+			})
+		}
+		if builtinMetricOut, ok := out.(output.WithBuiltinMetrics); ok {
+			builtinMetricOut.SetBuiltinMetrics(builtinMetrics)
+		}
+		if err := out.Start(); err != nil {
+			c.stopOutputs(i, logger)
+			return err
+		}
+	}
+	return nil
+}
+
+// add adds the sample to the batch, and sends the batch once it's full, or
+// before waiting for the time of the sample with a speed.
+func (c *outputConverter) add(ctx context.Context, sample stats.Sample) {
+	if ctx.Err() != nil {
+		return
+	}
+	if c.started.IsZero() {
+		c.first, c.started = sample.Time, time.Now()
+	}
+	if c.speed > 0 {
+		elapsed := time.Duration(float64(sample.Time.Sub(c.first)) / c.speed)
+		if wait := time.Until(c.started.Add(elapsed)); wait > 0 {
+			c.flush()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}
+	c.batch = append(c.batch, sample)
+	if len(c.batch) >= c.batchSize {
+		c.flush()
+	}
+}
+
+func (c *outputConverter) flush() {
+	if len(c.batch) == 0 {
+		return
+	}
+	for _, out := range c.outputs {
+		out.AddMetricSamples(c.batch)
+	}
+	c.sent += len(c.batch)
+	c.batch = make([]stats.SampleContainer, 0, c.batchSize)
+}
+
+// stop sends the last batch and stops the outputs, which flush their buffers.
+func (c *outputConverter) stop(logger logrus.FieldLogger) {
+	c.flush()
+	c.stopOutputs(len(c.outputs), logger)
+}
+
+func (c *outputConverter) stopOutputs(upTo int, logger logrus.FieldLogger) {
+	for _, out := range c.outputs[:upTo] {
+		if err := out.Stop(); err != nil {
+			logger.WithError(err).Errorf("Stopping the output %s failed", out.Description())
+		}
+	}
+}
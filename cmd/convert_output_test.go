@@ -0,0 +1,92 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/testutils/mockoutput"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+func TestConvertOutputCmd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	results := filepath.Join(dir, "results.json")
+	require.NoError(t, ioutil.WriteFile(results, []byte(replayJSONResults), 0o600))
+	converted := filepath.Join(dir, "converted.csv")
+
+	stdout := &bytes.Buffer{}
+	globalFlags := newCommandFlags()
+	globalFlags.stdout = &consoleWriter{Writer: stdout, Mutex: &sync.Mutex{}}
+	cmd := getConvertOutputCmd(context.Background(), testutils.NewLogger(t), globalFlags)
+	cmd.SetArgs([]string{"--out", "csv=" + converted, results})
+	require.NoError(t, cmd.Execute())
+	assert.Contains(t, stdout.String(), "Sent 4 samples to csv ("+converted+")")
+
+	var names []string
+	require.NoError(t, readResults(afero.NewOsFs(), converted, "", func(sample stats.Sample) {
+		names = append(names, sample.Metric.Name)
+	}))
+	assert.Equal(t, []string{"http_req_duration", "http_req_duration", "checks", "checks"}, names)
+
+	cmd = getConvertOutputCmd(context.Background(), testutils.NewLogger(t), newCommandFlags())
+	cmd.SetArgs([]string{results})
+	assert.Error(t, cmd.Execute(), "an output is required")
+}
+
+func TestOutputConverter(t *testing.T) {
+	t.Parallel()
+	metric := stats.New("my_metric", stats.Counter)
+	start := time.Now()
+	mockOutput := mockoutput.New()
+	converter := &outputConverter{outputs: []output.Output{mockOutput}, speed: 10, batchSize: 2}
+	require.NoError(t, converter.start(func() {}, testutils.NewLogger(t)))
+
+	began := time.Now()
+	for i := 0; i < 5; i++ {
+		converter.add(context.Background(), stats.Sample{
+			Metric: metric, Time: start.Add(time.Duration(i) * time.Second), Value: 1,
+		})
+	}
+	converter.stop(testutils.NewLogger(t))
+	assert.GreaterOrEqual(t, int64(time.Since(began)), int64(400*time.Millisecond), "4s of samples at 10x")
+	assert.Len(t, mockOutput.Samples, 5)
+	assert.Equal(t, 5, converter.sent)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	converter.add(ctx, stats.Sample{Metric: metric, Time: start, Value: 1})
+	converter.flush()
+	assert.Equal(t, 5, converter.sent)
+}
@@ -0,0 +1,105 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+)
+
+// exitCodeCategories are the failure categories which can be mapped to custom
+// exit codes, with the exit codes k6 uses for them by default.
+var exitCodeCategories = map[string]errext.ExitCode{ //nolint:gochecknoglobals
+	"threshold-failed":     exitcodes.ThresholdsHaveFailed,
+	"script-exception":     exitcodes.ScriptException,
+	"aborted-by-user":      exitcodes.ExternalAbort,
+	"output-error":         exitcodes.OutputFailed,
+	"infrastructure-error": exitcodes.InfrastructureError,
+}
+
+// ExitCodeMapping maps failure categories to the exit codes k6 should exit
+// with instead of the default ones, e.g. {"threshold-failed": 3}.
+type ExitCodeMapping map[string]errext.ExitCode
+
+// Validate returns an error if any of the categories is unknown.
+func (m ExitCodeMapping) Validate() error {
+	for category := range m {
+		if _, ok := exitCodeCategories[category]; !ok {
+			categories := make([]string, 0, len(exitCodeCategories))
+			for c := range exitCodeCategories {
+				categories = append(categories, c)
+			}
+			sort.Strings(categories)
+			return fmt.Errorf("invalid exit code category '%s', available categories are: %s",
+				category, strings.Join(categories, ", "))
+		}
+	}
+	return nil
+}
+
+// exitCode returns the exit code k6 should exit with instead of the given one.
+func (m ExitCodeMapping) exitCode(code errext.ExitCode) errext.ExitCode {
+	for category, custom := range m {
+		if exitCodeCategories[category] == code {
+			return custom
+		}
+	}
+	return code
+}
+
+// apply replaces the exit code of err, if the user mapped its category to a
+// custom exit code.
+func (m ExitCodeMapping) apply(err error) error {
+	if err == nil || len(m) == 0 {
+		return err
+	}
+	var ecerr errext.HasExitCode
+	if !errors.As(err, &ecerr) {
+		return err
+	}
+	if code := m.exitCode(ecerr.ExitCode()); code != ecerr.ExitCode() {
+		return errext.WithExitCode(err, code)
+	}
+	return err
+}
+
+// getExitCodeMapping returns the custom exit codes from the config file and the
+// environment variables. They are needed before the script is loaded, since it
+// can fail with a script exception as well.
+func getExitCodeMapping(fs afero.Fs, envMap map[string]string, globalFlags *commandFlags) (ExitCodeMapping, error) {
+	fileConf, _, err := readDiskConfig(fs, globalFlags)
+	if err != nil {
+		return nil, err
+	}
+	envConf, err := readEnvConfig(envMap)
+	if err != nil {
+		return nil, err
+	}
+	exitCodes := fileConf.Apply(envConf).ExitCodes
+	return exitCodes, exitCodes.Validate()
+}
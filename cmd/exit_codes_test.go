@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+)
+
+func TestExitCodeMapping(t *testing.T) {
+	t.Parallel()
+	mapping := ExitCodeMapping{"threshold-failed": 3, "infrastructure-error": 4, "aborted-by-user": 5}
+	require.NoError(t, mapping.Validate())
+	assert.Error(t, ExitCodeMapping{"thresholds": 3}.Validate())
+
+	assert.Equal(t, errext.ExitCode(5), mapping.exitCode(exitcodes.ExternalAbort))
+	assert.Equal(t, exitcodes.ScriptException, mapping.exitCode(exitcodes.ScriptException))
+
+	assertExitCode := func(t *testing.T, err error, exitCode errext.ExitCode) {
+		t.Helper()
+		var ecerr errext.HasExitCode
+		require.ErrorAs(t, err, &ecerr)
+		assert.Equal(t, exitCode, ecerr.ExitCode())
+	}
+
+	thresholdsErr := errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), exitcodes.ThresholdsHaveFailed)
+	assertExitCode(t, mapping.apply(thresholdsErr), 3)
+	assert.Equal(t, "some thresholds have failed", mapping.apply(thresholdsErr).Error())
+
+	infraErr := mapping.apply(fmt.Errorf("could not start: %w", infrastructureError{errors.New("connection refused")}))
+	assertExitCode(t, infraErr, 4)
+	assert.True(t, isInfrastructureError(infraErr))
+
+	scriptErr := errext.WithExitCodeIfNone(errors.New("boom"), exitcodes.ScriptException)
+	assert.Equal(t, scriptErr, mapping.apply(scriptErr))
+	plainErr := errors.New("no exit code")
+	assert.Equal(t, plainErr, mapping.apply(plainErr))
+	assert.NoError(t, mapping.apply(nil))
+	assert.Equal(t, thresholdsErr, ExitCodeMapping(nil).apply(thresholdsErr))
+}
+
+func TestGetExitCodeMapping(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	globalFlags := newCommandFlags()
+	globalFlags.configFilePath = "/config.json"
+	require.NoError(t, afero.WriteFile(fs, "/config.json",
+		[]byte(`{"exitCodes": {"threshold-failed": 3, "output-error": 4}}`), 0o644))
+
+	mapping, err := getExitCodeMapping(fs, nil, globalFlags)
+	require.NoError(t, err)
+	assert.Equal(t, ExitCodeMapping{"threshold-failed": 3, "output-error": 4}, mapping)
+
+	mapping, err = getExitCodeMapping(fs, map[string]string{"K6_EXIT_CODES": "script-exception:7"}, globalFlags)
+	require.NoError(t, err)
+	assert.Equal(t, ExitCodeMapping{"script-exception": 7}, mapping)
+
+	_, err = getExitCodeMapping(fs, map[string]string{"K6_EXIT_CODES": "timeout:7"}, globalFlags)
+	assert.Error(t, err)
+	_, err = getExitCodeMapping(fs, map[string]string{"K6_EXIT_CODES": "script-exception:700"}, globalFlags)
+	assert.Error(t, err)
+}
@@ -0,0 +1,113 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+)
+
+// The names of the hooks, which are also the values of the K6_HOOK env var.
+const (
+	hookBeforeTest = "beforeTest"
+	hookAfterTest  = "afterTest"
+	hookOnAbort    = "onAbort"
+)
+
+// hookRunner runs the commands of the hooks option, with the k6 env vars and:
+//   - K6_HOOK: the name of the hook
+//   - K6_SUMMARY_PATHS: the files the end-of-test summary was saved to, as a
+//     list separated by the OS path list separator, like PATH
+type hookRunner struct {
+	hooks          lib.Hooks
+	logger         logrus.FieldLogger
+	stdout, stderr io.Writer
+	summaryPaths   []string
+}
+
+func newHookRunner(hooks lib.Hooks, logger logrus.FieldLogger, globalFlags *commandFlags) *hookRunner {
+	return &hookRunner{hooks: hooks, logger: logger, stdout: globalFlags.stdout, stderr: globalFlags.stderr}
+}
+
+// setSummaryPaths saves the files of the summary result, for the hooks that
+// run after it was handled.
+func (h *hookRunner) setSummaryPaths(summaryResult map[string]io.Reader) {
+	h.summaryPaths = h.summaryPaths[:0]
+	for path := range summaryResult {
+		if path != "stdout" && path != "stderr" {
+			h.summaryPaths = append(h.summaryPaths, path)
+		}
+	}
+	sort.Strings(h.summaryPaths)
+}
+
+func (h *hookRunner) run(ctx context.Context, name string, command null.String) error {
+	if command.String == "" {
+		return nil
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command.String) //nolint:gosec
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command.String) //nolint:gosec
+	}
+	cmd.Env = append(os.Environ(),
+		"K6_HOOK="+name,
+		"K6_SUMMARY_PATHS="+strings.Join(h.summaryPaths, string(os.PathListSeparator)),
+	)
+	cmd.Stdout, cmd.Stderr = h.stdout, h.stderr
+
+	h.logger.WithField("hook", name).Debugf("Running the hook command %q...", command.String)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("the %s hook failed: %w", name, err)
+	}
+	return nil
+}
+
+// beforeTest runs the beforeTest hook, the test shouldn't be started if it
+// fails.
+func (h *hookRunner) beforeTest(ctx context.Context) error {
+	return h.run(ctx, hookBeforeTest, h.hooks.BeforeTest)
+}
+
+// testFinished runs the onAbort hook if the test was aborted, and then the
+// afterTest hook. Their errors are only logged, since the test is over.
+func (h *hookRunner) testFinished(ctx context.Context, aborted bool) {
+	if aborted {
+		if err := h.run(ctx, hookOnAbort, h.hooks.OnAbort); err != nil {
+			h.logger.WithError(err).Error("Error running a hook")
+		}
+	}
+	if err := h.run(ctx, hookAfterTest, h.hooks.AfterTest); err != nil {
+		h.logger.WithError(err).Error("Error running a hook")
+	}
+}
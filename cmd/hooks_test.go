@@ -0,0 +1,116 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib/testutils"
+)
+
+// runHooksScript runs a script with hooks that append their name and the
+// summary paths to a log file, and returns the error of the run and the log.
+func runHooksScript(t *testing.T, body string, hooks string, args ...string) (error, string) { //nolint:revive
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the hooks of the tests are unix shell commands")
+	}
+	dir := t.TempDir()
+	log := filepath.Join(dir, "hooks.log")
+	script := fmt.Sprintf(`
+		import exec from "k6/execution";
+		const log = %q;
+		export const options = {hooks: %s};
+		export default function() { %s }
+	`, log, hooks, body)
+	scriptPath := filepath.Join(dir, "script.js")
+	require.NoError(t, ioutil.WriteFile(scriptPath, []byte(script), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cmd := getRunCmd(ctx, testutils.NewLogger(t), newCommandFlags())
+	cmd.SetArgs(append([]string{"--no-usage-report", scriptPath}, args...))
+	err := cmd.Execute()
+
+	data, readErr := ioutil.ReadFile(log) //nolint:gosec
+	if readErr != nil {
+		data = nil
+	}
+	return err, string(data)
+}
+
+const testHooks = `{
+	beforeTest: 'echo "$K6_HOOK" >> ' + log,
+	afterTest: 'echo "$K6_HOOK $K6_SUMMARY_PATHS" >> ' + log,
+	onAbort: 'echo "$K6_HOOK" >> ' + log,
+}`
+
+func TestHooks(t *testing.T) {
+	t.Parallel()
+
+	t.Run("completed", func(t *testing.T) {
+		t.Parallel()
+		summaryPath := filepath.Join(t.TempDir(), "summary.json")
+		err, log := runHooksScript(t, "", testHooks, "--allow-exec", "--summary-export", summaryPath)
+		require.NoError(t, err)
+		assert.Equal(t, "beforeTest\nafterTest "+summaryPath+"\n", log)
+	})
+
+	t.Run("aborted", func(t *testing.T) {
+		t.Parallel()
+		err, log := runHooksScript(t, "exec.test.abort()", testHooks, "--allow-exec")
+		var e errext.HasExitCode
+		require.ErrorAs(t, err, &e)
+		assert.Equal(t, exitcodes.ScriptAborted, e.ExitCode())
+		assert.Equal(t, "beforeTest\nonAbort\nafterTest \n", log)
+	})
+
+	t.Run("before test failed", func(t *testing.T) {
+		t.Parallel()
+		hooks := `{beforeTest: 'exit 3', afterTest: 'echo "$K6_HOOK" >> ' + log}`
+		err, log := runHooksScript(t, "", hooks, "--allow-exec")
+		var e errext.HasExitCode
+		require.ErrorAs(t, err, &e)
+		assert.Equal(t, exitcodes.HookFailed, e.ExitCode())
+		assert.Contains(t, err.Error(), "the beforeTest hook failed: exit status 3")
+		assert.Empty(t, log)
+	})
+
+	t.Run("not allowed", func(t *testing.T) {
+		t.Parallel()
+		err, log := runHooksScript(t, "", testHooks)
+		var e errext.HasExitCode
+		require.ErrorAs(t, err, &e)
+		assert.Equal(t, exitcodes.InvalidConfig, e.ExitCode())
+		assert.Contains(t, err.Error(), "the hooks option can only be used with the --allow-exec flag")
+		assert.Empty(t, log)
+	})
+}
@@ -35,6 +35,7 @@ import (
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/pkg/testrun"
 )
 
 func getInspectCmd(logger *logrus.Logger, globalFlags *commandFlags) *cobra.Command {
@@ -62,7 +63,7 @@ func getInspectCmd(logger *logrus.Logger, globalFlags *commandFlags) *cobra.Comm
 			var b *js.Bundle
 			typ := globalFlags.runType
 			if typ == "" {
-				typ = detectType(src.Data)
+				typ = testrun.DetectType(src.Data)
 			}
 			switch typ {
 			// this is an exhaustive list
@@ -124,7 +125,8 @@ func addExecRequirements(b *js.Bundle,
 	}
 
 	conf, err := getConsolidatedConfig(
-		afero.NewOsFs(), Config{}, runner.GetOptions(), buildEnvMap(os.Environ()), globalFlags)
+		afero.NewOsFs(), Config{}, runner.GetOptions(), buildEnvMap(os.Environ()), globalFlags,
+		b.RuntimeOptions, logger)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,82 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"go.k6.io/k6/js/lint"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/pkg/testrun"
+)
+
+func getLintCmd(logger *logrus.Logger, stdout io.Writer) *cobra.Command {
+	lintCmd := &cobra.Command{
+		Use:   "lint [file]",
+		Short: "Check a script for common load testing mistakes",
+		Long: `Check a script for common load testing mistakes.
+
+The script isn't run, only its source is checked for:
+  - custom metrics created outside of the init context,
+  - open() called outside of the init context,
+  - a default function without sleep(), when no arrival-rate executor is used,
+  - requests to URLs which change between iterations, without a name tag.
+
+Every warning is printed with its position, and k6 exits with an error if
+there are any.`,
+		Example: `
+  # Check a script.
+  k6 lint script.js`[1:],
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			src, _, err := readSource(args[0], logger)
+			if err != nil {
+				return err
+			}
+			filename, data := src.URL.String(), src.Data
+			if testrun.DetectType(src.Data) == typeArchive {
+				arc, aerr := lib.ReadArchive(bytes.NewBuffer(src.Data))
+				if aerr != nil {
+					return aerr
+				}
+				filename, data = arc.FilenameURL.String(), arc.Data
+			}
+
+			warnings, err := lint.Lint(logger, filename, string(data))
+			if err != nil {
+				return err
+			}
+			for _, w := range warnings {
+				fprintf(stdout, "%s:%s\n", args[0], w)
+			}
+			if len(warnings) > 0 {
+				return fmt.Errorf("%d problems were found in the script", len(warnings))
+			}
+			return nil
+		},
+	}
+	return lintCmd
+}
@@ -0,0 +1,65 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/testutils"
+)
+
+func TestLintCmd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	bad := filepath.Join(dir, "bad.js")
+	require.NoError(t, os.WriteFile(bad, []byte(`import { Counter } from "k6/metrics";
+export default function () {
+	new Counter("my_counter").add(1);
+}
+`), 0o600))
+	good := filepath.Join(dir, "good.js")
+	require.NoError(t, os.WriteFile(good, []byte(`import { sleep } from "k6";
+export default function () {
+	sleep(1);
+}
+`), 0o600))
+
+	stdout := &bytes.Buffer{}
+	cmd := getLintCmd(testutils.NewLogger(t), stdout)
+	cmd.SetArgs([]string{bad})
+	err := cmd.Execute()
+	require.Error(t, err)
+	assert.Equal(t, "2 problems were found in the script", err.Error())
+	assert.Contains(t, stdout.String(), bad+":2:16: [missing-sleep]")
+	assert.Contains(t, stdout.String(), bad+":3:2: [metric-in-vu-code]")
+
+	stdout.Reset()
+	cmd = getLintCmd(testutils.NewLogger(t), stdout)
+	cmd.SetArgs([]string{good})
+	require.NoError(t, cmd.Execute())
+	assert.Empty(t, stdout.String())
+}
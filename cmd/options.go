@@ -24,14 +24,13 @@ import (
 	"errors"
 	"fmt"
 	"strings"
-	"time"
 
 	"github.com/spf13/pflag"
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib"
-	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/pkg/testrun"
 	"go.k6.io/k6/stats"
 )
 
@@ -44,7 +43,8 @@ var (
 func optionFlagSet() *pflag.FlagSet {
 	flags := pflag.NewFlagSet("", 0)
 	flags.SortFlags = false
-	flags.Int64P("vus", "u", 1, "number of virtual users")
+	defaults := testrun.DefaultOptions()
+	flags.Int64P("vus", "u", defaults.VUs.Int64, "number of virtual users")
 
 	// TODO: delete in a few versions
 	flags.Int64P("max", "m", 0, "max available virtual users")
@@ -58,11 +58,11 @@ func optionFlagSet() *pflag.FlagSet {
 	flags.BoolP("paused", "p", false, "start the test in a paused state")
 	flags.Bool("no-setup", false, "don't run setup()")
 	flags.Bool("no-teardown", false, "don't run teardown()")
-	flags.Int64("max-redirects", 10, "follow at most n redirects")
-	flags.Int64("batch", 20, "max parallel batch reqs")
-	flags.Int64("batch-per-host", 6, "max parallel batch reqs per host")
+	flags.Int64("max-redirects", defaults.MaxRedirects.Int64, "follow at most n redirects")
+	flags.Int64("batch", defaults.Batch.Int64, "max parallel batch reqs")
+	flags.Int64("batch-per-host", defaults.BatchPerHost.Int64, "max parallel batch reqs per host")
 	flags.Int64("rps", 0, "limit requests per second")
-	flags.String("user-agent", fmt.Sprintf("k6/%s (https://k6.io/)", consts.Version), "user agent for http requests")
+	flags.String("user-agent", defaults.UserAgent.String, "user agent for http requests")
 	flags.String("http-debug", "", "log all HTTP requests and responses. Excludes body by default. To include body use '--http-debug=full'") //nolint:lll
 	flags.Lookup("http-debug").NoOptDefVal = "headers"
 	flags.Bool("insecure-skip-tls-verify", false, "skip verification of TLS certificates")
@@ -82,6 +82,19 @@ func optionFlagSet() *pflag.FlagSet {
 	)
 	flags.StringSlice("summary-trend-stats", nil, sumTrendStatsHelp)
 	flags.String("summary-time-unit", "", "define the time unit used to display the trend stats. Possible units are: 's', 'ms' and 'us'") //nolint:lll
+	flags.Duration("summary-timeline-interval", 0, "aggregate the key metrics over intervals of this `duration` "+
+		"for the timeline in the summary export, 0 disables it")
+	flags.Duration("anomaly-detection-interval", 0, "detect error rate spikes, latency step changes and "+
+		"throughput collapses by comparing the requests over intervals of this `duration`, 0 disables it")
+	flags.Duration("thresholds-evaluation-interval", defaults.ThresholdsEvaluationInterval.TimeDuration(), "evaluate the thresholds every `duration`")
+	flags.Duration("thresholds-evaluation-delay", 0, "first evaluate the thresholds after this `duration` "+
+		"instead of after one interval")
+	flags.Int64("trend-precision", 0, fmt.Sprintf("round the trend metric values to `n` significant digits "+
+		"(1-%d) to bound their memory, 0 keeps them exact", stats.MaxTrendPrecision))
+	flags.String("trend-percentile-method", defaults.TrendPercentileMethod.String, "calculate the percentiles of the "+
+		"trend metrics with 'linear' interpolation or the 'nearest-rank' `method`")
+	flags.Duration("gauge-ewma-half-life", defaults.GaugeEWMAHalfLife.TimeDuration(), "halve the weight of the gauge metric "+
+		"values in their moving average, 'ewma', every `duration`")
 	// system-tags must have a default value, but we can't specify it here, otherwiese, it will always override others.
 	// set it to nil here, and add the default in applyDefault() instead.
 	systemTagsCliHelpText := fmt.Sprintf(
@@ -90,20 +103,34 @@ func optionFlagSet() *pflag.FlagSet {
 	)
 	flags.StringSlice("system-tags", nil, systemTagsCliHelpText)
 	flags.StringSlice("tag", nil, "add a `tag` to be applied to all samples, as `[name]=[value]`")
+	flags.StringArray("tag-transform", nil, "transform the values of a tag before thresholds and outputs, "+
+		"as `[name]=[rule]`, e.g. 'url=truncate(64)' or 'user=hash'")
+	flags.Int64("max-tag-combinations", 0, "replace the tag values of the new combinations of a metric "+
+		"with '__overflow__' once it has `n` distinct ones, 0 disables the limit")
 	flags.String("console-output", "", "redirects the console logging to the provided output file")
+	flags.String("check-failure-capture-dir", "", "save the requests and responses that made checks fail "+
+		"to files in the provided `directory`")
 	flags.Bool("discard-response-bodies", false, "Read but don't process or save HTTP response bodies")
+	flags.Bool("isolate-setup-teardown-metrics", false, "aggregate the samples of setup() and teardown() "+
+		"only in the sub-metrics of their groups, not in the metrics and their thresholds")
+	flags.Bool("typed-tags", false, "keep the number and boolean tag values of the custom metrics typed "+
+		"for the outputs, instead of their string forms")
 	flags.String("local-ips", "", "Client IP Ranges and/or CIDRs from which each VU will be making requests, "+
 		"e.g. '192.168.220.1,192.168.0.10-192.168.0.25', 'fd:1::0/120', etc.")
+	flags.String("interface", "", "`name` of the network interface the VUs make their requests from, "+
+		"instead of the local IPs")
 	flags.String("dns", types.DefaultDNSConfig().String(), "DNS resolver configuration. Possible ttl values are: 'inf' "+
 		"for a persistent cache, '0' to disable the cache,\nor a positive duration, e.g. '1s', '1m', etc. "+
 		"Milliseconds are assumed if no unit is provided.\n"+
 		"Possible select values to return a single IP are: 'first', 'random' or 'roundRobin'.\n"+
-		"Possible policy values are: 'preferIPv4', 'preferIPv6', 'onlyIPv4', 'onlyIPv6' or 'any'.\n")
+		"Possible policy values are: 'preferIPv4', 'preferIPv6', 'onlyIPv4', 'onlyIPv6', 'any' or 'happyEyeballs',\n"+
+		"which races the connections to an IPv6 and an IPv4 address.\n")
 	return flags
 }
 
 //nolint:funlen,gocognit,cyclop // this needs breaking up but probably should wait for croconf
 func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
+	defaults := testrun.DefaultOptions()
 	opts := lib.Options{
 		VUs:                   getNullInt64(flags, "vus"),
 		Duration:              getNullDuration(flags, "duration"),
@@ -123,12 +150,24 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		MinIterationDuration:  getNullDuration(flags, "min-iteration-duration"),
 		Throw:                 getNullBool(flags, "throw"),
 		DiscardResponseBodies: getNullBool(flags, "discard-response-bodies"),
+		TrendPrecision:        getNullInt64(flags, "trend-precision"),
+		TrendPercentileMethod: getNullString(flags, "trend-percentile-method"),
+		MaxTagCombinations:    getNullInt64(flags, "max-tag-combinations"),
+		Interface:             getNullString(flags, "interface"),
+
+		SummaryTimelineInterval:      getNullDuration(flags, "summary-timeline-interval"),
+		AnomalyDetectionInterval:     getNullDuration(flags, "anomaly-detection-interval"),
+		ThresholdsEvaluationInterval: getNullDuration(flags, "thresholds-evaluation-interval"),
+		ThresholdsEvaluationDelay:    getNullDuration(flags, "thresholds-evaluation-delay"),
+		GaugeEWMAHalfLife:            getNullDuration(flags, "gauge-ewma-half-life"),
+		IsolateSetupTeardownMetrics:  getNullBool(flags, "isolate-setup-teardown-metrics"),
+		TypedTags:                    getNullBool(flags, "typed-tags"),
 		// Default values for options without CLI flags:
 		// TODO: find a saner and more dev-friendly and error-proof way to handle options
-		SetupTimeout:    types.NullDuration{Duration: types.Duration(60 * time.Second), Valid: false},
-		TeardownTimeout: types.NullDuration{Duration: types.Duration(60 * time.Second), Valid: false},
+		SetupTimeout:    defaults.SetupTimeout,
+		TeardownTimeout: defaults.TeardownTimeout,
 
-		MetricSamplesBufferSize: null.NewInt(1000, false),
+		MetricSamplesBufferSize: defaults.MetricSamplesBufferSize,
 	}
 
 	// Using Changed() because GetStringSlice() doesn't differentiate between empty and no value
@@ -258,6 +297,23 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		opts.RunTags = stats.IntoSampleTags(&parsedRunTags)
 	}
 
+	if flags.Changed("tag-transform") {
+		tagTransforms, errTT := flags.GetStringArray("tag-transform")
+		if errTT != nil {
+			return opts, errTT
+		}
+		opts.TagTransforms = make(stats.TagTransforms, len(tagTransforms))
+		for _, s := range tagTransforms {
+			name, rule, errTT := parseTagNameValue(s)
+			if errTT != nil {
+				return opts, fmt.Errorf("error parsing tag transform '%s': %w", s, errTT)
+			}
+			if opts.TagTransforms[name], errTT = stats.NewTagTransform(rule); errTT != nil {
+				return opts, errTT
+			}
+		}
+	}
+
 	redirectConFile, err := flags.GetString("console-output")
 	if err != nil {
 		return opts, err
@@ -267,6 +323,15 @@ func getOptions(flags *pflag.FlagSet) (lib.Options, error) {
 		opts.ConsoleOutput = null.StringFrom(redirectConFile)
 	}
 
+	captureDir, err := flags.GetString("check-failure-capture-dir")
+	if err != nil {
+		return opts, err
+	}
+
+	if captureDir != "" {
+		opts.CheckFailureCaptureDir = null.StringFrom(captureDir)
+	}
+
 	if dns, err := flags.GetString("dns"); err != nil {
 		return opts, err
 	} else if dns != "" {
@@ -83,8 +83,8 @@ func getPossibleIDList(constrs map[string]func(output.Params) (output.Output, er
 
 func createOutputs(
 	outputFullArguments []string, src *loader.SourceData, conf Config, rtOpts lib.RuntimeOptions,
-	executionPlan []lib.ExecutionStep, osEnvironment map[string]string, logger logrus.FieldLogger,
-	globalFlags *commandFlags,
+	executionPlan []lib.ExecutionStep, osEnvironment map[string]string, metadata map[string]string,
+	logger logrus.FieldLogger, globalFlags *commandFlags,
 ) ([]output.Output, error) {
 	outputConstructors, err := getAllOutputConstructors()
 	if err != nil {
@@ -100,6 +100,7 @@ func createOutputs(
 		ScriptOptions:  conf.Options,
 		RuntimeOptions: rtOpts,
 		ExecutionPlan:  executionPlan,
+		Metadata:       metadata,
 	}
 	result := make([]output.Output, 0, len(outputFullArguments))
 
@@ -0,0 +1,176 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/netext"
+)
+
+const (
+	// portRangePath is where Linux has the range of the ephemeral ports the
+	// connections are opened from.
+	portRangePath = "/proc/sys/net/ipv4/ip_local_port_range"
+
+	// reservedFiles is how many open files the preflight check keeps for
+	// everything but the sockets of the VUs, e.g. the scripts and the outputs.
+	reservedFiles = 64
+)
+
+// socketBudget is what the sockets a test may need are compared with. A
+// value of 0 is unknown, and isn't checked.
+type socketBudget struct {
+	// openFiles is the soft limit of open files of the process
+	openFiles uint64
+	// ephemeralPorts is how many ports the connections can be opened from
+	ephemeralPorts uint64
+}
+
+// interfaceConfig is implemented by the executor configs which can set the
+// network interface the VUs make their connections from.
+type interfaceConfig interface {
+	GetInterface() string
+}
+
+// prewarmConfig is implemented by the executor configs which can open
+// connections before the first iteration of the VUs.
+type prewarmConfig interface {
+	GetPrewarmConnections() (int, []string)
+}
+
+// estimateSockets returns how many sockets the VUs of the test may have open
+// at the same time. Every VU opens one for its requests, or as many as it
+// sends in parallel with http.batch(), or as many as it prewarms, and keeps
+// them open across its scenarios.
+func estimateSockets(options lib.Options, et *lib.ExecutionTuple) uint64 {
+	perVU := int64(1)
+	if options.Batch.Int64 > perVU {
+		perVU = options.Batch.Int64
+	}
+	for _, sc := range options.Scenarios {
+		if pc, ok := sc.(prewarmConfig); ok {
+			n, urls := pc.GetPrewarmConnections()
+			if prewarmed := int64(n * len(urls)); prewarmed > perVU {
+				perVU = prewarmed
+			}
+		}
+	}
+	maxVUs := lib.GetMaxPossibleVUs(options.Scenarios.GetFullExecutionRequirements(et))
+	return maxVUs * uint64(perVU)
+}
+
+// readEphemeralPorts returns how many ports are in the ephemeral port range of
+// Linux, or 0 if it isn't known.
+func readEphemeralPorts(fs afero.Fs) uint64 {
+	data, err := afero.ReadFile(fs, portRangePath)
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) != 2 {
+		return 0
+	}
+	low, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return 0
+	}
+	high, err := strconv.ParseUint(fields[1], 10, 16)
+	if err != nil || high < low {
+		return 0
+	}
+	return high - low + 1
+}
+
+// checkSocketBudget returns the problems the test may run into, as it may
+// need the sockets, with the budget of the system. The ephemeral ports aren't
+// checked with --local-ips, as each of them has its own.
+func checkSocketBudget(sockets uint64, budget socketBudget, options lib.Options) []string {
+	var problems []string
+	if budget.openFiles != 0 && sockets+reservedFiles > budget.openFiles {
+		problems = append(problems, fmt.Sprintf(
+			"the test may need up to %d sockets, but the limit of open files is %d, "+
+				"so opening them may fail with 'too many open files'; raise it, e.g. with 'ulimit -n %d'",
+			sockets, budget.openFiles, sockets+reservedFiles))
+	}
+	if budget.ephemeralPorts != 0 && !options.LocalIPs.Valid && sockets > budget.ephemeralPorts {
+		problems = append(problems, fmt.Sprintf(
+			"the test may need up to %d sockets, but there are only %d ephemeral ports in %s, "+
+				"so connecting may fail with 'cannot assign requested address'; widen the range, "+
+				"or spread the connections over more source IPs with --local-ips",
+			sockets, budget.ephemeralPorts, portRangePath))
+	}
+	if len(problems) > 0 && (options.NoConnectionReuse.Bool || options.NoVUConnectionReuse.Bool) {
+		problems = append(problems, "the connections aren't reused, so the ports of the closed ones are "+
+			"unavailable while they're in TIME_WAIT, which makes running out of them more likely")
+	}
+	return problems
+}
+
+// preflightCheck warns about the problems with the sockets the test may run
+// into, or returns them as an error with --strict-preflight.
+func preflightCheck(
+	fs afero.Fs, logger logrus.FieldLogger, options lib.Options, et *lib.ExecutionTuple, strict bool,
+) error {
+	budget := socketBudget{openFiles: getOpenFilesLimit(), ephemeralPorts: readEphemeralPorts(fs)}
+	problems := checkSocketBudget(estimateSockets(options, et), budget, options)
+	if len(problems) == 0 {
+		return nil
+	}
+	if strict {
+		err := errors.New("the preflight check failed: " + strings.Join(problems, "; "))
+		return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
+	for _, problem := range problems {
+		logger.Warn(problem)
+	}
+	return nil
+}
+
+// checkInterfaces returns an error if the network interface of the test, or
+// the one of a scenario, doesn't exist or has no IP addresses, so the test
+// doesn't start just to have all of its connections fail.
+func checkInterfaces(options lib.Options) error {
+	names := []string{options.Interface.String}
+	for _, sc := range options.Scenarios.GetSortedConfigs() {
+		if ic, ok := sc.(interfaceConfig); ok {
+			names = append(names, ic.GetInterface())
+		}
+	}
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if _, err := netext.NewInterface(name).Addrs(); err != nil {
+			return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+		}
+	}
+	return nil
+}
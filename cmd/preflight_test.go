@@ -0,0 +1,161 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/types"
+)
+
+func newPreflightOptions(vus int64) lib.Options {
+	config := executor.NewConstantVUsConfig("default")
+	config.VUs = null.IntFrom(vus)
+	config.Duration = types.NullDurationFrom(time.Minute)
+	return lib.Options{Scenarios: lib.ScenarioConfigs{config.Name: config}}
+}
+
+func TestEstimateSockets(t *testing.T) {
+	t.Parallel()
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+
+	options := newPreflightOptions(100)
+	assert.Equal(t, uint64(100), estimateSockets(options, et))
+
+	options.Batch = null.IntFrom(20)
+	assert.Equal(t, uint64(2000), estimateSockets(options, et))
+
+	config := executor.NewPerVUIterationsConfig("warm")
+	config.VUs = null.IntFrom(10)
+	config.PrewarmConnections = null.IntFrom(30)
+	config.PrewarmURLs = []string{"https://a.test", "https://b.test"}
+	options.Scenarios[config.Name] = config
+	assert.Equal(t, uint64(110*60), estimateSockets(options, et))
+
+	half, err := lib.NewExecutionSegmentFromString("0:1/2")
+	require.NoError(t, err)
+	et, err = lib.NewExecutionTuple(half, nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(55*60), estimateSockets(options, et))
+}
+
+func TestReadEphemeralPorts(t *testing.T) {
+	t.Parallel()
+
+	fs := afero.NewMemMapFs()
+	assert.Equal(t, uint64(0), readEphemeralPorts(fs))
+
+	require.NoError(t, afero.WriteFile(fs, portRangePath, []byte("32768\t60999\n"), 0o644))
+	assert.Equal(t, uint64(28232), readEphemeralPorts(fs))
+
+	for _, invalid := range []string{"", "32768", "60999\t32768", "a\tb", "1\t70000"} {
+		require.NoError(t, afero.WriteFile(fs, portRangePath, []byte(invalid), 0o644))
+		assert.Equal(t, uint64(0), readEphemeralPorts(fs), invalid)
+	}
+}
+
+func TestCheckSocketBudget(t *testing.T) {
+	t.Parallel()
+
+	options := lib.Options{}
+	assert.Empty(t, checkSocketBudget(1000, socketBudget{}, options))
+	assert.Empty(t, checkSocketBudget(1000, socketBudget{openFiles: 2048, ephemeralPorts: 28232}, options))
+
+	problems := checkSocketBudget(1000, socketBudget{openFiles: 1024, ephemeralPorts: 28232}, options)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "the limit of open files is 1024")
+	assert.Contains(t, problems[0], "ulimit -n 1064")
+
+	problems = checkSocketBudget(30000, socketBudget{openFiles: 65536, ephemeralPorts: 28232}, options)
+	require.Len(t, problems, 1)
+	assert.Contains(t, problems[0], "only 28232 ephemeral ports")
+
+	options.NoConnectionReuse = null.BoolFrom(true)
+	problems = checkSocketBudget(30000, socketBudget{openFiles: 1024, ephemeralPorts: 28232}, options)
+	require.Len(t, problems, 3)
+	assert.Contains(t, problems[2], "TIME_WAIT")
+
+	require.NoError(t, options.LocalIPs.UnmarshalText([]byte("10.0.0.1-10.0.0.4")))
+	assert.Len(t, checkSocketBudget(30000, socketBudget{openFiles: 65536, ephemeralPorts: 28232}, options), 0)
+}
+
+func TestPreflightCheck(t *testing.T) {
+	t.Parallel()
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, portRangePath, []byte("60000\t60009\n"), 0o644))
+	logger, hook := test.NewNullLogger()
+
+	require.NoError(t, preflightCheck(fs, logger, newPreflightOptions(10), et, true))
+	assert.Empty(t, hook.AllEntries())
+
+	require.NoError(t, preflightCheck(fs, logger, newPreflightOptions(20), et, false))
+	entries := hook.AllEntries()
+	require.Len(t, entries, 1)
+	assert.Equal(t, logrus.WarnLevel, entries[0].Level)
+	assert.Contains(t, entries[0].Message, "only 10 ephemeral ports")
+
+	err = preflightCheck(fs, logger, newPreflightOptions(20), et, true)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the preflight check failed")
+	var ecerr errext.HasExitCode
+	require.ErrorAs(t, err, &ecerr)
+	assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+}
+
+func TestCheckInterfaces(t *testing.T) {
+	t.Parallel()
+
+	assert.NoError(t, checkInterfaces(newPreflightOptions(1)))
+
+	options := newPreflightOptions(1)
+	options.Interface = null.StringFrom("k6-missing0")
+	err := checkInterfaces(options)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"k6-missing0"`)
+	var ecerr errext.HasExitCode
+	require.ErrorAs(t, err, &ecerr)
+	assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+
+	config := executor.NewSharedIterationsConfig("other")
+	config.Interface = null.StringFrom("k6-missing1")
+	options = newPreflightOptions(1)
+	options.Scenarios[config.Name] = config
+	err = checkInterfaces(options)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `"k6-missing1"`)
+}
@@ -0,0 +1,148 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// The values of the --progress flag.
+const (
+	progressBars = "bars"
+	progressJSON = "json"
+	progressNone = "none"
+)
+
+func validateProgress(progress string, interval time.Duration) error {
+	switch progress {
+	case progressBars, progressJSON, progressNone:
+	default:
+		return fmt.Errorf("invalid progress mode '%s', it should be one of %s, %s or %s",
+			progress, progressBars, progressJSON, progressNone)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("the progress interval should be more than 0, but it's %s", interval)
+	}
+	return nil
+}
+
+// jsonProgress is a single line of the --progress=json output.
+type jsonProgress struct {
+	Elapsed             float64    `json:"elapsed"`
+	VUs                 int64      `json:"vus"`
+	Iterations          uint64     `json:"iterations"`
+	IterationsPerSecond float64    `json:"iterationsPerSecond"`
+	HTTPReqDurationP95  null.Float `json:"httpReqDurationP95"`
+	HTTPReqFailedRate   null.Float `json:"httpReqFailedRate"`
+	// Thresholds is "passing" or "failing", and empty if there aren't any.
+	Thresholds       string   `json:"thresholds,omitempty"`
+	FailedThresholds []string `json:"failedThresholds,omitempty"`
+	Done             bool     `json:"done,omitempty"`
+}
+
+// setMetrics sets the values calculated from the metrics of the test, which
+// should be locked by the caller.
+func (p *jsonProgress) setMetrics(testMetrics map[string]*stats.Metric) {
+	if m, ok := testMetrics[metrics.HTTPReqDurationName]; ok {
+		if sink, ok := m.Sink.(*stats.TrendSink); ok && sink.Count > 0 {
+			p.HTTPReqDurationP95 = null.FloatFrom(sink.P(0.95))
+		}
+	}
+	if m, ok := testMetrics[metrics.HTTPReqFailedName]; ok {
+		if sink, ok := m.Sink.(*stats.RateSink); ok && sink.Total > 0 {
+			p.HTTPReqFailedRate = null.FloatFrom(float64(sink.Trues) / float64(sink.Total))
+		}
+	}
+
+	p.FailedThresholds = nil
+	p.Thresholds = ""
+	for name, m := range testMetrics {
+		if len(m.Thresholds.Thresholds) == 0 {
+			continue
+		}
+		p.Thresholds = "passing"
+		if m.Tainted.Bool {
+			p.FailedThresholds = append(p.FailedThresholds, name)
+		}
+	}
+	if len(p.FailedThresholds) > 0 {
+		p.Thresholds = "failing"
+		sort.Strings(p.FailedThresholds)
+	}
+}
+
+// showJSONProgress writes a line of JSON with the progress of the test to
+// stdout every interval, and a last one with done set to true when ctx is done.
+func showJSONProgress(
+	ctx context.Context, engine *core.Engine, interval time.Duration, logger logrus.FieldLogger, globalFlags *commandFlags,
+) {
+	state := engine.ExecutionScheduler.GetState()
+	var lastIterations uint64
+	lastElapsed := time.Duration(0)
+
+	printProgress := func(done bool) {
+		elapsed := state.GetCurrentTestRunDuration()
+		progress := jsonProgress{
+			Elapsed:    elapsed.Seconds(),
+			VUs:        state.GetCurrentlyActiveVUsCount(),
+			Iterations: state.GetFullIterationCount(),
+			Done:       done,
+		}
+		if d := elapsed - lastElapsed; d > 0 {
+			progress.IterationsPerSecond = float64(progress.Iterations-lastIterations) / d.Seconds()
+		}
+		lastIterations, lastElapsed = progress.Iterations, elapsed
+
+		engine.MetricsLock.Lock()
+		progress.setMetrics(engine.Metrics)
+		engine.MetricsLock.Unlock()
+
+		data, err := json.Marshal(progress)
+		if err != nil {
+			logger.WithError(err).Error("couldn't marshal the progress")
+			return
+		}
+		fprintf(globalFlags.stdout, "%s\n", data)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			printProgress(true)
+			return
+		case <-ticker.C:
+			printProgress(false)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/stats"
+)
+
+func TestValidateProgress(t *testing.T) {
+	t.Parallel()
+	for _, progress := range []string{progressBars, progressJSON, progressNone} {
+		assert.NoError(t, validateProgress(progress, time.Second), progress)
+	}
+	assert.Error(t, validateProgress("ansi", time.Second))
+	assert.Error(t, validateProgress(progressJSON, 0))
+}
+
+func TestJSONProgressSetMetrics(t *testing.T) {
+	t.Parallel()
+	duration := stats.New(metrics.HTTPReqDurationName, stats.Trend, stats.Time)
+	failed := stats.New(metrics.HTTPReqFailedName, stats.Rate)
+	checks := stats.New(metrics.ChecksName, stats.Rate)
+	testMetrics := map[string]*stats.Metric{
+		duration.Name: duration,
+		failed.Name:   failed,
+		checks.Name:   checks,
+	}
+
+	var p jsonProgress
+	p.setMetrics(testMetrics)
+	assert.Equal(t, jsonProgress{}, p)
+
+	for i := 1; i <= 100; i++ {
+		duration.Sink.Add(stats.Sample{Value: float64(i)})
+		failed.Sink.Add(stats.Sample{Value: float64(i % 4 / 3)})
+	}
+	duration.Thresholds = stats.NewThresholds([]string{"p(95)<200"})
+	duration.Tainted = null.BoolFrom(false)
+	p.setMetrics(testMetrics)
+	assert.Equal(t, null.FloatFrom(95.05), p.HTTPReqDurationP95)
+	assert.Equal(t, null.FloatFrom(0.25), p.HTTPReqFailedRate)
+	assert.Equal(t, "passing", p.Thresholds)
+	assert.Empty(t, p.FailedThresholds)
+
+	checks.Thresholds = stats.NewThresholds([]string{"rate>0.99"})
+	checks.Tainted = null.BoolFrom(true)
+	duration.Tainted = null.BoolFrom(true)
+	p.setMetrics(testMetrics)
+	assert.Equal(t, "failing", p.Thresholds)
+	assert.Equal(t, []string{metrics.ChecksName, metrics.HTTPReqDurationName}, p.FailedThresholds)
+}
+
+func TestRunJSONProgress(t *testing.T) {
+	t.Parallel()
+	scriptPath := filepath.Join(t.TempDir(), "script.js")
+	script := `
+		import { sleep } from "k6";
+		export default function() { sleep(0.1) }
+	`
+	require.NoError(t, ioutil.WriteFile(scriptPath, []byte(script), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	stdout := &bytes.Buffer{}
+	globalFlags := newCommandFlags()
+	globalFlags.stdout = &consoleWriter{Writer: stdout, Mutex: &sync.Mutex{}}
+	cmd := getRunCmd(ctx, testutils.NewLogger(t), globalFlags)
+	cmd.SetArgs([]string{
+		"--no-usage-report", "--no-summary", "--iterations", "5",
+		"--progress", "json", "--progress-interval", "100ms", scriptPath,
+	})
+	require.NoError(t, cmd.Execute())
+
+	var lines []jsonProgress
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		if !strings.HasPrefix(line, "{") {
+			continue
+		}
+		var p jsonProgress
+		require.NoError(t, json.Unmarshal([]byte(line), &p), line)
+		lines = append(lines, p)
+	}
+	require.NotEmpty(t, lines)
+	last := lines[len(lines)-1]
+	assert.True(t, last.Done)
+	assert.Equal(t, uint64(5), last.Iterations)
+	assert.Empty(t, last.Thresholds)
+	assert.NotContains(t, stdout.String(), "default [", "the progress bars shouldn't be shown")
+}
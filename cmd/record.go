@@ -0,0 +1,131 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/converter/har"
+	"go.k6.io/k6/lib"
+)
+
+//nolint:funlen
+func getRecordCmd(
+	ctx context.Context, logger logrus.FieldLogger, defaultFs afero.Fs, defaultWriter io.Writer,
+) *cobra.Command {
+	var (
+		port         int
+		output       string
+		only         []string
+		skip         []string
+		threshold    uint
+		enableChecks bool
+		minSleep     uint
+		maxSleep     uint
+	)
+	recordCmd := &cobra.Command{
+		Use:   "record",
+		Short: "Record a browser session to a k6 script",
+		Long: `Record a browser session to a k6 script.
+
+A local HTTP proxy is started, which records the requests of the browser it's
+configured in until k6 is interrupted. The requests are grouped by page, every
+one starting with the request of an HTML document, and the observed idle times
+are kept as sleeps. HTTPS requests go through the proxy but can't be recorded.`,
+		Example: `
+  # Record a session through the proxy on port 8080.
+  k6 record --port 8080 --output script.js
+
+  # Record only the requests to the given domain.
+  k6 record --only yourdomain.com --output script.js`[1:],
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			listener, err := net.Listen("tcp", net.JoinHostPort("localhost", strconv.Itoa(port)))
+			if err != nil {
+				return err
+			}
+			recorder := har.NewRecorder(http.DefaultTransport)
+			srv := &http.Server{Handler: recorder} //nolint:gosec
+			go func() {
+				if serr := srv.Serve(listener); serr != nil && !errors.Is(serr, http.ErrServerClosed) {
+					logger.WithError(serr).Error("The recording proxy failed")
+				}
+			}()
+			logger.Infof("Recording through the proxy on http://%s, press Ctrl+C to stop", listener.Addr())
+
+			sigC := make(chan os.Signal, 1)
+			signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+			defer signal.Stop(sigC)
+			select {
+			case <-sigC:
+			case <-ctx.Done():
+			}
+			if err = srv.Close(); err != nil {
+				return err
+			}
+
+			h := recorder.HAR()
+			if len(h.Log.Entries) == 0 {
+				return errors.New("no requests were recorded")
+			}
+			logger.Infof("Recorded %d requests on %d pages", len(h.Log.Entries), len(h.Log.Pages))
+
+			// the redirections are recorded as separate requests
+			options := lib.Options{MaxRedirects: null.IntFrom(0)}
+			script, err := har.Convert(h, options, minSleep, maxSleep, enableChecks,
+				false, threshold, false, false, only, skip)
+			if err != nil {
+				return err
+			}
+
+			if output == "" || output == "-" {
+				_, err = io.WriteString(defaultWriter, script)
+				return err
+			}
+			return afero.WriteFile(defaultFs, output, []byte(script), 0o644)
+		},
+	}
+
+	recordCmd.Flags().SortFlags = false
+	recordCmd.Flags().IntVarP(&port, "port", "p", 8080, "the `port` of the recording proxy")
+	recordCmd.Flags().StringVarP(&output, "output", "O", output, "k6 script output filename (stdout by default)")
+	recordCmd.Flags().StringSliceVarP(&only, "only", "", []string{}, "include only requests from the given domains")
+	recordCmd.Flags().StringSliceVarP(&skip, "skip", "", []string{}, "skip requests from the given domains")
+	recordCmd.Flags().UintVarP(&threshold, "batch-threshold", "", 500, "batch the requests with idle times "+
+		"shorter than this many milliseconds, and sleep for the longer ones")
+	recordCmd.Flags().BoolVarP(&enableChecks, "enable-status-code-checks", "", false, "add a status code check for each HTTP response") //nolint:lll
+	recordCmd.Flags().UintVarP(&minSleep, "min-sleep", "", 20, "the minimum amount of seconds to sleep after each iteration")           //nolint:lll
+	recordCmd.Flags().UintVarP(&maxSleep, "max-sleep", "", 40, "the maximum amount of seconds to sleep after each iteration")           //nolint:lll
+	return recordCmd
+}
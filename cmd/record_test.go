@@ -0,0 +1,94 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"testing"
+	"time"
+
+	logtest "github.com/sirupsen/logrus/hooks/test"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordCmd(t *testing.T) {
+	t.Parallel()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "<html></html>")
+	}))
+	defer upstream.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	logger, hook := logtest.NewNullLogger()
+	fs := afero.NewMemMapFs()
+	cmd := getRecordCmd(ctx, logger, fs, &bytes.Buffer{})
+	cmd.SetArgs([]string{"--port", "0", "--output", "/script.js"})
+	errC := make(chan error)
+	go func() { errC <- cmd.Execute() }()
+
+	addrRegexp := regexp.MustCompile(`on (http://\S+),`)
+	var proxyURL *url.URL
+	require.Eventually(t, func() bool {
+		for _, entry := range hook.AllEntries() {
+			if m := addrRegexp.FindStringSubmatch(entry.Message); m != nil {
+				var err error
+				proxyURL, err = url.Parse(m[1])
+				return err == nil
+			}
+		}
+		return false
+	}, 5*time.Second, 10*time.Millisecond)
+
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, upstream.URL+"/index.html", nil)
+	require.NoError(t, err)
+	req.Header.Set("Accept", "text/html")
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	cancel()
+	require.NoError(t, <-errC)
+	script, err := afero.ReadFile(fs, "/script.js")
+	require.NoError(t, err)
+	assert.Contains(t, string(script), `group("page_1 - `+upstream.URL+`/index.html"`)
+	assert.Contains(t, string(script), "maxRedirects: 0,")
+}
+
+func TestRecordCmdNothingRecorded(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	logger, _ := logtest.NewNullLogger()
+	cmd := getRecordCmd(ctx, logger, afero.NewMemMapFs(), &bytes.Buffer{})
+	cmd.SetArgs([]string{"--port", "0"})
+	assert.EqualError(t, cmd.Execute(), "no requests were recorded")
+}
@@ -0,0 +1,499 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/loader"
+	"go.k6.io/k6/pkg/testrun"
+	"go.k6.io/k6/stats"
+)
+
+// replayScript is the script of the runner which generates the summary of the
+// replayed metrics, with the default handleSummary().
+const replayScript = `export default function() {}`
+
+func getReplayMetricsCmd(ctx context.Context, logger *logrus.Logger, globalFlags *commandFlags) *cobra.Command {
+	replayCmd := &cobra.Command{
+		Use:   "replay-metrics [file]",
+		Short: "Evaluate the thresholds and the summary of the results of a past test",
+		Long: `Evaluate the thresholds and the summary of the results of a past test.
+
+The results are the raw metric samples written by the json or the csv output,
+optionally gzipped. The thresholds and the summary options are given with
+--threshold and --options, so the criteria of a test can be tuned without
+running it again. The csv output doesn't keep the types of the metrics, so its
+custom metrics are replayed as trends.`,
+		Example: `
+  # Check the results of a test against stricter thresholds.
+  k6 replay-metrics --threshold 'http_req_duration=p(99)<300' results.json
+
+  # Use the thresholds and the summaryTrendStats of an options file.
+  k6 replay-metrics --options options.json --summary-export summary.json results.csv.gz`[1:],
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), buildEnvMap(os.Environ()))
+			if err != nil {
+				return err
+			}
+			opts, err := getReplayOptions(cmd.Flags())
+			if err != nil {
+				return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+			}
+			format, err := cmd.Flags().GetString("format")
+			if err != nil {
+				return err
+			}
+
+			replay := newMetricsReplay(opts, runtimeOptions.NoThresholds.Bool)
+			if err = readResults(afero.NewOsFs(), args[0], format, replay.add); err != nil {
+				return err
+			}
+			passed := replay.runThresholds(logger)
+
+			if !runtimeOptions.NoSummary.Bool {
+				if err = replay.handleSummary(ctx, logger, globalFlags, runtimeOptions); err != nil {
+					return err
+				}
+			}
+			if !passed {
+				return errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), exitcodes.ThresholdsHaveFailed)
+			}
+			return nil
+		},
+	}
+
+	replayCmd.Flags().SortFlags = false
+	replayCmd.Flags().AddFlagSet(replayFlagSet())
+	replayCmd.Flags().AddFlagSet(runtimeOptionFlagSet(false))
+	return replayCmd
+}
+
+func replayFlagSet() *pflag.FlagSet {
+	flags := pflag.NewFlagSet("", 0)
+	flags.SortFlags = false
+	flags.StringArray("threshold", nil, "add a threshold, as `metric=expression`, e.g. 'http_req_duration=p(95)<500'; "+
+		"can be repeated")
+	flags.String("options", "", "read the thresholds, summaryTrendStats and summaryTimeUnit from this JSON "+
+		"options `file`")
+	flags.String("format", "", "the format of the results, json or csv (default from the file extension)")
+	return flags
+}
+
+// getReplayOptions reads the options of the --options file, and adds the
+// thresholds of the --threshold flags to them.
+func getReplayOptions(flags *pflag.FlagSet) (lib.Options, error) {
+	var opts lib.Options
+	optionsFile, err := flags.GetString("options")
+	if err != nil {
+		return opts, err
+	}
+	if optionsFile != "" {
+		data, err := ioutil.ReadFile(optionsFile) //nolint:gosec
+		if err != nil {
+			return opts, err
+		}
+		if err = json.Unmarshal(data, &opts); err != nil {
+			return opts, fmt.Errorf("invalid options file %s: %w", optionsFile, err)
+		}
+	}
+
+	thresholds, err := flags.GetStringArray("threshold")
+	if err != nil {
+		return opts, err
+	}
+	for _, t := range thresholds {
+		name, source, ok := cutString(t, "=")
+		if !ok || name == "" || source == "" {
+			return opts, fmt.Errorf("invalid threshold %q, use 'metric=expression'", t)
+		}
+		if opts.Thresholds == nil {
+			opts.Thresholds = make(map[string]stats.Thresholds)
+		}
+		ts := opts.Thresholds[name]
+		ts.Thresholds = append(ts.Thresholds, stats.NewThresholds([]string{source}).Thresholds...)
+		opts.Thresholds[name] = ts
+	}
+
+	opts = testrun.ApplyDefaults(opts)
+	for name, ts := range opts.Thresholds {
+		ts := ts
+		if err = ts.Parse(); err != nil {
+			return opts, fmt.Errorf("invalid threshold for %s: %w", name, err)
+		}
+		opts.Thresholds[name] = ts
+	}
+	if _, err = stats.GetResolversForTrendColumns(opts.SummaryTrendStats); err != nil {
+		return opts, err
+	}
+	return opts, nil
+}
+
+// cutString is strings.Cut, which isn't in the Go version of the module yet.
+func cutString(s, sep string) (before, after string, found bool) {
+	if i := strings.Index(s, sep); i >= 0 {
+		return s[:i], s[i+len(sep):], true
+	}
+	return s, "", false
+}
+
+// metricsReplay adds the samples of past results to the metrics like the
+// engine does, with the thresholds of the replay.
+type metricsReplay struct {
+	options      lib.Options
+	noThresholds bool
+	submetrics   map[string][]*stats.Submetric
+
+	Metrics   map[string]*stats.Metric
+	RootGroup *lib.Group
+	// first and last are the times of the first and the last samples.
+	first, last time.Time
+}
+
+func newMetricsReplay(opts lib.Options, noThresholds bool) *metricsReplay {
+	rootGroup, _ := lib.NewGroup("", nil) // the root group can't fail
+	r := &metricsReplay{
+		options:      opts,
+		noThresholds: noThresholds,
+		submetrics:   make(map[string][]*stats.Submetric),
+		Metrics:      make(map[string]*stats.Metric),
+		RootGroup:    rootGroup,
+	}
+	if noThresholds {
+		return r
+	}
+	for name := range opts.Thresholds {
+		if strings.Contains(name, "{") {
+			parent, sm := stats.NewSubmetric(name)
+			r.submetrics[parent] = append(r.submetrics[parent], sm)
+		}
+	}
+	return r
+}
+
+// readResults reads the samples of the results of the json or the csv output
+// in the format, or the one of the extension of the file if it's empty, and
+// calls add with each of them. The gzipped files are decompressed.
+func readResults(fs afero.Fs, filename, format string, add func(stats.Sample)) error {
+	f, err := fs.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = f.Close() }()
+
+	var reader io.Reader = f
+	name := filename
+	if strings.HasSuffix(name, ".gz") {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf("couldn't decompress %s: %w", filename, err)
+		}
+		defer func() { _ = gz.Close() }()
+		reader = gz
+		name = strings.TrimSuffix(name, ".gz")
+	}
+	if format == "" {
+		format = "json"
+		if strings.HasSuffix(name, ".csv") {
+			format = "csv"
+		}
+	}
+
+	switch format {
+	case "json":
+		err = readJSONResults(reader, add)
+	case "csv":
+		err = readCSVResults(reader, add)
+	default:
+		return fmt.Errorf("invalid format %q, use json or csv", format)
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't read the results of %s: %w", filename, err)
+	}
+	return nil
+}
+
+// readJSONResults reads the results of the json output, where the metrics are
+// written before their samples.
+func readJSONResults(reader io.Reader, add func(stats.Sample)) error {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	defined := make(map[string]*stats.Metric)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var envelope struct {
+			Type   string          `json:"type"`
+			Metric string          `json:"metric"`
+			Data   json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(scanner.Bytes(), &envelope); err != nil {
+			return fmt.Errorf("line %d: %w", line, err)
+		}
+		switch envelope.Type {
+		case "Metric":
+			var m stats.Metric
+			if err := json.Unmarshal(envelope.Data, &m); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			defined[m.Name] = stats.New(m.Name, m.Type, m.Contains)
+		case "Point":
+			m, ok := defined[envelope.Metric]
+			if !ok {
+				return fmt.Errorf("line %d: the metric %q of the sample isn't defined before it", line, envelope.Metric)
+			}
+			var point struct {
+				Time  time.Time         `json:"time"`
+				Value float64           `json:"value"`
+				Tags  *stats.SampleTags `json:"tags"`
+			}
+			if err := json.Unmarshal(envelope.Data, &point); err != nil {
+				return fmt.Errorf("line %d: %w", line, err)
+			}
+			if point.Tags == nil {
+				point.Tags = stats.NewSampleTags(nil)
+			}
+			add(stats.Sample{Metric: m, Time: point.Time, Value: point.Value, Tags: point.Tags})
+		}
+	}
+	return scanner.Err()
+}
+
+// readCSVResults reads the results of the csv output, with the unix
+// timestamps and the tags in their own columns or in the extra_tags one. The
+// types of the builtin metrics are known, the other ones are trends.
+func readCSVResults(reader io.Reader, add func(stats.Sample)) error {
+	csvReader := csv.NewReader(reader)
+	header, err := csvReader.Read()
+	if err != nil {
+		return err
+	}
+	if len(header) < 4 || header[0] != "metric_name" || header[len(header)-1] != "extra_tags" {
+		return errors.New("the header of the csv file isn't the one of the csv output")
+	}
+	tagColumns := header[3 : len(header)-1]
+	builtinMetrics := metrics.NewRegistry()
+	metrics.RegisterBuiltinMetrics(builtinMetrics)
+	defined := make(map[string]*stats.Metric)
+	for line := 2; ; line++ {
+		row, err := csvReader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		timestamp, err := strconv.ParseInt(row[1], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid timestamp %q", line, row[1])
+		}
+		value, err := strconv.ParseFloat(row[2], 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid value %q", line, row[2])
+		}
+		tags := make(map[string]string)
+		for i, tag := range tagColumns {
+			if row[i+3] != "" {
+				tags[tag] = row[i+3]
+			}
+		}
+		if extra := row[len(row)-1]; extra != "" {
+			for _, kv := range strings.Split(extra, "&") {
+				k, v, _ := cutString(kv, "=")
+				tags[k] = v
+			}
+		}
+
+		m, ok := defined[row[0]]
+		if !ok {
+			if builtin := builtinMetrics.Get(row[0]); builtin != nil {
+				m = stats.New(builtin.Name, builtin.Type, builtin.Contains)
+			} else {
+				m = stats.New(row[0], stats.Trend)
+			}
+			defined[row[0]] = m
+		}
+		add(stats.Sample{
+			Metric: m, Time: time.Unix(timestamp, 0), Value: value, Tags: stats.IntoSampleTags(&tags),
+		})
+	}
+}
+
+// add adds the sample to its metric and its submetrics, and to the checks of
+// the root group if it's of the checks metric.
+func (r *metricsReplay) add(sample stats.Sample) {
+	m, ok := r.Metrics[sample.Metric.Name]
+	if !ok {
+		m = stats.New(sample.Metric.Name, sample.Metric.Type, sample.Metric.Contains)
+		if !r.noThresholds {
+			m.Thresholds = r.options.Thresholds[m.Name]
+		}
+		m.Submetrics = r.submetrics[m.Name]
+		r.Metrics[m.Name] = m
+	}
+	m.Sink.Add(sample)
+	m.Thresholds.AddSample(sample)
+	m.TrackSampleTime(sample.Time)
+	if r.first.IsZero() || sample.Time.Before(r.first) {
+		r.first = sample.Time
+	}
+	if sample.Time.After(r.last) {
+		r.last = sample.Time
+	}
+
+	for _, sm := range m.Submetrics {
+		if !sm.Matches(sample.Tags) {
+			continue
+		}
+		if sm.Metric == nil {
+			sm.Metric = stats.New(sm.Name, sample.Metric.Type, sample.Metric.Contains)
+			sm.Metric.Sub = *sm
+			sm.Metric.Thresholds = r.options.Thresholds[sm.Name]
+			r.Metrics[sm.Name] = sm.Metric
+		}
+		sm.Metric.Sink.Add(sample)
+		sm.Metric.Thresholds.AddSample(sample)
+		sm.Metric.TrackSampleTime(sample.Time)
+	}
+
+	if m.Name == metrics.ChecksName {
+		r.addCheck(sample)
+	}
+}
+
+// addCheck counts the check of the sample in its group, which are created
+// from the group path tag, like "::outer::inner".
+func (r *metricsReplay) addCheck(sample stats.Sample) {
+	name, ok := sample.Tags.Get("check")
+	if !ok {
+		return
+	}
+	group := r.RootGroup
+	if path, _ := sample.Tags.Get("group"); path != "" {
+		for _, groupName := range strings.Split(strings.TrimPrefix(path, lib.GroupSeparator), lib.GroupSeparator) {
+			g, err := group.Group(groupName)
+			if err != nil {
+				return
+			}
+			group = g
+		}
+	}
+	check, err := group.Check(name)
+	if err != nil {
+		return
+	}
+	if sample.Value != 0 {
+		check.Passes++
+	} else {
+		check.Fails++
+	}
+}
+
+// duration is the time between the first and the last samples.
+func (r *metricsReplay) duration() time.Duration {
+	return r.last.Sub(r.first)
+}
+
+// runThresholds runs the thresholds of the metrics and returns whether they
+// all passed. The thresholds of metrics without samples pass.
+func (r *metricsReplay) runThresholds(logger logrus.FieldLogger) bool {
+	passed := true
+	for name, m := range r.Metrics {
+		if len(m.Thresholds.Thresholds) == 0 {
+			continue
+		}
+		succ, err := m.Thresholds.RunWithMetrics(m.Sink, r.duration(), r.Metrics)
+		if err != nil {
+			logger.WithField("m", name).WithError(err).Error("Threshold error")
+			continue
+		}
+		m.Tainted = null.BoolFrom(!succ)
+		if !succ {
+			passed = false
+		}
+	}
+	for name := range r.options.Thresholds {
+		if _, ok := r.Metrics[name]; !ok && !r.noThresholds {
+			logger.Warnf("The results don't have any samples of %s, its thresholds weren't evaluated", name)
+		}
+	}
+	return passed
+}
+
+// handleSummary shows and exports the summary of the replayed metrics, with
+// the default handleSummary() of a runner with the options of the replay.
+func (r *metricsReplay) handleSummary(
+	ctx context.Context, logger *logrus.Logger, globalFlags *commandFlags, rtOpts lib.RuntimeOptions,
+) error {
+	src := &loader.SourceData{
+		URL:  &url.URL{Scheme: "file", Path: "/k6-replay.js"},
+		Data: []byte(replayScript),
+	}
+	registry := metrics.NewRegistry()
+	runner, err := testrun.NewRunner(
+		logger, src, typeJS, loader.CreateFilesystems(), rtOpts, metrics.RegisterBuiltinMetrics(registry), registry)
+	if err != nil {
+		return err
+	}
+	if err = runner.SetOptions(r.options); err != nil {
+		return err
+	}
+
+	result, err := runner.HandleSummary(ctx, &lib.Summary{
+		Metrics:         r.Metrics,
+		RootGroup:       r.RootGroup,
+		TestRunDuration: r.duration(),
+		NoColor:         globalFlags.noColor,
+		UIState: lib.UIState{
+			IsStdOutTTY: globalFlags.stdoutTTY,
+			IsStdErrTTY: globalFlags.stderrTTY,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return handleSummaryResult(afero.NewOsFs(), globalFlags.stdout, globalFlags.stderr, result)
+}
@@ -0,0 +1,149 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/stats"
+)
+
+const replayJSONResults = `{"type":"Metadata","data":{"os":"linux"}}
+{"type":"Metric","data":{"name":"http_req_duration","type":"trend","contains":"time","tainted":null,"thresholds":[],"submetrics":null,"sub":{"name":"","parent":"","suffix":"","tags":null}},"metric":"http_req_duration"}
+{"type":"Point","data":{"time":"2021-06-01T12:00:00Z","value":100,"tags":{"status":"200"}},"metric":"http_req_duration"}
+{"type":"Point","data":{"time":"2021-06-01T12:00:05Z","value":300,"tags":{"status":"500"}},"metric":"http_req_duration"}
+{"type":"Metric","data":{"name":"checks","type":"rate","contains":"default","tainted":null,"thresholds":[],"submetrics":null,"sub":{"name":"","parent":"","suffix":"","tags":null}},"metric":"checks"}
+{"type":"Point","data":{"time":"2021-06-01T12:00:06Z","value":1,"tags":{"check":"ok","group":"::login"}},"metric":"checks"}
+{"type":"Point","data":{"time":"2021-06-01T12:00:10Z","value":0,"tags":{"check":"ok","group":"::login"}},"metric":"checks"}
+`
+
+const replayCSVResults = `metric_name,timestamp,metric_value,check,group,status,extra_tags
+http_req_duration,1622548800,100.000000,,,200,
+http_req_duration,1622548805,300.000000,,,500,
+checks,1622548806,1.000000,ok,::login,,
+my_metric,1622548810,7.000000,,,,kind=custom&run=2
+`
+
+func TestMetricsReplayRead(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/results.json", []byte(replayJSONResults), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/results.csv", []byte(replayCSVResults), 0o644))
+	gzipped := &bytes.Buffer{}
+	gz := gzip.NewWriter(gzipped)
+	_, err := gz.Write([]byte(replayJSONResults))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	require.NoError(t, afero.WriteFile(fs, "/results.json.gz", gzipped.Bytes(), 0o644))
+
+	opts := lib.Options{Thresholds: map[string]stats.Thresholds{
+		"http_req_duration":             stats.NewThresholds([]string{"p(95)<500"}),
+		"http_req_duration{status:500}": stats.NewThresholds([]string{"max<200"}),
+	}}
+	for _, ts := range opts.Thresholds {
+		require.NoError(t, ts.Parse())
+	}
+
+	for _, filename := range []string{"/results.json", "/results.json.gz", "/results.csv"} {
+		replay := newMetricsReplay(opts, false)
+		require.NoError(t, readResults(fs, filename, "", replay.add), filename)
+
+		duration := replay.Metrics["http_req_duration"]
+		require.NotNil(t, duration, filename)
+		assert.Equal(t, stats.Trend, duration.Type)
+		assert.Equal(t, stats.Time, duration.Contains)
+		assert.Equal(t, uint64(2), duration.Sink.(*stats.TrendSink).Count)
+		require.Contains(t, replay.Metrics, "http_req_duration{status:500}", filename)
+		assert.Equal(t, 300.0, replay.Metrics["http_req_duration{status:500}"].Sink.(*stats.TrendSink).Max)
+
+		require.Contains(t, replay.RootGroup.Groups, "login", filename)
+		require.Contains(t, replay.RootGroup.Groups["login"].Checks, "ok", filename)
+		assert.Equal(t, int64(1), replay.RootGroup.Groups["login"].Checks["ok"].Passes)
+
+		assert.False(t, replay.runThresholds(testutils.NewLogger(t)), filename)
+		assert.False(t, duration.Tainted.Bool)
+		assert.True(t, replay.Metrics["http_req_duration{status:500}"].Tainted.Bool)
+	}
+
+	replay := newMetricsReplay(lib.Options{}, false)
+	require.NoError(t, readResults(fs, "/results.csv", "csv", replay.add))
+	require.Contains(t, replay.Metrics, "my_metric")
+	assert.Equal(t, stats.Trend, replay.Metrics["my_metric"].Type)
+	assert.Equal(t, stats.Rate, replay.Metrics["checks"].Type)
+	assert.Equal(t, "10s", replay.duration().String())
+
+	ignore := func(stats.Sample) {}
+	assert.Error(t, readResults(fs, "/results.csv", "json", ignore))
+	assert.Error(t, readResults(fs, "/results.json", "xml", ignore))
+	assert.Error(t, readResults(fs, "/missing.json", "", ignore))
+}
+
+func TestReplayMetricsCmd(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	results := filepath.Join(dir, "results.json")
+	require.NoError(t, ioutil.WriteFile(results, []byte(replayJSONResults), 0o600))
+	options := filepath.Join(dir, "options.json")
+	require.NoError(t, ioutil.WriteFile(options, []byte(`{"summaryTrendStats": ["max"]}`), 0o600))
+
+	run := func(args ...string) (string, error) {
+		stdout := &bytes.Buffer{}
+		globalFlags := newCommandFlags()
+		globalFlags.stdout = &consoleWriter{Writer: stdout, Mutex: &sync.Mutex{}}
+		cmd := getReplayMetricsCmd(context.Background(), testutils.NewLogger(t), globalFlags)
+		cmd.SetArgs(args)
+		err := cmd.Execute()
+		return stdout.String(), err
+	}
+
+	out, err := run("--options", options, "--threshold", "http_req_duration=max<500", results)
+	require.NoError(t, err)
+	assert.Contains(t, out, "✓ http_req_duration")
+	assert.Contains(t, out, "max=300ms")
+	assert.Contains(t, out, "█ login")
+
+	_, err = run("--threshold", "http_req_duration=max<200", "--no-summary", results)
+	var ecerr errext.HasExitCode
+	require.True(t, errors.As(err, &ecerr))
+	assert.Equal(t, exitcodes.ThresholdsHaveFailed, ecerr.ExitCode())
+
+	_, err = run("--threshold", "http_req_duration=max<200", "--no-thresholds", "--no-summary", results)
+	assert.NoError(t, err)
+
+	_, err = run("--threshold", "http_req_duration", results)
+	require.True(t, errors.As(err, &ecerr))
+	assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+}
@@ -0,0 +1,96 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+)
+
+// retryInterval is how long k6 waits before running the test again.
+const retryInterval = 5 * time.Second
+
+// infrastructureError is an error caused by the environment k6 runs in, and
+// not by the script or the tested system, like a failed DNS lookup or an
+// output which refused the connection. Running the test again may succeed.
+type infrastructureError struct {
+	error
+}
+
+func (e infrastructureError) Unwrap() error {
+	return e.error
+}
+
+func (e infrastructureError) ExitCode() errext.ExitCode {
+	return exitcodes.InfrastructureError
+}
+
+var _ errext.HasExitCode = infrastructureError{}
+
+// asInfrastructureError marks err as an infrastructure error if it was caused
+// by a failed DNS lookup or a refused connection, and returns it unchanged
+// otherwise. It should only be used for the errors from before the test
+// started, since the tested system can refuse connections too.
+func asInfrastructureError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED) {
+		return infrastructureError{err}
+	}
+	return err
+}
+
+func isInfrastructureError(err error) bool {
+	var infraErr infrastructureError
+	return errors.As(err, &infraErr)
+}
+
+// runWithRetries calls run up to retries more times while it fails with an
+// infrastructure error. Any other error, like failed thresholds, is returned
+// right away.
+func runWithRetries(
+	ctx context.Context, logger logrus.FieldLogger, retries int, interval time.Duration, run func() error,
+) error {
+	for attempt := 1; ; attempt++ {
+		err := run()
+		if err == nil || !isInfrastructureError(err) || attempt > retries {
+			return err
+		}
+		logger.WithError(err).Warnf(
+			"The test couldn't start because of an infrastructure error, retrying in %s (%d/%d)...",
+			interval, attempt, retries)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(interval):
+		}
+	}
+}
@@ -0,0 +1,103 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib/testutils"
+)
+
+func TestAsInfrastructureError(t *testing.T) {
+	t.Parallel()
+	dnsErr := fmt.Errorf("could not load the script: %w", &net.DNSError{Err: "no such host", Name: "k6.invalid"})
+	refusedErr := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	for _, err := range []error{dnsErr, refusedErr} {
+		err := asInfrastructureError(err)
+		assert.True(t, isInfrastructureError(err), err)
+		var ecerr errext.HasExitCode
+		assert.True(t, errors.As(err, &ecerr))
+		assert.Equal(t, exitcodes.InfrastructureError, ecerr.ExitCode())
+	}
+
+	assert.NoError(t, asInfrastructureError(nil))
+	otherErr := errors.New("invalid output type")
+	assert.Equal(t, otherErr, asInfrastructureError(otherErr))
+	assert.False(t, isInfrastructureError(otherErr))
+}
+
+func TestRunWithRetries(t *testing.T) {
+	t.Parallel()
+	infraErr := infrastructureError{errors.New("connection refused")}
+	thresholdsErr := errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), exitcodes.ThresholdsHaveFailed)
+
+	testCases := []struct {
+		name     string
+		retries  int
+		errs     []error
+		expRuns  int
+		expError error
+	}{
+		{name: "success", retries: 2, errs: []error{nil}, expRuns: 1},
+		{name: "retried success", retries: 2, errs: []error{infraErr, infraErr, nil}, expRuns: 3},
+		{name: "no retries", retries: 0, errs: []error{infraErr}, expRuns: 1, expError: infraErr},
+		{name: "retries exhausted", retries: 2, errs: []error{infraErr, infraErr, infraErr}, expRuns: 3, expError: infraErr},
+		{name: "thresholds", retries: 2, errs: []error{thresholdsErr}, expRuns: 1, expError: thresholdsErr},
+		{name: "thresholds after retry", retries: 2, errs: []error{infraErr, thresholdsErr}, expRuns: 2, expError: thresholdsErr},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			runs := 0
+			err := runWithRetries(context.Background(), testutils.NewLogger(t), tc.retries, 0, func() error {
+				err := tc.errs[runs]
+				runs++
+				return err
+			})
+			assert.Equal(t, tc.expError, err)
+			assert.Equal(t, tc.expRuns, runs)
+		})
+	}
+
+	t.Run("cancelled", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		runs := 0
+		err := runWithRetries(ctx, testutils.NewLogger(t), 5, retryInterval, func() error {
+			runs++
+			return infraErr
+		})
+		assert.Equal(t, infraErr, err)
+		assert.Equal(t, 1, runs)
+	})
+}
@@ -57,6 +57,9 @@ type commandFlags struct {
 	exitOnRunning         bool
 	showCloudLogs         bool
 	runType               string
+	retries               int
+	progress              string
+	progressInterval      time.Duration
 	archiveOut            string
 	quiet                 bool
 	noColor               bool
@@ -90,6 +93,8 @@ func newCommandFlags() *commandFlags {
 		showCloudLogs:         true,
 		runType:               os.Getenv("K6_TYPE"),
 		archiveOut:            "archive.tar",
+		progress:              progressBars,
+		progressInterval:      10 * time.Second,
 		outMutex:              outMutex,
 		stdoutTTY:             stdoutTTY,
 		stderrTTY:             stderrTTY,
@@ -185,9 +190,13 @@ func Execute() {
 		getArchiveCmd(logger, c.commandFlags),
 		getCloudCmd(ctx, logger, c.commandFlags),
 		getConvertCmd(afero.NewOsFs(), c.commandFlags.stdout),
+		getConvertOutputCmd(ctx, logger, c.commandFlags),
 		getInspectCmd(logger, c.commandFlags),
+		getLintCmd(logger, c.commandFlags.stdout),
 		loginCmd,
 		getPauseCmd(ctx, c.commandFlags),
+		getRecordCmd(ctx, logger, afero.NewOsFs(), c.commandFlags.stdout),
+		getReplayMetricsCmd(ctx, logger, c.commandFlags),
 		getResumeCmd(ctx, c.commandFlags),
 		getScaleCmd(ctx, c.commandFlags),
 		getRunCmd(ctx, logger, c.commandFlags),
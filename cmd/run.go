@@ -31,7 +31,10 @@ import (
 	"os"
 	"os/signal"
 	"runtime"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -45,18 +48,24 @@ import (
 	"go.k6.io/k6/core/local"
 	"go.k6.io/k6/errext"
 	"go.k6.io/k6/errext/exitcodes"
-	"go.k6.io/k6/js"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/clocksync"
 	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/envinfo"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/loader"
+	"go.k6.io/k6/pkg/testrun"
+	"go.k6.io/k6/stats"
 	"go.k6.io/k6/ui/pb"
 )
 
 const (
-	typeJS      = "js"
-	typeArchive = "archive"
+	typeJS      = testrun.TypeJS
+	typeArchive = testrun.TypeArchive
+
+	ntpTimeout = 5 * time.Second
 )
 
 //nolint:funlen,gocognit,gocyclo,cyclop
@@ -86,129 +95,31 @@ a commandline interface for interacting with it.`,
   k6 run -u 0 -s 10s:100 -s 60s -s 10s:0
 
   # Send metrics to an influxdb server
-  k6 run -o influxdb=http://1.2.3.4:8086/k6`[1:],
-		Args: exactArgsWithMsg(1, "arg should either be \"-\", if reading script from stdin, or a path to a script file"),
+  k6 run -o influxdb=http://1.2.3.4:8086/k6
+
+  # Run two scripts together, with their scenarios, and shared metrics and outputs.
+  k6 run browse.js checkout.js`[1:],
+		Args: minArgsWithMsg(1, "args should either be \"-\", if reading script from stdin, or paths to script files"),
 		RunE: func(cmd *cobra.Command, args []string) error {
 			// TODO: disable in quiet mode?
 			_, _ = fmt.Fprintf(globalFlags.stdout, "\n%s\n\n", getBanner(globalFlags.noColor || !globalFlags.stdoutTTY))
 
-			logger.Debug("Initializing the runner...")
-
-			// Create the Runner.
-			src, filesystems, err := readSource(args[0], logger)
-			if err != nil {
-				return err
-			}
-
-			osEnvironment := buildEnvMap(os.Environ())
-			runtimeOptions, err := getRuntimeOptions(cmd.Flags(), osEnvironment)
-			if err != nil {
-				return err
-			}
-
-			registry := metrics.NewRegistry()
-			builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
-			initRunner, err := newRunner(logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
-			if err != nil {
-				return common.UnwrapGojaInterruptedError(err)
-			}
-
-			logger.Debug("Getting the script options...")
-
-			cliConf, err := getConfig(cmd.Flags())
-			if err != nil {
-				return err
-			}
-			conf, err := getConsolidatedConfig(
-				afero.NewOsFs(), cliConf, initRunner.GetOptions(), buildEnvMap(os.Environ()), globalFlags)
-			if err != nil {
-				return err
-			}
-
-			// Parse the thresholds, only if the --no-threshold flag is not set.
-			// If parsing the threshold expressions failed, consider it as an
-			// invalid configuration error.
-			if !runtimeOptions.NoThresholds.Bool {
-				for _, thresholds := range conf.Options.Thresholds {
-					err = thresholds.Parse()
-					if err != nil {
-						return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
-					}
-				}
-			}
-
-			conf, err = deriveAndValidateConfig(conf, initRunner.IsExecutable, logger)
+			exitCodes, err := getExitCodeMapping(afero.NewOsFs(), buildEnvMap(os.Environ()), globalFlags)
 			if err != nil {
-				return err
+				return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
 			}
-
-			// Write options back to the runner too.
-			if err = initRunner.SetOptions(conf.Options); err != nil {
-				return err
-			}
-
-			// We prepare a bunch of contexts:
-			//  - The runCtx is cancelled as soon as the Engine's run() lambda finishes,
-			//    and can trigger things like the usage report and end of test summary.
-			//    Crucially, metrics processing by the Engine will still work after this
-			//    context is cancelled!
-			//  - The lingerCtx is cancelled by Ctrl+C, and is used to wait for that
-			//    event when k6 was ran with the --linger option.
-			//  - The globalCtx is cancelled only after we're completely done with the
-			//    test execution and any --linger has been cleared, so that the Engine
-			//    can start winding down its metrics processing.
-			globalCtx, globalCancel := context.WithCancel(ctx)
-			defer globalCancel()
-			lingerCtx, lingerCancel := context.WithCancel(globalCtx)
-			defer lingerCancel()
-			runCtx, runCancel := context.WithCancel(lingerCtx)
-			defer runCancel()
-
-			// Create a local execution scheduler wrapping the runner.
-			logger.Debug("Initializing the execution scheduler...")
-			execScheduler, err := local.NewExecutionScheduler(initRunner, logger)
-			if err != nil {
-				return err
-			}
-
-			// This is manually triggered after the Engine's Run() has completed,
-			// and things like a single Ctrl+C don't affect it. We use it to make
-			// sure that the progressbars finish updating with the latest execution
-			// state one last time, after the test run has finished.
-			progressCtx, progressCancel := context.WithCancel(globalCtx)
-			defer progressCancel()
-			initBar := execScheduler.GetInitProgressBar()
-			progressBarWG := &sync.WaitGroup{}
-			progressBarWG.Add(1)
-			go func() {
-				pbs := []*pb.ProgressBar{execScheduler.GetInitProgressBar()}
-				for _, s := range execScheduler.GetExecutors() {
-					pbs = append(pbs, s.GetProgress())
-				}
-				showProgress(progressCtx, pbs, logger, globalFlags)
-				progressBarWG.Done()
-			}()
-
-			// Create all outputs.
-			executionPlan := execScheduler.GetExecutionPlan()
-			outputs, err := createOutputs(conf.Out, src, conf, runtimeOptions, executionPlan, osEnvironment, logger, globalFlags)
-			if err != nil {
-				return err
-			}
-
-			// Create the engine.
-			initBar.Modify(pb.WithConstProgress(0, "Init engine"))
-			engine, err := core.NewEngine(execScheduler, conf.Options, runtimeOptions, outputs, logger, builtinMetrics)
-			if err != nil {
-				return err
-			}
-
-			// Spin up the REST API server, if not disabled.
+			// The REST API server is started only once, since its address
+			// can't be bound again while it runs, and serves the engine of
+			// the current attempt.
+			var currentEngine atomic.Value
 			if globalFlags.address != "" {
-				initBar.Modify(pb.WithConstProgress(0, "Init API server"))
 				go func() {
 					logger.Debugf("Starting the REST API server on %s", globalFlags.address)
-					if aerr := api.ListenAndServe(globalFlags.address, engine, logger); aerr != nil {
+					getEngine := func() *core.Engine {
+						engine, _ := currentEngine.Load().(*core.Engine)
+						return engine
+					}
+					if aerr := api.ListenAndServeEngine(globalFlags.address, getEngine, logger); aerr != nil {
 						// Only exit k6 if the user has explicitly set the REST API address
 						if cmd.Flags().Lookup("address").Changed {
 							logger.WithError(aerr).Error("Error from API server")
@@ -219,138 +130,352 @@ a commandline interface for interacting with it.`,
 					}
 				}()
 			}
+			err = runWithRetries(ctx, logger, globalFlags.retries, retryInterval, func() error {
+				return runTest(ctx, logger, globalFlags, cmd, args, &currentEngine)
+			})
+			return exitCodes.apply(err)
+		},
+	}
 
-			// We do this here so we can get any output URLs below.
-			initBar.Modify(pb.WithConstProgress(0, "Starting outputs"))
-			err = engine.StartOutputs()
-			if err != nil {
-				return err
-			}
-			defer engine.StopOutputs()
-
-			printExecutionDescription(
-				"local", args[0], "", conf, execScheduler.GetState().ExecutionTuple,
-				executionPlan, outputs, globalFlags.noColor || !globalFlags.stdoutTTY, globalFlags)
-
-			// Trap Interrupts, SIGINTs and SIGTERMs.
-			sigC := make(chan os.Signal, 1)
-			signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-			defer signal.Stop(sigC)
-			go func() {
-				sig := <-sigC
-				logger.WithField("sig", sig).Debug("Stopping k6 in response to signal...")
-				lingerCancel() // stop the test run, metric processing is cancelled below
-
-				// If we get a second signal, we immediately exit, so something like
-				// https://github.com/k6io/k6/issues/971 never happens again
-				sig = <-sigC
-				logger.WithField("sig", sig).Error("Aborting k6 in response to signal")
-				globalCancel() // not that it matters, given the following command...
-				os.Exit(int(exitcodes.ExternalAbort))
-			}()
-
-			// Initialize the engine
-			initBar.Modify(pb.WithConstProgress(0, "Init VUs..."))
-			engineRun, engineWait, err := engine.Init(globalCtx, runCtx)
-			if err != nil {
-				err = common.UnwrapGojaInterruptedError(err)
-				// Add a generic engine exit code if we don't have a more specific one
-				return errext.WithExitCodeIfNone(err, exitcodes.GenericEngine)
-			}
+	runCmd.Flags().SortFlags = false
+	runCmd.Flags().AddFlagSet(runCmdFlagSet(globalFlags))
 
-			// Init has passed successfully, so unless disabled, make sure we send a
-			// usage report after the context is done.
-			if !conf.NoUsageReport.Bool {
-				reportDone := make(chan struct{})
-				go func() {
-					<-runCtx.Done()
-					_ = reportUsage(execScheduler)
-					close(reportDone)
-				}()
-				defer func() {
-					select {
-					case <-reportDone:
-					case <-time.After(3 * time.Second):
-					}
-				}()
-			}
+	return runCmd
+}
 
-			// Start the test run
-			initBar.Modify(pb.WithConstProgress(0, "Starting test..."))
-			var interrupt error
-			err = engineRun()
-			if err != nil {
-				err = common.UnwrapGojaInterruptedError(err)
-				if common.IsInterruptError(err) {
-					// Don't return here since we need to work with --linger,
-					// show the end-of-test summary and exit cleanly.
-					interrupt = err
-				}
-				if !conf.Linger.Bool && interrupt == nil {
-					return errext.WithExitCodeIfNone(err, exitcodes.GenericEngine)
-				}
-			}
-			runCancel()
-			logger.Debug("Engine run terminated cleanly")
+// runTest runs a single local test, from loading the script to returning the
+// error the k6 process should exit with. The engine is stored in
+// currentEngine, for the REST API server.
+//nolint:funlen,gocognit,gocyclo,cyclop
+func runTest(
+	ctx context.Context, logger *logrus.Logger, globalFlags *commandFlags, cmd *cobra.Command, args []string,
+	currentEngine *atomic.Value,
+) error {
+	if err := validateProgress(globalFlags.progress, globalFlags.progressInterval); err != nil {
+		return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
 
-			progressCancel()
-			progressBarWG.Wait()
+	logger.Debug("Initializing the runner...")
 
-			executionState := execScheduler.GetState()
-			// Warn if no iterations could be completed.
-			if executionState.GetFullIterationCount() == 0 {
-				logger.Warn("No script iterations finished, consider making the test duration longer")
-			}
+	osEnvironment := buildEnvMap(os.Environ())
+	runtimeOptions, err := getRuntimeOptions(cmd.Flags(), osEnvironment)
+	if err != nil {
+		return err
+	}
 
-			// Handle the end-of-test summary.
-			if !runtimeOptions.NoSummary.Bool {
-				summaryResult, err := initRunner.HandleSummary(globalCtx, &lib.Summary{
-					Metrics:         engine.Metrics,
-					RootGroup:       engine.ExecutionScheduler.GetRunner().GetDefaultGroup(),
-					TestRunDuration: executionState.GetCurrentTestRunDuration(),
-					NoColor:         globalFlags.noColor,
-					UIState: lib.UIState{
-						IsStdOutTTY: globalFlags.stdoutTTY,
-						IsStdErrTTY: globalFlags.stderrTTY,
-					},
-				})
-				if err == nil {
-					err = handleSummaryResult(afero.NewOsFs(), globalFlags.stdout, globalFlags.stderr, summaryResult)
-				}
-				if err != nil {
-					logger.WithError(err).Error("failed to handle the end-of-test summary")
-				}
-			}
+	// Create the Runner.
+	var (
+		src         *loader.SourceData
+		filesystems map[string]afero.Fs
+	)
+	if len(args) > 1 {
+		if globalFlags.runType == typeArchive {
+			return errors.New("only scripts can be run together, not archives")
+		}
+		src, filesystems, err = readSuite(args, logger, runtimeOptions)
+	} else {
+		src, filesystems, err = readSource(args[0], logger)
+	}
+	if err != nil {
+		return asInfrastructureError(err)
+	}
 
-			if conf.Linger.Bool {
-				select {
-				case <-lingerCtx.Done():
-					// do nothing, we were interrupted by Ctrl+C already
-				default:
-					logger.Debug("Linger set; waiting for Ctrl+C...")
-					fprintf(globalFlags.stdout, "Linger set; waiting for Ctrl+C...")
-					<-lingerCtx.Done()
-					logger.Debug("Ctrl+C received, exiting...")
-				}
-			}
-			globalCancel() // signal the Engine that it should wind down
-			logger.Debug("Waiting for engine processes to finish...")
-			engineWait()
-			logger.Debug("Everything has finished, exiting k6!")
-			if interrupt != nil {
-				return interrupt
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	initRunner, err := testrun.NewRunner(logger, src, globalFlags.runType, filesystems, runtimeOptions, builtinMetrics, registry)
+	if err != nil {
+		return asInfrastructureError(common.UnwrapGojaInterruptedError(err))
+	}
+
+	logger.Debug("Getting the script options...")
+
+	cliConf, err := getConfig(cmd.Flags())
+	if err != nil {
+		return err
+	}
+	conf, err := getConsolidatedConfig(
+		afero.NewOsFs(), cliConf, initRunner.GetOptions(), buildEnvMap(os.Environ()), globalFlags,
+		runtimeOptions, logger)
+	if err != nil {
+		return err
+	}
+	conf = addMetricThresholds(conf, registry)
+
+	// Parse the thresholds, only if the --no-threshold flag is not set.
+	// If parsing the threshold expressions failed, consider it as an
+	// invalid configuration error.
+	if !runtimeOptions.NoThresholds.Bool {
+		if err = testrun.ParseThresholds(conf.Options.Thresholds); err != nil {
+			return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+		}
+		baseline, err := cmd.Flags().GetString("baseline")
+		if err != nil {
+			return err
+		}
+		if err = applyBaseline(afero.NewOsFs(), baseline, conf.Options.Thresholds); err != nil {
+			return err
+		}
+	}
+
+	conf, err = deriveAndValidateConfig(conf, initRunner.IsExecutable, logger)
+	if err != nil {
+		return err
+	}
+
+	// Write options back to the runner too.
+	if err = initRunner.SetOptions(conf.Options); err != nil {
+		return err
+	}
+
+	// The hooks run commands, like the k6/experimental/exec module, so
+	// they need to be allowed as well.
+	if !conf.Options.Hooks.IsEmpty() && !runtimeOptions.AllowExec.Bool {
+		err = errors.New("the hooks option can only be used with the --allow-exec flag")
+		return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
+	hooks := newHookRunner(conf.Options.Hooks, logger, globalFlags)
+
+	// The metadata of the environment is attached to the outputs and the
+	// summary if it's asked for, so the results of every instance of k6 are
+	// known.
+	var metadata map[string]string
+	if runtimeOptions.EnvMetadata.Bool {
+		metadata = envinfo.Detect(ctx, logger, osEnvironment)
+		logger.WithField("metadata", metadata).Debug("Detected the environment metadata")
+	}
+	if err = measureClockOffset(ctx, &runtimeOptions, logger); err != nil {
+		return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
+	if runtimeOptions.ClockOffset.Valid {
+		if metadata == nil {
+			metadata = make(map[string]string)
+		}
+		metadata[clocksync.MetadataKey] = runtimeOptions.ClockOffset.Duration.String()
+	}
+
+	// We prepare a bunch of contexts:
+	//  - The runCtx is cancelled as soon as the Engine's run() lambda finishes,
+	//    and can trigger things like the usage report and end of test summary.
+	//    Crucially, metrics processing by the Engine will still work after this
+	//    context is cancelled!
+	//  - The lingerCtx is cancelled by Ctrl+C, and is used to wait for that
+	//    event when k6 was ran with the --linger option.
+	//  - The globalCtx is cancelled only after we're completely done with the
+	//    test execution and any --linger has been cleared, so that the Engine
+	//    can start winding down its metrics processing.
+	globalCtx, globalCancel := context.WithCancel(ctx)
+	defer globalCancel()
+	lingerCtx, lingerCancel := context.WithCancel(globalCtx)
+	defer lingerCancel()
+	runCtx, runCancel := context.WithCancel(lingerCtx)
+	defer runCancel()
+
+	// Create a local execution scheduler wrapping the runner.
+	logger.Debug("Initializing the execution scheduler...")
+	execScheduler, err := local.NewExecutionScheduler(initRunner, logger)
+	if err != nil {
+		return err
+	}
+	et := execScheduler.GetState().ExecutionTuple
+	if err = preflightCheck(afero.NewOsFs(), logger, conf.Options, et, runtimeOptions.StrictPreflight.Bool); err != nil {
+		return err
+	}
+	if err = checkInterfaces(conf.Options); err != nil {
+		return err
+	}
+
+	// This is manually triggered after the Engine's Run() has completed,
+	// and things like a single Ctrl+C don't affect it. We use it to make
+	// sure that the progressbars finish updating with the latest execution
+	// state one last time, after the test run has finished.
+	progressCtx, progressCancel := context.WithCancel(globalCtx)
+	defer progressCancel()
+	initBar := execScheduler.GetInitProgressBar()
+	progressBarWG := &sync.WaitGroup{}
+	if globalFlags.progress == progressBars {
+		progressBarWG.Add(1)
+		go func() {
+			pbs := []*pb.ProgressBar{execScheduler.GetInitProgressBar()}
+			for _, s := range execScheduler.GetExecutors() {
+				pbs = append(pbs, s.GetProgress())
 			}
-			if engine.IsTainted() {
-				return errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), exitcodes.ThresholdsHaveFailed)
+			showProgress(progressCtx, pbs, logger, globalFlags)
+			progressBarWG.Done()
+		}()
+	}
+
+	// Create all outputs.
+	executionPlan := execScheduler.GetExecutionPlan()
+	outputs, err := createOutputs(
+		conf.Out, src, conf, runtimeOptions, executionPlan, osEnvironment, metadata, logger, globalFlags)
+	if err != nil {
+		return errext.WithExitCodeIfNone(asInfrastructureError(err), exitcodes.OutputFailed)
+	}
+
+	// Create the engine.
+	initBar.Modify(pb.WithConstProgress(0, "Init engine"))
+	engine, err := testrun.NewEngine(
+		execScheduler, conf.Options, runtimeOptions, outputs, logger, registry, builtinMetrics)
+	if err != nil {
+		return err
+	}
+	// The JSON progress needs the metrics of the engine, unlike the progress bars.
+	if globalFlags.progress == progressJSON && !globalFlags.quiet {
+		progressBarWG.Add(1)
+		go func() {
+			showJSONProgress(progressCtx, engine.Engine, globalFlags.progressInterval, logger, globalFlags)
+			progressBarWG.Done()
+		}()
+	}
+
+	currentEngine.Store(engine.Engine)
+
+	// We do this here so we can get any output URLs below.
+	initBar.Modify(pb.WithConstProgress(0, "Starting outputs"))
+	err = engine.StartOutputs()
+	if err != nil {
+		return errext.WithExitCodeIfNone(asInfrastructureError(err), exitcodes.OutputFailed)
+	}
+	defer engine.StopOutputs()
+
+	printExecutionDescription(
+		"local", strings.Join(args, ", "), "", conf, execScheduler.GetState().ExecutionTuple,
+		executionPlan, outputs, globalFlags.noColor || !globalFlags.stdoutTTY, globalFlags)
+
+	// Trap Interrupts, SIGINTs and SIGTERMs.
+	sigC := make(chan os.Signal, 1)
+	signal.Notify(sigC, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigC)
+	go func() {
+		sig := <-sigC
+		logger.WithField("sig", sig).Debug("Stopping k6 in response to signal...")
+		lingerCancel() // stop the test run, metric processing is cancelled below
+
+		// If we get a second signal, we immediately exit, so something like
+		// https://github.com/k6io/k6/issues/971 never happens again
+		sig = <-sigC
+		logger.WithField("sig", sig).Error("Aborting k6 in response to signal")
+		globalCancel() // not that it matters, given the following command...
+		os.Exit(int(conf.ExitCodes.exitCode(exitcodes.ExternalAbort)))
+	}()
+
+	if err = hooks.beforeTest(globalCtx); err != nil {
+		return errext.WithExitCodeIfNone(err, exitcodes.HookFailed)
+	}
+
+	// Initialize the engine
+	initBar.Modify(pb.WithConstProgress(0, "Init VUs..."))
+	if err = engine.Init(globalCtx, runCtx); err != nil {
+		hooks.testFinished(globalCtx, true)
+		return err
+	}
+
+	// Init has passed successfully, so unless disabled, make sure we send a
+	// usage report after the context is done.
+	if !conf.NoUsageReport.Bool {
+		reportDone := make(chan struct{})
+		go func() {
+			<-runCtx.Done()
+			_ = reportUsage(execScheduler)
+			close(reportDone)
+		}()
+		defer func() {
+			select {
+			case <-reportDone:
+			case <-time.After(3 * time.Second):
 			}
-			return nil
-		},
+		}()
 	}
 
-	runCmd.Flags().SortFlags = false
-	runCmd.Flags().AddFlagSet(runCmdFlagSet(globalFlags))
+	// Start the test run
+	initBar.Modify(pb.WithConstProgress(0, "Starting test..."))
+	var interrupt error
+	err = engine.Run()
+	if err != nil {
+		if common.IsInterruptError(err) {
+			// Don't return here since we need to work with --linger,
+			// show the end-of-test summary and exit cleanly.
+			interrupt = err
+		}
+		if !conf.Linger.Bool && interrupt == nil {
+			hooks.testFinished(globalCtx, true)
+			return errext.WithExitCodeIfNone(err, exitcodes.GenericEngine)
+		}
+	}
+	// a signal or an error that didn't stop k6 because of --linger
+	aborted := err != nil || lingerCtx.Err() != nil
+	runCancel()
+	logger.Debug("Engine run terminated cleanly")
+
+	progressCancel()
+	progressBarWG.Wait()
+
+	executionState := execScheduler.GetState()
+	// Warn if no iterations could be completed.
+	if executionState.GetFullIterationCount() == 0 {
+		logger.Warn("No script iterations finished, consider making the test duration longer")
+	}
 
-	return runCmd
+	// Handle the end-of-test summary.
+	if !runtimeOptions.NoSummary.Bool {
+		summaryResult, err := initRunner.HandleSummary(globalCtx, &lib.Summary{
+			Metrics:         engine.Metrics,
+			RootGroup:       engine.ExecutionScheduler.GetRunner().GetDefaultGroup(),
+			TestRunDuration: executionState.GetCurrentTestRunDuration(),
+			NoColor:         globalFlags.noColor,
+			UIState: lib.UIState{
+				IsStdOutTTY: globalFlags.stdoutTTY,
+				IsStdErrTTY: globalFlags.stderrTTY,
+			},
+			Metadata:            metadata,
+			CoordinatedOmission: engine.CoordinatedOmissionCorrections(),
+			Timeline:            engine.Timeline(),
+			Anomalies:           engine.Anomalies(),
+		})
+		if err == nil {
+			hooks.setSummaryPaths(summaryResult)
+			err = handleSummaryResult(afero.NewOsFs(), globalFlags.stdout, globalFlags.stderr, summaryResult)
+		}
+		if err != nil {
+			logger.WithError(err).Error("failed to handle the end-of-test summary")
+		}
+	}
+	hooks.testFinished(globalCtx, aborted)
+
+	if conf.Linger.Bool {
+		select {
+		case <-lingerCtx.Done():
+			// do nothing, we were interrupted by Ctrl+C already
+		default:
+			logger.Debug("Linger set; waiting for Ctrl+C...")
+			fprintf(globalFlags.stdout, "Linger set; waiting for Ctrl+C...")
+			<-lingerCtx.Done()
+			logger.Debug("Ctrl+C received, exiting...")
+		}
+	}
+	globalCancel() // signal the Engine that it should wind down
+	logger.Debug("Waiting for engine processes to finish...")
+	engine.Wait()
+	engine.MetricsLock.Lock()
+	if crossed := crossedWarningThresholds(engine.Metrics); len(crossed) > 0 {
+		logger.Warnf("Some thresholds with the warn severity were crossed, without failing the test: %s",
+			strings.Join(crossed, ", "))
+	}
+	if path := conf.ThresholdResultFile; path.Valid && path.String != "" {
+		err := writeThresholdResults(afero.NewOsFs(), path.String, engine.Metrics)
+		if err != nil {
+			logger.WithError(err).Error("failed to write the threshold results")
+		}
+	}
+	engine.MetricsLock.Unlock()
+	logger.Debug("Everything has finished, exiting k6!")
+	if interrupt != nil {
+		return interrupt
+	}
+	if engine.IsTainted() {
+		return errext.WithExitCodeIfNone(errors.New("some thresholds have failed"), exitcodes.ThresholdsHaveFailed)
+	}
+	return nil
 }
 
 func reportUsage(execScheduler *local.ExecutionScheduler) error {
@@ -400,36 +525,90 @@ func runCmdFlagSet(globalFlags *commandFlags) *pflag.FlagSet {
 	// - and finally, global variables are not very testable... :/
 	flags.StringVarP(&globalFlags.runType, "type", "t", globalFlags.runType, "override file `type`, \"js\" or \"archive\"")
 	flags.Lookup("type").DefValue = ""
+	flags.IntVar(&globalFlags.retries, "retries", 0,
+		"run the test again up to `N` times if it fails to start because of an infrastructure error")
+	flags.StringVar(&globalFlags.progress, "progress", globalFlags.progress,
+		"how to show the progress of the test, \"bars\", \"json\" lines for CI logs or \"none\"")
+	flags.DurationVar(&globalFlags.progressInterval, "progress-interval", globalFlags.progressInterval,
+		"how often to write the progress with --progress=json")
+	flags.String("baseline", "", "compare the thresholds using `baseline` with the JSON summary of a previous test")
 	return flags
 }
 
-// Creates a new runner.
-func newRunner(
-	logger *logrus.Logger, src *loader.SourceData, typ string, filesystems map[string]afero.Fs, rtOpts lib.RuntimeOptions,
-	builtinMetrics *metrics.BuiltinMetrics, registry *metrics.Registry,
-) (runner lib.Runner, err error) {
-	switch typ {
-	case "":
-		runner, err = newRunner(logger, src, detectType(src.Data), filesystems, rtOpts, builtinMetrics, registry)
-	case typeJS:
-		runner, err = js.New(logger, src, filesystems, rtOpts, builtinMetrics, registry)
-	case typeArchive:
-		var arc *lib.Archive
-		arc, err = lib.ReadArchive(bytes.NewReader(src.Data))
-		if err != nil {
-			return nil, err
-		}
-		switch arc.Type {
-		case typeJS:
-			runner, err = js.NewFromArchive(logger, arc, rtOpts, builtinMetrics, registry)
-		default:
-			return nil, fmt.Errorf("archive requests unsupported runner: %s", arc.Type)
+// measureClockOffset sets the clock offset of the runtime options from the
+// NTP server, if there's one. The test isn't stopped if the server can't be
+// queried, the sample times just aren't adjusted.
+func measureClockOffset(ctx context.Context, rtOpts *lib.RuntimeOptions, logger logrus.FieldLogger) error {
+	server := rtOpts.NTPServer.String
+	if server == "" {
+		return nil
+	}
+	if rtOpts.ClockOffset.Valid {
+		return errors.New("the clock offset can't be both set and measured with a NTP server")
+	}
+	ctx, cancel := context.WithTimeout(ctx, ntpTimeout)
+	defer cancel()
+	offset, err := clocksync.QueryNTP(ctx, server)
+	if err != nil {
+		logger.WithError(err).Warnf("Couldn't measure the clock offset with the NTP server %s", server)
+		return nil
+	}
+	logger.WithFields(logrus.Fields{
+		"offset":    offset.Offset,
+		"roundTrip": offset.RoundTrip,
+	}).Debugf("Measured the clock offset with the NTP server %s", server)
+	rtOpts.ClockOffset = types.NullDurationFrom(offset.Offset)
+	return nil
+}
+
+// thresholdResults is what's written to the --threshold-result-file, for the CI
+// scripts which only need to know whether the thresholds passed and why. The
+// crossed thresholds of the warn severity don't make it not passed.
+type thresholdResults struct {
+	Passed     bool                    `json:"passed"`
+	Thresholds []metricThresholdResult `json:"thresholds"`
+}
+
+type metricThresholdResult struct {
+	Metric string `json:"metric"`
+	stats.ThresholdResult
+}
+
+func writeThresholdResults(fs afero.Fs, path string, metrics map[string]*stats.Metric) error {
+	names := make([]string, 0, len(metrics))
+	for name := range metrics {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	results := thresholdResults{Passed: true, Thresholds: []metricThresholdResult{}}
+	for _, name := range names {
+		for _, result := range metrics[name].Thresholds.Results() {
+			results.Passed = results.Passed && (result.Passed || result.Severity == stats.ThresholdSeverityWarn)
+			results.Thresholds = append(results.Thresholds, metricThresholdResult{Metric: name, ThresholdResult: result})
 		}
-	default:
-		return nil, fmt.Errorf("unknown -t/--type: %s", typ)
 	}
 
-	return runner, err
+	data, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return afero.WriteFile(fs, path, append(data, '\n'), 0o644)
+}
+
+// crossedWarningThresholds returns the thresholds of the warn severity which
+// were crossed, as "metric: threshold", sorted.
+func crossedWarningThresholds(metrics map[string]*stats.Metric) []string {
+	var crossed []string
+	for name, m := range metrics {
+		for _, t := range m.Thresholds.Thresholds {
+			if t.IsWarning() && t.LastFailed {
+				crossed = append(crossed, name+": "+t.Source)
+			}
+		}
+	}
+	sort.Strings(crossed)
+	return crossed
 }
 
 func handleSummaryResult(fs afero.Fs, stdOut, stdErr io.Writer, result map[string]io.Reader) error {
@@ -32,17 +32,22 @@ import (
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/errext"
 	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/fsext"
 	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
 )
 
 type mockWriter struct {
@@ -134,6 +139,63 @@ func TestHandleSummaryResultError(t *testing.T) {
 	assertEqual(t, "file summary 2", files[filePath2])
 }
 
+func TestWriteThresholdResults(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+
+	durationThresholds := stats.NewThresholds([]string{"p(95)<500", "avg<200"})
+	require.NoError(t, durationThresholds.Parse())
+	_, err := durationThresholds.Run(stats.DummySink{"p(95)": 612.5, "avg": 150}, time.Second)
+	require.NoError(t, err)
+	failedThresholds := stats.NewThresholds([]string{"rate<0.01"})
+	require.NoError(t, failedThresholds.Parse())
+	_, err = failedThresholds.Run(stats.DummySink{"rate": 0}, time.Second)
+	require.NoError(t, err)
+	lastRun := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	for _, threshold := range append(durationThresholds.Thresholds, failedThresholds.Thresholds...) {
+		threshold.LastRun = lastRun
+	}
+
+	metrics := map[string]*stats.Metric{
+		"http_req_failed":   {Name: "http_req_failed", Thresholds: failedThresholds},
+		"http_req_duration": {Name: "http_req_duration", Thresholds: durationThresholds},
+		"iterations":        {Name: "iterations"},
+	}
+	require.NoError(t, writeThresholdResults(fs, "/thresholds.json", metrics))
+
+	data, err := afero.ReadFile(fs, "/thresholds.json")
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"passed": false,
+		"thresholds": [
+			{
+				"metric": "http_req_duration", "threshold": "p(95)<500", "aggregation": "p(95)", "operator": "<",
+				"limit": 500, "observed": 612.5, "margin": -112.5, "passed": false, "abortOnFail": false,
+				"lastRun": "2021-06-01T12:00:00Z"
+			},
+			{
+				"metric": "http_req_duration", "threshold": "avg<200", "aggregation": "avg", "operator": "<",
+				"limit": 200, "observed": 150, "margin": 50, "passed": true, "abortOnFail": false,
+				"lastRun": "2021-06-01T12:00:00Z"
+			},
+			{
+				"metric": "http_req_failed", "threshold": "rate<0.01", "aggregation": "rate", "operator": "<",
+				"limit": 0.01, "observed": 0, "margin": 0.01, "passed": true, "abortOnFail": false,
+				"lastRun": "2021-06-01T12:00:00Z"
+			}
+		]
+	}`, string(data))
+	assert.Empty(t, crossedWarningThresholds(metrics))
+
+	durationThresholds.Thresholds[0].Severity = stats.ThresholdSeverityWarn
+	require.NoError(t, writeThresholdResults(fs, "/thresholds.json", metrics))
+	data, err = afero.ReadFile(fs, "/thresholds.json")
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"passed": true,`)
+	assert.Contains(t, string(data), `"severity": "warn"`)
+	assert.Equal(t, []string{"http_req_duration: p(95)<500"}, crossedWarningThresholds(metrics))
+}
+
 func TestAbortTest(t *testing.T) {
 	t.Parallel()
 	testCases := []struct {
@@ -234,6 +296,18 @@ func TestRunThresholds(t *testing.T) {
 			testFilename: "testdata/thresholds/malformed_expression.js",
 			wantErr:      false,
 		},
+		{
+			name:         "run should fail with exit status 104 on a malformed threshold of a custom metric",
+			noThresholds: false,
+			testFilename: "testdata/thresholds/malformed_metric_expression.js",
+			wantErr:      true,
+		},
+		{
+			name:         "run should not fail on a malformed threshold of a custom metric with --no-thresholds",
+			noThresholds: true,
+			testFilename: "testdata/thresholds/malformed_metric_expression.js",
+			wantErr:      false,
+		},
 	}
 
 	for _, testCase := range testCases {
@@ -271,3 +345,34 @@ func TestRunThresholds(t *testing.T) {
 		})
 	}
 }
+
+func TestMeasureClockOffset(t *testing.T) {
+	t.Parallel()
+
+	t.Run("not configured", func(t *testing.T) {
+		t.Parallel()
+		rtOpts := lib.RuntimeOptions{ClockOffset: types.NullDurationFrom(time.Second)}
+		require.NoError(t, measureClockOffset(context.Background(), &rtOpts, testutils.NewLogger(t)))
+		assert.Equal(t, types.NullDurationFrom(time.Second), rtOpts.ClockOffset)
+	})
+
+	t.Run("set and measured", func(t *testing.T) {
+		t.Parallel()
+		rtOpts := lib.RuntimeOptions{
+			ClockOffset: types.NullDurationFrom(time.Second),
+			NTPServer:   null.StringFrom("127.0.0.1"),
+		}
+		err := measureClockOffset(context.Background(), &rtOpts, testutils.NewLogger(t))
+		require.Error(t, err)
+		assert.Equal(t, "the clock offset can't be both set and measured with a NTP server", err.Error())
+	})
+
+	t.Run("unreachable server", func(t *testing.T) {
+		t.Parallel()
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		rtOpts := lib.RuntimeOptions{NTPServer: null.StringFrom("127.0.0.1:1")}
+		require.NoError(t, measureClockOffset(ctx, &rtOpts, testutils.NewLogger(t)))
+		assert.False(t, rtOpts.ClockOffset.Valid)
+	})
+}
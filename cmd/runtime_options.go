@@ -25,11 +25,13 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/spf13/pflag"
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
 )
 
 // TODO: move this whole file out of the cmd package? maybe when fixing
@@ -59,19 +61,36 @@ func runtimeOptionFlagSet(includeSysEnv bool) *pflag.FlagSet {
 	flags.SortFlags = false
 	flags.Bool("include-system-env-vars", includeSysEnv, "pass the real system environment variables to the runtime")
 	flags.String("compatibility-mode", "extended",
-		`JavaScript compiler compatibility mode, "extended" or "base"
+		`JavaScript compiler compatibility mode, "extended", "base" or "experimental_enhanced"
 base: pure goja - Golang JS VM supporting ES5.1+
 extended: base + Babel with parts of ES2015 preset
 		  slower to compile in case the script uses syntax unsupported by base
+experimental_enhanced: base + the imports and exports of ES modules rewritten without Babel,
+		  falling back to extended for the syntax unsupported by base
 `)
 	flags.StringArrayP("env", "e", nil, "add/override environment variable with `VAR=value`")
+	flags.StringArray("script-arg", nil, "pass an argument to the script as `key=value`, it's in exec.test.args "+
+		"of k6/execution, as a number, a boolean or JSON if the value is valid JSON, and as a string otherwise")
 	flags.Bool("no-thresholds", false, "don't run thresholds")
 	flags.Bool("no-summary", false, "don't show the summary at the end of the test")
-	flags.String(
+	flags.StringArray(
 		"summary-export",
-		"",
-		"output the end-of-test summary report to JSON file",
+		nil,
+		"output the end-of-test summary report to a file, as `[format=]path`, the format is json (default), junit, "+
+			"html or markdown; can be repeated",
 	)
+	flags.Bool("allow-exec", false, "allow the execution of local commands in setup() and teardown()")
+	flags.Bool("env-metadata", false,
+		"detect the cloud provider, region, instance type and Kubernetes pod to add them to the results")
+	flags.Duration("clock-offset", 0, "offset of the local clock, added to the time of all the samples")
+	flags.String("ntp-server", "", "measure the offset of the local clock with this NTP server")
+	flags.Bool("verbose-init", false, "log the time and memory each imported module took in the init context")
+	flags.Bool("strict", false, "fail on unknown, likely misspelled, options in the script and the config file, "+
+		"instead of warning about them")
+	flags.Bool("strict-globals", false, "warn about the global variables created by the iterations of a VU, "+
+		"which are shared by all of its iterations")
+	flags.Bool("strict-preflight", false, "don't start the test if it may need more sockets than the limit of open "+
+		"files or the ephemeral ports allow, instead of warning about it")
 	return flags
 }
 
@@ -99,7 +118,14 @@ func getRuntimeOptions(flags *pflag.FlagSet, environment map[string]string) (lib
 		CompatibilityMode:    getNullString(flags, "compatibility-mode"),
 		NoThresholds:         getNullBool(flags, "no-thresholds"),
 		NoSummary:            getNullBool(flags, "no-summary"),
-		SummaryExport:        getNullString(flags, "summary-export"),
+		AllowExec:            getNullBool(flags, "allow-exec"),
+		EnvMetadata:          getNullBool(flags, "env-metadata"),
+		ClockOffset:          getNullDuration(flags, "clock-offset"),
+		NTPServer:            getNullString(flags, "ntp-server"),
+		VerboseInit:          getNullBool(flags, "verbose-init"),
+		StrictOptions:        getNullBool(flags, "strict"),
+		StrictGlobals:        getNullBool(flags, "strict-globals"),
+		StrictPreflight:      getNullBool(flags, "strict-preflight"),
 		Env:                  make(map[string]string),
 	}
 
@@ -123,11 +149,46 @@ func getRuntimeOptions(flags *pflag.FlagSet, environment map[string]string) (lib
 	if err := saveBoolFromEnv(environment, "K6_NO_SUMMARY", &opts.NoSummary); err != nil {
 		return opts, err
 	}
+	if err := saveBoolFromEnv(environment, "K6_ALLOW_EXEC", &opts.AllowExec); err != nil {
+		return opts, err
+	}
+	if err := saveBoolFromEnv(environment, "K6_ENV_METADATA", &opts.EnvMetadata); err != nil {
+		return opts, err
+	}
+	if err := saveBoolFromEnv(environment, "K6_VERBOSE_INIT", &opts.VerboseInit); err != nil {
+		return opts, err
+	}
+	if err := saveBoolFromEnv(environment, "K6_STRICT", &opts.StrictOptions); err != nil {
+		return opts, err
+	}
+	if err := saveBoolFromEnv(environment, "K6_STRICT_GLOBALS", &opts.StrictGlobals); err != nil {
+		return opts, err
+	}
+	if err := saveBoolFromEnv(environment, "K6_STRICT_PREFLIGHT", &opts.StrictPreflight); err != nil {
+		return opts, err
+	}
 
-	if envVar, ok := environment["K6_SUMMARY_EXPORT"]; ok {
-		if !opts.SummaryExport.Valid {
-			opts.SummaryExport = null.StringFrom(envVar)
+	if flags.Changed("summary-export") {
+		summaryExport, err := flags.GetStringArray("summary-export")
+		if err != nil {
+			return opts, err
 		}
+		opts.SummaryExport = summaryExport
+	} else if envVar, ok := environment["K6_SUMMARY_EXPORT"]; ok && envVar != "" {
+		opts.SummaryExport = strings.Split(envVar, ",")
+	}
+	if _, err := opts.GetSummaryExports(); err != nil {
+		return opts, err
+	}
+	if envVar, ok := environment["K6_CLOCK_OFFSET"]; ok && !opts.ClockOffset.Valid {
+		offset, err := time.ParseDuration(envVar)
+		if err != nil {
+			return opts, fmt.Errorf("env var 'K6_CLOCK_OFFSET' is not a valid duration: %w", err)
+		}
+		opts.ClockOffset = types.NullDurationFrom(offset)
+	}
+	if envVar, ok := environment["K6_NTP_SERVER"]; ok && !opts.NTPServer.Valid {
+		opts.NTPServer = null.StringFrom(envVar)
 	}
 
 	if opts.IncludeSystemEnvVars.Bool { // If enabled, gather the actual system environment variables
@@ -148,5 +209,20 @@ func getRuntimeOptions(flags *pflag.FlagSet, environment map[string]string) (lib
 		opts.Env[k] = v
 	}
 
+	scriptArgs, err := flags.GetStringArray("script-arg")
+	if err != nil {
+		return opts, err
+	}
+	for _, kv := range scriptArgs {
+		k, v := parseEnvKeyValue(kv)
+		if k == "" || !strings.Contains(kv, "=") {
+			return opts, fmt.Errorf("invalid script argument '%s', use 'key=value'", kv)
+		}
+		if opts.ScriptArgs == nil {
+			opts.ScriptArgs = make(map[string]string, len(scriptArgs))
+		}
+		opts.ScriptArgs[k] = v
+	}
+
 	return opts, nil
 }
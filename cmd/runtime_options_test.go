@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/spf13/afero"
 	"github.com/stretchr/testify/assert"
@@ -34,7 +35,9 @@ import (
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/loader"
+	"go.k6.io/k6/pkg/testrun"
 )
 
 type runtimeOptionsTestCase struct {
@@ -80,7 +83,7 @@ func testRuntimeOptionsCase(t *testing.T, tc runtimeOptionsTestCase) {
 	require.NoError(t, afero.WriteFile(fs, "/script.js", jsCode.Bytes(), 0o644))
 	registry := metrics.NewRegistry()
 	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
-	runner, err := newRunner(
+	runner, err := testrun.NewRunner(
 		testutils.NewLogger(t),
 		&loader.SourceData{Data: jsCode.Bytes(), URL: &url.URL{Path: "/script.js", Scheme: "file"}},
 		typeJS,
@@ -96,7 +99,7 @@ func testRuntimeOptionsCase(t *testing.T, tc runtimeOptionsTestCase) {
 	require.NoError(t, archive.Write(archiveBuf))
 
 	getRunnerErr := func(rtOpts lib.RuntimeOptions) (lib.Runner, error) {
-		return newRunner(
+		return testrun.NewRunner(
 			testutils.NewLogger(t),
 			&loader.SourceData{
 				Data: archiveBuf.Bytes(),
@@ -333,22 +336,50 @@ func TestRuntimeOptions(t *testing.T) {
 				Env:                  map[string]string{},
 				NoThresholds:         null.NewBool(false, true),
 				NoSummary:            null.NewBool(false, true),
-				SummaryExport:        null.NewString("foo", true),
+				SummaryExport:        []string{"foo"},
 			},
 		},
 		"summary and thresholds from env overwritten by CLI": {
 			useSysEnv: false,
 			systemEnv: map[string]string{"K6_NO_THRESHOLDS": "FALSE", "K6_NO_SUMMARY": "0", "K6_SUMMARY_EXPORT": "foo"},
-			cliFlags:  []string{"--no-thresholds", "true", "--no-summary", "true", "--summary-export", "bar"},
+			cliFlags: []string{
+				"--no-thresholds", "true", "--no-summary", "true", "--summary-export", "bar",
+				"--summary-export", "junit=bar.xml",
+			},
 			expRTOpts: lib.RuntimeOptions{
 				IncludeSystemEnvVars: null.NewBool(false, false),
 				CompatibilityMode:    defaultCompatMode,
 				Env:                  map[string]string{},
 				NoThresholds:         null.NewBool(true, true),
 				NoSummary:            null.NewBool(true, true),
-				SummaryExport:        null.NewString("bar", true),
+				SummaryExport:        []string{"bar", "junit=bar.xml"},
+			},
+		},
+		"multiple summary exports from env": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_SUMMARY_EXPORT": "summary.json,html=report"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				SummaryExport:        []string{"summary.json", "html=report"},
+			},
+		},
+		"summary export path with =": {
+			useSysEnv: false,
+			cliFlags:  []string{"--summary-export", "out=summary.json"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				SummaryExport:        []string{"out=summary.json"},
 			},
 		},
+		"summary export without a path": {
+			useSysEnv: false,
+			cliFlags:  []string{"--summary-export", "junit="},
+			expErr:    true,
+		},
 		"env var error detected even when CLI flags overwrite 1": {
 			useSysEnv: false,
 			systemEnv: map[string]string{"K6_NO_THRESHOLDS": "boo"},
@@ -361,6 +392,136 @@ func TestRuntimeOptions(t *testing.T) {
 			cliFlags:  []string{"--no-summary", "true"},
 			expErr:    true,
 		},
+		"allow exec from env": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_ALLOW_EXEC": "true"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				AllowExec:            null.NewBool(true, true),
+			},
+		},
+		"allow exec from env overwritten by CLI": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_ALLOW_EXEC": "true"},
+			cliFlags:  []string{"--allow-exec=false"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				AllowExec:            null.NewBool(false, true),
+			},
+		},
+		"env var error for allow exec": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_ALLOW_EXEC": "yes please"},
+			expErr:    true,
+		},
+		"env metadata from env overwritten by CLI": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_ENV_METADATA": "false"},
+			cliFlags:  []string{"--env-metadata"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				EnvMetadata:          null.NewBool(true, true),
+			},
+		},
+		"verbose init from env": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_VERBOSE_INIT": "true"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				VerboseInit:          null.NewBool(true, true),
+			},
+		},
+		"clock offset and NTP server from env": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_CLOCK_OFFSET": "-250ms", "K6_NTP_SERVER": "pool.ntp.org"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				ClockOffset:          types.NullDurationFrom(-250 * time.Millisecond),
+				NTPServer:            null.StringFrom("pool.ntp.org"),
+			},
+		},
+		"clock offset from env overwritten by CLI": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_CLOCK_OFFSET": "-250ms"},
+			cliFlags:  []string{"--clock-offset", "1s"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				ClockOffset:          types.NullDurationFrom(time.Second),
+			},
+		},
+		"script args": {
+			useSysEnv: false,
+			cliFlags:  []string{"--script-arg", "n=5", "--script-arg", "url=http://a/?b=c", "--script-arg", "empty="},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				ScriptArgs:           map[string]string{"n": "5", "url": "http://a/?b=c", "empty": ""},
+			},
+		},
+		"script arg without a value": {
+			useSysEnv: false,
+			cliFlags:  []string{"--script-arg", "debug"},
+			expErr:    true,
+		},
+		"strict flag": {
+			useSysEnv: false,
+			cliFlags:  []string{"--strict"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				StrictOptions:        null.BoolFrom(true),
+			},
+		},
+		"strict env var overwritten by the flag": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_STRICT": "true"},
+			cliFlags:  []string{"--strict=false"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				StrictOptions:        null.BoolFrom(false),
+			},
+		},
+		"strict globals from env": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_STRICT_GLOBALS": "true"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				StrictGlobals:        null.BoolFrom(true),
+			},
+		},
+		"strict preflight flag": {
+			useSysEnv: false,
+			cliFlags:  []string{"--strict-preflight"},
+			expRTOpts: lib.RuntimeOptions{
+				IncludeSystemEnvVars: null.NewBool(false, false),
+				CompatibilityMode:    defaultCompatMode,
+				Env:                  map[string]string{},
+				StrictPreflight:      null.BoolFrom(true),
+			},
+		},
+		"env var error for clock offset": {
+			useSysEnv: false,
+			systemEnv: map[string]string{"K6_CLOCK_OFFSET": "a bit"},
+			expErr:    true,
+		},
 	}
 	for name, tc := range runtimeOptionsTestCases {
 		tc := tc
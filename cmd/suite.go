@@ -0,0 +1,302 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/loader"
+	"go.k6.io/k6/pkg/testrun"
+	"go.k6.io/k6/stats"
+)
+
+const (
+	// suiteFilename is the name of the generated script of a suite, it's in
+	// the working directory but it doesn't exist there.
+	suiteFilename = "k6-suite.js"
+	// suiteScriptTag is the tag with the script of the scenarios of a suite.
+	suiteScriptTag = "script"
+)
+
+var suiteNameReplacer = regexp.MustCompile(`[^0-9a-zA-Z_-]+`) //nolint:gochecknoglobals
+
+// suiteScript is one of the scripts of a suite.
+type suiteScript struct {
+	name    string
+	url     *url.URL
+	options lib.Options
+	// the functions of the script that the suite script calls
+	hasSetup, hasTeardown, hasHandleSummary bool
+}
+
+// readSuite reads the scripts of `k6 run a.js b.js` and returns the source of
+// a suite script that runs all of them. Each script becomes a group of
+// scenarios named after it, and tagged with script=name, while the other
+// options, the metrics, the thresholds and the outputs are shared:
+//   - the scenarios of each script, or the ones derived from its execution
+//     shortcuts, are prefixed with its name, so a.js's default scenario becomes
+//     a_default, and their exec function is exported as a_<exec>
+//   - the other options are merged in order, so the ones of the later scripts
+//     win, except the thresholds, which are all kept
+//   - the setup() of the suite calls the one of each script, and each script
+//     gets its own data in its exec functions and teardown()
+//   - only the handleSummary() of the first script that has one is used
+//
+//nolint:funlen
+func readSuite(
+	filenames []string, logger *logrus.Logger, rtOpts lib.RuntimeOptions,
+) (*loader.SourceData, map[string]afero.Fs, error) {
+	pwd, err := os.Getwd()
+	if err != nil {
+		return nil, nil, err
+	}
+	scripts := make([]suiteScript, 0, len(filenames))
+	names := make(map[string]bool, len(filenames))
+	for _, filename := range filenames {
+		if filename == "-" {
+			return nil, nil, errors.New("the script can't be read from stdin when running multiple scripts")
+		}
+		// the filesystems only allow reading the files that were read during
+		// the init of the runner after it, so each script needs its own ones
+		filesystems := loader.CreateFilesystems()
+		src, err := loader.ReadSource(logger, filename, pwd, filesystems, os.Stdin)
+		if err != nil {
+			return nil, nil, err
+		}
+		if testrun.DetectType(src.Data) != typeJS {
+			return nil, nil, fmt.Errorf("%s is an archive, only scripts can be run together", filename)
+		}
+
+		registry := metrics.NewRegistry()
+		runner, err := testrun.NewRunner(
+			logger, src, typeJS, filesystems, rtOpts, metrics.RegisterBuiltinMetrics(registry), registry)
+		if err != nil {
+			return nil, nil, err
+		}
+		options, err := executor.DeriveScenariosFromShortcuts(runner.GetOptions(), logger)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid execution options of %s: %w", filename, err)
+		}
+
+		name := suiteScriptName(src.URL)
+		for i := 2; names[name]; i++ {
+			name = fmt.Sprintf("%s_%d", suiteScriptName(src.URL), i)
+		}
+		names[name] = true
+		for scenario, config := range options.Scenarios {
+			if exec := config.GetExec(); !runner.IsExecutable(exec) {
+				return nil, nil, fmt.Errorf("the exec function %q of the scenario %q isn't exported by %s",
+					exec, scenario, filename)
+			}
+		}
+		scripts = append(scripts, suiteScript{
+			name:             name,
+			url:              src.URL,
+			options:          options,
+			hasSetup:         runner.IsExecutable(consts.SetupFn),
+			hasTeardown:      runner.IsExecutable(consts.TeardownFn),
+			hasHandleSummary: runner.IsExecutable(consts.HandleSummaryFn),
+		})
+	}
+
+	options, err := mergeSuiteOptions(scripts)
+	if err != nil {
+		return nil, nil, err
+	}
+	data, err := suiteSource(scripts, options, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	suitePath := filepath.Clean(afero.FilePathSeparator + filepath.Join(pwd, suiteFilename))
+	return &loader.SourceData{
+		URL:  &url.URL{Scheme: "file", Path: filepath.ToSlash(suitePath)},
+		Data: data,
+	}, loader.CreateFilesystems(), nil
+}
+
+// suiteScriptName returns the name of a script in a suite, which is its
+// filename without the extension, and with only the characters that are valid
+// in scenario names.
+func suiteScriptName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if u.Opaque != "" {
+		name = path.Base(u.Opaque)
+	}
+	name = strings.TrimSuffix(name, path.Ext(name))
+	name = strings.Trim(suiteNameReplacer.ReplaceAllString(name, "_"), "_")
+	if name == "" {
+		return "script"
+	}
+	return name
+}
+
+// mergeSuiteOptions merges the options of the scripts of a suite, with their
+// scenarios renamed and tagged after their script.
+func mergeSuiteOptions(scripts []suiteScript) (lib.Options, error) {
+	var result lib.Options
+	scenarios := make(map[string]json.RawMessage)
+	thresholds := make(map[string]stats.Thresholds)
+	for _, script := range scripts {
+		for name, config := range script.options.Scenarios {
+			data, err := json.Marshal(config)
+			if err != nil {
+				return result, err
+			}
+			var fields map[string]interface{}
+			if err = json.Unmarshal(data, &fields); err != nil {
+				return result, err
+			}
+			fields["exec"] = script.name + "_" + config.GetExec()
+			tags, _ := fields["tags"].(map[string]interface{})
+			if tags == nil {
+				tags = make(map[string]interface{})
+			}
+			if _, ok := tags[suiteScriptTag]; !ok {
+				tags[suiteScriptTag] = script.name
+			}
+			fields["tags"] = tags
+			if scenarios[script.name+"_"+name], err = json.Marshal(fields); err != nil {
+				return result, err
+			}
+		}
+		for metric, ths := range script.options.Thresholds {
+			merged := thresholds[metric]
+			merged.Thresholds = append(merged.Thresholds, ths.Thresholds...)
+			merged.Abort = merged.Abort || ths.Abort
+			thresholds[metric] = merged
+		}
+		result = result.Apply(script.options)
+	}
+
+	// the scenarios replace the execution shortcuts of all the scripts
+	result.VUs = null.Int{}
+	result.Iterations = null.Int{}
+	result.Duration = types.NullDuration{}
+	result.Stages = nil
+	if len(thresholds) > 0 {
+		result.Thresholds = thresholds
+	}
+	data, err := json.Marshal(scenarios)
+	if err != nil {
+		return result, err
+	}
+	result.Scenarios = nil
+	if err = json.Unmarshal(data, &result.Scenarios); err != nil {
+		return result, err
+	}
+	return result, nil
+}
+
+// suiteSource returns the code of the suite script, which is plain ES5.1 so it
+// works in both compatibility modes.
+func suiteSource(scripts []suiteScript, options lib.Options, logger logrus.FieldLogger) ([]byte, error) {
+	optionsJSON, err := json.Marshal(options)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	b.WriteString("var scripts = {};\n")
+	var hasSetup, hasTeardown bool
+	handleSummary := ""
+	for _, script := range scripts {
+		fmt.Fprintf(&b, "scripts[%s] = require(%s);\n", jsString(script.name), jsString(script.url.String()))
+		hasSetup = hasSetup || script.hasSetup
+		hasTeardown = hasTeardown || script.hasTeardown
+		if !script.hasHandleSummary {
+			continue
+		}
+		if handleSummary == "" {
+			handleSummary = script.name
+		} else {
+			logger.Warnf("Only the handleSummary() of %s is used, the one of %s is ignored", handleSummary, script.name)
+		}
+	}
+	fmt.Fprintf(&b, "exports.options = %s;\n", optionsJSON)
+
+	if hasSetup {
+		b.WriteString(`exports.setup = function () {
+	var data = {};
+	for (var name in scripts) {
+		if (typeof scripts[name].setup === "function") {
+			data[name] = scripts[name].setup();
+		}
+	}
+	return data;
+};
+`)
+	}
+	if hasTeardown {
+		b.WriteString(`exports.teardown = function (data) {
+	for (var name in scripts) {
+		if (typeof scripts[name].teardown === "function") {
+			scripts[name].teardown(data ? data[name] : undefined);
+		}
+	}
+};
+`)
+	}
+	if handleSummary != "" {
+		fmt.Fprintf(&b, "exports.handleSummary = scripts[%s].handleSummary;\n", jsString(handleSummary))
+	}
+
+	execs := make(map[string]bool)
+	for _, script := range scripts {
+		for _, config := range script.options.Scenarios {
+			fn := script.name + "_" + config.GetExec()
+			if execs[fn] {
+				continue
+			}
+			execs[fn] = true
+			name := jsString(script.name)
+			// the other arguments, e.g. the iteration context, are passed as-is
+			fmt.Fprintf(&b, `exports[%s] = function (data) {
+	var args = [data ? data[%s] : undefined].concat(Array.prototype.slice.call(arguments, 1));
+	return scripts[%s][%s].apply(this, args);
+};
+`, jsString(fn), name, name, jsString(config.GetExec()))
+		}
+	}
+	return b.Bytes(), nil
+}
+
+// jsString returns s as a JS string literal.
+func jsString(s string) string {
+	data, _ := json.Marshal(s) //nolint:errchkjson
+	return string(data)
+}
@@ -0,0 +1,183 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func TestSuiteScriptName(t *testing.T) {
+	t.Parallel()
+	for rawURL, exp := range map[string]string{
+		"file:///tmp/browse.js":              "browse",
+		"file:///tmp/check-out.test.js":      "check-out_test",
+		"https://example.com/scripts/api.js": "api",
+		"github.com/k6io/scripts/smoke.js":   "smoke",
+		"file:///tmp/.js":                    "script",
+	} {
+		u, err := url.Parse(rawURL)
+		require.NoError(t, err)
+		assert.Equal(t, exp, suiteScriptName(u), rawURL)
+	}
+}
+
+func TestMergeSuiteOptions(t *testing.T) {
+	t.Parallel()
+	logger := testutils.NewLogger(t)
+	browse, err := executor.DeriveScenariosFromShortcuts(lib.Options{
+		VUs:        null.IntFrom(5),
+		Duration:   types.NullDurationFrom(10 * time.Second),
+		Thresholds: map[string]stats.Thresholds{"http_req_duration": stats.NewThresholds([]string{"p(95)<500"})},
+		UserAgent:  null.StringFrom("browse"),
+	}, logger)
+	require.NoError(t, err)
+	checkout := lib.Options{
+		Scenarios: lib.ScenarioConfigs{
+			"buy": func() executor.PerVUIterationsConfig {
+				config := executor.NewPerVUIterationsConfig("buy")
+				config.Exec = null.StringFrom("buy")
+				config.Tags = map[string]string{"flow": "checkout"}
+				return config
+			}(),
+		},
+		Thresholds: map[string]stats.Thresholds{"http_req_duration": stats.NewThresholds([]string{"p(99)<1000"})},
+		UserAgent:  null.StringFrom("checkout"),
+	}
+
+	merged, err := mergeSuiteOptions([]suiteScript{
+		{name: "browse", options: browse},
+		{name: "checkout", options: checkout},
+	})
+	require.NoError(t, err)
+
+	assert.False(t, merged.VUs.Valid)
+	assert.False(t, merged.Duration.Valid)
+	assert.Equal(t, null.StringFrom("checkout"), merged.UserAgent)
+	require.Len(t, merged.Scenarios, 2)
+	browseDefault := merged.Scenarios["browse_default"]
+	require.NotNil(t, browseDefault)
+	assert.Equal(t, "constant-vus", browseDefault.GetType())
+	assert.Equal(t, "browse_default", browseDefault.GetExec())
+	assert.Equal(t, map[string]string{"script": "browse"}, browseDefault.GetTags())
+	checkoutBuy := merged.Scenarios["checkout_buy"]
+	require.NotNil(t, checkoutBuy)
+	assert.Equal(t, "checkout_buy", checkoutBuy.GetExec())
+	assert.Equal(t, map[string]string{"flow": "checkout", "script": "checkout"}, checkoutBuy.GetTags())
+
+	ths := merged.Thresholds["http_req_duration"]
+	require.Len(t, ths.Thresholds, 2)
+	assert.Equal(t, "p(95)<500", ths.Thresholds[0].Source)
+	assert.Equal(t, "p(99)<1000", ths.Thresholds[1].Source)
+}
+
+func TestRunSuite(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	scripts := map[string]string{
+		"browse.js": `
+			import { Counter } from "k6/metrics";
+			const pages = new Counter("pages");
+			export const options = { iterations: 3, thresholds: { pages: ["count>=3"] } };
+			export function setup() { return { page: "home" }; }
+			export default function (data, ctx) {
+				if (data.page !== "home") { throw new Error("wrong data: " + JSON.stringify(data)); }
+				if (!ctx || ctx.scenario !== "browse_default") { throw new Error("wrong context: " + JSON.stringify(ctx)); }
+				pages.add(1);
+			}
+		`,
+		"checkout.js": `
+			import { Counter } from "k6/metrics";
+			import exec from "k6/execution";
+			const pages = new Counter("pages");
+			export const options = {
+				scenarios: {
+					buy: { executor: "per-vu-iterations", vus: 2, iterations: 1, exec: "buy", args: { x: 1 } },
+				},
+				thresholds: { "pages{script:checkout}": ["count==2"] },
+			};
+			export function buy(data, ctx, args) {
+				if (data !== undefined) { throw new Error("unexpected data"); }
+				if (exec.scenario.name !== "checkout_buy") { throw new Error("wrong scenario"); }
+				if (!ctx || ctx.scenario !== "checkout_buy") { throw new Error("wrong context: " + JSON.stringify(ctx)); }
+				if (!args || args.x !== 1) { throw new Error("wrong args: " + JSON.stringify(args)); }
+				pages.add(1);
+			}
+		`,
+	}
+	args := []string{"--no-usage-report", "--summary-export", filepath.Join(dir, "summary.json")}
+	for name, script := range scripts {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, name), []byte(script), 0o600))
+	}
+	args = append(args, filepath.Join(dir, "browse.js"), filepath.Join(dir, "checkout.js"))
+
+	cmd := getRunCmd(context.Background(), testutils.NewLogger(t), newCommandFlags())
+	cmd.SetArgs(args)
+	require.NoError(t, cmd.Execute())
+
+	data, err := ioutil.ReadFile(filepath.Join(dir, "summary.json")) //nolint:gosec
+	require.NoError(t, err)
+	var summary struct {
+		Metrics map[string]map[string]interface{} `json:"metrics"`
+	}
+	require.NoError(t, json.Unmarshal(data, &summary))
+	assert.Equal(t, float64(5), summary.Metrics["pages"]["count"])
+	assert.Equal(t, map[string]interface{}{"count>=3": false}, summary.Metrics["pages"]["thresholds"])
+	assert.Equal(t, map[string]interface{}{"count==2": false}, summary.Metrics["pages{script:checkout}"]["thresholds"])
+}
+
+func TestRunSuiteErrors(t *testing.T) {
+	t.Parallel()
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.js")
+	require.NoError(t, ioutil.WriteFile(script, []byte(`
+		export const options = { scenarios: { s: { executor: "per-vu-iterations", exec: "missing" } } };
+		export default function () {}
+	`), 0o600))
+	other := filepath.Join(dir, "other.js")
+	require.NoError(t, ioutil.WriteFile(other, []byte(`export default function () {}`), 0o600))
+
+	for args, expErr := range map[[2]string]string{
+		{script, other}: `the exec function "missing" of the scenario "s" isn't exported by ` + script,
+		{other, "-"}:    "the script can't be read from stdin when running multiple scripts",
+	} {
+		cmd := getRunCmd(context.Background(), testutils.NewLogger(t), newCommandFlags())
+		cmd.SetArgs([]string{"--no-usage-report", args[0], args[1]})
+		err := cmd.Execute()
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), expErr)
+	}
+}
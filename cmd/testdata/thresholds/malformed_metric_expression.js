@@ -0,0 +1,12 @@
+import { Trend } from "k6/metrics";
+
+const loginTime = new Trend("login_time", true, {
+	thresholds: ["p(95)<<800"],
+});
+
+export default function () {
+	loginTime.add(100);
+	console.log(
+		"asserting that a malformed threshold of a custom metric fails with exit code 104 (Invalid config)"
+	);
+}
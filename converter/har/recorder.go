@@ -0,0 +1,246 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/lib/consts"
+)
+
+// hopHeaders are the hop-by-hop headers, which are meant for the proxy and not
+// forwarded nor recorded.
+//nolint:gochecknoglobals
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization", "Proxy-Connection",
+	"Te", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+// Recorder is an HTTP proxy which records the requests going through it, and
+// their responses, as the entries of a HAR. A page is started by every
+// request for an HTML document, and the requests which follow it are the
+// resources of that page. HTTPS requests are tunneled without being recorded,
+// since their content can't be seen.
+type Recorder struct {
+	transport http.RoundTripper
+	now       func() time.Time
+
+	mu      sync.Mutex
+	pages   []Page
+	entries []*Entry
+}
+
+// NewRecorder returns a Recorder which sends the requests with transport.
+func NewRecorder(transport http.RoundTripper) *Recorder {
+	return &Recorder{transport: transport, now: time.Now}
+}
+
+// HAR returns the pages and the entries recorded so far.
+func (r *Recorder) HAR() HAR {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return HAR{Log: &Log{
+		Version: "1.2",
+		Creator: &Creator{Name: "k6 record", Version: consts.Version},
+		Pages:   append([]Page{}, r.pages...),
+		Entries: append([]*Entry{}, r.entries...),
+	}}
+}
+
+// isPageRequest returns true if req is the navigation of a browser to a page.
+func isPageRequest(req *http.Request) bool {
+	return req.Method == http.MethodGet && strings.Contains(req.Header.Get("Accept"), "text/html")
+}
+
+// pageref returns the ID of the page of req, which started at started.
+func (r *Recorder) pageref(req *http.Request, started time.Time) string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if len(r.pages) == 0 || isPageRequest(req) {
+		r.pages = append(r.pages, Page{
+			StartedDateTime: started,
+			ID:              fmt.Sprintf("page_%d", len(r.pages)+1),
+			Title:           req.URL.String(),
+		})
+	}
+	return r.pages[len(r.pages)-1].ID
+}
+
+func (r *Recorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method == http.MethodConnect {
+		r.tunnel(w, req)
+		return
+	}
+	if !req.URL.IsAbs() {
+		http.Error(w, "this is a recording proxy, configure it as the HTTP proxy of the browser", http.StatusBadRequest)
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out := req.Clone(req.Context())
+	out.RequestURI = ""
+	out.Body = ioutil.NopCloser(bytes.NewReader(body))
+	removeHopHeaders(out.Header)
+
+	started := r.now()
+	pageref := r.pageref(req, started)
+	resp, err := r.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer func() { _ = resp.Body.Close() }()
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	elapsed := r.now().Sub(started)
+
+	removeHopHeaders(resp.Header)
+	for name, values := range resp.Header {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(respBody)
+
+	entry := &Entry{
+		Pageref:         pageref,
+		StartedDateTime: started,
+		Time:            float32(elapsed.Seconds() * 1000),
+		Request:         recordRequest(out, body),
+		Response:        recordResponse(resp, respBody),
+		Cache:           &Cache{},
+		Timings:         &Timings{Wait: float32(elapsed.Seconds() * 1000)},
+	}
+	r.mu.Lock()
+	r.entries = append(r.entries, entry)
+	r.mu.Unlock()
+}
+
+// tunnel relays the connection of a CONNECT request to its host.
+func (r *Recorder) tunnel(w http.ResponseWriter, req *http.Request) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "tunneling isn't supported", http.StatusInternalServerError)
+		return
+	}
+	upstream, err := net.DialTimeout("tcp", req.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		_ = upstream.Close()
+		return
+	}
+	go relay(conn, upstream)
+	go relay(upstream, conn)
+}
+
+func relay(dst io.WriteCloser, src io.ReadCloser) {
+	defer func() {
+		_ = dst.Close()
+		_ = src.Close()
+	}()
+	_, _ = io.Copy(dst, src)
+}
+
+func removeHopHeaders(header http.Header) {
+	for _, name := range hopHeaders {
+		header.Del(name)
+	}
+}
+
+func recordHeaders(header http.Header) []Header {
+	names := make([]string, 0, len(header))
+	for name := range header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var headers []Header
+	for _, name := range names {
+		for _, value := range header[name] {
+			headers = append(headers, Header{Name: name, Value: value})
+		}
+	}
+	return headers
+}
+
+func recordRequest(req *http.Request, body []byte) *Request {
+	request := &Request{
+		Method:      req.Method,
+		URL:         req.URL.String(),
+		HTTPVersion: req.Proto,
+		Headers:     recordHeaders(req.Header),
+		BodySize:    int64(len(body)),
+	}
+	for _, c := range req.Cookies() {
+		request.Cookies = append(request.Cookies, Cookie{Name: c.Name, Value: c.Value})
+	}
+	for _, h := range recordHeaders(http.Header(req.URL.Query())) {
+		request.QueryString = append(request.QueryString, QueryString{Name: h.Name, Value: h.Value})
+	}
+	if len(body) > 0 {
+		request.PostData = &PostData{MimeType: req.Header.Get("Content-Type"), Text: string(body)}
+	}
+	return request
+}
+
+func recordResponse(resp *http.Response, body []byte) *Response {
+	mimeType := resp.Header.Get("Content-Type")
+	content := &Content{Size: int64(len(body)), MimeType: mimeType}
+	// Only the uncompressed textual responses are kept, for the correlation of
+	// JSON values.
+	textual := strings.Contains(mimeType, "json") || strings.HasPrefix(mimeType, "text/")
+	if textual && resp.Header.Get("Content-Encoding") == "" {
+		content.Text = string(body)
+	}
+	return &Response{
+		Status:      resp.StatusCode,
+		StatusText:  http.StatusText(resp.StatusCode),
+		HTTPVersion: resp.Proto,
+		Headers:     recordHeaders(resp.Header),
+		Content:     content,
+		RedirectURL: resp.Header.Get("Location"),
+		BodySize:    int64(len(body)),
+	}
+}
@@ -0,0 +1,122 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package har
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+)
+
+// newTestRecorder returns a recorder with a clock which advances by 100ms
+// every time it's read, and a client which uses it as its proxy.
+func newTestRecorder(t *testing.T) (*Recorder, *http.Client) {
+	t.Helper()
+	recorder := NewRecorder(http.DefaultTransport)
+	var mu sync.Mutex
+	now := time.Date(2021, 10, 1, 12, 0, 0, 0, time.UTC)
+	recorder.now = func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		now = now.Add(100 * time.Millisecond)
+		return now
+	}
+	proxy := httptest.NewServer(recorder)
+	t.Cleanup(proxy.Close)
+	proxyURL, err := url.Parse(proxy.URL)
+	require.NoError(t, err)
+	client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+	return recorder, client
+}
+
+func TestRecorder(t *testing.T) {
+	t.Parallel()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"path": "`+r.URL.Path+`", "body": "`+string(body)+`"}`)
+	}))
+	defer upstream.Close()
+	recorder, client := newTestRecorder(t)
+
+	do := func(method, path, accept, body string) {
+		req, err := http.NewRequest(method, upstream.URL+path, strings.NewReader(body)) //nolint:noctx
+		require.NoError(t, err)
+		req.Header.Set("Accept", accept)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		respBody, err := ioutil.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.NoError(t, resp.Body.Close())
+		assert.Equal(t, `{"path": "`+path+`", "body": "`+body+`"}`, string(respBody))
+	}
+	do(http.MethodGet, "/", "text/html,application/xhtml+xml", "")
+	do(http.MethodGet, "/style.css", "text/css", "")
+	do(http.MethodPost, "/login", "application/json", "user=admin")
+	do(http.MethodGet, "/home", "text/html", "")
+
+	h := recorder.HAR()
+	require.Len(t, h.Log.Pages, 2)
+	assert.Equal(t, upstream.URL+"/", h.Log.Pages[0].Title)
+	assert.Equal(t, upstream.URL+"/home", h.Log.Pages[1].Title)
+	require.Len(t, h.Log.Entries, 4)
+	for i, pageref := range []string{"page_1", "page_1", "page_1", "page_2"} {
+		assert.Equal(t, pageref, h.Log.Entries[i].Pageref)
+	}
+	login := h.Log.Entries[2]
+	assert.Equal(t, http.MethodPost, login.Request.Method)
+	require.NotNil(t, login.Request.PostData)
+	assert.Equal(t, "user=admin", login.Request.PostData.Text)
+	assert.Equal(t, http.StatusOK, login.Response.Status)
+	assert.Equal(t, `{"path": "/login", "body": "user=admin"}`, login.Response.Content.Text)
+	for _, header := range login.Request.Headers {
+		assert.NotEqual(t, "Proxy-Connection", header.Name)
+	}
+
+	script, err := Convert(h, lib.Options{}, 1, 2, false, false, 500, false, false, nil, nil)
+	require.NoError(t, err)
+	assert.Contains(t, script, `group("page_1 - `+upstream.URL+`/", function() {`)
+	assert.Contains(t, script, `group("page_2 - `+upstream.URL+`/home", function() {`)
+	assert.Contains(t, script, "sleep(0.20);")
+	assert.Contains(t, script, `"url": "`+upstream.URL+`/login"`)
+}
+
+func TestRecorderNotProxied(t *testing.T) {
+	t.Parallel()
+	proxy := httptest.NewServer(NewRecorder(http.DefaultTransport))
+	defer proxy.Close()
+
+	resp, err := http.Get(proxy.URL) //nolint:noctx
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
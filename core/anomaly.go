@@ -0,0 +1,258 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// The kinds of the anomalies the anomalyDetector detects.
+const (
+	anomalyErrorRateSpike     = "error-rate-spike"
+	anomalyLatencyStep        = "latency-step"
+	anomalyThroughputCollapse = "throughput-collapse"
+)
+
+const (
+	// anomalyBaselineIntervals is how many of the last intervals an interval
+	// is compared with, and anomalyMinBaselineIntervals is how many there
+	// should be before it is.
+	anomalyBaselineIntervals    = 5
+	anomalyMinBaselineIntervals = 3
+
+	// anomalyMinRequests is the fewest requests in an interval, or on average
+	// in the baseline for a throughput collapse, it's compared with.
+	anomalyMinRequests = 10
+
+	// anomalyFactor is how many times the error rate or the latency should be
+	// over their baseline, or the throughput under it, to be an anomaly.
+	anomalyFactor = 2.0
+
+	// anomalyMinErrorRateIncrease is how much more the error rate should be
+	// than its baseline besides, so a few more errors aren't a spike.
+	anomalyMinErrorRateIncrease = 0.1
+
+	// anomalyLateSampleGrace is how long after its end an interval is still
+	// open to the samples which arrive late, out of order with the others.
+	anomalyLateSampleGrace = time.Second
+)
+
+// anomalyInterval has the requests of a single interval.
+type anomalyInterval struct {
+	start            int64
+	reqs, failed     float64
+	durationSum      float64
+	durationsCounted float64
+}
+
+// anomalyDetector compares the error rate, the average duration and the
+// number of the requests of every interval of the anomalyDetectionInterval
+// option with the last ones, and returns the events of the anomalies in them.
+// An anomaly which lasts over consecutive intervals is returned only for the
+// first one. The times of the samples of the requests are its only clock: an
+// interval is compared once a sample arrives anomalyLateSampleGrace after its
+// end, so the last intervals of the test aren't, as they may not be complete.
+type anomalyDetector struct {
+	interval time.Duration
+	started  bool
+	// next is the start of the first interval which isn't compared yet, and
+	// open are the intervals from it to the one of the last sample
+	next    int64
+	open    []anomalyInterval
+	history []anomalyInterval
+	// ongoing are the kinds of the anomalies of the last interval
+	ongoing map[string]bool
+	events  []lib.Event
+}
+
+// newAnomalyDetector returns nil if the anomalyDetectionInterval option is 0.
+func newAnomalyDetector(opts lib.Options) *anomalyDetector {
+	interval := time.Duration(opts.AnomalyDetectionInterval.Duration)
+	if interval <= 0 {
+		return nil
+	}
+	return &anomalyDetector{interval: interval}
+}
+
+// add adds the samples of the requests to their interval, and returns the
+// events of the anomalies of the intervals which ended anomalyLateSampleGrace
+// before them. The samples of the other metrics are ignored, and the ones of
+// the intervals which were already compared are left out.
+func (d *anomalyDetector) add(sampleContainers []stats.SampleContainer) []lib.Event {
+	var events []lib.Event
+	for _, sc := range sampleContainers {
+		for _, sample := range sc.GetSamples() {
+			name := sample.Metric.Name
+			if sample.Time.IsZero() ||
+				(name != metrics.HTTPReqsName && name != metrics.HTTPReqFailedName && name != metrics.HTTPReqDurationName) {
+				continue
+			}
+			t := sample.Time.UnixNano()
+			if !d.started {
+				d.started = true
+				d.next = sample.Time.Truncate(d.interval).UnixNano()
+			}
+			events = append(events, d.endBefore(t-int64(anomalyLateSampleGrace))...)
+			if t < d.next {
+				continue
+			}
+			i := int((t - d.next) / int64(d.interval))
+			for len(d.open) <= i {
+				d.open = append(d.open, anomalyInterval{start: d.next + int64(len(d.open))*int64(d.interval)})
+			}
+			interval := &d.open[i]
+			switch name {
+			case metrics.HTTPReqsName:
+				interval.reqs += sample.Value
+			case metrics.HTTPReqFailedName:
+				if sample.Value != 0 {
+					interval.failed++
+				}
+			case metrics.HTTPReqDurationName:
+				interval.durationSum += sample.Value
+				interval.durationsCounted++
+			}
+		}
+	}
+	d.events = append(d.events, events...)
+	return events
+}
+
+// endBefore ends the intervals which ended before the time t. After more
+// intervals without requests than the history has, ending the next ones
+// doesn't change anything, so it jumps straight to the one of t instead.
+func (d *anomalyDetector) endBefore(t int64) []lib.Event {
+	interval := int64(d.interval)
+	var events []lib.Event
+	for empty := 0; d.next+interval <= t; {
+		current := anomalyInterval{start: d.next}
+		if len(d.open) > 0 {
+			current, d.open = d.open[0], d.open[1:]
+		}
+		if current.reqs == 0 && current.durationsCounted == 0 {
+			empty++
+		} else {
+			empty = 0
+		}
+		events = append(events, d.end(current)...)
+		d.next += interval
+		if empty > anomalyBaselineIntervals && len(d.open) == 0 {
+			d.next += (t - d.next) / interval * interval
+		}
+	}
+	return events
+}
+
+// end compares the interval with the baseline, and keeps it in the history.
+func (d *anomalyDetector) end(current anomalyInterval) []lib.Event {
+	var events []lib.Event
+	if len(d.history) >= anomalyMinBaselineIntervals {
+		ongoing := make(map[string]bool)
+		for _, a := range d.detect(current) {
+			if !d.ongoing[a.kind] {
+				events = append(events, d.newEvent(current, a))
+			}
+			ongoing[a.kind] = true
+		}
+		d.ongoing = ongoing
+	}
+
+	d.history = append(d.history, current)
+	if len(d.history) > anomalyBaselineIntervals {
+		d.history = d.history[1:]
+	}
+	return events
+}
+
+// anomaly is an anomaly of an interval, with the value of its metric in the
+// interval and in the baseline.
+type anomaly struct {
+	kind, metric    string
+	value, baseline float64
+}
+
+// detect returns the anomalies of the interval, compared with the history.
+func (d *anomalyDetector) detect(current anomalyInterval) []anomaly {
+	var baseline anomalyInterval
+	for _, h := range d.history {
+		baseline.reqs += h.reqs
+		baseline.failed += h.failed
+		baseline.durationSum += h.durationSum
+		baseline.durationsCounted += h.durationsCounted
+	}
+
+	var anomalies []anomaly
+	check := func(kind, metric string, isAnomaly bool, value, base float64) {
+		if isAnomaly {
+			anomalies = append(anomalies, anomaly{kind: kind, metric: metric, value: value, baseline: base})
+		}
+	}
+
+	if current.reqs >= anomalyMinRequests && baseline.reqs > 0 {
+		rate, baseRate := current.failed/current.reqs, baseline.failed/baseline.reqs
+		check(anomalyErrorRateSpike, metrics.HTTPReqFailedName,
+			rate >= baseRate*anomalyFactor && rate-baseRate >= anomalyMinErrorRateIncrease, rate, baseRate)
+	}
+	if current.durationsCounted >= anomalyMinRequests && baseline.durationSum > 0 {
+		avg := current.durationSum / current.durationsCounted
+		baseAvg := baseline.durationSum / baseline.durationsCounted
+		check(anomalyLatencyStep, metrics.HTTPReqDurationName, avg >= baseAvg*anomalyFactor, avg, baseAvg)
+	}
+	baseReqs := baseline.reqs / float64(len(d.history))
+	if baseReqs >= anomalyMinRequests {
+		check(anomalyThroughputCollapse, metrics.HTTPReqsName,
+			current.reqs <= baseReqs/anomalyFactor, current.reqs, baseReqs)
+	}
+	return anomalies
+}
+
+func (d *anomalyDetector) newEvent(interval anomalyInterval, a anomaly) lib.Event {
+	var description string
+	switch a.kind {
+	case anomalyErrorRateSpike:
+		description = fmt.Sprintf("The error rate of the requests spiked to %.2f%%, from %.2f%%",
+			a.value*100, a.baseline*100)
+	case anomalyLatencyStep:
+		description = fmt.Sprintf("The average duration of the requests stepped up to %.2fms, from %.2fms",
+			a.value, a.baseline)
+	case anomalyThroughputCollapse:
+		description = fmt.Sprintf("The requests collapsed to %.0f, from %.1f on average, per %s",
+			a.value, a.baseline, d.interval)
+	}
+	start := time.Unix(0, interval.start)
+	return lib.Event{
+		Type: lib.EventAnomalyDetected,
+		Time: start,
+		Data: map[string]interface{}{
+			"kind":        a.kind,
+			"metric":      a.metric,
+			"value":       a.value,
+			"baseline":    a.baseline,
+			"end":         start.Add(d.interval),
+			"description": description,
+		},
+	}
+}
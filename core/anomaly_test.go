@@ -0,0 +1,156 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func TestAnomalyDetector(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newAnomalyDetector(lib.Options{}))
+
+	d := newAnomalyDetector(lib.Options{AnomalyDetectionInterval: types.NullDurationFrom(10 * time.Second)})
+	require.NotNil(t, d)
+	reqs := stats.New(metrics.HTTPReqsName, stats.Counter)
+	failed := stats.New(metrics.HTTPReqFailedName, stats.Rate)
+	duration := stats.New(metrics.HTTPReqDurationName, stats.Trend, stats.Time)
+	vus := stats.New(metrics.VUsName, stats.Gauge)
+
+	start := time.Unix(1600000000, 0)
+	requests := func(interval, n, failures int, value float64) []stats.SampleContainer {
+		var samples []stats.SampleContainer
+		for i := 0; i < n; i++ {
+			now := start.Add(time.Duration(interval)*10*time.Second + time.Duration(i)*time.Millisecond)
+			isFailed := 0.0
+			if i < failures {
+				isFailed = 1
+			}
+			samples = append(samples, stats.ConnectedSamples{Samples: []stats.Sample{
+				{Metric: reqs, Time: now, Value: 1},
+				{Metric: failed, Time: now, Value: isFailed},
+				{Metric: duration, Time: now, Value: value},
+			}})
+		}
+		return samples
+	}
+
+	var events []lib.Event
+	for i := 0; i < 4; i++ {
+		events = append(events, d.add(requests(i, 20, 1, 100))...)
+	}
+	assert.Empty(t, events)
+	events = append(events, d.add(requests(4, 20, 10, 100))...)
+	events = append(events, d.add(requests(5, 20, 10, 100))...)
+	events = append(events, d.add(requests(6, 20, 1, 300))...)
+	events = append(events, d.add(requests(7, 2, 0, 300))...)
+	// only the samples of the requests end the intervals
+	assert.Empty(t, d.add([]stats.SampleContainer{
+		stats.Sample{Metric: vus, Time: start.Add(90 * time.Second), Value: 1},
+	}))
+	events = append(events, d.add(requests(9, 1, 0, 100))...)
+
+	require.Len(t, events, 3, "the error rate spike of the interval 5 continued the one of the interval 4")
+	assert.Equal(t, events, d.events)
+
+	assert.Equal(t, lib.EventAnomalyDetected, events[0].Type)
+	assert.Equal(t, start.Add(40*time.Second), events[0].Time)
+	assert.Equal(t, anomalyErrorRateSpike, events[0].Data["kind"])
+	assert.Equal(t, metrics.HTTPReqFailedName, events[0].Data["metric"])
+	assert.Equal(t, 0.5, events[0].Data["value"])
+	assert.Equal(t, 0.05, events[0].Data["baseline"])
+	assert.Equal(t, start.Add(50*time.Second), events[0].Data["end"])
+	assert.Equal(t, "The error rate of the requests spiked to 50.00%, from 5.00%", events[0].Data["description"])
+
+	assert.Equal(t, start.Add(60*time.Second), events[1].Time)
+	assert.Equal(t, anomalyLatencyStep, events[1].Data["kind"])
+	assert.Equal(t, 300.0, events[1].Data["value"])
+	assert.Equal(t, 100.0, events[1].Data["baseline"])
+
+	assert.Equal(t, start.Add(70*time.Second), events[2].Time)
+	assert.Equal(t, anomalyThroughputCollapse, events[2].Data["kind"])
+	assert.Equal(t, metrics.HTTPReqsName, events[2].Data["metric"])
+	assert.Equal(t, 2.0, events[2].Data["value"])
+	assert.Equal(t, "The requests collapsed to 2, from 20.0 on average, per 10s", events[2].Data["description"])
+}
+
+func TestAnomalyDetectorEmptyIntervals(t *testing.T) {
+	t.Parallel()
+	d := newAnomalyDetector(lib.Options{AnomalyDetectionInterval: types.NullDurationFrom(time.Second)})
+	reqs := stats.New(metrics.HTTPReqsName, stats.Counter)
+
+	start := time.Unix(1600000000, 0)
+	for i := 0; i < 5; i++ {
+		assert.Empty(t, d.add([]stats.SampleContainer{
+			stats.Sample{Metric: reqs, Time: start.Add(time.Duration(i) * time.Second), Value: 50},
+		}))
+	}
+	// the requests stopped for 3s, which is a single collapse
+	events := d.add([]stats.SampleContainer{stats.Sample{Metric: reqs, Time: start.Add(8 * time.Second), Value: 50}})
+	require.Len(t, events, 1)
+	assert.Equal(t, start.Add(5*time.Second), events[0].Time)
+	assert.Equal(t, 0.0, events[0].Data["value"])
+
+	// a late sample is added to its interval if it's still open, within the
+	// grace after its end, and left out otherwise
+	assert.Empty(t, d.add([]stats.SampleContainer{
+		stats.Sample{Metric: reqs, Time: start.Add(7500 * time.Millisecond), Value: 50},
+		stats.Sample{Metric: reqs, Time: start, Value: 50},
+	}))
+	require.Len(t, d.open, 2)
+	assert.Equal(t, start.Add(7*time.Second).UnixNano(), d.open[0].start)
+	assert.Equal(t, 50.0, d.open[0].reqs)
+	assert.Equal(t, 50.0, d.open[1].reqs)
+}
+
+func TestAnomalyDetectorJump(t *testing.T) {
+	t.Parallel()
+	d := newAnomalyDetector(lib.Options{AnomalyDetectionInterval: types.NullDurationFrom(time.Second)})
+	reqs := stats.New(metrics.HTTPReqsName, stats.Counter)
+
+	start := time.Unix(1600000000, 0)
+	for i := 0; i < 5; i++ {
+		assert.Empty(t, d.add([]stats.SampleContainer{
+			stats.Sample{Metric: reqs, Time: start.Add(time.Duration(i) * time.Second), Value: 50},
+		}))
+	}
+	// a sample far in the future doesn't end every interval until it
+	later := start.Add(1000 * time.Hour)
+	events := d.add([]stats.SampleContainer{stats.Sample{Metric: reqs, Time: later, Value: 50}})
+	require.Len(t, events, 1)
+	assert.Equal(t, anomalyThroughputCollapse, events[0].Data["kind"])
+	assert.Equal(t, later.Add(-time.Second).UnixNano(), d.next)
+	require.Len(t, d.open, 2)
+	assert.Equal(t, later.UnixNano(), d.open[1].start)
+	assert.Len(t, d.history, anomalyBaselineIntervals)
+	for _, h := range d.history {
+		assert.Zero(t, h.reqs)
+	}
+}
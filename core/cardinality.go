@@ -0,0 +1,156 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+// overflowTagValue replaces the tag values of the samples of the metrics
+// with too many distinct combinations of them.
+const overflowTagValue = "__overflow__"
+
+// tagCombinations are the distinct combinations of tag values of a metric.
+type tagCombinations struct {
+	// seen are the keys of the combinations, up to the limit
+	seen       map[string]bool
+	overflowed bool
+}
+
+// tagCardinalityGuard bounds the distinct combinations of tag values of every
+// metric to the maxTagCombinations option, so a tag with unbounded values,
+// like a user ID, doesn't make the memory of the engine and the outputs grow
+// with every sample. Past the limit, all of the values of the combinations
+// which aren't in the seen ones are replaced with overflowTagValue, so there
+// are at most the limit and one distinct combinations, even if every value of
+// a new combination is in one of the seen ones.
+type tagCardinalityGuard struct {
+	limit   int
+	logger  logrus.FieldLogger
+	metrics map[string]*tagCombinations
+}
+
+func newTagCardinalityGuard(opts lib.Options, logger logrus.FieldLogger) *tagCardinalityGuard {
+	if opts.MaxTagCombinations.Int64 <= 0 {
+		return nil
+	}
+	return &tagCardinalityGuard{
+		limit:   int(opts.MaxTagCombinations.Int64),
+		logger:  logger,
+		metrics: make(map[string]*tagCombinations),
+	}
+}
+
+// tagCombinationKey returns a key which is the same for the equal tag sets.
+func tagCombinationKey(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte('=')
+		b.WriteString(tags[k])
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// fold returns the tags of a sample of the metric, with the values replaced
+// with overflowTagValue if the metric reached the limit and they aren't one of
+// its seen combinations.
+func (g *tagCardinalityGuard) fold(metric string, tags *stats.SampleTags) *stats.SampleTags {
+	combinations, ok := g.metrics[metric]
+	if !ok {
+		combinations = &tagCombinations{seen: make(map[string]bool)}
+		g.metrics[metric] = combinations
+	}
+	values := tags.CloneTags()
+	key := tagCombinationKey(values)
+	if combinations.seen[key] {
+		return tags
+	}
+	if len(combinations.seen) < g.limit {
+		combinations.seen[key] = true
+		return tags
+	}
+
+	for k := range values {
+		values[k] = overflowTagValue
+	}
+	if !combinations.overflowed {
+		combinations.overflowed = true
+		g.logger.WithField("metric", metric).Warnf(
+			"The metric '%s' has more than %d distinct combinations of tag values, the values of the new ones "+
+				"are replaced with '%s'; avoid tagging it with unbounded values, like IDs, or shorten them "+
+				"with the tagTransforms option", metric, g.limit, overflowTagValue)
+	}
+	return stats.IntoSampleTags(&values)
+}
+
+// apply folds the tags of all of the given samples, in place, like
+// transformSampleTags.
+func (g *tagCardinalityGuard) apply(sampleContainers []stats.SampleContainer) {
+	// Samples in a container usually share the same tags, so cache the result
+	type cacheKey struct {
+		metric string
+		tags   *stats.SampleTags
+	}
+	cache := make(map[cacheKey]*stats.SampleTags)
+	fold := func(sample stats.Sample) *stats.SampleTags {
+		key := cacheKey{metric: sample.Metric.Name, tags: sample.Tags}
+		if folded, ok := cache[key]; ok {
+			return folded
+		}
+		folded := g.fold(sample.Metric.Name, sample.Tags)
+		cache[key] = folded
+		return folded
+	}
+
+	for i, sc := range sampleContainers {
+		switch container := sc.(type) {
+		case stats.Sample:
+			container.Tags = fold(container)
+			sampleContainers[i] = container
+		case stats.ConnectedSamples:
+			for j := range container.Samples {
+				container.Samples[j].Tags = fold(container.Samples[j])
+			}
+			if len(container.Samples) > 0 {
+				container.Tags = container.Samples[0].Tags
+			}
+			sampleContainers[i] = container
+		default:
+			samples := container.GetSamples()
+			for j := range samples {
+				samples[j].Tags = fold(samples[j])
+			}
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+func TestTagCardinalityGuard(t *testing.T) {
+	t.Parallel()
+	logger, hook := test.NewNullLogger()
+	assert.Nil(t, newTagCardinalityGuard(lib.Options{}, logger))
+
+	g := newTagCardinalityGuard(lib.Options{MaxTagCombinations: null.IntFrom(3)}, logger)
+	require.NotNil(t, g)
+	reqs := stats.New("http_reqs", stats.Counter)
+	other := stats.New("other", stats.Counter)
+	sample := func(metric *stats.Metric, user, method string) stats.Sample {
+		return stats.Sample{
+			Metric: metric, Value: 1,
+			Tags: stats.NewSampleTags(map[string]string{"user": user, "method": method}),
+		}
+	}
+
+	samples := []stats.SampleContainer{
+		sample(reqs, "1", "GET"), sample(reqs, "2", "GET"), sample(reqs, "3", "POST"),
+		sample(reqs, "1", "GET"), sample(reqs, "4", "GET"), sample(reqs, "5", "PUT"),
+		sample(reqs, "1", "POST"), sample(other, "4", "GET"),
+	}
+	g.apply(samples)
+	users := make([]string, len(samples))
+	methods := make([]string, len(samples))
+	for i, sc := range samples {
+		sample := sc.(stats.Sample)
+		users[i], _ = sample.Tags.Get("user")
+		methods[i], _ = sample.Tags.Get("method")
+	}
+	o := overflowTagValue
+	assert.Equal(t, []string{"1", "2", "3", "1", o, o, o, "4"}, users)
+	assert.Equal(t, []string{"GET", "GET", "POST", "GET", o, o, o, "GET"}, methods,
+		"the seen combinations are kept, and the other metrics have their own limit")
+
+	entries := hook.AllEntries()
+	require.Len(t, entries, 1, "the warning is logged once per metric")
+	assert.Equal(t, logrus.WarnLevel, entries[0].Level)
+	assert.Contains(t, entries[0].Message, "The metric 'http_reqs' has more than 3 distinct combinations")
+}
+
+func TestTagCardinalityGuardCrossProduct(t *testing.T) {
+	t.Parallel()
+	logger, _ := test.NewNullLogger()
+	g := newTagCardinalityGuard(lib.Options{MaxTagCombinations: null.IntFrom(4)}, logger)
+
+	// the 4 seen combinations have 4 values of every tag, and 16 combinations of them
+	series := make(map[string]bool)
+	for _, pass := range []bool{true, false} {
+		for i := 0; i < 4; i++ {
+			for j := 0; j < 4; j++ {
+				if pass && i != j {
+					continue
+				}
+				tags := stats.NewSampleTags(map[string]string{"a": strconv.Itoa(i), "b": strconv.Itoa(j)})
+				series[tagCombinationKey(g.fold("m", tags).CloneTags())] = true
+			}
+		}
+	}
+	assert.Len(t, series, 5, "the seen combinations and the folded one")
+	assert.Len(t, g.metrics["m"].seen, 4)
+}
+
+func TestTagCardinalityGuardConnectedSamples(t *testing.T) {
+	t.Parallel()
+	logger, _ := test.NewNullLogger()
+	g := newTagCardinalityGuard(lib.Options{MaxTagCombinations: null.IntFrom(1)}, logger)
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+
+	var samples []stats.SampleContainer
+	for i := 0; i < 3; i++ {
+		tags := stats.NewSampleTags(map[string]string{"url": fmt.Sprintf("http://test/%d", i)})
+		samples = append(samples, stats.ConnectedSamples{
+			Samples: []stats.Sample{{Metric: duration, Tags: tags}, {Metric: duration, Tags: tags}},
+			Tags:    tags,
+		})
+	}
+	g.apply(samples)
+	for i, sc := range samples {
+		container := sc.(stats.ConnectedSamples)
+		expected := fmt.Sprintf("http://test/%d", i)
+		if i > 0 {
+			expected = overflowTagValue
+		}
+		for _, sample := range container.Samples {
+			url, _ := sample.Tags.Get("url")
+			assert.Equal(t, expected, url)
+		}
+		url, _ := container.Tags.Get("url")
+		assert.Equal(t, expected, url)
+	}
+	assert.Len(t, g.metrics["http_req_duration"].seen, 1)
+}
@@ -0,0 +1,106 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// maxSyntheticSamples limits the samples back-filled for a single sample, so
+// a request which hung for minutes with a short interval doesn't add millions
+// of them to the sinks.
+const maxSyntheticSamples = 1000
+
+// correctedMetrics are the metrics which are back-filled: the latency of the
+// requests and the duration of the iterations. The other time Trend metrics,
+// like the phases of the requests and the custom ones, don't measure what the
+// delayed iterations would have waited for.
+var correctedMetrics = map[string]bool{ //nolint:gochecknoglobals
+	metrics.HTTPReqDurationName:   true,
+	metrics.IterationDurationName: true,
+}
+
+// coordinatedOmissionCorrector back-fills the http_req_duration and the
+// iteration_duration metrics of the closed-model scenarios with the
+// correctCoordinatedOmission option.
+//
+// A VU of a closed-model executor doesn't start its next iteration while the
+// current one is slow, so the measurements of the iterations which were
+// intended to start in the meantime are omitted, and the percentiles are too
+// optimistic. Like the expected interval correction of HdrHistogram, every
+// value longer than the interval is followed by the synthetic values the
+// delayed iterations would have measured: the value minus one interval, minus
+// two intervals and so on, while they are still at least one interval long.
+type coordinatedOmissionCorrector struct {
+	intervals map[string]time.Duration
+	// synthetic counts the back-filled samples of each metric and submetric.
+	synthetic map[string]int64
+}
+
+// newCoordinatedOmissionCorrector returns nil if none of the scenarios are
+// corrected.
+func newCoordinatedOmissionCorrector(opts lib.Options) *coordinatedOmissionCorrector {
+	c := &coordinatedOmissionCorrector{
+		intervals: make(map[string]time.Duration),
+		synthetic: make(map[string]int64),
+	}
+	for name, conf := range opts.Scenarios {
+		if interval := conf.GetCoordinatedOmissionInterval(); interval > 0 {
+			c.intervals[name] = interval
+		}
+	}
+	if len(c.intervals) == 0 {
+		return nil
+	}
+	return c
+}
+
+// backfill returns the synthetic samples for the sample, if it's of one of the
+// correctedMetrics in a corrected scenario.
+func (c *coordinatedOmissionCorrector) backfill(m *stats.Metric, sample stats.Sample) []stats.Sample {
+	if !correctedMetrics[m.Name] || sample.Tags == nil {
+		return nil
+	}
+	scenario, ok := sample.Tags.Get("scenario")
+	if !ok {
+		return nil
+	}
+	interval := stats.D(c.intervals[scenario])
+	if interval <= 0 || sample.Value < 2*interval {
+		return nil
+	}
+
+	var synthetic []stats.Sample
+	for value := sample.Value - interval; value >= interval && len(synthetic) < maxSyntheticSamples; value -= interval {
+		s := sample
+		s.Value = value
+		synthetic = append(synthetic, s)
+	}
+	return synthetic
+}
+
+func (c *coordinatedOmissionCorrector) count(name string, n int) {
+	c.synthetic[name] += int64(n)
+}
@@ -0,0 +1,123 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func newCoordinatedOmissionOptions(interval time.Duration) lib.Options {
+	corrected := executor.NewConstantVUsConfig("corrected")
+	corrected.CorrectCoordinatedOmission = types.NullDurationFrom(interval)
+	return lib.Options{Scenarios: lib.ScenarioConfigs{
+		"corrected":   corrected,
+		"uncorrected": executor.NewConstantVUsConfig("uncorrected"),
+	}}
+}
+
+func TestCoordinatedOmissionBackfill(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newCoordinatedOmissionCorrector(lib.Options{}))
+	assert.Nil(t, newCoordinatedOmissionCorrector(newCoordinatedOmissionOptions(0)))
+
+	c := newCoordinatedOmissionCorrector(newCoordinatedOmissionOptions(100 * time.Millisecond))
+	require.NotNil(t, c)
+	duration := stats.New(metrics.HTTPReqDurationName, stats.Trend, stats.Time)
+	sample := func(metric *stats.Metric, scenario string, value float64) stats.Sample {
+		return stats.Sample{
+			Metric: metric,
+			Tags:   stats.IntoSampleTags(&map[string]string{"scenario": scenario}),
+			Value:  value,
+		}
+	}
+	values := func(samples []stats.Sample) []float64 {
+		var result []float64
+		for _, s := range samples {
+			result = append(result, s.Value)
+		}
+		return result
+	}
+
+	assert.Equal(t, []float64{350, 250, 150}, values(c.backfill(duration, sample(duration, "corrected", 450))))
+	assert.Equal(t, []float64{100}, values(c.backfill(duration, sample(duration, "corrected", 200))))
+	assert.Empty(t, c.backfill(duration, sample(duration, "corrected", 199)))
+	assert.Empty(t, c.backfill(duration, sample(duration, "uncorrected", 450)))
+	assert.Len(t, c.backfill(duration, sample(duration, "corrected", 1e9)), maxSyntheticSamples)
+
+	iteration := stats.New(metrics.IterationDurationName, stats.Trend, stats.Time)
+	assert.Equal(t, []float64{350, 250, 150}, values(c.backfill(iteration, sample(iteration, "corrected", 450))))
+
+	// only the request and iteration durations are back-filled
+	waiting := stats.New(metrics.HTTPReqWaitingName, stats.Trend, stats.Time)
+	assert.Empty(t, c.backfill(waiting, sample(waiting, "corrected", 450)))
+	custom := stats.New("my_time", stats.Trend, stats.Time)
+	assert.Empty(t, c.backfill(custom, sample(custom, "corrected", 450)))
+	counter := stats.New("my_counter", stats.Counter)
+	assert.Empty(t, c.backfill(counter, sample(counter, "corrected", 450)))
+}
+
+func TestEngineCoordinatedOmissionCorrection(t *testing.T) {
+	t.Parallel()
+	parent, sm := stats.NewSubmetric(metrics.HTTPReqDurationName + "{status:200}")
+	e := &Engine{
+		Metrics:     make(map[string]*stats.Metric),
+		submetrics:  map[string][]*stats.Submetric{parent: {sm}},
+		coCorrector: newCoordinatedOmissionCorrector(newCoordinatedOmissionOptions(100 * time.Millisecond)),
+	}
+	assert.Empty(t, e.CoordinatedOmissionCorrections())
+
+	duration := stats.New(metrics.HTTPReqDurationName, stats.Trend, stats.Time)
+	e.processSamplesForMetrics([]stats.SampleContainer{
+		stats.Sample{
+			Metric: duration,
+			Tags:   stats.IntoSampleTags(&map[string]string{"scenario": "corrected", "status": "200"}),
+			Value:  300,
+		},
+		stats.Sample{
+			Metric: duration,
+			Tags:   stats.IntoSampleTags(&map[string]string{"scenario": "uncorrected", "status": "500"}),
+			Value:  300,
+		},
+	})
+
+	sink, ok := e.Metrics[metrics.HTTPReqDurationName].Sink.(*stats.TrendSink)
+	require.True(t, ok)
+	assert.Equal(t, uint64(4), sink.Count)
+	subSink, ok := e.Metrics[sm.Name].Sink.(*stats.TrendSink)
+	require.True(t, ok)
+	assert.Equal(t, uint64(3), subSink.Count)
+	assert.Equal(t, map[string]int64{
+		metrics.HTTPReqDurationName: 2,
+		sm.Name:                     2,
+	}, e.CoordinatedOmissionCorrections())
+
+	assert.Nil(t, (&Engine{}).CoordinatedOmissionCorrections())
+}
@@ -23,6 +23,7 @@ package core
 import (
 	"context"
 	"errors"
+	"fmt"
 	"strings"
 	"sync"
 	"time"
@@ -33,6 +34,7 @@ import (
 	"go.k6.io/k6/errext"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/output"
 	"go.k6.io/k6/stats"
@@ -77,12 +79,67 @@ type Engine struct {
 
 	// Are thresholds tainted?
 	thresholdsTainted bool
+
+	// Stops the test or single scenarios when too many requests fail, nil if
+	// there's no abortOnErrorRate option.
+	errorRateBreaker   *errorRateBreaker
+	errorRateAbortChan chan struct{}
+
+	// Stops the scenarios with too many failed checks or requests, nil if
+	// there aren't any with the stopOnFailures option.
+	failureLimiter *failureLimiter
+
+	// Back-fills the Trend metrics of the scenarios with the
+	// correctCoordinatedOmission option, nil if there aren't any.
+	coCorrector *coordinatedOmissionCorrector
+
+	// Aggregates the key metrics over the intervals of the
+	// summaryTimelineInterval option, nil if it's 0.
+	timeline *timeline
+
+	// Folds the tag values of the metrics with more distinct combinations
+	// than the maxTagCombinations option, nil if it's 0.
+	tagGuard *tagCardinalityGuard
+
+	// Detects the anomalies in the requests over the intervals of the
+	// anomalyDetectionInterval option, nil if it's 0.
+	anomalies *anomalyDetector
+
+	// The metrics of the test run, which the names of the metrics the
+	// scripts read the values of are looked up in.
+	registry *metrics.Registry
+	// The values of the metrics the scripts read, which are cached until
+	// metricValuesExpiry, for an interval of the thresholds evaluation.
+	metricValues       map[metricValueKey]float64
+	metricValuesExpiry time.Time
+
+	// The metrics which were reset, by the scenario they were reset in, as
+	// all of its VUs reset them but only the first one should.
+	resets map[metricResetKey]*appliedMetricReset
+}
+
+// metricResetKey is the name of a metric and the scenario it was reset in.
+type metricResetKey struct {
+	name, scenario string
+}
+
+// appliedMetricReset is the VU which reset a metric in a scenario, and whether
+// it was warned that resetting it again is ignored.
+type appliedMetricReset struct {
+	vu     uint64
+	warned bool
+}
+
+// metricValueKey is the name of a metric and the aggregation method of one of
+// its values.
+type metricValueKey struct {
+	name, method string
 }
 
 // NewEngine instantiates a new Engine, without doing any heavy initialization.
 func NewEngine(
 	ex lib.ExecutionScheduler, opts lib.Options, rtOpts lib.RuntimeOptions, outputs []output.Output, logger *logrus.Logger,
-	builtinMetrics *metrics.BuiltinMetrics,
+	registry *metrics.Registry, builtinMetrics *metrics.BuiltinMetrics,
 ) (*Engine, error) {
 	if ex == nil {
 		return nil, errors.New("missing ExecutionScheduler instance")
@@ -99,8 +156,19 @@ func NewEngine(
 		Samples:        make(chan stats.SampleContainer, opts.MetricSamplesBufferSize.Int64),
 		stopChan:       make(chan struct{}),
 		logger:         logger.WithField("component", "engine"),
+		registry:       registry,
 		builtinMetrics: builtinMetrics,
+
+		errorRateBreaker:   newErrorRateBreaker(opts),
+		errorRateAbortChan: make(chan struct{}),
+		failureLimiter:     newFailureLimiter(opts),
+
+		coCorrector: newCoordinatedOmissionCorrector(opts),
+		timeline:    newTimeline(opts),
+		anomalies:   newAnomalyDetector(opts),
 	}
+	e.tagGuard = newTagCardinalityGuard(opts, e.logger)
+	e.executionState.Metrics = e
 
 	e.thresholds = opts.Thresholds
 	e.submetrics = make(map[string][]*stats.Submetric)
@@ -127,6 +195,31 @@ func NewEngine(
 		}
 	}
 
+	// The per-class request counts are only tracked when the status_class
+	// system tag is enabled, and they're only shown in the summary for the
+	// classes that were actually seen, since submetrics without any samples
+	// never get added to the engine's metrics.
+	if opts.SystemTags.Has(stats.TagStatusClass) {
+		for _, class := range []string{"1xx", "2xx", "3xx", "4xx", "5xx"} {
+			name := "http_reqs{status_class:" + class + "}"
+			if _, ok := e.thresholds[name]; ok {
+				continue
+			}
+			parent, sm := stats.NewSubmetric(name)
+			e.submetrics[parent] = append(e.submetrics[parent], sm)
+		}
+	}
+
+	// The latency of the requests cut short by the interruption of their
+	// iterations is shown separately in the summary, if there were any.
+	if opts.SystemTags.Has(stats.TagInterrupted) {
+		name := "http_req_duration{interrupted:true}"
+		if _, ok := e.thresholds[name]; !ok {
+			parent, sm := stats.NewSubmetric(name)
+			e.submetrics[parent] = append(e.submetrics[parent], sm)
+		}
+	}
+
 	return e, nil
 }
 
@@ -278,13 +371,21 @@ func (e *Engine) startBackgroundProcesses(
 			}
 		case <-runCtx.Done():
 			e.logger.Debug("run: context expired; exiting...")
+			e.emitAbortInitiated("interrupted")
 			e.setRunStatus(lib.RunStatusAbortedUser)
 		case <-e.stopChan:
+			e.emitAbortInitiated("stopped")
 			runSubCancel()
 			e.logger.Debug("run: stopped by user; exiting...")
 			e.setRunStatus(lib.RunStatusAbortedUser)
 		case <-thresholdAbortChan:
 			e.logger.Debug("run: stopped by thresholds; exiting...")
+			e.emitAbortInitiated("thresholds")
+			runSubCancel()
+			e.setRunStatus(lib.RunStatusAbortedThreshold)
+		case <-e.errorRateAbortChan:
+			e.logger.Debug("run: stopped by the error rate limit; exiting...")
+			e.emitAbortInitiated("error-rate")
 			runSubCancel()
 			e.setRunStatus(lib.RunStatusAbortedThreshold)
 		}
@@ -296,7 +397,19 @@ func (e *Engine) startBackgroundProcesses(
 		go func() {
 			defer processes.Done()
 			defer e.logger.Debug("Engine: Thresholds terminated")
-			ticker := time.NewTicker(thresholdsRate)
+			interval := e.thresholdsInterval()
+			if e.Options.ThresholdsEvaluationDelay.Valid {
+				select {
+				case <-time.After(e.Options.ThresholdsEvaluationDelay.TimeDuration()):
+				case <-runCtx.Done():
+					return
+				}
+				if e.processThresholds() {
+					close(thresholdAbortChan)
+					return
+				}
+			}
+			ticker := time.NewTicker(interval)
 			defer ticker.Stop()
 
 			for {
@@ -316,6 +429,12 @@ func (e *Engine) startBackgroundProcesses(
 	return processes.Wait
 }
 
+// emitAbortInitiated emits the event for a test which is being stopped early,
+// for the given reason.
+func (e *Engine) emitAbortInitiated(reason string) {
+	e.executionState.Events.Emit(lib.EventAbortInitiated, map[string]interface{}{"reason": reason})
+}
+
 func (e *Engine) processMetrics(globalCtx context.Context, processMetricsAfterRun chan struct{}) {
 	sampleContainers := []stats.SampleContainer{}
 
@@ -453,10 +572,11 @@ func (e *Engine) processThresholds() (shouldAbort bool) {
 		if len(m.Thresholds.Thresholds) == 0 {
 			continue
 		}
+		wasTainted := m.Tainted.Bool
 		m.Tainted = null.BoolFrom(false)
 
 		e.logger.WithField("m", m.Name).Debug("running thresholds")
-		succ, err := m.Thresholds.Run(m.Sink, t)
+		succ, err := m.Thresholds.RunWithMetrics(m.Sink, t, e.Metrics)
 		if err != nil {
 			e.logger.WithField("m", m.Name).WithError(err).Error("Threshold error")
 			continue
@@ -469,13 +589,90 @@ func (e *Engine) processThresholds() (shouldAbort bool) {
 				shouldAbort = true
 			}
 		}
+		if wasTainted == succ {
+			e.emitEvent(lib.Event{Type: lib.EventThresholdCrossed, Time: time.Now(), Data: map[string]interface{}{
+				"metric": m.Name,
+				"passed": succ,
+			}})
+		}
 	}
 
+	e.sendThresholdEvaluation(t)
 	return shouldAbort
 }
 
+// sendThresholdEvaluation gives the results of the last run of the thresholds
+// to the outputs which want them. The metrics should be locked by the caller.
+func (e *Engine) sendThresholdEvaluation(t time.Duration) {
+	var evaluation *output.ThresholdEvaluation
+	for _, out := range e.outputs {
+		thresholdsOut, ok := out.(output.WithThresholdEvaluations)
+		if !ok {
+			continue
+		}
+		if evaluation == nil {
+			evaluation = &output.ThresholdEvaluation{
+				TestRunDuration: t,
+				Passed:          !e.thresholdsTainted,
+				Results:         make(map[string][]stats.ThresholdResult),
+			}
+			for _, m := range e.Metrics {
+				if len(m.Thresholds.Thresholds) > 0 {
+					evaluation.Results[m.Name] = m.Thresholds.Results()
+				}
+			}
+		}
+		thresholdsOut.AddThresholdEvaluation(*evaluation)
+	}
+}
+
+// setupTeardownSubmetric returns the sub-metric of m for the group of setup()
+// or teardown() the sample was emitted in, or in one of the groups within it,
+// e.g. http_req_duration{group:::setup} for ::setup::login, which is added if
+// m doesn't have it yet. It returns nil for the samples of the iterations or
+// if the isolateSetupTeardownMetrics option isn't enabled.
+func (e *Engine) setupTeardownSubmetric(m *stats.Metric, sample stats.Sample) *stats.Submetric {
+	if !e.Options.IsolateSetupTeardownMetrics.Bool {
+		return nil
+	}
+	group, _ := sample.Tags.Get("group")
+	for _, fn := range []string{consts.SetupFn, consts.TeardownFn} {
+		root := lib.GroupSeparator + fn
+		if group == root || strings.HasPrefix(group, root+lib.GroupSeparator) {
+			return e.setupTeardownGroupSubmetric(m, root)
+		}
+	}
+	return nil
+}
+
+func (e *Engine) setupTeardownGroupSubmetric(m *stats.Metric, group string) *stats.Submetric {
+	name := m.Name + "{group:" + group + "}"
+	var sm *stats.Submetric
+	for _, s := range m.Submetrics {
+		if s.Name == name {
+			sm = s
+			break
+		}
+	}
+	if sm == nil {
+		_, sm = stats.NewSubmetric(name)
+		m.Submetrics = append(m.Submetrics, sm)
+	}
+	if sm.Metric == nil {
+		sm.Metric = e.newMetric(sm.Name, m)
+		sm.Metric.Sub = *sm
+		sm.Metric.Thresholds = e.thresholds[sm.Name]
+		e.Metrics[sm.Name] = sm.Metric
+	}
+	return sm
+}
+
 func (e *Engine) processSamplesForMetrics(sampleContainers []stats.SampleContainer) {
 	for _, sampleContainer := range sampleContainers {
+		if reset, ok := sampleContainer.(stats.MetricReset); ok {
+			e.resetMetric(reset)
+			continue
+		}
 		samples := sampleContainer.GetSamples()
 
 		if len(samples) == 0 {
@@ -485,30 +682,262 @@ func (e *Engine) processSamplesForMetrics(sampleContainers []stats.SampleContain
 		for _, sample := range samples {
 			m, ok := e.Metrics[sample.Metric.Name]
 			if !ok {
-				m = stats.New(sample.Metric.Name, sample.Metric.Type, sample.Metric.Contains)
+				m = e.newMetric(sample.Metric.Name, sample.Metric)
 				m.Thresholds = e.thresholds[m.Name]
 				m.Submetrics = e.submetrics[m.Name]
 				e.Metrics[m.Name] = m
 			}
+			if sm := e.setupTeardownSubmetric(m, sample); sm != nil {
+				sm.Metric.Sink.Add(sample)
+				sm.Metric.Thresholds.AddSample(sample)
+				sm.Metric.TrackSampleTime(sample.Time)
+				continue
+			}
+			var synthetic []stats.Sample
+			if e.coCorrector != nil {
+				synthetic = e.coCorrector.backfill(m, sample)
+			}
 			m.Sink.Add(sample)
+			m.Thresholds.AddSample(sample)
+			m.TrackSampleTime(sample.Time)
+			if e.timeline != nil {
+				e.timeline.add(sample)
+			}
+			for _, s := range synthetic {
+				m.Sink.Add(s)
+				m.Thresholds.AddSample(s)
+			}
+			if len(synthetic) > 0 {
+				e.coCorrector.count(m.Name, len(synthetic))
+			}
+
+			switch m.Name {
+			case metrics.HTTPReqFailedName:
+				e.addFailureCauseSubmetric(m, sample)
+			case metrics.WorkloadMixName:
+				e.addWorkloadMixSubmetric(m, sample)
+			}
 
 			for _, sm := range m.Submetrics {
-				if !sample.Tags.Contains(sm.Tags) {
+				if !sm.Matches(sample.Tags) {
 					continue
 				}
 
 				if sm.Metric == nil {
-					sm.Metric = stats.New(sm.Name, sample.Metric.Type, sample.Metric.Contains)
+					sm.Metric = e.newMetric(sm.Name, sample.Metric)
 					sm.Metric.Sub = *sm
 					sm.Metric.Thresholds = e.thresholds[sm.Name]
 					e.Metrics[sm.Name] = sm.Metric
 				}
 				sm.Metric.Sink.Add(sample)
+				sm.Metric.Thresholds.AddSample(sample)
+				sm.Metric.TrackSampleTime(sample.Time)
+				for _, s := range synthetic {
+					sm.Metric.Sink.Add(s)
+					sm.Metric.Thresholds.AddSample(s)
+				}
+				if len(synthetic) > 0 {
+					e.coCorrector.count(sm.Name, len(synthetic))
+				}
 			}
 		}
 	}
 }
 
+// resetMetric replaces the sinks of the metric of the reset and of its
+// submetrics with new ones, so their values are of the samples after it, once
+// per scenario. Their thresholds are kept, and are evaluated with the new
+// values only, like the rates of the counters over the time since the reset.
+// The resets of the other VUs of the scenario are ignored, and so are the
+// later ones of the VU which reset it, with a warning.
+func (e *Engine) resetMetric(reset stats.MetricReset) {
+	name := reset.Metric.Name
+	m, ok := e.Metrics[name]
+	if !ok {
+		return
+	}
+	key := metricResetKey{name: name, scenario: reset.Scenario}
+	if applied, ok := e.resets[key]; ok {
+		if applied.vu == reset.VU && !applied.warned {
+			applied.warned = true
+			e.logger.WithField("metric", name).Warnf("The metric '%s' was already reset in the scenario '%s', "+
+				"only its first reset in a scenario is applied", name, reset.Scenario)
+		}
+		return
+	}
+	if e.resets == nil {
+		e.resets = make(map[metricResetKey]*appliedMetricReset)
+	}
+	e.resets[key] = &appliedMetricReset{vu: reset.VU}
+
+	resetAt := e.executionState.GetCurrentTestRunDuration()
+	if !reset.Time.IsZero() {
+		resetAt -= time.Since(reset.Time)
+	}
+	if resetAt < 0 {
+		resetAt = 0
+	}
+	resetSink := func(m *stats.Metric, like *stats.Metric) {
+		m.Sink = e.newMetric(m.Name, like).Sink
+		if counter, ok := m.Sink.(*stats.CounterSink); ok {
+			counter.ResetAt = resetAt
+		}
+		m.Thresholds.Reset()
+	}
+	resetSink(m, m)
+	for _, sm := range m.Submetrics {
+		if sm.Metric != nil {
+			resetSink(sm.Metric, m)
+		}
+	}
+	e.metricValuesExpiry = time.Time{}
+}
+
+// newMetric returns a new metric for the engine like the one of the samples,
+// with its Trend values rounded to the trendPrecision option if it's set, and
+// their percentiles calculated with the trendPercentileMethod one. A Histogram
+// keeps the buckets of the one of the samples, and a Gauge averages its values
+// with the half-life of the gaugeEWMAHalfLife option.
+func (e *Engine) newMetric(name string, like *stats.Metric) *stats.Metric {
+	m := stats.New(name, like.Type, like.Contains)
+	if histogram, ok := like.Sink.(*stats.HistogramSink); ok {
+		m.Sink, _ = stats.NewHistogramSink(histogram.Buckets)
+	}
+	if like.Type == stats.Gauge {
+		m.Sink = stats.NewGaugeSink(time.Duration(e.Options.GaugeEWMAHalfLife.Duration))
+	}
+	if like.Type != stats.Trend {
+		return m
+	}
+	sink := stats.NewTrendSink(int(e.Options.TrendPrecision.Int64))
+	if e.Options.TrendPercentileMethod.Valid {
+		sink.SetPercentileMethod(stats.PercentileMethod(e.Options.TrendPercentileMethod.String))
+	}
+	m.Sink = sink
+	return m
+}
+
+// thresholdsInterval returns how often the thresholds are evaluated.
+func (e *Engine) thresholdsInterval() time.Duration {
+	if e.Options.ThresholdsEvaluationInterval.Valid {
+		return e.Options.ThresholdsEvaluationInterval.TimeDuration()
+	}
+	return thresholdsRate
+}
+
+// MetricValue implements lib.MetricsReader with the metrics of the engine.
+// The values are calculated at most once per interval of the thresholds
+// evaluation, as calculating the ones of a Trend sorts all of its values. It
+// returns an error for the names of the metrics which aren't registered.
+func (e *Engine) MetricValue(name, method string) (float64, error) {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+
+	if now := time.Now(); now.After(e.metricValuesExpiry) {
+		e.metricValues = make(map[metricValueKey]float64)
+		e.metricValuesExpiry = now.Add(e.thresholdsInterval())
+	}
+	key := metricValueKey{name: name, method: method}
+	if value, ok := e.metricValues[key]; ok {
+		return value, nil
+	}
+
+	m, ok := e.Metrics[name]
+	if !ok {
+		parentName, _, err := stats.ParseSubmetric(name)
+		if err != nil {
+			return 0, err
+		}
+		parent := e.registry.Get(parentName)
+		if parent == nil {
+			return 0, fmt.Errorf("there's no metric %q", parentName)
+		}
+		// the values of a metric without samples yet are the ones of an empty sink
+		m = e.newMetric(name, parent)
+	}
+	m.Sink.Calc()
+	value, err := stats.AggregatedValue(m.Sink, method, e.executionState.GetCurrentTestRunDuration())
+	if err != nil {
+		return 0, err
+	}
+	e.metricValues[key] = value
+	return value, nil
+}
+
+// Timeline returns the values of the key metrics over the intervals of the
+// summaryTimelineInterval option, or nil if it's 0. The MetricsLock needs to
+// be held while calling it.
+func (e *Engine) Timeline() *lib.SummaryTimeline {
+	if e.timeline == nil {
+		return nil
+	}
+	return e.timeline.export()
+}
+
+// Anomalies returns the events of the anomalies detected in the requests, in
+// order, or nil if the anomalyDetectionInterval option is 0. The MetricsLock
+// needs to be held while calling it.
+func (e *Engine) Anomalies() []lib.Event {
+	if e.anomalies == nil {
+		return nil
+	}
+	return append([]lib.Event(nil), e.anomalies.events...)
+}
+
+// CoordinatedOmissionCorrections returns how many synthetic samples were
+// back-filled in each of the metrics corrected for coordinated omission. The
+// MetricsLock needs to be held while calling it.
+func (e *Engine) CoordinatedOmissionCorrections() map[string]int64 {
+	if e.coCorrector == nil {
+		return nil
+	}
+	corrections := make(map[string]int64, len(e.coCorrector.synthetic))
+	for name, n := range e.coCorrector.synthetic {
+		corrections[name] = n
+	}
+	return corrections
+}
+
+// addFailureCauseSubmetric adds a http_req_failed{error_code:X} submetric for
+// every distinct error code that is seen, so the end-of-test summary can show
+// a breakdown of the failure causes next to the overall failure rate.
+func (e *Engine) addFailureCauseSubmetric(m *stats.Metric, sample stats.Sample) {
+	if sample.Value == 0 {
+		return
+	}
+	code, ok := sample.Tags.Get("error_code")
+	if !ok || code == "" {
+		return
+	}
+	e.addSubmetric(m, m.Name+"{error_code:"+code+"}")
+}
+
+// addWorkloadMixSubmetric adds a workload_mix{endpoint:X,expected:Y}
+// submetric for every endpoint of a k6/experimental/workload mix, so the
+// end-of-test summary shows the share of the iterations each one got next to
+// its configured share.
+func (e *Engine) addWorkloadMixSubmetric(m *stats.Metric, sample stats.Sample) {
+	endpoint, ok := sample.Tags.Get("endpoint")
+	if !ok || endpoint == "" {
+		return
+	}
+	expected, _ := sample.Tags.Get("expected")
+	e.addSubmetric(m, m.Name+"{endpoint:"+endpoint+",expected:"+expected+"}")
+}
+
+// addSubmetric adds the named submetric to m, unless it already has it.
+func (e *Engine) addSubmetric(m *stats.Metric, name string) {
+	for _, sm := range m.Submetrics {
+		if sm.Name == name {
+			return
+		}
+	}
+
+	_, sm := stats.NewSubmetric(name)
+	m.Submetrics = append(m.Submetrics, sm)
+	e.submetrics[m.Name] = m.Submetrics
+}
+
 func (e *Engine) processSamples(sampleContainers []stats.SampleContainer) {
 	if len(sampleContainers) == 0 {
 		return
@@ -518,12 +947,146 @@ func (e *Engine) processSamples(sampleContainers []stats.SampleContainer) {
 	e.MetricsLock.Lock()
 	defer e.MetricsLock.Unlock()
 
+	if len(e.Options.TagTransforms) > 0 {
+		e.transformSampleTags(sampleContainers)
+	}
+	if e.tagGuard != nil {
+		e.tagGuard.apply(sampleContainers)
+	}
+	if offset := e.runtimeOptions.ClockOffset.TimeDuration(); offset != 0 {
+		adjustSampleTimes(sampleContainers, offset)
+	}
+
 	// TODO: run this and the below code in goroutines?
 	if !(e.runtimeOptions.NoSummary.Bool && e.runtimeOptions.NoThresholds.Bool) {
 		e.processSamplesForMetrics(sampleContainers)
 	}
 
+	if e.errorRateBreaker != nil {
+		e.processErrorRates(sampleContainers)
+	}
+	if e.failureLimiter != nil {
+		e.processFailureLimits(sampleContainers)
+	}
+
 	for _, out := range e.outputs {
 		out.AddMetricSamples(sampleContainers)
 	}
+
+	if e.anomalies != nil {
+		for _, event := range e.anomalies.add(sampleContainers) {
+			e.emitEvent(event)
+		}
+	}
+}
+
+// emitEvent emits an event about the metrics, and gives it to the outputs
+// which want them. The metrics should be locked by the caller, so the outputs
+// get the events in order with the samples.
+func (e *Engine) emitEvent(event lib.Event) {
+	e.executionState.Events.EmitEvent(event)
+	for _, out := range e.outputs {
+		if eventsOut, ok := out.(output.WithEvents); ok {
+			eventsOut.AddEvent(event)
+		}
+	}
+}
+
+// processErrorRates stops the whole test or a single scenario the first time
+// the rate of failed requests in their window is over the abortOnErrorRate
+// limit.
+func (e *Engine) processErrorRates(sampleContainers []stats.SampleContainer) {
+	e.errorRateBreaker.add(sampleContainers)
+
+	now := time.Now().Add(e.runtimeOptions.ClockOffset.TimeDuration())
+	if w := e.errorRateBreaker.global; w != nil {
+		if rate, exceeded := w.exceeded(now); exceeded {
+			e.logger.Warnf("Stopping the test, %.2f%% of the requests failed, over the limit of %s",
+				rate*100, w.limit)
+			close(e.errorRateAbortChan)
+		}
+	}
+	for name, w := range e.errorRateBreaker.scenarios {
+		if rate, exceeded := w.exceeded(now); exceeded {
+			e.logger.WithField("scenario", name).Warnf(
+				"Stopping the scenario, %.2f%% of its requests failed, over the limit of %s", rate*100, w.limit)
+			e.ExecutionScheduler.StopScenario(name)
+		}
+	}
+}
+
+// processFailureLimits stops the scenarios which reached the limit of their
+// stopOnFailures option, while the rest of the test keeps running.
+func (e *Engine) processFailureLimits(sampleContainers []stats.SampleContainer) {
+	for _, name := range e.failureLimiter.add(sampleContainers) {
+		c := e.failureLimiter.scenarios[name]
+		e.logger.WithField("scenario", name).Warnf(
+			"Stopping the scenario after %d failed checks and %d failed requests, reaching the limit of %s",
+			c.checks, c.requests, c.limit)
+		e.ExecutionScheduler.StopScenario(name)
+	}
+}
+
+// transformSampleTags applies the configured tag transformation rules to all
+// of the given samples, before they are processed by the sinks and outputs.
+// Sample containers are modified in place, since nothing else should be using
+// them after they have been sent to the Engine.
+func (e *Engine) transformSampleTags(sampleContainers []stats.SampleContainer) {
+	// Samples in a container usually share the same tags, so cache the result
+	cache := make(map[*stats.SampleTags]*stats.SampleTags)
+	transform := func(tags *stats.SampleTags) *stats.SampleTags {
+		if transformed, ok := cache[tags]; ok {
+			return transformed
+		}
+		transformed := e.Options.TagTransforms.Apply(tags)
+		cache[tags] = transformed
+		return transformed
+	}
+
+	for i, sc := range sampleContainers {
+		switch container := sc.(type) {
+		case stats.Sample:
+			container.Tags = transform(container.Tags)
+			sampleContainers[i] = container
+		case stats.ConnectedSamples:
+			container.Tags = transform(container.Tags)
+			for j := range container.Samples {
+				container.Samples[j].Tags = transform(container.Samples[j].Tags)
+			}
+			sampleContainers[i] = container
+		default:
+			samples := container.GetSamples()
+			for j := range samples {
+				samples[j].Tags = transform(samples[j].Tags)
+			}
+		}
+	}
+}
+
+// adjustSampleTimes adds the clock offset to the time of all of the given
+// samples, so they're aligned with the ones of the other instances of k6 when
+// the results are merged. Like with transformSampleTags, sample containers are
+// modified in place.
+func adjustSampleTimes(sampleContainers []stats.SampleContainer, offset time.Duration) {
+	adjust := func(samples []stats.Sample) {
+		for j := range samples {
+			samples[j].Time = samples[j].Time.Add(offset)
+		}
+	}
+
+	for i, sc := range sampleContainers {
+		switch container := sc.(type) {
+		case stats.Sample:
+			container.Time = container.Time.Add(offset)
+			sampleContainers[i] = container
+		case stats.ConnectedSamples:
+			container.Time = container.Time.Add(offset)
+			adjust(container.Samples)
+			sampleContainers[i] = container
+		case stats.ShiftableSampleContainer:
+			container.ShiftTime(offset)
+		default:
+			adjust(container.GetSamples())
+		}
+	}
 }
@@ -40,6 +40,7 @@ import (
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/executor"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/netext/httpext"
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/lib/testutils/httpmultibin"
 	"go.k6.io/k6/lib/testutils/minirunner"
@@ -80,7 +81,7 @@ func newTestEngine( //nolint:golint
 
 	registry := metrics.NewRegistry()
 	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
-	engine, err = NewEngine(execScheduler, opts, lib.RuntimeOptions{}, outputs, logger, builtinMetrics)
+	engine, err = NewEngine(execScheduler, opts, lib.RuntimeOptions{}, outputs, logger, registry, builtinMetrics)
 	require.NoError(t, err)
 
 	run, waitFn, err := engine.Init(globalCtx, runCtx)
@@ -286,6 +287,355 @@ func TestEngine_processSamples(t *testing.T) {
 		assert.IsType(t, &stats.GaugeSink{}, e.Metrics["my_metric"].Sink)
 		assert.IsType(t, &stats.GaugeSink{}, e.Metrics["my_metric{a:1}"].Sink)
 	})
+	t.Run("sample times", func(t *testing.T) {
+		t.Parallel()
+		ths := stats.NewThresholds([]string{`value<2`})
+		require.NoError(t, ths.Parse())
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+			Thresholds: map[string]stats.Thresholds{"my_metric{a:1}": ths},
+		})
+		defer wait()
+
+		start := time.Unix(1600000000, 0)
+		tagsA := stats.IntoSampleTags(&map[string]string{"a": "1"})
+		tagsB := stats.IntoSampleTags(&map[string]string{"a": "2"})
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: metric, Value: 1, Time: start.Add(time.Second), Tags: tagsA},
+			stats.Sample{Metric: metric, Value: 1, Time: start, Tags: tagsB},
+			stats.Sample{Metric: metric, Value: 1, Time: start.Add(time.Minute), Tags: tagsB},
+		})
+
+		assert.Equal(t, start, e.Metrics["my_metric"].FirstSampleTime)
+		assert.Equal(t, start.Add(time.Minute), e.Metrics["my_metric"].LastSampleTime)
+		assert.Equal(t, start.Add(time.Second), e.Metrics["my_metric{a:1}"].FirstSampleTime)
+		assert.Equal(t, start.Add(time.Second), e.Metrics["my_metric{a:1}"].LastSampleTime)
+	})
+	t.Run("trend precision", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{TrendPrecision: null.IntFrom(3)})
+		defer wait()
+
+		trend := stats.New("my_trend", stats.Trend, stats.Time)
+		e.processSamples([]stats.SampleContainer{stats.Sample{Metric: trend, Value: 123.456}})
+
+		require.IsType(t, &stats.TrendSink{}, e.Metrics["my_trend"].Sink)
+		assert.Equal(t, 3, e.Metrics["my_trend"].Sink.(*stats.TrendSink).Precision())
+	})
+	t.Run("trend percentile method", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+			TrendPercentileMethod: null.StringFrom("nearest-rank"),
+		})
+		defer wait()
+
+		trend := stats.New("my_trend", stats.Trend, stats.Time)
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: trend, Value: 1}, stats.Sample{Metric: trend, Value: 2},
+		})
+
+		require.IsType(t, &stats.TrendSink{}, e.Metrics["my_trend"].Sink)
+		sink := e.Metrics["my_trend"].Sink.(*stats.TrendSink)
+		assert.Equal(t, stats.PercentileNearestRank, sink.PercentileMethod())
+		assert.Equal(t, 2.0, sink.P(0.9))
+	})
+	t.Run("histogram buckets", func(t *testing.T) {
+		t.Parallel()
+		ths := stats.NewThresholds([]string{`p(50)<100`})
+		require.NoError(t, ths.Parse())
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+			Thresholds: map[string]stats.Thresholds{"my_histogram{a:1}": ths},
+		})
+		defer wait()
+
+		histogram, err := metrics.NewRegistry().NewHistogram("my_histogram", []float64{100, 200}, stats.Time)
+		require.NoError(t, err)
+		tags := stats.IntoSampleTags(&map[string]string{"a": "1"})
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: histogram, Value: 150, Tags: tags},
+			stats.Sample{Metric: histogram, Value: 250, Tags: tags},
+		})
+
+		for _, name := range []string{"my_histogram", "my_histogram{a:1}"} {
+			require.IsType(t, &stats.HistogramSink{}, e.Metrics[name].Sink)
+			sink := e.Metrics[name].Sink.(*stats.HistogramSink)
+			assert.Equal(t, []float64{100, 200}, sink.Buckets)
+			assert.Equal(t, []uint64{0, 1, 1}, sink.Counts)
+		}
+	})
+	t.Run("tag transforms", func(t *testing.T) {
+		t.Parallel()
+		ths := stats.NewThresholds([]string{`value<2`})
+		require.NoError(t, ths.Parse())
+		tt, err := stats.NewTagTransform("class")
+		require.NoError(t, err)
+
+		mockOutput := mockoutput.New()
+		e, _, wait := newTestEngine(t, nil, nil, []output.Output{mockOutput}, lib.Options{
+			Thresholds: map[string]stats.Thresholds{
+				"my_metric{status:4xx}": ths,
+			},
+			TagTransforms: stats.TagTransforms{"status": tt},
+		})
+		defer wait()
+
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"status": "404"})},
+			stats.Samples{{Metric: metric, Value: 1.5, Tags: stats.IntoSampleTags(&map[string]string{"status": "200"})}},
+		})
+
+		require.Contains(t, e.Metrics, "my_metric{status:4xx}")
+		assert.Equal(t, 1.25, e.Metrics["my_metric{status:4xx}"].Sink.(*stats.GaugeSink).Value)
+
+		samples := mockOutput.SampleContainers
+		require.Len(t, samples, 2)
+		assert.Equal(t, map[string]string{"status": "4xx"}, samples[0].GetSamples()[0].Tags.CloneTags())
+		assert.Equal(t, map[string]string{"status": "2xx"}, samples[1].GetSamples()[0].Tags.CloneTags())
+	})
+	t.Run("status class submetrics", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagStatusClass),
+		})
+		defer wait()
+
+		httpReqs := stats.New("http_reqs", stats.Counter)
+		sample := func(class string) stats.Sample {
+			tags := map[string]string{"status_class": class}
+			return stats.Sample{Metric: httpReqs, Value: 1, Tags: stats.IntoSampleTags(&tags)}
+		}
+		e.processSamples([]stats.SampleContainer{sample("2xx"), sample("2xx"), sample("5xx")})
+
+		assert.Len(t, e.submetrics["http_reqs"], 5)
+		require.Contains(t, e.Metrics, "http_reqs{status_class:2xx}")
+		require.Contains(t, e.Metrics, "http_reqs{status_class:5xx}")
+		assert.NotContains(t, e.Metrics, "http_reqs{status_class:4xx}")
+		assert.Equal(t, 2.0, e.Metrics["http_reqs{status_class:2xx}"].Sink.(*stats.CounterSink).Value)
+		assert.Equal(t, 1.0, e.Metrics["http_reqs{status_class:5xx}"].Sink.(*stats.CounterSink).Value)
+	})
+	t.Run("status class submetrics aren't added by default", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+			SystemTags: &stats.DefaultSystemTagSet,
+		})
+		defer wait()
+
+		assert.Empty(t, e.submetrics["http_reqs"])
+	})
+	t.Run("failure causes", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{})
+		defer wait()
+
+		reqFailed := stats.New(metrics.HTTPReqFailedName, stats.Rate)
+		sample := func(value float64, tags map[string]string) stats.Sample {
+			return stats.Sample{Metric: reqFailed, Value: value, Tags: stats.IntoSampleTags(&tags)}
+		}
+		e.processSamples([]stats.SampleContainer{
+			sample(0, map[string]string{"status": "200"}),
+			sample(1, map[string]string{"status": "0", "error_code": "1050"}),
+			sample(1, map[string]string{"status": "0", "error_code": "1050"}),
+			sample(1, map[string]string{"status": "503", "error_code": "1503"}),
+			sample(0, map[string]string{"status": "404", "error_code": "1404"}),
+		})
+
+		assert.Len(t, e.submetrics[metrics.HTTPReqFailedName], 2)
+		require.Contains(t, e.Metrics, "http_req_failed{error_code:1050}")
+		require.Contains(t, e.Metrics, "http_req_failed{error_code:1503}")
+		assert.NotContains(t, e.Metrics, "http_req_failed{error_code:1404}")
+		assert.Equal(t, int64(2), e.Metrics["http_req_failed{error_code:1050}"].Sink.(*stats.RateSink).Trues)
+		assert.Equal(t, int64(1), e.Metrics["http_req_failed{error_code:1503}"].Sink.(*stats.RateSink).Trues)
+	})
+	t.Run("workload mix", func(t *testing.T) {
+		t.Parallel()
+		e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{})
+		defer wait()
+
+		mix := stats.New(metrics.WorkloadMixName, stats.Rate)
+		sample := func(picked bool, endpoint, expected string) stats.Sample {
+			tags := map[string]string{"endpoint": endpoint, "expected": expected}
+			return stats.Sample{Metric: mix, Value: stats.B(picked), Tags: stats.IntoSampleTags(&tags)}
+		}
+		e.processSamples([]stats.SampleContainer{
+			sample(true, "browse", "75%"), sample(false, "buy", "25%"),
+			sample(false, "browse", "75%"), sample(true, "buy", "25%"),
+			sample(true, "browse", "75%"), sample(false, "buy", "25%"),
+		})
+
+		assert.Len(t, e.submetrics[metrics.WorkloadMixName], 2)
+		require.Contains(t, e.Metrics, "workload_mix{endpoint:browse,expected:75%}")
+		require.Contains(t, e.Metrics, "workload_mix{endpoint:buy,expected:25%}")
+		assert.Equal(t, int64(2), e.Metrics["workload_mix{endpoint:browse,expected:75%}"].Sink.(*stats.RateSink).Trues)
+		assert.Equal(t, int64(3), e.Metrics["workload_mix{endpoint:buy,expected:25%}"].Sink.(*stats.RateSink).Total)
+	})
+	t.Run("clock offset", func(t *testing.T) {
+		t.Parallel()
+		mockOutput := mockoutput.New()
+		e, _, wait := newTestEngine(t, nil, nil, []output.Output{mockOutput}, lib.Options{})
+		defer wait()
+		e.runtimeOptions.ClockOffset = types.NullDurationFrom(-1500 * time.Millisecond)
+
+		now := time.Date(2022, 2, 2, 12, 0, 0, 0, time.UTC)
+		adjusted := now.Add(-1500 * time.Millisecond)
+		trail := &httpext.Trail{EndTime: now, Samples: []stats.Sample{{Metric: metric, Time: now, Value: 1}}}
+		e.processSamples([]stats.SampleContainer{
+			stats.Sample{Metric: metric, Time: now, Value: 1},
+			stats.Samples{{Metric: metric, Time: now, Value: 1}},
+			stats.ConnectedSamples{Time: now, Samples: []stats.Sample{{Metric: metric, Time: now, Value: 1}}},
+			trail,
+		})
+
+		samples := mockOutput.SampleContainers
+		require.Len(t, samples, 4)
+		for _, sc := range samples {
+			assert.Equal(t, adjusted, sc.GetSamples()[0].Time)
+		}
+		assert.Equal(t, adjusted, samples[2].(stats.ConnectedSamples).Time)
+		assert.Equal(t, adjusted, trail.EndTime)
+	})
+}
+
+func TestEngineMetricValue(t *testing.T) {
+	t.Parallel()
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{})
+	defer wait()
+	assert.Equal(t, e, e.executionState.Metrics)
+
+	trend := stats.New("my_trend", stats.Trend)
+	var samples []stats.SampleContainer
+	for _, v := range []float64{10, 20, 30} {
+		samples = append(samples, stats.Sample{Metric: trend, Value: v})
+	}
+	e.processSamples(samples)
+
+	value, err := e.MetricValue("my_trend", "med")
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, value)
+	value, err = e.MetricValue("my_trend", "p(50)")
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, value)
+	_, err = e.MetricValue("my_trend", "rate")
+	assert.Error(t, err)
+
+	// the values are cached until the next interval of the thresholds evaluation
+	e.processSamples([]stats.SampleContainer{stats.Sample{Metric: trend, Value: 1000}})
+	value, err = e.MetricValue("my_trend", "med")
+	require.NoError(t, err)
+	assert.Equal(t, 20.0, value)
+	e.metricValuesExpiry = time.Time{}
+	value, err = e.MetricValue("my_trend", "med")
+	require.NoError(t, err)
+	assert.Equal(t, 25.0, value)
+
+	e.registry.MustNewMetric("my_counter", stats.Counter)
+	value, err = e.MetricValue("my_counter", "count")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value, "a metric without samples has values of 0")
+	value, err = e.MetricValue("my_counter{status:200}", "count")
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, value)
+	_, err = e.MetricValue("my_counter", "med")
+	assert.Error(t, err)
+
+	_, err = e.MetricValue("my_typo", "count")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `there's no metric "my_typo"`)
+}
+
+func TestEngineResetMetric(t *testing.T) {
+	t.Parallel()
+	ths := stats.NewThresholds([]string{`count<10`, `count<3`, `rate<1000000`})
+	require.NoError(t, ths.Parse())
+	ths.Thresholds[1].ConsecutiveBreaches = 2
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+		Thresholds: map[string]stats.Thresholds{"my_counter{phase:one}": ths},
+	})
+	defer wait()
+	logHook := &testutils.SimpleLogrusHook{HookedLevels: []logrus.Level{logrus.WarnLevel}}
+	e.logger.Logger.AddHook(logHook)
+	e.executionState.MarkStarted()
+
+	counter := stats.New("my_counter", stats.Counter)
+	tags := stats.IntoSampleTags(&map[string]string{"phase": "one"})
+	e.processSamples([]stats.SampleContainer{stats.Sample{Metric: counter, Value: 5, Tags: tags}})
+	e.processThresholds()
+	submetric := e.Metrics["my_counter{phase:one}"]
+	require.True(t, submetric.Thresholds.Thresholds[1].LastFailed)
+
+	time.Sleep(50 * time.Millisecond)
+	resetTime := time.Now()
+	e.processSamples([]stats.SampleContainer{
+		stats.MetricReset{Metric: counter, Time: resetTime, Scenario: "two", VU: 1},
+		stats.Sample{Metric: counter, Value: 2, Tags: tags},
+		// the other VUs of the scenario reset the metric too
+		stats.MetricReset{Metric: counter, Time: resetTime, Scenario: "two", VU: 2},
+		stats.Sample{Metric: counter, Value: 1, Tags: tags},
+		stats.MetricReset{Metric: stats.New("missing", stats.Counter)},
+	})
+
+	assert.Equal(t, 3.0, e.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+	assert.Equal(t, 3.0, submetric.Sink.(*stats.CounterSink).Value)
+	assert.Equal(t, ths.Thresholds, submetric.Thresholds.Thresholds, "the thresholds are kept")
+	assert.False(t, submetric.Thresholds.Thresholds[1].LastFailed, "the state of the thresholds is reset")
+	assert.NotContains(t, e.Metrics, "missing")
+	assert.Empty(t, logHook.Drain())
+
+	// the rate of the thresholds is over the time since the reset
+	resetAt := submetric.Sink.(*stats.CounterSink).ResetAt
+	require.True(t, resetAt > 0)
+	before := e.executionState.GetCurrentTestRunDuration()
+	e.processThresholds()
+	after := e.executionState.GetCurrentTestRunDuration()
+	rate := submetric.Thresholds.Results()[2].Observed.Float64
+	assert.True(t, rate >= 3/(after-resetAt).Seconds() && rate <= 3/(before-resetAt).Seconds(),
+		"the rate %f isn't over the time since the reset", rate)
+
+	// resetting it again in the same scenario is ignored with a warning
+	e.processSamples([]stats.SampleContainer{
+		stats.MetricReset{Metric: counter, Time: time.Now(), Scenario: "two", VU: 1},
+		stats.MetricReset{Metric: counter, Time: time.Now(), Scenario: "two", VU: 1},
+	})
+	assert.Equal(t, 3.0, e.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+	entries := logHook.Drain()
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Message, "The metric 'my_counter' was already reset in the scenario 'two'")
+
+	e.processSamples([]stats.SampleContainer{
+		stats.MetricReset{Metric: counter, Time: resetTime, Scenario: "three"},
+		stats.Sample{Metric: counter, Value: 4, Tags: tags},
+	})
+	assert.Equal(t, 4.0, e.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+}
+
+func TestEngineIsolateSetupTeardownMetrics(t *testing.T) {
+	t.Parallel()
+	ths := stats.NewThresholds([]string{`count<10`})
+	require.NoError(t, ths.Parse())
+	setupThs := stats.NewThresholds([]string{`count<100`})
+	require.NoError(t, setupThs.Parse())
+	e, _, wait := newTestEngine(t, nil, nil, nil, lib.Options{
+		IsolateSetupTeardownMetrics: null.BoolFrom(true),
+		Thresholds: map[string]stats.Thresholds{
+			"my_counter":                ths,
+			"my_counter{group:::setup}": setupThs,
+		},
+	})
+	defer wait()
+
+	counter := stats.New("my_counter", stats.Counter)
+	e.processSamples([]stats.SampleContainer{
+		stats.Sample{Metric: counter, Value: 50, Tags: stats.IntoSampleTags(&map[string]string{"group": "::setup"})},
+		stats.Sample{Metric: counter, Value: 2, Tags: stats.IntoSampleTags(&map[string]string{"group": ""})},
+		stats.Sample{Metric: counter, Value: 3, Tags: stats.IntoSampleTags(&map[string]string{"group": "::teardown"})},
+		stats.Sample{Metric: counter, Value: 5, Tags: stats.IntoSampleTags(&map[string]string{"group": "::setup::login"})},
+		stats.Sample{Metric: counter, Value: 1, Tags: stats.IntoSampleTags(&map[string]string{"group": "::setuplike"})},
+	})
+
+	assert.Equal(t, 3.0, e.Metrics["my_counter"].Sink.(*stats.CounterSink).Value)
+	assert.Equal(t, 55.0, e.Metrics["my_counter{group:::setup}"].Sink.(*stats.CounterSink).Value,
+		"the samples of the groups within setup() are isolated too")
+	assert.Equal(t, setupThs, e.Metrics["my_counter{group:::setup}"].Thresholds)
+	assert.Equal(t, 3.0, e.Metrics["my_counter{group:::teardown}"].Sink.(*stats.CounterSink).Value)
+	assert.False(t, e.processThresholds())
 }
 
 func TestEngineThresholdsWillAbort(t *testing.T) {
@@ -351,6 +701,43 @@ func TestEngineAbortedByThresholds(t *testing.T) {
 	}
 }
 
+func TestEngineThresholdsEvaluationInterval(t *testing.T) {
+	t.Parallel()
+	metric := stats.New("my_metric", stats.Gauge)
+	ths := stats.NewThresholds([]string{"value>1.25"})
+	require.NoError(t, ths.Parse())
+	ths.Thresholds[0].AbortOnFail = true
+
+	done := make(chan struct{})
+	runner := &minirunner.MiniRunner{
+		Fn: func(ctx context.Context, _ *lib.State, out chan<- stats.SampleContainer) error {
+			out <- stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"})}
+			<-ctx.Done()
+			close(done)
+			return nil
+		},
+	}
+
+	_, run, wait := newTestEngine(t, nil, runner, nil, lib.Options{
+		Thresholds:                   map[string]stats.Thresholds{metric.Name: ths},
+		ThresholdsEvaluationInterval: types.NullDurationFrom(100 * time.Millisecond),
+		ThresholdsEvaluationDelay:    types.NullDurationFrom(500 * time.Millisecond),
+	})
+	defer wait()
+
+	start := time.Now()
+	go func() {
+		assert.NoError(t, run())
+	}()
+
+	select {
+	case <-done:
+		assert.GreaterOrEqual(t, int64(time.Since(start)), int64(500*time.Millisecond))
+	case <-time.After(thresholdsRate):
+		assert.Fail(t, "the thresholds should have been evaluated before the default interval")
+	}
+}
+
 func TestEngine_processThresholds(t *testing.T) {
 	t.Parallel()
 	metric := stats.New("my_metric", stats.Gauge)
@@ -368,6 +755,13 @@ func TestEngine_processThresholds(t *testing.T) {
 		"submetric,match,failing":   {false, map[string][]string{"my_metric{a:1}": {"value>1.25"}}, false},
 		"submetric,nomatch,passing": {true, map[string][]string{"my_metric{a:2}": {"value<2"}}, false},
 		"submetric,nomatch,failing": {true, map[string][]string{"my_metric{a:2}": {"value>1.25"}}, false},
+
+		"submetric,regex,failing":      {false, map[string][]string{"my_metric{a~:[0-9]}": {"value>1.25"}}, false},
+		"submetric,star,exact,passing": {true, map[string][]string{"my_metric{a:*}": {"value>1.25"}}, false},
+		"submetric,regex,nomatch,pass": {true, map[string][]string{"my_metric{a~:[2-9]}": {"value>1.25"}}, false},
+
+		"cross-metric,passing": {true, map[string][]string{"my_metric": {"value < no_samples_metric + 2"}}, false},
+		"cross-metric,failing": {false, map[string][]string{"my_metric{a:1}": {"value * 2 < my_metric/value"}}, false},
 	}
 
 	for name, data := range testdata {
@@ -845,7 +1239,7 @@ func TestVuInitException(t *testing.T) {
 
 	execScheduler, err := local.NewExecutionScheduler(runner, logger)
 	require.NoError(t, err)
-	engine, err := NewEngine(execScheduler, opts, lib.RuntimeOptions{}, nil, logger, builtinMetrics)
+	engine, err := NewEngine(execScheduler, opts, lib.RuntimeOptions{}, nil, logger, registry, builtinMetrics)
 	require.NoError(t, err)
 
 	ctx, cancel := context.WithCancel(context.Background())
@@ -929,6 +1323,7 @@ func TestEmittedMetricsWhenScalingDown(t *testing.T) {
 	// and stat executing its third one, while the second VU will only fully complete 1 iteration
 	// and will be canceled in the middle of its second one.
 	assert.Equal(t, 3.0, getMetricSum(mockOutput, metrics.IterationsName))
+	assert.Equal(t, 2.0, getMetricSum(mockOutput, metrics.IterationsInterruptedName))
 
 	// That means that we expect to see 8 HTTP requests in total, 3*2=6 from the complete iterations
 	// and one each from the two iterations that would be canceled in the middle of their execution
@@ -1230,7 +1625,7 @@ func TestActiveVUsCount(t *testing.T) {
 	require.NoError(t, runner.SetOptions(opts))
 	execScheduler, err := local.NewExecutionScheduler(runner, logger)
 	require.NoError(t, err)
-	engine, err := NewEngine(execScheduler, opts, rtOpts, []output.Output{mockOutput}, logger, builtinMetrics)
+	engine, err := NewEngine(execScheduler, opts, rtOpts, []output.Output{mockOutput}, logger, registry, builtinMetrics)
 	require.NoError(t, err)
 	run, waitFn, err := engine.Init(ctx, ctx) // no need for 2 different contexts
 	require.NoError(t, err)
@@ -1258,3 +1653,58 @@ func TestActiveVUsCount(t *testing.T) {
 		assert.Equal(t, "Insufficient VUs, reached 10 active VUs and cannot initialize more", logEntry.Message)
 	}
 }
+
+func TestEngineEvents(t *testing.T) {
+	t.Parallel()
+	metric := stats.New("my_metric", stats.Gauge)
+	ths := stats.NewThresholds([]string{"value>1.25"})
+	require.NoError(t, ths.Parse())
+	ths.Thresholds[0].AbortOnFail = true
+
+	runner := &minirunner.MiniRunner{
+		Fn: func(ctx context.Context, _ *lib.State, out chan<- stats.SampleContainer) error {
+			out <- stats.Sample{Metric: metric, Value: 1.25, Tags: stats.IntoSampleTags(&map[string]string{"a": "1"})}
+			<-ctx.Done()
+			return nil
+		},
+	}
+	mockOutput := mockoutput.New()
+	e, run, wait := newTestEngine(t, nil, runner, []output.Output{mockOutput}, lib.Options{
+		Thresholds: map[string]stats.Thresholds{metric.Name: ths},
+	})
+	events, unsubscribe := e.ExecutionScheduler.GetState().Events.Subscribe()
+	defer unsubscribe()
+
+	require.NoError(t, run())
+	wait()
+
+	var got []lib.Event
+	for len(events) > 0 {
+		got = append(got, <-events)
+	}
+	find := func(typ lib.EventType) *lib.Event {
+		for i := range got {
+			if got[i].Type == typ {
+				return &got[i]
+			}
+		}
+		require.Failf(t, "missing event", "%s in %v", typ, got)
+		return nil
+	}
+
+	assert.Equal(t, lib.EventTestStarted, got[0].Type)
+	assert.Equal(t, map[string]interface{}{"metric": "my_metric", "passed": false}, find(lib.EventThresholdCrossed).Data)
+	assert.Equal(t, map[string]interface{}{"reason": "thresholds"}, find(lib.EventAbortInitiated).Data)
+	assert.Equal(t, map[string]interface{}{"scenario": "default"}, find(lib.EventScenarioFinished).Data)
+	var stages []interface{}
+	for _, event := range got {
+		if event.Type == lib.EventStageChanged {
+			stages = append(stages, event.Data["stage"])
+		}
+	}
+	assert.Equal(t, []interface{}{"Started", "Setup", "Running", "Teardown", "Ended"}, stages)
+	assert.Equal(t, lib.EventTestFinished, got[len(got)-1].Type)
+
+	require.Len(t, mockOutput.Events, 1, "the outputs get only the events about the metrics")
+	assert.Equal(t, *find(lib.EventThresholdCrossed), mockOutput.Events[0])
+}
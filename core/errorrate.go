@@ -0,0 +1,141 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+// errorRateBucket counts the requests of a single second.
+type errorRateBucket struct {
+	second        int64
+	total, failed int64
+}
+
+// errorRateWindow keeps the counts of requests and failed requests in a
+// sliding window, with a resolution of one second.
+type errorRateWindow struct {
+	limit   types.ErrorRateLimit
+	buckets []errorRateBucket
+	tripped bool
+}
+
+func newErrorRateWindow(limit types.ErrorRateLimit) *errorRateWindow {
+	size := int(time.Duration(limit.Window).Round(time.Second) / time.Second)
+	if size < 1 {
+		size = 1
+	}
+	return &errorRateWindow{limit: limit, buckets: make([]errorRateBucket, size)}
+}
+
+func (w *errorRateWindow) add(t time.Time, failed bool) {
+	second := t.Unix()
+	b := &w.buckets[second%int64(len(w.buckets))]
+	if b.second != second {
+		*b = errorRateBucket{second: second}
+	}
+	b.total++
+	if failed {
+		b.failed++
+	}
+}
+
+// rate returns the rate of failed requests in the window ending at now, and
+// the number of requests it's calculated from.
+func (w *errorRateWindow) rate(now time.Time) (rate float64, total int64) {
+	oldest := now.Unix() - int64(len(w.buckets))
+	var failed int64
+	for _, b := range w.buckets {
+		if b.second > oldest && b.total > 0 {
+			total += b.total
+			failed += b.failed
+		}
+	}
+	if total == 0 {
+		return 0, 0
+	}
+	return float64(failed) / float64(total), total
+}
+
+// exceeded returns whether the limit is exceeded for the first time, so the
+// test or the scenario is stopped only once.
+func (w *errorRateWindow) exceeded(now time.Time) (float64, bool) {
+	if w.tripped {
+		return 0, false
+	}
+	rate, total := w.rate(now)
+	if total < w.limit.MinRequests || rate <= w.limit.Threshold {
+		return rate, false
+	}
+	w.tripped = true
+	return rate, true
+}
+
+// errorRateBreaker checks the rates of the failed requests of the whole test
+// and of the scenarios with their own abortOnErrorRate option.
+type errorRateBreaker struct {
+	global    *errorRateWindow
+	scenarios map[string]*errorRateWindow
+}
+
+// newErrorRateBreaker returns nil if there aren't any error rate limits.
+func newErrorRateBreaker(opts lib.Options) *errorRateBreaker {
+	b := &errorRateBreaker{scenarios: make(map[string]*errorRateWindow)}
+	if opts.AbortOnErrorRate != nil {
+		b.global = newErrorRateWindow(*opts.AbortOnErrorRate)
+	}
+	for name, conf := range opts.Scenarios {
+		if limit := conf.GetAbortOnErrorRate(); limit != nil {
+			b.scenarios[name] = newErrorRateWindow(*limit)
+		}
+	}
+	if b.global == nil && len(b.scenarios) == 0 {
+		return nil
+	}
+	return b
+}
+
+func (b *errorRateBreaker) add(sampleContainers []stats.SampleContainer) {
+	for _, sc := range sampleContainers {
+		for _, sample := range sc.GetSamples() {
+			if sample.Metric.Name != metrics.HTTPReqFailedName {
+				continue
+			}
+			failed := sample.Value != 0
+			if b.global != nil {
+				b.global.add(sample.Time, failed)
+			}
+			if len(b.scenarios) == 0 || sample.Tags == nil {
+				continue
+			}
+			if scenario, ok := sample.Tags.Get("scenario"); ok {
+				if w, ok := b.scenarios[scenario]; ok {
+					w.add(sample.Time, failed)
+				}
+			}
+		}
+	}
+}
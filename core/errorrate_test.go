@@ -0,0 +1,204 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/testutils/minirunner"
+	"go.k6.io/k6/lib/testutils/mockoutput"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+func TestErrorRateWindow(t *testing.T) {
+	t.Parallel()
+	w := newErrorRateWindow(types.ErrorRateLimit{
+		Threshold: 0.5, Window: types.Duration(3 * time.Second), MinRequests: 4,
+	})
+	start := time.Unix(1000, 0)
+
+	w.add(start, true)
+	w.add(start, true)
+	w.add(start.Add(time.Second), true)
+	rate, total := w.rate(start.Add(time.Second))
+	assert.Equal(t, 1.0, rate)
+	assert.Equal(t, int64(3), total)
+	_, exceeded := w.exceeded(start.Add(time.Second))
+	assert.False(t, exceeded, "there are less than minRequests requests")
+
+	w.add(start.Add(2*time.Second), false)
+	w.add(start.Add(2*time.Second), false)
+	rate, total = w.rate(start.Add(2 * time.Second))
+	assert.Equal(t, 0.6, rate)
+	assert.Equal(t, int64(5), total)
+
+	// The first second is out of the window now
+	w.add(start.Add(3*time.Second), false)
+	rate, total = w.rate(start.Add(3 * time.Second))
+	assert.Equal(t, 0.25, rate)
+	assert.Equal(t, int64(4), total)
+	_, exceeded = w.exceeded(start.Add(3 * time.Second))
+	assert.False(t, exceeded)
+
+	w.add(start.Add(4*time.Second), true)
+	w.add(start.Add(4*time.Second), true)
+	w.add(start.Add(5*time.Second), true)
+	w.add(start.Add(5*time.Second), true)
+	rate, exceeded = w.exceeded(start.Add(5 * time.Second))
+	assert.True(t, exceeded)
+	assert.Equal(t, 0.8, rate)
+	_, exceeded = w.exceeded(start.Add(5 * time.Second))
+	assert.False(t, exceeded, "the limit is only exceeded once")
+
+	rate, total = w.rate(start.Add(time.Minute))
+	assert.Equal(t, 0.0, rate)
+	assert.Equal(t, int64(0), total)
+}
+
+func failedRequestSample(ctx context.Context, failed bool) stats.Sample {
+	value := 0.0
+	if failed {
+		value = 1
+	}
+	return stats.Sample{
+		Time:   time.Now(),
+		Metric: stats.New(metrics.HTTPReqFailedName, stats.Rate),
+		Tags:   stats.IntoSampleTags(&map[string]string{"scenario": lib.GetScenarioState(ctx).Name}),
+		Value:  value,
+	}
+}
+
+func TestEngineAbortedByErrorRate(t *testing.T) {
+	t.Parallel()
+	done := make(chan struct{})
+	var once sync.Once
+	runner := &minirunner.MiniRunner{
+		Fn: func(ctx context.Context, _ *lib.State, out chan<- stats.SampleContainer) error {
+			select {
+			case out <- failedRequestSample(ctx, true):
+			case <-ctx.Done():
+			}
+			select {
+			case <-ctx.Done():
+				once.Do(func() { close(done) })
+			case <-time.After(10 * time.Millisecond):
+			}
+			return nil
+		},
+	}
+
+	mockOutput := mockoutput.New()
+	_, run, wait := newTestEngine(t, nil, runner, []output.Output{mockOutput}, lib.Options{
+		VUs:      null.IntFrom(1),
+		Duration: types.NullDurationFrom(time.Minute),
+		AbortOnErrorRate: &types.ErrorRateLimit{
+			Threshold: 0.05, Window: types.Duration(30 * time.Second), MinRequests: 5,
+		},
+	})
+
+	go func() {
+		assert.NoError(t, run())
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		assert.Fail(t, "Test should have been aborted within 10 seconds")
+	}
+	wait()
+	assert.Equal(t, lib.RunStatusAbortedThreshold, mockOutput.RunStatus)
+}
+
+func TestEngineScenarioStoppedByErrorRate(t *testing.T) {
+	t.Parallel()
+	failingStopped := make(chan struct{})
+	var once sync.Once
+	var okIterations int64
+	runner := &minirunner.MiniRunner{
+		Fn: func(ctx context.Context, _ *lib.State, out chan<- stats.SampleContainer) error {
+			failing := lib.GetScenarioState(ctx).Name == "failing"
+			if !failing {
+				atomic.AddInt64(&okIterations, 1)
+			}
+			select {
+			case out <- failedRequestSample(ctx, failing):
+			case <-ctx.Done():
+			}
+			select {
+			case <-ctx.Done():
+				if failing {
+					once.Do(func() { close(failingStopped) })
+				}
+			case <-time.After(10 * time.Millisecond):
+			}
+			return nil
+		},
+	}
+
+	scenario := func(name string, limit *types.ErrorRateLimit) executor.ConstantVUsConfig {
+		config := executor.NewConstantVUsConfig(name)
+		config.VUs = null.IntFrom(1)
+		config.Duration = types.NullDurationFrom(time.Minute)
+		config.AbortOnErrorRate = limit
+		return config
+	}
+	limit := &types.ErrorRateLimit{Threshold: 0.5, Window: types.Duration(30 * time.Second), MinRequests: 5}
+	runCtx, runCancel := context.WithCancel(context.Background())
+	defer runCancel()
+	_, run, wait := newTestEngine(t, runCtx, runner, nil, lib.Options{
+		Scenarios: lib.ScenarioConfigs{
+			"failing": scenario("failing", limit),
+			"ok":      scenario("ok", limit),
+		},
+	})
+	defer wait()
+
+	runResult := make(chan error, 1)
+	go func() {
+		runResult <- run()
+	}()
+
+	select {
+	case <-failingStopped:
+	case <-time.After(10 * time.Second):
+		require.Fail(t, "The failing scenario should have been stopped within 10 seconds")
+	}
+	iterations := atomic.LoadInt64(&okIterations)
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt64(&okIterations) > iterations
+	}, 5*time.Second, 10*time.Millisecond, "the other scenario should keep running")
+
+	runCancel()
+	assert.NoError(t, <-runResult)
+}
@@ -0,0 +1,90 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+// failureCount counts the failed checks and requests of a scenario with the
+// stopOnFailures option.
+type failureCount struct {
+	limit            types.FailureLimit
+	checks, requests int64
+	reached          bool
+}
+
+// failureLimiter counts the failures of the scenarios with their own
+// stopOnFailures option.
+type failureLimiter struct {
+	scenarios map[string]*failureCount
+}
+
+// newFailureLimiter returns nil if there aren't any failure limits.
+func newFailureLimiter(opts lib.Options) *failureLimiter {
+	l := &failureLimiter{scenarios: make(map[string]*failureCount)}
+	for name, conf := range opts.Scenarios {
+		if limit := conf.GetStopOnFailures(); limit != nil {
+			l.scenarios[name] = &failureCount{limit: *limit}
+		}
+	}
+	if len(l.scenarios) == 0 {
+		return nil
+	}
+	return l
+}
+
+// add counts the failures of the samples, and returns the names of the
+// scenarios which reached their limit with them, so each is stopped only once.
+func (l *failureLimiter) add(sampleContainers []stats.SampleContainer) []string {
+	var reached []string
+	for _, sc := range sampleContainers {
+		for _, sample := range sc.GetSamples() {
+			name := sample.Metric.Name
+			if (name != metrics.ChecksName && name != metrics.HTTPReqFailedName) || sample.Tags == nil {
+				continue
+			}
+			scenario, ok := sample.Tags.Get("scenario")
+			if !ok {
+				continue
+			}
+			c, ok := l.scenarios[scenario]
+			if !ok || c.reached {
+				continue
+			}
+			switch {
+			case name == metrics.ChecksName && sample.Value == 0:
+				c.checks++
+			case name == metrics.HTTPReqFailedName && sample.Value != 0:
+				c.requests++
+			}
+			if (c.limit.Checks.Valid && c.checks >= c.limit.Checks.Int64) ||
+				(c.limit.Requests.Valid && c.requests >= c.limit.Requests.Int64) {
+				c.reached = true
+				reached = append(reached, scenario)
+			}
+		}
+	}
+	return reached
+}
@@ -0,0 +1,87 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func TestFailureLimiter(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newFailureLimiter(lib.Options{}))
+
+	scenario := func(name string, limit *types.FailureLimit) executor.ConstantVUsConfig {
+		config := executor.NewConstantVUsConfig(name)
+		config.StopOnFailures = limit
+		return config
+	}
+	l := newFailureLimiter(lib.Options{Scenarios: lib.ScenarioConfigs{
+		"checkout": scenario("checkout", &types.FailureLimit{Checks: null.IntFrom(3), Requests: null.IntFrom(2)}),
+		"browse":   scenario("browse", &types.FailureLimit{Requests: null.IntFrom(2)}),
+		"other":    scenario("other", nil),
+	}})
+	require.NotNil(t, l)
+	assert.Len(t, l.scenarios, 2)
+
+	checks := stats.New(metrics.ChecksName, stats.Rate)
+	failed := stats.New(metrics.HTTPReqFailedName, stats.Rate)
+	sample := func(m *stats.Metric, scenario string, value float64) stats.SampleContainer {
+		return stats.Sample{
+			Metric: m, Time: time.Now(), Value: value,
+			Tags: stats.IntoSampleTags(&map[string]string{"scenario": scenario}),
+		}
+	}
+
+	assert.Empty(t, l.add([]stats.SampleContainer{
+		sample(checks, "checkout", 0),
+		sample(checks, "checkout", 1),
+		sample(checks, "checkout", 0),
+		sample(checks, "browse", 0),
+		sample(checks, "browse", 0),
+		sample(failed, "browse", 0),
+		sample(failed, "browse", 1),
+		sample(failed, "other", 1),
+		sample(failed, "other", 1),
+	}))
+	assert.Equal(t, int64(2), l.scenarios["checkout"].checks)
+	assert.Equal(t, int64(1), l.scenarios["browse"].requests)
+
+	assert.Equal(t, []string{"checkout", "browse"}, l.add([]stats.SampleContainer{
+		sample(checks, "checkout", 0),
+		sample(failed, "browse", 1),
+		sample(failed, "checkout", 1),
+	}))
+	assert.Empty(t, l.add([]stats.SampleContainer{
+		sample(checks, "checkout", 0),
+		sample(failed, "browse", 1),
+	}), "the limits are only reached once")
+}
@@ -24,6 +24,7 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -51,6 +52,10 @@ type ExecutionScheduler struct {
 	maxDuration     time.Duration // cached value derived from the execution plan
 	maxPossibleVUs  uint64        // cached value derived from the execution plan
 	state           *lib.ExecutionState
+
+	// cancel the contexts of the running scenarios, to stop them one by one
+	scenarioCancelsMx sync.Mutex
+	scenarioCancels   map[string]context.CancelFunc
 }
 
 // Check to see if we implement the lib.ExecutionScheduler interface
@@ -112,6 +117,7 @@ func NewExecutionScheduler(runner lib.Runner, logger *logrus.Logger) (*Execution
 		maxDuration:     maxDuration,
 		maxPossibleVUs:  maxPossibleVUs,
 		state:           executionState,
+		scenarioCancels: make(map[string]context.CancelFunc),
 	}, nil
 }
 
@@ -331,11 +337,14 @@ func (e *ExecutionScheduler) runExecutor(
 	)
 	executorLogger.Debugf("Starting executor")
 	err := executor.Run(runCtx, engineOut, builtinMetrics) // executor should handle context cancel itself
+	eventData := map[string]interface{}{"scenario": executorConfig.GetName()}
 	if err == nil {
 		executorLogger.Debugf("Executor finished successfully")
 	} else {
 		executorLogger.WithField("error", err).Errorf("Executor error")
+		eventData["error"] = err.Error()
 	}
+	e.state.Events.Emit(lib.EventScenarioFinished, eventData)
 	runResults <- err
 }
 
@@ -401,7 +410,12 @@ func (e *ExecutionScheduler) Run(
 	// This is for addressing test.abort().
 	execCtx := executor.Context(runSubCtx)
 	for _, exec := range e.executors {
-		go e.runExecutor(execCtx, runResults, engineOut, exec, builtinMetrics)
+		scenarioCtx, scenarioCancel := context.WithCancel(execCtx)
+		defer scenarioCancel()
+		e.scenarioCancelsMx.Lock()
+		e.scenarioCancels[exec.GetConfig().GetName()] = scenarioCancel
+		e.scenarioCancelsMx.Unlock()
+		go e.runExecutor(scenarioCtx, runResults, engineOut, exec, builtinMetrics)
 	}
 
 	// Wait for all executors to finish
@@ -435,6 +449,17 @@ func (e *ExecutionScheduler) Run(
 	return firstErr
 }
 
+// StopScenario stops the scenario with the given name by cancelling its
+// context, interrupting its iterations.
+func (e *ExecutionScheduler) StopScenario(name string) {
+	e.scenarioCancelsMx.Lock()
+	defer e.scenarioCancelsMx.Unlock()
+	if cancel, ok := e.scenarioCancels[name]; ok {
+		e.logger.WithField("scenario", name).Debug("Stopping the scenario...")
+		cancel()
+	}
+}
+
 // SetPaused pauses a test, if called with true. And if called with false, tries
 // to start/resume it. See the lib.ExecutionScheduler interface documentation of
 // the methods for the various caveats about its usage.
@@ -0,0 +1,129 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// timelineTrendPrecision is the significant digits the Trend values of the
+// timeline are rounded to, so every interval has a small sink.
+const timelineTrendPrecision = 3
+
+// timelineMetrics are the metrics in the timeline, besides the ones with
+// thresholds.
+var timelineMetrics = []string{ //nolint:gochecknoglobals
+	metrics.VUsName,
+	metrics.IterationsName,
+	metrics.IterationDurationName,
+	metrics.DroppedIterationsName,
+	metrics.ChecksName,
+	metrics.HTTPReqsName,
+	metrics.HTTPReqDurationName,
+	metrics.HTTPReqFailedName,
+	metrics.DataSentName,
+	metrics.DataReceivedName,
+}
+
+// timeline aggregates the samples of the key metrics over fixed intervals,
+// aligned to the Unix epoch, for a coarse time series in the summary.
+type timeline struct {
+	interval time.Duration
+	// sinks are the sinks of every interval of the metrics, by its start.
+	sinks map[string]map[int64]stats.Sink
+}
+
+// newTimeline returns nil if the summaryTimelineInterval option is 0.
+func newTimeline(opts lib.Options) *timeline {
+	interval := time.Duration(opts.SummaryTimelineInterval.Duration)
+	if interval <= 0 {
+		return nil
+	}
+	tl := &timeline{interval: interval, sinks: make(map[string]map[int64]stats.Sink)}
+	for _, name := range timelineMetrics {
+		tl.sinks[name] = make(map[int64]stats.Sink)
+	}
+	for name := range opts.Thresholds {
+		if !strings.Contains(name, "{") {
+			tl.sinks[name] = make(map[int64]stats.Sink)
+		}
+	}
+	return tl
+}
+
+// add adds the sample to the sink of its interval, if it's of a key metric.
+func (tl *timeline) add(sample stats.Sample) {
+	intervals, ok := tl.sinks[sample.Metric.Name]
+	if !ok || sample.Time.IsZero() {
+		return
+	}
+	start := sample.Time.Truncate(tl.interval).UnixNano()
+	sink, ok := intervals[start]
+	if !ok {
+		histogram, isHistogram := sample.Metric.Sink.(*stats.HistogramSink)
+		switch {
+		case sample.Metric.Type == stats.Trend:
+			sink = stats.NewTrendSink(timelineTrendPrecision)
+		case isHistogram:
+			sink, _ = stats.NewHistogramSink(histogram.Buckets)
+		default:
+			sink = stats.New(sample.Metric.Name, sample.Metric.Type).Sink
+		}
+		intervals[start] = sink
+	}
+	sink.Add(sample)
+}
+
+// export returns the intervals with samples of every metric, in order.
+func (tl *timeline) export() *lib.SummaryTimeline {
+	result := &lib.SummaryTimeline{
+		Interval: tl.interval,
+		Metrics:  make(map[string][]lib.SummaryTimelinePoint),
+	}
+	for name, intervals := range tl.sinks {
+		if len(intervals) == 0 {
+			continue
+		}
+		starts := make([]int64, 0, len(intervals))
+		for start := range intervals {
+			starts = append(starts, start)
+		}
+		sort.Slice(starts, func(i, j int) bool { return starts[i] < starts[j] })
+
+		points := make([]lib.SummaryTimelinePoint, len(starts))
+		for i, start := range starts {
+			sink := intervals[start]
+			sink.Calc()
+			points[i] = lib.SummaryTimelinePoint{
+				Time:   time.Unix(0, start),
+				Values: sink.Format(tl.interval),
+			}
+		}
+		result.Metrics[name] = points
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func TestTimeline(t *testing.T) {
+	t.Parallel()
+	assert.Nil(t, newTimeline(lib.Options{}))
+
+	tl := newTimeline(lib.Options{
+		SummaryTimelineInterval: types.NullDurationFrom(10 * time.Second),
+		Thresholds: map[string]stats.Thresholds{
+			"my_trend":               stats.NewThresholds([]string{"avg<100"}),
+			"http_req_duration{a:1}": stats.NewThresholds([]string{"avg<100"}),
+		},
+	})
+	require.NotNil(t, tl)
+	reqs := stats.New(metrics.HTTPReqsName, stats.Counter)
+	vus := stats.New(metrics.VUsName, stats.Gauge)
+	myTrend := stats.New("my_trend", stats.Trend, stats.Time)
+	other := stats.New("other", stats.Counter)
+
+	start := time.Unix(1600000000, 0)
+	for i := 0; i < 30; i++ {
+		now := start.Add(time.Duration(i) * time.Second)
+		tl.add(stats.Sample{Metric: reqs, Time: now, Value: 1})
+		tl.add(stats.Sample{Metric: vus, Time: now, Value: float64(i)})
+		tl.add(stats.Sample{Metric: other, Time: now, Value: 1})
+		if i < 15 {
+			tl.add(stats.Sample{Metric: myTrend, Time: now, Value: float64(100 * (i + 1))})
+		}
+	}
+
+	exported := tl.export()
+	assert.Equal(t, 10*time.Second, exported.Interval)
+	assert.NotContains(t, exported.Metrics, "other")
+	assert.NotContains(t, exported.Metrics, "http_req_duration{a:1}")
+	assert.NotContains(t, exported.Metrics, metrics.HTTPReqDurationName, "the metrics without samples are left out")
+
+	require.Len(t, exported.Metrics[metrics.HTTPReqsName], 3)
+	for i, point := range exported.Metrics[metrics.HTTPReqsName] {
+		assert.Equal(t, start.Add(time.Duration(i)*10*time.Second), point.Time)
+		assert.Equal(t, map[string]float64{"count": 10, "rate": 1}, point.Values)
+	}
+	require.Len(t, exported.Metrics[metrics.VUsName], 3)
+	assert.Equal(t, map[string]float64{"value": 29}, exported.Metrics[metrics.VUsName][2].Values)
+
+	trend := exported.Metrics["my_trend"]
+	require.Len(t, trend, 2, "the trend stopped halfway through")
+	assert.Equal(t, 100.0, trend[0].Values["min"])
+	assert.Equal(t, 1000.0, trend[0].Values["max"])
+	assert.Equal(t, 550.0, trend[0].Values["avg"])
+	assert.Equal(t, 1100.0, trend[1].Values["min"])
+	assert.Equal(t, 1500.0, trend[1].Values["max"])
+}
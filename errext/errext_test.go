@@ -74,4 +74,10 @@ func TestErrextHelpers(t *testing.T) {
 	assert.Equal(t, finalErrorMess.Error(), "woot: wrapper error: base error")
 	assertHasHint(t, finalErrorMess, "best hint (better hint (test hint))")
 	assertHasExitCode(t, finalErrorMess, testExitCode)
+
+	assert.Nil(t, WithExitCode(nil, testExitCode))
+	errWithNewExitCode := WithExitCode(finalErrorMess, ExitCode(27))
+	assertHasHint(t, errWithNewExitCode, "best hint (better hint (test hint))")
+	assertHasExitCode(t, errWithNewExitCode, ExitCode(27))
+	assert.Equal(t, errWithNewExitCode.Error(), "woot: wrapper error: base error")
 }
@@ -50,6 +50,15 @@ func WithExitCodeIfNone(err error, exitCode ExitCode) error {
 	return withExitCode{err, exitCode}
 }
 
+// WithExitCode attaches the given exit code to the error, replacing the exit
+// code it already had, if any. It won't do anything if the error is nil.
+func WithExitCode(err error, exitCode ExitCode) error {
+	if err == nil {
+		return nil
+	}
+	return withExitCode{err, exitCode}
+}
+
 type withExitCode struct {
 	error
 	exitCode ExitCode
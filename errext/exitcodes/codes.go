@@ -37,4 +37,7 @@ const (
 	CannotStartRESTAPI       errext.ExitCode = 106
 	ScriptException          errext.ExitCode = 107
 	ScriptAborted            errext.ExitCode = 108
+	HookFailed               errext.ExitCode = 109
+	InfrastructureError      errext.ExitCode = 110
+	OutputFailed             errext.ExitCode = 111
 )
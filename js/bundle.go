@@ -21,7 +21,6 @@
 package js
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
@@ -57,6 +56,9 @@ type Bundle struct {
 	registry          *metrics.Registry
 
 	exports map[string]goja.Callable
+	// the variables declared at the top of the main script, only with the
+	// --strict-globals flag, see globalsTracker
+	declaredGlobals []string
 }
 
 // A BundleInstance is a self-contained instance of a Bundle.
@@ -80,6 +82,7 @@ func NewBundle(
 		return nil, err
 	}
 
+	report := newInitReport(rtOpts, src.URL)
 	// Compile sources, both ES5 and ES6 are supported.
 	code := string(src.Data)
 	c := compiler.New(logger)
@@ -88,10 +91,11 @@ func NewBundle(
 		Strict:            true,
 		SourceMapLoader:   generateSourceMapLoader(logger, filesystems),
 	}
-	pgm, _, err := c.Compile(code, src.URL.String(), true)
+	pgm, compiled, err := c.Compile(code, src.URL.String(), true)
 	if err != nil {
 		return nil, err
 	}
+	report.compiled()
 	// Make a bundle, instantiate it into a throwaway VM to populate caches.
 	rt := goja.New()
 	bundle := Bundle{
@@ -105,9 +109,27 @@ func NewBundle(
 		exports:           make(map[string]goja.Callable),
 		registry:          registry,
 	}
+	if rtOpts.StrictGlobals.Bool {
+		bundle.declaredGlobals = declaredGlobals(compiled)
+	}
+	bundle.BaseInitContext.report = report
 	if err = bundle.instantiate(logger, rt, bundle.BaseInitContext, 0); err != nil {
 		return nil, err
 	}
+	bundle.BaseInitContext.report = nil
+	report.log(logger)
+
+	// The options can depend on the environment variables, so they are only
+	// read once the variables are known to be valid. The init code ran
+	// without the defaults of the envSchema, so it's run again with them.
+	env, err := applyEnvSchema(rt, rtOpts.Env)
+	if err != nil {
+		return nil, err
+	}
+	if env != nil {
+		rtOpts.Env = env
+		return NewBundle(logger, src, filesystems, rtOpts, registry)
+	}
 
 	err = bundle.getExports(logger, rt, true)
 	if err != nil {
@@ -135,16 +157,18 @@ func NewBundleFromArchive(
 		return nil, err
 	}
 
+	report := newInitReport(rtOpts, arc.FilenameURL)
 	c := compiler.New(logger)
 	c.Options = compiler.Options{
 		Strict:            true,
 		CompatibilityMode: compatMode,
 		SourceMapLoader:   generateSourceMapLoader(logger, arc.Filesystems),
 	}
-	pgm, _, err := c.Compile(string(arc.Data), arc.FilenameURL.String(), true)
+	pgm, compiled, err := c.Compile(string(arc.Data), arc.FilenameURL.String(), true)
 	if err != nil {
 		return nil, err
 	}
+	report.compiled()
 	rt := goja.New()
 	initctx := NewInitContext(logger, rt, c, compatMode,
 		new(context.Context), arc.Filesystems, arc.PwdURL)
@@ -158,6 +182,16 @@ func NewBundleFromArchive(
 		env[k] = v
 	}
 	rtOpts.Env = env
+	if len(arc.ScriptArgs) > 0 {
+		scriptArgs := make(map[string]string, len(arc.ScriptArgs)+len(rtOpts.ScriptArgs))
+		for k, v := range arc.ScriptArgs {
+			scriptArgs[k] = v
+		}
+		for k, v := range rtOpts.ScriptArgs {
+			scriptArgs[k] = v
+		}
+		rtOpts.ScriptArgs = scriptArgs
+	}
 
 	bundle := &Bundle{
 		Filename:          arc.FilenameURL,
@@ -170,10 +204,25 @@ func NewBundleFromArchive(
 		exports:           make(map[string]goja.Callable),
 		registry:          registry,
 	}
+	if rtOpts.StrictGlobals.Bool {
+		bundle.declaredGlobals = declaredGlobals(compiled)
+	}
 
+	bundle.BaseInitContext.report = report
 	if err = bundle.instantiate(logger, rt, bundle.BaseInitContext, 0); err != nil {
 		return nil, err
 	}
+	bundle.BaseInitContext.report = nil
+	report.log(logger)
+
+	env, err = applyEnvSchema(rt, rtOpts.Env)
+	if err != nil {
+		return nil, err
+	}
+	if env != nil {
+		rtOpts.Env = env
+		return NewBundleFromArchive(logger, arc, rtOpts, registry)
+	}
 
 	// Grab exported objects, but avoid overwriting options, which would
 	// be initialized from the metadata.json at this point.
@@ -202,6 +251,12 @@ func (b *Bundle) makeArchive() *lib.Archive {
 	for k, v := range b.RuntimeOptions.Env {
 		arc.Env[k] = v
 	}
+	if len(b.RuntimeOptions.ScriptArgs) > 0 {
+		arc.ScriptArgs = make(map[string]string, len(b.RuntimeOptions.ScriptArgs))
+		for k, v := range b.RuntimeOptions.ScriptArgs {
+			arc.ScriptArgs[k] = v
+		}
+	}
 
 	return arc
 }
@@ -216,7 +271,7 @@ func (b *Bundle) getExports(logger logrus.FieldLogger, rt *goja.Runtime, options
 
 	for _, k := range exports.Keys() {
 		v := exports.Get(k)
-		if fn, ok := goja.AssertFunction(v); ok && k != consts.Options {
+		if fn, ok := goja.AssertFunction(v); ok && k != consts.Options && k != consts.EnvSchema {
 			b.exports[k] = fn
 			continue
 		}
@@ -229,13 +284,13 @@ func (b *Bundle) getExports(logger logrus.FieldLogger, rt *goja.Runtime, options
 			if err != nil {
 				return err
 			}
-			dec := json.NewDecoder(bytes.NewReader(data))
-			dec.DisallowUnknownFields()
-			if err := dec.Decode(&b.Options); err != nil {
-				if uerr := json.Unmarshal(data, &b.Options); uerr != nil {
-					return uerr
-				}
-				logger.WithError(err).Warn("There were unknown fields in the options exported in the script")
+			if err = json.Unmarshal(data, &b.Options); err != nil {
+				return err
+			}
+			err = lib.CheckUnknownOptions(logger, data, b.Options, "the options exported in the script",
+				b.RuntimeOptions.StrictOptions.Bool)
+			if err != nil {
+				return err
 			}
 		case consts.SetupFn:
 			return errors.New("exported 'setup' must be a function")
@@ -316,17 +371,18 @@ func (b *Bundle) instantiate(logger logrus.FieldLogger, rt *goja.Runtime, init *
 	rt.Set("__VU", vuID)
 	_ = rt.Set("console", newConsole(logger))
 
-	if init.compatibilityMode == lib.CompatibilityModeExtended {
+	if init.compatibilityMode != lib.CompatibilityModeBase {
 		rt.Set("global", rt.GlobalObject())
 	}
 
 	// TODO: get rid of the unused ctxPtr, use a real external context (so we
 	// can interrupt), build the common.InitEnvironment earlier and reuse it
 	initenv := &common.InitEnvironment{
-		Logger:      logger,
-		FileSystems: init.filesystems,
-		CWD:         init.pwd,
-		Registry:    b.registry,
+		Logger:         logger,
+		FileSystems:    init.filesystems,
+		CWD:            init.pwd,
+		Registry:       b.registry,
+		RuntimeOptions: b.RuntimeOptions,
 	}
 	init.moduleVUImpl.initEnv = initenv
 	ctx := common.WithInitEnv(context.Background(), initenv)
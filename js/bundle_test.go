@@ -22,6 +22,7 @@ package js
 
 import (
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/url"
@@ -39,6 +40,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/fsext"
@@ -146,6 +149,19 @@ func TestNewBundle(t *testing.T) {
 
 			assert.NoError(t, err)
 		})
+		t.Run("ExperimentalEnhanced", func(t *testing.T) {
+			t.Parallel()
+			rtOpts := lib.RuntimeOptions{
+				CompatibilityMode: null.StringFrom(lib.CompatibilityModeExperimentalEnhanced.String()),
+			}
+			b, err := getSimpleBundle(t, "/script.js", `
+				import { sleep } from "k6";
+				export const options = { vus: global.__ENV?.VUS?.length || 2 };
+				export default function() { sleep(0); };
+			`, rtOpts)
+			require.NoError(t, err)
+			assert.Equal(t, null.IntFrom(2), b.Options.VUs)
+		})
 		t.Run("Base/ok/Minimal", func(t *testing.T) {
 			t.Parallel()
 			rtOpts := lib.RuntimeOptions{
@@ -165,7 +181,7 @@ func TestNewBundle(t *testing.T) {
 			}{
 				{
 					"InvalidCompat", "es1", `export default function() {};`,
-					`invalid compatibility mode "es1". Use: "extended", "base"`,
+					`invalid compatibility mode "es1". Use: "extended", "base", "experimental_enhanced"`,
 				},
 				// ES2015 modules are not supported
 				{
@@ -483,6 +499,19 @@ func TestNewBundle(t *testing.T) {
 			require.Contains(t, entries[0].Message, "There were unknown fields")
 			require.Contains(t, entries[0].Data["error"].(error).Error(), "unknown field \"something\"")
 		})
+		t.Run("Unknown field with strict", func(t *testing.T) {
+			t.Parallel()
+			_, err := getSimpleBundle(t, "/script.js", `
+				export let options = {
+					vus: 10,
+					duraton: "10s",
+				};
+				export default function() {};
+			`, lib.RuntimeOptions{StrictOptions: null.BoolFrom(true)})
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "there were unknown fields in the options exported in the script")
+			assert.Contains(t, err.Error(), "unknown field \"duraton\"")
+		})
 	})
 }
 
@@ -878,6 +907,129 @@ func TestBundleEnv(t *testing.T) {
 	}
 }
 
+func TestBundleEnvSchema(t *testing.T) {
+	t.Parallel()
+	data := `
+		export const envSchema = {
+			VUS: { type: "integer", required: true, description: "the number of VUs" },
+			BASE_URL: { type: "string", default: "https://test.k6.io" },
+			SLEEP: { type: "number", default: 0.5 },
+			DEBUG: { type: "boolean", default: false },
+		};
+		export const options = { vus: parseInt(__ENV.VUS), tags: { base: __ENV.BASE_URL } };
+		export default function() {
+			if (__ENV.DEBUG !== "false") { throw new Error("Invalid DEBUG: " + __ENV.DEBUG); }
+		}
+	`
+
+	t.Run("valid", func(t *testing.T) {
+		t.Parallel()
+		rtOpts := lib.RuntimeOptions{Env: map[string]string{"VUS": "5", "DEBUG": "false"}}
+		b1, err := getSimpleBundle(t, "/script.js", data, rtOpts)
+		require.NoError(t, err)
+		assert.Equal(t, null.IntFrom(5), b1.Options.VUs)
+		assert.Equal(t, "https://test.k6.io", b1.Options.RunTags.CloneTags()["base"])
+		assert.Equal(t, "https://test.k6.io", b1.RuntimeOptions.Env["BASE_URL"])
+		assert.Equal(t, "0.5", b1.RuntimeOptions.Env["SLEEP"])
+
+		logger := testutils.NewLogger(t)
+		b2, err := NewBundleFromArchive(logger, b1.makeArchive(), lib.RuntimeOptions{
+			Env: map[string]string{"BASE_URL": "https://example.com"},
+		}, metrics.NewRegistry())
+		require.NoError(t, err)
+		assert.Equal(t, "https://example.com", b2.RuntimeOptions.Env["BASE_URL"])
+		assert.Equal(t, "5", b2.RuntimeOptions.Env["VUS"])
+
+		bi, err := b2.Instantiate(logger, 0, newModuleVUImpl())
+		require.NoError(t, err)
+		_, err = bi.exports[consts.DefaultFn](goja.Undefined())
+		assert.NoError(t, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		t.Parallel()
+		rtOpts := lib.RuntimeOptions{Env: map[string]string{"DEBUG": "maybe"}}
+		_, err := getSimpleBundle(t, "/script.js", data, rtOpts)
+		require.Error(t, err)
+		var ecerr errext.HasExitCode
+		require.True(t, errors.As(err, &ecerr))
+		assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+		assert.Contains(t, err.Error(), `DEBUG should be a boolean, but it's "maybe"`)
+		assert.Contains(t, err.Error(), "VUS is required (the number of VUs)")
+	})
+
+	schemas := map[string]string{
+		"unknown type":     `{ A: { type: "date" } }`,
+		"invalid default":  `{ A: { type: "integer", default: "ten" } }`,
+		"unknown field":    `{ A: { type: "string", optional: true } }`,
+		"not an object":    `function() {}`,
+		"no declaration":   `{ A: null }`,
+		"object default":   `{ A: { default: {} } }`,
+		"not finite value": `{ A: { type: "number", default: "Infinity" } }`,
+	}
+	for name, schema := range schemas {
+		schema := schema
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := getSimpleBundle(t, "/script.js", fmt.Sprintf(
+				"export const envSchema = %s; export default function() {}", schema))
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestBundleVerboseInit(t *testing.T) {
+	t.Parallel()
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/lib.js", []byte(`
+		import { sleep } from "k6";
+		import { slow } from "./slow.js";
+		export let lib = slow;
+	`), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/slow.js", []byte(`
+		var start = Date.now();
+		while (Date.now() - start < 50) {}
+		export let slow = true;
+	`), 0o644))
+
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	hook := testutils.SimpleLogrusHook{HookedLevels: []logrus.Level{logrus.InfoLevel}}
+	logger.AddHook(&hook)
+
+	_, err := getSimpleBundle(t, "/script.js", `
+		import { lib } from "./lib.js";
+		export default function() {};
+	`, fs, logger, lib.RuntimeOptions{VerboseInit: null.BoolFrom(true)})
+	require.NoError(t, err)
+
+	entries := hook.Drain()
+	modules := make([]string, len(entries))
+	stats := make(map[string]logrus.Fields, len(entries))
+	for i, e := range entries {
+		assert.Equal(t, "Module init time", e.Message)
+		modules[i] = e.Data["module"].(string)
+		stats[modules[i]] = e.Data
+	}
+	// the modules are sorted by the time their own code took to evaluate
+	require.Len(t, modules, 4)
+	assert.Equal(t, "file:///slow.js", modules[0])
+	assert.ElementsMatch(t, []string{"file:///slow.js", "file:///lib.js", "k6", "file:///script.js"}, modules)
+
+	// Date.now() has a millisecond resolution, so the loop can be shorter
+	minSlow := int64(40 * time.Millisecond)
+	assert.GreaterOrEqual(t, int64(stats["file:///slow.js"]["evaluateSelf"].(time.Duration)), minSlow)
+	assert.GreaterOrEqual(t, int64(stats["file:///lib.js"]["evaluate"].(time.Duration)), minSlow)
+	assert.Less(t, int64(stats["file:///lib.js"]["evaluateSelf"].(time.Duration)), minSlow)
+	assert.Positive(t, stats["file:///lib.js"]["allocatedBytes"])
+
+	// the report is only for the first instantiation
+	hook.Drain()
+	_, err = getSimpleBundle(t, "/script.js", `export default function() {};`, fs, logger)
+	require.NoError(t, err)
+	assert.Empty(t, hook.Drain())
+}
+
 func TestBundleNotSharable(t *testing.T) {
 	t.Parallel()
 	data := `
@@ -0,0 +1,155 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package common
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/dop251/goja"
+)
+
+// AbortSignal stops the requests of k6/http and the connections of k6/ws it's
+// passed to with the signal param, like the AbortSignal of the web platform,
+// when its context is done. In JS it has the aborted and reason properties and
+// the throwIfAborted() method. Its objects are created with NewAbortSignal and
+// the Go value can be gotten back with their Export().
+type AbortSignal struct {
+	rt     *goja.Runtime
+	ctx    context.Context
+	cancel context.CancelFunc
+	reason goja.Value
+}
+
+var _ goja.DynamicObject = &AbortSignal{}
+
+// abortSignalKeys are the properties of the JS objects of the AbortSignals.
+//nolint:gochecknoglobals
+var abortSignalKeys = []string{"aborted", "reason", "throwIfAborted"}
+
+// NewAbortSignal returns the JS object of a signal which is aborted when ctx
+// is done, with the reason if it's set, or the error of ctx otherwise. cancel
+// is called by Abort, it can be nil if the signal is only aborted by ctx.
+func NewAbortSignal(
+	rt *goja.Runtime, ctx context.Context, cancel context.CancelFunc, reason string,
+) (*goja.Object, *AbortSignal) {
+	s := &AbortSignal{rt: rt, ctx: ctx, cancel: cancel}
+	if reason != "" {
+		s.reason = rt.ToValue(reason)
+	}
+	return rt.NewDynamicObject(s), s
+}
+
+// NewTimeoutAbortSignal returns the JS object of a signal which is aborted
+// after the timeout, like AbortSignal.timeout() of the web platform.
+func NewTimeoutAbortSignal(rt *goja.Runtime, timeout time.Duration) *goja.Object {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	o, _ := NewAbortSignal(rt, ctx, cancel, "signal timed out")
+	return o
+}
+
+// Context returns the context which is done when the signal is aborted.
+func (s *AbortSignal) Context() context.Context {
+	return s.ctx
+}
+
+// Aborted returns whether the signal was aborted.
+func (s *AbortSignal) Aborted() bool {
+	return s.ctx.Err() != nil
+}
+
+// Abort aborts the signal with the reason, if it isn't already. It must be
+// called on the event loop of the runtime of the signal.
+func (s *AbortSignal) Abort(reason goja.Value) {
+	if s.Aborted() || s.cancel == nil {
+		return
+	}
+	if reason != nil && !goja.IsUndefined(reason) {
+		s.reason = reason
+	} else if s.reason == nil {
+		s.reason = s.rt.ToValue("signal is aborted without reason")
+	}
+	s.cancel()
+}
+
+// Reason returns the reason the signal was aborted with, or undefined if it
+// wasn't aborted.
+func (s *AbortSignal) Reason() goja.Value {
+	if !s.Aborted() {
+		return goja.Undefined()
+	}
+	if s.reason != nil {
+		return s.reason
+	}
+	return s.rt.ToValue(s.ctx.Err().Error())
+}
+
+func (s *AbortSignal) throwIfAborted() {
+	if s.Aborted() {
+		Throw(s.rt, fmt.Errorf("the operation was aborted: %s", s.Reason().String()))
+	}
+}
+
+// Get implements goja.DynamicObject.
+func (s *AbortSignal) Get(key string) goja.Value {
+	switch key {
+	case "aborted":
+		return s.rt.ToValue(s.Aborted())
+	case "reason":
+		return s.Reason()
+	case "throwIfAborted":
+		return s.rt.ToValue(s.throwIfAborted)
+	default:
+		return nil
+	}
+}
+
+// Set implements goja.DynamicObject, the signals are read-only.
+func (s *AbortSignal) Set(string, goja.Value) bool {
+	return false
+}
+
+// Has implements goja.DynamicObject.
+func (s *AbortSignal) Has(key string) bool {
+	return s.Get(key) != nil
+}
+
+// Delete implements goja.DynamicObject, the signals are read-only.
+func (s *AbortSignal) Delete(string) bool {
+	return false
+}
+
+// Keys implements goja.DynamicObject.
+func (s *AbortSignal) Keys() []string {
+	return abortSignalKeys
+}
+
+// GetAbortSignal returns the AbortSignal of the JS value v, or an error if it
+// isn't one.
+func GetAbortSignal(v goja.Value) (*AbortSignal, error) {
+	if v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		if s, ok := v.Export().(*AbortSignal); ok {
+			return s, nil
+		}
+	}
+	return nil, fmt.Errorf("the signal should be an AbortSignal, e.g. of an AbortController of the k6 module")
+}
@@ -26,6 +26,8 @@ import (
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
+
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/metrics"
 )
 
@@ -36,7 +38,10 @@ type InitEnvironment struct {
 	FileSystems map[string]afero.Fs
 	CWD         *url.URL
 	Registry    *metrics.Registry
-	// TODO: add RuntimeOptions and other properties, goja sources, etc.
+	// RuntimeOptions are the options of the test run that aren't part of
+	// the script options, like the --allow-exec flag.
+	RuntimeOptions lib.RuntimeOptions
+	// TODO: add other properties, goja sources, etc.
 	// ideally, we should leave this as the only data structure necessary for
 	// executing the init context for all JS modules
 }
@@ -48,6 +48,17 @@ func (i *InterruptError) ExitCode() errext.ExitCode {
 // AbortTest is the reason emitted when a test script calls test.abort()
 const AbortTest = "test aborted"
 
+// SkipIterationError is the value the runtime is interrupted with when the
+// script skips the current iteration with test.skipIteration().
+type SkipIterationError struct {
+	Reason string
+}
+
+// Error returns the reason the iteration was skipped for.
+func (s *SkipIterationError) Error() string {
+	return "iteration skipped: " + s.Reason
+}
+
 // IsInterruptError returns true if err is *InterruptError.
 func IsInterruptError(err error) bool {
 	if err == nil {
@@ -241,8 +241,18 @@ func (c *Compiler) compileImpl(
 		c.logger.WithError(state.srcMapError).Warnf("Couldn't load source map for %s", filename)
 		ast, err = parser.ParseFile(nil, filename, code, 0, parser.WithDisableSourceMaps)
 	}
+	if err != nil && compatibilityMode == lib.CompatibilityModeExperimentalEnhanced {
+		// The lines are kept by the rewrite, so the source map still applies.
+		if rewritten, ok := esmToCommonJS(src); ok {
+			pgm, rewrittenCode, rerr := c.compileImpl(rewritten, filename, main, lib.CompatibilityModeBase, srcMap)
+			if rerr == nil {
+				return pgm, rewrittenCode, nil
+			}
+			c.logger.WithError(rerr).Debugf("Couldn't run %s without Babel", filename)
+		}
+	}
 	if err != nil {
-		if compatibilityMode == lib.CompatibilityModeExtended {
+		if compatibilityMode != lib.CompatibilityModeBase {
 			code, state.srcMap, err = c.Transform(src, filename, state.srcMap)
 			if err != nil {
 				return nil, code, err
@@ -0,0 +1,528 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"strings"
+)
+
+// esmPrologue marks the exports of a rewritten module as the ones of an ES
+// module, like Babel does, so their default export is imported as it is.
+const esmPrologue = `"use strict";Object.defineProperty(exports, "__esModule", { value: true });`
+
+// esmInteropDefault returns the default export of a module, which is the
+// whole module for the CommonJS and native k6 modules.
+const esmInteropDefault = "function _k6InteropDefault(m) { return m && m.__esModule ? m.default : m; }"
+
+// esmRewriter rewrites the import and export statements of an ES module to
+// CommonJS, without parsing the rest of the code, which is only scanned for
+// its strings, comments, regular expressions and nesting.
+type esmRewriter struct {
+	src string
+	pos int
+	out strings.Builder
+
+	depth int
+	// templates are the depths of the substitutions of the template
+	// literals being scanned, so their end resumes the template.
+	templates []int
+	// last is the last significant character, and lastWord the last word if
+	// it was one, to tell regular expressions from divisions.
+	last     byte
+	lastWord string
+
+	// declaring is set while the declarators of an exported variable
+	// statement are scanned, and nextIsName when the next word is one.
+	declaring  bool
+	nextIsName bool
+
+	exports     []string
+	usesInterop bool
+	isModule    bool
+}
+
+// esmToCommonJS rewrites the import and export statements of the ES module
+// src to CommonJS, so it can be run by goja without Babel. The lines of the
+// code are kept, so the positions in errors are still right. It returns false
+// if src doesn't have any, or has some which aren't supported, like
+// re-exports and exported classes, which are left to Babel.
+func esmToCommonJS(src string) (string, bool) {
+	r := &esmRewriter{src: src}
+	if !r.rewrite() || !r.isModule {
+		return "", false
+	}
+	var result strings.Builder
+	result.WriteString(esmPrologue)
+	result.WriteString(r.out.String())
+	result.WriteString("\n")
+	result.WriteString(strings.Join(r.exports, " "))
+	if r.usesInterop {
+		result.WriteString(esmInteropDefault)
+	}
+	return result.String(), true
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\v' || c == '\f'
+}
+
+// regexpAllowed returns whether a / at the current position starts a regular
+// expression, rather than being a division.
+func (r *esmRewriter) regexpAllowed() bool {
+	if r.lastWord != "" {
+		switch r.lastWord {
+		case "return", "typeof", "instanceof", "in", "of", "new", "delete", "void", "throw",
+			"case", "do", "else", "yield", "await":
+			return true
+		}
+		return false
+	}
+	return r.last == 0 || strings.IndexByte("(,=:[!&|?{};+-*%<>~^}", r.last) >= 0
+}
+
+//nolint:funlen,gocognit,cyclop
+func (r *esmRewriter) rewrite() bool {
+	for r.pos < len(r.src) {
+		c := r.src[r.pos]
+		switch {
+		case isSpace(c):
+			if c == '\n' && r.declaring && r.depth == 0 &&
+				strings.IndexByte(",=+-*/%&|^!?:<>(", r.last) < 0 {
+				r.declaring = false
+			}
+			r.copy(1)
+		case strings.HasPrefix(r.src[r.pos:], "//"):
+			end := strings.IndexByte(r.src[r.pos:], '\n')
+			if end < 0 {
+				end = len(r.src) - r.pos
+			}
+			r.copy(end)
+		case strings.HasPrefix(r.src[r.pos:], "/*"):
+			end := strings.Index(r.src[r.pos+2:], "*/")
+			if end < 0 {
+				return false
+			}
+			r.copy(end + 4)
+		case c == '\'' || c == '"':
+			end := r.stringEnd()
+			if end < 0 {
+				return false
+			}
+			r.copy(end - r.pos)
+			r.significant(c, "")
+		case c == '`':
+			r.copy(1)
+			if !r.copyTemplate() {
+				return false
+			}
+		case c == '/' && r.regexpAllowed():
+			r.copyRegexp()
+			r.significant('/', "")
+		case isWordChar(c):
+			start := r.pos
+			for r.pos < len(r.src) && isWordChar(r.src[r.pos]) {
+				r.pos++
+			}
+			word := r.src[start:r.pos]
+			r.pos = start
+			if r.depth == 0 && r.last != '.' && (word == "import" || word == "export") {
+				r.pos += len(word)
+				var ok bool
+				if word == "import" {
+					ok = r.rewriteImport(start)
+				} else {
+					ok = r.rewriteExport()
+				}
+				if !ok {
+					return false
+				}
+				continue
+			}
+			if r.declaring && r.nextIsName && r.depth == 0 {
+				r.exports = append(r.exports, "exports."+word+" = "+word+";")
+				r.nextIsName = false
+			}
+			r.copy(len(word))
+			r.significant(0, word)
+		default:
+			switch c {
+			case '{', '(', '[':
+				r.depth++
+			case '}', ')', ']':
+				r.depth--
+				if r.depth < 0 {
+					return false
+				}
+				if c == '}' && len(r.templates) > 0 && r.templates[len(r.templates)-1] == r.depth {
+					r.templates = r.templates[:len(r.templates)-1]
+					r.copy(1)
+					if !r.copyTemplate() {
+						return false
+					}
+					continue
+				}
+			case ',':
+				r.nextIsName = r.declaring && r.depth == 0
+			case ';':
+				if r.depth == 0 {
+					r.declaring = false
+				}
+			}
+			r.copy(1)
+			r.significant(c, "")
+		}
+	}
+	return r.depth == 0 && len(r.templates) == 0
+}
+
+func (r *esmRewriter) significant(c byte, word string) {
+	r.last, r.lastWord = c, word
+	if word != "" {
+		r.last = 'a'
+	}
+}
+
+func (r *esmRewriter) copy(n int) {
+	r.out.WriteString(r.src[r.pos : r.pos+n])
+	r.pos += n
+}
+
+// stringEnd returns the end of the string literal at the position, or -1
+// if it doesn't end.
+func (r *esmRewriter) stringEnd() int {
+	quote := r.src[r.pos]
+	for i := r.pos + 1; i < len(r.src); i++ {
+		switch r.src[i] {
+		case '\\':
+			i++
+		case '\n':
+			return -1
+		case quote:
+			return i + 1
+		}
+	}
+	return -1
+}
+
+// copyTemplate copies the rest of a template literal, up to its end or the
+// start of a substitution, whose code is then scanned as any other.
+func (r *esmRewriter) copyTemplate() bool {
+	for i := r.pos; i < len(r.src); i++ {
+		switch r.src[i] {
+		case '\\':
+			i++
+		case '`':
+			r.copy(i + 1 - r.pos)
+			r.significant('`', "")
+			return true
+		case '$':
+			if i+1 < len(r.src) && r.src[i+1] == '{' {
+				r.copy(i + 2 - r.pos)
+				r.templates = append(r.templates, r.depth)
+				r.depth++
+				r.significant('{', "")
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// copyRegexp copies the regular expression at the position, or only the /
+// if it ends with the line, since then it's a division after all.
+func (r *esmRewriter) copyRegexp() {
+	inClass := false
+	for i := r.pos + 1; i < len(r.src); i++ {
+		switch c := r.src[i]; {
+		case c == '\\':
+			i++
+		case c == '\n':
+			r.copy(1)
+			return
+		case c == '[':
+			inClass = true
+		case c == ']':
+			inClass = false
+		case c == '/' && !inClass:
+			i++
+			for i < len(r.src) && isWordChar(r.src[i]) {
+				i++
+			}
+			r.copy(i - r.pos)
+			return
+		}
+	}
+	r.copy(1)
+}
+
+// skipSpace skips the whitespace at the position and returns the newlines in
+// it, which are kept in the output.
+func (r *esmRewriter) skipSpace() string {
+	var newlines strings.Builder
+	for r.pos < len(r.src) && isSpace(r.src[r.pos]) {
+		if r.src[r.pos] == '\n' {
+			newlines.WriteByte('\n')
+		}
+		r.pos++
+	}
+	return newlines.String()
+}
+
+// readWord returns the word at the position, if there is one.
+func (r *esmRewriter) readWord() string {
+	start := r.pos
+	for r.pos < len(r.src) && isWordChar(r.src[r.pos]) {
+		r.pos++
+	}
+	return r.src[start:r.pos]
+}
+
+func (r *esmRewriter) peekWord() string {
+	pos := r.pos
+	word := r.readWord()
+	r.pos = pos
+	return word
+}
+
+// readSpecifiers reads the list in the braces of an import or an export,
+// e.g. { a, b as c }, and returns its names and their local names.
+func (r *esmRewriter) readSpecifiers() (names, locals []string, newlines string, ok bool) {
+	r.pos++ // {
+	for {
+		newlines += r.skipSpace()
+		if r.pos < len(r.src) && r.src[r.pos] == '}' {
+			r.pos++
+			return names, locals, newlines, true
+		}
+		name := r.readWord()
+		if name == "" {
+			return nil, nil, "", false
+		}
+		local := name
+		newlines += r.skipSpace()
+		if r.peekWord() == "as" {
+			r.readWord()
+			newlines += r.skipSpace()
+			if local = r.readWord(); local == "" {
+				return nil, nil, "", false
+			}
+			newlines += r.skipSpace()
+		}
+		names, locals = append(names, name), append(locals, local)
+		if r.pos < len(r.src) && r.src[r.pos] == ',' {
+			r.pos++
+		}
+	}
+}
+
+// readModuleName reads the quoted name of an imported module.
+func (r *esmRewriter) readModuleName() (string, bool) {
+	if r.pos >= len(r.src) || (r.src[r.pos] != '"' && r.src[r.pos] != '\'') {
+		return "", false
+	}
+	start, end := r.pos, r.stringEnd()
+	if end < 0 {
+		return "", false
+	}
+	r.pos = end
+	return r.src[start:end], true
+}
+
+// skipSemicolon skips the semicolon which ends a statement, if it has one.
+func (r *esmRewriter) skipSemicolon() string {
+	pos := r.pos
+	newlines := r.skipSpace()
+	if r.pos < len(r.src) && r.src[r.pos] == ';' {
+		r.pos++
+		return newlines
+	}
+	r.pos = pos
+	return ""
+}
+
+// rewriteImport rewrites the import statement after the import keyword at
+// start to the declarations of its bindings.
+//nolint:funlen,cyclop
+func (r *esmRewriter) rewriteImport(start int) bool {
+	if r.pos < len(r.src) && (r.src[r.pos] == '(' || r.src[r.pos] == '.') {
+		// a dynamic import or import.meta
+		r.pos = start
+		return false
+	}
+	r.isModule = true
+	newlines := r.skipSpace()
+	var defaultName, namespace string
+	var names, locals []string
+	if r.pos < len(r.src) && r.src[r.pos] != '"' && r.src[r.pos] != '\'' {
+		if r.pos < len(r.src) && isWordChar(r.src[r.pos]) {
+			if defaultName = r.readWord(); defaultName == "type" {
+				return false // a default import named type, left to Babel
+			}
+			newlines += r.skipSpace()
+			if r.pos < len(r.src) && r.src[r.pos] == ',' {
+				r.pos++
+				newlines += r.skipSpace()
+			}
+		}
+		if r.pos < len(r.src) && r.src[r.pos] == '*' {
+			r.pos++
+			newlines += r.skipSpace()
+			if r.readWord() != "as" {
+				return false
+			}
+			newlines += r.skipSpace()
+			if namespace = r.readWord(); namespace == "" {
+				return false
+			}
+		} else if r.pos < len(r.src) && r.src[r.pos] == '{' {
+			var specNewlines string
+			var ok bool
+			if names, locals, specNewlines, ok = r.readSpecifiers(); !ok {
+				return false
+			}
+			newlines += specNewlines
+		}
+		newlines += r.skipSpace()
+		if r.readWord() != "from" {
+			return false
+		}
+		newlines += r.skipSpace()
+	}
+	module, ok := r.readModuleName()
+	if !ok {
+		return false
+	}
+	newlines += r.skipSemicolon()
+
+	var declarations []string
+	require := "require(" + module + ")"
+	if defaultName != "" {
+		declarations = append(declarations, defaultName+" = _k6InteropDefault("+require+")")
+		r.usesInterop = true
+	}
+	if namespace != "" {
+		declarations = append(declarations, namespace+" = "+require)
+	}
+	for i, name := range names {
+		if name == "default" {
+			declarations = append(declarations, locals[i]+" = _k6InteropDefault("+require+")")
+			r.usesInterop = true
+		} else {
+			declarations = append(declarations, locals[i]+" = "+require+"."+name)
+		}
+	}
+	if len(declarations) == 0 {
+		r.out.WriteString(require + ";")
+	} else {
+		r.out.WriteString("var " + strings.Join(declarations, ", ") + ";")
+	}
+	r.out.WriteString(newlines)
+	r.significant(';', "")
+	return true
+}
+
+// rewriteExport rewrites the export statement after the export keyword.
+// The exported declarations are kept as they are, and assigned to the
+// exports at the end of the module.
+//nolint:funlen,cyclop
+func (r *esmRewriter) rewriteExport() bool {
+	r.isModule = true
+	r.out.WriteString(r.skipSpace())
+	switch word := r.peekWord(); word {
+	case "default":
+		r.readWord()
+		r.out.WriteString(r.skipSpace())
+		switch r.peekWord() {
+		case "class", "async":
+			return false
+		case "function":
+			pos := r.pos
+			r.readWord()
+			r.skipSpace()
+			if r.pos < len(r.src) && r.src[r.pos] == '*' {
+				r.pos++
+				r.skipSpace()
+			}
+			name := r.readWord()
+			r.pos = pos
+			if name != "" {
+				r.out.WriteString("exports.default = " + name + "; ")
+				break
+			}
+			r.out.WriteString("exports.default = ")
+		default:
+			r.out.WriteString("exports.default = ")
+		}
+		r.significant('=', "")
+	case "function":
+		pos := r.pos
+		r.readWord()
+		r.skipSpace()
+		if r.pos < len(r.src) && r.src[r.pos] == '*' {
+			r.pos++
+			r.skipSpace()
+		}
+		name := r.readWord()
+		r.pos = pos
+		if name == "" {
+			return false
+		}
+		r.exports = append(r.exports, "exports."+name+" = "+name+";")
+		r.significant(';', "")
+	case "var", "let", "const":
+		r.copy(len(word))
+		for r.pos < len(r.src) && isSpace(r.src[r.pos]) {
+			r.copy(1)
+		}
+		if r.pos >= len(r.src) || !isWordChar(r.src[r.pos]) {
+			return false // destructuring
+		}
+		r.declaring, r.nextIsName = true, true
+		r.significant(0, word)
+	case "":
+		if r.pos >= len(r.src) || r.src[r.pos] != '{' {
+			return false // export * from
+		}
+		names, locals, newlines, ok := r.readSpecifiers()
+		if !ok {
+			return false
+		}
+		pos := r.pos
+		r.skipSpace()
+		if r.peekWord() == "from" {
+			return false // a re-export
+		}
+		r.pos = pos
+		newlines += r.skipSemicolon()
+		for i, name := range names {
+			r.exports = append(r.exports, "exports."+locals[i]+" = "+name+";")
+		}
+		r.out.WriteString(newlines)
+		r.significant(';', "")
+	default:
+		return false
+	}
+	return true
+}
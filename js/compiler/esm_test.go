@@ -0,0 +1,197 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package compiler
+
+import (
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+)
+
+func TestESMToCommonJS(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name, src, expected string
+	}{
+		{
+			name: "imports",
+			src: `import http from "k6/http";
+import { sleep, check as c } from 'k6';
+import * as metrics from "k6/metrics";
+import def, {
+	named,
+} from "./lib.js"
+import "./side-effect.js";`,
+			expected: esmPrologue + `var http = _k6InteropDefault(require("k6/http"));
+var sleep = require('k6').sleep, c = require('k6').check;
+var metrics = require("k6/metrics");
+var def = _k6InteropDefault(require("./lib.js")), named = require("./lib.js").named;
+
+
+require("./side-effect.js");
+` + esmInteropDefault,
+		},
+		{
+			name: "exports",
+			src: `export const options = {
+	vus: 1,
+}, other = [1, 2];
+export let a = 1
+export function setup() {}
+export default function () {}
+const b = 2;
+export { b, b as c };`,
+			expected: esmPrologue + `const options = {
+	vus: 1,
+}, other = [1, 2];
+let a = 1
+function setup() {}
+exports.default = function () {}
+const b = 2;
+
+exports.options = options; exports.other = other; exports.a = a; exports.setup = setup; exports.b = b; exports.c = b;`,
+		},
+		{
+			name: "named default function",
+			src:  `export default function main() {}`,
+			expected: esmPrologue + `exports.default = main; function main() {}
+`,
+		},
+		{
+			name: "default expression",
+			src:  `export default { a: "export default" };`,
+			expected: esmPrologue + `exports.default = { a: "export default" };
+`,
+		},
+		{
+			name: "strings, comments, templates and regexps",
+			src: `// import x from "y";
+/* export default 1; */
+const s = "import a from 'b'", t = ` + "`${`import ${s}`} export {}`" + `, r = /export { a }/g;
+export const x = s.length / 2 / t.length;`,
+			expected: esmPrologue + `// import x from "y";
+/* export default 1; */
+const s = "import a from 'b'", t = ` + "`${`import ${s}`} export {}`" + `, r = /export { a }/g;
+const x = s.length / 2 / t.length;
+exports.x = x;`,
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			code, ok := esmToCommonJS(tc.src)
+			require.True(t, ok)
+			assert.Equal(t, tc.expected, code)
+		})
+	}
+}
+
+func TestESMToCommonJSNotSupported(t *testing.T) {
+	t.Parallel()
+	for _, src := range []string{
+		`var a = 1;`,
+		`export * from "./lib.js";`,
+		`export { a } from "./lib.js";`,
+		`export class A {}`,
+		`export default class A {}`,
+		`export const { a, b } = obj;`,
+		`import type { A } from "./types";`,
+		`const m = import("./lib.js");`,
+		`import a from "./lib.js`,
+	} {
+		_, ok := esmToCommonJS(src)
+		assert.False(t, ok, src)
+	}
+}
+
+func TestCompileExperimentalEnhanced(t *testing.T) {
+	t.Parallel()
+	run := func(t *testing.T, src string, main bool) (*goja.Runtime, string) {
+		t.Helper()
+		c := New(testutils.NewLogger(t))
+		c.Options.CompatibilityMode = lib.CompatibilityModeExperimentalEnhanced
+		pgm, code, err := c.Compile(src, "script.js", main)
+		require.NoError(t, err)
+		rt := goja.New()
+		exports := rt.NewObject()
+		require.NoError(t, rt.Set("exports", exports))
+		require.NoError(t, rt.Set("require", func(name string) map[string]interface{} {
+			return map[string]interface{}{"name": name}
+		}))
+		_, err = rt.RunProgram(pgm)
+		require.NoError(t, err)
+		return rt, code
+	}
+
+	t.Run("without Babel", func(t *testing.T) {
+		t.Parallel()
+		rt, code := run(t, `import lib, { name } from "k6/lib";
+export const options = { vus: lib.name.length };
+export default function () { return options?.vus + name; }`, true)
+		assert.NotContains(t, code, "_interopRequireDefault", "Babel shouldn't be used")
+		v, err := rt.RunString(`exports.default()`)
+		require.NoError(t, err)
+		assert.Equal(t, "6k6/lib", v.String())
+	})
+
+	t.Run("with Babel", func(t *testing.T) {
+		t.Parallel()
+		rt, code := run(t, `import lib from "k6/lib";
+export const options = { vus: lib.name.length };
+export default class Test {}`, true)
+		assert.Contains(t, code, "_interopRequireDefault")
+		v, err := rt.RunString(`exports.options.vus`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(6), v.Export())
+	})
+
+	// TypeScript isn't supported, its type annotations are syntax errors.
+	t.Run("TypeScript", func(t *testing.T) {
+		t.Parallel()
+		for _, src := range []string{
+			`export const options: { vus: number } = { vus: 1 };`,
+			`interface Options { vus: number }
+export const options = { vus: 1 };`,
+			`export default function (data: unknown) { return data as string; }`,
+			`function first<T>(items: T[]): T { return items[0]; }
+export default function () { first([1]); }`,
+		} {
+			c := New(testutils.NewLogger(t))
+			c.Options.CompatibilityMode = lib.CompatibilityModeExperimentalEnhanced
+			_, _, err := c.Compile(src, "script.ts", true)
+			assert.Error(t, err, src)
+		}
+	})
+
+	t.Run("lines", func(t *testing.T) {
+		t.Parallel()
+		rt, _ := run(t, "import {\n\ta,\n} from 'a';\nexport default function () {\n\tthrow new Error('x');\n}", true)
+		_, err := rt.RunString(`exports.default()`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "script.js:5:")
+	})
+}
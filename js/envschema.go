@@ -0,0 +1,222 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/lib/consts"
+)
+
+// The types of the environment variables of an envSchema.
+const (
+	envTypeString  = "string"
+	envTypeNumber  = "number"
+	envTypeInteger = "integer"
+	envTypeBoolean = "boolean"
+)
+
+// envVarSchema is the declaration of an environment variable in the envSchema
+// exported by the script, for instance:
+//
+//	export const envSchema = {
+//		VUS: { type: "integer", required: true, description: "the number of VUs" },
+//		BASE_URL: { type: "string", default: "https://test.k6.io" },
+//	};
+type envVarSchema struct {
+	// Type is one of string, number, integer and boolean, string by default.
+	Type        string      `json:"type"`
+	Required    bool        `json:"required"`
+	Default     interface{} `json:"default"`
+	Description string      `json:"description"`
+
+	// defaultValue is Default as the string it has in __ENV.
+	defaultValue string
+}
+
+// envSchema is the envSchema exported by the script, by variable name.
+type envSchema map[string]*envVarSchema
+
+// getEnvSchema returns the envSchema exported by the script, if it has one.
+func getEnvSchema(rt *goja.Runtime) (envSchema, error) {
+	exportsV := rt.Get("exports")
+	if goja.IsNull(exportsV) || goja.IsUndefined(exportsV) {
+		return nil, nil //nolint:nilnil
+	}
+	v := exportsV.ToObject(rt).Get(consts.EnvSchema)
+	if v == nil || goja.IsNull(v) || goja.IsUndefined(v) {
+		return nil, nil //nolint:nilnil
+	}
+	if _, ok := goja.AssertFunction(v); ok {
+		return nil, errors.New("exported 'envSchema' must be an object")
+	}
+
+	data, err := json.Marshal(v.Export())
+	if err != nil {
+		return nil, err
+	}
+	var schema envSchema
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err = dec.Decode(&schema); err != nil {
+		return nil, fmt.Errorf("invalid exported 'envSchema': %w", err)
+	}
+	for name, s := range schema {
+		if s == nil {
+			return nil, fmt.Errorf("invalid exported 'envSchema': the variable %s has no declaration", name)
+		}
+		if err = s.init(); err != nil {
+			return nil, fmt.Errorf("invalid exported 'envSchema': the variable %s %w", name, err)
+		}
+	}
+	return schema, nil
+}
+
+// init checks the type and the default of the declaration.
+func (s *envVarSchema) init() error {
+	switch s.Type {
+	case "":
+		s.Type = envTypeString
+	case envTypeString, envTypeNumber, envTypeInteger, envTypeBoolean:
+	default:
+		return fmt.Errorf("has the unknown type %q, it should be one of %s, %s, %s and %s",
+			s.Type, envTypeString, envTypeNumber, envTypeInteger, envTypeBoolean)
+	}
+	if s.Default == nil {
+		return nil
+	}
+	switch d := s.Default.(type) {
+	case string:
+		s.defaultValue = d
+	case bool:
+		s.defaultValue = strconv.FormatBool(d)
+	case float64:
+		s.defaultValue = strconv.FormatFloat(d, 'f', -1, 64)
+	default:
+		return errors.New("has a default which isn't a string, a number or a boolean")
+	}
+	if err := s.check(s.defaultValue); err != nil {
+		return fmt.Errorf("has an invalid default: %w", err)
+	}
+	return nil
+}
+
+// check returns an error if the value isn't of the type of the declaration.
+func (s *envVarSchema) check(value string) error {
+	var err error
+	switch s.Type {
+	case envTypeNumber:
+		var f float64
+		if f, err = strconv.ParseFloat(value, 64); err == nil && (math.IsNaN(f) || math.IsInf(f, 0)) {
+			err = errors.New("not finite")
+		}
+	case envTypeInteger:
+		_, err = strconv.ParseInt(value, 10, 64)
+	case envTypeBoolean:
+		_, err = strconv.ParseBool(value)
+	}
+	if err != nil {
+		return fmt.Errorf("should be %s %s, but it's %q", article(s.Type), s.Type, value)
+	}
+	return nil
+}
+
+func article(word string) string {
+	if strings.ContainsAny(word[:1], "aeiou") {
+		return "an"
+	}
+	return "a"
+}
+
+// validate checks the environment variables against the schema, and returns
+// the defaults of the ones which aren't set. All the invalid variables are
+// in the error, which has the InvalidConfig exit code.
+func (schema envSchema) validate(env map[string]string) (map[string]string, error) {
+	names := make([]string, 0, len(schema))
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var problems []string
+	defaults := make(map[string]string)
+	for _, name := range names {
+		s := schema[name]
+		value, ok := env[name]
+		var err error
+		switch {
+		case ok:
+			err = s.check(value)
+		case s.Default != nil:
+			defaults[name] = s.defaultValue
+		case s.Required:
+			err = errors.New("is required")
+		}
+		if err == nil {
+			continue
+		}
+		problem := fmt.Sprintf("%s %s", name, err)
+		if s.Description != "" {
+			problem += fmt.Sprintf(" (%s)", s.Description)
+		}
+		problems = append(problems, problem)
+	}
+	if len(problems) > 0 {
+		err := fmt.Errorf("invalid environment variables, as declared in the envSchema of the script:\n\t%s",
+			strings.Join(problems, "\n\t"))
+		return nil, errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
+	return defaults, nil
+}
+
+// applyEnvSchema validates the environment variables with the envSchema
+// exported by the script instantiated in rt. It returns the environment
+// variables with the defaults of the schema if any was missing, and nil if
+// they are complete.
+func applyEnvSchema(rt *goja.Runtime, env map[string]string) (map[string]string, error) {
+	schema, err := getEnvSchema(rt)
+	if err != nil || schema == nil {
+		return nil, errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
+	defaults, err := schema.validate(env)
+	if err != nil || len(defaults) == 0 {
+		return nil, err
+	}
+	withDefaults := make(map[string]string, len(env)+len(defaults))
+	for k, v := range env {
+		withDefaults[k] = v
+	}
+	for k, v := range defaults {
+		withDefaults[k] = v
+	}
+	return withDefaults, nil
+}
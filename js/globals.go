@@ -0,0 +1,119 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"github.com/dop251/goja"
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/parser"
+	"github.com/sirupsen/logrus"
+)
+
+// globalsTracker finds the global variables which are created or reassigned
+// by the iterations of a VU. Unlike the local variables of the iterations,
+// they silently keep their values from one iteration to the next. It's only
+// used with the --strict-globals flag, as checking the globals after every
+// iteration isn't free. The objects in the globals which are modified in
+// place aren't found.
+type globalsTracker struct {
+	names    []string
+	values   map[string]goja.Value
+	reported map[string]bool
+}
+
+// changedByK6 are the globals which k6 itself changes between iterations.
+//nolint:gochecknoglobals
+var changedByK6 = map[string]bool{"__ITER": true}
+
+// declaredGlobals returns the names of the variables declared at the top of
+// the compiled code of the main script, with var, let or const. The ones
+// declared with let and const aren't properties of the global object, so
+// they can't be listed in the VUs.
+func declaredGlobals(code string) []string {
+	program, err := parser.ParseFile(nil, "", code, 0, parser.WithDisableSourceMaps)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	addBindings := func(bindings []*ast.Binding) {
+		for _, binding := range bindings {
+			if id, ok := binding.Target.(*ast.Identifier); ok {
+				names = append(names, id.Name.String())
+			}
+		}
+	}
+	for _, statement := range program.Body {
+		switch s := statement.(type) {
+		case *ast.VariableStatement:
+			addBindings(s.List)
+		case *ast.LexicalDeclaration:
+			addBindings(s.List)
+		}
+	}
+	return names
+}
+
+// newGlobalsTracker returns a tracker with the current values of the
+// declared globals and of the properties of the global object of rt, i.e.
+// the ones of the init context and of k6 itself.
+func newGlobalsTracker(rt *goja.Runtime, declared []string) *globalsTracker {
+	g := &globalsTracker{values: make(map[string]goja.Value), reported: make(map[string]bool)}
+	for _, name := range append(rt.GlobalObject().Keys(), declared...) {
+		g.add(rt, name)
+	}
+	return g
+}
+
+func (g *globalsTracker) add(rt *goja.Runtime, name string) {
+	if _, ok := g.values[name]; !ok {
+		g.names = append(g.names, name)
+	}
+	g.values[name] = rt.Get(name)
+}
+
+// report logs a warning for every global of rt which was created or
+// reassigned since the last time, once per VU.
+func (g *globalsTracker) report(rt *goja.Runtime, logger logrus.FieldLogger, iteration int64) {
+	for _, name := range g.names {
+		if g.reported[name] || changedByK6[name] {
+			continue
+		}
+		previous := g.values[name]
+		if current := rt.Get(name); current != nil && previous != nil && !current.SameAs(previous) {
+			g.reported[name] = true
+			logger.WithFields(logrus.Fields{"global": name, "iteration": iteration}).Warnf(
+				"The global variable '%s' of the init context was reassigned, so its value is kept for the "+
+					"next iterations of the VU; declare a local variable in the function instead if it isn't "+
+					"intended", name)
+		}
+	}
+	for _, name := range rt.GlobalObject().Keys() {
+		if _, ok := g.values[name]; ok {
+			continue
+		}
+		g.add(rt, name)
+		g.reported[name] = true
+		logger.WithFields(logrus.Fields{"global": name, "iteration": iteration}).Warnf(
+			"The global variable '%s' was created outside of the init context, so it's shared by all the "+
+				"iterations of the VU; declare a local variable in the function instead, or declare it in the "+
+				"init context if sharing it is intended", name)
+	}
+}
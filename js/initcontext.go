@@ -44,11 +44,22 @@ import (
 	"go.k6.io/k6/js/modules/k6/encoding"
 	"go.k6.io/k6/js/modules/k6/execution"
 	"go.k6.io/k6/js/modules/k6/experimental"
+	"go.k6.io/k6/js/modules/k6/experimental/dns"
+	"go.k6.io/k6/js/modules/k6/experimental/exec"
+	"go.k6.io/k6/js/modules/k6/experimental/ftp"
+	"go.k6.io/k6/js/modules/k6/experimental/ldap"
+	"go.k6.io/k6/js/modules/k6/experimental/mockserver"
+	"go.k6.io/k6/js/modules/k6/experimental/ssh"
+	k6sync "go.k6.io/k6/js/modules/k6/experimental/sync"
+	"go.k6.io/k6/js/modules/k6/experimental/thrift"
+	"go.k6.io/k6/js/modules/k6/experimental/utils"
+	"go.k6.io/k6/js/modules/k6/experimental/workload"
 	"go.k6.io/k6/js/modules/k6/grpc"
 	"go.k6.io/k6/js/modules/k6/html"
 	"go.k6.io/k6/js/modules/k6/http"
 	"go.k6.io/k6/js/modules/k6/metrics"
 	"go.k6.io/k6/js/modules/k6/ws"
+	"go.k6.io/k6/js/wasm"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/fsext"
 	"go.k6.io/k6/loader"
@@ -58,6 +69,9 @@ type programWithSource struct {
 	pgm    *goja.Program
 	src    string
 	module *goja.Object
+	// wasm is set instead of pgm for WebAssembly modules, which are
+	// instantiated for every VU.
+	wasm *wasm.Module
 }
 
 const openCantBeUsedOutsideInitContextMsg = `The "open()" function is only available in the init stage ` +
@@ -84,6 +98,10 @@ type InitContext struct {
 	logger logrus.FieldLogger
 
 	modules map[string]interface{}
+
+	// report measures the imported modules, it's only set for the first
+	// instantiation with the --verbose-init flag.
+	report *initReport
 }
 
 // NewInitContext creates a new initcontext with the provided arguments
@@ -112,8 +130,9 @@ func newBoundInitContext(base *InitContext, vuImpl *moduleVUImpl) *InitContext {
 	programs := make(map[string]programWithSource, len(base.programs))
 	for key, program := range base.programs {
 		programs[key] = programWithSource{
-			src: program.src,
-			pgm: program.pgm,
+			src:  program.src,
+			pgm:  program.pgm,
+			wasm: program.wasm,
 		}
 	}
 	return &InitContext{
@@ -235,6 +254,8 @@ func (i *InitContext) requireModule(name string) (goja.Value, error) {
 	if !ok {
 		return nil, fmt.Errorf("unknown module: %s", name)
 	}
+	i.report.begin(name)
+	defer i.report.end()
 	if m, ok := mod.(modules.Module); ok {
 		instance := m.NewModuleInstance(i.moduleVUImpl)
 		return i.moduleVUImpl.runtime.ToValue(toESModuleExports(instance.Exports())), nil
@@ -268,24 +289,21 @@ func (i *InitContext) requireFile(name string) (goja.Value, error) {
 		}
 		i.pwd = loader.Dir(fileURL)
 		defer func() { i.pwd = pwd }()
+		i.report.begin(fileURL.String())
+		defer i.report.end()
 		exports := i.moduleVUImpl.runtime.NewObject()
 		pgm.module = i.moduleVUImpl.runtime.NewObject()
 		_ = pgm.module.Set("exports", exports)
 
-		if pgm.pgm == nil {
-			// Load the sources; the loader takes care of remote loading, etc.
-			data, err := loader.Load(i.logger, i.filesystems, fileURL, name)
-			if err != nil {
+		if pgm.pgm == nil && pgm.wasm == nil {
+			if err = i.loadProgram(fileURL, name, &pgm); err != nil {
 				return goja.Undefined(), err
 			}
+			i.report.compiled()
+		}
 
-			pgm.src = string(data.Data)
-
-			// Compile the sources; this handles ES5 vs ES6 automatically.
-			pgm.pgm, err = i.compileImport(pgm.src, data.URL.String())
-			if err != nil {
-				return goja.Undefined(), err
-			}
+		if pgm.wasm != nil {
+			return i.instantiateWasm(fileURL.String(), pgm)
 		}
 
 		i.programs[fileURL.String()] = pgm
@@ -306,6 +324,43 @@ func (i *InitContext) requireFile(name string) (goja.Value, error) {
 	return pgm.module.Get("exports"), nil
 }
 
+// loadProgram loads the file and either compiles it or decodes it, if it's a
+// WebAssembly module.
+func (i *InitContext) loadProgram(fileURL *url.URL, name string, pgm *programWithSource) error {
+	// Load the sources; the loader takes care of remote loading, etc.
+	data, err := loader.Load(i.logger, i.filesystems, fileURL, name)
+	if err != nil {
+		return err
+	}
+
+	if wasm.IsModule(data.Data) {
+		if pgm.wasm, err = wasm.Decode(data.Data); err != nil {
+			return fmt.Errorf("couldn't decode the WebAssembly module %s: %w", name, err)
+		}
+		i.logger.Warnf("The support of WebAssembly modules is experimental, %s is run by k6's own interpreter, "+
+			"which may change in a later release", name)
+		return nil
+	}
+
+	pgm.src = string(data.Data)
+
+	// Compile the sources; this handles ES5 vs ES6 automatically.
+	pgm.pgm, err = i.compileImport(pgm.src, data.URL.String())
+	return err
+}
+
+// instantiateWasm instantiates the WebAssembly module of the program for this
+// VU, whose exports are the exports of the JS module.
+func (i *InitContext) instantiateWasm(key string, pgm programWithSource) (goja.Value, error) {
+	exports, err := wasm.Exports(i.moduleVUImpl.runtime, i.moduleVUImpl.Context, pgm.wasm)
+	if err != nil {
+		return goja.Undefined(), err
+	}
+	_ = pgm.module.Set("exports", exports)
+	i.programs[key] = pgm
+	return exports, nil
+}
+
 func (i *InitContext) compileImport(src, filename string) (*goja.Program, error) {
 	pgm, _, err := i.compiler.Compile(src, filename, false)
 	return pgm, err
@@ -313,8 +368,9 @@ func (i *InitContext) compileImport(src, filename string) (*goja.Program, error)
 
 // Open implements open() in the init context and will read and return the
 // contents of a file. If the second argument is "b" it returns an ArrayBuffer
-// instance, otherwise a string representation.
-func (i *InitContext) Open(filename string, args ...string) (goja.Value, error) {
+// instance, otherwise a string representation. The file can also be a https,
+// s3 or gs URL, see loadRemoteData.
+func (i *InitContext) Open(filename string, mode string, rawParams map[string]interface{}) (goja.Value, error) {
 	if i.moduleVUImpl.State() != nil {
 		return nil, errors.New(openCantBeUsedOutsideInitContextMsg)
 	}
@@ -323,6 +379,53 @@ func (i *InitContext) Open(filename string, args ...string) (goja.Value, error)
 		return nil, errors.New("open() can't be used with an empty filename")
 	}
 
+	var (
+		data []byte
+		err  error
+	)
+	if loader.IsRemoteData(filename) {
+		data, err = i.loadRemoteData(filename, rawParams)
+	} else {
+		data, err = i.readLocalFile(filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if mode == "b" {
+		ab := i.moduleVUImpl.runtime.NewArrayBuffer(data)
+		return i.moduleVUImpl.runtime.ToValue(&ab), nil
+	}
+	return i.moduleVUImpl.runtime.ToValue(string(data)), nil
+}
+
+// loadRemoteData loads a data file from a remote source, which is only fetched
+// once and saved in the archive. The only param is the headers of https
+// requests, the credentials of the s3 and gs sources are read from the env.
+func (i *InitContext) loadRemoteData(rawURL string, rawParams map[string]interface{}) ([]byte, error) {
+	opts := loader.RemoteDataOptions{}
+	for k, v := range rawParams {
+		if k != "headers" {
+			return nil, fmt.Errorf("unknown open() param: %q", k)
+		}
+		headers, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("open() headers must be an object with key-value pairs")
+		}
+		opts.Headers = make(map[string]string, len(headers))
+		for hk, hv := range headers {
+			if opts.Headers[hk], ok = hv.(string); !ok {
+				return nil, fmt.Errorf("open() header %q value must be a string", hk)
+			}
+		}
+	}
+	if initEnv := i.moduleVUImpl.initEnv; initEnv != nil {
+		opts.Env = initEnv.RuntimeOptions.Env
+	}
+	return loader.LoadData(i.logger, i.filesystems, rawURL, opts)
+}
+
+func (i *InitContext) readLocalFile(filename string) ([]byte, error) {
 	// Here IsAbs should be enough but unfortunately it doesn't handle absolute paths starting from
 	// the current drive on windows like `\users\noname\...`. Also it makes it more easy to test and
 	// will probably be need for archive execution under windows if always consider '/...' as an
@@ -336,16 +439,7 @@ func (i *InitContext) Open(filename string, args ...string) (goja.Value, error)
 		filename = afero.FilePathSeparator + filename
 	}
 
-	data, err := readFile(fs, filename)
-	if err != nil {
-		return nil, err
-	}
-
-	if len(args) > 0 && args[0] == "b" {
-		ab := i.moduleVUImpl.runtime.NewArrayBuffer(data)
-		return i.moduleVUImpl.runtime.ToValue(&ab), nil
-	}
-	return i.moduleVUImpl.runtime.ToValue(string(data)), nil
+	return readFile(fs, filename)
 }
 
 func readFile(fileSystem afero.Fs, filename string) (data []byte, err error) {
@@ -384,18 +478,28 @@ func (i *InitContext) allowOnlyOpenedFiles() {
 
 func getInternalJSModules() map[string]interface{} {
 	return map[string]interface{}{
-		"k6":              k6.New(),
-		"k6/crypto":       crypto.New(),
-		"k6/crypto/x509":  x509.New(),
-		"k6/data":         data.New(),
-		"k6/encoding":     encoding.New(),
-		"k6/execution":    execution.New(),
-		"k6/net/grpc":     grpc.New(),
-		"k6/html":         html.New(),
-		"k6/http":         http.New(),
-		"k6/metrics":      metrics.New(),
-		"k6/ws":           ws.New(),
-		"k6/experimental": experimental.New(),
+		"k6":                         k6.New(),
+		"k6/crypto":                  crypto.New(),
+		"k6/crypto/x509":             x509.New(),
+		"k6/data":                    data.New(),
+		"k6/encoding":                encoding.New(),
+		"k6/execution":               execution.New(),
+		"k6/net/grpc":                grpc.New(),
+		"k6/html":                    html.New(),
+		"k6/http":                    http.New(),
+		"k6/metrics":                 metrics.New(),
+		"k6/ws":                      ws.New(),
+		"k6/experimental":            experimental.New(),
+		"k6/experimental/dns":        dns.New(),
+		"k6/experimental/exec":       exec.New(),
+		"k6/experimental/ftp":        ftp.New(),
+		"k6/experimental/ldap":       ldap.New(),
+		"k6/experimental/mockserver": mockserver.New(),
+		"k6/experimental/ssh":        ssh.New(),
+		"k6/experimental/sync":       k6sync.New(),
+		"k6/experimental/thrift":     thrift.New(),
+		"k6/experimental/utils":      utils.New(),
+		"k6/experimental/workload":   workload.New(),
 	}
 }
 
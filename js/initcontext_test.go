@@ -27,6 +27,7 @@ import (
 	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"path/filepath"
 	"testing"
 	"time"
@@ -44,6 +45,7 @@ import (
 	"go.k6.io/k6/lib/netext"
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/loader"
 	"go.k6.io/k6/stats"
 )
 
@@ -242,6 +244,65 @@ func TestInitContextRequire(t *testing.T) {
 	})
 }
 
+func TestInitContextRequireWasm(t *testing.T) {
+	t.Parallel()
+	// a module exporting add(i32, i32) -> i32 and a memory with "k6" at 0
+	addModule := []byte{
+		0x00, 0x61, 0x73, 0x6d, 0x01, 0x00, 0x00, 0x00,
+		0x01, 0x07, 0x01, 0x60, 0x02, 0x7f, 0x7f, 0x01, 0x7f,
+		0x03, 0x02, 0x01, 0x00,
+		0x05, 0x03, 0x01, 0x00, 0x01,
+		0x07, 0x10, 0x02, 0x03, 'a', 'd', 'd', 0x00, 0x00, 0x06, 'm', 'e', 'm', 'o', 'r', 'y', 0x02, 0x00,
+		0x0a, 0x09, 0x01, 0x07, 0x00, 0x20, 0x00, 0x20, 0x01, 0x6a, 0x0b,
+		0x0b, 0x08, 0x01, 0x00, 0x41, 0x00, 0x0b, 0x02, 'k', '6',
+	}
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/path/to/lib.wasm", addModule, 0o644))
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	hook := testutils.SimpleLogrusHook{HookedLevels: []logrus.Level{logrus.WarnLevel}}
+	logger.AddHook(&hook)
+	require.NoError(t, afero.WriteFile(fs, "/path/to/other.js", []byte(`
+		import lib from "./lib.wasm";
+		export function double(a) { return lib.add(a, a); }
+	`), 0o644))
+
+	b, err := getSimpleBundle(t, "/path/to/script.js", `
+		import lib from "./lib.wasm";
+		import { add } from "./lib.wasm";
+		import { double } from "./other.js";
+		export default function() {
+			if (add(2, 3) !== 5 || lib.add(-1, -2) !== -3 || double(21) !== 42) {
+				throw new Error("unexpected result of add");
+			}
+			if (add(0x7fffffff, 1) !== -0x80000000) {
+				throw new Error("add should overflow");
+			}
+			var memory = new Uint8Array(lib.memory.buffer);
+			if (String.fromCharCode(memory[0], memory[1]) !== "k6") {
+				throw new Error("unexpected memory contents");
+			}
+		}
+	`, fs, logger)
+	require.NoError(t, err)
+
+	entries := hook.Drain()
+	require.Len(t, entries, 1)
+	assert.Contains(t, entries[0].Message, "The support of WebAssembly modules is experimental, ./lib.wasm")
+
+	for i := 0; i < 2; i++ {
+		bi, err := b.Instantiate(testutils.NewLogger(t), uint64(i), newModuleVUImpl())
+		require.NoError(t, err)
+		_, err = bi.exports[consts.DefaultFn](goja.Undefined())
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, afero.WriteFile(fs, "/path/to/broken.wasm", addModule[:20], 0o644))
+	_, err = getSimpleBundle(t, "/path/to/script.js", `import "./broken.wasm"; export default function() {}`, fs)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "couldn't decode the WebAssembly module ./broken.wasm")
+}
+
 func createAndReadFile(t *testing.T, file string, content []byte, expectedLength int, binary string) (*BundleInstance, error) {
 	t.Helper()
 	fs := afero.NewMemMapFs()
@@ -332,6 +393,55 @@ func TestInitContextOpen(t *testing.T) {
 	})
 }
 
+func TestInitContextOpenRemoteData(t *testing.T) {
+	t.Parallel()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/bucket/users.csv" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte("user,pass\nadmin,123"))
+	}))
+	rtOpts := lib.RuntimeOptions{Env: map[string]string{"AWS_ENDPOINT_URL": srv.URL}}
+	filesystems := map[string]afero.Fs{
+		"file": afero.NewMemMapFs(), "https": afero.NewMemMapFs(), "s3": afero.NewMemMapFs(),
+	}
+
+	b, err := NewBundle(testutils.NewLogger(t), &loader.SourceData{
+		URL: &url.URL{Path: "/script.js", Scheme: "file"},
+		Data: []byte(`
+			export const data = open("s3://bucket/users.csv");
+			export default function() {}
+		`),
+	}, filesystems, rtOpts, metrics.NewRegistry())
+	require.NoError(t, err)
+	bi, err := b.Instantiate(testutils.NewLogger(t), 0, newModuleVUImpl())
+	require.NoError(t, err)
+	assert.Equal(t, "user,pass\nadmin,123", bi.Runtime.Get("data").Export())
+
+	// the file is in the archive, so it isn't fetched again from it
+	arc := b.makeArchive()
+	srv.Close()
+	data, err := afero.ReadFile(arc.Filesystems["s3"], "/bucket/users.csv")
+	require.NoError(t, err)
+	assert.Equal(t, "user,pass\nadmin,123", string(data))
+
+	arcBundle, err := NewBundleFromArchive(testutils.NewLogger(t), arc, rtOpts, metrics.NewRegistry())
+	require.NoError(t, err)
+	bi, err = arcBundle.Instantiate(testutils.NewLogger(t), 0, newModuleVUImpl())
+	require.NoError(t, err)
+	assert.Equal(t, "user,pass\nadmin,123", bi.Runtime.Get("data").Export())
+
+	t.Run("UnknownParam", func(t *testing.T) {
+		t.Parallel()
+		_, err := getSimpleBundle(t, "/script.js",
+			`open("https://example.com/data.csv", "", {timeout: "1s"}); export default function() {}`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `unknown open() param: "timeout"`)
+	})
+}
+
 func TestRequestWithBinaryFile(t *testing.T) {
 	t.Parallel()
 
@@ -0,0 +1,146 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"net/url"
+	"runtime"
+	"sort"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/lib"
+)
+
+// moduleInitStats are the time and memory a module took to load and compile
+// and then evaluate in the init context. The totals include the imports of
+// the module, while self is only its own code.
+type moduleInitStats struct {
+	name                     string
+	compile                  time.Duration
+	evaluate, evaluateSelf   time.Duration
+	allocated, allocatedSelf uint64
+}
+
+type initReportFrame struct {
+	stats          moduleInitStats
+	start          time.Time
+	startAllocated uint64
+	// the totals of the modules imported by this one
+	children          time.Duration
+	childrenAllocated uint64
+}
+
+// initReport measures how long the main script and the modules it imports
+// took in the init context, to find the ones making the initialization of the
+// VUs slow. It's only used with the --verbose-init flag, as measuring the
+// allocated memory stops the world, and its methods do nothing when it's nil.
+type initReport struct {
+	stack   []*initReportFrame
+	modules []moduleInitStats
+}
+
+// newInitReport starts measuring the main script if the report is enabled.
+func newInitReport(rtOpts lib.RuntimeOptions, mainScript *url.URL) *initReport {
+	if !rtOpts.VerboseInit.Bool {
+		return nil
+	}
+	r := &initReport{}
+	r.begin(mainScript.String())
+	return r
+}
+
+func totalAllocated() uint64 {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	return stats.TotalAlloc
+}
+
+// begin starts measuring the module name, until end is called.
+func (r *initReport) begin(name string) {
+	if r == nil {
+		return
+	}
+	r.stack = append(r.stack, &initReportFrame{
+		stats:          moduleInitStats{name: name},
+		start:          time.Now(),
+		startAllocated: totalAllocated(),
+	})
+}
+
+// compiled records that the current module was loaded and compiled, the rest
+// of its time is spent evaluating it.
+func (r *initReport) compiled() {
+	if r == nil {
+		return
+	}
+	frame := r.stack[len(r.stack)-1]
+	frame.stats.compile = time.Since(frame.start)
+}
+
+func (r *initReport) end() {
+	if r == nil {
+		return
+	}
+	frame := r.stack[len(r.stack)-1]
+	r.stack = r.stack[:len(r.stack)-1]
+
+	stats := frame.stats
+	elapsed := time.Since(frame.start)
+	stats.evaluate = elapsed - stats.compile
+	stats.evaluateSelf = stats.evaluate - frame.children
+	stats.allocated = totalAllocated() - frame.startAllocated
+	stats.allocatedSelf = stats.allocated - frame.childrenAllocated
+	r.modules = append(r.modules, stats)
+
+	if len(r.stack) > 0 {
+		parent := r.stack[len(r.stack)-1]
+		parent.children += elapsed
+		parent.childrenAllocated += stats.allocated
+	}
+}
+
+// log ends the measurement of the main script and logs the stats of all the
+// modules, starting with the ones whose own code took the longest to evaluate.
+func (r *initReport) log(logger logrus.FieldLogger) {
+	if r == nil {
+		return
+	}
+	for len(r.stack) > 0 {
+		r.end()
+	}
+	modules := make([]moduleInitStats, len(r.modules))
+	copy(modules, r.modules)
+	sort.SliceStable(modules, func(i, j int) bool {
+		return modules[i].evaluateSelf > modules[j].evaluateSelf
+	})
+	for _, m := range modules {
+		logger.WithFields(logrus.Fields{
+			"module":             m.name,
+			"compile":            m.compile,
+			"evaluate":           m.evaluate,
+			"evaluateSelf":       m.evaluateSelf,
+			"allocatedBytes":     m.allocated,
+			"allocatedSelfBytes": m.allocatedSelf,
+		}).Info("Module init time")
+	}
+}
@@ -0,0 +1,83 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package js
+
+import (
+	"context"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+)
+
+// newIterationContext returns the object which is passed to the exec function
+// of an iteration after the setup data, and the args of the scenario if it has
+// any. It tells library code which iteration it's running in and when it will
+// be interrupted, without k6/execution:
+//
+//	{
+//	  scenario: "default",
+//	  iteration: 3,           // of the VU, like __ITER
+//	  iterationInScenario: 2, // of the VU in the scenario
+//	  iterationInInstance: 7,
+//	  iterationInTest: 7,
+//	  deadline: 1600000000000, // in ms since the epoch, or null
+//	  signal: { aborted: false, reason: undefined, throwIfAborted() {} },
+//	}
+//
+// The signal is a common.AbortSignal, which is aborted when ctx is done, e.g.
+// at the end of the iteration or of the scenario, so it can be passed to the
+// requests as well.
+func (u *ActiveVU) newIterationContext(ctx context.Context) (*goja.Object, error) {
+	rt := u.Runtime
+	values := map[string]interface{}{
+		"scenario":            u.scenarioName,
+		"iteration":           u.iteration,
+		"iterationInScenario": u.scenarioIter[u.scenarioName],
+		"iterationInInstance": u.scIterLocal,
+		"iterationInTest":     u.scIterGlobal,
+		"deadline":            nil,
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		values["deadline"] = deadline.UnixNano() / int64(time.Millisecond)
+	}
+	if u.getNextIterationCounters == nil {
+		// there are no iteration counters outside of the executors, e.g. in
+		// the tests
+		values["iterationInInstance"], values["iterationInTest"] = nil, nil
+	}
+
+	o := rt.NewObject()
+	for name, value := range values {
+		err := o.DefineDataProperty(name, rt.ToValue(value), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	signal, _ := common.NewAbortSignal(rt, ctx, nil, "")
+	err := o.DefineDataProperty("signal", signal, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
+	if err != nil {
+		return nil, err
+	}
+	return o, nil
+}
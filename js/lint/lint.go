@@ -0,0 +1,409 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package lint finds the common mistakes of load testing in k6 scripts, by
+// looking at their source without running them.
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/dop251/goja/ast"
+	"github.com/dop251/goja/file"
+	"github.com/dop251/goja/parser"
+	"github.com/dop251/goja/token"
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/js/compiler"
+)
+
+// The rules which can be broken by a script.
+const (
+	RuleMetricInVUCode    = "metric-in-vu-code"
+	RuleOpenInVUCode      = "open-in-vu-code"
+	RuleMissingSleep      = "missing-sleep"
+	RuleUnnamedDynamicURL = "unnamed-dynamic-url"
+)
+
+// Warning is a likely mistake found in a script.
+type Warning struct {
+	Rule    string
+	Line    int
+	Column  int
+	Message string
+}
+
+func (w Warning) String() string {
+	return fmt.Sprintf("%d:%d: [%s] %s", w.Line, w.Column, w.Rule, w.Message)
+}
+
+// openModelExecutors are the types of the executors which start iterations at
+// a fixed rate, regardless of how long they take.
+//nolint:gochecknoglobals
+var openModelExecutors = []string{
+	"constant-arrival-rate", "ramping-arrival-rate", "arrival-rate-profile", "access-log-replay",
+}
+
+//nolint:gochecknoglobals
+var (
+	metricTypes = map[string]bool{"Counter": true, "Gauge": true, "Rate": true, "Trend": true, "Histogram": true}
+	// httpURLArg is the index of the URL in the arguments of the request
+	// functions of k6/http, which is followed by the body if they have one,
+	// and then by the params.
+	httpURLArg = map[string]int{
+		"get": 0, "head": 0, "del": 0, "options": 0, "post": 0, "put": 0, "patch": 0, "request": 1,
+	}
+	httpHasBody = map[string]bool{
+		"del": true, "options": true, "post": true, "put": true, "patch": true, "request": true,
+	}
+)
+
+// Lint returns the warnings about the script src, sorted by their position.
+// Scripts with ES modules are transformed by Babel first, and the positions
+// are those in the original source.
+func Lint(logger logrus.FieldLogger, filename, src string) ([]Warning, error) {
+	program, transformed, err := parse(logger, filename, src)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &linter{file: program.File, transformed: transformed}
+	exported := l.exportedFunctions(program)
+	seen := make(map[ast.Node]bool)
+	for _, name := range sortedKeys(exported) {
+		fn := exported[name]
+		if seen[fn] {
+			continue
+		}
+		seen[fn] = true
+		hasSleep := l.lintVUCode(fn)
+		if name == "default" && !hasSleep && !isOpenModel(src) {
+			l.warn(RuleMissingSleep, fn, "the default function doesn't sleep(), so every VU sends its requests "+
+				"as fast as it can; add think time, or use an arrival-rate executor to control the rate of iterations")
+		}
+	}
+
+	sort.SliceStable(l.warnings, func(i, j int) bool {
+		if l.warnings[i].Line != l.warnings[j].Line {
+			return l.warnings[i].Line < l.warnings[j].Line
+		}
+		return l.warnings[i].Column < l.warnings[j].Column
+	})
+	return l.warnings, nil
+}
+
+// parse returns the AST of src, transformed by Babel if goja can't parse it,
+// and whether it was.
+func parse(logger logrus.FieldLogger, filename, src string) (*ast.Program, bool, error) {
+	program, err := parser.ParseFile(nil, filename, src, 0, parser.WithDisableSourceMaps)
+	if err == nil {
+		return program, false, nil
+	}
+
+	c := compiler.New(logger)
+	// Babel only returns the source map when there is a loader for them.
+	c.Options.SourceMapLoader = func(string) ([]byte, error) {
+		return nil, errors.New("the source maps aren't loaded while linting")
+	}
+	code, srcMap, err := c.Transform(src, filename, nil)
+	if err != nil {
+		return nil, true, err
+	}
+	program, err = parser.ParseFile(nil, filename, code, 0, parser.WithSourceMapLoader(func(string) ([]byte, error) {
+		return srcMap, nil
+	}))
+	return program, true, err
+}
+
+func isOpenModel(src string) bool {
+	for _, executor := range openModelExecutors {
+		if strings.Contains(src, executor) {
+			return true
+		}
+	}
+	return false
+}
+
+type linter struct {
+	file        *file.File
+	transformed bool
+	warnings    []Warning
+}
+
+func (l *linter) warn(rule string, node ast.Node, message string) {
+	pos := l.file.Position(int(node.Idx0()) - l.file.Base())
+	if l.transformed {
+		// the columns in the source maps start from 0
+		pos.Column++
+	}
+	l.warnings = append(l.warnings, Warning{Rule: rule, Line: pos.Line, Column: pos.Column, Message: message})
+}
+
+// exportedFunctions returns the functions which are exported by the script,
+// i.e. the default function and the ones of setup(), teardown(), the
+// scenarios and handleSummary(), which all run outside of the init context.
+// Both CommonJS and the exports of ES modules transformed by Babel are
+// assignments to the properties of exports.
+func (l *linter) exportedFunctions(program *ast.Program) map[string]ast.Node {
+	declared := make(map[string]ast.Node)
+	assigned := make(map[string]ast.Expression)
+	walk(reflect.ValueOf(program), func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.FunctionDeclaration:
+			if n.Function.Name != nil {
+				declared[n.Function.Name.Name.String()] = n.Function
+			}
+		case *ast.Binding:
+			if id, ok := n.Target.(*ast.Identifier); ok && isFunction(n.Initializer) {
+				declared[id.Name.String()] = n.Initializer
+			}
+		case *ast.AssignExpression:
+			if n.Operator != token.ASSIGN {
+				break
+			}
+			if dot, ok := n.Left.(*ast.DotExpression); ok && identifierName(dot.Left) == "exports" {
+				assigned[dot.Identifier.Name.String()] = n.Right
+			}
+		}
+	})
+
+	exported := make(map[string]ast.Node)
+	for name, value := range assigned {
+		if isFunction(value) {
+			exported[name] = value
+		} else if fn, ok := declared[identifierName(value)]; ok {
+			exported[name] = fn
+		}
+	}
+	return exported
+}
+
+// lintVUCode adds the warnings about the function fn, which runs in the VUs,
+// and returns whether it calls sleep().
+func (l *linter) lintVUCode(fn ast.Node) (hasSleep bool) {
+	walk(reflect.ValueOf(fn), func(node ast.Node) {
+		switch n := node.(type) {
+		case *ast.NewExpression:
+			if name := calleeName(n.Callee); metricTypes[name] {
+				l.warn(RuleMetricInVUCode, n, fmt.Sprintf("new %s() is called outside of the init context, "+
+					"where custom metrics can't be created; create it once in the init context instead", name))
+			}
+		case *ast.CallExpression:
+			if identifierName(n.Callee) == "open" {
+				l.warn(RuleOpenInVUCode, n, "open() is called outside of the init context, "+
+					"where it isn't available; read the file once in the init context instead")
+			}
+			if calleeName(n.Callee) == "sleep" {
+				hasSleep = true
+			}
+			l.lintHTTPCall(n)
+		}
+	})
+	return hasSleep
+}
+
+// lintHTTPCall warns about the requests of k6/http with a URL which changes
+// between the iterations, and no name tag to group their metrics, which
+// would create a new time series for every URL.
+func (l *linter) lintHTTPCall(call *ast.CallExpression) {
+	dot, ok := unwrapSequence(call.Callee).(*ast.DotExpression)
+	if !ok || !strings.Contains(strings.ToLower(rootName(dot.Left)), "http") {
+		return
+	}
+	method := dot.Identifier.Name.String()
+	urlArg, ok := httpURLArg[method]
+	if !ok || len(call.ArgumentList) <= urlArg || !isDynamic(call.ArgumentList[urlArg]) {
+		return
+	}
+	paramsArg := urlArg + 1
+	if httpHasBody[method] {
+		paramsArg++
+	}
+	if len(call.ArgumentList) > paramsArg {
+		params, isLiteral := call.ArgumentList[paramsArg].(*ast.ObjectLiteral)
+		// params which aren't a literal could have a name tag
+		if !isLiteral || hasNameTag(params) {
+			return
+		}
+	}
+	l.warn(RuleUnnamedDynamicURL, call, fmt.Sprintf("the URL of http.%s() changes between requests, "+
+		"which creates a new time series for each of them; set the name tag in its params, "+
+		"or use the http.url template literal", method))
+}
+
+// isDynamic returns whether the URL expression is built from variables.
+func isDynamic(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.TemplateLiteral:
+		// http.url`...` is already named after its template
+		return e.Tag == nil && len(e.Expressions) > 0
+	case *ast.BinaryExpression:
+		return e.Operator == token.PLUS && !(isLiteral(e.Left) && isLiteral(e.Right))
+	case *ast.CallExpression:
+		return calleeName(e.Callee) == "concat"
+	}
+	return false
+}
+
+func isLiteral(expr ast.Expression) bool {
+	switch e := expr.(type) {
+	case *ast.StringLiteral, *ast.NumberLiteral:
+		return true
+	case *ast.BinaryExpression:
+		return e.Operator == token.PLUS && isLiteral(e.Left) && isLiteral(e.Right)
+	}
+	return false
+}
+
+func hasNameTag(params *ast.ObjectLiteral) bool {
+	tags, ok := propertyValue(params, "tags")
+	if !ok {
+		return false
+	}
+	tagsObj, isLiteral := tags.(*ast.ObjectLiteral)
+	if !isLiteral {
+		return true
+	}
+	_, ok = propertyValue(tagsObj, "name")
+	return ok
+}
+
+// propertyValue returns the value of the property name of obj, which is true
+// for shorthand properties and spreads, since their value is unknown.
+func propertyValue(obj *ast.ObjectLiteral, name string) (ast.Expression, bool) {
+	for _, prop := range obj.Value {
+		switch p := prop.(type) {
+		case *ast.PropertyKeyed:
+			if key, ok := p.Key.(*ast.StringLiteral); ok && key.Value.String() == name {
+				return p.Value, true
+			}
+			if identifierName(p.Key) == name {
+				return p.Value, true
+			}
+		case *ast.PropertyShort:
+			if p.Name.Name.String() == name {
+				return &p.Name, true
+			}
+		case *ast.SpreadElement:
+			return p.Expression, true
+		}
+	}
+	return nil, false
+}
+
+func isFunction(expr ast.Expression) bool {
+	switch expr.(type) {
+	case *ast.FunctionLiteral, *ast.ArrowFunctionLiteral:
+		return true
+	}
+	return false
+}
+
+func identifierName(expr ast.Expression) string {
+	if id, ok := expr.(*ast.Identifier); ok {
+		return id.Name.String()
+	}
+	return ""
+}
+
+// unwrapSequence returns the last expression of the sequences which Babel
+// uses to call the imported functions, e.g. (0, _k.sleep)(1).
+func unwrapSequence(expr ast.Expression) ast.Expression {
+	if seq, ok := expr.(*ast.SequenceExpression); ok && len(seq.Sequence) > 0 {
+		return seq.Sequence[len(seq.Sequence)-1]
+	}
+	return expr
+}
+
+// calleeName returns the name of the called function, without the objects
+// it's a property of.
+func calleeName(expr ast.Expression) string {
+	switch e := unwrapSequence(expr).(type) {
+	case *ast.Identifier:
+		return e.Name.String()
+	case *ast.DotExpression:
+		return e.Identifier.Name.String()
+	}
+	return ""
+}
+
+// rootName returns the name of the variable at the root of the properties
+// in expr, e.g. _http2 for _http2.default.
+func rootName(expr ast.Expression) string {
+	for {
+		switch e := expr.(type) {
+		case *ast.Identifier:
+			return e.Name.String()
+		case *ast.DotExpression:
+			expr = e.Left
+		default:
+			return ""
+		}
+	}
+}
+
+func sortedKeys(m map[string]ast.Node) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+//nolint:gochecknoglobals
+var astPkgPath = reflect.TypeOf(ast.Program{}).PkgPath()
+
+// walk calls visit for all the nodes in v, depth first. goja's AST doesn't
+// have a visitor, so the fields of the nodes are walked with reflection.
+func walk(v reflect.Value, visit func(ast.Node)) {
+	switch v.Kind() { //nolint:exhaustive
+	case reflect.Interface:
+		if !v.IsNil() {
+			walk(v.Elem(), visit)
+		}
+	case reflect.Ptr:
+		if v.IsNil() || v.Type().Elem().PkgPath() != astPkgPath {
+			return
+		}
+		if node, ok := v.Interface().(ast.Node); ok {
+			visit(node)
+		}
+		walk(v.Elem(), visit)
+	case reflect.Struct:
+		if v.Type().PkgPath() != astPkgPath {
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			// the hoisted declarations are also in the body
+			if v.Type().Field(i).Name != "DeclarationList" {
+				walk(v.Field(i), visit)
+			}
+		}
+	case reflect.Slice:
+		for i := 0; i < v.Len(); i++ {
+			walk(v.Index(i), visit)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lint
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/testutils"
+)
+
+// positionsAndRules returns the warnings without their messages.
+func positionsAndRules(warnings []Warning) []string {
+	result := make([]string, 0, len(warnings))
+	for _, w := range warnings {
+		result = append(result, fmt.Sprintf("%d:%d: [%s]", w.Line, w.Column, w.Rule))
+	}
+	return result
+}
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+	testCases := []struct {
+		name, src string
+		expected  []string
+	}{
+		{
+			name: "clean",
+			src: `
+var http = require("k6/http");
+var k6 = require("k6");
+var Trend = require("k6/metrics").Trend;
+var t = new Trend("my_trend");
+var data = open("data.json");
+exports.default = function() {
+	http.get("https://test.k6.io/items/" + data.id, { tags: { name: "items" } });
+	http.post(http.url` + "`https://test.k6.io/items/${data.id}`" + `, data);
+	http.get("https://test.k6.io/" + "items");
+	k6.sleep(1);
+};`,
+			expected: []string{},
+		},
+		{
+			name: "commonjs",
+			src: `
+var http = require("k6/http");
+var metrics = require("k6/metrics");
+function helper() { return open("data.json"); }
+function run() {
+	var c = new metrics.Counter("my_counter");
+	http.get("https://test.k6.io/items/" + Math.random());
+	http.post("https://test.k6.io/items/".concat(1), null, {});
+	http.request("GET", ` + "`https://test.k6.io/${c}`" + `, null, { tags: { kind: "x" } });
+}
+exports.default = run;
+exports.setup = function() { return open("data.json"); };`,
+			expected: []string{
+				"5:1: [missing-sleep]",
+				"6:10: [metric-in-vu-code]",
+				"7:2: [unnamed-dynamic-url]",
+				"8:2: [unnamed-dynamic-url]",
+				"9:2: [unnamed-dynamic-url]",
+				"12:37: [open-in-vu-code]",
+			},
+		},
+		{
+			name: "es modules",
+			src: `import http from "k6/http";
+import { sleep } from "k6";
+import { Trend } from "k6/metrics";
+
+export function setup() {
+	return JSON.parse(open("data.json"));
+}
+
+export default function (data) {
+	const t = new Trend("my_trend");
+	for (const id of data.ids) {
+		http.get(` + "`https://test.k6.io/items/${id}`" + `);
+	}
+	sleep(1);
+}`,
+			expected: []string{
+				"6:20: [open-in-vu-code]",
+				"10:12: [metric-in-vu-code]",
+				"12:3: [unnamed-dynamic-url]",
+			},
+		},
+		{
+			name: "open model",
+			src: `
+exports.options = { scenarios: { s: { executor: "constant-arrival-rate", rate: 10, duration: "1m" } } };
+exports.default = () => {};`,
+			expected: []string{},
+		},
+		{
+			name: "arrow function",
+			src: `
+var http = require("k6/http");
+var def = () => http.get("https://test.k6.io/" + __VU);
+exports.default = def;`,
+			expected: []string{"3:11: [missing-sleep]", "3:17: [unnamed-dynamic-url]"},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			warnings, err := Lint(testutils.NewLogger(t), "script.js", tc.src)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, positionsAndRules(warnings))
+		})
+	}
+}
+
+func TestLintSyntaxError(t *testing.T) {
+	t.Parallel()
+	_, err := Lint(testutils.NewLogger(t), "script.js", "export default function( {")
+	require.Error(t, err)
+}
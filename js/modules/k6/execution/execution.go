@@ -21,6 +21,7 @@
 package execution
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"reflect"
@@ -30,6 +31,7 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/js/modules/k6/metrics"
 	"go.k6.io/k6/lib"
 )
 
@@ -42,6 +44,12 @@ type (
 	ModuleInstance struct {
 		vu  modules.VU
 		obj *goja.Object
+		// scriptArgs are the --script-arg flags, and args is their object,
+		// which is created when it's first used.
+		scriptArgs map[string]string
+		args       *goja.Object
+		// options is the frozen object of the consolidated options.
+		options *goja.Object
 	}
 )
 
@@ -59,6 +67,9 @@ func New() *RootModule {
 // a new instance for each VU.
 func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	mi := &ModuleInstance{vu: vu}
+	if initEnv := vu.InitEnv(); initEnv != nil {
+		mi.scriptArgs = initEnv.RuntimeOptions.ScriptArgs
+	}
 	rt := vu.Runtime()
 	o := rt.NewObject()
 	defProp := func(name string, newInfo func() (*goja.Object, error)) {
@@ -123,17 +134,59 @@ func (mi *ModuleInstance) newScenarioInfo() (*goja.Object, error) {
 			p, _ := getScenarioState().ProgressFn()
 			return p
 		},
+		"timeRemaining": func() interface{} {
+			ss := getScenarioState()
+			remaining := ss.Duration - time.Since(ss.StartTime)
+			if remaining < 0 {
+				remaining = 0
+			}
+			return float64(remaining) / float64(time.Millisecond)
+		},
+		"stage": func() interface{} {
+			ss := getScenarioState()
+			if len(ss.Stages) == 0 {
+				return nil
+			}
+			index, progress := getCurrentStage(ss.Stages, time.Since(ss.StartTime))
+			return map[string]interface{}{
+				"index":    index,
+				"target":   ss.Stages[index].Target,
+				"progress": progress,
+			}
+		},
 		"iterationInInstance": func() interface{} {
 			return vuState.GetScenarioLocalVUIter()
 		},
 		"iterationInTest": func() interface{} {
 			return vuState.GetScenarioGlobalVUIter()
 		},
+		"request": func() interface{} {
+			ss := getScenarioState()
+			if len(ss.Requests) == 0 {
+				return nil
+			}
+			r := ss.Requests[vuState.GetScenarioGlobalVUIter()%uint64(len(ss.Requests))]
+			return map[string]interface{}{"method": r.Method, "path": r.Path}
+		},
 	}
 
 	return newInfoObj(rt, si)
 }
 
+// getCurrentStage returns the index of the stage the scenario is in after
+// elapsed time, and how much of the stage is done, from 0 to 1. After the last
+// stage it's still the current one, with all of it done.
+func getCurrentStage(stages []lib.ScenarioStage, elapsed time.Duration) (int, float64) {
+	var end time.Duration
+	for i, stage := range stages {
+		end += stage.Duration
+		if elapsed < end {
+			return i, 1 - float64(end-elapsed)/float64(stage.Duration)
+		}
+	}
+	return len(stages) - 1, 1
+}
+
 // newInstanceInfo returns a goja.Object with property accessors to retrieve
 // information about the local instance stats.
 func (mi *ModuleInstance) newInstanceInfo() (*goja.Object, error) {
@@ -153,6 +206,12 @@ func (mi *ModuleInstance) newInstanceInfo() (*goja.Object, error) {
 		"iterationsInterrupted": func() interface{} {
 			return es.GetPartialIterationCount()
 		},
+		"iterationsDropped": func() interface{} {
+			return es.GetDroppedIterationCount()
+		},
+		"requestsFailed": func() interface{} {
+			return es.GetFailedRequestCount()
+		},
 		"vusActive": func() interface{} {
 			return es.GetCurrentlyActiveVUsCount()
 		},
@@ -179,11 +238,112 @@ func (mi *ModuleInstance) newTestInfo() (*goja.Object, error) {
 				rt.Interrupt(&common.InterruptError{Reason: reason})
 			}
 		},
+		// stop the current iteration, without counting it as one
+		"skipIteration": func() interface{} {
+			return func(reason goja.Value) {
+				if mi.vu.State() == nil {
+					common.Throw(rt, errors.New("skipping an iteration in the init context is not supported"))
+				}
+				skip := &common.SkipIterationError{}
+				if reason != nil && !goja.IsUndefined(reason) {
+					skip.Reason = reason.String()
+				}
+				rt.Interrupt(skip)
+			}
+		},
+		// the arguments of the script, from the --script-arg flags
+		"args": func() interface{} {
+			return mi.getArgs()
+		},
+		// the consolidated options of the test
+		"options": func() interface{} {
+			return mi.getOptions()
+		},
+		// the values of the metrics of the test so far, by name
+		"metrics": func() interface{} {
+			return map[string]interface{}{
+				"get": func(name string) (*goja.Object, error) {
+					return metrics.NewMetricReader(mi.vu, name)
+				},
+			}
+		},
 	}
 
 	return newInfoObj(rt, ti)
 }
 
+// getArgs returns the object with the arguments of the script. The values are
+// parsed as JSON, so numbers, booleans, arrays and objects keep their types,
+// and the values which aren't valid JSON are strings.
+func (mi *ModuleInstance) getArgs() *goja.Object {
+	if mi.args != nil {
+		return mi.args
+	}
+	rt := mi.vu.Runtime()
+	mi.args = rt.NewObject()
+	for k, v := range mi.scriptArgs {
+		var value interface{}
+		if err := json.Unmarshal([]byte(v), &value); err != nil {
+			value = v
+		}
+		if err := mi.args.Set(k, value); err != nil {
+			common.Throw(rt, err)
+		}
+	}
+	return mi.args
+}
+
+// getOptions returns the frozen object of the options of the test, after
+// they were consolidated from the script, the config file, the environment
+// variables and the CLI flags, with the options which were set.
+func (mi *ModuleInstance) getOptions() *goja.Object {
+	if mi.options != nil {
+		return mi.options
+	}
+	rt := mi.vu.Runtime()
+	vuState := mi.vu.State()
+	if vuState == nil {
+		common.Throw(rt, errors.New("getting the test options in the init context is not supported"))
+	}
+
+	specified := make(map[string]interface{})
+	vuState.Options.ForEachSpecified("json", func(key string, value interface{}) {
+		specified[key] = value
+	})
+	data, err := json.Marshal(specified)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	// JSON.parse gives plain objects, which can be frozen unlike the
+	// wrapped Go values.
+	parse, _ := goja.AssertFunction(rt.Get("JSON").ToObject(rt).Get("parse"))
+	freeze, _ := goja.AssertFunction(rt.Get("Object").ToObject(rt).Get("freeze"))
+	options, err := parse(goja.Undefined(), rt.ToValue(string(data)))
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	if err = deepFreeze(freeze, options); err != nil {
+		common.Throw(rt, err)
+	}
+	mi.options = options.ToObject(rt)
+	return mi.options
+}
+
+// deepFreeze freezes the object and the objects and arrays it contains.
+func deepFreeze(freeze goja.Callable, v goja.Value) error {
+	obj, ok := v.(*goja.Object)
+	if !ok {
+		return nil
+	}
+	for _, k := range obj.Keys() {
+		if err := deepFreeze(freeze, obj.Get(k)); err != nil {
+			return err
+		}
+	}
+	_, err := freeze(goja.Undefined(), obj)
+	return err
+}
+
 // newVUInfo returns a goja.Object with property accessors to retrieve
 // information about the currently executing VU.
 func (mi *ModuleInstance) newVUInfo() (*goja.Object, error) {
@@ -211,9 +371,81 @@ func (mi *ModuleInstance) newVUInfo() (*goja.Object, error) {
 		Runtime: rt,
 		State:   vuState,
 	}))
+	if err != nil {
+		return o, err
+	}
+	err = o.Set("withTags", func(tags goja.Value, fn goja.Value) (goja.Value, error) {
+		return withTags(rt, vuState, tags, fn)
+	})
 	return o, err
 }
 
+// withTags calls fn with the tags set on the VU, and then sets the ones it
+// replaced back and deletes the others, even if it throws. If fn returns a
+// promise, they are set back once it's settled, so they apply to its async
+// continuations too, and to anything else the VU runs meanwhile.
+func withTags(rt *goja.Runtime, state *lib.State, tags goja.Value, fn goja.Value) (goja.Value, error) {
+	callable, ok := goja.AssertFunction(fn)
+	if !ok {
+		return nil, errors.New("withTags() requires a function as its second argument")
+	}
+	if tags == nil || goja.IsUndefined(tags) || goja.IsNull(tags) {
+		return callable(goja.Undefined())
+	}
+
+	obj := tags.ToObject(rt)
+	values := make(map[string]string)
+	for _, key := range obj.Keys() {
+		val := obj.Get(key)
+		switch val.ExportType().Kind() { //nolint:exhaustive
+		case reflect.String, reflect.Bool, reflect.Int64, reflect.Float64:
+			values[key] = val.String()
+		default:
+			return nil, fmt.Errorf("the value of the tag '%s' should be a String, a Boolean or a Number", key)
+		}
+	}
+
+	replaced := make(map[string]*string, len(values))
+	for key, value := range values {
+		if old, ok := state.Tags.Get(key); ok {
+			replaced[key] = &old
+		} else {
+			replaced[key] = nil
+		}
+		state.Tags.Set(key, value)
+	}
+	restore := func() {
+		for key, old := range replaced {
+			if old != nil {
+				state.Tags.Set(key, *old)
+			} else {
+				state.Tags.Delete(key)
+			}
+		}
+	}
+
+	result, err := callable(goja.Undefined())
+	if err != nil {
+		restore()
+		return nil, err
+	}
+	if _, isPromise := result.Export().(*goja.Promise); !isPromise {
+		restore()
+		return result, nil
+	}
+	then, ok := goja.AssertFunction(result.ToObject(rt).Get("then"))
+	if !ok {
+		restore()
+		return result, nil
+	}
+	onSettled := rt.ToValue(func() { restore() })
+	if _, err = then(result, onSettled, onSettled); err != nil {
+		restore()
+		return nil, err
+	}
+	return result, nil
+}
+
 func newInfoObj(rt *goja.Runtime, props map[string]func() interface{}) (*goja.Object, error) {
 	o := rt.NewObject()
 
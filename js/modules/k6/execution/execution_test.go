@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/sirupsen/logrus"
@@ -34,6 +35,7 @@ import (
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
 	"gopkg.in/guregu/null.v3"
 )
@@ -184,6 +186,59 @@ func TestVUTags(t *testing.T) {
 			assert.Contains(t, entries[0].Message, "discarded")
 		})
 	})
+
+	t.Run("WithTags", func(t *testing.T) {
+		t.Parallel()
+
+		tenv := setupTagsExecEnv(t)
+		state := tenv.Module.vu.State()
+		v, err := tenv.Runtime.RunString(`
+			exec.vu.withTags({ vu: "outer", endpoint: "login", attempt: 2 }, function() {
+				return exec.vu.withTags({ endpoint: "token" }, function() {
+					return JSON.stringify(exec.vu.tags);
+				});
+			});
+		`)
+		require.NoError(t, err)
+		assert.JSONEq(t, `{"vu": "outer", "endpoint": "token", "attempt": "2"}`, v.String())
+		assert.Equal(t, map[string]string{"vu": "42"}, state.Tags.Clone(), "the tags were set back")
+
+		_, err = tenv.Runtime.RunString(`exec.vu.withTags({ endpoint: "login" }, function() { throw new Error("oops"); })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "oops")
+		assert.Equal(t, map[string]string{"vu": "42"}, state.Tags.Clone(), "the tags were set back after the throw")
+
+		_, err = tenv.Runtime.RunString(`exec.vu.withTags({ endpoint: [1] }, function() {})`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the value of the tag 'endpoint' should be")
+	})
+
+	t.Run("WithTagsAsync", func(t *testing.T) {
+		t.Parallel()
+
+		tenv := setupTagsExecEnv(t)
+		state := tenv.Module.vu.State()
+		_, err := tenv.Runtime.RunString(`
+			var resolve;
+			var inside;
+			exec.vu.withTags({ endpoint: "login" }, function() {
+				return new Promise(function(r) { resolve = r; }).then(function() {
+					inside = exec.vu.tags.endpoint;
+				});
+			});
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"vu": "42", "endpoint": "login"}, state.Tags.Clone(),
+			"the tags are kept until the promise is settled")
+
+		v, err := tenv.Runtime.RunString(`resolve(); inside`)
+		require.NoError(t, err)
+		assert.Equal(t, "undefined", v.String(), "the continuations haven't run yet")
+		v, err = tenv.Runtime.RunString(`inside`)
+		require.NoError(t, err)
+		assert.Equal(t, "login", v.String())
+		assert.Equal(t, map[string]string{"vu": "42"}, state.Tags.Clone())
+	})
 }
 
 func TestAbortTest(t *testing.T) { //nolint: tparallel
@@ -223,3 +278,221 @@ func TestAbortTest(t *testing.T) { //nolint: tparallel
 		prove(t, `exec.test.abort("mayday")`, fmt.Sprintf("%s: mayday", common.AbortTest))
 	})
 }
+
+func TestScenarioStage(t *testing.T) {
+	t.Parallel()
+
+	rt := goja.New()
+	ctx := lib.WithScenarioState(context.Background(), &lib.ScenarioState{
+		Name:      "ramping",
+		Executor:  "ramping-vus",
+		StartTime: time.Now().Add(-15 * time.Second),
+		Duration:  30 * time.Second,
+		Stages: []lib.ScenarioStage{
+			{Duration: 10 * time.Second, Target: 5},
+			{Duration: 20 * time.Second, Target: 10},
+		},
+	})
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{},
+			CtxField:     ctx,
+			StateField:   &lib.State{},
+		},
+	).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Default))
+
+	_, err := rt.RunString(`
+		var stage = exec.scenario.stage;
+		if (stage.index !== 1) throw new Error('unexpected stage index: ' + stage.index);
+		if (stage.target !== 10) throw new Error('unexpected stage target: ' + stage.target);
+		if (stage.progress < 0.25 || stage.progress > 0.3) {
+			throw new Error('unexpected stage progress: ' + stage.progress);
+		}
+		var remaining = exec.scenario.timeRemaining;
+		if (remaining > 15000 || remaining < 14000) throw new Error('unexpected time remaining: ' + remaining);
+	`)
+	require.NoError(t, err)
+}
+
+func TestScenarioRequest(t *testing.T) {
+	t.Parallel()
+
+	rt := goja.New()
+	ctx := lib.WithScenarioState(context.Background(), &lib.ScenarioState{
+		Name:      "replay",
+		Executor:  "access-log-replay",
+		StartTime: time.Now(),
+		Requests: []lib.ScenarioRequest{
+			{Method: "GET", Path: "/"},
+			{Method: "POST", Path: "/login"},
+		},
+	})
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{},
+			CtxField:     ctx,
+			StateField: &lib.State{
+				GetScenarioGlobalVUIter: func() uint64 { return 3 },
+			},
+		},
+	).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Default))
+
+	_, err := rt.RunString(`
+		var request = exec.scenario.request;
+		if (request.method !== 'POST' || request.path !== '/login') {
+			throw new Error('unexpected request: ' + JSON.stringify(request));
+		}
+	`)
+	require.NoError(t, err)
+}
+
+func TestScriptArgs(t *testing.T) {
+	t.Parallel()
+
+	rt := goja.New()
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{
+				RuntimeOptions: lib.RuntimeOptions{ScriptArgs: map[string]string{
+					"n":     "5",
+					"debug": "true",
+					"user":  `{"name":"k6","roles":["admin"]}`,
+					"host":  "test.k6.io",
+				}},
+			},
+			CtxField:   context.Background(),
+			StateField: &lib.State{},
+		},
+	).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Default))
+
+	_, err := rt.RunString(`
+		var args = exec.test.args;
+		if (args.n !== 5) throw new Error('unexpected n: ' + args.n);
+		if (args.debug !== true) throw new Error('unexpected debug: ' + args.debug);
+		if (args.user.name !== 'k6' || args.user.roles[0] !== 'admin') {
+			throw new Error('unexpected user: ' + JSON.stringify(args.user));
+		}
+		if (args.host !== 'test.k6.io') throw new Error('unexpected host: ' + args.host);
+		if (args.missing !== undefined) throw new Error('unexpected missing: ' + args.missing);
+		if (exec.test.args !== args) throw new Error('args should be the same object');
+	`)
+	require.NoError(t, err)
+}
+
+// countReader has the count of every metric it's asked for.
+type countReader float64
+
+func (r countReader) MetricValue(name, method string) (float64, error) {
+	if method != "count" {
+		return 0, fmt.Errorf("no %s for %s", method, name)
+	}
+	return float64(r), nil
+}
+
+func TestTestMetrics(t *testing.T) {
+	t.Parallel()
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 1, 1)
+	es.Metrics = countReader(3)
+	rt := goja.New()
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{},
+			CtxField:     lib.WithExecutionState(context.Background(), es),
+			StateField:   &lib.State{},
+		},
+	).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Default))
+
+	v, err := rt.RunString(`exec.test.metrics.get("http_reqs").count`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), v.Export())
+	_, err = rt.RunString(`exec.test.metrics.get("http_reqs").rate`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no rate for http_reqs")
+}
+
+func TestTestOptions(t *testing.T) {
+	t.Parallel()
+
+	rt := goja.New()
+	state := &lib.State{Options: lib.Options{
+		VUs:        null.IntFrom(10),
+		Duration:   types.NullDurationFrom(time.Minute),
+		Thresholds: map[string]stats.Thresholds{"http_req_duration": stats.NewThresholds([]string{"p(95)<200"})},
+	}}
+	vu := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Default))
+
+	_, err := rt.RunString(`exec.test.options`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "init context")
+
+	vu.StateField = state
+	_, err = rt.RunString(`
+		"use strict";
+		var options = exec.test.options;
+		if (options.vus !== 10) throw new Error('unexpected vus: ' + options.vus);
+		if (options.duration !== '1m0s') throw new Error('unexpected duration: ' + options.duration);
+		if (options.thresholds.http_req_duration[0] !== 'p(95)<200') {
+			throw new Error('unexpected thresholds: ' + JSON.stringify(options.thresholds));
+		}
+		if (options.iterations !== undefined) throw new Error('unexpected iterations: ' + options.iterations);
+		if (exec.test.options !== options) throw new Error('options should be the same object');
+		if (!Object.isFrozen(options) || !Object.isFrozen(options.thresholds.http_req_duration)) {
+			throw new Error('options should be frozen');
+		}
+		try {
+			options.vus = 20;
+			throw new Error('options should be read-only');
+		} catch (e) {
+			if (!(e instanceof TypeError)) throw e;
+		}
+	`)
+	require.NoError(t, err)
+}
+
+func TestGetCurrentStage(t *testing.T) {
+	t.Parallel()
+
+	stages := []lib.ScenarioStage{
+		{Duration: 10 * time.Second, Target: 5},
+		{Duration: 0, Target: 20},
+		{Duration: 20 * time.Second, Target: 10},
+	}
+	testCases := []struct {
+		elapsed  time.Duration
+		index    int
+		progress float64
+	}{
+		{0, 0, 0},
+		{5 * time.Second, 0, 0.5},
+		{10 * time.Second, 2, 0},
+		{25 * time.Second, 2, 0.75},
+		{time.Minute, 2, 1},
+	}
+	for _, tc := range testCases {
+		index, progress := getCurrentStage(stages, tc.elapsed)
+		assert.Equal(t, tc.index, index, tc.elapsed)
+		assert.InDelta(t, tc.progress, progress, 0.0001, tc.elapsed)
+	}
+}
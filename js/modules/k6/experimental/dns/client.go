@@ -0,0 +1,499 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dns
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+//nolint:lll
+var (
+	errQueryInInitContext   = common.NewInitContextError("DNS queries in the init context are not supported")
+	errConnectInInitContext = common.NewInitContextError("connecting to a DNS server in the init context is not supported")
+)
+
+const (
+	protocolUDP = "udp"
+	protocolTCP = "tcp"
+	protocolDoT = "dot"
+	protocolDoH = "doh"
+
+	defaultDNSPort = "53"
+	defaultDoTPort = "853"
+	defaultTimeout = 5 * time.Second
+
+	// maxMessageSize is the largest possible DNS message over UDP or TCP.
+	maxMessageSize  = 65535
+	dohContentType  = "application/dns-message"
+	maxDoHBodyBytes = maxMessageSize
+)
+
+//nolint:gochecknoglobals
+var (
+	queryTypes = map[string]dnsmessage.Type{
+		"A":     dnsmessage.TypeA,
+		"AAAA":  dnsmessage.TypeAAAA,
+		"CNAME": dnsmessage.TypeCNAME,
+		"MX":    dnsmessage.TypeMX,
+		"NS":    dnsmessage.TypeNS,
+		"PTR":   dnsmessage.TypePTR,
+		"SOA":   dnsmessage.TypeSOA,
+		"SRV":   dnsmessage.TypeSRV,
+		"TXT":   dnsmessage.TypeTXT,
+	}
+	rcodeNames = map[dnsmessage.RCode]string{
+		dnsmessage.RCodeSuccess:        "NOERROR",
+		dnsmessage.RCodeFormatError:    "FORMERR",
+		dnsmessage.RCodeServerFailure:  "SERVFAIL",
+		dnsmessage.RCodeNameError:      "NXDOMAIN",
+		dnsmessage.RCodeNotImplemented: "NOTIMP",
+		dnsmessage.RCodeRefused:        "REFUSED",
+	}
+)
+
+// Client sends DNS queries to a single server.
+type Client struct {
+	vu       modules.VU
+	server   string
+	protocol string
+	timeout  time.Duration
+	conn     net.Conn
+}
+
+// Record is a single resource record of a response.
+type Record struct {
+	Name string
+	Type string
+	TTL  uint32
+	Data string
+}
+
+// Response is the response to a DNS query.
+type Response struct {
+	Rcode         string
+	Authoritative bool
+	Truncated     bool
+	Answers       []Record
+	Authorities   []Record
+	Additionals   []Record
+}
+
+type queryParams struct {
+	Tags             map[string]string
+	Timeout          time.Duration
+	RecursionDesired bool
+}
+
+func parseTags(v interface{}) (map[string]string, error) {
+	rawTags, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("tags must be an object with key-value pairs")
+	}
+	tags := make(map[string]string, len(rawTags))
+	for tk, tv := range rawTags {
+		strVal, ok := tv.(string)
+		if !ok {
+			return nil, fmt.Errorf("tag %q value must be a string", tk)
+		}
+		tags[tk] = strVal
+	}
+	return tags, nil
+}
+
+// Connect sets the server the queries are sent to. The server is a host with
+// an optional port for the udp, tcp and dot protocols, and a https URL for
+// the doh protocol.
+func (c *Client) Connect(server string, params map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+
+	protocol, timeout := protocolUDP, defaultTimeout
+	for k, v := range params {
+		var err error
+		switch k {
+		case "protocol":
+			protocol, _ = v.(string)
+			switch protocol {
+			case protocolUDP, protocolTCP, protocolDoT, protocolDoH:
+			default:
+				return fmt.Errorf("invalid protocol value: '%#v', it needs to be 'udp', 'tcp', 'dot' or 'doh'", v)
+			}
+		case "timeout":
+			if timeout, err = types.GetDurationValue(v); err != nil {
+				return fmt.Errorf("invalid timeout value: %w", err)
+			}
+		default:
+			return fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+
+	switch protocol {
+	case protocolDoH:
+		if u, err := url.Parse(server); err != nil || u.Scheme != "https" {
+			return fmt.Errorf("the doh server must be a https URL, got '%s'", server)
+		}
+	case protocolDoT:
+		server = withDefaultPort(server, defaultDoTPort)
+	default:
+		server = withDefaultPort(server, defaultDNSPort)
+	}
+
+	_ = c.Close()
+	c.server, c.protocol, c.timeout = server, protocol, timeout
+	if protocol == protocolDoH {
+		return nil
+	}
+	return c.dial()
+}
+
+func withDefaultPort(server, port string) string {
+	if _, _, err := net.SplitHostPort(server); err == nil {
+		return server
+	}
+	return net.JoinHostPort(strings.Trim(server, "[]"), port)
+}
+
+func (c *Client) dial() error {
+	state := c.vu.State()
+	ctx, cancel := context.WithTimeout(c.vu.Context(), c.timeout)
+	defer cancel()
+
+	network := "tcp"
+	if c.protocol == protocolUDP {
+		network = "udp"
+	}
+	conn, err := state.Dialer.DialContext(ctx, network, c.server)
+	if err != nil {
+		return err
+	}
+	if c.protocol == protocolDoT {
+		tlsConfig := state.TLSConfig.Clone()
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{} //nolint:gosec // the defaults are used when there's no config
+		}
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(c.server)
+		}
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err = tlsConn.HandshakeContext(ctx); err != nil {
+			_ = conn.Close()
+			return err
+		}
+		conn = tlsConn
+	}
+	c.conn = conn
+	return nil
+}
+
+// Query sends a query for the given name and record type, and returns the
+// response of the server.
+func (c *Client) Query(name, qtype string, params map[string]interface{}) (*Response, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errQueryInInitContext
+	}
+	if c.server == "" {
+		return nil, errors.New("no DNS server, you must call connect first")
+	}
+
+	p := queryParams{Timeout: c.timeout, RecursionDesired: true}
+	for k, v := range params {
+		var err error
+		switch k {
+		case "tags":
+			if p.Tags, err = parseTags(v); err != nil {
+				return nil, err
+			}
+		case "timeout":
+			if p.Timeout, err = types.GetDurationValue(v); err != nil {
+				return nil, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		case "recursionDesired":
+			var ok bool
+			if p.RecursionDesired, ok = v.(bool); !ok {
+				return nil, fmt.Errorf("invalid recursionDesired value: '%#v', it needs to be boolean", v)
+			}
+		default:
+			return nil, fmt.Errorf("unknown param: %q", k)
+		}
+	}
+
+	qtype = strings.ToUpper(qtype)
+	t, ok := queryTypes[qtype]
+	if !ok {
+		return nil, fmt.Errorf("unsupported query type '%s'", qtype)
+	}
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: p.RecursionDesired},
+		Questions: []dnsmessage.Question{{Name: qname, Type: t, Class: dnsmessage.ClassINET}},
+	}
+	if c.protocol != protocolDoH {
+		// DoH queries should use an ID of 0, so they are cache friendly
+		msg.Header.ID = uint16(rand.Intn(1 << 16)) //nolint:gosec
+	}
+	query, err := msg.Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	tags := state.CloneTags()
+	for k, v := range p.Tags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = c.server
+	}
+	if _, ok := tags["name"]; !ok && state.Options.SystemTags.Has(stats.TagName) {
+		tags["name"] = name
+	}
+	if state.Options.SystemTags.Has(stats.TagProto) {
+		tags["proto"] = c.protocol
+	}
+	tags["type"] = qtype
+
+	startTime := time.Now()
+	raw, err := c.exchange(query, msg.Header.ID, p.Timeout)
+	endTime := time.Now()
+	if err != nil {
+		return nil, err
+	}
+	var resp dnsmessage.Message
+	if err = resp.Unpack(raw); err != nil {
+		return nil, fmt.Errorf("invalid DNS response: %w", err)
+	}
+
+	res := &Response{
+		Rcode:         rcodeName(resp.RCode),
+		Authoritative: resp.Authoritative,
+		Truncated:     resp.Truncated,
+		Answers:       toRecords(resp.Answers),
+		Authorities:   toRecords(resp.Authorities),
+		Additionals:   toRecords(resp.Additionals),
+	}
+	tags["rcode"] = res.Rcode
+	stats.PushIfNotDone(c.vu.Context(), state.Samples, stats.Sample{
+		Metric: state.BuiltinMetrics.DNSQueryDuration,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  stats.D(endTime.Sub(startTime)),
+		Time:   endTime,
+	})
+	return res, nil
+}
+
+// exchange sends the query and returns the raw response.
+func (c *Client) exchange(query []byte, id uint16, timeout time.Duration) ([]byte, error) {
+	if c.protocol == protocolDoH {
+		return c.exchangeHTTPS(query, timeout)
+	}
+
+	if c.conn == nil {
+		// the server may close idle connections, so reconnect if needed
+		if err := c.dial(); err != nil {
+			return nil, err
+		}
+	}
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := c.vu.Context().Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var resp []byte
+	var err error
+	if c.protocol == protocolUDP {
+		resp, err = c.exchangeUDP(query, id)
+	} else {
+		resp, err = c.exchangeStream(query, id)
+	}
+	if err != nil {
+		// the state of stream connections is unknown after an error
+		_ = c.Close()
+	}
+	return resp, err
+}
+
+func (c *Client) exchangeUDP(query []byte, id uint16) ([]byte, error) {
+	if _, err := c.conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, err := c.conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// ignore stray responses, i.e. late responses of timed out queries
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+func (c *Client) exchangeStream(query []byte, id uint16) ([]byte, error) {
+	msg := make([]byte, 2, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	if _, err := c.conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+
+	var size [2]byte
+	if _, err := io.ReadFull(c.conn, size[:]); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, binary.BigEndian.Uint16(size[:]))
+	if _, err := io.ReadFull(c.conn, resp); err != nil {
+		return nil, err
+	}
+	if len(resp) < 2 || binary.BigEndian.Uint16(resp) != id {
+		return nil, errors.New("the DNS response doesn't match the query")
+	}
+	return resp, nil
+}
+
+func (c *Client) exchangeHTTPS(query []byte, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(c.vu.Context(), timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.server, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", dohContentType)
+	req.Header.Set("Accept", dohContentType)
+
+	resp, err := c.vu.State().Transport.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxDoHBodyBytes))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("the DoH server responded with status %d", resp.StatusCode)
+	}
+	return body, nil
+}
+
+// Close closes the connection to the DNS server, if there is one.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+func rcodeName(rcode dnsmessage.RCode) string {
+	if name, ok := rcodeNames[rcode]; ok {
+		return name
+	}
+	return strconv.Itoa(int(rcode))
+}
+
+func typeName(t dnsmessage.Type) string {
+	for name, qt := range queryTypes {
+		if qt == t {
+			return name
+		}
+	}
+	return "TYPE" + strconv.Itoa(int(t))
+}
+
+func toRecords(resources []dnsmessage.Resource) []Record {
+	records := make([]Record, 0, len(resources))
+	for _, r := range resources {
+		if r.Header.Type == dnsmessage.TypeOPT {
+			continue // EDNS pseudo records aren't real records
+		}
+		records = append(records, Record{
+			Name: r.Header.Name.String(),
+			Type: typeName(r.Header.Type),
+			TTL:  r.Header.TTL,
+			Data: recordData(r.Body),
+		})
+	}
+	return records
+}
+
+// recordData returns the data of a record in the presentation format used by
+// zone files, e.g. `10 5 5060 sip.example.com.` for SRV records.
+func recordData(body dnsmessage.ResourceBody) string {
+	switch r := body.(type) {
+	case *dnsmessage.AResource:
+		return net.IP(r.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		return net.IP(r.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		return r.CNAME.String()
+	case *dnsmessage.MXResource:
+		return fmt.Sprintf("%d %s", r.Pref, r.MX.String())
+	case *dnsmessage.NSResource:
+		return r.NS.String()
+	case *dnsmessage.PTRResource:
+		return r.PTR.String()
+	case *dnsmessage.SOAResource:
+		return fmt.Sprintf("%s %s %d %d %d %d %d",
+			r.NS.String(), r.MBox.String(), r.Serial, r.Refresh, r.Retry, r.Expire, r.MinTTL)
+	case *dnsmessage.SRVResource:
+		return fmt.Sprintf("%d %d %d %s", r.Priority, r.Weight, r.Port, r.Target.String())
+	case *dnsmessage.TXTResource:
+		return strings.Join(r.TXT, "")
+	case *dnsmessage.UnknownResource:
+		return fmt.Sprintf(`\# %d %x`, len(r.Data), r.Data)
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,360 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package dns
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// resolve answers the queries of the test servers.
+func resolve(t *testing.T, query []byte) []byte {
+	var msg dnsmessage.Message
+	require.NoError(t, msg.Unpack(query))
+	q := msg.Questions[0]
+	hdr := func(rtype dnsmessage.Type) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: q.Name, Type: rtype, Class: dnsmessage.ClassINET, TTL: 300}
+	}
+
+	resp := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: msg.ID, Response: true, Authoritative: true},
+		Questions: msg.Questions,
+	}
+	target := dnsmessage.MustNewName("sip.example.com.")
+	switch {
+	case q.Name.String() == "missing.example.com.":
+		resp.RCode = dnsmessage.RCodeNameError
+		resp.Authorities = []dnsmessage.Resource{{
+			Header: hdr(dnsmessage.TypeSOA),
+			Body: &dnsmessage.SOAResource{
+				NS: target, MBox: target, Serial: 1, Refresh: 2, Retry: 3, Expire: 4, MinTTL: 5,
+			},
+		}}
+	case q.Type == dnsmessage.TypeA:
+		resp.Answers = []dnsmessage.Resource{
+			{Header: hdr(dnsmessage.TypeA), Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}},
+			{Header: hdr(dnsmessage.TypeA), Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 2}}},
+		}
+	case q.Type == dnsmessage.TypeAAAA:
+		resp.Answers = []dnsmessage.Resource{{
+			Header: hdr(dnsmessage.TypeAAAA),
+			Body:   &dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}},
+		}}
+	case q.Type == dnsmessage.TypeSRV:
+		resp.Answers = []dnsmessage.Resource{{
+			Header: hdr(dnsmessage.TypeSRV),
+			Body:   &dnsmessage.SRVResource{Priority: 10, Weight: 5, Port: 5060, Target: target},
+		}}
+		resp.Additionals = []dnsmessage.Resource{{
+			Header: dnsmessage.ResourceHeader{Name: target, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET},
+			Body:   &dnsmessage.AResource{A: [4]byte{10, 0, 0, 3}},
+		}}
+	case q.Type == dnsmessage.TypeTXT:
+		resp.Answers = []dnsmessage.Resource{{
+			Header: hdr(dnsmessage.TypeTXT),
+			Body:   &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}},
+		}}
+	default:
+		resp.RCode = dnsmessage.RCodeNotImplemented
+	}
+	b, err := resp.Pack()
+	require.NoError(t, err)
+	return b
+}
+
+// listen starts a DNS server and returns its address.
+func listen(t *testing.T, protocol string, tlsConfig *tls.Config) string {
+	if protocol == protocolUDP {
+		pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = pc.Close() })
+		go func() {
+			buf := make([]byte, maxMessageSize)
+			for {
+				n, addr, err := pc.ReadFrom(buf)
+				if err != nil {
+					return
+				}
+				// a stray response which the client should ignore
+				_, _ = pc.WriteTo(resolve(t, append([]byte{0xff ^ buf[0], buf[1]}, buf[2:n]...)), addr)
+				_, _ = pc.WriteTo(resolve(t, buf[:n]), addr)
+			}
+		}()
+		return pc.LocalAddr().String()
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	if protocol == protocolDoT {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				for {
+					var size [2]byte
+					if _, err := io.ReadFull(conn, size[:]); err != nil {
+						return
+					}
+					query := make([]byte, binary.BigEndian.Uint16(size[:]))
+					if _, err := io.ReadFull(conn, query); err != nil {
+						return
+					}
+					resp := resolve(t, query)
+					binary.BigEndian.PutUint16(size[:], uint16(len(resp)))
+					if _, err := conn.Write(append(size[:], resp...)); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+type testState struct {
+	rt      *goja.Runtime
+	vu      *modulestest.VU
+	state   *lib.State
+	samples chan stats.SampleContainer
+	tlsSrv  *httptest.Server
+}
+
+func newTestState(t *testing.T) testState {
+	t.Helper()
+
+	// the TLS test server is used for DoH and its certificate for DoT
+	tlsSrv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dns-query" || r.Header.Get("Content-Type") != dohContentType {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		query, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, []byte{0, 0}, query[:2])
+		w.Header().Set("Content-Type", dohContentType)
+		_, _ = w.Write(resolve(t, query))
+	}))
+	t.Cleanup(tlsSrv.Close)
+	transport := tlsSrv.Client().Transport.(*http.Transport) //nolint:forcetypeassert
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Logger: logrus.New()},
+	}
+	state := &lib.State{
+		Dialer:    &net.Dialer{},
+		Transport: transport,
+		TLSConfig: transport.TLSClientConfig,
+		Samples:   samples,
+		Options: lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagURL, stats.TagName, stats.TagProto),
+		},
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewTagMap(nil),
+	}
+
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("dns", m.Exports().Named))
+	_, err := rt.RunString(`var client = new dns.Client();`)
+	require.NoError(t, err)
+
+	return testState{rt: rt, vu: vu, state: state, samples: samples, tlsSrv: tlsSrv}
+}
+
+func TestClientInitContext(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+
+	_, err := ts.rt.RunString(`client.connect("127.0.0.1")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connecting to a DNS server in the init context is not supported")
+
+	_, err = ts.rt.RunString(`client.query("example.com", "A")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "DNS queries in the init context are not supported")
+}
+
+func TestClientQuery(t *testing.T) {
+	t.Parallel()
+
+	for _, protocol := range []string{protocolUDP, protocolTCP, protocolDoT, protocolDoH} {
+		protocol := protocol
+		t.Run(protocol, func(t *testing.T) {
+			t.Parallel()
+			ts := newTestState(t)
+			ts.vu.StateField = ts.state
+
+			server := ts.tlsSrv.URL + "/dns-query"
+			if protocol != protocolDoH {
+				server = listen(t, protocol, ts.tlsSrv.TLS)
+			}
+			require.NoError(t, ts.rt.Set("server", server))
+			require.NoError(t, ts.rt.Set("protocol", protocol))
+
+			_, err := ts.rt.RunString(`
+				client.connect(server, {protocol: protocol, timeout: "2s"});
+
+				var res = client.query("example.com", "A", {tags: {foo: "bar"}});
+				if (res.rcode !== "NOERROR" || !res.authoritative || res.answers.length !== 2 ||
+					res.answers[1].data !== "10.0.0.2" || res.answers[0].name !== "example.com." ||
+					res.answers[0].type !== "A" || res.answers[0].ttl !== 300) {
+					throw new Error("unexpected A response: " + JSON.stringify(res));
+				}
+				res = client.query("example.com.", "aaaa", {tags: {name: "ipv6"}});
+				if (res.answers[0].data !== "2001:db8::1") {
+					throw new Error("unexpected AAAA response: " + JSON.stringify(res));
+				}
+				res = client.query("_sip._tcp.example.com", "SRV");
+				if (res.answers[0].data !== "10 5 5060 sip.example.com." || res.additionals[0].data !== "10.0.0.3") {
+					throw new Error("unexpected SRV response: " + JSON.stringify(res));
+				}
+				res = client.query("example.com", "TXT");
+				if (res.answers[0].data !== "v=spf1 -all") {
+					throw new Error("unexpected TXT response: " + JSON.stringify(res));
+				}
+				res = client.query("missing.example.com", "A", {recursionDesired: false});
+				if (res.rcode !== "NXDOMAIN" || res.answers.length !== 0 ||
+					res.authorities[0].data !== "sip.example.com. sip.example.com. 1 2 3 4 5") {
+					throw new Error("unexpected NXDOMAIN response: " + JSON.stringify(res));
+				}
+				client.close();
+				// the connection is reopened when needed
+				res = client.query("example.com", "MX");
+				if (res.rcode !== "NOTIMP") { throw new Error("unexpected MX response: " + JSON.stringify(res)); }
+			`)
+			require.NoError(t, err)
+
+			bufSamples := stats.GetBufferedSamples(ts.samples)
+			require.Len(t, bufSamples, 6)
+			var rcodes []string
+			for _, sc := range bufSamples {
+				sample := sc.GetSamples()[0]
+				assert.Equal(t, metrics.DNSQueryDurationName, sample.Metric.Name)
+				rcode, _ := sample.Tags.Get("rcode")
+				rcodes = append(rcodes, rcode)
+			}
+			assert.Equal(t, []string{"NOERROR", "NOERROR", "NOERROR", "NOERROR", "NXDOMAIN", "NOTIMP"}, rcodes)
+			assert.Equal(t, map[string]string{
+				"foo":   "bar",
+				"url":   server,
+				"name":  "example.com.",
+				"proto": protocol,
+				"type":  "A",
+				"rcode": "NOERROR",
+			}, bufSamples[0].GetSamples()[0].Tags.CloneTags())
+			// the name tag of the user isn't overwritten
+			name, _ := bufSamples[1].GetSamples()[0].Tags.Get("name")
+			assert.Equal(t, "ipv6", name)
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+
+	_, err := ts.rt.RunString(`client.query("example.com", "A")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no DNS server, you must call connect first")
+
+	require.NoError(t, ts.rt.Set("server", listen(t, protocolUDP, nil)))
+	require.NoError(t, ts.rt.Set("dohServer", ts.tlsSrv.URL))
+	// the order matters, as some of the cases depend on the previous connect
+	tests := []struct {
+		code, expErr string
+	}{
+		{`client.connect(server, {protocol: "quic"})`, "invalid protocol value"},
+		{`client.connect(server, {foo: 1})`, `unknown connect param: "foo"`},
+		{`client.connect(server, {protocol: "doh"})`, "the doh server must be a https URL"},
+		{`client.connect(dohServer, {protocol: "doh"})`, ""},
+		{`client.query("example.com", "A")`, "the DoH server responded with status 400"},
+		{`client.connect(server)`, ""},
+		{`client.query("example.com", "AXFR")`, "unsupported query type 'AXFR'"},
+		{`client.query("example.com", "A", {foo: 1})`, `unknown param: "foo"`},
+		{`client.query("example.com", "A", {tags: 1})`, "tags must be an object"},
+		{`client.query("a".repeat(64), "A")`, "segment length too long"},
+		{`client.query("example.com", "A", {timeout: "1s"})`, ""},
+	}
+	for _, tc := range tests {
+		_, err := ts.rt.RunString(tc.code)
+		if tc.expErr == "" {
+			assert.NoError(t, err, tc.code)
+			continue
+		}
+		require.Error(t, err, tc.code)
+		assert.Contains(t, err.Error(), tc.expErr, tc.code)
+	}
+}
+
+func TestRecordData(t *testing.T) {
+	t.Parallel()
+
+	name := dnsmessage.MustNewName("example.com.")
+	tests := []struct {
+		body     dnsmessage.ResourceBody
+		expected string
+	}{
+		{&dnsmessage.CNAMEResource{CNAME: name}, "example.com."},
+		{&dnsmessage.MXResource{Pref: 10, MX: name}, "10 example.com."},
+		{&dnsmessage.NSResource{NS: name}, "example.com."},
+		{&dnsmessage.PTRResource{PTR: name}, "example.com."},
+		{&dnsmessage.UnknownResource{Type: 99, Data: []byte{0xab, 0xcd}}, `\# 2 abcd`},
+	}
+	for _, tc := range tests {
+		assert.Equal(t, tc.expected, recordData(tc.body))
+	}
+	assert.Equal(t, "TYPE99", typeName(99))
+	assert.Equal(t, "SRV", typeName(dnsmessage.TypeSRV))
+	assert.Equal(t, "REFUSED", rcodeName(dnsmessage.RCodeRefused))
+	assert.Equal(t, "6", rcodeName(6))
+}
@@ -0,0 +1,162 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package exec
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	osexec "os/exec"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/types"
+)
+
+//nolint:lll
+var (
+	errNotAllowed       = errors.New("executing commands is disabled, it needs to be allowed with the --allow-exec flag")
+	errInInitContext    = common.NewInitContextError("executing commands in the init context is not supported")
+	errOutsideLifecycle = errors.New("commands can only be executed in the setup() and teardown() functions")
+)
+
+const defaultTimeout = time.Minute
+
+// Result is the output and exit code of a command.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+type params struct {
+	Timeout time.Duration
+	Dir     string
+	Env     map[string]string
+	Stdin   string
+}
+
+func parseParams(raw map[string]interface{}) (params, error) {
+	p := params{Timeout: defaultTimeout}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "timeout":
+			if p.Timeout, err = types.GetDurationValue(v); err != nil {
+				return p, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		case "dir", "stdin":
+			s, ok := v.(string)
+			if !ok {
+				return p, fmt.Errorf("invalid %s value: '%#v', it needs to be a string", k, v)
+			}
+			if k == "dir" {
+				p.Dir = s
+			} else {
+				p.Stdin = s
+			}
+		case "env":
+			rawEnv, ok := v.(map[string]interface{})
+			if !ok {
+				return p, errors.New("env must be an object with key-value pairs")
+			}
+			p.Env = make(map[string]string, len(rawEnv))
+			for ek, ev := range rawEnv {
+				s, ok := ev.(string)
+				if !ok {
+					return p, fmt.Errorf("env var %q value must be a string", ek)
+				}
+				p.Env[ek] = s
+			}
+		default:
+			return p, fmt.Errorf("unknown param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// inLifecycleFunction returns whether setup() or teardown() is being run.
+// They're run by VUs with an ID of 0, and in their own top-level groups, so
+// nested groups of the function need to be skipped.
+func inLifecycleFunction(state *lib.State) bool {
+	if state.VUID != 0 || state.Group == nil {
+		return false
+	}
+	g := state.Group
+	for g.Parent != nil && g.Parent.Parent != nil {
+		g = g.Parent
+	}
+	return g.Parent != nil && (g.Name == consts.SetupFn || g.Name == consts.TeardownFn)
+}
+
+// Command runs the named program with the args, without a shell, and returns
+// its output and exit code. A non-zero exit code isn't an error, but the
+// program failing to start, or running longer than the timeout, is.
+func (mi *ModuleInstance) Command(name string, args []string, rawParams map[string]interface{}) (*Result, error) {
+	if !mi.allowed {
+		return nil, errNotAllowed
+	}
+	state := mi.vu.State()
+	if state == nil {
+		return nil, errInInitContext
+	}
+	if !inLifecycleFunction(state) {
+		return nil, errOutsideLifecycle
+	}
+	p, err := parseParams(rawParams)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(mi.vu.Context(), p.Timeout)
+	defer cancel()
+	cmd := osexec.CommandContext(ctx, name, args...) //nolint:gosec // running the user's commands is the point
+	cmd.Dir = p.Dir
+	cmd.Stdin = strings.NewReader(p.Stdin)
+	if len(p.Env) > 0 {
+		cmd.Env = os.Environ()
+		for k, v := range p.Env {
+			cmd.Env = append(cmd.Env, k+"="+v)
+		}
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	state.Logger.WithField("command", cmd.String()).Debug("Executing a command")
+	err = cmd.Run()
+	if ctx.Err() != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("the command %q timed out after %s", name, p.Timeout)
+	}
+	var exitErr *osexec.ExitError
+	if err != nil && !errors.As(err, &exitErr) {
+		return nil, err
+	}
+	return &Result{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: cmd.ProcessState.ExitCode(),
+	}, nil
+}
@@ -0,0 +1,160 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package exec
+
+import (
+	"context"
+	"runtime"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/consts"
+)
+
+func newTestRuntime(t *testing.T, allowExec bool) (*goja.Runtime, *modulestest.VU) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("the tests run unix commands")
+	}
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{
+			Logger:         logrus.New(),
+			RuntimeOptions: lib.RuntimeOptions{AllowExec: null.BoolFrom(allowExec)},
+		},
+	}
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("exec", m.Exports().Named))
+	return rt, vu
+}
+
+// newState returns the state of a VU running the named function, in a group
+// with the nested group names.
+func newState(t *testing.T, vuID uint64, fn string, groups ...string) *lib.State {
+	t.Helper()
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	g, err := root.Group(fn)
+	require.NoError(t, err)
+	for _, name := range groups {
+		g, err = g.Group(name)
+		require.NoError(t, err)
+	}
+	return &lib.State{VUID: vuID, Group: g, Logger: logrus.New(), Tags: lib.NewTagMap(nil)}
+}
+
+func TestCommand(t *testing.T) {
+	t.Parallel()
+	rt, vu := newTestRuntime(t, true)
+	vu.StateField = newState(t, 0, consts.SetupFn)
+
+	v, err := rt.RunString(`
+		var res = exec.command("sh", ["-c", "echo $GREETING $(cat); echo oops >&2; exit 3"], {
+			stdin: "world", env: {GREETING: "hello"}, timeout: "10s",
+		});
+		[res.stdout, res.stderr, res.exit_code];
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"hello world\n", "oops\n", int64(3)}, v.Export())
+
+	v, err = rt.RunString(`exec.command("pwd", [], {dir: "/"}).stdout`)
+	require.NoError(t, err)
+	assert.Equal(t, "/\n", v.String())
+
+	v, err = rt.RunString(`exec.command("true").exit_code`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), v.Export())
+}
+
+func TestCommandLifecycle(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		name   string
+		state  *lib.State
+		expErr string
+	}{
+		{"setup", newState(t, 0, consts.SetupFn), ""},
+		{"teardown", newState(t, 0, consts.TeardownFn), ""},
+		{"setup group", newState(t, 0, consts.SetupFn, "prepare", "terraform"), ""},
+		{"default", newState(t, 1, "setup"), "commands can only be executed in the setup() and teardown() functions"},
+		{"summary", newState(t, 0, "handleSummary"), "commands can only be executed in the setup() and teardown()"},
+		{"init", nil, "executing commands in the init context is not supported"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			rt, vu := newTestRuntime(t, true)
+			if tc.state != nil {
+				vu.StateField = tc.state
+			}
+			_, err := rt.RunString(`exec.command("true")`)
+			if tc.expErr == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr)
+		})
+	}
+}
+
+func TestCommandErrors(t *testing.T) {
+	t.Parallel()
+
+	rt, vu := newTestRuntime(t, false)
+	vu.StateField = newState(t, 0, consts.SetupFn)
+	_, err := rt.RunString(`exec.command("true")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "it needs to be allowed with the --allow-exec flag")
+
+	rt, vu = newTestRuntime(t, true)
+	vu.StateField = newState(t, 0, consts.TeardownFn)
+	errCases := []struct {
+		code, expErr string
+	}{
+		{`exec.command("sleep", ["10"], {timeout: "100ms"})`, `the command "sleep" timed out after 100ms`},
+		{`exec.command("k6-no-such-command")`, `"k6-no-such-command": executable file not found`},
+		{`exec.command("true", [], {shell: true})`, `unknown param: "shell"`},
+		{`exec.command("true", [], {timeout: "soon"})`, "invalid timeout value"},
+		{`exec.command("true", [], {dir: 1})`, "invalid dir value"},
+		{`exec.command("true", [], {env: {A: 1}})`, `env var "A" value must be a string`},
+	}
+	for _, tc := range errCases {
+		_, err := rt.RunString(tc.code)
+		require.Error(t, err, tc.code)
+		assert.Contains(t, err.Error(), tc.expErr, tc.code)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package filetransfer contains the parts that are shared by the modules
+// which transfer files, like the FTP and the SFTP clients: the parsing of
+// the batch transfers and running them in parallel.
+package filetransfer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+)
+
+// The methods of a transfer.
+const (
+	MethodUpload   = "upload"
+	MethodDownload = "download"
+)
+
+// Transfer is a single upload or download.
+type Transfer struct {
+	Method string
+	Path   string
+	// Data is the uploaded data, or the downloaded data after the transfer.
+	Data []byte
+	// Binary downloads are returned as an ArrayBuffer instead of a string.
+	Binary bool
+}
+
+// Worker transfers files over a single connection or session.
+type Worker interface {
+	Upload(path string, data []byte) error
+	Download(path string) ([]byte, error)
+}
+
+// Do runs the transfer with the worker and returns how long it took.
+func Do(w Worker, t *Transfer) (time.Duration, error) {
+	start := time.Now()
+	var err error
+	if t.Method == MethodUpload {
+		err = w.Upload(t.Path, t.Data)
+	} else {
+		t.Data, err = w.Download(t.Path)
+	}
+	return time.Since(start), err
+}
+
+// Throughput returns the throughput of a transfer in bytes per second.
+func Throughput(size int, duration time.Duration) float64 {
+	if duration <= 0 {
+		return 0
+	}
+	return float64(size) / duration.Seconds()
+}
+
+// ParseBatch parses an array of transfers like
+// `[{method: "upload", path: "/a", data: "..."}, {method: "download", path: "/b"}]`.
+func ParseBatch(v goja.Value) ([]*Transfer, error) {
+	var raw []interface{}
+	if v != nil && !goja.IsUndefined(v) && !goja.IsNull(v) {
+		var ok bool
+		if raw, ok = v.Export().([]interface{}); !ok {
+			return nil, errors.New("the transfers must be an array of objects")
+		}
+	}
+
+	transfers := make([]*Transfer, 0, len(raw))
+	for i, r := range raw {
+		obj, ok := r.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("transfer %d must be an object", i)
+		}
+		t := &Transfer{}
+		for k, v := range obj {
+			var err error
+			switch k {
+			case "method":
+				t.Method, _ = v.(string)
+				if t.Method != MethodUpload && t.Method != MethodDownload {
+					return nil, fmt.Errorf(
+						"transfer %d has an invalid method '%v', it needs to be 'upload' or 'download'", i, v)
+				}
+			case "path":
+				if t.Path, ok = v.(string); !ok || t.Path == "" {
+					return nil, fmt.Errorf("transfer %d has an invalid path '%v'", i, v)
+				}
+			case "data":
+				if t.Data, err = common.ToBytes(v); err != nil {
+					return nil, fmt.Errorf("transfer %d has invalid data: %w", i, err)
+				}
+			case "responseType":
+				if v != "text" && v != "binary" {
+					return nil, fmt.Errorf(
+						"transfer %d has an invalid responseType '%v', it needs to be 'text' or 'binary'", i, v)
+				}
+				t.Binary = v == "binary"
+			default:
+				return nil, fmt.Errorf("transfer %d has an unknown property %q", i, k)
+			}
+		}
+		if t.Method == "" || t.Path == "" {
+			return nil, fmt.Errorf("transfer %d must have a method and a path", i)
+		}
+		transfers = append(transfers, t)
+	}
+	return transfers, nil
+}
+
+// RunBatch runs the transfers with the workers in parallel, every worker
+// doing a single transfer at a time. The done callback is called after every
+// successful transfer, possibly concurrently. The first error stops the
+// remaining transfers and is returned.
+func RunBatch(
+	ctx context.Context, transfers []*Transfer, workers []Worker, done func(*Transfer, time.Duration),
+) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	queue := make(chan *Transfer, len(transfers))
+	for _, t := range transfers {
+		queue <- t
+	}
+	close(queue)
+
+	var (
+		wg       sync.WaitGroup
+		errOnce  sync.Once
+		firstErr error
+	)
+	for _, w := range workers {
+		wg.Add(1)
+		go func(w Worker) {
+			defer wg.Done()
+			for t := range queue {
+				if ctx.Err() != nil {
+					return
+				}
+				duration, err := Do(w, t)
+				if err != nil {
+					errOnce.Do(func() {
+						firstErr = fmt.Errorf("%s of %s failed: %w", t.Method, t.Path, err)
+						cancel()
+					})
+					return
+				}
+				done(t, duration)
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	if firstErr == nil && ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return firstErr
+}
+
+// Results returns the JS values of the transfers, the downloaded data or
+// null for the uploads.
+func Results(rt *goja.Runtime, transfers []*Transfer) []interface{} {
+	results := make([]interface{}, len(transfers))
+	for i, t := range transfers {
+		switch {
+		case t.Method == MethodUpload:
+			results[i] = nil
+		case t.Binary:
+			ab := rt.NewArrayBuffer(t.Data)
+			results[i] = &ab
+		default:
+			results[i] = string(t.Data)
+		}
+	}
+	return results
+}
@@ -0,0 +1,152 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package filetransfer
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memWorker is a worker that stores the files in memory.
+type memWorker struct {
+	mu    *sync.Mutex
+	files map[string][]byte
+	fail  string
+}
+
+func (w memWorker) Upload(path string, data []byte) error {
+	if path == w.fail {
+		return errors.New("boom")
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.files[path] = data
+	return nil
+}
+
+func (w memWorker) Download(path string) ([]byte, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	data, ok := w.files[path]
+	if !ok || path == w.fail {
+		return nil, errors.New("no such file")
+	}
+	return data, nil
+}
+
+func TestParseBatch(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+
+	v, err := rt.RunString(`[
+		{method: "upload", path: "/a", data: "hello"},
+		{method: "upload", path: "/b", data: new Uint8Array([1, 2]).buffer},
+		{method: "download", path: "/c", responseType: "binary"},
+	]`)
+	require.NoError(t, err)
+	transfers, err := ParseBatch(v)
+	require.NoError(t, err)
+	assert.Equal(t, []*Transfer{
+		{Method: MethodUpload, Path: "/a", Data: []byte("hello")},
+		{Method: MethodUpload, Path: "/b", Data: []byte{1, 2}},
+		{Method: MethodDownload, Path: "/c", Binary: true},
+	}, transfers)
+
+	transfers, err = ParseBatch(goja.Undefined())
+	require.NoError(t, err)
+	assert.Empty(t, transfers)
+
+	errCases := []struct {
+		code, expErr string
+	}{
+		{`"nope"`, "the transfers must be an array of objects"},
+		{`[1]`, "transfer 0 must be an object"},
+		{`[{method: "delete", path: "/a"}]`, "transfer 0 has an invalid method 'delete'"},
+		{`[{method: "upload", path: ""}]`, "transfer 0 has an invalid path ''"},
+		{`[{method: "download", path: "/a", responseType: "json"}]`, "transfer 0 has an invalid responseType 'json'"},
+		{`[{method: "download", path: "/a", mode: 1}]`, `transfer 0 has an unknown property "mode"`},
+		{`[{method: "download", path: "/a"}, {method: "download"}]`, "transfer 1 must have a method and a path"},
+	}
+	for _, tc := range errCases {
+		v, err := rt.RunString(tc.code)
+		require.NoError(t, err)
+		_, err = ParseBatch(v)
+		require.Error(t, err, tc.code)
+		assert.Contains(t, err.Error(), tc.expErr, tc.code)
+	}
+}
+
+func TestRunBatch(t *testing.T) {
+	t.Parallel()
+	mu := &sync.Mutex{}
+	files := map[string][]byte{"/existing": []byte("data")}
+	workers := []Worker{memWorker{mu: mu, files: files}, memWorker{mu: mu, files: files}}
+
+	transfers := []*Transfer{{Method: MethodDownload, Path: "/existing"}}
+	for _, p := range []string{"/a", "/b", "/c"} {
+		transfers = append(transfers, &Transfer{Method: MethodUpload, Path: p, Data: []byte(p)})
+	}
+	var (
+		doneMu sync.Mutex
+		done   int
+	)
+	err := RunBatch(context.Background(), transfers, workers, func(*Transfer, time.Duration) {
+		doneMu.Lock()
+		done++
+		doneMu.Unlock()
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 4, done)
+	assert.Equal(t, []byte("data"), transfers[0].Data)
+	assert.Equal(t, []byte("/c"), files["/c"])
+
+	rt := goja.New()
+	transfers[0].Binary = true
+	results := Results(rt, transfers[:2])
+	require.Len(t, results, 2)
+	ab, ok := results[0].(*goja.ArrayBuffer)
+	require.True(t, ok)
+	assert.Equal(t, []byte("data"), ab.Bytes())
+	assert.Nil(t, results[1])
+
+	workers = []Worker{memWorker{mu: mu, files: files, fail: "/a"}}
+	err = RunBatch(context.Background(), transfers, workers, func(*Transfer, time.Duration) {})
+	require.Error(t, err)
+	assert.Equal(t, "upload of /a failed: boom", err.Error())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = RunBatch(ctx, transfers, workers, func(*Transfer, time.Duration) {})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestThroughput(t *testing.T) {
+	t.Parallel()
+	assert.Equal(t, 2048.0, Throughput(1024, 500*time.Millisecond))
+	assert.Equal(t, 0.0, Throughput(1024, 0))
+}
@@ -0,0 +1,364 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ftp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/js/modules/k6/experimental/filetransfer"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+//nolint:lll
+var (
+	errOperationInInitContext = common.NewInitContextError("FTP operations in the init context are not supported")
+	errConnectInInitContext   = common.NewInitContextError("connecting to a FTP server in the init context is not supported")
+)
+
+const (
+	defaultPort         = "21"
+	defaultImplicitPort = "990"
+	defaultTimeout      = time.Minute
+	defaultParallel     = 4
+	anonymousUser       = "anonymous"
+)
+
+// Client represents a connection to a FTP server.
+type Client struct {
+	vu   modules.VU
+	addr string
+	opts connOptions
+	conn *conn
+}
+
+type params struct {
+	Tags         map[string]string
+	Timeout      time.Duration
+	ResponseType string
+	Parallel     int
+}
+
+func parseTags(v interface{}) (map[string]string, error) {
+	rawTags, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("tags must be an object with key-value pairs")
+	}
+	tags := make(map[string]string, len(rawTags))
+	for tk, tv := range rawTags {
+		strVal, ok := tv.(string)
+		if !ok {
+			return nil, fmt.Errorf("tag %q value must be a string", tk)
+		}
+		tags[tk] = strVal
+	}
+	return tags, nil
+}
+
+// parseParams parses the params of an operation, allowed lists the params
+// that are specific to the operation.
+func (c *Client) parseParams(raw map[string]interface{}, allowed ...string) (params, error) {
+	p := params{Timeout: c.opts.timeout, ResponseType: "text", Parallel: defaultParallel}
+	for k, v := range raw {
+		isAllowed := k == "tags" || k == "timeout"
+		for _, a := range allowed {
+			isAllowed = isAllowed || k == a
+		}
+		if !isAllowed {
+			return p, fmt.Errorf("unknown param: %q", k)
+		}
+
+		var err error
+		switch k {
+		case "tags":
+			if p.Tags, err = parseTags(v); err != nil {
+				return p, err
+			}
+		case "timeout":
+			if p.Timeout, err = types.GetDurationValue(v); err != nil {
+				return p, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		case "responseType":
+			p.ResponseType, _ = v.(string)
+			if p.ResponseType != "text" && p.ResponseType != "binary" {
+				return p, fmt.Errorf("invalid responseType value: '%#v', it needs to be 'text' or 'binary'", v)
+			}
+		case "parallel":
+			n, ok := v.(int64)
+			if !ok || n < 1 {
+				return p, fmt.Errorf("invalid parallel value: '%#v', it needs to be a positive integer", v)
+			}
+			p.Parallel = int(n)
+		}
+	}
+	return p, nil
+}
+
+func parseConnectParams(raw map[string]interface{}) (connOptions, error) {
+	opts := connOptions{username: anonymousUser, security: securityNone, timeout: defaultTimeout}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "username", "password", "security":
+			s, ok := v.(string)
+			if !ok {
+				return opts, fmt.Errorf("invalid %s value: '%#v', it needs to be a string", k, v)
+			}
+			switch k {
+			case "username":
+				opts.username = s
+			case "password":
+				opts.password = s
+			case "security":
+				if s != securityNone && s != securityExplicit && s != securityImplicit {
+					return opts, fmt.Errorf(
+						"invalid security value: '%s', it needs to be 'none', 'explicit' or 'implicit'", s)
+				}
+				opts.security = s
+			}
+		case "timeout":
+			if opts.timeout, err = types.GetDurationValue(v); err != nil {
+				return opts, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		default:
+			return opts, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return opts, nil
+}
+
+// Connect connects and logs in to the FTP server at the given address. The
+// login is anonymous unless a username is given.
+func (c *Client) Connect(addr string, rawParams map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	opts, err := parseConnectParams(rawParams)
+	if err != nil {
+		return err
+	}
+	opts.tlsConfig = state.TLSConfig
+	if _, _, err = net.SplitHostPort(addr); err != nil {
+		port := defaultPort
+		if opts.security == securityImplicit {
+			port = defaultImplicitPort
+		}
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), port)
+	}
+
+	_ = c.Close()
+	conn, err := dial(c.vu.Context(), state.Dialer, addr, opts)
+	if err != nil {
+		return err
+	}
+	c.addr, c.opts, c.conn = addr, opts, conn
+	return nil
+}
+
+// Upload stores the data, a string or an ArrayBuffer, in the file at the
+// remote path.
+func (c *Client) Upload(remotePath string, data goja.Value, rawParams map[string]interface{}) error {
+	p, err := c.parseParams(rawParams)
+	if err != nil {
+		return err
+	}
+	var exported interface{}
+	if data != nil {
+		exported = data.Export()
+	}
+	b, err := common.ToBytes(exported)
+	if err != nil {
+		return err
+	}
+	return c.transfer(&filetransfer.Transfer{Method: filetransfer.MethodUpload, Path: remotePath, Data: b}, p)
+}
+
+// Download retrieves the file at the remote path, it's returned as a string
+// unless the responseType param is binary.
+func (c *Client) Download(remotePath string, rawParams map[string]interface{}) (interface{}, error) {
+	p, err := c.parseParams(rawParams, "responseType")
+	if err != nil {
+		return nil, err
+	}
+	t := &filetransfer.Transfer{
+		Method: filetransfer.MethodDownload, Path: remotePath, Binary: p.ResponseType == "binary",
+	}
+	if err = c.transfer(t, p); err != nil {
+		return nil, err
+	}
+	return filetransfer.Results(c.vu.Runtime(), []*filetransfer.Transfer{t})[0], nil
+}
+
+func (c *Client) transfer(t *filetransfer.Transfer, p params) error {
+	tags, err := c.tags(p)
+	if err != nil {
+		return err
+	}
+	c.conn.opts.timeout = p.Timeout
+	duration, err := filetransfer.Do(c.conn, t)
+	c.conn.opts.timeout = c.opts.timeout
+	if err != nil {
+		c.checkConn(err)
+		return err
+	}
+	c.pushTransfer(tags, t, duration)
+	return nil
+}
+
+// Batch runs the uploads and downloads in parallel, each parallel transfer
+// using its own connection. The results are the downloaded files, or null
+// for the uploads.
+func (c *Client) Batch(transfers goja.Value, rawParams map[string]interface{}) ([]interface{}, error) {
+	p, err := c.parseParams(rawParams, "parallel")
+	if err != nil {
+		return nil, err
+	}
+	batch, err := filetransfer.ParseBatch(transfers)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := c.tags(p)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := c.opts
+	opts.timeout = p.Timeout
+	conns := []*conn{c.conn}
+	defer func() {
+		for _, conn := range conns[1:] {
+			_ = conn.close()
+		}
+	}()
+	for len(conns) < p.Parallel && len(conns) < len(batch) {
+		conn, err := dial(c.vu.Context(), c.vu.State().Dialer, c.addr, opts)
+		if err != nil {
+			return nil, err
+		}
+		conns = append(conns, conn)
+	}
+	workers := make([]filetransfer.Worker, len(conns))
+	for i, conn := range conns {
+		conn.opts.timeout = p.Timeout
+		workers[i] = conn
+	}
+
+	err = filetransfer.RunBatch(c.vu.Context(), batch, workers, func(t *filetransfer.Transfer, d time.Duration) {
+		c.pushTransfer(tags, t, d)
+	})
+	c.conn.opts.timeout = c.opts.timeout
+	if err != nil {
+		c.checkConn(err)
+		return nil, err
+	}
+	return filetransfer.Results(c.vu.Runtime(), batch), nil
+}
+
+// checkConn closes the connection after an error, unless the error is a
+// negative reply of the server, after which the connection is still usable.
+func (c *Client) checkConn(err error) {
+	var replyErr *replyError
+	if errors.As(err, &replyErr) {
+		return
+	}
+	_ = c.conn.conn.Close()
+	c.conn = nil
+}
+
+// tags returns the tags of the samples of an operation, the method tag is
+// set when the samples are emitted.
+func (c *Client) tags(p params) (map[string]string, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errOperationInInitContext
+	}
+	if c.conn == nil {
+		return nil, errors.New("no FTP connection, you must call connect first")
+	}
+
+	tags := state.CloneTags()
+	for k, v := range p.Tags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = c.addr
+	}
+	if state.Options.SystemTags.Has(stats.TagProto) {
+		tags["proto"] = "ftp"
+		if c.opts.security != securityNone {
+			tags["proto"] = "ftps"
+		}
+	}
+	return tags, nil
+}
+
+// pushTransfer emits the duration and the throughput of a transfer. It may
+// be called concurrently by the transfers of a batch.
+func (c *Client) pushTransfer(baseTags map[string]string, t *filetransfer.Transfer, duration time.Duration) {
+	state := c.vu.State()
+	tags := make(map[string]string, len(baseTags)+1)
+	for k, v := range baseTags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagMethod) {
+		tags["method"] = t.Method
+	}
+
+	now := time.Now()
+	sampleTags := stats.IntoSampleTags(&tags)
+	stats.PushIfNotDone(c.vu.Context(), state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{
+				Metric: state.BuiltinMetrics.FTPReqDuration,
+				Tags:   sampleTags,
+				Value:  stats.D(duration),
+				Time:   now,
+			},
+			{
+				Metric: state.BuiltinMetrics.FileTransferThroughput,
+				Tags:   sampleTags,
+				Value:  filetransfer.Throughput(len(t.Data), duration),
+				Time:   now,
+			},
+		},
+		Tags: sampleTags,
+		Time: now,
+	})
+}
+
+// Close logs out and closes the connection to the FTP server.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.close()
+	c.conn = nil
+	return err
+}
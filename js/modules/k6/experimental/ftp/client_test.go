@@ -0,0 +1,227 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ftp
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+type testState struct {
+	rt      *goja.Runtime
+	vu      *modulestest.VU
+	state   *lib.State
+	samples chan stats.SampleContainer
+	tlsSrv  *httptest.Server
+}
+
+func newTestState(t *testing.T) testState {
+	t.Helper()
+
+	// borrow the self-signed certificate of a TLS test server
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(tlsSrv.Close)
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Logger: logrus.New()},
+	}
+	state := &lib.State{
+		Dialer:    &net.Dialer{},
+		TLSConfig: tlsSrv.Client().Transport.(*http.Transport).TLSClientConfig, //nolint:forcetypeassert
+		Samples:   samples,
+		Options: lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagURL, stats.TagMethod, stats.TagProto),
+		},
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewTagMap(nil),
+	}
+
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("ftp", m.Exports().Named))
+	_, err := rt.RunString(`var client = new ftp.Client();`)
+	require.NoError(t, err)
+
+	return testState{rt: rt, vu: vu, state: state, samples: samples, tlsSrv: tlsSrv}
+}
+
+func TestClientInitContext(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+
+	_, err := ts.rt.RunString(`client.connect("127.0.0.1")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connecting to a FTP server in the init context is not supported")
+
+	_, err = ts.rt.RunString(`client.download("/pub/motd")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "FTP operations in the init context are not supported")
+}
+
+func TestClientTransfers(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		security string
+		pasvOnly bool
+	}{
+		"plain":    {security: securityNone},
+		"pasv":     {security: securityNone, pasvOnly: true},
+		"explicit": {security: securityExplicit},
+		"implicit": {security: securityImplicit},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ts := newTestState(t)
+			ts.vu.StateField = ts.state
+			srv := newServer(t, tc.security, ts.tlsSrv.TLS, tc.pasvOnly)
+			big := strings.Repeat("0123456789", 100000)
+			require.NoError(t, ts.rt.Set("addr", srv.addr))
+			require.NoError(t, ts.rt.Set("security", tc.security))
+			require.NoError(t, ts.rt.Set("big", big))
+
+			_, err := ts.rt.RunString(`
+				client.connect(addr, {username: "k6", password: "secret", security: security});
+
+				var motd = client.download("/pub/motd", {tags: {foo: "bar"}});
+				if (motd !== "hello") { throw new Error("unexpected download: " + motd); }
+				var bin = client.download("/pub/motd", {responseType: "binary"});
+				if (!(bin instanceof ArrayBuffer) || bin.byteLength !== 5) {
+					throw new Error("unexpected binary download: " + bin);
+				}
+				client.upload("/incoming/big", big);
+
+				var res = client.batch([
+					{method: "download", path: "/incoming/big"},
+					{method: "upload", path: "/incoming/a", data: "a"},
+					{method: "upload", path: "/incoming/b", data: new Uint8Array([1, 2]).buffer},
+					{method: "download", path: "/pub/motd", responseType: "binary"},
+					{method: "download", path: "/pub/motd"},
+				], {parallel: 3});
+				if (res.length !== 5 || res[0] !== big || res[1] !== null || res[2] !== null ||
+					res[3].byteLength !== 5 || res[4] !== "hello") {
+					throw new Error("unexpected batch results");
+				}
+				client.close();
+			`)
+			require.NoError(t, err)
+
+			data, _ := srv.file("/incoming/big")
+			assert.Equal(t, big, string(data))
+			data, _ = srv.file("/incoming/b")
+			assert.Equal(t, []byte{1, 2}, data)
+			assert.Equal(t, 3, srv.connCount()) // the two extra connections of the batch
+
+			bufSamples := stats.GetBufferedSamples(ts.samples)
+			require.Len(t, bufSamples, 8)
+			proto := "ftps"
+			if tc.security == securityNone {
+				proto = "ftp"
+			}
+			for _, sc := range bufSamples {
+				samples := sc.GetSamples()
+				require.Len(t, samples, 2)
+				assert.Equal(t, metrics.FTPReqDurationName, samples[0].Metric.Name)
+				assert.Equal(t, metrics.FileTransferThroughputName, samples[1].Metric.Name)
+				assert.Greater(t, samples[1].Value, 0.0)
+				p, _ := samples[0].Tags.Get("proto")
+				assert.Equal(t, proto, p)
+			}
+			assert.Equal(t, map[string]string{
+				"foo": "bar", "url": srv.addr, "method": "download", "proto": proto,
+			}, bufSamples[0].GetSamples()[0].Tags.CloneTags())
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+
+	_, err := ts.rt.RunString(`client.download("/pub/motd")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no FTP connection, you must call connect first")
+
+	srv := newServer(t, securityNone, nil, false)
+	require.NoError(t, ts.rt.Set("addr", srv.addr))
+	// the order matters, as some of the cases depend on the previous connect
+	tests := []struct {
+		code, expErr string
+	}{
+		{`client.connect(addr, {username: "k6", password: "wrong"})`, "530 login incorrect"},
+		{`client.connect(addr, {security: "ssl"})`, "invalid security value"},
+		{`client.connect(addr, {security: "explicit"})`, "502 not supported"},
+		{`client.connect(addr, {foo: 1})`, `unknown connect param: "foo"`},
+		{`client.connect(addr)`, ""}, // anonymous
+		{`client.download("/missing")`, "550 file not found"},
+		{`client.download("/pub/motd", {parallel: 2})`, `unknown param: "parallel"`},
+		{`client.upload("/x", 1)`, "invalid type int64"},
+		{`client.batch([{method: "delete", path: "/x"}])`, "transfer 0 has an invalid method 'delete'"},
+		{`client.batch([{method: "upload", path: "/x"}], {parallel: 0})`, "invalid parallel value"},
+		{`client.batch([{method: "download", path: "/missing"}])`, "download of /missing failed: 550"},
+		// the connection is still usable after negative replies
+		{`client.download("/pub/motd")`, ""},
+	}
+	for _, tc := range tests {
+		_, err := ts.rt.RunString(tc.code)
+		if tc.expErr == "" {
+			assert.NoError(t, err, tc.code)
+			continue
+		}
+		require.Error(t, err, tc.code)
+		assert.Contains(t, err.Error(), tc.expErr, tc.code)
+	}
+}
+
+func TestParsePASV(t *testing.T) {
+	t.Parallel()
+
+	port, err := parsePASV("Entering Passive Mode (127,0,0,1,195,80).")
+	require.NoError(t, err)
+	assert.Equal(t, "50000", port)
+
+	for _, msg := range []string{"Entering Passive Mode", "(127,0,0,1,195)", "(127,0,0,1,300,80)", "(1,2,3,4,a,b)"} {
+		_, err = parsePASV(msg)
+		assert.Error(t, err, msg)
+	}
+}
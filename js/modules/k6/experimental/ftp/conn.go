@@ -0,0 +1,301 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ftp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.k6.io/k6/lib"
+)
+
+// The security of the connections, FTPS is either explicit (RFC 4217) with
+// AUTH TLS, or implicit, with TLS from the start like HTTPS.
+const (
+	securityNone     = "none"
+	securityExplicit = "explicit"
+	securityImplicit = "implicit"
+)
+
+// replyError is a negative, or unexpected, reply of the server.
+type replyError struct {
+	code int
+	msg  string
+}
+
+func (e *replyError) Error() string {
+	return fmt.Sprintf("%d %s", e.code, e.msg)
+}
+
+type connOptions struct {
+	username  string
+	password  string
+	security  string
+	tlsConfig *tls.Config
+	timeout   time.Duration
+}
+
+// conn is a FTP control connection, the data connections are opened in
+// passive mode for every transfer.
+type conn struct {
+	dialer  lib.DialContexter
+	ctx     context.Context
+	host    string
+	conn    net.Conn
+	text    *textproto.Conn
+	opts    connOptions
+	tlsData bool
+}
+
+// dial connects and logs in to the FTP server at addr.
+func dial(ctx context.Context, dialer lib.DialContexter, addr string, opts connOptions) (*conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if opts.security != securityNone {
+		opts.tlsConfig = opts.tlsConfig.Clone()
+		if opts.tlsConfig == nil {
+			opts.tlsConfig = &tls.Config{} //nolint:gosec // the defaults are used when there's no config
+		}
+		if opts.tlsConfig.ServerName == "" {
+			opts.tlsConfig.ServerName = host
+		}
+		// many servers require the data connections to resume the TLS
+		// session of the control connection
+		if opts.tlsConfig.ClientSessionCache == nil {
+			opts.tlsConfig.ClientSessionCache = tls.NewLRUClientSessionCache(1)
+		}
+	}
+
+	c := &conn{dialer: dialer, ctx: ctx, host: host, opts: opts}
+	if c.conn, err = c.dialConn(addr, opts.security == securityImplicit); err != nil {
+		return nil, err
+	}
+	c.text = textproto.NewConn(c.conn)
+	if err = c.login(); err != nil {
+		_ = c.conn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *conn) dialConn(addr string, withTLS bool) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.opts.timeout)
+	defer cancel()
+	netConn, err := c.dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	if err = netConn.SetDeadline(time.Now().Add(c.opts.timeout)); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	if !withTLS {
+		return netConn, nil
+	}
+	tlsConn := tls.Client(netConn, c.opts.tlsConfig)
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		_ = netConn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+func (c *conn) login() error {
+	if _, _, err := c.readResponse(220); err != nil {
+		return err
+	}
+	if c.opts.security == securityExplicit {
+		if _, _, err := c.cmd([]int{234}, "AUTH TLS"); err != nil {
+			return err
+		}
+		tlsConn := tls.Client(c.conn, c.opts.tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return err
+		}
+		c.conn, c.text = tlsConn, textproto.NewConn(tlsConn)
+	}
+
+	code, _, err := c.cmd([]int{230, 331}, "USER %s", c.opts.username)
+	if err != nil {
+		return err
+	}
+	if code == 331 {
+		if _, _, err = c.cmd([]int{230, 202}, "PASS %s", c.opts.password); err != nil {
+			return err
+		}
+	}
+	if c.opts.security != securityNone {
+		if _, _, err = c.cmd([]int{200}, "PBSZ 0"); err != nil {
+			return err
+		}
+		if _, _, err = c.cmd([]int{200}, "PROT P"); err != nil {
+			return err
+		}
+		c.tlsData = true
+	}
+	_, _, err = c.cmd([]int{200}, "TYPE I")
+	return err
+}
+
+// readResponse reads a response and checks that its code is one of the
+// expected ones.
+func (c *conn) readResponse(expected ...int) (int, string, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.opts.timeout)); err != nil {
+		return 0, "", err
+	}
+	code, msg, err := c.text.ReadResponse(0)
+	var protoErr *textproto.Error
+	if err != nil && !errors.As(err, &protoErr) {
+		return 0, "", err
+	}
+	for _, e := range expected {
+		if code == e {
+			return code, msg, nil
+		}
+	}
+	return code, msg, &replyError{code: code, msg: msg}
+}
+
+func (c *conn) cmd(expected []int, format string, args ...interface{}) (int, string, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.opts.timeout)); err != nil {
+		return 0, "", err
+	}
+	if err := c.text.PrintfLine(format, args...); err != nil {
+		return 0, "", err
+	}
+	return c.readResponse(expected...)
+}
+
+// passive opens a data connection with EPSV, or PASV if the server doesn't
+// support it, without TLS. The address in the PASV response is ignored, like most clients
+// do, as it's often wrong behind NATs.
+func (c *conn) passive() (net.Conn, error) {
+	code, msg, err := c.cmd([]int{229}, "EPSV")
+	var port string
+	switch {
+	case err == nil:
+		start, end := strings.Index(msg, "(|||"), strings.LastIndex(msg, "|)")
+		if start == -1 || end < start+4 {
+			return nil, fmt.Errorf("invalid EPSV response: %s", msg)
+		}
+		port = msg[start+4 : end]
+	case code >= 500 && code < 600:
+		if _, msg, err = c.cmd([]int{227}, "PASV"); err != nil {
+			return nil, err
+		}
+		if port, err = parsePASV(msg); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+	return c.dialConn(net.JoinHostPort(c.host, port), false)
+}
+
+// openData opens a data connection and sends the transfer command. With
+// protected data connections, the TLS handshake is done after the server's
+// preliminary reply, since servers only start it after accepting the command.
+func (c *conn) openData(format string, args ...interface{}) (net.Conn, error) {
+	dataConn, err := c.passive()
+	if err != nil {
+		return nil, err
+	}
+	if _, _, err = c.cmd([]int{125, 150}, format, args...); err != nil {
+		_ = dataConn.Close()
+		return nil, err
+	}
+	if !c.tlsData {
+		return dataConn, nil
+	}
+	tlsConn := tls.Client(dataConn, c.opts.tlsConfig)
+	if err = tlsConn.HandshakeContext(c.ctx); err != nil {
+		_ = dataConn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// parsePASV returns the port of a response like
+// `Entering Passive Mode (127,0,0,1,195,80)`.
+func parsePASV(msg string) (string, error) {
+	start, end := strings.Index(msg, "("), strings.LastIndex(msg, ")")
+	if start == -1 || end < start {
+		return "", fmt.Errorf("invalid PASV response: %s", msg)
+	}
+	parts := strings.Split(msg[start+1:end], ",")
+	if len(parts) != 6 {
+		return "", fmt.Errorf("invalid PASV response: %s", msg)
+	}
+	hi, err1 := strconv.Atoi(parts[4])
+	lo, err2 := strconv.Atoi(parts[5])
+	if err1 != nil || err2 != nil || hi > 255 || lo > 255 {
+		return "", fmt.Errorf("invalid PASV response: %s", msg)
+	}
+	return strconv.Itoa(hi<<8 | lo), nil
+}
+
+// Upload stores data in the file at path.
+func (c *conn) Upload(path string, data []byte) error {
+	dataConn, err := c.openData("STOR %s", path)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = dataConn.Close() }()
+	if _, err = dataConn.Write(data); err != nil {
+		return err
+	}
+	if err = dataConn.Close(); err != nil {
+		return err
+	}
+	_, _, err = c.readResponse(226, 250)
+	return err
+}
+
+// Download retrieves the file at path.
+func (c *conn) Download(path string) ([]byte, error) {
+	dataConn, err := c.openData("RETR %s", path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = dataConn.Close() }()
+	data, err := ioutil.ReadAll(dataConn)
+	if err != nil {
+		return nil, err
+	}
+	_, _, err = c.readResponse(226, 250)
+	return data, err
+}
+
+// close logs out and closes the control connection.
+func (c *conn) close() error {
+	_, _, _ = c.cmd([]int{221}, "QUIT")
+	return c.conn.Close()
+}
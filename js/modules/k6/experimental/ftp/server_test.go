@@ -0,0 +1,234 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ftp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testUser     = "k6"
+	testPassword = "secret"
+)
+
+// server is a minimal in-process FTP server, which serves the files in
+// memory.
+type server struct {
+	t         *testing.T
+	addr      string
+	security  string
+	tlsConfig *tls.Config
+	// pasvOnly makes the server reject EPSV, like some older servers do
+	pasvOnly bool
+
+	mu    sync.Mutex
+	files map[string][]byte
+	conns int
+}
+
+func newServer(t *testing.T, security string, tlsConfig *tls.Config, pasvOnly bool) *server {
+	s := &server{
+		t: t, security: security, tlsConfig: tlsConfig, pasvOnly: pasvOnly,
+		files: map[string][]byte{"/pub/motd": []byte("hello")},
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	if security == securityImplicit {
+		l = tls.NewListener(l, tlsConfig)
+	}
+	s.addr = l.Addr().String()
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			s.mu.Unlock()
+			go s.serve(conn)
+		}
+	}()
+	return s
+}
+
+func (s *server) file(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[path]
+	return data, ok
+}
+
+func (s *server) connCount() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conns
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	text := textproto.NewConn(conn)
+	reply := func(code int, msg string) bool {
+		return text.PrintfLine("%d %s", code, msg) == nil
+	}
+	if !reply(220, "ready") {
+		return
+	}
+
+	var (
+		dataListener net.Listener
+		protected    = s.security == securityImplicit
+		user         string
+	)
+	acceptData := func() (net.Conn, bool) {
+		if dataListener == nil {
+			reply(425, "use EPSV or PASV first")
+			return nil, false
+		}
+		defer func() {
+			_ = dataListener.Close()
+			dataListener = nil
+		}()
+		if !reply(150, "opening data connection") {
+			return nil, false
+		}
+		dataConn, err := dataListener.Accept()
+		if err != nil {
+			return nil, false
+		}
+		if protected {
+			dataConn = tls.Server(dataConn, s.tlsConfig)
+		}
+		return dataConn, true
+	}
+
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd, arg := line, ""
+		if i := strings.IndexByte(line, ' '); i != -1 {
+			cmd, arg = line[:i], line[i+1:]
+		}
+
+		ok := true
+		switch cmd {
+		case "AUTH":
+			if s.security != securityExplicit {
+				ok = reply(502, "not supported")
+				break
+			}
+			if !reply(234, "proceed with negotiation") {
+				return
+			}
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, text = tlsConn, textproto.NewConn(tlsConn)
+		case "USER":
+			user = arg
+			if user == "anonymous" {
+				ok = reply(230, "logged in")
+			} else {
+				ok = reply(331, "password required")
+			}
+		case "PASS":
+			if user == testUser && arg == testPassword {
+				ok = reply(230, "logged in")
+			} else {
+				ok = reply(530, "login incorrect")
+			}
+		case "PBSZ":
+			ok = reply(200, "PBSZ=0")
+		case "PROT":
+			protected = arg == "P"
+			ok = reply(200, "protection level set")
+		case "TYPE":
+			ok = reply(200, "type set")
+		case "EPSV", "PASV":
+			if cmd == "EPSV" && s.pasvOnly {
+				ok = reply(502, "not supported")
+				break
+			}
+			if dataListener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+				return
+			}
+			port := dataListener.Addr().(*net.TCPAddr).Port //nolint:forcetypeassert
+			if cmd == "EPSV" {
+				ok = reply(229, fmt.Sprintf("Entering Extended Passive Mode (|||%d|)", port))
+			} else {
+				// the address is deliberately wrong, it should be ignored
+				ok = reply(227, fmt.Sprintf("Entering Passive Mode (10,0,0,1,%d,%d)", port>>8, port&0xff))
+			}
+		case "STOR":
+			dataConn, accepted := acceptData()
+			if !accepted {
+				break
+			}
+			data, err := ioutil.ReadAll(dataConn)
+			_ = dataConn.Close()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.files[arg] = data
+			s.mu.Unlock()
+			ok = reply(226, "transfer complete")
+		case "RETR":
+			data, exists := s.file(arg)
+			if !exists {
+				ok = reply(550, "file not found")
+				break
+			}
+			dataConn, accepted := acceptData()
+			if !accepted {
+				break
+			}
+			_, err := dataConn.Write(data)
+			_ = dataConn.Close()
+			if err != nil {
+				return
+			}
+			ok = reply(226, "transfer complete")
+		case "QUIT":
+			reply(221, "bye")
+			return
+		default:
+			ok = reply(502, "not implemented")
+		}
+		if !ok {
+			return
+		}
+	}
+}
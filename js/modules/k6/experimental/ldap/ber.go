@@ -0,0 +1,230 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// BER classes and the constructed bit of an identifier octet.
+const (
+	classUniversal   = 0x00
+	classApplication = 0x40
+	classContext     = 0x80
+	constructed      = 0x20
+)
+
+// Universal tags used by LDAP.
+const (
+	tagBoolean     = 0x01
+	tagInteger     = 0x02
+	tagOctetString = 0x04
+	tagNull        = 0x05
+	tagEnumerated  = 0x0a
+	tagSequence    = 0x10 | constructed
+	tagSet         = 0x11 | constructed
+)
+
+// maxPacketSize limits the size of the packets read from the server.
+const maxPacketSize = 16 << 20
+
+var errPacketTooBig = errors.New("packet exceeds the maximum size")
+
+// packet is a node of a BER encoded message. Only the single octet identifiers
+// are supported, which is all that LDAP needs.
+type packet struct {
+	tag      byte
+	value    []byte
+	children []*packet
+}
+
+func newSequence(tag byte, children ...*packet) *packet {
+	return &packet{tag: tag, children: children}
+}
+
+func newOctetString(tag byte, value string) *packet {
+	return &packet{tag: tag, value: []byte(value)}
+}
+
+func newInteger(tag byte, v int64) *packet {
+	// the minimal two's complement representation of v
+	n := 1
+	for ; n < 8; n++ {
+		if v >= -(1<<(8*n-1)) && v < 1<<(8*n-1) {
+			break
+		}
+	}
+	b := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		b[i] = byte(v)
+		v >>= 8
+	}
+	return &packet{tag: tag, value: b}
+}
+
+func newBoolean(v bool) *packet {
+	if v {
+		return &packet{tag: tagBoolean, value: []byte{0xff}}
+	}
+	return &packet{tag: tagBoolean, value: []byte{0x00}}
+}
+
+func (p *packet) isConstructed() bool {
+	return p.tag&constructed != 0
+}
+
+func (p *packet) bytes() []byte {
+	content := p.value
+	if p.isConstructed() {
+		content = nil
+		for _, child := range p.children {
+			content = append(content, child.bytes()...)
+		}
+	}
+	return append(append([]byte{p.tag}, encodeLength(len(content))...), content...)
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+func (p *packet) str() string {
+	return string(p.value)
+}
+
+func (p *packet) integer() (int64, error) {
+	if len(p.value) == 0 || len(p.value) > 8 {
+		return 0, fmt.Errorf("invalid integer of %d bytes", len(p.value))
+	}
+	v := int64(int8(p.value[0]))
+	for _, b := range p.value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// child returns the i-th child, or an error if there aren't enough of them.
+func (p *packet) child(i int) (*packet, error) {
+	if i >= len(p.children) {
+		return nil, fmt.Errorf("expected at least %d elements in packet with tag %#x", i+1, p.tag)
+	}
+	return p.children[i], nil
+}
+
+// readPacket reads a single BER encoded packet.
+func readPacket(r *bufio.Reader) (*packet, error) {
+	tag, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	if tag&0x1f == 0x1f {
+		return nil, fmt.Errorf("multi-byte tags are not supported")
+	}
+	length, err := readLength(r)
+	if err != nil {
+		return nil, err
+	}
+	content := make([]byte, length)
+	if _, err = io.ReadFull(r, content); err != nil {
+		return nil, err
+	}
+	return parsePacket(tag, content)
+}
+
+func readLength(r io.ByteReader) (int, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+	if b < 0x80 {
+		return int(b), nil
+	}
+	n := int(b & 0x7f)
+	if n == 0 || n > 4 {
+		return 0, fmt.Errorf("unsupported length encoding %#x", b)
+	}
+	length := 0
+	for i := 0; i < n; i++ {
+		if b, err = r.ReadByte(); err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	if length > maxPacketSize {
+		return 0, errPacketTooBig
+	}
+	return length, nil
+}
+
+func parsePacket(tag byte, content []byte) (*packet, error) {
+	p := &packet{tag: tag}
+	if !p.isConstructed() {
+		p.value = content
+		return p, nil
+	}
+	for len(content) > 0 {
+		if len(content) < 2 {
+			return nil, io.ErrUnexpectedEOF
+		}
+		childTag := content[0]
+		length, n, err := parseLength(content[1:])
+		if err != nil {
+			return nil, err
+		}
+		start := 1 + n
+		if start+length > len(content) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		child, err := parsePacket(childTag, content[start:start+length])
+		if err != nil {
+			return nil, err
+		}
+		p.children = append(p.children, child)
+		content = content[start+length:]
+	}
+	return p, nil
+}
+
+// parseLength returns the decoded length and the number of bytes it used.
+func parseLength(b []byte) (int, int, error) {
+	if b[0] < 0x80 {
+		return int(b[0]), 1, nil
+	}
+	n := int(b[0] & 0x7f)
+	if n == 0 || n > 4 || len(b) < n+1 {
+		return 0, 0, fmt.Errorf("unsupported length encoding %#x", b[0])
+	}
+	length := 0
+	for _, c := range b[1 : n+1] {
+		length = length<<8 | int(c)
+	}
+	return length, n + 1, nil
+}
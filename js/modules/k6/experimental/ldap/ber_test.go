@@ -0,0 +1,109 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIntegerEncoding(t *testing.T) {
+	t.Parallel()
+
+	tests := map[int64][]byte{
+		0:       {0x02, 0x01, 0x00},
+		127:     {0x02, 0x01, 0x7f},
+		128:     {0x02, 0x02, 0x00, 0x80},
+		256:     {0x02, 0x02, 0x01, 0x00},
+		-1:      {0x02, 0x01, 0xff},
+		-128:    {0x02, 0x01, 0x80},
+		-129:    {0x02, 0x02, 0xff, 0x7f},
+		1 << 40: {0x02, 0x06, 0x01, 0x00, 0x00, 0x00, 0x00, 0x00},
+	}
+	for v, expected := range tests {
+		p := newInteger(tagInteger, v)
+		assert.Equal(t, expected, p.bytes(), v)
+		decoded, err := p.integer()
+		require.NoError(t, err)
+		assert.Equal(t, v, decoded)
+	}
+}
+
+func TestPacketRoundTrip(t *testing.T) {
+	t.Parallel()
+
+	long := strings.Repeat("x", 300)
+	msg := newSequence(tagSequence,
+		newInteger(tagInteger, 7),
+		newSequence(opSearchRequest,
+			newOctetString(tagOctetString, "dc=example,dc=org"),
+			newBoolean(true),
+			newOctetString(tagOctetString, long),
+			newSequence(tagSet),
+		),
+		&packet{tag: opUnbindRequest},
+	)
+	b := msg.bytes()
+	// the long string needs the long form of the length
+	assert.True(t, bytes.Contains(b, []byte{0x04, 0x82, 0x01, 0x2c}))
+
+	decoded, err := readPacket(bufio.NewReader(bytes.NewReader(b)))
+	require.NoError(t, err)
+	assert.Equal(t, b, decoded.bytes())
+	require.Len(t, decoded.children, 3)
+	op, err := decoded.child(1)
+	require.NoError(t, err)
+	assert.Equal(t, byte(opSearchRequest), op.tag)
+	require.Len(t, op.children, 4)
+	assert.Equal(t, "dc=example,dc=org", op.children[0].str())
+	assert.Equal(t, long, op.children[2].str())
+	assert.Empty(t, op.children[3].children)
+
+	_, err = decoded.child(3)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "expected at least 4 elements")
+}
+
+func TestReadPacketErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string][]byte{
+		"unexpected EOF":                  {0x30, 0x05, 0x02, 0x01},
+		"multi-byte tags":                 {0x1f, 0x01, 0x00},
+		"unsupported length encoding":     {0x30, 0x80},
+		"packet exceeds the maximum size": {0x30, 0x84, 0x7f, 0xff, 0xff, 0xff},
+	}
+	for expErr, b := range tests {
+		_, err := readPacket(bufio.NewReader(bytes.NewReader(b)))
+		require.Error(t, err, expErr)
+		assert.Contains(t, err.Error(), expErr)
+	}
+
+	// the length of a child exceeds its parent
+	_, err := readPacket(bufio.NewReader(bytes.NewReader([]byte{0x30, 0x03, 0x04, 0x05, 0x61})))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unexpected EOF")
+}
@@ -0,0 +1,578 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+//nolint:lll
+var (
+	errOperationInInitContext = common.NewInitContextError("LDAP operations in the init context are not supported")
+	errConnectInInitContext   = common.NewInitContextError("connecting to a LDAP server in the init context is not supported")
+)
+
+// Protocol operations, as defined in RFC 4511 section 4.2 and onwards.
+const (
+	opBindRequest           = classApplication | constructed | 0
+	opBindResponse          = classApplication | constructed | 1
+	opUnbindRequest         = classApplication | 2
+	opSearchRequest         = classApplication | constructed | 3
+	opSearchResultEntry     = classApplication | constructed | 4
+	opSearchResultDone      = classApplication | constructed | 5
+	opModifyRequest         = classApplication | constructed | 6
+	opModifyResponse        = classApplication | constructed | 7
+	opSearchResultReference = classApplication | constructed | 19
+	opExtendedRequest       = classApplication | constructed | 23
+	opExtendedResponse      = classApplication | constructed | 24
+
+	authSimple         = classContext | 0
+	extendedName       = classContext | 0
+	startTLSOID        = "1.3.6.1.4.1.1466.20037"
+	resultCodeSuccess  = 0
+	defaultLDAPPort    = "389"
+	defaultLDAPSPort   = "636"
+	protocolVersion    = 3
+	defaultTimeout     = time.Minute
+	defaultSearchScope = "sub"
+)
+
+//nolint:gochecknoglobals
+var (
+	searchScopes      = map[string]int64{"base": 0, "one": 1, "sub": 2}
+	modifyOperations  = map[string]int64{"add": 0, "delete": 1, "replace": 2}
+	expectedResponses = map[byte]byte{
+		opBindRequest:     opBindResponse,
+		opSearchRequest:   opSearchResultDone,
+		opModifyRequest:   opModifyResponse,
+		opExtendedRequest: opExtendedResponse,
+	}
+)
+
+// Client represents a connection to a LDAP server.
+type Client struct {
+	vu     modules.VU
+	url    string
+	conn   net.Conn
+	reader *bufio.Reader
+	msgID  int64
+}
+
+// Entry is a single entry returned by a search.
+type Entry struct {
+	DN         string              `js:"dn"`
+	Attributes map[string][]string `js:"attributes"`
+}
+
+// Result is the result of a LDAP operation. Whether the operation succeeded
+// can be checked with the code, 0 being success.
+type Result struct {
+	Code       int64
+	MatchedDN  string `js:"matched_dn"`
+	Message    string
+	Entries    []Entry
+	References []string
+}
+
+type connectParams struct {
+	StartTLS bool
+	Timeout  time.Duration
+	Tags     map[string]string
+}
+
+type params struct {
+	Tags    map[string]string
+	Timeout time.Duration
+
+	// search only
+	Scope      string
+	Attributes []string
+	SizeLimit  int64
+	TimeLimit  int64
+	TypesOnly  bool
+}
+
+func parseTags(v interface{}) (map[string]string, error) {
+	rawTags, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("tags must be an object with key-value pairs")
+	}
+	tags := make(map[string]string, len(rawTags))
+	for tk, tv := range rawTags {
+		strVal, ok := tv.(string)
+		if !ok {
+			return nil, fmt.Errorf("tag %q value must be a string", tk)
+		}
+		tags[tk] = strVal
+	}
+	return tags, nil
+}
+
+func parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	p := connectParams{Timeout: defaultTimeout}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "startTLS":
+			var ok bool
+			if p.StartTLS, ok = v.(bool); !ok {
+				return p, fmt.Errorf("invalid startTLS value: '%#v', it needs to be boolean", v)
+			}
+		case "timeout":
+			if p.Timeout, err = types.GetDurationValue(v); err != nil {
+				return p, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		case "tags":
+			if p.Tags, err = parseTags(v); err != nil {
+				return p, err
+			}
+		default:
+			return p, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+func parseParams(raw map[string]interface{}, search bool) (params, error) {
+	p := params{Timeout: defaultTimeout, Scope: defaultSearchScope}
+	for k, v := range raw {
+		var err error
+		switch {
+		case k == "tags":
+			if p.Tags, err = parseTags(v); err != nil {
+				return p, err
+			}
+		case k == "timeout":
+			if p.Timeout, err = types.GetDurationValue(v); err != nil {
+				return p, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		case search && k == "scope":
+			scope, ok := v.(string)
+			if _, known := searchScopes[scope]; !ok || !known {
+				return p, fmt.Errorf("invalid scope value: '%#v', it needs to be 'base', 'one' or 'sub'", v)
+			}
+			p.Scope = scope
+		case search && k == "attributes":
+			list, ok := v.([]interface{})
+			if !ok {
+				return p, errors.New("attributes must be an array of strings")
+			}
+			for _, attr := range list {
+				s, ok := attr.(string)
+				if !ok {
+					return p, errors.New("attributes must be an array of strings")
+				}
+				p.Attributes = append(p.Attributes, s)
+			}
+		case search && (k == "sizeLimit" || k == "timeLimit"):
+			n, ok := v.(int64)
+			if !ok || n < 0 {
+				return p, fmt.Errorf("invalid %s value: '%#v', it needs to be a non-negative integer", k, v)
+			}
+			if k == "sizeLimit" {
+				p.SizeLimit = n
+			} else {
+				p.TimeLimit = n
+			}
+		case search && k == "typesOnly":
+			var ok bool
+			if p.TypesOnly, ok = v.(bool); !ok {
+				return p, fmt.Errorf("invalid typesOnly value: '%#v', it needs to be boolean", v)
+			}
+		default:
+			return p, fmt.Errorf("unknown param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// Connect connects to the LDAP server at the given ldap:// or ldaps:// URL,
+// optionally upgrading the connection with StartTLS.
+func (c *Client) Connect(rawURL string, rawParams map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	p, err := parseConnectParams(rawParams)
+	if err != nil {
+		return err
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	port := u.Port()
+	switch {
+	case u.Scheme == "ldap" && port == "":
+		port = defaultLDAPPort
+	case u.Scheme == "ldaps" && port == "":
+		port = defaultLDAPSPort
+	case u.Scheme != "ldap" && u.Scheme != "ldaps":
+		return fmt.Errorf("unsupported scheme '%s', only ldap and ldaps are supported", u.Scheme)
+	}
+	if u.Scheme == "ldaps" && p.StartTLS {
+		return errors.New("startTLS can't be used with ldaps connections")
+	}
+
+	_ = c.Close()
+	ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+	defer cancel()
+	conn, err := state.Dialer.DialContext(ctx, "tcp", net.JoinHostPort(u.Hostname(), port))
+	if err != nil {
+		return err
+	}
+	if u.Scheme == "ldaps" {
+		if conn, err = c.handshake(ctx, conn, u.Hostname()); err != nil {
+			return err
+		}
+	}
+	c.url, c.conn, c.reader, c.msgID = rawURL, conn, bufio.NewReader(conn), 0
+
+	if !p.StartTLS {
+		return nil
+	}
+	req := newSequence(opExtendedRequest, newOctetString(extendedName, startTLSOID))
+	res, err := c.do("starttls", req, p.Tags, p.Timeout)
+	if err == nil && res.Code != resultCodeSuccess {
+		err = fmt.Errorf("StartTLS failed with result code %d: %s", res.Code, res.Message)
+	}
+	if err != nil {
+		_ = c.Close()
+		return err
+	}
+	if c.conn, err = c.handshake(ctx, c.conn, u.Hostname()); err != nil {
+		_ = c.Close()
+		return err
+	}
+	c.reader = bufio.NewReader(c.conn)
+	return nil
+}
+
+func (c *Client) handshake(ctx context.Context, conn net.Conn, serverName string) (net.Conn, error) {
+	tlsConfig := c.vu.State().TLSConfig.Clone()
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{} //nolint:gosec // the defaults are used when there's no config
+	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = serverName
+	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return tlsConn, nil
+}
+
+// Bind authenticates with the given DN and password, using simple
+// authentication. An empty DN and password do an anonymous bind.
+func (c *Client) Bind(dn, password string, rawParams map[string]interface{}) (*Result, error) {
+	p, err := parseParams(rawParams, false)
+	if err != nil {
+		return nil, err
+	}
+	req := newSequence(opBindRequest,
+		newInteger(tagInteger, protocolVersion),
+		newOctetString(tagOctetString, dn),
+		newOctetString(authSimple, password),
+	)
+	return c.do("bind", req, p.Tags, p.Timeout)
+}
+
+// Search searches for the entries under the given base DN that match the
+// filter, which uses the string representation from RFC 4515.
+func (c *Client) Search(baseDN, filter string, rawParams map[string]interface{}) (*Result, error) {
+	p, err := parseParams(rawParams, true)
+	if err != nil {
+		return nil, err
+	}
+	compiled, err := compileFilter(filter)
+	if err != nil {
+		return nil, err
+	}
+	attributes := newSequence(tagSequence)
+	for _, attr := range p.Attributes {
+		attributes.children = append(attributes.children, newOctetString(tagOctetString, attr))
+	}
+	req := newSequence(opSearchRequest,
+		newOctetString(tagOctetString, baseDN),
+		newInteger(tagEnumerated, searchScopes[p.Scope]),
+		newInteger(tagEnumerated, 0), // never dereference aliases
+		newInteger(tagInteger, p.SizeLimit),
+		newInteger(tagInteger, p.TimeLimit),
+		newBoolean(p.TypesOnly),
+		compiled,
+		attributes,
+	)
+	return c.do("search", req, p.Tags, p.Timeout)
+}
+
+// Modify applies the given changes to the entry with the given DN. Every
+// change is an object with an operation (add, delete or replace), an
+// attribute and the list of values.
+func (c *Client) Modify(dn string, changes goja.Value, rawParams map[string]interface{}) (*Result, error) {
+	p, err := parseParams(rawParams, false)
+	if err != nil {
+		return nil, err
+	}
+	req, err := newModifyRequest(dn, changes)
+	if err != nil {
+		return nil, err
+	}
+	return c.do("modify", req, p.Tags, p.Timeout)
+}
+
+func newModifyRequest(dn string, changes goja.Value) (*packet, error) {
+	var rawChanges []interface{}
+	if changes != nil && !goja.IsUndefined(changes) && !goja.IsNull(changes) {
+		var ok bool
+		if rawChanges, ok = changes.Export().([]interface{}); !ok {
+			return nil, errors.New("changes must be an array of objects")
+		}
+	}
+
+	list := newSequence(tagSequence)
+	for i, rawChange := range rawChanges {
+		change, ok := rawChange.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("change %d must be an object", i)
+		}
+		opName, _ := change["operation"].(string)
+		op, ok := modifyOperations[opName]
+		if !ok {
+			return nil, fmt.Errorf("change %d has an invalid operation '%v', "+
+				"it needs to be 'add', 'delete' or 'replace'", i, change["operation"])
+		}
+		attr, ok := change["attribute"].(string)
+		if !ok || attr == "" {
+			return nil, fmt.Errorf("change %d must have an attribute", i)
+		}
+		values := newSequence(tagSet)
+		rawValues, _ := change["values"].([]interface{})
+		for _, v := range rawValues {
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("the values of change %d must be strings", i)
+			}
+			values.children = append(values.children, newOctetString(tagOctetString, s))
+		}
+		list.children = append(list.children, newSequence(tagSequence,
+			newInteger(tagEnumerated, op),
+			newSequence(tagSequence, newOctetString(tagOctetString, attr), values),
+		))
+	}
+	return newSequence(opModifyRequest, newOctetString(tagOctetString, dn), list), nil
+}
+
+// Close unbinds and closes the connection to the LDAP server.
+func (c *Client) Close() error {
+	if c.conn == nil {
+		return nil
+	}
+	c.msgID++
+	msg := newSequence(tagSequence, newInteger(tagInteger, c.msgID), &packet{tag: opUnbindRequest})
+	_, _ = c.conn.Write(msg.bytes())
+	err := c.conn.Close()
+	c.conn, c.reader = nil, nil
+	return err
+}
+
+// do sends a request and reads the responses to it, emitting the duration
+// of the whole operation as a sample.
+func (c *Client) do(op string, req *packet, userTags map[string]string, timeout time.Duration) (*Result, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errOperationInInitContext
+	}
+	if c.conn == nil {
+		return nil, errors.New("no LDAP connection, you must call connect first")
+	}
+
+	tags := state.CloneTags()
+	for k, v := range userTags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = c.url
+	}
+	if state.Options.SystemTags.Has(stats.TagMethod) {
+		tags["method"] = op
+	}
+
+	startTime := time.Now()
+	res, err := c.roundTrip(req, timeout)
+	endTime := time.Now()
+	if err != nil {
+		// the connection is in an unknown state after a failed operation
+		_ = c.conn.Close()
+		c.conn, c.reader = nil, nil
+		return nil, err
+	}
+
+	if state.Options.SystemTags.Has(stats.TagStatus) {
+		tags["status"] = strconv.FormatInt(res.Code, 10)
+	}
+	stats.PushIfNotDone(c.vu.Context(), state.Samples, stats.Sample{
+		Metric: state.BuiltinMetrics.LDAPReqDuration,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  stats.D(endTime.Sub(startTime)),
+		Time:   endTime,
+	})
+	return res, nil
+}
+
+func (c *Client) roundTrip(req *packet, timeout time.Duration) (*Result, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := c.vu.Context().Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	c.msgID++
+	msg := newSequence(tagSequence, newInteger(tagInteger, c.msgID), req)
+	if _, err := c.conn.Write(msg.bytes()); err != nil {
+		return nil, err
+	}
+
+	res := &Result{}
+	for {
+		resp, err := readPacket(c.reader)
+		if err != nil {
+			return nil, err
+		}
+		op, err := c.checkResponse(resp)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op.tag {
+		case opSearchResultEntry:
+			entry, err := parseEntry(op)
+			if err != nil {
+				return nil, err
+			}
+			res.Entries = append(res.Entries, entry)
+			continue
+		case opSearchResultReference:
+			for _, ref := range op.children {
+				res.References = append(res.References, ref.str())
+			}
+			continue
+		case expectedResponses[req.tag]:
+			return res, parseResult(op, res)
+		default:
+			return nil, fmt.Errorf("unexpected response with tag %#x", op.tag)
+		}
+	}
+}
+
+// checkResponse checks the envelope of a response and returns the protocol
+// operation in it.
+func (c *Client) checkResponse(resp *packet) (*packet, error) {
+	if resp.tag != tagSequence {
+		return nil, fmt.Errorf("unexpected packet with tag %#x", resp.tag)
+	}
+	idPacket, err := resp.child(0)
+	if err != nil {
+		return nil, err
+	}
+	id, err := idPacket.integer()
+	if err != nil {
+		return nil, err
+	}
+	op, err := resp.child(1)
+	if err != nil {
+		return nil, err
+	}
+	if id == 0 && op.tag == opExtendedResponse {
+		// an unsolicited notification, i.e. notice of disconnection
+		res := &Result{}
+		if err = parseResult(op, res); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("the server sent an unsolicited notification: %d %s", res.Code, res.Message)
+	}
+	if id != c.msgID {
+		return nil, fmt.Errorf("unexpected message id %d, expected %d", id, c.msgID)
+	}
+	return op, nil
+}
+
+func parseResult(op *packet, res *Result) error {
+	if len(op.children) < 3 {
+		return fmt.Errorf("invalid LDAP result with %d elements", len(op.children))
+	}
+	code, err := op.children[0].integer()
+	if err != nil {
+		return err
+	}
+	res.Code = code
+	res.MatchedDN = op.children[1].str()
+	res.Message = op.children[2].str()
+	return nil
+}
+
+func parseEntry(op *packet) (Entry, error) {
+	entry := Entry{Attributes: make(map[string][]string)}
+	dn, err := op.child(0)
+	if err != nil {
+		return entry, err
+	}
+	entry.DN = dn.str()
+	attrs, err := op.child(1)
+	if err != nil {
+		return entry, err
+	}
+	for _, attr := range attrs.children {
+		name, err := attr.child(0)
+		if err != nil {
+			return entry, err
+		}
+		values, err := attr.child(1)
+		if err != nil {
+			return entry, err
+		}
+		list := make([]string, 0, len(values.children))
+		for _, v := range values.children {
+			list = append(list, v.str())
+		}
+		entry.Attributes[name.str()] = list
+	}
+	return entry, nil
+}
@@ -0,0 +1,357 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+const (
+	testAdminDN       = "cn=admin,dc=example,dc=org"
+	testAdminPassword = "secret"
+)
+
+// server is a minimal in-process LDAP server.
+type server struct {
+	t         *testing.T
+	tlsConfig *tls.Config
+
+	mu       sync.Mutex
+	requests []*packet
+}
+
+func (s *server) listen(implicitTLS bool) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(s.t, err)
+	s.t.Cleanup(func() { _ = l.Close() })
+	if implicitTLS {
+		l = tls.NewListener(l, s.tlsConfig)
+	}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func (s *server) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+	r := bufio.NewReader(conn)
+	for {
+		msg, err := readPacket(r)
+		if err != nil {
+			return
+		}
+		id, op := msg.children[0], msg.children[1]
+		s.mu.Lock()
+		s.requests = append(s.requests, op)
+		s.mu.Unlock()
+
+		reply := func(resp *packet) bool {
+			_, err := conn.Write(newSequence(tagSequence, id, resp).bytes())
+			return err == nil
+		}
+		result := func(tag byte, code int64, message string) *packet {
+			return newSequence(tag, newInteger(tagEnumerated, code),
+				newOctetString(tagOctetString, ""), newOctetString(tagOctetString, message))
+		}
+
+		var ok bool
+		switch op.tag {
+		case opUnbindRequest:
+			return
+		case opExtendedRequest:
+			if !reply(result(opExtendedResponse, 0, "")) {
+				return
+			}
+			tlsConn := tls.Server(conn, s.tlsConfig)
+			if tlsConn.Handshake() != nil {
+				return
+			}
+			conn, r = tlsConn, bufio.NewReader(tlsConn)
+			continue
+		case opBindRequest:
+			if op.children[1].str() == testAdminDN && op.children[2].str() == testAdminPassword {
+				ok = reply(result(opBindResponse, 0, ""))
+			} else {
+				ok = reply(result(opBindResponse, 49, "invalid credentials"))
+			}
+		case opSearchRequest:
+			if op.children[0].str() != "dc=example,dc=org" {
+				ok = reply(result(opSearchResultDone, 32, "no such object"))
+				break
+			}
+			for _, cn := range []string{"alice", "bob"} {
+				reply(newSequence(opSearchResultEntry,
+					newOctetString(tagOctetString, "cn="+cn+",dc=example,dc=org"),
+					newSequence(tagSequence,
+						newSequence(tagSequence, newOctetString(tagOctetString, "cn"),
+							newSequence(tagSet, newOctetString(tagOctetString, cn))),
+						newSequence(tagSequence, newOctetString(tagOctetString, "objectClass"),
+							newSequence(tagSet,
+								newOctetString(tagOctetString, "top"), newOctetString(tagOctetString, "person"))),
+					),
+				))
+			}
+			reply(newSequence(opSearchResultReference,
+				newOctetString(tagOctetString, "ldap://other/dc=example,dc=org")))
+			ok = reply(result(opSearchResultDone, 0, ""))
+		case opModifyRequest:
+			ok = reply(result(opModifyResponse, 0, ""))
+		}
+		if !ok {
+			return
+		}
+	}
+}
+
+func (s *server) lastRequest() *packet {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests[len(s.requests)-1]
+}
+
+type testState struct {
+	rt      *goja.Runtime
+	vu      *modulestest.VU
+	state   *lib.State
+	samples chan stats.SampleContainer
+	server  *server
+}
+
+func newTestState(t *testing.T) testState {
+	t.Helper()
+
+	// borrow the self-signed certificate of a TLS test server
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(tlsSrv.Close)
+	clientTLSConfig := tlsSrv.Client().Transport.(*http.Transport).TLSClientConfig //nolint:forcetypeassert
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Logger: logrus.New()},
+	}
+	state := &lib.State{
+		Dialer:    &net.Dialer{},
+		TLSConfig: clientTLSConfig,
+		Samples:   samples,
+		Options: lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagURL, stats.TagStatus, stats.TagMethod),
+		},
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewTagMap(nil),
+	}
+
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("ldap", m.Exports().Named))
+	_, err := rt.RunString(`var client = new ldap.Client();`)
+	require.NoError(t, err)
+
+	return testState{
+		rt: rt, vu: vu, state: state, samples: samples,
+		server: &server{t: t, tlsConfig: tlsSrv.TLS},
+	}
+}
+
+func TestClientInitContext(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+
+	_, err := ts.rt.RunString(`client.connect("ldap://127.0.0.1")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connecting to a LDAP server in the init context is not supported")
+
+	_, err = ts.rt.RunString(`client.bind("", "")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "LDAP operations in the init context are not supported")
+}
+
+func TestClientOperations(t *testing.T) {
+	t.Parallel()
+
+	for name, tc := range map[string]struct {
+		scheme      string
+		implicitTLS bool
+		params      map[string]interface{}
+	}{
+		"plain":    {scheme: "ldap"},
+		"ldaps":    {scheme: "ldaps", implicitTLS: true},
+		"starttls": {scheme: "ldap", params: map[string]interface{}{"startTLS": true}},
+	} {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			ts := newTestState(t)
+			ts.vu.StateField = ts.state
+			url := tc.scheme + "://" + ts.server.listen(tc.implicitTLS)
+			require.NoError(t, ts.rt.Set("url", url))
+			require.NoError(t, ts.rt.Set("params", tc.params))
+
+			_, err := ts.rt.RunString(`
+				client.connect(url, params);
+
+				var res = client.bind("cn=admin,dc=example,dc=org", "wrong");
+				if (res.code !== 49 || res.message !== "invalid credentials") {
+					throw new Error("unexpected bind result: " + JSON.stringify(res));
+				}
+				res = client.bind("cn=admin,dc=example,dc=org", "secret");
+				if (res.code !== 0) { throw new Error("unexpected bind result: " + JSON.stringify(res)); }
+
+				res = client.search("dc=example,dc=org", "(objectClass=person)", {
+					scope: "one", attributes: ["cn", "objectClass"], sizeLimit: 10, tags: {foo: "bar"},
+				});
+				if (res.code !== 0 || res.entries.length !== 2 || res.entries[1].dn !== "cn=bob,dc=example,dc=org" ||
+					res.entries[0].attributes.objectClass.join(",") !== "top,person" ||
+					res.references[0] !== "ldap://other/dc=example,dc=org") {
+					throw new Error("unexpected search result: " + JSON.stringify(res));
+				}
+
+				res = client.search("dc=missing", "(cn=*)");
+				if (res.code !== 32 || res.entries.length !== 0) {
+					throw new Error("unexpected search result: " + JSON.stringify(res));
+				}
+			`)
+			require.NoError(t, err)
+
+			search := ts.server.lastRequest()
+			require.Len(t, search.children, 8)
+			scope, err := search.children[1].integer()
+			require.NoError(t, err)
+			assert.Equal(t, int64(2), scope) // the default
+			assert.Equal(t, []*packet{newOctetString(filterPresent, "cn")}, search.children[6:7])
+
+			_, err = ts.rt.RunString(`
+				res = client.modify("cn=alice,dc=example,dc=org", [
+					{operation: "replace", attribute: "mail", values: ["alice@example.org"]},
+					{operation: "delete", attribute: "description"},
+				]);
+				if (res.code !== 0) { throw new Error("unexpected modify result: " + JSON.stringify(res)); }
+				client.close();
+			`)
+			require.NoError(t, err)
+
+			modify := ts.server.lastRequest()
+			expected := newSequence(opModifyRequest,
+				newOctetString(tagOctetString, "cn=alice,dc=example,dc=org"),
+				newSequence(tagSequence,
+					newSequence(tagSequence, newInteger(tagEnumerated, 2), newSequence(tagSequence,
+						newOctetString(tagOctetString, "mail"),
+						newSequence(tagSet, newOctetString(tagOctetString, "alice@example.org")))),
+					newSequence(tagSequence, newInteger(tagEnumerated, 1), newSequence(tagSequence,
+						newOctetString(tagOctetString, "description"), newSequence(tagSet))),
+				),
+			)
+			assert.Equal(t, expected.bytes(), modify.bytes())
+
+			bufSamples := stats.GetBufferedSamples(ts.samples)
+			var methods, statuses []string
+			for _, sc := range bufSamples {
+				sample := sc.GetSamples()[0]
+				assert.Equal(t, metrics.LDAPReqDurationName, sample.Metric.Name)
+				method, _ := sample.Tags.Get("method")
+				status, _ := sample.Tags.Get("status")
+				methods, statuses = append(methods, method), append(statuses, status)
+			}
+			expMethods := []string{"bind", "bind", "search", "search", "modify"}
+			expStatuses := []string{"49", "0", "0", "32", "0"}
+			if tc.params != nil {
+				expMethods = append([]string{"starttls"}, expMethods...)
+				expStatuses = append([]string{"0"}, expStatuses...)
+			}
+			assert.Equal(t, expMethods, methods)
+			assert.Equal(t, expStatuses, statuses)
+			assert.Equal(t, map[string]string{
+				"foo": "bar", "url": url, "method": "search", "status": "0",
+			}, bufSamples[len(bufSamples)-3].GetSamples()[0].Tags.CloneTags())
+		})
+	}
+}
+
+func TestClientErrors(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+
+	_, err := ts.rt.RunString(`client.bind("", "")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no LDAP connection, you must call connect first")
+
+	require.NoError(t, ts.rt.Set("addr", ts.server.listen(false)))
+	tests := map[string]string{
+		`client.connect("http://" + addr)`:                    "unsupported scheme 'http'",
+		`client.connect("ldaps://" + addr, {startTLS: true})`: "startTLS can't be used with ldaps connections",
+		`client.connect("ldap://" + addr, {foo: 1})`:          `unknown connect param: "foo"`,
+		`client.connect("ldap://" + addr, {startTLS: 1})`:     "invalid startTLS value",
+		`client.connect("ldap://" + addr)`:                    "",
+
+		`client.bind("", "", {x: 1})`:                                          `unknown param: "x"`,
+		`client.search("", "(cn=*")`:                                           "missing ')'",
+		`client.search("", "cn=*", {scope: "all"})`:                            "invalid scope value",
+		`client.search("", "cn=*", {sizeLimit: -1})`:                           "invalid sizeLimit value",
+		`client.search("", "cn=*", {attributes: "cn"})`:                        "attributes must be an array",
+		`client.modify("", {})`:                                                "changes must be an array",
+		`client.modify("", [{operation: "set"}])`:                              "invalid operation 'set'",
+		`client.modify("", [{operation: "add"}])`:                              "must have an attribute",
+		`client.modify("", [{operation: "add", attribute: "a", values: [1]}])`: "must be strings",
+	}
+	for code, expErr := range tests {
+		if !strings.HasPrefix(code, "client.connect") {
+			code = `client.connect("ldap://" + addr); ` + code
+		}
+		_, err := ts.rt.RunString(code)
+		if expErr == "" {
+			assert.NoError(t, err, code)
+			continue
+		}
+		require.Error(t, err, code)
+		assert.Contains(t, err.Error(), expErr, code)
+	}
+}
@@ -0,0 +1,198 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Filter choices, as defined in RFC 4511 section 4.5.1.
+const (
+	filterAnd            = classContext | constructed | 0
+	filterOr             = classContext | constructed | 1
+	filterNot            = classContext | constructed | 2
+	filterEqualityMatch  = classContext | constructed | 3
+	filterSubstrings     = classContext | constructed | 4
+	filterGreaterOrEqual = classContext | constructed | 5
+	filterLessOrEqual    = classContext | constructed | 6
+	filterPresent        = classContext | 7
+	filterApproxMatch    = classContext | constructed | 8
+
+	substringInitial = classContext | 0
+	substringAny     = classContext | 1
+	substringFinal   = classContext | 2
+)
+
+// compileFilter converts a string search filter, as defined in RFC 4515, to
+// its BER representation. Extensible matches aren't supported.
+func compileFilter(filter string) (*packet, error) {
+	filter = strings.TrimSpace(filter)
+	if filter == "" {
+		return nil, fmt.Errorf("empty search filter")
+	}
+	if filter[0] != '(' {
+		filter = "(" + filter + ")"
+	}
+	p, rest, err := parseFilter(filter)
+	if err != nil {
+		return nil, fmt.Errorf("invalid search filter '%s': %w", filter, err)
+	}
+	if rest != "" {
+		return nil, fmt.Errorf("invalid search filter '%s': unexpected '%s' at the end", filter, rest)
+	}
+	return p, nil
+}
+
+// parseFilter parses a single parenthesized filter and returns the rest of
+// the input.
+func parseFilter(s string) (*packet, string, error) {
+	if s == "" || s[0] != '(' {
+		return nil, "", fmt.Errorf("expected '('")
+	}
+	s = s[1:]
+	if s == "" {
+		return nil, "", fmt.Errorf("unexpected end of filter")
+	}
+
+	switch s[0] {
+	case '&', '|':
+		tag := byte(filterAnd)
+		if s[0] == '|' {
+			tag = filterOr
+		}
+		set := newSequence(tag)
+		s = s[1:]
+		for s != "" && s[0] == '(' {
+			var child *packet
+			var err error
+			if child, s, err = parseFilter(s); err != nil {
+				return nil, "", err
+			}
+			set.children = append(set.children, child)
+		}
+		return closeFilter(set, s)
+	case '!':
+		child, rest, err := parseFilter(s[1:])
+		if err != nil {
+			return nil, "", err
+		}
+		return closeFilter(newSequence(filterNot, child), rest)
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end == -1 {
+		return nil, "", fmt.Errorf("missing ')'")
+	}
+	p, err := parseItem(s[:end])
+	if err != nil {
+		return nil, "", err
+	}
+	return p, s[end+1:], nil
+}
+
+func closeFilter(p *packet, s string) (*packet, string, error) {
+	if s == "" || s[0] != ')' {
+		return nil, "", fmt.Errorf("missing ')'")
+	}
+	return p, s[1:], nil
+}
+
+// parseItem parses a simple, present or substring filter item like `cn=foo*`.
+func parseItem(item string) (*packet, error) {
+	idx := strings.IndexByte(item, '=')
+	if idx < 1 {
+		return nil, fmt.Errorf("invalid filter item '%s'", item)
+	}
+	attr, value := item[:idx], item[idx+1:]
+
+	var tag byte = filterEqualityMatch
+	switch attr[len(attr)-1] {
+	case '>':
+		tag, attr = filterGreaterOrEqual, attr[:len(attr)-1]
+	case '<':
+		tag, attr = filterLessOrEqual, attr[:len(attr)-1]
+	case '~':
+		tag, attr = filterApproxMatch, attr[:len(attr)-1]
+	case ':':
+		return nil, fmt.Errorf("extensible match filters are not supported")
+	}
+	if attr == "" {
+		return nil, fmt.Errorf("invalid filter item '%s'", item)
+	}
+
+	if tag != filterEqualityMatch || !strings.Contains(value, "*") {
+		unescaped, err := unescapeFilterValue(value)
+		if err != nil {
+			return nil, err
+		}
+		return newSequence(tag, newOctetString(tagOctetString, attr), newOctetString(tagOctetString, unescaped)), nil
+	}
+	if value == "*" {
+		return newOctetString(filterPresent, attr), nil
+	}
+
+	parts := strings.Split(value, "*")
+	substrings := newSequence(tagSequence)
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		unescaped, err := unescapeFilterValue(part)
+		if err != nil {
+			return nil, err
+		}
+		partTag := byte(substringAny)
+		switch i {
+		case 0:
+			partTag = substringInitial
+		case len(parts) - 1:
+			partTag = substringFinal
+		}
+		substrings.children = append(substrings.children, newOctetString(partTag, unescaped))
+	}
+	return newSequence(filterSubstrings, newOctetString(tagOctetString, attr), substrings), nil
+}
+
+// unescapeFilterValue decodes the \XX escape sequences of a filter value.
+func unescapeFilterValue(value string) (string, error) {
+	if !strings.Contains(value, "\\") {
+		return value, nil
+	}
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] != '\\' {
+			b.WriteByte(value[i])
+			continue
+		}
+		if i+3 > len(value) {
+			return "", fmt.Errorf("invalid escape sequence in '%s'", value)
+		}
+		decoded, err := hex.DecodeString(value[i+1 : i+3])
+		if err != nil {
+			return "", fmt.Errorf("invalid escape sequence in '%s'", value)
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,88 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ldap
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompileFilter(t *testing.T) {
+	t.Parallel()
+
+	attr := func(tag byte, name, value string) *packet {
+		return newSequence(tag, newOctetString(tagOctetString, name), newOctetString(tagOctetString, value))
+	}
+	tests := map[string]*packet{
+		"(cn=foo)":            attr(filterEqualityMatch, "cn", "foo"),
+		"cn=foo":              attr(filterEqualityMatch, "cn", "foo"),
+		"(objectClass=*)":     newOctetString(filterPresent, "objectClass"),
+		"(uidNumber>=1000)":   attr(filterGreaterOrEqual, "uidNumber", "1000"),
+		"(uidNumber<=1000)":   attr(filterLessOrEqual, "uidNumber", "1000"),
+		"(sn~=smith)":         attr(filterApproxMatch, "sn", "smith"),
+		`(cn=a\2ab\28\29\5c)`: attr(filterEqualityMatch, "cn", `a*b()\`),
+		"(cn=jo*s*ith)": newSequence(filterSubstrings, newOctetString(tagOctetString, "cn"), newSequence(tagSequence,
+			newOctetString(substringInitial, "jo"),
+			newOctetString(substringAny, "s"),
+			newOctetString(substringFinal, "ith"),
+		)),
+		"(cn=*smith)": newSequence(filterSubstrings, newOctetString(tagOctetString, "cn"), newSequence(tagSequence,
+			newOctetString(substringFinal, "smith"),
+		)),
+		"(&(objectClass=person)(|(cn=foo)(!(sn=bar))))": newSequence(filterAnd,
+			attr(filterEqualityMatch, "objectClass", "person"),
+			newSequence(filterOr,
+				attr(filterEqualityMatch, "cn", "foo"),
+				newSequence(filterNot, attr(filterEqualityMatch, "sn", "bar")),
+			),
+		),
+	}
+	for filter, expected := range tests {
+		p, err := compileFilter(filter)
+		require.NoError(t, err, filter)
+		assert.Equal(t, expected.bytes(), p.bytes(), filter)
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"":             "empty search filter",
+		"(cn=foo":      "missing ')'",
+		"(&(cn=foo)":   "missing ')'",
+		"(cn=foo))":    "unexpected ')' at the end",
+		"(=foo)":       "invalid filter item",
+		"(>=foo)":      "invalid filter item",
+		"(cn:dn:=foo)": "extensible match filters are not supported",
+		`(cn=foo\zz)`:  "invalid escape sequence",
+		`(cn=foo\2)`:   "invalid escape sequence",
+		"(!cn=foo)":    "expected '('",
+		"(":            "unexpected end of filter",
+	}
+	for filter, expErr := range tests {
+		_, err := compileFilter(filter)
+		require.Error(t, err, filter)
+		assert.Contains(t, err.Error(), expErr, filter)
+	}
+}
@@ -0,0 +1,296 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mockserver
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+const (
+	defaultWaitTimeout = time.Minute
+	// maxCallbackBody is how much of the body of a callback is read, to look
+	// up its correlation ID and to return it to the script.
+	maxCallbackBody = 1 << 20
+	// The callbacks which nobody waits for are kept for receivedCallbackTTL,
+	// and at most maxReceivedCallbacks of them, the oldest being dropped.
+	receivedCallbackTTL  = 5 * time.Minute
+	maxReceivedCallbacks = 10000
+)
+
+var errWaitInInitContext = common.NewInitContextError("waiting for callbacks in the init context is not supported")
+
+// correlation is where the correlation ID is read from in the callback
+// requests: a header, a query parameter or a top-level field of a JSON body.
+type correlation struct {
+	Header string
+	Query  string
+	JSON   string
+}
+
+func parseCorrelation(v interface{}) (*correlation, error) {
+	raw, ok := v.(map[string]interface{})
+	if !ok || len(raw) != 1 {
+		return nil, errors.New("correlation must be an object with one of header, query or json")
+	}
+	c := &correlation{}
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok || s == "" {
+			return nil, fmt.Errorf("invalid correlation %s value: '%#v', it needs to be a non-empty string", k, v)
+		}
+		switch k {
+		case "header":
+			c.Header = s
+		case "query":
+			c.Query = s
+		case "json":
+			c.JSON = s
+		default:
+			return nil, fmt.Errorf("unknown correlation param: %q", k)
+		}
+	}
+	return c, nil
+}
+
+// id returns the correlation ID of the request, or an empty string if it
+// doesn't have one.
+func (c *correlation) id(req *http.Request, body []byte) string {
+	switch {
+	case c.Header != "":
+		return req.Header.Get(c.Header)
+	case c.Query != "":
+		return req.URL.Query().Get(c.Query)
+	default:
+		var fields map[string]interface{}
+		if json.Unmarshal(body, &fields) != nil {
+			return ""
+		}
+		switch v := fields[c.JSON].(type) {
+		case string:
+			return v
+		case float64:
+			return fmt.Sprint(v)
+		default:
+			return ""
+		}
+	}
+}
+
+// Callback is a request received by a mock server, with the correlation ID
+// which was waited for. Early is set for the callbacks which arrived before
+// they were waited for, whose latency isn't known, so their Duration is 0.
+type Callback struct {
+	ID       string            `js:"id"`
+	Server   string            `js:"server"`
+	Method   string            `js:"method"`
+	Path     string            `js:"path"`
+	Headers  map[string]string `js:"headers"`
+	Body     string            `js:"body"`
+	Duration float64           `js:"duration"`
+	Early    bool              `js:"early"`
+
+	received time.Time
+}
+
+func newCallback(server, id string, req *http.Request, body []byte) *Callback {
+	headers := make(map[string]string, len(req.Header))
+	for k := range req.Header {
+		headers[k] = req.Header.Get(k)
+	}
+	return &Callback{
+		ID:       id,
+		Server:   server,
+		Method:   req.Method,
+		Path:     req.URL.Path,
+		Headers:  headers,
+		Body:     string(body),
+		received: time.Now(),
+	}
+}
+
+// callbackRegistry matches the received callbacks with the VUs waiting for
+// them. The callbacks which arrive before anyone waits for them are kept
+// until they are, for at most ttl, and at most limit of them.
+type callbackRegistry struct {
+	mx       sync.Mutex
+	received map[string]*Callback
+	// order has the received callbacks from the oldest one, and the ones
+	// which have been waited for since, until they are pruned.
+	order   []*Callback
+	waiting map[string][]chan *Callback
+	ttl     time.Duration
+	limit   int
+}
+
+func newCallbackRegistry() *callbackRegistry {
+	return &callbackRegistry{
+		received: make(map[string]*Callback),
+		waiting:  make(map[string][]chan *Callback),
+		ttl:      receivedCallbackTTL,
+		limit:    maxReceivedCallbacks,
+	}
+}
+
+// deliver sends the callback to the VUs waiting for it, each one with its
+// own copy, since they set its duration, or keeps it until one waits for it.
+func (r *callbackRegistry) deliver(cb *Callback) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	waiting := r.waiting[cb.ID]
+	if len(waiting) == 0 {
+		r.prune(cb.received)
+		r.received[cb.ID] = cb
+		r.order = append(r.order, cb)
+		return
+	}
+	delete(r.waiting, cb.ID)
+	for _, ch := range waiting {
+		c := *cb
+		ch <- &c
+	}
+}
+
+// prune drops the received callbacks older than the ttl, and the oldest ones
+// while there isn't room for another one.
+func (r *callbackRegistry) prune(now time.Time) {
+	for len(r.order) > 0 {
+		cb := r.order[0]
+		kept := r.received[cb.ID] == cb
+		if kept && now.Sub(cb.received) < r.ttl && len(r.received) < r.limit {
+			return
+		}
+		if kept {
+			delete(r.received, cb.ID)
+		}
+		r.order[0] = nil
+		r.order = r.order[1:]
+	}
+}
+
+// wait returns a channel which receives the callback with the ID, right away
+// if it has already been received.
+func (r *callbackRegistry) wait(id string) chan *Callback {
+	ch := make(chan *Callback, 1)
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	if cb, ok := r.received[id]; ok {
+		delete(r.received, id)
+		ch <- cb
+		return ch
+	}
+	r.waiting[id] = append(r.waiting[id], ch)
+	return ch
+}
+
+func (r *callbackRegistry) cancel(id string, ch chan *Callback) {
+	r.mx.Lock()
+	defer r.mx.Unlock()
+	waiting := r.waiting[id]
+	for i, c := range waiting {
+		if c == ch {
+			waiting = append(waiting[:i], waiting[i+1:]...)
+			break
+		}
+	}
+	if len(waiting) == 0 {
+		delete(r.waiting, id)
+	} else {
+		r.waiting[id] = waiting
+	}
+}
+
+// Callbacks waits for the callbacks of the mock servers with a correlation.
+type Callbacks struct {
+	mi *ModuleInstance
+}
+
+// WaitFor returns a promise which is resolved with the callback with the
+// correlation ID, or rejected if it doesn't arrive before the timeout. The
+// time from the call to the callback is measured by the callback_duration
+// metric, so it should be called right after starting the transaction; a
+// callback which had already arrived is marked as early and left out of the
+// metric, since the time it took is unknown.
+func (c *Callbacks) WaitFor(id string, timeout goja.Value) (*goja.Promise, error) {
+	vu := c.mi.vu
+	state := vu.State()
+	if state == nil {
+		return nil, errWaitInInitContext
+	}
+	d := defaultWaitTimeout
+	if timeout != nil && !goja.IsUndefined(timeout) && !goja.IsNull(timeout) {
+		var err error
+		if d, err = types.GetDurationValue(timeout.Export()); err != nil {
+			return nil, fmt.Errorf("invalid timeout value: %w", err)
+		}
+	}
+
+	rt := vu.Runtime()
+	promise, resolve, reject := rt.NewPromise()
+	runOnLoop := vu.RegisterCallback()
+	registry := c.mi.root.callbacks
+	start := time.Now()
+	ch := registry.wait(id)
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case cb := <-ch:
+			if cb.received.Before(start) {
+				cb.Early = true
+			} else {
+				cb.Duration = stats.D(cb.received.Sub(start))
+				tags := state.CloneTags()
+				tags["server"] = cb.Server
+				stats.PushIfNotDone(vu.Context(), state.Samples, stats.Sample{
+					Metric: state.BuiltinMetrics.CallbackDuration,
+					Time:   cb.received,
+					Tags:   stats.IntoSampleTags(&tags),
+					Value:  cb.Duration,
+				})
+			}
+			runOnLoop(func() error {
+				resolve(cb)
+				return nil
+			})
+		case <-timer.C:
+			registry.cancel(id, ch)
+			runOnLoop(func() error {
+				reject(rt.NewGoError(fmt.Errorf("the callback %q didn't arrive in %s", id, d)))
+				return nil
+			})
+		case <-vu.Context().Done():
+			registry.cancel(id, ch)
+			runOnLoop(func() error { return nil })
+		}
+	}()
+	return promise, nil
+}
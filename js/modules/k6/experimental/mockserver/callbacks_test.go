@@ -0,0 +1,241 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mockserver
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// loopVU is a VU with a minimal event loop, which runs the callbacks queued
+// by the module when runLoop is called.
+type loopVU struct {
+	*modulestest.VU
+	queue chan func() error
+}
+
+func (vu *loopVU) RegisterCallback() func(func() error) {
+	return func(f func() error) { vu.queue <- f }
+}
+
+func (vu *loopVU) runLoop(t *testing.T) {
+	t.Helper()
+	select {
+	case f := <-vu.queue:
+		require.NoError(t, f())
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a callback")
+	}
+}
+
+func newLoopRuntime(t *testing.T, ctx context.Context) (*goja.Runtime, *loopVU, chan stats.SampleContainer) {
+	t.Helper()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	registry := metrics.NewRegistry()
+	samples := make(chan stats.SampleContainer, 100)
+	vu := &loopVU{
+		VU: &modulestest.VU{
+			CtxField:     ctx,
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{Logger: logrus.New()},
+			StateField: &lib.State{
+				Logger:         logrus.New(),
+				Tags:           lib.NewTagMap(nil),
+				Samples:        samples,
+				BuiltinMetrics: metrics.RegisterBuiltinMetrics(registry),
+			},
+		},
+		queue: make(chan func() error, 10),
+	}
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("mockserver", m.Exports().Named))
+	return rt, vu, samples
+}
+
+func TestCallbacksWaitFor(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt, vu, samples := newLoopRuntime(t, ctx)
+
+	v, err := rt.RunString(`
+		var server = mockserver.start({
+			name: "payments",
+			correlation: { json: "orderId" },
+			routes: [{ method: "POST", path: "/notify", status: 204 }],
+		});
+		var result = {};
+		mockserver.callbacks.waitFor("order-1", "5s").then(
+			(cb) => { result.resolved = cb },
+			(e) => { result.rejected = e },
+		);
+		server.url;
+	`)
+	require.NoError(t, err)
+	url := v.String()
+
+	time.Sleep(50 * time.Millisecond)
+	res, err := http.Post(url+"/notify", "application/json", strings.NewReader(`{"orderId": "order-1"}`)) //nolint:noctx
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, http.StatusNoContent, res.StatusCode)
+
+	vu.runLoop(t)
+	v, err = rt.RunString(`[result.resolved.id, result.resolved.server, result.resolved.method,
+		result.resolved.path, result.resolved.body, result.resolved.duration >= 50, result.resolved.early,
+		result.rejected]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{
+		"order-1", "payments", "POST", "/notify", `{"orderId": "order-1"}`, true, false, nil,
+	}, v.Export())
+
+	sample := (<-samples).GetSamples()[0]
+	assert.Equal(t, metrics.CallbackDurationName, sample.Metric.Name)
+	assert.GreaterOrEqual(t, sample.Value, 50.0)
+	assert.Equal(t, "payments", sample.Tags.CloneTags()["server"])
+
+	// A callback which arrives before it's waited for resolves right away, as
+	// an early one, which is left out of the callback_duration metric.
+	res, err = http.Post(url+"/notify", "application/json", strings.NewReader(`{"orderId": 2}`)) //nolint:noctx
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	_, err = rt.RunString(`
+		result = {};
+		mockserver.callbacks.waitFor("2").then((cb) => { result.resolved = cb });
+	`)
+	require.NoError(t, err)
+	vu.runLoop(t)
+	v, err = rt.RunString(`[result.resolved.id, result.resolved.duration, result.resolved.early]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"2", int64(0), true}, v.Export())
+	select {
+	case sc := <-samples:
+		t.Fatalf("unexpected sample of %s for an early callback", sc.GetSamples()[0].Metric.Name)
+	default:
+	}
+}
+
+func TestCallbacksWaitForTimeout(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt, vu, _ := newLoopRuntime(t, ctx)
+
+	_, err := rt.RunString(`
+		var result = {};
+		mockserver.callbacks.waitFor("missing", "50ms").catch((e) => { result.rejected = e.toString() });
+	`)
+	require.NoError(t, err)
+	vu.runLoop(t)
+	v, err := rt.RunString(`result.rejected`)
+	require.NoError(t, err)
+	assert.Contains(t, v.String(), `the callback "missing" didn't arrive in 50ms`)
+
+	_, err = rt.RunString(`mockserver.callbacks.waitFor("missing", "soon")`)
+	assert.Error(t, err)
+}
+
+func TestCorrelationID(t *testing.T) {
+	t.Parallel()
+	req, err := http.NewRequest(http.MethodPost, "http://k6.io/hook?id=q1", nil) //nolint:noctx
+	require.NoError(t, err)
+	req.Header.Set("X-Correlation-ID", "h1")
+
+	assert.Equal(t, "h1", (&correlation{Header: "X-Correlation-ID"}).id(req, nil))
+	assert.Equal(t, "q1", (&correlation{Query: "id"}).id(req, nil))
+	assert.Equal(t, "j1", (&correlation{JSON: "id"}).id(req, []byte(`{"id": "j1"}`)))
+	assert.Equal(t, "", (&correlation{JSON: "id"}).id(req, []byte(`not json`)))
+	assert.Equal(t, "", (&correlation{JSON: "id"}).id(req, []byte(`{"id": {}}`)))
+
+	for _, raw := range []interface{}{
+		"X-Correlation-ID",
+		map[string]interface{}{},
+		map[string]interface{}{"header": "a", "query": "b"},
+		map[string]interface{}{"cookie": "a"},
+		map[string]interface{}{"json": ""},
+	} {
+		_, err := parseCorrelation(raw)
+		assert.Error(t, err, raw)
+	}
+}
+
+func TestCallbackRegistry(t *testing.T) {
+	t.Parallel()
+	req, err := http.NewRequest(http.MethodPost, "http://k6.io/hook", nil) //nolint:noctx
+	require.NoError(t, err)
+
+	t.Run("every waiter gets its own copy", func(t *testing.T) {
+		t.Parallel()
+		r := newCallbackRegistry()
+		ch1, ch2 := r.wait("a"), r.wait("a")
+		r.deliver(newCallback("s", "a", req, nil))
+		cb1, cb2 := <-ch1, <-ch2
+		assert.NotSame(t, cb1, cb2)
+		assert.Equal(t, "a", cb1.ID)
+		assert.Equal(t, "a", cb2.ID)
+	})
+
+	t.Run("the oldest received callbacks are dropped", func(t *testing.T) {
+		t.Parallel()
+		r := newCallbackRegistry()
+		r.limit = 2
+		for _, id := range []string{"a", "b", "c"} {
+			r.deliver(newCallback("s", id, req, nil))
+		}
+		assert.Len(t, r.received, 2)
+		assert.NotContains(t, r.received, "a")
+
+		// a callback which has been waited for doesn't take any room
+		<-r.wait("b")
+		r.deliver(newCallback("s", "d", req, nil))
+		assert.Len(t, r.received, 2)
+		assert.Contains(t, r.received, "c")
+		assert.Contains(t, r.received, "d")
+	})
+
+	t.Run("the expired received callbacks are dropped", func(t *testing.T) {
+		t.Parallel()
+		r := newCallbackRegistry()
+		r.ttl = time.Minute
+		old := newCallback("s", "old", req, nil)
+		old.received = old.received.Add(-2 * time.Minute)
+		r.deliver(old)
+		r.deliver(newCallback("s", "new", req, nil))
+		assert.Len(t, r.received, 1)
+		assert.Contains(t, r.received, "new")
+	})
+}
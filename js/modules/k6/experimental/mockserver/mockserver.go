@@ -0,0 +1,80 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package mockserver implements the k6/experimental/mockserver module, which
+// can be used to start local HTTP servers with stubbed routes from setup(), so
+// the flows where the tested system calls back into k6, like webhooks, can be
+// tested without a separate mock service. The callbacks with a correlation ID
+// can be awaited with callbacks.waitFor(), which measures their latency.
+package mockserver
+
+import (
+	"sync"
+
+	"go.k6.io/k6/js/modules"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU. It holds the started servers and the received
+	// callbacks, so they can be looked up by all VUs.
+	RootModule struct {
+		mx        sync.Mutex
+		servers   map[string]*Server
+		callbacks *callbackRegistry
+	}
+
+	// ModuleInstance represents an instance of the mockserver module for
+	// every VU.
+	ModuleInstance struct {
+		vu   modules.VU
+		root *RootModule
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{
+		servers:   make(map[string]*Server),
+		callbacks: newCallbackRegistry(),
+	}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (r *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu, root: r}
+}
+
+// Exports returns the exports of the mockserver module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"start":     mi.Start,
+			"get":       mi.Get,
+			"callbacks": &Callbacks{mi: mi},
+		},
+	}
+}
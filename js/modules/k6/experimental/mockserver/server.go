@@ -0,0 +1,326 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mockserver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+var errInInitContext = common.NewInitContextError("starting a mock server in the init context is not supported")
+
+const (
+	defaultName    = "default"
+	defaultAddress = "127.0.0.1:0"
+)
+
+type route struct {
+	Method  string
+	Path    string
+	Status  int
+	Body    string
+	Headers map[string]string
+	Latency time.Duration
+}
+
+type config struct {
+	Name        string
+	Address     string
+	Routes      []route
+	Correlation *correlation
+}
+
+// Server is a local HTTP server which responds with the stubs of its routes.
+type Server struct {
+	Name string `js:"name"`
+	URL  string `js:"url"`
+
+	routes      []route
+	correlation *correlation
+	callbacks   *callbackRegistry
+	server      *http.Server
+	remove      func()
+
+	mx       sync.Mutex
+	requests map[string]int64
+	stopped  bool
+}
+
+func parseStringMap(v interface{}, name string) (map[string]string, error) {
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%s must be an object with key-value pairs", name)
+	}
+	m := make(map[string]string, len(raw))
+	for k, v := range raw {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("%s %q value must be a string", name, k)
+		}
+		m[k] = s
+	}
+	return m, nil
+}
+
+func parseRoute(v interface{}) (route, error) {
+	r := route{Status: http.StatusOK}
+	raw, ok := v.(map[string]interface{})
+	if !ok {
+		return r, errors.New("routes must be objects")
+	}
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "method", "path", "body":
+			s, ok := v.(string)
+			if !ok {
+				return r, fmt.Errorf("invalid route %s value: '%#v', it needs to be a string", k, v)
+			}
+			switch k {
+			case "method":
+				r.Method = s
+			case "path":
+				r.Path = s
+			default:
+				r.Body = s
+			}
+		case "status":
+			status, ok := v.(int64)
+			if !ok || status < 100 || status > 999 {
+				return r, fmt.Errorf("invalid route status value: '%#v', it needs to be an HTTP status code", v)
+			}
+			r.Status = int(status)
+		case "headers":
+			if r.Headers, err = parseStringMap(v, "header"); err != nil {
+				return r, err
+			}
+		case "latency":
+			if r.Latency, err = types.GetDurationValue(v); err != nil {
+				return r, fmt.Errorf("invalid route latency value: %w", err)
+			}
+		default:
+			return r, fmt.Errorf("unknown route param: %q", k)
+		}
+	}
+	if r.Path == "" {
+		return r, errors.New("every route needs a path")
+	}
+	return r, nil
+}
+
+func parseConfig(raw map[string]interface{}) (config, error) {
+	c := config{Name: defaultName, Address: defaultAddress}
+	for k, v := range raw {
+		switch k {
+		case "name", "address":
+			s, ok := v.(string)
+			if !ok {
+				return c, fmt.Errorf("invalid %s value: '%#v', it needs to be a string", k, v)
+			}
+			if k == "name" {
+				c.Name = s
+			} else {
+				c.Address = s
+			}
+		case "routes":
+			rawRoutes, ok := v.([]interface{})
+			if !ok {
+				return c, errors.New("routes must be an array")
+			}
+			for _, rawRoute := range rawRoutes {
+				r, err := parseRoute(rawRoute)
+				if err != nil {
+					return c, err
+				}
+				c.Routes = append(c.Routes, r)
+			}
+		case "correlation":
+			var err error
+			if c.Correlation, err = parseCorrelation(v); err != nil {
+				return c, err
+			}
+		default:
+			return c, fmt.Errorf("unknown param: %q", k)
+		}
+	}
+	return c, nil
+}
+
+// Start starts a server with the routes of the config, which keeps running
+// until it's stopped or the test ends. The servers are shared by all VUs, and
+// their names have to be unique.
+func (mi *ModuleInstance) Start(rawConfig map[string]interface{}) (*Server, error) {
+	if mi.vu.State() == nil {
+		return nil, errInInitContext
+	}
+	c, err := parseConfig(rawConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	root := mi.root
+	root.mx.Lock()
+	defer root.mx.Unlock()
+	if _, ok := root.servers[c.Name]; ok {
+		return nil, fmt.Errorf("a mock server named %q is already running", c.Name)
+	}
+	listener, err := net.Listen("tcp", c.Address)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't start the mock server %q: %w", c.Name, err)
+	}
+	s := &Server{
+		Name:        c.Name,
+		URL:         "http://" + listener.Addr().String(),
+		routes:      c.Routes,
+		correlation: c.Correlation,
+		callbacks:   root.callbacks,
+		requests:    make(map[string]int64),
+	}
+	s.remove = func() {
+		root.mx.Lock()
+		defer root.mx.Unlock()
+		if root.servers[s.Name] == s {
+			delete(root.servers, s.Name)
+		}
+	}
+	s.server = &http.Server{Handler: s, ReadHeaderTimeout: time.Minute}
+	root.servers[c.Name] = s
+
+	go func() { _ = s.server.Serve(listener) }()
+	// The servers are stopped with the test run, not with the context of the
+	// VU, since the one of setup() is done as soon as it returns.
+	done := testRunDone(mi.vu.Context())
+	go func() {
+		<-done
+		s.Stop()
+	}()
+	return s, nil
+}
+
+// testRunDone returns a channel which is closed when the test run has ended,
+// after teardown(), or when ctx is done if it isn't the context of a test run.
+func testRunDone(ctx context.Context) <-chan struct{} {
+	executionState := lib.GetExecutionState(ctx)
+	if executionState == nil {
+		return ctx.Done()
+	}
+	events, unsubscribe := executionState.Events.Subscribe()
+	done := make(chan struct{})
+	if executionState.HasEnded() {
+		unsubscribe()
+		close(done)
+		return done
+	}
+	go func() {
+		defer unsubscribe()
+		for event := range events {
+			if event.Type == lib.EventTestFinished {
+				close(done)
+				return
+			}
+		}
+	}()
+	return done
+}
+
+// Get returns the running server with the name, or null if there isn't one.
+func (mi *ModuleInstance) Get(name string) *Server {
+	mi.root.mx.Lock()
+	defer mi.root.mx.Unlock()
+	return mi.root.servers[name]
+}
+
+// ServeHTTP responds with the stub of the first route matching the method and
+// the path of the request, after waiting for its latency. The requests with a
+// correlation ID are delivered as callbacks first.
+func (s *Server) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mx.Lock()
+	s.requests[req.URL.Path]++
+	s.mx.Unlock()
+
+	if s.correlation != nil {
+		body, _ := ioutil.ReadAll(io.LimitReader(req.Body, maxCallbackBody))
+		if id := s.correlation.id(req, body); id != "" {
+			s.callbacks.deliver(newCallback(s.Name, id, req, body))
+		}
+	}
+
+	for _, r := range s.routes {
+		if r.Path != req.URL.Path || (r.Method != "" && r.Method != req.Method) {
+			continue
+		}
+		if r.Latency > 0 {
+			select {
+			case <-time.After(r.Latency):
+			case <-req.Context().Done():
+				return
+			}
+		}
+		for k, v := range r.Headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(r.Status)
+		_, _ = w.Write([]byte(r.Body))
+		return
+	}
+	http.NotFound(w, req)
+}
+
+// RequestCount returns how many requests the server has received for the
+// path, or for all paths if it's empty.
+func (s *Server) RequestCount(path string) int64 {
+	s.mx.Lock()
+	defer s.mx.Unlock()
+	if path != "" {
+		return s.requests[path]
+	}
+	var count int64
+	for _, c := range s.requests {
+		count += c
+	}
+	return count
+}
+
+// Stop stops the server, and closes the connections of the requests which
+// are still being handled. Stopping a stopped server does nothing.
+func (s *Server) Stop() {
+	s.mx.Lock()
+	if s.stopped {
+		s.mx.Unlock()
+		return
+	}
+	s.stopped = true
+	s.mx.Unlock()
+
+	_ = s.server.Close()
+	s.remove()
+}
@@ -0,0 +1,189 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mockserver
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+)
+
+func newTestRuntime(t *testing.T, root *RootModule, ctx context.Context) (*goja.Runtime, *modulestest.VU) {
+	t.Helper()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	vu := &modulestest.VU{
+		CtxField:     ctx,
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Logger: logrus.New()},
+	}
+	m, ok := root.NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("mockserver", m.Exports().Named))
+	return rt, vu
+}
+
+func get(t *testing.T, method, url string) (*http.Response, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, nil) //nolint:noctx
+	require.NoError(t, err)
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = res.Body.Close() }()
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(t, err)
+	return res, string(body)
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	root := New()
+	rt, vu := newTestRuntime(t, root, ctx)
+
+	_, err := rt.RunString(`mockserver.start({})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "init context")
+
+	vu.StateField = &lib.State{Logger: logrus.New()}
+	v, err := rt.RunString(`
+		var server = mockserver.start({
+			name: "webhooks",
+			routes: [
+				{ method: "POST", path: "/hook", status: 202, body: "accepted", headers: { "X-Mock": "yes" } },
+				{ path: "/slow", latency: "200ms" },
+			],
+		});
+		server.url;
+	`)
+	require.NoError(t, err)
+	url := v.String()
+	assert.Regexp(t, `^http://127\.0\.0\.1:\d+$`, url)
+
+	res, body := get(t, http.MethodPost, url+"/hook")
+	assert.Equal(t, http.StatusAccepted, res.StatusCode)
+	assert.Equal(t, "accepted", body)
+	assert.Equal(t, "yes", res.Header.Get("X-Mock"))
+
+	res, _ = get(t, http.MethodGet, url+"/hook")
+	assert.Equal(t, http.StatusNotFound, res.StatusCode, "the method doesn't match")
+
+	start := time.Now()
+	res, body = get(t, http.MethodGet, url+"/slow")
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.Empty(t, body)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	// Another VU sees the same server.
+	otherRt, otherVU := newTestRuntime(t, root, ctx)
+	otherVU.StateField = &lib.State{Logger: logrus.New()}
+	v, err = otherRt.RunString(`
+		var s = mockserver.get("webhooks");
+		[s.name, s.requestCount("/hook"), s.requestCount(""), mockserver.get("nope") === null];
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"webhooks", int64(2), int64(3), true}, v.Export())
+
+	_, err = rt.RunString(`mockserver.start({ name: "webhooks" })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already running")
+
+	_, err = rt.RunString(`server.stop(); server.stop()`)
+	require.NoError(t, err)
+	m, ok := root.NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	assert.Nil(t, m.Get("webhooks"))
+	_, err = http.Get(url) //nolint:noctx
+	assert.Error(t, err)
+
+	// The servers are stopped when the test ends.
+	_, err = rt.RunString(`mockserver.start({})`)
+	require.NoError(t, err)
+	assert.NotNil(t, m.Get(defaultName))
+	cancel()
+	assert.Eventually(t, func() bool {
+		return m.Get(defaultName) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestServerStartedInSetup(t *testing.T) {
+	t.Parallel()
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	executionState := lib.NewExecutionState(lib.Options{}, et, 1, 1)
+	runCtx := lib.WithExecutionState(context.Background(), executionState)
+	setupCtx, setupCancel := context.WithCancel(runCtx)
+	root := New()
+	rt, vu := newTestRuntime(t, root, setupCtx)
+	vu.StateField = &lib.State{Logger: logrus.New()}
+
+	v, err := rt.RunString(`mockserver.start({ routes: [{ path: "/" }] }).url`)
+	require.NoError(t, err)
+	url := v.String()
+
+	// The server keeps running once setup() has returned.
+	setupCancel()
+	time.Sleep(50 * time.Millisecond)
+	res, _ := get(t, http.MethodGet, url)
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+
+	executionState.MarkStarted()
+	executionState.MarkEnded()
+	m, ok := root.NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	assert.Eventually(t, func() bool {
+		return m.Get(defaultName) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestParseConfig(t *testing.T) {
+	t.Parallel()
+	c, err := parseConfig(map[string]interface{}{})
+	require.NoError(t, err)
+	assert.Equal(t, config{Name: defaultName, Address: defaultAddress}, c)
+
+	invalid := []map[string]interface{}{
+		{"port": int64(80)},
+		{"name": int64(1)},
+		{"routes": "/"},
+		{"routes": []interface{}{map[string]interface{}{"status": int64(200)}}},
+		{"routes": []interface{}{map[string]interface{}{"path": "/", "status": int64(2)}}},
+		{"routes": []interface{}{map[string]interface{}{"path": "/", "latency": "soon"}}},
+		{"routes": []interface{}{map[string]interface{}{"path": "/", "headers": map[string]interface{}{"a": int64(1)}}}},
+		{"routes": []interface{}{map[string]interface{}{"path": "/", "query": "a=b"}}},
+	}
+	for _, raw := range invalid {
+		_, err := parseConfig(raw)
+		assert.Error(t, err, raw)
+	}
+}
@@ -0,0 +1,557 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+	gossh "golang.org/x/crypto/ssh"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/js/modules/k6/experimental/filetransfer"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+//nolint:lll
+var (
+	errOperationInInitContext = common.NewInitContextError("SSH operations in the init context are not supported")
+	errConnectInInitContext   = common.NewInitContextError("connecting to a SSH server in the init context is not supported")
+)
+
+const (
+	defaultPort     = "22"
+	defaultTimeout  = time.Minute
+	defaultParallel = 4
+)
+
+// Client represents a connection to a SSH server.
+type Client struct {
+	vu      modules.VU
+	addr    string
+	timeout time.Duration
+	client  *gossh.Client
+	sftp    *sftpSession
+}
+
+// CommandResult is the output and exit code of a command.
+type CommandResult struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+}
+
+type params struct {
+	Tags         map[string]string
+	Timeout      time.Duration
+	Stdin        string
+	ResponseType string
+	Parallel     int
+}
+
+func parseTags(v interface{}) (map[string]string, error) {
+	rawTags, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("tags must be an object with key-value pairs")
+	}
+	tags := make(map[string]string, len(rawTags))
+	for tk, tv := range rawTags {
+		strVal, ok := tv.(string)
+		if !ok {
+			return nil, fmt.Errorf("tag %q value must be a string", tk)
+		}
+		tags[tk] = strVal
+	}
+	return tags, nil
+}
+
+// parseParams parses the params of an operation, allowed lists the params
+// that are specific to the operation.
+func (c *Client) parseParams(raw map[string]interface{}, allowed ...string) (params, error) {
+	p := params{Timeout: c.timeout, ResponseType: "text", Parallel: defaultParallel}
+	for k, v := range raw {
+		isAllowed := k == "tags" || k == "timeout"
+		for _, a := range allowed {
+			isAllowed = isAllowed || k == a
+		}
+		if !isAllowed {
+			return p, fmt.Errorf("unknown param: %q", k)
+		}
+
+		var err error
+		switch k {
+		case "tags":
+			if p.Tags, err = parseTags(v); err != nil {
+				return p, err
+			}
+		case "timeout":
+			if p.Timeout, err = types.GetDurationValue(v); err != nil {
+				return p, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		case "stdin":
+			var ok bool
+			if p.Stdin, ok = v.(string); !ok {
+				return p, fmt.Errorf("invalid stdin value: '%#v', it needs to be a string", v)
+			}
+		case "responseType":
+			p.ResponseType, _ = v.(string)
+			if p.ResponseType != "text" && p.ResponseType != "binary" {
+				return p, fmt.Errorf("invalid responseType value: '%#v', it needs to be 'text' or 'binary'", v)
+			}
+		case "parallel":
+			n, ok := v.(int64)
+			if !ok || n < 1 {
+				return p, fmt.Errorf("invalid parallel value: '%#v', it needs to be a positive integer", v)
+			}
+			p.Parallel = int(n)
+		}
+	}
+	return p, nil
+}
+
+// clientConfig returns the config of the connection from the connect params.
+// The key of the server is verified with the hostKey param, like the TLS
+// certificates are, unless the insecureSkipHostKeyVerify param or the
+// insecureSkipTLSVerify option is enabled.
+//nolint:funlen,cyclop
+func (c *Client) clientConfig(raw map[string]interface{}, options lib.Options) (*gossh.ClientConfig, error) {
+	cfg := &gossh.ClientConfig{}
+	var privateKey, passphrase string
+	insecureSkipHostKeyVerify := options.InsecureSkipTLSVerify.Bool
+	c.timeout = defaultTimeout
+	for k, v := range raw {
+		var err error
+		switch k {
+		case "username", "password", "privateKey", "passphrase", "hostKey":
+			s, ok := v.(string)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s value: '%#v', it needs to be a string", k, v)
+			}
+			switch k {
+			case "username":
+				cfg.User = s
+			case "password":
+				cfg.Auth = append(cfg.Auth, gossh.Password(s))
+			case "privateKey":
+				privateKey = s
+			case "passphrase":
+				passphrase = s
+			case "hostKey":
+				hostKey, _, _, _, err := gossh.ParseAuthorizedKey([]byte(s))
+				if err != nil {
+					return nil, fmt.Errorf("invalid hostKey value: %w", err)
+				}
+				cfg.HostKeyCallback = gossh.FixedHostKey(hostKey)
+			}
+		case "timeout":
+			if c.timeout, err = types.GetDurationValue(v); err != nil {
+				return nil, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		case "insecureSkipHostKeyVerify":
+			skip, ok := v.(bool)
+			if !ok {
+				return nil, fmt.Errorf("invalid %s value: '%#v', it needs to be a boolean", k, v)
+			}
+			insecureSkipHostKeyVerify = insecureSkipHostKeyVerify || skip
+		default:
+			return nil, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+
+	if privateKey != "" {
+		var signer gossh.Signer
+		var err error
+		if passphrase != "" {
+			signer, err = gossh.ParsePrivateKeyWithPassphrase([]byte(privateKey), []byte(passphrase))
+		} else {
+			signer, err = gossh.ParsePrivateKey([]byte(privateKey))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid privateKey value: %w", err)
+		}
+		// the keys are tried before the password, like OpenSSH does
+		cfg.Auth = append([]gossh.AuthMethod{gossh.PublicKeys(signer)}, cfg.Auth...)
+	}
+	if cfg.User == "" {
+		return nil, errors.New("the username is required")
+	}
+	if cfg.HostKeyCallback == nil {
+		if !insecureSkipHostKeyVerify {
+			return nil, errors.New("the hostKey of the server is required to verify it, " +
+				"unless the insecureSkipHostKeyVerify param or the insecureSkipTLSVerify option is enabled")
+		}
+		cfg.HostKeyCallback = gossh.InsecureIgnoreHostKey() //nolint:gosec
+	}
+	return cfg, nil
+}
+
+// Connect connects and authenticates to the SSH server at the given address,
+// with a password and/or a private key, once its host key is verified.
+func (c *Client) Connect(addr string, rawParams map[string]interface{}) error {
+	state := c.vu.State()
+	if state == nil {
+		return errConnectInInitContext
+	}
+	cfg, err := c.clientConfig(rawParams, state.Options)
+	if err != nil {
+		return err
+	}
+	if _, _, err = net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(strings.Trim(addr, "[]"), defaultPort)
+	}
+
+	_ = c.Close()
+	ctx, cancel := context.WithTimeout(c.vu.Context(), c.timeout)
+	defer cancel()
+	conn, err := state.Dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return err
+	}
+	// the deadline only applies to the handshake
+	if err = conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		_ = conn.Close()
+		return err
+	}
+	sshConn, chans, reqs, err := gossh.NewClientConn(conn, addr, cfg)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+	if err = conn.SetDeadline(time.Time{}); err != nil {
+		_ = sshConn.Close()
+		return err
+	}
+	c.addr, c.client = addr, gossh.NewClient(sshConn, chans, reqs)
+	return nil
+}
+
+// Run runs the command on the server and returns its output and exit code.
+// A non-zero exit code isn't an error.
+func (c *Client) Run(command string, rawParams map[string]interface{}) (*CommandResult, error) {
+	p, err := c.parseParams(rawParams, "stdin")
+	if err != nil {
+		return nil, err
+	}
+
+	res := &CommandResult{}
+	err = c.do("run", p, func() (string, error) {
+		session, err := c.client.NewSession()
+		if err != nil {
+			return "", err
+		}
+		defer func() { _ = session.Close() }()
+
+		var stdout, stderr bytes.Buffer
+		session.Stdin = strings.NewReader(p.Stdin)
+		session.Stdout, session.Stderr = &stdout, &stderr
+		err = withTimeout(p.Timeout, func() error { return session.Run(command) }, func() {
+			_ = session.Signal(gossh.SIGKILL)
+			_ = session.Close()
+		})
+		var exitErr *gossh.ExitError
+		if errors.As(err, &exitErr) {
+			res.ExitCode, err = exitErr.ExitStatus(), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		res.Stdout, res.Stderr = stdout.String(), stderr.String()
+		return strconv.Itoa(res.ExitCode), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return res, nil
+}
+
+// Upload writes the data, a string or an ArrayBuffer, to the file at the
+// remote path with SFTP.
+func (c *Client) Upload(remotePath string, data goja.Value, rawParams map[string]interface{}) error {
+	p, err := c.parseParams(rawParams)
+	if err != nil {
+		return err
+	}
+	var exported interface{}
+	if data != nil {
+		exported = data.Export()
+	}
+	b, err := common.ToBytes(exported)
+	if err != nil {
+		return err
+	}
+	return c.transfer(&filetransfer.Transfer{Method: filetransfer.MethodUpload, Path: remotePath, Data: b}, p)
+}
+
+// Download reads the file at the remote path with SFTP, it's returned as a
+// string unless the responseType param is binary.
+func (c *Client) Download(remotePath string, rawParams map[string]interface{}) (interface{}, error) {
+	p, err := c.parseParams(rawParams, "responseType")
+	if err != nil {
+		return nil, err
+	}
+	t := &filetransfer.Transfer{
+		Method: filetransfer.MethodDownload, Path: remotePath, Binary: p.ResponseType == "binary",
+	}
+	if err = c.transfer(t, p); err != nil {
+		return nil, err
+	}
+	return filetransfer.Results(c.vu.Runtime(), []*filetransfer.Transfer{t})[0], nil
+}
+
+func (c *Client) transfer(t *filetransfer.Transfer, p params) error {
+	tags, err := c.tags(p)
+	if err != nil {
+		return err
+	}
+	var duration time.Duration
+	err = c.withSFTP(p.Timeout, func(s *sftpSession) (err error) {
+		duration, err = filetransfer.Do(s, t)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	c.pushTransfer(tags, t, duration)
+	return nil
+}
+
+// Batch runs the uploads and downloads in parallel, each parallel transfer
+// using its own SFTP session. The results are the downloaded files, or null
+// for the uploads.
+func (c *Client) Batch(transfers goja.Value, rawParams map[string]interface{}) ([]interface{}, error) {
+	p, err := c.parseParams(rawParams, "parallel")
+	if err != nil {
+		return nil, err
+	}
+	batch, err := filetransfer.ParseBatch(transfers)
+	if err != nil {
+		return nil, err
+	}
+	tags, err := c.tags(p)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.sftp == nil {
+		if c.sftp, err = newSFTPSession(c.client); err != nil {
+			return nil, err
+		}
+	}
+	sessions := []*sftpSession{c.sftp}
+	defer func() {
+		for _, s := range sessions[1:] {
+			_ = s.close()
+		}
+	}()
+	for len(sessions) < p.Parallel && len(sessions) < len(batch) {
+		s, err := newSFTPSession(c.client)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+	workers := make([]filetransfer.Worker, len(sessions))
+	for i, s := range sessions {
+		workers[i] = s
+	}
+
+	ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+	defer cancel()
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			// closing the sessions stops the transfers in progress
+			for _, s := range sessions {
+				_ = s.close()
+			}
+		case <-done:
+		}
+	}()
+	err = filetransfer.RunBatch(ctx, batch, workers, func(t *filetransfer.Transfer, duration time.Duration) {
+		c.pushTransfer(tags, t, duration)
+	})
+	close(done)
+	<-stopped
+
+	var statusErr *sftpError
+	if (err != nil && !errors.As(err, &statusErr)) || ctx.Err() != nil {
+		_ = c.sftp.close()
+		c.sftp = nil
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("the operation timed out after %s", p.Timeout)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return filetransfer.Results(c.vu.Runtime(), batch), nil
+}
+
+// withSFTP runs fn with the SFTP session, starting it if needed.
+func (c *Client) withSFTP(timeout time.Duration, fn func(*sftpSession) error) error {
+	if c.sftp == nil {
+		s, err := newSFTPSession(c.client)
+		if err != nil {
+			return err
+		}
+		c.sftp = s
+	}
+	s := c.sftp
+	err := withTimeout(timeout, func() error { return fn(s) }, func() { _ = s.close() })
+	var statusErr *sftpError
+	if err != nil && !errors.As(err, &statusErr) {
+		// the session is unusable after anything other than a status error
+		_ = s.close()
+		c.sftp = nil
+	}
+	return err
+}
+
+// withTimeout runs fn and calls cancel if it doesn't return in time, which
+// should make fn return.
+func withTimeout(timeout time.Duration, fn func() error, cancel func()) error {
+	timedOut := make(chan struct{})
+	timer := time.AfterFunc(timeout, func() {
+		close(timedOut)
+		cancel()
+	})
+	err := fn()
+	if !timer.Stop() {
+		<-timedOut
+		return fmt.Errorf("the operation timed out after %s", timeout)
+	}
+	return err
+}
+
+// tags returns the tags of the samples of an operation, the method tag is
+// set when the samples are emitted.
+func (c *Client) tags(p params) (map[string]string, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errOperationInInitContext
+	}
+	if c.client == nil {
+		return nil, errors.New("no SSH connection, you must call connect first")
+	}
+
+	tags := state.CloneTags()
+	for k, v := range p.Tags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = c.addr
+	}
+	return tags, nil
+}
+
+// do runs an operation and emits its duration as a sample, fn returns the
+// value of the status tag.
+func (c *Client) do(method string, p params, fn func() (string, error)) error {
+	tags, err := c.tags(p)
+	if err != nil {
+		return err
+	}
+
+	startTime := time.Now()
+	status, err := fn()
+	endTime := time.Now()
+	if err != nil {
+		return err
+	}
+
+	state := c.vu.State()
+	if state.Options.SystemTags.Has(stats.TagMethod) {
+		tags["method"] = method
+	}
+	if status != "" && state.Options.SystemTags.Has(stats.TagStatus) {
+		tags["status"] = status
+	}
+	stats.PushIfNotDone(c.vu.Context(), state.Samples, stats.Sample{
+		Metric: state.BuiltinMetrics.SSHReqDuration,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  stats.D(endTime.Sub(startTime)),
+		Time:   endTime,
+	})
+	return nil
+}
+
+// pushTransfer emits the duration and the throughput of a SFTP transfer. It
+// may be called concurrently by the transfers of a batch.
+func (c *Client) pushTransfer(baseTags map[string]string, t *filetransfer.Transfer, duration time.Duration) {
+	state := c.vu.State()
+	tags := make(map[string]string, len(baseTags)+2)
+	for k, v := range baseTags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagMethod) {
+		tags["method"] = t.Method
+	}
+	if state.Options.SystemTags.Has(stats.TagProto) {
+		tags["proto"] = "sftp"
+	}
+
+	now := time.Now()
+	sampleTags := stats.IntoSampleTags(&tags)
+	stats.PushIfNotDone(c.vu.Context(), state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{
+				Metric: state.BuiltinMetrics.SSHReqDuration,
+				Tags:   sampleTags,
+				Value:  stats.D(duration),
+				Time:   now,
+			},
+			{
+				Metric: state.BuiltinMetrics.FileTransferThroughput,
+				Tags:   sampleTags,
+				Value:  filetransfer.Throughput(len(t.Data), duration),
+				Time:   now,
+			},
+		},
+		Tags: sampleTags,
+		Time: now,
+	})
+}
+
+// Close closes the connection to the SSH server.
+func (c *Client) Close() error {
+	if c.sftp != nil {
+		_ = c.sftp.close()
+		c.sftp = nil
+	}
+	if c.client == nil {
+		return nil
+	}
+	err := c.client.Close()
+	c.client = nil
+	return err
+}
@@ -0,0 +1,243 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ssh
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+type testState struct {
+	rt      *goja.Runtime
+	vu      *modulestest.VU
+	state   *lib.State
+	samples chan stats.SampleContainer
+	server  *server
+}
+
+func newTestState(t *testing.T) testState {
+	t.Helper()
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Logger: logrus.New()},
+	}
+	state := &lib.State{
+		Dialer:  &net.Dialer{},
+		Samples: samples,
+		Options: lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagURL, stats.TagStatus, stats.TagMethod),
+		},
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewTagMap(nil),
+	}
+
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("ssh", m.Exports().Named))
+	_, err := rt.RunString(`var client = new ssh.Client();`)
+	require.NoError(t, err)
+
+	srv := newServer(t)
+	require.NoError(t, rt.Set("addr", srv.addr))
+	require.NoError(t, rt.Set("hostKey", string(gossh.MarshalAuthorizedKey(srv.hostKey))))
+	return testState{rt: rt, vu: vu, state: state, samples: samples, server: srv}
+}
+
+func TestClientInitContext(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+
+	_, err := ts.rt.RunString(`client.connect(addr, {username: "k6", password: "secret", hostKey: hostKey})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connecting to a SSH server in the init context is not supported")
+
+	_, err = ts.rt.RunString(`client.run("echo hi")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "SSH operations in the init context are not supported")
+}
+
+func TestClientConnect(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+	require.NoError(t, ts.rt.Set("key", ts.server.clientKeyPEM("")))
+	require.NoError(t, ts.rt.Set("encryptedKey", ts.server.clientKeyPEM("passphrase")))
+
+	// a different host key than the server's
+	otherSigner, err := gossh.NewSignerFromKey(newECDSAKey(t))
+	require.NoError(t, err)
+	require.NoError(t, ts.rt.Set("otherHostKey", string(gossh.MarshalAuthorizedKey(otherSigner.PublicKey()))))
+
+	tests := []struct {
+		code, expErr string
+	}{
+		{`client.connect(addr, {username: "k6", password: "secret", hostKey: hostKey})`, ""},
+		{`client.connect(addr, {username: "k6", password: "secret", insecureSkipHostKeyVerify: true})`, ""},
+		{`client.connect(addr, {username: "k6", password: "secret"})`, "the hostKey of the server is required"},
+		{`client.connect(addr, {username: "k6", password: "secret", insecureSkipHostKeyVerify: 1})`,
+			"invalid insecureSkipHostKeyVerify value"},
+		{`client.connect(addr, {username: "k6", privateKey: key, hostKey: hostKey})`, ""},
+		{`client.connect(addr, {username: "k6", privateKey: encryptedKey, passphrase: "passphrase", hostKey: hostKey})`, ""},
+		{`client.connect(addr, {username: "k6", password: "wrong", hostKey: hostKey})`, "unable to authenticate"},
+		{`client.connect(addr, {username: "k6", password: "secret", hostKey: otherHostKey})`, "host key mismatch"},
+		{`client.connect(addr, {username: "k6", privateKey: encryptedKey, passphrase: "x"})`, "invalid privateKey"},
+		{`client.connect(addr, {username: "k6", privateKey: "foo"})`, "invalid privateKey value"},
+		{`client.connect(addr, {username: "k6", hostKey: "foo"})`, "invalid hostKey value"},
+		{`client.connect(addr, {username: 1})`, "invalid username value"},
+		{`client.connect(addr, {password: "secret"})`, "the username is required"},
+		{`client.connect(addr, {username: "k6", foo: 1})`, `unknown connect param: "foo"`},
+	}
+	for _, tc := range tests {
+		_, err := ts.rt.RunString(tc.code + "; client.close();")
+		if tc.expErr == "" {
+			assert.NoError(t, err, tc.code)
+			continue
+		}
+		require.Error(t, err, tc.code)
+		assert.Contains(t, err.Error(), tc.expErr, tc.code)
+	}
+
+	// the host key isn't verified with the insecureSkipTLSVerify option, unless it's set
+	ts.state.Options.InsecureSkipTLSVerify = null.BoolFrom(true)
+	_, err = ts.rt.RunString(`client.connect(addr, {username: "k6", password: "secret"}); client.close();`)
+	assert.NoError(t, err)
+	_, err = ts.rt.RunString(`client.connect(addr, {username: "k6", password: "secret", hostKey: otherHostKey})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "host key mismatch")
+}
+
+func TestClientRun(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+
+	_, err := ts.rt.RunString(`client.run("echo hi")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no SSH connection, you must call connect first")
+
+	_, err = ts.rt.RunString(`
+		client.connect(addr, {username: "k6", password: "secret", hostKey: hostKey});
+
+		var res = client.run("echo hello world", {tags: {foo: "bar"}});
+		if (res.stdout !== "hello world\n" || res.stderr !== "" || res.exit_code !== 0) {
+			throw new Error("unexpected echo result: " + JSON.stringify(res));
+		}
+		res = client.run("cat", {stdin: "some input"});
+		if (res.stdout !== "some input") { throw new Error("unexpected cat result: " + JSON.stringify(res)); }
+		res = client.run("fail");
+		if (res.stderr !== "oops\n" || res.exit_code !== 3) {
+			throw new Error("unexpected fail result: " + JSON.stringify(res));
+		}
+	`)
+	require.NoError(t, err)
+
+	_, err = ts.rt.RunString(`client.run("sleep", {timeout: "100ms"})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the operation timed out after 100ms")
+
+	_, err = ts.rt.RunString(`client.run("echo hi", {responseType: "binary"})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown param: "responseType"`)
+
+	bufSamples := stats.GetBufferedSamples(ts.samples)
+	require.Len(t, bufSamples, 3)
+	var statuses []string
+	for _, sc := range bufSamples {
+		sample := sc.GetSamples()[0]
+		assert.Equal(t, metrics.SSHReqDurationName, sample.Metric.Name)
+		status, _ := sample.Tags.Get("status")
+		statuses = append(statuses, status)
+	}
+	assert.Equal(t, []string{"0", "0", "3"}, statuses)
+	assert.Equal(t, map[string]string{
+		"foo": "bar", "url": ts.server.addr, "method": "run", "status": "0",
+	}, bufSamples[0].GetSamples()[0].Tags.CloneTags())
+}
+
+func TestClientSFTP(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+	big := strings.Repeat("0123456789", 10000) // a few chunks
+	require.NoError(t, ts.rt.Set("big", big))
+
+	_, err := ts.rt.RunString(`
+		client.connect(addr, {username: "k6", password: "secret", hostKey: hostKey});
+
+		var motd = client.download("/etc/motd");
+		if (motd !== "hello") { throw new Error("unexpected download: " + motd); }
+		var bin = client.download("/etc/motd", {responseType: "binary"});
+		if (!(bin instanceof ArrayBuffer) || bin.byteLength !== 5) {
+			throw new Error("unexpected binary download: " + bin);
+		}
+
+		client.upload("/tmp/big", big);
+		if (client.download("/tmp/big") !== big) { throw new Error("unexpected download of the upload"); }
+		client.upload("/tmp/bin", new Uint8Array([1, 2, 3]).buffer);
+		client.upload("/tmp/empty", "");
+		if (client.download("/tmp/empty") !== "") { throw new Error("unexpected download of the empty file"); }
+	`)
+	require.NoError(t, err)
+	data, ok := ts.server.file("/tmp/big")
+	require.True(t, ok)
+	assert.Equal(t, big, string(data))
+	data, _ = ts.server.file("/tmp/bin")
+	assert.Equal(t, []byte{1, 2, 3}, data)
+
+	_, err = ts.rt.RunString(`client.download("/missing")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "sftp: no such file (code 2)")
+
+	// the session is still usable after a status error
+	_, err = ts.rt.RunString(`client.upload("/tmp/x", 1)`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid type int64")
+	_, err = ts.rt.RunString(`client.download("/etc/motd"); client.close()`)
+	require.NoError(t, err)
+
+	var methods []string
+	for _, sc := range stats.GetBufferedSamples(ts.samples) {
+		method, _ := sc.GetSamples()[0].Tags.Get("method")
+		methods = append(methods, method)
+	}
+	assert.Equal(t, []string{"download", "download", "upload", "download", "upload", "upload", "download", "download"},
+		methods)
+}
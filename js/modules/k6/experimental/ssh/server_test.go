@@ -0,0 +1,258 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ssh
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+const (
+	testUser     = "k6"
+	testPassword = "secret"
+)
+
+// server is a minimal in-process SSH server, which runs a few fake commands
+// and serves the files in memory with SFTP.
+type server struct {
+	t         *testing.T
+	addr      string
+	hostKey   gossh.PublicKey
+	clientKey *ecdsa.PrivateKey
+
+	mu    sync.Mutex
+	files map[string][]byte
+}
+
+func newECDSAKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	return key
+}
+
+func newServer(t *testing.T) *server {
+	hostSigner, err := gossh.NewSignerFromKey(newECDSAKey(t))
+	require.NoError(t, err)
+	s := &server{
+		t:         t,
+		hostKey:   hostSigner.PublicKey(),
+		clientKey: newECDSAKey(t),
+		files:     map[string][]byte{"/etc/motd": []byte("hello")},
+	}
+	clientPublicKey, err := gossh.NewPublicKey(&s.clientKey.PublicKey)
+	require.NoError(t, err)
+
+	cfg := &gossh.ServerConfig{
+		PasswordCallback: func(c gossh.ConnMetadata, password []byte) (*gossh.Permissions, error) {
+			if c.User() == testUser && string(password) == testPassword {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+		PublicKeyCallback: func(c gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if c.User() == testUser && bytes.Equal(key.Marshal(), clientPublicKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	cfg.AddHostKey(hostSigner)
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+	s.addr = l.Addr().String()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn, cfg)
+		}
+	}()
+	return s
+}
+
+// clientKeyPEM returns the client key, encrypted if there is a passphrase.
+func (s *server) clientKeyPEM(passphrase string) string {
+	der, err := x509.MarshalECPrivateKey(s.clientKey)
+	require.NoError(s.t, err)
+	block := &pem.Block{Type: "EC PRIVATE KEY", Bytes: der}
+	if passphrase != "" {
+		//nolint:staticcheck // legacy PEM encryption is still widely used for keys
+		block, err = x509.EncryptPEMBlock(rand.Reader, block.Type, der, []byte(passphrase), x509.PEMCipherAES256)
+		require.NoError(s.t, err)
+	}
+	return string(pem.EncodeToMemory(block))
+}
+
+func (s *server) serve(conn net.Conn, cfg *gossh.ServerConfig) {
+	sshConn, chans, reqs, err := gossh.NewServerConn(conn, cfg)
+	if err != nil {
+		return
+	}
+	defer func() { _ = sshConn.Close() }()
+	go gossh.DiscardRequests(reqs)
+
+	for newChannel := range chans {
+		if newChannel.ChannelType() != "session" {
+			_ = newChannel.Reject(gossh.UnknownChannelType, "unsupported channel type")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go s.serveSession(channel, requests)
+	}
+}
+
+func (s *server) serveSession(channel gossh.Channel, requests <-chan *gossh.Request) {
+	defer func() { _ = channel.Close() }()
+	for req := range requests {
+		arg, _, _ := readString(req.Payload)
+		switch req.Type {
+		case "exec":
+			_ = req.Reply(true, nil)
+			code, ok := runCommand(string(arg), channel)
+			if !ok {
+				continue // until the client closes the channel
+			}
+			_, _ = channel.SendRequest("exit-status", false, appendUint32(nil, code))
+			return
+		case "subsystem":
+			_ = req.Reply(string(arg) == "sftp", nil)
+			if string(arg) == "sftp" {
+				s.serveSFTP(channel)
+				return
+			}
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+// runCommand runs a fake command and returns its exit code, or false if it
+// never exits.
+func runCommand(command string, channel gossh.Channel) (uint32, bool) {
+	switch {
+	case strings.HasPrefix(command, "echo "):
+		_, _ = io.WriteString(channel, strings.TrimPrefix(command, "echo ")+"\n")
+	case command == "cat":
+		_, _ = io.Copy(channel, channel)
+	case command == "fail":
+		_, _ = io.WriteString(channel.Stderr(), "oops\n")
+		return 3, true
+	case command == "sleep":
+		return 0, false
+	default:
+		_, _ = io.WriteString(channel.Stderr(), command+": command not found\n")
+		return 127, true
+	}
+	return 0, true
+}
+
+func (s *server) file(path string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.files[path]
+	return data, ok
+}
+
+func (s *server) serveSFTP(channel gossh.Channel) {
+	sess := &sftpSession{w: channel, r: channel}
+	handles := map[string]string{}
+	for {
+		typ, payload, err := sess.readPacket()
+		if err != nil {
+			return
+		}
+		if typ == sftpPacketInit {
+			_ = sess.writePacket(sftpPacketVersion, appendUint32(nil, sftpVersion))
+			continue
+		}
+		id := append([]byte{}, payload[:4]...)
+		payload = payload[4:]
+		status := func(code uint32, message string) {
+			resp := appendString(appendString(appendUint32(id, code), []byte(message)), nil)
+			_ = sess.writePacket(sftpPacketStatus, resp)
+		}
+
+		handle, rest, _ := readString(payload)
+		switch typ {
+		case sftpPacketOpen:
+			path := string(handle)
+			flags := binary.BigEndian.Uint32(rest)
+			if _, ok := s.file(path); !ok && flags&sftpFlagCreate == 0 {
+				status(2, "no such file")
+				continue
+			}
+			if flags&sftpFlagTrunc != 0 {
+				s.mu.Lock()
+				s.files[path] = nil
+				s.mu.Unlock()
+			}
+			h := string(rune('a' + len(handles)))
+			handles[h] = path
+			_ = sess.writePacket(sftpPacketHandle, appendString(id, []byte(h)))
+		case sftpPacketRead:
+			data, _ := s.file(handles[string(handle)])
+			offset := binary.BigEndian.Uint64(rest)
+			length := uint64(binary.BigEndian.Uint32(rest[8:]))
+			if offset >= uint64(len(data)) {
+				status(sftpStatusEOF, "EOF")
+				continue
+			}
+			end := offset + length
+			if end > uint64(len(data)) {
+				end = uint64(len(data))
+			}
+			_ = sess.writePacket(sftpPacketData, appendString(id, data[offset:end]))
+		case sftpPacketWrite:
+			path := handles[string(handle)]
+			offset := binary.BigEndian.Uint64(rest)
+			data, _, _ := readString(rest[8:])
+			s.mu.Lock()
+			s.files[path] = append(s.files[path][:offset], data...)
+			s.mu.Unlock()
+			status(sftpStatusOK, "")
+		case sftpPacketClose:
+			delete(handles, string(handle))
+			status(sftpStatusOK, "")
+		default:
+			status(8, "unsupported")
+		}
+	}
+}
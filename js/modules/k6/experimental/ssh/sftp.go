@@ -0,0 +1,277 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// The subset of the SFTP protocol version 3 that is needed to upload and
+// download files, see draft-ietf-secsh-filexfer-02.
+const (
+	sftpVersion = 3
+
+	sftpPacketInit    = 1
+	sftpPacketVersion = 2
+	sftpPacketOpen    = 3
+	sftpPacketClose   = 4
+	sftpPacketRead    = 5
+	sftpPacketWrite   = 6
+	sftpPacketStatus  = 101
+	sftpPacketHandle  = 102
+	sftpPacketData    = 103
+
+	sftpFlagRead   = 0x01
+	sftpFlagWrite  = 0x02
+	sftpFlagCreate = 0x08
+	sftpFlagTrunc  = 0x10
+
+	sftpStatusOK  = 0
+	sftpStatusEOF = 1
+
+	// sftpChunkSize is the size of the reads and writes, which all servers
+	// are required to support.
+	sftpChunkSize = 32 * 1024
+	// sftpMaxPacketSize limits the size of the packets read from the server.
+	sftpMaxPacketSize = 256 * 1024
+)
+
+// sftpError is a SFTP status other than OK returned by the server.
+type sftpError struct {
+	code    uint32
+	message string
+}
+
+func (e *sftpError) Error() string {
+	return fmt.Sprintf("sftp: %s (code %d)", e.message, e.code)
+}
+
+// sftpSession is a SFTP subsystem running on a SSH session. Requests are sent
+// one at a time, so they don't need to be matched with their responses.
+type sftpSession struct {
+	session *gossh.Session
+	w       io.WriteCloser
+	r       io.Reader
+	id      uint32
+}
+
+func newSFTPSession(client *gossh.Client) (*sftpSession, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+	s := &sftpSession{session: session}
+	if s.w, err = session.StdinPipe(); err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+	if s.r, err = session.StdoutPipe(); err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+	if err = session.RequestSubsystem("sftp"); err != nil {
+		_ = session.Close()
+		return nil, err
+	}
+
+	init := make([]byte, 4)
+	binary.BigEndian.PutUint32(init, sftpVersion)
+	if err = s.writePacket(sftpPacketInit, init); err != nil {
+		_ = s.close()
+		return nil, err
+	}
+	typ, _, err := s.readPacket()
+	if err == nil && typ != sftpPacketVersion {
+		err = fmt.Errorf("sftp: expected a version packet, got %d", typ)
+	}
+	if err != nil {
+		_ = s.close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *sftpSession) close() error {
+	_ = s.w.Close()
+	return s.session.Close()
+}
+
+func (s *sftpSession) writePacket(typ byte, payload []byte) error {
+	header := make([]byte, 5)
+	binary.BigEndian.PutUint32(header, uint32(len(payload)+1))
+	header[4] = typ
+	_, err := s.w.Write(append(header, payload...))
+	return err
+}
+
+func (s *sftpSession) readPacket() (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(s.r, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:4])
+	if length < 1 || length > sftpMaxPacketSize {
+		return 0, nil, fmt.Errorf("sftp: invalid packet length %d", length)
+	}
+	payload := make([]byte, length-1)
+	if _, err := io.ReadFull(s.r, payload); err != nil {
+		return 0, nil, err
+	}
+	return header[4], payload, nil
+}
+
+// request sends a request and returns the type and payload of the response,
+// without the request ID. Status responses other than OK are returned as an
+// error.
+func (s *sftpSession) request(typ byte, fields ...interface{}) (byte, []byte, error) {
+	s.id++
+	payload := appendUint32(nil, s.id)
+	for _, field := range fields {
+		switch v := field.(type) {
+		case uint32:
+			payload = appendUint32(payload, v)
+		case uint64:
+			payload = appendUint32(appendUint32(payload, uint32(v>>32)), uint32(v))
+		case string:
+			payload = appendString(payload, []byte(v))
+		case []byte:
+			payload = appendString(payload, v)
+		default:
+			panic(fmt.Sprintf("unsupported sftp field type %T", field))
+		}
+	}
+	if err := s.writePacket(typ, payload); err != nil {
+		return 0, nil, err
+	}
+
+	respType, resp, err := s.readPacket()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(resp) < 4 || binary.BigEndian.Uint32(resp) != s.id {
+		return 0, nil, errors.New("sftp: the response doesn't match the request")
+	}
+	resp = resp[4:]
+	if respType != sftpPacketStatus {
+		return respType, resp, nil
+	}
+	if len(resp) < 4 {
+		return 0, nil, errors.New("sftp: invalid status response")
+	}
+	code := binary.BigEndian.Uint32(resp)
+	if code == sftpStatusOK {
+		return respType, nil, nil
+	}
+	message, _, _ := readString(resp[4:])
+	return 0, nil, &sftpError{code: code, message: string(message)}
+}
+
+func (s *sftpSession) open(path string, flags uint32) ([]byte, error) {
+	// the flags are followed by empty attributes
+	typ, resp, err := s.request(sftpPacketOpen, path, flags, uint32(0))
+	if err != nil {
+		return nil, err
+	}
+	handle, _, ok := readString(resp)
+	if typ != sftpPacketHandle || !ok {
+		return nil, errors.New("sftp: invalid open response")
+	}
+	return handle, nil
+}
+
+func (s *sftpSession) closeHandle(handle []byte) error {
+	_, _, err := s.request(sftpPacketClose, handle)
+	return err
+}
+
+// Upload writes data to the file at path, which is created or truncated.
+func (s *sftpSession) Upload(path string, data []byte) error {
+	handle, err := s.open(path, sftpFlagWrite|sftpFlagCreate|sftpFlagTrunc)
+	if err != nil {
+		return err
+	}
+	for offset := 0; offset < len(data); offset += sftpChunkSize {
+		end := offset + sftpChunkSize
+		if end > len(data) {
+			end = len(data)
+		}
+		if _, _, err = s.request(sftpPacketWrite, handle, uint64(offset), data[offset:end]); err != nil {
+			_ = s.closeHandle(handle)
+			return err
+		}
+	}
+	return s.closeHandle(handle)
+}
+
+// Download reads the whole file at path.
+func (s *sftpSession) Download(path string) ([]byte, error) {
+	handle, err := s.open(path, sftpFlagRead)
+	if err != nil {
+		return nil, err
+	}
+	var data []byte
+	for {
+		typ, resp, err := s.request(sftpPacketRead, handle, uint64(len(data)), uint32(sftpChunkSize))
+		var statusErr *sftpError
+		if errors.As(err, &statusErr) && statusErr.code == sftpStatusEOF {
+			break
+		}
+		if err == nil && typ != sftpPacketData {
+			err = errors.New("sftp: invalid read response")
+		}
+		if err != nil {
+			_ = s.closeHandle(handle)
+			return nil, err
+		}
+		chunk, _, ok := readString(resp)
+		if !ok {
+			_ = s.closeHandle(handle)
+			return nil, errors.New("sftp: invalid read response")
+		}
+		data = append(data, chunk...)
+	}
+	return data, s.closeHandle(handle)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+}
+
+func appendString(b []byte, s []byte) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+// readString reads a length-prefixed string and returns the rest of b.
+func readString(b []byte) ([]byte, []byte, bool) {
+	if len(b) < 4 {
+		return nil, nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint64(len(b)-4) < uint64(n) {
+		return nil, nil, false
+	}
+	return b[4 : 4+n], b[4+n:], true
+}
@@ -0,0 +1,100 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sync
+
+import (
+	"fmt"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+// semaphore has a buffered channel with a slot for every permit, a permit is
+// acquired by sending to it and released by receiving from it. The permits are
+// only held for the calls of the functions given to with, so a VU can't leave
+// a lock held past its iteration, or release one held by another VU.
+type semaphore struct {
+	kind, name string
+	permits    chan struct{}
+}
+
+func newSemaphore(kind, name string, permits int64) *semaphore {
+	return &semaphore{kind: kind, name: name, permits: make(chan struct{}, permits)}
+}
+
+// with calls fn with a permit, which is released after it returns or throws.
+// It waits for the permit until the iteration of the VU is interrupted, and
+// it's not supported in the init context, as it would block the
+// initialization of the other VUs.
+func (s *semaphore) with(vu modules.VU, fn goja.Value) (goja.Value, error) {
+	callable, ok := goja.AssertFunction(fn)
+	if !ok {
+		return nil, fmt.Errorf("a function is expected to be called with the %s %q locked", s.kind, s.name)
+	}
+	if vu.State() == nil {
+		return nil, common.NewInitContextError(fmt.Sprintf("locking the %s %q in the init context is not supported",
+			s.kind, s.name))
+	}
+	ctx := vu.Context()
+	select {
+	case s.permits <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("couldn't lock the %s %q: %w", s.kind, s.name, ctx.Err())
+	}
+	defer func() {
+		<-s.permits
+	}()
+	return callable(goja.Undefined())
+}
+
+// Mutex is a named lock which at most one VU holds at a time.
+type Mutex struct {
+	vu  modules.VU
+	sem *semaphore
+}
+
+// WithLock waits until the mutex is unlocked, calls fn with it locked, unlocks
+// it after fn returns or throws, and returns what fn returned. The mutex isn't
+// held for what fn does asynchronously.
+func (m *Mutex) WithLock(fn goja.Value) (goja.Value, error) {
+	return m.sem.with(m.vu, fn)
+}
+
+// Semaphore is a named lock which at most as many VUs as its permits hold at
+// a time.
+type Semaphore struct {
+	vu  modules.VU
+	sem *semaphore
+}
+
+// WithPermit waits until there's a permit, calls fn with it, gives it back
+// after fn returns or throws, and returns what fn returned. The permit isn't
+// held for what fn does asynchronously.
+func (s *Semaphore) WithPermit(fn goja.Value) (goja.Value, error) {
+	return s.sem.with(s.vu, fn)
+}
+
+// Permits returns how many permits the semaphore has in total.
+func (s *Semaphore) Permits() int {
+	return cap(s.sem.permits)
+}
@@ -0,0 +1,217 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+)
+
+// rateLimiter is a token bucket with a token for every request it allows, of
+// which it has as many as its rate, and which it refills at the rate. A token
+// is reserved in order, even if it's waited for, so the VUs are let through
+// in the order they asked.
+type rateLimiter struct {
+	name   string
+	rate   float64
+	period time.Duration
+
+	mu     sync.Mutex
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(name string, rate float64, period time.Duration) *rateLimiter {
+	return &rateLimiter{name: name, rate: rate, period: period, tokens: math.Max(rate, 1)}
+}
+
+func (l *rateLimiter) String() string {
+	return fmt.Sprintf("%g per %s", l.rate, l.period)
+}
+
+// refill adds the tokens the rate added since the last refill, up to the rate.
+func (l *rateLimiter) refill(now time.Time) {
+	if !l.last.IsZero() && now.After(l.last) {
+		l.tokens += float64(now.Sub(l.last)) / float64(l.period) * l.rate
+		l.tokens = math.Min(l.tokens, math.Max(l.rate, 1))
+	}
+	if now.After(l.last) {
+		l.last = now
+	}
+}
+
+// reserve takes a token, and returns how long to wait until it's available.
+func (l *rateLimiter) reserve(now time.Time) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens / l.rate * float64(l.period))
+}
+
+// cancel gives back a token which was reserved, but wasn't waited for.
+func (l *rateLimiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = math.Min(l.tokens+1, math.Max(l.rate, 1))
+}
+
+// tryTake takes a token and returns true if there's one, or returns false
+// without waiting.
+func (l *rateLimiter) tryTake(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.refill(now)
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// rateLimiters are the rate limiters of the test run by name, like the locks,
+// the ones with the same name in all the VUs are the same.
+type rateLimiters struct {
+	mu     sync.Mutex
+	byName map[string]*rateLimiter
+}
+
+// get returns the rate limiter with the name, created with the rate and the
+// period if it doesn't exist yet.
+func (r *rateLimiters) get(name string, rate float64, period time.Duration) (*rateLimiter, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if l, ok := r.byName[name]; ok {
+		if l.rate != rate || l.period != period {
+			return nil, fmt.Errorf("the rate limiter %q is %s, not %g per %s", name, l, rate, period)
+		}
+		return l, nil
+	}
+	l := newRateLimiter(name, rate, period)
+	r.byName[name] = l
+	return l, nil
+}
+
+// NewRateLimiter is the JS constructor of the RateLimiter with the name, which
+// allows the rate of requests every period, for all the VUs together. It can
+// only be created in the init context.
+func (mi *ModuleInstance) NewRateLimiter(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	if mi.vu.State() != nil {
+		common.Throw(rt, errors.New("the rate limiters can only be created in the init context"))
+	}
+	name := call.Argument(0).String()
+	if goja.IsUndefined(call.Argument(0)) || name == "" {
+		common.Throw(rt, errors.New("the name of the rate limiter is required"))
+	}
+	rate := call.Argument(1)
+	if goja.IsUndefined(rate) || rate.ToFloat() <= 0 {
+		common.Throw(rt, fmt.Errorf("the rate of the rate limiter %q should be a positive number", name))
+	}
+	period := time.Second
+	if arg := call.Argument(2); !goja.IsUndefined(arg) {
+		var err error
+		if period, err = types.GetDurationValue(arg.Export()); err != nil {
+			common.Throw(rt, fmt.Errorf("invalid period of the rate limiter: %w", err))
+		}
+		if period <= 0 {
+			common.Throw(rt, errors.New("the period of the rate limiter should be more than 0"))
+		}
+	}
+	l, err := mi.rateLimiters.get(name, rate.ToFloat(), period)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(&RateLimiter{vu: mi.vu, limiter: l}).ToObject(rt)
+}
+
+// RateLimiter lets the VUs through at the rate of the limiter, e.g. to cap the
+// requests to a single endpoint.
+type RateLimiter struct {
+	vu      modules.VU
+	limiter *rateLimiter
+}
+
+// Wait waits until the rate limiter lets the VU through, or until the
+// iteration of the VU is interrupted. It's not supported in the init context.
+func (r *RateLimiter) Wait() error {
+	if r.vu.State() == nil {
+		return common.NewInitContextError("waiting for a rate limiter in the init context is not supported")
+	}
+	d := r.limiter.reserve(time.Now())
+	if d <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-r.vu.Context().Done():
+		r.limiter.cancel()
+		return fmt.Errorf("couldn't wait for the rate limiter: %w", r.vu.Context().Err())
+	}
+}
+
+// WaitAsync returns a promise which is resolved on the event loop when the
+// rate limiter lets the VU through, so it can do something else meanwhile.
+func (r *RateLimiter) WaitAsync() (*goja.Promise, error) {
+	if r.vu.State() == nil {
+		return nil, common.NewInitContextError("waiting for a rate limiter in the init context is not supported")
+	}
+	promise, resolve, _ := r.vu.Runtime().NewPromise()
+	runOnLoop := r.vu.RegisterCallback()
+	d := r.limiter.reserve(time.Now())
+	ctx := r.vu.Context()
+	go func() {
+		timer := time.NewTimer(d)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+			runOnLoop(func() error {
+				resolve(goja.Undefined())
+				return nil
+			})
+		case <-ctx.Done():
+			r.limiter.cancel()
+			runOnLoop(func() error { return nil })
+		}
+	}()
+	return promise, nil
+}
+
+// TryWait returns true if the rate limiter lets the VU through right away, or
+// false without waiting.
+func (r *RateLimiter) TryWait() bool {
+	return r.limiter.tryTake(time.Now())
+}
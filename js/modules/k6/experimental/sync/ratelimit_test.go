@@ -0,0 +1,144 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+)
+
+// loopVU is a VU with a minimal event loop, which runs the callbacks queued
+// by the module when runLoop is called.
+type loopVU struct {
+	*modulestest.VU
+	queue chan func() error
+}
+
+func (vu *loopVU) RegisterCallback() func(func() error) {
+	return func(f func() error) { vu.queue <- f }
+}
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+	rm := New()
+	rt1, vu1 := newTestVU(t, rm, context.Background())
+	rt2, vu2 := newTestVU(t, rm, context.Background())
+
+	_, err := rt1.RunString(`var limiter = new sync.RateLimiter("api", 2, "100ms");`)
+	require.NoError(t, err)
+	_, err = rt2.RunString(`var limiter = new sync.RateLimiter("api", 2, "100ms");`)
+	require.NoError(t, err)
+
+	_, err = rt1.RunString(`limiter.wait()`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+
+	vu1.StateField = &lib.State{}
+	vu2.StateField = &lib.State{}
+	v, err := rt1.RunString(`[limiter.tryWait(), limiter.tryWait()]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{true, true}, v.Export())
+	v, err = rt2.RunString(`limiter.tryWait()`)
+	require.NoError(t, err)
+	assert.False(t, v.ToBoolean(), "the other VU took the tokens of the limiter")
+
+	start := time.Now()
+	_, err = rt2.RunString(`limiter.wait()`)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond, "a token is refilled every 50ms")
+
+	_, err = rt2.RunString(`new sync.RateLimiter("api", 2, "1s")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "only be created in the init context")
+}
+
+func TestRateLimiterName(t *testing.T) {
+	t.Parallel()
+	rm := New()
+	rt1, vu1 := newTestVU(t, rm, context.Background())
+	rt2, vu2 := newTestVU(t, rm, context.Background())
+
+	_, err := rt1.RunString(`var a = new sync.RateLimiter("a", 1); var b = new sync.RateLimiter("b", 5, 1000);`)
+	require.NoError(t, err)
+	_, err = rt2.RunString(`var b = new sync.RateLimiter("b", 5, "1s"); var a = new sync.RateLimiter("a", 1, "1s");`)
+	require.NoError(t, err, "the limiters with the same name are the same, whatever order they're created in")
+	_, err = rt2.RunString(`new sync.RateLimiter("b", 10);`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `the rate limiter "b" is 5 per 1s, not 10 per 1s`)
+
+	vu1.StateField = &lib.State{}
+	vu2.StateField = &lib.State{}
+	v, err := rt1.RunString(`a.tryWait()`)
+	require.NoError(t, err)
+	assert.True(t, v.ToBoolean())
+	v, err = rt2.RunString(`[a.tryWait(), b.tryWait()]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{false, true}, v.Export(), "the other VU took the token of a")
+	vu1.StateField, vu2.StateField = nil, nil
+
+	for _, invalid := range []string{
+		`new sync.RateLimiter()`, `new sync.RateLimiter("")`, `new sync.RateLimiter("c")`, `new sync.RateLimiter("c", 0)`,
+		`new sync.RateLimiter("c", 1, "0s")`, `new sync.RateLimiter("c", 1, "x")`,
+	} {
+		_, err = rt1.RunString(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestRateLimiterWaitAsync(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rt, testVU := newTestVU(t, New(), ctx)
+	vu := &loopVU{VU: testVU, queue: make(chan func() error, 10)}
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("sync", m.Exports().Named))
+
+	_, err := rt.RunString(`var limiter = new sync.RateLimiter("api", 1, "50ms");`)
+	require.NoError(t, err)
+	testVU.StateField = &lib.State{}
+	_, err = rt.RunString(`
+		var resolved = 0;
+		limiter.waitAsync().then(() => { resolved++ });
+		limiter.waitAsync().then(() => { resolved++ });
+	`)
+	require.NoError(t, err)
+
+	for i := 1; i <= 2; i++ {
+		select {
+		case f := <-vu.queue:
+			require.NoError(t, f())
+		case <-time.After(5 * time.Second):
+			t.Fatal("timed out waiting for the rate limiter")
+		}
+		v, err := rt.RunString(`resolved`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(i), v.Export())
+	}
+}
@@ -0,0 +1,139 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package sync implements the k6/experimental/sync module, which can be used
+// to serialize the access of the VUs to a contended resource with the named
+// mutexes and semaphores, or to cap the rate of their requests with the named
+// rate limiters, which are shared by all the VUs of the test run.
+package sync
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct {
+		locks        *locks
+		rateLimiters *rateLimiters
+	}
+
+	// ModuleInstance represents an instance of the sync module for every VU.
+	ModuleInstance struct {
+		vu           modules.VU
+		locks        *locks
+		rateLimiters *rateLimiters
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{locks: &locks{
+		mutexes:    make(map[string]*semaphore),
+		semaphores: make(map[string]*semaphore),
+	}, rateLimiters: &rateLimiters{byName: make(map[string]*rateLimiter)}}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (rm *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{vu: vu, locks: rm.locks, rateLimiters: rm.rateLimiters}
+}
+
+// Exports returns the exports of the sync module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"Mutex":       mi.NewMutex,
+			"Semaphore":   mi.NewSemaphore,
+			"RateLimiter": mi.NewRateLimiter,
+		},
+	}
+}
+
+// locks are the mutexes and the semaphores of the test run by name, the
+// ones with the same name in all the VUs are the same.
+type locks struct {
+	mu         sync.Mutex
+	mutexes    map[string]*semaphore
+	semaphores map[string]*semaphore
+}
+
+// get returns the lock with the name, created with the permits if it
+// doesn't exist yet.
+func (l *locks) get(kind string, byName map[string]*semaphore, name string, permits int64) (*semaphore, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s, ok := byName[name]; ok {
+		if int64(cap(s.permits)) != permits {
+			return nil, fmt.Errorf("the %s %q has %d permits, not %d", kind, name, cap(s.permits), permits)
+		}
+		return s, nil
+	}
+	s := newSemaphore(kind, name, permits)
+	byName[name] = s
+	return s, nil
+}
+
+// NewMutex is the JS constructor of the Mutex with the name.
+func (mi *ModuleInstance) NewMutex(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	name := call.Argument(0).String()
+	if goja.IsUndefined(call.Argument(0)) || name == "" {
+		common.Throw(rt, errors.New("the name of the mutex is required"))
+	}
+	s, err := mi.locks.get("mutex", mi.locks.mutexes, name, 1)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(&Mutex{vu: mi.vu, sem: s}).ToObject(rt)
+}
+
+// NewSemaphore is the JS constructor of the Semaphore with the name and the
+// number of its permits.
+func (mi *ModuleInstance) NewSemaphore(call goja.ConstructorCall) *goja.Object {
+	rt := mi.vu.Runtime()
+	name := call.Argument(0).String()
+	if goja.IsUndefined(call.Argument(0)) || name == "" {
+		common.Throw(rt, errors.New("the name of the semaphore is required"))
+	}
+	permits := call.Argument(1)
+	if goja.IsUndefined(permits) || permits.ToInteger() <= 0 {
+		common.Throw(rt, fmt.Errorf("the permits of the semaphore %q should be a positive number", name))
+	}
+	s, err := mi.locks.get("semaphore", mi.locks.semaphores, name, permits.ToInteger())
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return rt.ToValue(&Semaphore{vu: mi.vu, sem: s}).ToObject(rt)
+}
@@ -0,0 +1,188 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package sync
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+)
+
+func newTestVU(t *testing.T, rm *RootModule, ctx context.Context) (*goja.Runtime, *modulestest.VU) {
+	t.Helper()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	vu := &modulestest.VU{
+		CtxField:     ctx,
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{},
+	}
+	m, ok := rm.NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("sync", m.Exports().Named))
+	return rt, vu
+}
+
+func TestMutex(t *testing.T) {
+	t.Parallel()
+	rm := New()
+	rt1, vu1 := newTestVU(t, rm, context.Background())
+	rt2, vu2 := newTestVU(t, rm, context.Background())
+
+	_, err := rt1.RunString(`var mutex = new sync.Mutex("token");`)
+	require.NoError(t, err)
+	_, err = rt2.RunString(`var mutex = new sync.Mutex("token");`)
+	require.NoError(t, err)
+
+	_, err = rt1.RunString(`mutex.withLock(function() {})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+
+	vu1.StateField = &lib.State{}
+	vu2.StateField = &lib.State{}
+	holding, release := make(chan struct{}), make(chan struct{})
+	require.NoError(t, rt1.Set("hold", func() {
+		close(holding)
+		<-release
+	}))
+	held := make(chan struct{})
+	go func() {
+		defer close(held)
+		_, lockErr := rt1.RunString(`mutex.withLock(hold)`)
+		assert.NoError(t, lockErr)
+	}()
+	<-holding
+
+	locked := make(chan struct{})
+	go func() {
+		defer close(locked)
+		v, lockErr := rt2.RunString(`mutex.withLock(function() { return "refreshed"; })`)
+		assert.NoError(t, lockErr)
+		assert.Equal(t, "refreshed", v.Export())
+	}()
+	select {
+	case <-locked:
+		require.Fail(t, "the mutex should have been waited for")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	<-held
+	select {
+	case <-locked:
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "the mutex should have been locked after it was unlocked")
+	}
+
+	_, err = rt1.RunString(`mutex.withLock(function() { throw new Error("failed refresh"); })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed refresh")
+	assertLocks(t, rt2, `mutex.withLock(function() {})`, "the mutex was unlocked after the holder threw")
+
+	_, err = rt1.RunString(`mutex.withLock("token")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a function is expected")
+}
+
+// assertLocks asserts that the code, which takes a lock, doesn't wait for it.
+func assertLocks(t *testing.T, rt *goja.Runtime, code, msg string) {
+	t.Helper()
+	done := make(chan error)
+	go func() {
+		_, err := rt.RunString(code)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		require.Fail(t, msg)
+	}
+}
+
+func TestMutexInterrupted(t *testing.T) {
+	t.Parallel()
+	rm := New()
+	rt1, vu1 := newTestVU(t, rm, context.Background())
+	ctx, cancel := context.WithCancel(context.Background())
+	rt2, vu2 := newTestVU(t, rm, ctx)
+	vu1.StateField = &lib.State{}
+	vu2.StateField = &lib.State{}
+
+	holding, release := make(chan struct{}), make(chan struct{})
+	require.NoError(t, rt1.Set("hold", func() {
+		close(holding)
+		<-release
+	}))
+	held := make(chan struct{})
+	go func() {
+		defer close(held)
+		_, _ = rt1.RunString(`new sync.Mutex("token").withLock(hold)`)
+	}()
+	<-holding
+	time.AfterFunc(10*time.Millisecond, cancel)
+	_, err := rt2.RunString(`new sync.Mutex("token").withLock(function() {})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `couldn't lock the mutex "token"`)
+	close(release)
+	<-held
+}
+
+func TestSemaphore(t *testing.T) {
+	t.Parallel()
+	rm := New()
+	rt, vu := newTestVU(t, rm, context.Background())
+	vu.StateField = &lib.State{}
+
+	v, err := rt.RunString(`
+		var sem = new sync.Semaphore("logins", 2);
+		var nested = sem.withPermit(function() {
+			return sem.withPermit(function() { return "both permits"; });
+		});
+		[nested, sem.permits()];
+	`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"both permits", int64(2)}, v.Export())
+
+	_, err = rt.RunString(`sem.withPermit(function() { throw new Error("failed login"); })`)
+	require.Error(t, err)
+	assertLocks(t, rt, `sem.withPermit(function() { sem.withPermit(function() {}); })`,
+		"the permit was given back after the holder threw")
+
+	_, err = rt.RunString(`new sync.Semaphore("logins", 3)`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `the semaphore "logins" has 2 permits, not 3`)
+
+	assertLocks(t, rt, `sem.withPermit(function() { new sync.Mutex("logins").withLock(function() {}); })`,
+		"the mutexes and the semaphores have their own names")
+
+	for _, invalid := range []string{`new sync.Semaphore("x")`, `new sync.Semaphore("x", 0)`, `new sync.Semaphore()`} {
+		_, err = rt.RunString(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -0,0 +1,464 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+//nolint:lll
+var (
+	errInvokeInInitContext  = common.NewInitContextError("invoking Thrift methods in the init context is not supported")
+	errConnectInInitContext = common.NewInitContextError("connecting to a Thrift server in the init context is not supported")
+)
+
+// Client represents a Thrift client that can be used to call the methods of
+// the services defined in the loaded IDL files.
+type Client struct {
+	services map[string]*idlService
+
+	vu        modules.VU
+	addr      string
+	protocol  protocolFactory
+	transport transport
+	// multiplexed prefixes the method names with the service name, as
+	// expected by servers using TMultiplexedProcessor.
+	multiplexed bool
+	seqID       int32
+}
+
+// MethodInfo holds information on the methods of the loaded services.
+type MethodInfo struct {
+	Service    string
+	Method     string
+	FullMethod string
+	Oneway     bool
+}
+
+// Response is a Thrift response that can be used by the goja VM. Exception is
+// set to an object with the `type`, the `field` and the `value` of a declared
+// exception thrown by the method, Error is set to an object with the `type`
+// and the `message` of an application exception.
+type Response struct {
+	Message   interface{}
+	Exception interface{}
+	Error     interface{}
+}
+
+// Load parses the given .thrift files, including the files they include, and
+// makes the services defined in them available for invocation.
+func (c *Client) Load(filenames ...string) ([]MethodInfo, error) {
+	if c.vu.State() != nil {
+		return nil, errors.New("load must be called in the init context")
+	}
+
+	initEnv := c.vu.InitEnv()
+	if initEnv == nil {
+		return nil, errors.New("missing init environment")
+	}
+	if c.services == nil {
+		// This allows us to call load() multiple times, without overwriting
+		// the previously loaded definitions.
+		c.services = make(map[string]*idlService)
+	}
+
+	files := make(map[string]*idlFile)
+	var load func(filename string) (*idlFile, error)
+	load = func(filename string) (*idlFile, error) {
+		if f, ok := files[filename]; ok {
+			return f, nil
+		}
+		r, err := initEnv.FileSystems["file"].Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r)
+		_ = r.Close()
+		if err != nil {
+			return nil, err
+		}
+		f, includes, err := parseIDL(filename, data)
+		if err != nil {
+			return nil, err
+		}
+		files[filename] = f
+		for _, inc := range includes {
+			incFile, err := load(filepath.Join(filepath.Dir(filename), inc))
+			if err != nil {
+				return nil, err
+			}
+			f.includes[includeName(inc)] = incFile
+		}
+		return f, nil
+	}
+
+	var loaded []*idlFile
+	for _, filename := range filenames {
+		f, err := load(initEnv.GetAbsFilePath(filename))
+		if err != nil {
+			return nil, err
+		}
+		loaded = append(loaded, f)
+	}
+	for _, f := range files {
+		if err := f.resolve(); err != nil {
+			return nil, err
+		}
+	}
+
+	var rtn []MethodInfo
+	for _, f := range loaded {
+		for _, name := range f.serviceNames {
+			s := f.services[name]
+			c.services[name] = s
+			for svc := s; svc != nil; svc = svc.base {
+				for _, fn := range svc.functions {
+					rtn = append(rtn, MethodInfo{
+						Service:    name,
+						Method:     fn.name,
+						FullMethod: name + "." + fn.name,
+						Oneway:     fn.oneway,
+					})
+				}
+			}
+		}
+	}
+	return rtn, nil
+}
+
+type connectParams struct {
+	Protocol    string
+	Transport   string
+	Multiplexed bool
+	Timeout     time.Duration
+}
+
+func (c *Client) parseConnectParams(raw map[string]interface{}) (connectParams, error) {
+	params := connectParams{
+		Protocol:  "binary",
+		Transport: "buffered",
+		Timeout:   time.Minute,
+	}
+	for k, v := range raw {
+		switch k {
+		case "protocol":
+			var ok bool
+			params.Protocol, ok = v.(string)
+			if _, known := protocols[params.Protocol]; !ok || !known {
+				return params, fmt.Errorf("invalid protocol value: '%#v', it needs to be 'binary' or 'compact'", v)
+			}
+		case "transport":
+			var ok bool
+			params.Transport, ok = v.(string)
+			if !ok || (params.Transport != "buffered" && params.Transport != "framed" && params.Transport != "http") {
+				return params, fmt.Errorf(
+					"invalid transport value: '%#v', it needs to be 'buffered', 'framed' or 'http'", v)
+			}
+		case "multiplexed":
+			var ok bool
+			params.Multiplexed, ok = v.(bool)
+			if !ok {
+				return params, fmt.Errorf("invalid multiplexed value: '%#v', it needs to be boolean", v)
+			}
+		case "timeout":
+			var err error
+			params.Timeout, err = types.GetDurationValue(v)
+			if err != nil {
+				return params, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		default:
+			return params, fmt.Errorf("unknown connect param: %q", k)
+		}
+	}
+	return params, nil
+}
+
+// Connect connects to the Thrift server at the given address. The address is
+// a host:port pair for the buffered and framed transports, and an URL for the
+// HTTP transport.
+func (c *Client) Connect(addr string, params map[string]interface{}) (bool, error) {
+	state := c.vu.State()
+	if state == nil {
+		return false, errConnectInInitContext
+	}
+
+	p, err := c.parseConnectParams(params)
+	if err != nil {
+		return false, err
+	}
+	if c.transport != nil {
+		_ = c.transport.close()
+		c.transport = nil
+	}
+
+	if p.Transport == "http" {
+		c.transport = &httpTransport{url: addr, client: &http.Client{Transport: state.Transport}}
+	} else {
+		ctx, cancel := context.WithTimeout(c.vu.Context(), p.Timeout)
+		defer cancel()
+		conn, err := state.Dialer.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return false, err
+		}
+		c.transport = &socketTransport{conn: conn, reader: bufio.NewReader(conn), framed: p.Transport == "framed"}
+	}
+
+	c.addr = addr
+	c.protocol = protocols[p.Protocol]
+	c.multiplexed = p.Multiplexed
+	return true, nil
+}
+
+type params struct {
+	Tags    map[string]string
+	Timeout time.Duration
+}
+
+func (c *Client) parseParams(raw map[string]interface{}) (params, error) {
+	p := params{
+		Timeout: time.Minute,
+	}
+	for k, v := range raw {
+		switch k {
+		case "tags":
+			p.Tags = make(map[string]string)
+
+			rawTags, ok := v.(map[string]interface{})
+			if !ok {
+				return p, errors.New("tags must be an object with key-value pairs")
+			}
+			for tk, tv := range rawTags {
+				strVal, ok := tv.(string)
+				if !ok {
+					return p, fmt.Errorf("tag %q value must be a string", tk)
+				}
+				p.Tags[tk] = strVal
+			}
+		case "timeout":
+			var err error
+			p.Timeout, err = types.GetDurationValue(v)
+			if err != nil {
+				return p, fmt.Errorf("invalid timeout value: %w", err)
+			}
+		default:
+			return p, fmt.Errorf("unknown param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// Invoke calls a method by its Service.method name with the given arguments,
+// an object with one property for every argument of the method.
+func (c *Client) Invoke(method string, args goja.Value, params map[string]interface{}) (*Response, error) {
+	state := c.vu.State()
+	if state == nil {
+		return nil, errInvokeInInitContext
+	}
+	if c.transport == nil {
+		return nil, errors.New("no Thrift connection, you must call connect first")
+	}
+
+	idx := strings.LastIndexByte(method, '.')
+	if idx == -1 {
+		return nil, fmt.Errorf("method %q must be in the Service.method format", method)
+	}
+	serviceName, methodName := method[:idx], method[idx+1:]
+	fn := c.services[serviceName].function(methodName)
+	if fn == nil {
+		return nil, fmt.Errorf("method %q not found in the loaded services", method)
+	}
+
+	p, err := c.parseParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	var argsObj map[string]interface{}
+	if args != nil && !goja.IsUndefined(args) && !goja.IsNull(args) {
+		var ok bool
+		if argsObj, ok = args.Export().(map[string]interface{}); !ok {
+			return nil, errors.New("the method arguments must be an object")
+		}
+	}
+
+	wireName := methodName
+	if c.multiplexed {
+		wireName = serviceName + ":" + methodName
+	}
+	msgType := messageCall
+	if fn.oneway {
+		msgType = messageOneway
+	}
+	c.seqID++
+	w := c.protocol.writer()
+	w.writeMessageBegin(wireName, msgType, c.seqID)
+	if err = encodeStruct(w, fn.args, argsObj); err != nil {
+		return nil, fmt.Errorf("unable to serialise the arguments: %w", err)
+	}
+
+	tags := state.CloneTags()
+	for k, v := range p.Tags {
+		tags[k] = v
+	}
+	if state.Options.SystemTags.Has(stats.TagURL) {
+		tags["url"] = c.addr + "/" + serviceName + "/" + methodName
+	}
+	if state.Options.SystemTags.Has(stats.TagService) {
+		tags["service"] = serviceName
+	}
+	if state.Options.SystemTags.Has(stats.TagMethod) {
+		tags["method"] = methodName
+	}
+	// Only set the name system tag if the user didn't explicitly set it beforehand
+	if _, ok := tags["name"]; !ok && state.Options.SystemTags.Has(stats.TagName) {
+		tags["name"] = method
+	}
+
+	startTime := time.Now()
+	response, err := c.roundTrip(w.bytes(), fn, p.Timeout)
+	endTime := time.Now()
+	if err != nil {
+		// the connection is in an unknown state after a failed call
+		_ = c.transport.close()
+		c.transport = nil
+		return nil, err
+	}
+
+	if state.Options.SystemTags.Has(stats.TagStatus) {
+		switch {
+		case response.Error != nil:
+			tags["status"] = "error"
+		case response.Exception != nil:
+			tags["status"] = "exception"
+		default:
+			tags["status"] = "ok"
+		}
+	}
+	stats.PushIfNotDone(c.vu.Context(), state.Samples, stats.Sample{
+		Metric: state.BuiltinMetrics.ThriftReqDuration,
+		Tags:   stats.IntoSampleTags(&tags),
+		Value:  stats.D(endTime.Sub(startTime)),
+		Time:   endTime,
+	})
+
+	return response, nil
+}
+
+func (c *Client) roundTrip(msg []byte, fn *idlFunction, timeout time.Duration) (*Response, error) {
+	reply, err := c.transport.roundTrip(c.vu.Context(), msg, fn.oneway, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if fn.oneway {
+		return &Response{}, nil
+	}
+
+	r := c.protocol.reader(reply)
+	_, msgType, seqID := r.readMessageBegin()
+	if err = r.err(); err != nil {
+		return nil, err
+	}
+	if seqID != c.seqID {
+		return nil, fmt.Errorf("out of order reply, expected sequence id %d but got %d", c.seqID, seqID)
+	}
+
+	response := &Response{}
+	switch msgType {
+	case messageException:
+		exc := decodeStruct(r, applicationException)
+		if typ, ok := exc["type"].(int64); ok {
+			exc["type"] = applicationExceptionType(typ)
+		}
+		response.Error = exc
+	case messageReply:
+		result := decodeResult(r, fn)
+		for _, f := range fn.throws.fields {
+			if v, ok := result[f.name]; ok {
+				response.Exception = map[string]interface{}{"type": f.typ.ref, "field": f.name, "value": v}
+				break
+			}
+		}
+		response.Message = result[successField]
+	default:
+		return nil, fmt.Errorf("unexpected message type %d in reply", msgType)
+	}
+	if err = r.err(); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// successField is the name of the result struct field with the return value.
+const successField = "success"
+
+func decodeResult(r protocolReader, fn *idlFunction) map[string]interface{} {
+	result := &idlStruct{name: fn.name + "_result", fields: fn.throws.fields}
+	if fn.returns != nil {
+		result.fields = append([]*idlField{{id: 0, name: successField, typ: fn.returns}}, result.fields...)
+	}
+	return decodeStruct(r, result)
+}
+
+//nolint:gochecknoglobals
+var applicationException = &idlStruct{
+	name: "TApplicationException",
+	fields: []*idlField{
+		{id: 1, name: "message", typ: &idlType{kind: typeString}},
+		{id: 2, name: "type", typ: &idlType{kind: typeI32}},
+	},
+}
+
+func applicationExceptionType(typ int64) string {
+	types := [...]string{
+		"UNKNOWN", "UNKNOWN_METHOD", "INVALID_MESSAGE_TYPE", "WRONG_METHOD_NAME", "BAD_SEQUENCE_ID",
+		"MISSING_RESULT", "INTERNAL_ERROR", "PROTOCOL_ERROR", "INVALID_TRANSFORM", "INVALID_PROTOCOL",
+		"UNSUPPORTED_CLIENT_TYPE",
+	}
+	if typ < 0 || typ >= int64(len(types)) {
+		return types[0]
+	}
+	return types[typ]
+}
+
+// Close closes the connection to the Thrift server.
+func (c *Client) Close() error {
+	if c.transport == nil {
+		return nil
+	}
+	err := c.transport.close()
+	c.transport = nil
+	return err
+}
@@ -0,0 +1,374 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// calculator implements the Calculator service from the tutorial IDL.
+type calculator struct {
+	service *idlService
+}
+
+func (c calculator) handle(name string, args map[string]interface{}) (messageType, *idlStruct, map[string]interface{}) {
+	fn := c.service.function(name)
+	if fn == nil {
+		return messageException, applicationException, map[string]interface{}{
+			"message": "unknown method " + name, "type": int64(1),
+		}
+	}
+	result := &idlStruct{fields: fn.throws.fields}
+	if fn.returns != nil {
+		result.fields = append([]*idlField{{id: 0, name: successField, typ: fn.returns}}, result.fields...)
+	}
+
+	num := func(v interface{}) int64 {
+		n, _ := v.(int64)
+		return n
+	}
+	switch name {
+	case "add":
+		return messageReply, result, map[string]interface{}{successField: num(args["num1"]) + num(args["num2"])}
+	case "calculate":
+		w := args["w"].(map[string]interface{})
+		if w["op"] == "DIVIDE" && w["num2"] == int64(0) {
+			return messageReply, result, map[string]interface{}{
+				"ouch": map[string]interface{}{"whatOp": int64(4), "why": "Cannot divide by 0"},
+			}
+		}
+		return messageReply, result, map[string]interface{}{successField: num(w["num1"]) / num(w["num2"])}
+	case "getStruct":
+		return messageReply, result, map[string]interface{}{
+			successField: map[string]interface{}{"key": args["key"], "value": "v"},
+		}
+	default:
+		return messageReply, result, map[string]interface{}{}
+	}
+}
+
+// serve handles a single call read from r, the reply is returned, or nil
+// for oneway calls.
+func (c calculator) serve(t *testing.T, protocol protocolFactory, r byteReader) []byte {
+	pr := protocol.reader(r)
+	name, msgType, seqID := pr.readMessageBegin()
+	require.NoError(t, pr.err())
+
+	var args map[string]interface{}
+	if fn := c.service.function(name); fn != nil {
+		args = decodeStruct(pr, fn.args)
+	} else {
+		skipValue(pr, typeStruct, 0)
+	}
+	require.NoError(t, pr.err())
+	if msgType == messageOneway {
+		return nil
+	}
+
+	replyType, result, value := c.handle(name, args)
+	w := protocol.writer()
+	w.writeMessageBegin(name, replyType, seqID)
+	require.NoError(t, encodeStruct(w, result, value))
+	return w.bytes()
+}
+
+func (c calculator) listen(t *testing.T, protocol protocolFactory, framed bool) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer func() { _ = conn.Close() }()
+				br := bufio.NewReader(conn)
+				for {
+					if _, err := br.Peek(1); err != nil {
+						return
+					}
+					var r byteReader = br
+					if framed {
+						var size [4]byte
+						if _, err := io.ReadFull(br, size[:]); err != nil {
+							return
+						}
+						frame := make([]byte, binary.BigEndian.Uint32(size[:]))
+						if _, err := io.ReadFull(br, frame); err != nil {
+							return
+						}
+						r = bytes.NewReader(frame)
+					}
+					reply := c.serve(t, protocol, r)
+					if reply == nil {
+						continue
+					}
+					if framed {
+						var size [4]byte
+						binary.BigEndian.PutUint32(size[:], uint32(len(reply)))
+						reply = append(size[:], reply...)
+					}
+					if _, err := conn.Write(reply); err != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+type testState struct {
+	rt      *goja.Runtime
+	vu      *modulestest.VU
+	state   *lib.State
+	samples chan stats.SampleContainer
+	calc    calculator
+}
+
+func newTestState(t *testing.T) testState {
+	t.Helper()
+
+	fs := afero.NewMemMapFs()
+	require.NoError(t, afero.WriteFile(fs, "/idl/shared.thrift", []byte(sharedIDL), 0o644))
+	require.NoError(t, afero.WriteFile(fs, "/idl/tutorial.thrift", []byte(tutorialIDL), 0o644))
+
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	samples := make(chan stats.SampleContainer, 1000)
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{
+			Logger:      logrus.New(),
+			CWD:         &url.URL{Path: "/idl"},
+			FileSystems: map[string]afero.Fs{"file": fs},
+		},
+	}
+	state := &lib.State{
+		Dialer:    &net.Dialer{},
+		Transport: http.DefaultTransport,
+		Samples:   samples,
+		Options: lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagName, stats.TagURL, stats.TagStatus, stats.TagMethod),
+		},
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		Tags:           lib.NewTagMap(nil),
+	}
+
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("thrift", m.Exports().Named))
+	_, err := rt.RunString(`
+		var client = new thrift.Client();
+		var methods = client.load("tutorial.thrift");
+	`)
+	require.NoError(t, err)
+
+	f := parseTestIDL(t)
+	return testState{
+		rt: rt, vu: vu, state: state, samples: samples,
+		calc: calculator{service: f.services["Calculator"]},
+	}
+}
+
+func TestClientLoad(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+
+	v, err := ts.rt.RunString(`methods.map(m => m.full_method).sort().join(",")`)
+	require.NoError(t, err)
+	assert.Equal(t,
+		"Calculator.add,Calculator.calculate,Calculator.getStruct,Calculator.ping,Calculator.zip", v.String())
+
+	_, err = ts.rt.RunString(`client.load("missing.thrift")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "file does not exist")
+
+	_, err = ts.rt.RunString(`client.connect("localhost:1")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connecting to a Thrift server in the init context is not supported")
+}
+
+func TestClientInvoke(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		protocol, transport string
+	}{
+		{"binary", "buffered"},
+		{"binary", "framed"},
+		{"compact", "framed"},
+		{"compact", "http"},
+	} {
+		tc := tc
+		t.Run(tc.protocol+"_"+tc.transport, func(t *testing.T) {
+			t.Parallel()
+			ts := newTestState(t)
+			protocol := protocols[tc.protocol]
+
+			var addr string
+			if tc.transport == "http" {
+				srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					body, err := ioutil.ReadAll(r.Body)
+					require.NoError(t, err)
+					_, _ = w.Write(ts.calc.serve(t, protocol, bytes.NewReader(body)))
+				}))
+				t.Cleanup(srv.Close)
+				addr = srv.URL
+			} else {
+				addr = ts.calc.listen(t, protocol, tc.transport == "framed")
+			}
+			ts.vu.StateField = ts.state
+			require.NoError(t, ts.rt.Set("addr", addr))
+			require.NoError(t, ts.rt.Set("params", map[string]interface{}{
+				"protocol": tc.protocol, "transport": tc.transport,
+			}))
+
+			_, err := ts.rt.RunString(`
+				client.connect(addr, params);
+
+				var res = client.invoke("Calculator.add", {num1: 1, num2: 2});
+				if (res.message !== 3) { throw new Error("unexpected add result: " + JSON.stringify(res)); }
+
+				res = client.invoke("Calculator.calculate", {logid: 1, w: {num1: 10, num2: 5, op: "DIVIDE"}});
+				if (res.message !== 2) { throw new Error("unexpected calculate result: " + JSON.stringify(res)); }
+
+				res = client.invoke("Calculator.calculate", {logid: 1, w: {num1: 10, num2: 0, op: "DIVIDE"}});
+				if (res.message !== null || res.exception.type !== "InvalidOperation" ||
+					res.exception.field !== "ouch" || res.exception.value.why !== "Cannot divide by 0") {
+					throw new Error("unexpected exception: " + JSON.stringify(res));
+				}
+
+				res = client.invoke("Calculator.getStruct", {key: 5}, {tags: {foo: "bar"}});
+				if (res.message.key !== 5 || res.message.value !== "v") {
+					throw new Error("unexpected getStruct result: " + JSON.stringify(res));
+				}
+
+				client.invoke("Calculator.ping");
+				client.invoke("Calculator.zip");
+				res = client.invoke("Calculator.add", {num1: -1, num2: 1});
+				if (res.message !== 0) { throw new Error("unexpected add result: " + JSON.stringify(res)); }
+				client.close();
+			`)
+			require.NoError(t, err)
+
+			bufSamples := stats.GetBufferedSamples(ts.samples)
+			require.Len(t, bufSamples, 7)
+			statuses := make([]string, 0, len(bufSamples))
+			for _, sc := range bufSamples {
+				sample := sc.GetSamples()[0]
+				assert.Equal(t, metrics.ThriftReqDurationName, sample.Metric.Name)
+				status, _ := sample.Tags.Get("status")
+				statuses = append(statuses, status)
+			}
+			assert.Equal(t, []string{"ok", "ok", "exception", "ok", "ok", "ok", "ok"}, statuses)
+			tags := bufSamples[3].GetSamples()[0].Tags.CloneTags()
+			assert.Equal(t, map[string]string{
+				"foo":    "bar",
+				"name":   "Calculator.getStruct",
+				"method": "getStruct",
+				"url":    addr + "/Calculator/getStruct",
+				"status": "ok",
+			}, tags)
+		})
+	}
+}
+
+func TestClientInvokeErrors(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+
+	_, err := ts.rt.RunString(`client.invoke("Calculator.add", {num1: 1, num2: 2})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "no Thrift connection, you must call connect first")
+
+	require.NoError(t, ts.rt.Set("addr", ts.calc.listen(t, protocols["binary"], false)))
+	tests := map[string]string{
+		`client.connect(addr, {protocol: "json"})`:                            "invalid protocol value",
+		`client.connect(addr, {transport: "zlib"})`:                           "invalid transport value",
+		`client.connect(addr, {foo: "bar"})`:                                  `unknown connect param: "foo"`,
+		`client.connect(addr); client.invoke("add")`:                          "must be in the Service.method format",
+		`client.connect(addr); client.invoke("Calculator.sub")`:               `method "Calculator.sub" not found`,
+		`client.connect(addr); client.invoke("Calculator.add", 1)`:            "the method arguments must be an object",
+		`client.connect(addr); client.invoke("Calculator.add", {})`:           "", // optional arguments
+		`client.connect(addr); client.invoke("Calculator.add", {num1: "1"})`:  "expected a number, got string",
+		`client.connect(addr); client.invoke("Calculator.add", {}, {foo: 1})`: `unknown param: "foo"`,
+	}
+	for code, expErr := range tests {
+		_, err := ts.rt.RunString(code)
+		if expErr == "" {
+			assert.NoError(t, err, code)
+			continue
+		}
+		require.Error(t, err, code)
+		assert.Contains(t, err.Error(), expErr, code)
+	}
+}
+
+func TestClientApplicationException(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	ts.vu.StateField = ts.state
+
+	// the server doesn't know about the method on the client side
+	ts.calc.service = &idlService{name: "Calculator", functions: map[string]*idlFunction{}}
+	require.NoError(t, ts.rt.Set("addr", ts.calc.listen(t, protocols["binary"], false)))
+	_, err := ts.rt.RunString(`
+		client.connect(addr);
+		var res = client.invoke("Calculator.add", {num1: 1, num2: 2});
+		if (res.error.type !== "UNKNOWN_METHOD" || res.error.message !== "unknown method add") {
+			throw new Error("unexpected response: " + JSON.stringify(res));
+		}
+	`)
+	require.NoError(t, err)
+
+	bufSamples := stats.GetBufferedSamples(ts.samples)
+	require.Len(t, bufSamples, 1)
+	status, _ := bufSamples[0].GetSamples()[0].Tags.Get("status")
+	assert.Equal(t, "error", status)
+}
@@ -0,0 +1,359 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/dop251/goja"
+)
+
+// maxNestingDepth limits the recursion while skipping unknown values.
+const maxNestingDepth = 64
+
+// encodeValue writes a value exported from the JS runtime as the given type.
+func encodeValue(w protocolWriter, t *idlType, v interface{}) error {
+	switch t.kind {
+	case typeBool:
+		b, ok := v.(bool)
+		if !ok {
+			return fmt.Errorf("expected a boolean, got %T", v)
+		}
+		w.writeBool(b)
+	case typeByte:
+		n, err := toInt(v, t, math.MinInt8, math.MaxInt8)
+		if err != nil {
+			return err
+		}
+		w.writeByte(int8(n))
+	case typeI16:
+		n, err := toInt(v, t, math.MinInt16, math.MaxInt16)
+		if err != nil {
+			return err
+		}
+		w.writeI16(int16(n))
+	case typeI32:
+		n, err := toInt(v, t, math.MinInt32, math.MaxInt32)
+		if err != nil {
+			return err
+		}
+		w.writeI32(int32(n))
+	case typeI64:
+		n, err := toInt(v, t, math.MinInt64, math.MaxInt64)
+		if err != nil {
+			return err
+		}
+		w.writeI64(n)
+	case typeDouble:
+		switch n := v.(type) {
+		case float64:
+			w.writeDouble(n)
+		case int64:
+			w.writeDouble(float64(n))
+		default:
+			return fmt.Errorf("expected a number, got %T", v)
+		}
+	case typeString:
+		b, err := toBytes(v)
+		if err != nil {
+			return err
+		}
+		w.writeBinary(b)
+	case typeStruct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("expected an object for %s, got %T", t.strct.name, v)
+		}
+		return encodeStruct(w, t.strct, obj)
+	case typeList, typeSet:
+		list, ok := v.([]interface{})
+		if !ok {
+			return fmt.Errorf("expected an array, got %T", v)
+		}
+		w.writeListBegin(t.elem.kind, len(list))
+		for i, elem := range list {
+			if err := encodeValue(w, t.elem, elem); err != nil {
+				return fmt.Errorf("[%d]: %w", i, err)
+			}
+		}
+	case typeMap:
+		return encodeMap(w, t, v)
+	default:
+		return fmt.Errorf("unsupported type %d", t.kind)
+	}
+	return nil
+}
+
+func toInt(v interface{}, t *idlType, min, max int64) (int64, error) {
+	var n int64
+	switch value := v.(type) {
+	case int64:
+		n = value
+	case float64:
+		if value != math.Trunc(value) {
+			return 0, fmt.Errorf("expected an integer, got %v", value)
+		}
+		n = int64(value)
+	case string:
+		if t.enum == nil {
+			return 0, fmt.Errorf("expected a number, got string")
+		}
+		enumValue, ok := t.enum.values[value]
+		if !ok {
+			return 0, fmt.Errorf("unknown %s value '%s'", t.enum.name, value)
+		}
+		n = int64(enumValue)
+	default:
+		return 0, fmt.Errorf("expected a number, got %T", v)
+	}
+	if n < min || n > max {
+		return 0, fmt.Errorf("value %d is out of range", n)
+	}
+	return n, nil
+}
+
+func toBytes(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	case goja.ArrayBuffer:
+		return value.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("expected a string or an ArrayBuffer, got %T", v)
+	}
+}
+
+func encodeStruct(w protocolWriter, s *idlStruct, obj map[string]interface{}) error {
+	for key := range obj {
+		if !s.hasField(key) {
+			return fmt.Errorf("unknown field '%s' in %s", key, s.name)
+		}
+	}
+
+	w.writeStructBegin()
+	for _, f := range s.fields {
+		v, ok := obj[f.name]
+		if !ok || v == nil {
+			if f.required {
+				return fmt.Errorf("required field '%s' of %s is missing", f.name, s.name)
+			}
+			continue
+		}
+		w.writeFieldBegin(f.typ.kind, f.id)
+		if err := encodeValue(w, f.typ, v); err != nil {
+			return fmt.Errorf("%s.%s: %w", s.name, f.name, err)
+		}
+	}
+	w.writeFieldStop()
+	w.writeStructEnd()
+	return nil
+}
+
+func (s *idlStruct) hasField(name string) bool {
+	for _, f := range s.fields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeMap writes a JS object as a map, the keys are converted from strings
+// to the key type of the map.
+func encodeMap(w protocolWriter, t *idlType, v interface{}) error {
+	obj, ok := v.(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("expected an object, got %T", v)
+	}
+	keys := make([]string, 0, len(obj))
+	for key := range obj {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	w.writeMapBegin(t.key.kind, t.elem.kind, len(keys))
+	for _, key := range keys {
+		k, err := parseMapKey(t.key, key)
+		if err != nil {
+			return err
+		}
+		if err = encodeValue(w, t.key, k); err != nil {
+			return fmt.Errorf("key '%s': %w", key, err)
+		}
+		if err = encodeValue(w, t.elem, obj[key]); err != nil {
+			return fmt.Errorf("[%s]: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func parseMapKey(t *idlType, key string) (interface{}, error) {
+	switch t.kind {
+	case typeString:
+		return key, nil
+	case typeBool:
+		return strconv.ParseBool(key)
+	case typeDouble:
+		return strconv.ParseFloat(key, 64)
+	case typeByte, typeI16, typeI32, typeI64:
+		if n, err := strconv.ParseInt(key, 10, 64); err == nil {
+			return n, nil
+		}
+		if t.enum != nil {
+			return key, nil
+		}
+		return nil, fmt.Errorf("invalid integer map key '%s'", key)
+	default:
+		return nil, fmt.Errorf("unsupported map key type %d", t.kind)
+	}
+}
+
+// decodeValue reads a value of the given type, in a form that can be handed
+// to the JS runtime.
+func decodeValue(r protocolReader, t *idlType) interface{} {
+	switch t.kind {
+	case typeBool:
+		return r.readBool()
+	case typeByte:
+		return int64(r.readByte())
+	case typeI16:
+		return int64(r.readI16())
+	case typeI32:
+		v := r.readI32()
+		if t.enum != nil {
+			if name, ok := t.enum.names[v]; ok {
+				return name
+			}
+		}
+		return int64(v)
+	case typeI64:
+		return r.readI64()
+	case typeDouble:
+		return r.readDouble()
+	case typeString:
+		b := r.readBinary()
+		if t.binary {
+			return b
+		}
+		return string(b)
+	case typeStruct:
+		return decodeStruct(r, t.strct)
+	case typeList, typeSet:
+		elemType, size := r.readListBegin()
+		list := make([]interface{}, 0, size)
+		for i := 0; i < size && r.err() == nil; i++ {
+			if elemType != t.elem.kind {
+				skipValue(r, elemType, 0)
+				continue
+			}
+			list = append(list, decodeValue(r, t.elem))
+		}
+		return list
+	case typeMap:
+		keyType, elemType, size := r.readMapBegin()
+		obj := make(map[string]interface{}, size)
+		for i := 0; i < size && r.err() == nil; i++ {
+			if keyType != t.key.kind || elemType != t.elem.kind {
+				skipValue(r, keyType, 0)
+				skipValue(r, elemType, 0)
+				continue
+			}
+			key := fmt.Sprint(decodeValue(r, t.key))
+			obj[key] = decodeValue(r, t.elem)
+		}
+		return obj
+	default:
+		r.fail(fmt.Errorf("unsupported type %d", t.kind))
+		return nil
+	}
+}
+
+// decodeStruct reads a struct, fields that are unknown or have an unexpected
+// type are skipped.
+func decodeStruct(r protocolReader, s *idlStruct) map[string]interface{} {
+	obj := make(map[string]interface{})
+	r.readStructBegin()
+	for r.err() == nil {
+		t, id := r.readFieldBegin()
+		if t == typeStop {
+			break
+		}
+		f := s.fieldByID(id)
+		if f == nil || f.typ.kind != t {
+			skipValue(r, t, 0)
+			continue
+		}
+		obj[f.name] = decodeValue(r, f.typ)
+	}
+	r.readStructEnd()
+	return obj
+}
+
+func skipValue(r protocolReader, t ttype, depth int) {
+	if depth > maxNestingDepth {
+		r.fail(fmt.Errorf("maximum nesting depth of %d exceeded", maxNestingDepth))
+		return
+	}
+	switch t {
+	case typeBool:
+		r.readBool()
+	case typeByte:
+		r.readByte()
+	case typeI16:
+		r.readI16()
+	case typeI32:
+		r.readI32()
+	case typeI64:
+		r.readI64()
+	case typeDouble:
+		r.readDouble()
+	case typeString:
+		r.readBinary()
+	case typeStruct:
+		r.readStructBegin()
+		for r.err() == nil {
+			ft, _ := r.readFieldBegin()
+			if ft == typeStop {
+				break
+			}
+			skipValue(r, ft, depth+1)
+		}
+		r.readStructEnd()
+	case typeList, typeSet:
+		elemType, size := r.readListBegin()
+		for i := 0; i < size && r.err() == nil; i++ {
+			skipValue(r, elemType, depth+1)
+		}
+	case typeMap:
+		keyType, elemType, size := r.readMapBegin()
+		for i := 0; i < size && r.err() == nil; i++ {
+			skipValue(r, keyType, depth+1)
+			skipValue(r, elemType, depth+1)
+		}
+	default:
+		r.fail(fmt.Errorf("unknown type %d", t))
+	}
+}
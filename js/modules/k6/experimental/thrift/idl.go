@@ -0,0 +1,686 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ttype is a Thrift wire type, as used by the binary protocol.
+type ttype byte
+
+const (
+	typeStop   ttype = 0
+	typeVoid   ttype = 1
+	typeBool   ttype = 2
+	typeByte   ttype = 3
+	typeDouble ttype = 4
+	typeI16    ttype = 6
+	typeI32    ttype = 8
+	typeI64    ttype = 10
+	typeString ttype = 11
+	typeStruct ttype = 12
+	typeMap    ttype = 13
+	typeSet    ttype = 14
+	typeList   ttype = 15
+)
+
+// idlType is a (possibly not yet resolved) type from an IDL file.
+type idlType struct {
+	kind   ttype
+	binary bool
+
+	// set for container types
+	key, elem *idlType
+
+	// set for references to typedefs, enums and structs
+	ref    string
+	file   *idlFile
+	strct  *idlStruct
+	enum   *idlEnum
+	target *idlType
+}
+
+type idlField struct {
+	id       int16
+	name     string
+	typ      *idlType
+	required bool
+}
+
+type idlStruct struct {
+	name   string
+	kind   string // struct, union or exception
+	fields []*idlField
+}
+
+func (s *idlStruct) fieldByID(id int16) *idlField {
+	for _, f := range s.fields {
+		if f.id == id {
+			return f
+		}
+	}
+	return nil
+}
+
+type idlEnum struct {
+	name   string
+	values map[string]int32
+	names  map[int32]string
+}
+
+type idlFunction struct {
+	name    string
+	oneway  bool
+	returns *idlType // nil for void
+	args    *idlStruct
+	throws  *idlStruct
+}
+
+type idlService struct {
+	name      string
+	extends   string
+	functions map[string]*idlFunction
+	base      *idlService
+}
+
+func (s *idlService) function(name string) *idlFunction {
+	for ; s != nil; s = s.base {
+		if fn, ok := s.functions[name]; ok {
+			return fn
+		}
+	}
+	return nil
+}
+
+// idlFile holds the definitions of a single parsed .thrift file.
+type idlFile struct {
+	name     string
+	includes map[string]*idlFile
+	typedefs map[string]*idlType
+	enums    map[string]*idlEnum
+	structs  map[string]*idlStruct
+	services map[string]*idlService
+
+	// the order in which the services were defined
+	serviceNames []string
+	// all types that reference other definitions, resolved after parsing
+	refs []*idlType
+}
+
+func newIDLFile(name string) *idlFile {
+	return &idlFile{
+		name:     name,
+		includes: make(map[string]*idlFile),
+		typedefs: make(map[string]*idlType),
+		enums:    make(map[string]*idlEnum),
+		structs:  make(map[string]*idlStruct),
+		services: make(map[string]*idlService),
+	}
+}
+
+// includeName returns the name under which the definitions of an included file
+// are available, i.e. "shared" for "shared.thrift".
+func includeName(filename string) string {
+	return strings.TrimSuffix(path.Base(filename), ".thrift")
+}
+
+// lookup finds the definitions file and the local name of a possibly qualified
+// reference, e.g. "shared.SharedStruct".
+func (f *idlFile) lookup(ref string) (*idlFile, string) {
+	if idx := strings.LastIndexByte(ref, '.'); idx != -1 {
+		if inc, ok := f.includes[ref[:idx]]; ok {
+			return inc, ref[idx+1:]
+		}
+	}
+	return f, ref
+}
+
+func (f *idlFile) resolveService(s *idlService) error {
+	if s.extends == "" || s.base != nil {
+		return nil
+	}
+	file, name := f.lookup(s.extends)
+	base, ok := file.services[name]
+	if !ok {
+		return fmt.Errorf("service %s extends unknown service %s", s.name, s.extends)
+	}
+	s.base = base
+	return file.resolveService(base)
+}
+
+func resolveType(t *idlType, seen map[*idlType]bool) error {
+	if t.ref == "" || t.kind != 0 {
+		return nil
+	}
+	if seen[t] {
+		return fmt.Errorf("recursive typedef %s", t.ref)
+	}
+	seen[t] = true
+
+	file, name := t.file.lookup(t.ref)
+	if s, ok := file.structs[name]; ok {
+		t.kind, t.strct = typeStruct, s
+		return nil
+	}
+	if e, ok := file.enums[name]; ok {
+		t.kind, t.enum = typeI32, e
+		return nil
+	}
+	target, ok := file.typedefs[name]
+	if !ok {
+		return fmt.Errorf("%s: unknown type %s", t.file.name, t.ref)
+	}
+	if err := resolveType(target, seen); err != nil {
+		return err
+	}
+	t.kind, t.binary, t.key, t.elem = target.kind, target.binary, target.key, target.elem
+	t.strct, t.enum, t.target = target.strct, target.enum, target
+	return nil
+}
+
+func (f *idlFile) resolve() error {
+	for _, t := range f.refs {
+		if err := resolveType(t, make(map[*idlType]bool)); err != nil {
+			return err
+		}
+	}
+	for _, s := range f.services {
+		if err := f.resolveService(s); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// idlParser is a small recursive descent parser for the Thrift IDL. It only
+// keeps the parts that are needed to encode and decode calls, constants and
+// annotations are parsed but otherwise ignored.
+type idlParser struct {
+	file   *idlFile
+	tokens []string
+	pos    int
+	// the included files, in the order they were encountered
+	includes []string
+}
+
+func parseIDL(name string, data []byte) (*idlFile, []string, error) {
+	tokens, err := tokenizeIDL(string(data))
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	p := &idlParser{file: newIDLFile(name), tokens: tokens}
+	if err := p.parse(); err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return p.file, p.includes, nil
+}
+
+func tokenizeIDL(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case unicode.IsSpace(rune(c)):
+			i++
+		case c == '#' || strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			end := strings.IndexByte(src[i+1:], c)
+			if end == -1 {
+				return nil, fmt.Errorf("unterminated string literal")
+			}
+			tokens = append(tokens, src[i:i+end+2])
+			i += end + 2
+		case strings.ContainsRune("{}()<>[],;:=", rune(c)):
+			tokens = append(tokens, string(c))
+			i++
+		default:
+			start := i
+			for i < len(src) && !isTokenEnd(src[i:]) {
+				i++
+			}
+			tokens = append(tokens, src[start:i])
+		}
+	}
+	return tokens, nil
+}
+
+// isTokenEnd checks whether an identifier or a literal ends at the start of src.
+func isTokenEnd(src string) bool {
+	return unicode.IsSpace(rune(src[0])) || strings.ContainsRune("{}()<>[],;:=\"'#", rune(src[0])) ||
+		strings.HasPrefix(src, "//") || strings.HasPrefix(src, "/*")
+}
+
+func (p *idlParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *idlParser) next() string {
+	tok := p.peek()
+	if tok != "" {
+		p.pos++
+	}
+	return tok
+}
+
+func (p *idlParser) accept(tok string) bool {
+	if p.peek() == tok {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *idlParser) expect(tok string) error {
+	if got := p.next(); got != tok {
+		return p.unexpected(got, "'"+tok+"'")
+	}
+	return nil
+}
+
+func (p *idlParser) unexpected(got, expected string) error {
+	if got == "" {
+		return fmt.Errorf("unexpected end of file, expected %s", expected)
+	}
+	return fmt.Errorf("unexpected '%s', expected %s", got, expected)
+}
+
+func (p *idlParser) identifier() (string, error) {
+	tok := p.next()
+	if tok == "" || !(unicode.IsLetter(rune(tok[0])) || tok[0] == '_') {
+		return "", p.unexpected(tok, "an identifier")
+	}
+	return tok, nil
+}
+
+func (p *idlParser) skipListSeparator() {
+	if !p.accept(",") {
+		p.accept(";")
+	}
+}
+
+// skipAnnotations skips the optional `(key = "value", ...)` annotations.
+func (p *idlParser) skipAnnotations() error {
+	if p.peek() != "(" {
+		return nil
+	}
+	return p.skipBalanced("(", ")")
+}
+
+func (p *idlParser) skipBalanced(open, closing string) error {
+	depth := 0
+	for {
+		switch tok := p.next(); tok {
+		case "":
+			return p.unexpected(tok, "'"+closing+"'")
+		case open:
+			depth++
+		case closing:
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func (p *idlParser) parse() error {
+	for p.peek() != "" {
+		var err error
+		switch tok := p.next(); tok {
+		case "include":
+			inc := p.next()
+			if len(inc) < 2 || (inc[0] != '"' && inc[0] != '\'') {
+				return p.unexpected(inc, "a file name")
+			}
+			p.includes = append(p.includes, inc[1:len(inc)-1])
+		case "cpp_include":
+			p.next()
+		case "namespace":
+			p.next()
+			p.next()
+		case "typedef":
+			err = p.parseTypedef()
+		case "const":
+			err = p.parseConst()
+		case "enum":
+			err = p.parseEnum()
+		case "senum":
+			var name string
+			if name, err = p.identifier(); err == nil {
+				p.file.typedefs[name] = &idlType{kind: typeString}
+				err = p.skipBalanced("{", "}")
+			}
+		case "struct", "union", "exception":
+			var s *idlStruct
+			if s, err = p.parseStruct(tok); err == nil {
+				p.file.structs[s.name] = s
+			}
+		case "service":
+			err = p.parseService()
+		default:
+			return p.unexpected(tok, "a definition")
+		}
+		if err != nil {
+			return err
+		}
+		if err = p.skipAnnotations(); err != nil {
+			return err
+		}
+		p.skipListSeparator()
+	}
+	return nil
+}
+
+func (p *idlParser) parseType() (*idlType, error) {
+	tok := p.next()
+	var t *idlType
+	switch tok {
+	case "bool":
+		t = &idlType{kind: typeBool}
+	case "byte", "i8":
+		t = &idlType{kind: typeByte}
+	case "i16":
+		t = &idlType{kind: typeI16}
+	case "i32":
+		t = &idlType{kind: typeI32}
+	case "i64":
+		t = &idlType{kind: typeI64}
+	case "double":
+		t = &idlType{kind: typeDouble}
+	case "string", "slist":
+		t = &idlType{kind: typeString}
+	case "binary":
+		t = &idlType{kind: typeString, binary: true}
+	case "list", "set":
+		t = &idlType{kind: typeList}
+		if tok == "set" {
+			t.kind = typeSet
+		}
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(">"); err != nil {
+			return nil, err
+		}
+		t.elem = elem
+	case "map":
+		t = &idlType{kind: typeMap}
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		key, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(","); err != nil {
+			return nil, err
+		}
+		elem, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(">"); err != nil {
+			return nil, err
+		}
+		t.key, t.elem = key, elem
+	case "":
+		return nil, p.unexpected(tok, "a type")
+	default:
+		p.pos--
+		name, err := p.identifier()
+		if err != nil {
+			return nil, err
+		}
+		t = &idlType{ref: name, file: p.file}
+		p.file.refs = append(p.file.refs, t)
+	}
+	if p.accept("cpp_type") {
+		p.next()
+	}
+	return t, p.skipAnnotations()
+}
+
+func (p *idlParser) parseTypedef() error {
+	t, err := p.parseType()
+	if err != nil {
+		return err
+	}
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	p.file.typedefs[name] = t
+	return nil
+}
+
+func (p *idlParser) parseConst() error {
+	if _, err := p.parseType(); err != nil {
+		return err
+	}
+	if _, err := p.identifier(); err != nil {
+		return err
+	}
+	if err := p.expect("="); err != nil {
+		return err
+	}
+	return p.skipConstValue()
+}
+
+func (p *idlParser) skipConstValue() error {
+	switch p.peek() {
+	case "[":
+		return p.skipBalanced("[", "]")
+	case "{":
+		return p.skipBalanced("{", "}")
+	case "":
+		return p.unexpected("", "a constant value")
+	default:
+		p.next()
+		return nil
+	}
+}
+
+func (p *idlParser) parseEnum() error {
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	e := &idlEnum{name: name, values: make(map[string]int32), names: make(map[int32]string)}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	var next int32
+	for !p.accept("}") {
+		valueName, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		if p.accept("=") {
+			tok := p.next()
+			v, err := strconv.ParseInt(tok, 0, 32)
+			if err != nil {
+				return p.unexpected(tok, "an integer enum value")
+			}
+			next = int32(v)
+		}
+		e.values[valueName] = next
+		if _, ok := e.names[next]; !ok {
+			e.names[next] = valueName
+		}
+		next++
+		if err = p.skipAnnotations(); err != nil {
+			return err
+		}
+		p.skipListSeparator()
+	}
+	p.file.enums[name] = e
+	return nil
+}
+
+// parseFields parses a list of fields until the given closing token.
+func (p *idlParser) parseFields(closing string) ([]*idlField, error) {
+	var fields []*idlField
+	var implicitID int16
+	for !p.accept(closing) {
+		f := &idlField{}
+		if tok := p.peek(); tok != "" && (tok[0] == '-' || unicode.IsDigit(rune(tok[0]))) {
+			id, err := strconv.ParseInt(p.next(), 0, 16)
+			if err != nil {
+				return nil, p.unexpected(tok, "a field id")
+			}
+			if err = p.expect(":"); err != nil {
+				return nil, err
+			}
+			f.id = int16(id)
+		} else {
+			// fields without an explicit id get negative ids, like the
+			// official compiler does
+			implicitID--
+			f.id = implicitID
+		}
+		switch p.peek() {
+		case "required":
+			p.next()
+			f.required = true
+		case "optional":
+			p.next()
+		}
+		t, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		if f.name, err = p.identifier(); err != nil {
+			return nil, err
+		}
+		f.typ = t
+		if p.accept("=") {
+			if err = p.skipConstValue(); err != nil {
+				return nil, err
+			}
+		}
+		if err = p.skipAnnotations(); err != nil {
+			return nil, err
+		}
+		p.skipListSeparator()
+		fields = append(fields, f)
+	}
+	return fields, nil
+}
+
+func (p *idlParser) parseStruct(kind string) (*idlStruct, error) {
+	name, err := p.identifier()
+	if err != nil {
+		return nil, err
+	}
+	p.accept("xsd_all")
+	if err = p.expect("{"); err != nil {
+		return nil, err
+	}
+	fields, err := p.parseFields("}")
+	if err != nil {
+		return nil, err
+	}
+	return &idlStruct{name: name, kind: kind, fields: fields}, nil
+}
+
+func (p *idlParser) parseService() error {
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	s := &idlService{name: name, functions: make(map[string]*idlFunction)}
+	if p.accept("extends") {
+		if s.extends, err = p.identifier(); err != nil {
+			return err
+		}
+	}
+	if err = p.expect("{"); err != nil {
+		return err
+	}
+	for !p.accept("}") {
+		fn, err := p.parseFunction()
+		if err != nil {
+			return err
+		}
+		s.functions[fn.name] = fn
+	}
+	p.file.services[name] = s
+	p.file.serviceNames = append(p.file.serviceNames, name)
+	return nil
+}
+
+func (p *idlParser) parseFunction() (*idlFunction, error) {
+	fn := &idlFunction{oneway: p.accept("oneway")}
+	if !p.accept("void") {
+		t, err := p.parseType()
+		if err != nil {
+			return nil, err
+		}
+		fn.returns = t
+	}
+	var err error
+	if fn.name, err = p.identifier(); err != nil {
+		return nil, err
+	}
+	if err = p.expect("("); err != nil {
+		return nil, err
+	}
+	args, err := p.parseFields(")")
+	if err != nil {
+		return nil, err
+	}
+	fn.args = &idlStruct{name: fn.name + "_args", kind: "struct", fields: args}
+	fn.throws = &idlStruct{name: fn.name + "_result", kind: "struct"}
+	if p.accept("throws") {
+		if err = p.expect("("); err != nil {
+			return nil, err
+		}
+		if fn.throws.fields, err = p.parseFields(")"); err != nil {
+			return nil, err
+		}
+	}
+	if err = p.skipAnnotations(); err != nil {
+		return nil, err
+	}
+	p.skipListSeparator()
+	return fn, nil
+}
@@ -0,0 +1,171 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sharedIDL = `
+namespace go shared
+
+struct SharedStruct {
+  1: i32 key
+  2: string value
+}
+
+service SharedService {
+  SharedStruct getStruct(1: i32 key)
+}
+`
+
+const tutorialIDL = `
+/* The tutorial service, slightly modified
+ * from the one in the Thrift repository. */
+include "shared.thrift"
+
+namespace go tutorial
+namespace java tutorial
+
+typedef i32 MyInteger
+typedef list<MyInteger> Numbers
+
+const i32 INT32CONSTANT = 9853
+const map<string,string> MAPCONSTANT = {'hello':'world', 'goodnight':'moon'}
+
+enum Operation {
+  ADD = 1,
+  SUBTRACT = 2,
+  MULTIPLY,
+  DIVIDE = 0x4
+}
+
+struct Work {
+  1: i32 num1 = 0,
+  2: required i32 num2,
+  3: Operation op,
+  4: optional string comment, // a trailing comment
+  5: optional map<string, Numbers> extra (annotated = "yes")
+  6: binary payload
+}
+
+exception InvalidOperation {
+  1: i32 whatOp,
+  2: string why
+}
+
+service Calculator extends shared.SharedService {
+   void ping(),
+   i32 add(1:i32 num1, 2:i32 num2),
+   i32 calculate(1:i32 logid, 2:Work w) throws (1:InvalidOperation ouch),
+   oneway void zip()
+} (service.annotation = "1")
+`
+
+func TestParseIDL(t *testing.T) {
+	t.Parallel()
+
+	shared, includes, err := parseIDL("shared.thrift", []byte(sharedIDL))
+	require.NoError(t, err)
+	assert.Empty(t, includes)
+
+	f, includes, err := parseIDL("tutorial.thrift", []byte(tutorialIDL))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"shared.thrift"}, includes)
+	f.includes[includeName(includes[0])] = shared
+	require.NoError(t, shared.resolve())
+	require.NoError(t, f.resolve())
+
+	op := f.enums["Operation"]
+	require.NotNil(t, op)
+	assert.Equal(t, map[string]int32{"ADD": 1, "SUBTRACT": 2, "MULTIPLY": 3, "DIVIDE": 4}, op.values)
+
+	work := f.structs["Work"]
+	require.NotNil(t, work)
+	require.Len(t, work.fields, 6)
+	assert.True(t, work.fields[1].required)
+	assert.Equal(t, typeI32, work.fields[2].typ.kind)
+	assert.Equal(t, op, work.fields[2].typ.enum)
+	extra := work.fields[4].typ
+	assert.Equal(t, typeMap, extra.kind)
+	assert.Equal(t, typeString, extra.key.kind)
+	assert.Equal(t, typeList, extra.elem.kind)
+	assert.Equal(t, typeI32, extra.elem.elem.kind)
+	assert.True(t, work.fields[5].typ.binary)
+
+	calc := f.services["Calculator"]
+	require.NotNil(t, calc)
+	assert.Equal(t, shared.services["SharedService"], calc.base)
+	assert.True(t, calc.function("zip").oneway)
+	assert.Nil(t, calc.function("ping").returns)
+	calculate := calc.function("calculate")
+	require.NotNil(t, calculate)
+	require.Len(t, calculate.args.fields, 2)
+	assert.Equal(t, work, calculate.args.fields[1].typ.strct)
+	require.Len(t, calculate.throws.fields, 1)
+	assert.Equal(t, "ouch", calculate.throws.fields[0].name)
+
+	getStruct := calc.function("getStruct")
+	require.NotNil(t, getStruct)
+	assert.Equal(t, shared.structs["SharedStruct"], getStruct.returns.strct)
+	assert.Nil(t, calc.function("missing"))
+}
+
+func TestParseIDLErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"struct Foo { 1: i32 }":                       "unexpected '}', expected an identifier",
+		"struct Foo { 1: i32 bar":                     "unexpected end of file",
+		"enum Foo { A = B }":                          "expected an integer enum value",
+		"foo Bar":                                     "unexpected 'foo', expected a definition",
+		"/* unterminated":                             "unterminated comment",
+		"include \"unterminated":                      "unterminated string literal",
+		"service Foo { void bar(1: map<i32> baz) }":   "unexpected '>', expected ','",
+		"typedef list<i32 Foo":                        "unexpected 'Foo', expected '>'",
+		"struct Foo { 1: list<i32> bar } typedef Foo": "unexpected end of file, expected an identifier",
+	}
+	for idl, expErr := range tests {
+		_, _, err := parseIDL("test.thrift", []byte(idl))
+		require.Error(t, err, idl)
+		assert.Contains(t, err.Error(), expErr, idl)
+	}
+}
+
+func TestResolveIDLErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := map[string]string{
+		"struct Foo { 1: Bar bar }":              "unknown type Bar",
+		"typedef Foo Bar typedef Bar Foo":        "recursive typedef",
+		"service Foo extends Bar { void baz() }": "extends unknown service Bar",
+	}
+	for idl, expErr := range tests {
+		f, _, err := parseIDL("test.thrift", []byte(idl))
+		require.NoError(t, err, idl)
+		err = f.resolve()
+		require.Error(t, err, idl)
+		assert.Contains(t, err.Error(), expErr, idl)
+	}
+}
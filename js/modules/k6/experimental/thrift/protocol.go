@@ -0,0 +1,583 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+type messageType byte
+
+const (
+	messageCall      messageType = 1
+	messageReply     messageType = 2
+	messageException messageType = 3
+	messageOneway    messageType = 4
+)
+
+// maxContainerSize limits the sizes read from the wire, so that a corrupted or
+// malicious response can't make us allocate huge amounts of memory.
+const maxContainerSize = 64 << 20
+
+var errContainerTooBig = errors.New("container or string size exceeds the limit")
+
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+// protocolWriter serializes values to a buffer using a specific protocol.
+type protocolWriter interface {
+	writeMessageBegin(name string, typ messageType, seqID int32)
+	writeStructBegin()
+	writeStructEnd()
+	writeFieldBegin(t ttype, id int16)
+	writeFieldStop()
+	writeMapBegin(key, elem ttype, size int)
+	writeListBegin(elem ttype, size int)
+	writeBool(v bool)
+	writeByte(v int8)
+	writeI16(v int16)
+	writeI32(v int32)
+	writeI64(v int64)
+	writeDouble(v float64)
+	writeBinary(v []byte)
+	bytes() []byte
+}
+
+// protocolReader deserializes values using a specific protocol. The first
+// error is kept and all reads after it return zero values.
+type protocolReader interface {
+	readMessageBegin() (name string, typ messageType, seqID int32)
+	readStructBegin()
+	readStructEnd()
+	readFieldBegin() (t ttype, id int16)
+	readMapBegin() (key, elem ttype, size int)
+	readListBegin() (elem ttype, size int)
+	readBool() bool
+	readByte() int8
+	readI16() int16
+	readI32() int32
+	readI64() int64
+	readDouble() float64
+	readBinary() []byte
+	err() error
+	fail(err error)
+}
+
+type protocolFactory struct {
+	writer func() protocolWriter
+	reader func(byteReader) protocolReader
+}
+
+//nolint:gochecknoglobals
+var protocols = map[string]protocolFactory{
+	"binary": {
+		writer: func() protocolWriter { return &binaryWriter{} },
+		reader: func(r byteReader) protocolReader { return &binaryReader{errReader{r: r}} },
+	},
+	"compact": {
+		writer: func() protocolWriter { return &compactWriter{} },
+		reader: func(r byteReader) protocolReader { return &compactReader{errReader: errReader{r: r}} },
+	},
+}
+
+// errReader keeps track of the first read error.
+type errReader struct {
+	r       byteReader
+	lastErr error
+}
+
+func (er *errReader) err() error {
+	return er.lastErr
+}
+
+func (er *errReader) fail(err error) {
+	if er.lastErr == nil {
+		er.lastErr = err
+	}
+}
+
+func (er *errReader) read(n int) []byte {
+	if er.lastErr != nil {
+		return make([]byte, n)
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(er.r, buf); err != nil {
+		er.fail(err)
+	}
+	return buf
+}
+
+func (er *errReader) readByte() byte {
+	if er.lastErr != nil {
+		return 0
+	}
+	b, err := er.r.ReadByte()
+	if err != nil {
+		er.fail(err)
+	}
+	return b
+}
+
+func (er *errReader) checkSize(size int64) int {
+	if size < 0 || size > maxContainerSize {
+		er.fail(errContainerTooBig)
+		return 0
+	}
+	return int(size)
+}
+
+// binaryWriter implements the strict binary protocol.
+type binaryWriter struct {
+	buf bytes.Buffer
+}
+
+var _ protocolWriter = &binaryWriter{}
+
+const binaryVersion1 = 0x80010000
+
+func (w *binaryWriter) writeMessageBegin(name string, typ messageType, seqID int32) {
+	w.writeI32(int32(uint32(binaryVersion1) | uint32(typ)))
+	w.writeBinary([]byte(name))
+	w.writeI32(seqID)
+}
+
+func (w *binaryWriter) writeStructBegin() {}
+func (w *binaryWriter) writeStructEnd()   {}
+
+func (w *binaryWriter) writeFieldBegin(t ttype, id int16) {
+	w.buf.WriteByte(byte(t))
+	w.writeI16(id)
+}
+
+func (w *binaryWriter) writeFieldStop() {
+	w.buf.WriteByte(byte(typeStop))
+}
+
+func (w *binaryWriter) writeMapBegin(key, elem ttype, size int) {
+	w.buf.WriteByte(byte(key))
+	w.buf.WriteByte(byte(elem))
+	w.writeI32(int32(size))
+}
+
+func (w *binaryWriter) writeListBegin(elem ttype, size int) {
+	w.buf.WriteByte(byte(elem))
+	w.writeI32(int32(size))
+}
+
+func (w *binaryWriter) writeBool(v bool) {
+	if v {
+		w.buf.WriteByte(1)
+	} else {
+		w.buf.WriteByte(0)
+	}
+}
+
+func (w *binaryWriter) writeByte(v int8) {
+	w.buf.WriteByte(byte(v))
+}
+
+func (w *binaryWriter) writeI16(v int16) {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], uint16(v))
+	w.buf.Write(b[:])
+}
+
+func (w *binaryWriter) writeI32(v int32) {
+	var b [4]byte
+	binary.BigEndian.PutUint32(b[:], uint32(v))
+	w.buf.Write(b[:])
+}
+
+func (w *binaryWriter) writeI64(v int64) {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], uint64(v))
+	w.buf.Write(b[:])
+}
+
+func (w *binaryWriter) writeDouble(v float64) {
+	w.writeI64(int64(math.Float64bits(v)))
+}
+
+func (w *binaryWriter) writeBinary(v []byte) {
+	w.writeI32(int32(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *binaryWriter) bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// binaryReader implements the binary protocol, both the strict and the old
+// non-strict message headers are accepted.
+type binaryReader struct {
+	errReader
+}
+
+var _ protocolReader = &binaryReader{}
+
+func (r *binaryReader) readMessageBegin() (string, messageType, int32) {
+	header := r.readI32()
+	if header < 0 {
+		if uint32(header)&0xffff0000 != binaryVersion1 {
+			r.fail(fmt.Errorf("bad binary protocol version %#x", uint32(header)&0xffff0000))
+			return "", 0, 0
+		}
+		name := string(r.readBinary())
+		return name, messageType(header & 0xff), r.readI32()
+	}
+	name := string(r.read(r.checkSize(int64(header))))
+	typ := messageType(r.readByte())
+	return name, typ, r.readI32()
+}
+
+func (r *binaryReader) readStructBegin() {}
+func (r *binaryReader) readStructEnd()   {}
+
+func (r *binaryReader) readFieldBegin() (ttype, int16) {
+	t := ttype(r.errReader.readByte())
+	if t == typeStop {
+		return t, 0
+	}
+	return t, r.readI16()
+}
+
+func (r *binaryReader) readMapBegin() (ttype, ttype, int) {
+	key, elem := ttype(r.errReader.readByte()), ttype(r.errReader.readByte())
+	return key, elem, r.checkSize(int64(r.readI32()))
+}
+
+func (r *binaryReader) readListBegin() (ttype, int) {
+	elem := ttype(r.errReader.readByte())
+	return elem, r.checkSize(int64(r.readI32()))
+}
+
+func (r *binaryReader) readBool() bool {
+	return r.errReader.readByte() != 0
+}
+
+func (r *binaryReader) readByte() int8 {
+	return int8(r.errReader.readByte())
+}
+
+func (r *binaryReader) readI16() int16 {
+	return int16(binary.BigEndian.Uint16(r.read(2)))
+}
+
+func (r *binaryReader) readI32() int32 {
+	return int32(binary.BigEndian.Uint32(r.read(4)))
+}
+
+func (r *binaryReader) readI64() int64 {
+	return int64(binary.BigEndian.Uint64(r.read(8)))
+}
+
+func (r *binaryReader) readDouble() float64 {
+	return math.Float64frombits(uint64(r.readI64()))
+}
+
+func (r *binaryReader) readBinary() []byte {
+	return r.read(r.checkSize(int64(r.readI32())))
+}
+
+// The compact protocol uses its own type ids on the wire.
+const (
+	compactBoolTrue  = 1
+	compactBoolFalse = 2
+
+	compactProtocolID = 0x82
+	compactVersion    = 1
+)
+
+//nolint:gochecknoglobals
+var (
+	compactTypes = map[ttype]byte{
+		typeStop: 0, typeBool: compactBoolTrue, typeByte: 3, typeI16: 4, typeI32: 5, typeI64: 6,
+		typeDouble: 7, typeString: 8, typeList: 9, typeSet: 10, typeMap: 11, typeStruct: 12,
+	}
+	compactTypesReverse = map[byte]ttype{
+		0: typeStop, compactBoolTrue: typeBool, compactBoolFalse: typeBool, 3: typeByte, 4: typeI16, 5: typeI32,
+		6: typeI64, 7: typeDouble, 8: typeString, 9: typeList, 10: typeSet, 11: typeMap, 12: typeStruct,
+	}
+)
+
+// compactWriter implements the compact protocol.
+type compactWriter struct {
+	buf bytes.Buffer
+
+	lastFieldID  int16
+	fieldIDStack []int16
+
+	// bool fields are written together with their field header
+	pendingBoolField *int16
+}
+
+var _ protocolWriter = &compactWriter{}
+
+func (w *compactWriter) writeVarint(v uint64) {
+	var b [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(b[:], v)
+	w.buf.Write(b[:n])
+}
+
+func zigzag(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
+
+func unzigzag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+func (w *compactWriter) writeMessageBegin(name string, typ messageType, seqID int32) {
+	w.buf.WriteByte(compactProtocolID)
+	w.buf.WriteByte(compactVersion | byte(typ)<<5)
+	w.writeVarint(uint64(uint32(seqID)))
+	w.writeBinary([]byte(name))
+}
+
+func (w *compactWriter) writeStructBegin() {
+	w.fieldIDStack = append(w.fieldIDStack, w.lastFieldID)
+	w.lastFieldID = 0
+}
+
+func (w *compactWriter) writeStructEnd() {
+	w.lastFieldID = w.fieldIDStack[len(w.fieldIDStack)-1]
+	w.fieldIDStack = w.fieldIDStack[:len(w.fieldIDStack)-1]
+}
+
+func (w *compactWriter) writeFieldHeader(ct byte, id int16) {
+	if delta := id - w.lastFieldID; delta > 0 && delta <= 15 {
+		w.buf.WriteByte(byte(delta)<<4 | ct)
+	} else {
+		w.buf.WriteByte(ct)
+		w.writeVarint(zigzag(int64(id)))
+	}
+	w.lastFieldID = id
+}
+
+func (w *compactWriter) writeFieldBegin(t ttype, id int16) {
+	if t == typeBool {
+		w.pendingBoolField = &id
+		return
+	}
+	w.writeFieldHeader(compactTypes[t], id)
+}
+
+func (w *compactWriter) writeFieldStop() {
+	w.buf.WriteByte(0)
+}
+
+func (w *compactWriter) writeMapBegin(key, elem ttype, size int) {
+	if size == 0 {
+		w.buf.WriteByte(0)
+		return
+	}
+	w.writeVarint(uint64(size))
+	w.buf.WriteByte(compactTypes[key]<<4 | compactTypes[elem])
+}
+
+func (w *compactWriter) writeListBegin(elem ttype, size int) {
+	if size < 15 {
+		w.buf.WriteByte(byte(size)<<4 | compactTypes[elem])
+		return
+	}
+	w.buf.WriteByte(0xf0 | compactTypes[elem])
+	w.writeVarint(uint64(size))
+}
+
+func (w *compactWriter) writeBool(v bool) {
+	ct := byte(compactBoolFalse)
+	if v {
+		ct = compactBoolTrue
+	}
+	if w.pendingBoolField != nil {
+		w.writeFieldHeader(ct, *w.pendingBoolField)
+		w.pendingBoolField = nil
+		return
+	}
+	w.buf.WriteByte(ct)
+}
+
+func (w *compactWriter) writeByte(v int8) {
+	w.buf.WriteByte(byte(v))
+}
+
+func (w *compactWriter) writeI16(v int16) {
+	w.writeVarint(zigzag(int64(v)))
+}
+
+func (w *compactWriter) writeI32(v int32) {
+	w.writeVarint(zigzag(int64(v)))
+}
+
+func (w *compactWriter) writeI64(v int64) {
+	w.writeVarint(zigzag(v))
+}
+
+func (w *compactWriter) writeDouble(v float64) {
+	var b [8]byte
+	binary.LittleEndian.PutUint64(b[:], math.Float64bits(v))
+	w.buf.Write(b[:])
+}
+
+func (w *compactWriter) writeBinary(v []byte) {
+	w.writeVarint(uint64(len(v)))
+	w.buf.Write(v)
+}
+
+func (w *compactWriter) bytes() []byte {
+	return w.buf.Bytes()
+}
+
+// compactReader implements the compact protocol.
+type compactReader struct {
+	errReader
+
+	lastFieldID  int16
+	fieldIDStack []int16
+
+	// the value of a bool field is part of its header
+	pendingBool *bool
+}
+
+var _ protocolReader = &compactReader{}
+
+func (r *compactReader) readVarint() uint64 {
+	if r.lastErr != nil {
+		return 0
+	}
+	v, err := binary.ReadUvarint(r.r)
+	if err != nil {
+		r.fail(err)
+	}
+	return v
+}
+
+func (r *compactReader) readMessageBegin() (string, messageType, int32) {
+	if id := r.errReader.readByte(); id != compactProtocolID && r.lastErr == nil {
+		r.fail(fmt.Errorf("bad compact protocol id %#x", id))
+		return "", 0, 0
+	}
+	versionAndType := r.errReader.readByte()
+	if versionAndType&0x1f != compactVersion && r.lastErr == nil {
+		r.fail(fmt.Errorf("bad compact protocol version %d", versionAndType&0x1f))
+		return "", 0, 0
+	}
+	seqID := int32(uint32(r.readVarint()))
+	name := string(r.readBinary())
+	return name, messageType(versionAndType >> 5), seqID
+}
+
+func (r *compactReader) readStructBegin() {
+	r.fieldIDStack = append(r.fieldIDStack, r.lastFieldID)
+	r.lastFieldID = 0
+}
+
+func (r *compactReader) readStructEnd() {
+	r.lastFieldID = r.fieldIDStack[len(r.fieldIDStack)-1]
+	r.fieldIDStack = r.fieldIDStack[:len(r.fieldIDStack)-1]
+}
+
+func (r *compactReader) toType(ct byte) ttype {
+	t, ok := compactTypesReverse[ct]
+	if !ok {
+		r.fail(fmt.Errorf("unknown compact protocol type %d", ct))
+	}
+	return t
+}
+
+func (r *compactReader) readFieldBegin() (ttype, int16) {
+	header := r.errReader.readByte()
+	ct := header & 0x0f
+	if ct == 0 {
+		return typeStop, 0
+	}
+	var id int16
+	if delta := int16(header >> 4); delta != 0 {
+		id = r.lastFieldID + delta
+	} else {
+		id = int16(unzigzag(r.readVarint()))
+	}
+	r.lastFieldID = id
+	t := r.toType(ct)
+	if t == typeBool {
+		v := ct == compactBoolTrue
+		r.pendingBool = &v
+	}
+	return t, id
+}
+
+func (r *compactReader) readMapBegin() (ttype, ttype, int) {
+	size := r.checkSize(int64(r.readVarint()))
+	if size == 0 {
+		return typeStop, typeStop, 0
+	}
+	types := r.errReader.readByte()
+	return r.toType(types >> 4), r.toType(types & 0x0f), size
+}
+
+func (r *compactReader) readListBegin() (ttype, int) {
+	header := r.errReader.readByte()
+	size := int64(header >> 4)
+	if size == 15 {
+		size = int64(r.readVarint())
+	}
+	return r.toType(header & 0x0f), r.checkSize(size)
+}
+
+func (r *compactReader) readBool() bool {
+	if r.pendingBool != nil {
+		v := *r.pendingBool
+		r.pendingBool = nil
+		return v
+	}
+	return r.errReader.readByte() == compactBoolTrue
+}
+
+func (r *compactReader) readByte() int8 {
+	return int8(r.errReader.readByte())
+}
+
+func (r *compactReader) readI16() int16 {
+	return int16(unzigzag(r.readVarint()))
+}
+
+func (r *compactReader) readI32() int32 {
+	return int32(unzigzag(r.readVarint()))
+}
+
+func (r *compactReader) readI64() int64 {
+	return unzigzag(r.readVarint())
+}
+
+func (r *compactReader) readDouble() float64 {
+	return math.Float64frombits(binary.LittleEndian.Uint64(r.read(8)))
+}
+
+func (r *compactReader) readBinary() []byte {
+	return r.read(r.checkSize(int64(r.readVarint())))
+}
@@ -0,0 +1,184 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parseTestIDL(t *testing.T) *idlFile {
+	t.Helper()
+	shared, _, err := parseIDL("shared.thrift", []byte(sharedIDL))
+	require.NoError(t, err)
+	f, _, err := parseIDL("tutorial.thrift", []byte(tutorialIDL))
+	require.NoError(t, err)
+	f.includes["shared"] = shared
+	require.NoError(t, shared.resolve())
+	require.NoError(t, f.resolve())
+	return f
+}
+
+func TestProtocolMessageEncoding(t *testing.T) {
+	t.Parallel()
+	add := parseTestIDL(t).services["Calculator"].function("add")
+
+	tests := map[string][]byte{
+		"binary": {
+			0x80, 0x01, 0x00, 0x01, 0x00, 0x00, 0x00, 0x03, 'a', 'd', 'd', 0x00, 0x00, 0x00, 0x01,
+			0x08, 0x00, 0x01, 0x00, 0x00, 0x00, 0x01,
+			0x08, 0x00, 0x02, 0x00, 0x00, 0x00, 0x02,
+			0x00,
+		},
+		"compact": {0x82, 0x21, 0x01, 0x03, 'a', 'd', 'd', 0x15, 0x02, 0x15, 0x04, 0x00},
+	}
+	for name, expected := range tests {
+		name, expected := name, expected
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			w := protocols[name].writer()
+			w.writeMessageBegin("add", messageCall, 1)
+			require.NoError(t, encodeStruct(w, add.args, map[string]interface{}{"num1": int64(1), "num2": int64(2)}))
+			assert.Equal(t, expected, w.bytes())
+
+			r := protocols[name].reader(bytes.NewReader(expected))
+			msgName, msgType, seqID := r.readMessageBegin()
+			assert.Equal(t, "add", msgName)
+			assert.Equal(t, messageCall, msgType)
+			assert.Equal(t, int32(1), seqID)
+			assert.Equal(t, map[string]interface{}{"num1": int64(1), "num2": int64(2)}, decodeStruct(r, add.args))
+			require.NoError(t, r.err())
+		})
+	}
+}
+
+func TestProtocolRoundTrip(t *testing.T) {
+	t.Parallel()
+	f := parseTestIDL(t)
+	work := f.structs["Work"]
+
+	value := map[string]interface{}{
+		"num1":    int64(-1),
+		"num2":    int64(1 << 20),
+		"op":      "MULTIPLY",
+		"comment": "a comment",
+		"extra": map[string]interface{}{
+			"empty": []interface{}{},
+			"long": []interface{}{int64(1), int64(2), int64(3), int64(4), int64(5), int64(6), int64(7), int64(8),
+				int64(9), int64(10), int64(11), int64(12), int64(13), int64(14), int64(15), int64(16)},
+		},
+		"payload": []byte{0, 1, 2, 255},
+	}
+	for name, protocol := range protocols {
+		w := protocol.writer()
+		require.NoError(t, encodeStruct(w, work, value), name)
+		r := protocol.reader(bytes.NewReader(w.bytes()))
+		assert.Equal(t, value, decodeStruct(r, work), name)
+		require.NoError(t, r.err(), name)
+	}
+}
+
+func TestProtocolSkipUnknownFields(t *testing.T) {
+	t.Parallel()
+	f := parseTestIDL(t)
+	work := f.structs["Work"]
+	// a struct with the first fields of Work
+	older := &idlStruct{name: "Work", fields: work.fields[:2]}
+
+	value := map[string]interface{}{
+		"num1":    int64(1),
+		"num2":    int64(2),
+		"comment": "skipped",
+		"extra":   map[string]interface{}{"skipped": []interface{}{int64(1)}},
+	}
+	for name, protocol := range protocols {
+		w := protocol.writer()
+		require.NoError(t, encodeStruct(w, work, value), name)
+		// a trailing value, to check that the skipped fields were read fully
+		w.writeI32(42)
+		r := protocol.reader(bytes.NewReader(w.bytes()))
+		assert.Equal(t, map[string]interface{}{"num1": int64(1), "num2": int64(2)}, decodeStruct(r, older), name)
+		assert.Equal(t, int32(42), r.readI32(), name)
+		require.NoError(t, r.err(), name)
+	}
+}
+
+func TestProtocolBools(t *testing.T) {
+	t.Parallel()
+	s := &idlStruct{name: "Bools", fields: []*idlField{
+		{id: 1, name: "a", typ: &idlType{kind: typeBool}},
+		{id: 20, name: "b", typ: &idlType{kind: typeBool}},
+		{id: 21, name: "c", typ: &idlType{kind: typeList, elem: &idlType{kind: typeBool}}},
+	}}
+	value := map[string]interface{}{"a": true, "b": false, "c": []interface{}{true, false, true}}
+	for name, protocol := range protocols {
+		w := protocol.writer()
+		require.NoError(t, encodeStruct(w, s, value), name)
+		r := protocol.reader(bytes.NewReader(w.bytes()))
+		assert.Equal(t, value, decodeStruct(r, s), name)
+		require.NoError(t, r.err(), name)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	t.Parallel()
+	work := parseTestIDL(t).structs["Work"]
+
+	tests := map[string]map[string]interface{}{
+		"required field 'num2' of Work is missing": {"num1": int64(1)},
+		"unknown field 'foo' in Work":              {"num2": int64(1), "foo": "bar"},
+		"Work.num1: expected a number, got string": {"num2": int64(1), "num1": "1"},
+		"Work.num1: expected an integer, got 1.5":  {"num2": int64(1), "num1": 1.5},
+		"Work.num1: value 4294967296 is out of":    {"num2": int64(1), "num1": int64(1 << 32)},
+		"Work.op: unknown Operation value 'MOD'":   {"num2": int64(1), "op": "MOD"},
+		"Work.extra: [a]: expected an array":       {"num2": int64(1), "extra": map[string]interface{}{"a": "b"}},
+	}
+	for expErr, value := range tests {
+		err := encodeStruct(protocols["binary"].writer(), work, value)
+		require.Error(t, err, expErr)
+		assert.Contains(t, err.Error(), expErr)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+	work := parseTestIDL(t).structs["Work"]
+
+	for name, protocol := range protocols {
+		w := protocol.writer()
+		require.NoError(t, encodeStruct(w, work, map[string]interface{}{"num2": int64(1), "comment": "truncated"}))
+		data := w.bytes()
+		r := protocol.reader(bytes.NewReader(data[:len(data)-3]))
+		decodeStruct(r, work)
+		assert.Error(t, r.err(), name)
+	}
+
+	r := protocols["binary"].reader(bytes.NewReader([]byte{0xff, 0xff, 0xff, 0xff}))
+	r.readBinary()
+	assert.ErrorIs(t, r.err(), errContainerTooBig)
+
+	r = protocols["compact"].reader(bytes.NewReader([]byte{0x80, 0x21}))
+	r.readMessageBegin()
+	assert.EqualError(t, r.err(), "bad compact protocol id 0x80")
+}
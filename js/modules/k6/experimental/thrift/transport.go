@@ -0,0 +1,132 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package thrift
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+// transport sends serialized messages to a Thrift server and returns a reader
+// of the reply.
+type transport interface {
+	roundTrip(ctx context.Context, msg []byte, oneway bool, timeout time.Duration) (byteReader, error)
+	close() error
+}
+
+// socketTransport is a buffered or framed transport over a single TCP
+// connection. Calls on it are sequential, like they are in the official
+// clients.
+type socketTransport struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	framed bool
+}
+
+func (t *socketTransport) roundTrip(
+	ctx context.Context, msg []byte, oneway bool, timeout time.Duration,
+) (byteReader, error) {
+	deadline := time.Now().Add(timeout)
+	if ctxDeadline, ok := ctx.Deadline(); ok && ctxDeadline.Before(deadline) {
+		deadline = ctxDeadline
+	}
+	if err := t.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	if t.framed {
+		frame := make([]byte, 4, 4+len(msg))
+		binary.BigEndian.PutUint32(frame, uint32(len(msg)))
+		msg = append(frame, msg...)
+	}
+	if _, err := t.conn.Write(msg); err != nil {
+		return nil, err
+	}
+	if oneway || !t.framed {
+		return t.reader, nil
+	}
+
+	var size [4]byte
+	if _, err := io.ReadFull(t.reader, size[:]); err != nil {
+		return nil, err
+	}
+	frameSize := binary.BigEndian.Uint32(size[:])
+	if frameSize > maxContainerSize {
+		return nil, errContainerTooBig
+	}
+	frame := make([]byte, frameSize)
+	if _, err := io.ReadFull(t.reader, frame); err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(frame), nil
+}
+
+func (t *socketTransport) close() error {
+	return t.conn.Close()
+}
+
+// httpTransport sends every message as the body of a POST request.
+type httpTransport struct {
+	url    string
+	client *http.Client
+}
+
+func (t *httpTransport) roundTrip(
+	ctx context.Context, msg []byte, _ bool, timeout time.Duration,
+) (byteReader, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(msg))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-thrift")
+	req.Header.Set("Accept", "application/x-thrift")
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxContainerSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	return bytes.NewReader(body), nil
+}
+
+func (t *httpTransport) close() error {
+	t.client.CloseIdleConnections()
+	return nil
+}
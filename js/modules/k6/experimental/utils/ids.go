@@ -0,0 +1,188 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package utils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+)
+
+const (
+	// snowflakeEpoch is the time the timestamps of the snowflake IDs are
+	// counted from, the one of Twitter, in milliseconds since the Unix epoch.
+	snowflakeEpoch = 1288834974657
+
+	snowflakeNodeBits     = 10
+	snowflakeSequenceBits = 12
+)
+
+// crockford is the Crockford's Base32 alphabet of the ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// UUIDv4 returns a random UUID.
+func (mi *ModuleInstance) UUIDv4() (string, error) {
+	var id [16]byte
+	if _, err := io.ReadFull(mi.random, id[:]); err != nil {
+		return "", err
+	}
+	return formatUUID(id, 4), nil
+}
+
+// UUIDv7 returns a UUID which starts with the Unix time in milliseconds, and
+// is random after it, so the ones of later milliseconds sort after it.
+func (mi *ModuleInstance) UUIDv7() (string, error) {
+	var id [16]byte
+	if _, err := io.ReadFull(mi.random, id[6:]); err != nil {
+		return "", err
+	}
+	putMillis(id[:6], time.Now())
+	return formatUUID(id, 7), nil
+}
+
+// ULID returns a Universally Unique Lexicographically Sortable Identifier, of
+// the Unix time in milliseconds and 80 random bits.
+func (mi *ModuleInstance) ULID() (string, error) {
+	var id [16]byte
+	if _, err := io.ReadFull(mi.random, id[6:]); err != nil {
+		return "", err
+	}
+	putMillis(id[:6], time.Now())
+
+	// The 128 bits are encoded in 26 characters of 5 bits, of which the first
+	// one has only 3 bits.
+	var encoded [26]byte
+	hi, lo := binary.BigEndian.Uint64(id[:8]), binary.BigEndian.Uint64(id[8:])
+	for i := 25; i >= 0; i-- {
+		encoded[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(encoded[:]), nil
+}
+
+// Snowflake returns a 64-bit ID of the milliseconds since the snowflakeEpoch,
+// the index of the execution segment of the instance and a sequence, which
+// increases over all the VUs of the instance. It's returned as a string, as JS
+// numbers can't hold all of its bits.
+func (mi *ModuleInstance) Snowflake() (string, error) {
+	state := mi.vu.State()
+	if state == nil {
+		return "", common.NewInitContextError("generating snowflake IDs in the init context is not supported")
+	}
+	mi.snowflakes.once.Do(func() {
+		mi.snowflakes.node = uint64(segmentIndex(state.Options)) & (1<<snowflakeNodeBits - 1)
+	})
+	return strconv.FormatUint(mi.snowflakes.next(time.Now()), 10), nil
+}
+
+// Sequence returns the next ID of the sequence of the VU with the name, or of
+// its default one, of the form "segment-vu-n": the index of the execution
+// segment of the instance, the ID of the VU in the instance, and the number
+// of the ID in the sequence, from 1. So the IDs of all the VUs of all the
+// instances are unique.
+func (mi *ModuleInstance) Sequence(name goja.Value) (string, error) {
+	state := mi.vu.State()
+	if state == nil {
+		return "", common.NewInitContextError("generating sequence IDs in the init context is not supported")
+	}
+	if mi.namespace == "" {
+		mi.namespace = fmt.Sprintf("%d-%d-", segmentIndex(state.Options), state.VUID)
+	}
+	var key string
+	if name != nil && !goja.IsUndefined(name) && !goja.IsNull(name) {
+		key = name.String()
+	}
+	mi.sequences[key]++
+	return mi.namespace + strconv.FormatUint(mi.sequences[key], 10), nil
+}
+
+// segmentIndex returns the index of the execution segment of the instance in
+// the execution segment sequence, 0 if there's a single instance.
+func segmentIndex(opts lib.Options) int {
+	et, err := lib.NewExecutionTuple(opts.ExecutionSegment, opts.ExecutionSegmentSequence)
+	if err != nil {
+		return 0
+	}
+	return et.SegmentIndex
+}
+
+// putMillis puts the Unix time of t in milliseconds in the 6 bytes of b.
+func putMillis(b []byte, t time.Time) {
+	ms := uint64(t.UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		b[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+// formatUUID sets the version and the variant of the UUID and formats it.
+func formatUUID(id [16]byte, version byte) string {
+	id[6] = id[6]&0x0f | version<<4
+	id[8] = id[8]&0x3f | 0x80
+	var s [36]byte
+	hex.Encode(s[0:8], id[0:4])
+	s[8] = '-'
+	hex.Encode(s[9:13], id[4:6])
+	s[13] = '-'
+	hex.Encode(s[14:18], id[6:8])
+	s[18] = '-'
+	hex.Encode(s[19:23], id[8:10])
+	s[23] = '-'
+	hex.Encode(s[24:], id[10:])
+	return string(s[:])
+}
+
+// snowflakeGenerator generates the snowflake IDs of the instance. When the
+// sequence of a millisecond runs out, the IDs are of the next one, so they
+// keep increasing.
+type snowflakeGenerator struct {
+	once sync.Once
+	node uint64
+
+	mu       sync.Mutex
+	last     uint64
+	sequence uint64
+}
+
+func (g *snowflakeGenerator) next(now time.Time) uint64 {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	ms := uint64(now.UnixNano()/int64(time.Millisecond) - snowflakeEpoch)
+	if ms > g.last {
+		g.last, g.sequence = ms, 0
+	} else {
+		g.sequence++
+		if g.sequence == 1<<snowflakeSequenceBits {
+			g.last, g.sequence = g.last+1, 0
+		}
+	}
+	return g.last<<(snowflakeNodeBits+snowflakeSequenceBits) | g.node<<snowflakeSequenceBits | g.sequence
+}
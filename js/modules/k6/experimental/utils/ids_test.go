@@ -0,0 +1,150 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package utils
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+)
+
+func newTestVU(t *testing.T, rm *RootModule, state *lib.State) *goja.Runtime {
+	t.Helper()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{},
+		StateField:   state,
+	}
+	m, ok := rm.NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("utils", m.Exports().Named))
+	return rt
+}
+
+func TestUUIDs(t *testing.T) {
+	t.Parallel()
+	rt := newTestVU(t, New(), nil)
+
+	v, err := rt.RunString(`[utils.uuidv4(), utils.uuidv4(), utils.uuidv7()]`)
+	require.NoError(t, err)
+	ids, ok := v.Export().([]interface{})
+	require.True(t, ok)
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, ids[0])
+	assert.NotEqual(t, ids[0], ids[1])
+	assert.Regexp(t, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`, ids[2])
+
+	ms, err := strconv.ParseInt(ids[2].(string)[:8]+ids[2].(string)[9:13], 16, 64)
+	require.NoError(t, err)
+	assert.InDelta(t, time.Now().UnixNano()/int64(time.Millisecond), ms, 1000)
+}
+
+func TestULID(t *testing.T) {
+	t.Parallel()
+	rt := newTestVU(t, New(), nil)
+
+	v, err := rt.RunString(`utils.ulid()`)
+	require.NoError(t, err)
+	first := v.String()
+	assert.Regexp(t, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`, first)
+
+	time.Sleep(2 * time.Millisecond)
+	v, err = rt.RunString(`utils.ulid()`)
+	require.NoError(t, err)
+	assert.Less(t, first, v.String(), "the ULIDs of later milliseconds sort after")
+}
+
+func TestSnowflake(t *testing.T) {
+	t.Parallel()
+	segment, err := lib.NewExecutionSegmentFromString("1/3:2/3")
+	require.NoError(t, err)
+	sequence, err := lib.NewExecutionSegmentSequenceFromString("0,1/3,2/3,1")
+	require.NoError(t, err)
+	state := &lib.State{Options: lib.Options{ExecutionSegment: segment, ExecutionSegmentSequence: &sequence}}
+
+	rm := New()
+	rt1 := newTestVU(t, rm, state)
+	rt2 := newTestVU(t, rm, state)
+
+	var last uint64
+	for i := 0; i < 5000; i++ {
+		rt := rt1
+		if i%2 == 1 {
+			rt = rt2
+		}
+		v, err := rt.RunString(`utils.snowflake()`)
+		require.NoError(t, err)
+		id, err := strconv.ParseUint(v.String(), 10, 64)
+		require.NoError(t, err)
+		require.Greater(t, id, last, "the IDs of all the VUs increase")
+		assert.Equal(t, uint64(1), id>>snowflakeSequenceBits&(1<<snowflakeNodeBits-1), "the node is the segment index")
+		last = id
+	}
+
+	_, err = newTestVU(t, rm, nil).RunString(`utils.snowflake()`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+}
+
+func TestSnowflakeGeneratorOverflow(t *testing.T) {
+	t.Parallel()
+	g := &snowflakeGenerator{node: 3}
+	now := time.Unix(1600000000, 0)
+	var last uint64
+	for i := 0; i < 1<<snowflakeSequenceBits+1; i++ {
+		id := g.next(now)
+		require.Greater(t, id, last)
+		last = id
+	}
+	ms := uint64(now.UnixNano()/int64(time.Millisecond) - snowflakeEpoch)
+	assert.Equal(t, ms+1, last>>(snowflakeNodeBits+snowflakeSequenceBits), "the sequence ran out")
+	assert.Equal(t, ms+1, g.next(now.Add(-time.Second))>>(snowflakeNodeBits+snowflakeSequenceBits),
+		"the time going back doesn't repeat the IDs")
+}
+
+func TestSequence(t *testing.T) {
+	t.Parallel()
+	rm := New()
+	rt1 := newTestVU(t, rm, &lib.State{VUID: 1})
+	rt2 := newTestVU(t, rm, &lib.State{VUID: 2})
+
+	v, err := rt1.RunString(`[utils.sequence(), utils.sequence(), utils.sequence("orders"), utils.sequence()]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"0-1-1", "0-1-2", "0-1-1", "0-1-3"}, v.Export())
+	v, err = rt2.RunString(`utils.sequence()`)
+	require.NoError(t, err)
+	assert.Equal(t, "0-2-1", v.String())
+
+	_, err = newTestVU(t, rm, nil).RunString(`utils.sequence()`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+}
@@ -0,0 +1,86 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package utils implements the k6/experimental/utils module, which generates
+// unique IDs in Go, as generating a lot of them in JS takes a measurable part
+// of the iterations.
+package utils
+
+import (
+	"bufio"
+	"crypto/rand"
+
+	"go.k6.io/k6/js/modules"
+)
+
+type (
+	// RootModule is the global module instance that will create module
+	// instances for each VU.
+	RootModule struct {
+		snowflakes *snowflakeGenerator
+	}
+
+	// ModuleInstance represents an instance of the utils module for every VU.
+	ModuleInstance struct {
+		vu         modules.VU
+		snowflakes *snowflakeGenerator
+		// random buffers the random bytes of the IDs, as reading them one ID
+		// at a time is slow; a VU runs a single ID generator at a time.
+		random *bufio.Reader
+		// sequences are the last IDs of the sequences of the VU by name
+		sequences map[string]uint64
+		// namespace is the prefix of the IDs of the sequences of the VU
+		namespace string
+	}
+)
+
+var (
+	_ modules.Module   = &RootModule{}
+	_ modules.Instance = &ModuleInstance{}
+)
+
+// New returns a pointer to a new RootModule instance.
+func New() *RootModule {
+	return &RootModule{snowflakes: &snowflakeGenerator{}}
+}
+
+// NewModuleInstance implements the modules.Module interface to return
+// a new instance for each VU.
+func (rm *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
+	return &ModuleInstance{
+		vu:         vu,
+		snowflakes: rm.snowflakes,
+		random:     bufio.NewReaderSize(rand.Reader, 4096),
+		sequences:  make(map[string]uint64),
+	}
+}
+
+// Exports returns the exports of the utils module.
+func (mi *ModuleInstance) Exports() modules.Exports {
+	return modules.Exports{
+		Named: map[string]interface{}{
+			"uuidv4":    mi.UUIDv4,
+			"uuidv7":    mi.UUIDv7,
+			"ulid":      mi.ULID,
+			"snowflake": mi.Snowflake,
+			"sequence":  mi.Sequence,
+		},
+	}
+}
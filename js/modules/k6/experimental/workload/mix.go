@@ -0,0 +1,175 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package workload
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/stats"
+)
+
+const (
+	// endpointTag is set to the name of the picked endpoint while it runs,
+	// so all of its metrics can be told apart.
+	endpointTag = "endpoint"
+	// expectedTag is the configured share of an endpoint in the samples of
+	// the workload_mix metric, e.g. "70%".
+	expectedTag = "expected"
+)
+
+var errRunInInitContext = common.NewInitContextError("running a workload mix in the init context is not supported")
+
+// endpoint is one of the weighted choices of a Mix.
+type endpoint struct {
+	name     string
+	weight   float64
+	expected string
+	exec     goja.Callable
+}
+
+// Mix picks one of its endpoints at random for every run, in proportion to
+// their weights.
+type Mix struct {
+	vu        modules.VU
+	endpoints []endpoint
+	total     float64
+}
+
+// newMix returns the Mix of the endpoints in the JS array v.
+func newMix(rt *goja.Runtime, v goja.Value) (*Mix, error) {
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return nil, errors.New("the endpoints of the mix are required")
+	}
+	obj := v.ToObject(rt)
+	length := obj.Get("length")
+	if length == nil || length.ToInteger() == 0 {
+		return nil, errors.New("the endpoints of the mix should be a non-empty array")
+	}
+
+	mix := &Mix{}
+	names := make(map[string]bool)
+	for i := int64(0); i < length.ToInteger(); i++ {
+		item := obj.Get(strconv.FormatInt(i, 10))
+		if item == nil || goja.IsUndefined(item) || goja.IsNull(item) {
+			return nil, fmt.Errorf("the endpoint %d of the mix isn't an object", i)
+		}
+		e, err := newEndpoint(item.ToObject(rt))
+		if err != nil {
+			return nil, fmt.Errorf("the endpoint %d of the mix is invalid: %w", i, err)
+		}
+		if names[e.name] {
+			return nil, fmt.Errorf("the endpoint name %q is used more than once", e.name)
+		}
+		names[e.name] = true
+		mix.endpoints = append(mix.endpoints, e)
+		mix.total += e.weight
+	}
+
+	for i, e := range mix.endpoints {
+		mix.endpoints[i].expected = strconv.FormatFloat(100*e.weight/mix.total, 'g', 4, 64) + "%"
+	}
+	return mix, nil
+}
+
+func newEndpoint(obj *goja.Object) (endpoint, error) {
+	var e endpoint
+	if name := obj.Get("name"); name != nil && !goja.IsUndefined(name) {
+		e.name = name.String()
+	}
+	// The names end up in the names of the submetrics in the summary.
+	if e.name == "" || strings.ContainsAny(e.name, ",:{}*") {
+		return e, fmt.Errorf("the name %q should be non-empty, without any of the characters ,:{}*", e.name)
+	}
+
+	weight := obj.Get("weight")
+	if weight == nil || goja.IsUndefined(weight) {
+		return e, errors.New("the weight is required")
+	}
+	e.weight = weight.ToFloat()
+	if !(e.weight > 0) || math.IsInf(e.weight, 0) {
+		return e, fmt.Errorf("the weight should be a positive number, not %s", weight)
+	}
+
+	var ok bool
+	if e.exec, ok = goja.AssertFunction(obj.Get("exec")); !ok {
+		return e, errors.New("exec should be a function")
+	}
+	return e, nil
+}
+
+// pick returns the index of the endpoint at x, from 0 to the total weight.
+func (m *Mix) pick(x float64) int {
+	for i, e := range m.endpoints {
+		if x < e.weight {
+			return i
+		}
+		x -= e.weight
+	}
+	return len(m.endpoints) - 1
+}
+
+// Run picks an endpoint and calls its exec function, with the endpoint tag
+// set to its name, and returns what it returned. Whether each endpoint was
+// picked is added to the workload_mix metric, tagged with its name and its
+// configured share, so the summary shows the achieved mix next to it.
+func (m *Mix) Run() (goja.Value, error) {
+	state := m.vu.State()
+	if state == nil {
+		return nil, errRunInInitContext
+	}
+
+	picked := m.pick(rand.Float64() * m.total) //nolint:gosec
+	now := time.Now()
+	samples := make(stats.Samples, 0, len(m.endpoints))
+	for i, e := range m.endpoints {
+		tags := state.CloneTags()
+		tags[endpointTag] = e.name
+		tags[expectedTag] = e.expected
+		samples = append(samples, stats.Sample{
+			Metric: state.BuiltinMetrics.WorkloadMix,
+			Tags:   stats.IntoSampleTags(&tags),
+			Value:  stats.B(i == picked),
+			Time:   now,
+		})
+	}
+	stats.PushIfNotDone(m.vu.Context(), state.Samples, samples)
+
+	previous, hadPrevious := state.Tags.Get(endpointTag)
+	state.Tags.Set(endpointTag, m.endpoints[picked].name)
+	defer func() {
+		if hadPrevious {
+			state.Tags.Set(endpointTag, previous)
+		} else {
+			state.Tags.Delete(endpointTag)
+		}
+	}()
+	return m.endpoints[picked].exec(goja.Undefined())
+}
@@ -0,0 +1,147 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package workload
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func newTestRuntime(t *testing.T) (*goja.Runtime, *modulestest.VU) {
+	t.Helper()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+	vu := &modulestest.VU{
+		CtxField:     context.Background(),
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Logger: logrus.New()},
+	}
+	m, ok := New().NewModuleInstance(vu).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("workload", m.Exports().Named))
+	return rt, vu
+}
+
+func TestMixRun(t *testing.T) {
+	t.Parallel()
+	rt, vu := newTestRuntime(t)
+	_, err := rt.RunString(`
+		var tags = [];
+		var mix = new workload.Mix([
+			{name: "browse", weight: 3, exec: function() { tags.push(state.endpoint()); return "browsed"; }},
+			{name: "buy", weight: 1, exec: function() { tags.push(state.endpoint()); return "bought"; }},
+		]);
+	`)
+	require.NoError(t, err)
+
+	_, err = rt.RunString(`mix.run()`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+
+	samples := make(chan stats.SampleContainer, 1000)
+	state := &lib.State{
+		Tags:           lib.NewTagMap(map[string]string{"scenario": "default"}),
+		Samples:        samples,
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+	}
+	vu.StateField = state
+	require.NoError(t, rt.Set("state", map[string]interface{}{
+		"endpoint": func() string {
+			name, _ := state.Tags.Get("endpoint")
+			return name
+		},
+	}))
+
+	for i := 0; i < 100; i++ {
+		v, err := rt.RunString(`mix.run()`)
+		require.NoError(t, err)
+		assert.Contains(t, []string{"browsed", "bought"}, v.String())
+	}
+	_, ok := state.Tags.Get("endpoint")
+	assert.False(t, ok, "the endpoint tag should be removed after the run")
+	assert.Subset(t, []string{"browse", "buy"}, rt.Get("tags").Export())
+
+	require.Len(t, samples, 100)
+	picked := map[string]int{}
+	for i := 0; i < 100; i++ {
+		ss := (<-samples).GetSamples()
+		require.Len(t, ss, 2)
+		for _, s := range ss {
+			assert.Equal(t, metrics.WorkloadMixName, s.Metric.Name)
+			tags := s.Tags.CloneTags()
+			assert.Equal(t, "default", tags["scenario"])
+			assert.Equal(t, map[string]string{"browse": "75%", "buy": "25%"}[tags["endpoint"]], tags["expected"])
+			if s.Value == 1 {
+				picked[tags["endpoint"]]++
+			}
+		}
+	}
+	assert.Equal(t, 100, picked["browse"]+picked["buy"])
+	assert.Greater(t, picked["browse"], picked["buy"])
+}
+
+func TestMixPick(t *testing.T) {
+	t.Parallel()
+	mix := &Mix{endpoints: []endpoint{{name: "a", weight: 1}, {name: "b", weight: 2}, {name: "c", weight: 1}}, total: 4}
+	assert.Equal(t, 0, mix.pick(0))
+	assert.Equal(t, 0, mix.pick(0.99))
+	assert.Equal(t, 1, mix.pick(1))
+	assert.Equal(t, 1, mix.pick(2.99))
+	assert.Equal(t, 2, mix.pick(3))
+	assert.Equal(t, 2, mix.pick(4))
+}
+
+func TestMixErrors(t *testing.T) {
+	t.Parallel()
+	testCases := []struct{ code, expErr string }{
+		{`new workload.Mix()`, "the endpoints of the mix are required"},
+		{`new workload.Mix([])`, "should be a non-empty array"},
+		{`new workload.Mix([null])`, "the endpoint 0 of the mix isn't an object"},
+		{`new workload.Mix([{weight: 1, exec: f}])`, "the name \"\" should be non-empty"},
+		{`new workload.Mix([{name: "a:b", weight: 1, exec: f}])`, "without any of the characters"},
+		{`new workload.Mix([{name: "a", exec: f}])`, "the weight is required"},
+		{`new workload.Mix([{name: "a", weight: -1, exec: f}])`, "the weight should be a positive number"},
+		{`new workload.Mix([{name: "a", weight: "x", exec: f}])`, "the weight should be a positive number"},
+		{`new workload.Mix([{name: "a", weight: 1}])`, "exec should be a function"},
+		{`new workload.Mix([{name: "a", weight: 1, exec: f}, {name: "a", weight: 1, exec: f}])`, "used more than once"},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.code, func(t *testing.T) {
+			t.Parallel()
+			rt, _ := newTestRuntime(t)
+			_, err := rt.RunString(`var f = function() {}; ` + tc.code)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.expErr)
+		})
+	}
+}
@@ -54,6 +54,7 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib/netext"
 	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
 	reflectpb "google.golang.org/grpc/reflection/grpc_reflection_v1alpha"
@@ -602,9 +603,18 @@ func (c *Client) HandleRPC(ctx context.Context, stat grpcstats.RPCStats) {
 				tags["ip"] = ip
 			}
 		}
+		if state.Options.SystemTags.Has(stats.TagIPFamily) && s.RemoteAddr != nil {
+			if family := netext.IPFamily(s.RemoteAddr); family != "" {
+				tags[stats.TagIPFamily.String()] = family
+			}
+		}
 	case *grpcstats.End:
+		code := status.Code(s.Error)
 		if state.Options.SystemTags.Has(stats.TagStatus) {
-			tags["status"] = strconv.Itoa(int(status.Code(s.Error)))
+			tags["status"] = strconv.Itoa(int(code))
+		}
+		if state.Options.SystemTags.Has(stats.TagStatusClass) {
+			tags["status_class"] = stats.StatusClass(strconv.Itoa(httpStatusFromCode(code)))
 		}
 
 		mTags := map[string]string(tags)
@@ -629,6 +639,38 @@ func (c *Client) HandleRPC(ctx context.Context, stat grpcstats.RPCStats) {
 	}
 }
 
+// httpStatusFromCode maps a gRPC status code to the HTTP status code that most
+// closely resembles it, so that gRPC requests can share the status_class tag
+// values with HTTP requests. The mapping is the one used by grpc-gateway.
+func httpStatusFromCode(code codes.Code) int {
+	switch code {
+	case codes.OK:
+		return 200
+	case codes.Canceled:
+		return 499
+	case codes.InvalidArgument, codes.FailedPrecondition, codes.OutOfRange:
+		return 400
+	case codes.DeadlineExceeded:
+		return 504
+	case codes.NotFound:
+		return 404
+	case codes.AlreadyExists, codes.Aborted:
+		return 409
+	case codes.PermissionDenied:
+		return 403
+	case codes.Unauthenticated:
+		return 401
+	case codes.ResourceExhausted:
+		return 429
+	case codes.Unimplemented:
+		return 501
+	case codes.Unavailable:
+		return 503
+	default: // Unknown, Internal, DataLoss
+		return 500
+	}
+}
+
 type connectParams struct {
 	IsPlaintext           bool
 	UseReflectionProtocol bool
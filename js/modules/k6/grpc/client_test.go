@@ -30,6 +30,7 @@ import (
 	"net/url"
 	"os"
 	"runtime"
+	"strconv"
 	"strings"
 	"sync/atomic"
 	"testing"
@@ -795,6 +796,27 @@ func TestDebugStat(t *testing.T) {
 	}
 }
 
+func TestHTTPStatusFromCode(t *testing.T) {
+	t.Parallel()
+
+	tests := map[codes.Code]string{
+		codes.OK:                "2xx",
+		codes.Canceled:          "4xx",
+		codes.InvalidArgument:   "4xx",
+		codes.NotFound:          "4xx",
+		codes.Unauthenticated:   "4xx",
+		codes.ResourceExhausted: "4xx",
+		codes.Unknown:           "5xx",
+		codes.DeadlineExceeded:  "5xx",
+		codes.Unimplemented:     "5xx",
+		codes.Unavailable:       "5xx",
+		codes.DataLoss:          "5xx",
+	}
+	for code, class := range tests {
+		assert.Equal(t, class, stats.StatusClass(strconv.Itoa(httpStatusFromCode(code))), code.String())
+	}
+}
+
 func TestClientInvokeHeadersDeprecated(t *testing.T) {
 	t.Parallel()
 
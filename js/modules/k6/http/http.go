@@ -108,6 +108,7 @@ func (r *RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 	mustExport("request", mi.defaultClient.Request)
 	mustExport("batch", mi.defaultClient.Batch)
 	mustExport("setResponseCallback", mi.defaultClient.SetResponseCallback)
+	mustExport("pollUntil", mi.PollUntil)
 
 	mustExport("expectedStatuses", mi.expectedStatuses) // TODO: refactor?
 
@@ -0,0 +1,173 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+const (
+	defaultPollInterval = time.Second
+	defaultPollTimeout  = time.Minute
+)
+
+type pollParams struct {
+	interval  time.Duration
+	backoff   float64
+	timeout   time.Duration
+	tags      map[string]string
+	reqParams goja.Value
+}
+
+func (mi *ModuleInstance) parsePollParams(v goja.Value) (pollParams, error) {
+	p := pollParams{
+		interval:  defaultPollInterval,
+		backoff:   1,
+		timeout:   defaultPollTimeout,
+		reqParams: goja.Undefined(),
+	}
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return p, nil
+	}
+	rt := mi.vu.Runtime()
+	obj := v.ToObject(rt)
+	for _, k := range obj.Keys() {
+		val := obj.Get(k)
+		var err error
+		switch k {
+		case "interval":
+			if p.interval, err = types.GetDurationValue(val.Export()); err != nil || p.interval <= 0 {
+				return p, fmt.Errorf("invalid interval value '%s', it needs to be a positive duration", val)
+			}
+		case "timeout":
+			if p.timeout, err = types.GetDurationValue(val.Export()); err != nil || p.timeout <= 0 {
+				return p, fmt.Errorf("invalid timeout value '%s', it needs to be a positive duration", val)
+			}
+		case "backoff":
+			if p.backoff = val.ToFloat(); p.backoff < 1 {
+				return p, fmt.Errorf("invalid backoff value '%s', it needs to be a number >= 1", val)
+			}
+		case "tags":
+			p.tags = make(map[string]string)
+			tagsObj := val.ToObject(rt)
+			for _, tk := range tagsObj.Keys() {
+				p.tags[tk] = tagsObj.Get(tk).String()
+			}
+		case "params":
+			p.reqParams = val
+		default:
+			return p, fmt.Errorf("unknown poll param: %q", k)
+		}
+	}
+	return p, nil
+}
+
+// PollUntil polls the URL with GET requests, or calls the function, until the
+// predicate returns true for the result, and returns that result. The waits
+// between the attempts start at the interval and are multiplied by the
+// backoff after each attempt. The whole operation is measured by a single
+// async_job_duration sample, tagged with the number of attempts and with
+// whether it completed or timed out; it's an error if it times out.
+func (mi *ModuleInstance) PollUntil(urlOrFn goja.Value, predicate goja.Callable, params goja.Value) (goja.Value, error) {
+	state := mi.vu.State()
+	if state == nil {
+		return nil, ErrHTTPForbiddenInInitContext
+	}
+	if predicate == nil {
+		return nil, errors.New("pollUntil requires a predicate function as its second argument")
+	}
+	p, err := mi.parsePollParams(params)
+	if err != nil {
+		return nil, err
+	}
+
+	rt := mi.vu.Runtime()
+	poll, isFn := goja.AssertFunction(urlOrFn)
+	if !isFn {
+		poll = func(goja.Value, ...goja.Value) (goja.Value, error) {
+			res, err := mi.defaultClient.Request(http.MethodGet, urlOrFn, goja.Undefined(), p.reqParams)
+			if err != nil {
+				return nil, err
+			}
+			return rt.ToValue(res), nil
+		}
+	}
+
+	ctx := mi.vu.Context()
+	start := time.Now()
+	deadline := start.Add(p.timeout)
+	interval := p.interval
+	for attempt := 1; ; attempt++ {
+		result, err := poll(goja.Undefined())
+		if err != nil {
+			return nil, err
+		}
+		done, err := predicate(goja.Undefined(), result)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		if done.ToBoolean() || !now.Before(deadline) {
+			tags := state.CloneTags()
+			for k, v := range p.tags {
+				tags[k] = v
+			}
+			tags["attempts"] = strconv.Itoa(attempt)
+			tags["result"] = "completed"
+			if !done.ToBoolean() {
+				tags["result"] = "timeout"
+			}
+			stats.PushIfNotDone(ctx, state.Samples, stats.Sample{
+				Metric: state.BuiltinMetrics.AsyncJobDuration,
+				Time:   now,
+				Tags:   stats.IntoSampleTags(&tags),
+				Value:  stats.D(now.Sub(start)),
+			})
+			if !done.ToBoolean() {
+				return nil, fmt.Errorf("polling timed out after %d attempts in %s", attempt, p.timeout)
+			}
+			return result, nil
+		}
+
+		wait := interval
+		if remaining := deadline.Sub(now); wait > remaining {
+			wait = remaining
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+		interval = time.Duration(float64(interval) * p.backoff)
+	}
+}
@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func getPollSamples(samples chan stats.SampleContainer) []stats.Sample {
+	var result []stats.Sample
+	for _, container := range stats.GetBufferedSamples(samples) {
+		for _, sample := range container.GetSamples() {
+			if sample.Metric.Name == metrics.AsyncJobDurationName {
+				result = append(result, sample)
+			}
+		}
+	}
+	return result
+}
+
+func TestPollUntil(t *testing.T) {
+	t.Parallel()
+	tb, _, samples, rt, _ := newRuntime(t)
+	sr := tb.Replacer.Replace
+
+	var polls int64
+	tb.Mux.HandleFunc("/job", func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&polls, 1) < 3 {
+			_, _ = fmt.Fprint(w, `{"status": "pending"}`)
+			return
+		}
+		_, _ = fmt.Fprint(w, `{"status": "done"}`)
+	})
+
+	t.Run("url", func(t *testing.T) {
+		start := time.Now()
+		v, err := rt.RunString(sr(`
+			var res = http.pollUntil("HTTPBIN_URL/job", (r) => r.json().status === "done", {
+				interval: "50ms", backoff: 2, timeout: "10s", tags: { job: "export" },
+			});
+			res.json().status;
+		`))
+		require.NoError(t, err)
+		assert.Equal(t, "done", v.String())
+		assert.EqualValues(t, 3, atomic.LoadInt64(&polls))
+		// The waits were 50ms and 100ms.
+		assert.GreaterOrEqual(t, time.Since(start), 150*time.Millisecond)
+
+		pollSamples := getPollSamples(samples)
+		require.Len(t, pollSamples, 1)
+		tags := pollSamples[0].Tags.CloneTags()
+		assert.Equal(t, "3", tags["attempts"])
+		assert.Equal(t, "completed", tags["result"])
+		assert.Equal(t, "export", tags["job"])
+		assert.GreaterOrEqual(t, pollSamples[0].Value, 150.0)
+	})
+
+	t.Run("function", func(t *testing.T) {
+		v, err := rt.RunString(`
+			var calls = 0;
+			http.pollUntil(() => ++calls, (n) => n === 4, { interval: "1ms" });
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), v.Export())
+		pollSamples := getPollSamples(samples)
+		require.Len(t, pollSamples, 1)
+		assert.Equal(t, "4", pollSamples[0].Tags.CloneTags()["attempts"])
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := rt.RunString(`http.pollUntil(() => 0, (n) => false, { interval: "20ms", timeout: "100ms" })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "polling timed out")
+		pollSamples := getPollSamples(samples)
+		require.Len(t, pollSamples, 1)
+		assert.Equal(t, "timeout", pollSamples[0].Tags.CloneTags()["result"])
+	})
+
+	t.Run("invalid params", func(t *testing.T) {
+		for _, params := range []string{
+			`{ interval: "soon" }`, `{ timeout: 0 }`, `{ backoff: 0.5 }`, `{ retries: 3 }`,
+		} {
+			_, err := rt.RunString(`http.pollUntil(() => 0, (n) => true, ` + params + `)`)
+			assert.Error(t, err, params)
+		}
+		_, err := rt.RunString(`http.pollUntil(() => 0)`)
+		assert.Error(t, err)
+	})
+}
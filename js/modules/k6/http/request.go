@@ -339,6 +339,16 @@ func (c *Client) parseRequest(
 				for _, key := range tagObj.Keys() {
 					result.Tags[key] = tagObj.Get(key).String()
 				}
+			case "metadata":
+				metadataV := params.Get(k)
+				if goja.IsUndefined(metadataV) || goja.IsNull(metadataV) {
+					continue
+				}
+				metadataObj := metadataV.ToObject(rt)
+				result.Metadata = make(map[string]string, len(metadataObj.Keys()))
+				for _, key := range metadataObj.Keys() {
+					result.Metadata[key] = metadataObj.Get(key).String()
+				}
 			case "auth":
 				result.Auth = params.Get(k).String()
 			case "timeout":
@@ -349,6 +359,14 @@ func (c *Client) parseRequest(
 				result.Timeout = t
 			case "throw":
 				result.Throw = params.Get(k).ToBoolean()
+			case "discardResponseMetrics":
+				result.DiscardResponseMetrics = params.Get(k).ToBoolean()
+			case "signal":
+				signal, err := common.GetAbortSignal(params.Get(k))
+				if err != nil {
+					return nil, err
+				}
+				result.Signal = signal.Context()
 			case "responseType":
 				responseType, err := httpext.ResponseTypeString(params.Get(k).String())
 				if err != nil {
@@ -48,6 +48,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"gopkg.in/guregu/null.v3"
 
+	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/metrics"
@@ -1109,6 +1110,33 @@ func TestRequestAndBatch(t *testing.T) {
 				}
 			})
 		})
+
+		t.Run("metadata", func(t *testing.T) {
+			_, err := rt.RunString(sr(`
+			var res = http.request("GET", "HTTPBIN_URL/headers", null, { metadata: { trace_id: "abc" } });
+			if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+			`))
+			assert.NoError(t, err)
+			bufSamples := stats.GetBufferedSamples(samples)
+			assertRequestMetricsEmitted(t, bufSamples, "GET", sr("HTTPBIN_URL/headers"), "", 200, "")
+			for _, sampleC := range bufSamples {
+				for _, sample := range sampleC.GetSamples() {
+					assert.Equal(t, map[string]string{"trace_id": "abc"}, sample.Metadata)
+					_, ok := sample.Tags.Get("trace_id")
+					assert.False(t, ok)
+				}
+			}
+		})
+
+		t.Run("discardResponseMetrics", func(t *testing.T) {
+			_, err := rt.RunString(sr(`
+			var res = http.request("GET", "HTTPBIN_URL/headers", null, { discardResponseMetrics: true });
+			if (res.status != 200) { throw new Error("wrong status: " + res.status); }
+			if (!(res.timings.duration > 0)) { throw new Error("no timings: " + res.timings.duration); }
+			`))
+			assert.NoError(t, err)
+			assert.Empty(t, stats.GetBufferedSamples(samples))
+		})
 	})
 
 	t.Run("GET", func(t *testing.T) {
@@ -1980,6 +2008,78 @@ func checkErrorCode(t testing.TB, tags *stats.SampleTags, code int, msg string)
 	}
 }
 
+func TestRequestAbortSignal(t *testing.T) {
+	t.Parallel()
+	tb, state, samples, rt, _ := newRuntime(t)
+	state.Options.Throw = null.BoolFrom(false)
+	sr := tb.Replacer.Replace
+
+	abortedCtx, cancel := context.WithCancel(context.Background())
+	aborted, _ := common.NewAbortSignal(rt, abortedCtx, cancel, "")
+	cancel()
+	require.NoError(t, rt.Set("aborted", aborted))
+	require.NoError(t, rt.Set("timeout", common.NewTimeoutAbortSignal(rt, 500*time.Millisecond)))
+
+	t.Run("aborted before", func(t *testing.T) {
+		hook := logtest.NewLocal(state.Logger)
+		defer hook.Reset()
+
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/get", { signal: aborted });
+			if (res.error_code !== 1060 || res.error !== "request aborted") {
+				throw new Error("wrong error: " + res.error_code + " " + res.error);
+			}
+		`))
+		require.NoError(t, err)
+		assert.Empty(t, stats.GetBufferedSamples(samples), "the request shouldn't be sent")
+		assert.Nil(t, hook.LastEntry())
+	})
+
+	t.Run("aborted during", func(t *testing.T) {
+		hook := logtest.NewLocal(state.Logger)
+		defer hook.Reset()
+
+		start := time.Now()
+		_, err := rt.RunString(sr(`
+			var res = http.get("HTTPBIN_URL/delay/10", { signal: timeout });
+			if (res.error_code !== 1060 || res.error !== "request aborted") {
+				throw new Error("wrong error: " + res.error_code + " " + res.error);
+			}
+		`))
+		require.NoError(t, err)
+		assert.WithinDuration(t, start.Add(500*time.Millisecond), time.Now(), 2*time.Second)
+		assert.Nil(t, hook.LastEntry())
+
+		var reqs []stats.Sample
+		for _, container := range stats.GetBufferedSamples(samples) {
+			for _, sample := range container.GetSamples() {
+				if sample.Metric.Name == metrics.HTTPReqsName {
+					reqs = append(reqs, sample)
+				}
+			}
+		}
+		require.Len(t, reqs, 1)
+		errorCode, _ := reqs[0].Tags.Get("error_code")
+		assert.Equal(t, "1060", errorCode)
+	})
+
+	t.Run("throw", func(t *testing.T) {
+		_, err := rt.RunString(sr(`http.get("HTTPBIN_URL/get", { signal: aborted, throw: true });`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "request aborted")
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		hook := logtest.NewLocal(state.Logger)
+		defer hook.Reset()
+
+		_, err := rt.RunString(sr(`http.get("HTTPBIN_URL/get", { signal: { aborted: true } });`))
+		require.NoError(t, err)
+		require.NotNil(t, hook.LastEntry())
+		assert.Contains(t, hook.LastEntry().Data["error"].(error).Error(), "the signal should be an AbortSignal")
+	})
+}
+
 func TestErrorCodes(t *testing.T) {
 	t.Parallel()
 	tb, state, samples, rt, _ := newRuntime(t)
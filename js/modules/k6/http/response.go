@@ -33,6 +33,7 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules/k6/html"
+	"go.k6.io/k6/lib/checkcapture"
 	"go.k6.io/k6/lib/netext/httpext"
 )
 
@@ -45,6 +46,60 @@ type Response struct {
 	validatedJSON bool
 }
 
+var _ checkcapture.Capturable = &Response{}
+
+// CheckFailureCapture implements checkcapture.Capturable, with the sensitive
+// headers and the cookies redacted and the bodies capped.
+func (res *Response) CheckFailureCapture() (request, response interface{}) {
+	if res.Response == nil {
+		return nil, nil
+	}
+	resp := *res.Response
+	resp.Request = nil
+	resp.Headers = make(map[string]string, len(res.Headers))
+	for name, value := range res.Headers {
+		if checkcapture.IsSensitiveHeader(name) {
+			value = checkcapture.Redacted
+		}
+		resp.Headers[name] = value
+	}
+	resp.Cookies = make(map[string][]*httpext.HTTPCookie, len(res.Cookies))
+	for name, cookies := range res.Cookies {
+		for _, c := range cookies {
+			redacted := *c
+			redacted.Value = checkcapture.Redacted
+			resp.Cookies[name] = append(resp.Cookies[name], &redacted)
+		}
+	}
+	switch body := res.Body.(type) {
+	case string:
+		resp.Body = checkcapture.CapBody(body)
+	case []byte:
+		resp.Body = checkcapture.CapBody(string(body))
+	}
+
+	if res.Request == nil {
+		return nil, &resp
+	}
+	req := *res.Request
+	req.Body = checkcapture.CapBody(req.Body)
+	req.Headers = make(map[string][]string, len(res.Request.Headers))
+	for name, values := range res.Request.Headers {
+		if checkcapture.IsSensitiveHeader(name) {
+			values = []string{checkcapture.Redacted}
+		}
+		req.Headers[name] = values
+	}
+	req.Cookies = make(map[string][]*httpext.HTTPRequestCookie, len(res.Request.Cookies))
+	for name, cookies := range res.Request.Cookies {
+		for _, c := range cookies {
+			req.Cookies[name] = append(req.Cookies[name],
+				&httpext.HTTPRequestCookie{Name: c.Name, Value: checkcapture.Redacted, Replace: c.Replace})
+		}
+	}
+	return &req, &resp
+}
+
 type jsonError struct {
 	line      int
 	character int
@@ -541,3 +541,21 @@ func deleteSystemTag(state *lib.State, tag string) {
 	}
 	state.Options.SystemTags = stats.ToSystemTagSet(tagsList)
 }
+
+func TestResponseCallbackCountsFailedRequests(t *testing.T) {
+	t.Parallel()
+	tb, state, _, _, _ := newRuntime(t) //nolint:dogsled
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 1, 1)
+	rt, _ := getTestModuleInstance(t, lib.WithExecutionState(tb.Context, es), state)
+
+	_, err = rt.RunString(tb.Replacer.Replace(`
+		http.get("HTTPBIN_URL/status/200");
+		http.get("HTTPBIN_URL/status/503");
+		http.get("HTTPBIN_URL/status/404", { responseCallback: http.expectedStatuses(404) });
+		http.get("HTTPBIN_URL/status/201", { responseCallback: http.expectedStatuses(200) });
+	`))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), es.GetFailedRequestCount())
+}
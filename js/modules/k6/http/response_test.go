@@ -25,11 +25,14 @@ import (
 	"fmt"
 	"net/http"
 	"net/url"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"go.k6.io/k6/lib/checkcapture"
+	"go.k6.io/k6/lib/netext/httpext"
 	"go.k6.io/k6/stats"
 )
 
@@ -411,3 +414,38 @@ func TestResponse(t *testing.T) {
 		})
 	})
 }
+
+func TestResponseCheckFailureCapture(t *testing.T) {
+	t.Parallel()
+	res := &Response{Response: &httpext.Response{
+		Status:  500,
+		Headers: map[string]string{"Set-Cookie": "session=secret", "Content-Type": "text/plain"},
+		Cookies: map[string][]*httpext.HTTPCookie{"session": {{Name: "session", Value: "secret"}}},
+		Body:    []byte(strings.Repeat("x", checkcapture.MaxBodySize+1)),
+		Request: &httpext.Request{
+			Headers: map[string][]string{"authorization": {"Bearer secret"}, "Accept": {"*/*"}},
+			Cookies: map[string][]*httpext.HTTPRequestCookie{"session": {{Name: "session", Value: "secret"}}},
+			Body:    "hello",
+		},
+	}}
+
+	request, response := res.CheckFailureCapture()
+	req, ok := request.(*httpext.Request)
+	require.True(t, ok)
+	resp, ok := response.(*httpext.Response)
+	require.True(t, ok)
+
+	assert.Equal(t, []string{checkcapture.Redacted}, req.Headers["authorization"])
+	assert.Equal(t, []string{"*/*"}, req.Headers["Accept"])
+	assert.Equal(t, checkcapture.Redacted, req.Cookies["session"][0].Value)
+	assert.Equal(t, "hello", req.Body)
+	assert.Equal(t, checkcapture.Redacted, resp.Headers["Set-Cookie"])
+	assert.Equal(t, "text/plain", resp.Headers["Content-Type"])
+	assert.Equal(t, checkcapture.Redacted, resp.Cookies["session"][0].Value)
+	assert.Nil(t, resp.Request)
+	assert.Equal(t, checkcapture.CapBody(strings.Repeat("x", checkcapture.MaxBodySize+1)), resp.Body)
+	assert.Less(t, len(resp.Body.(string)), checkcapture.MaxBodySize+20)
+	// the response itself isn't changed
+	assert.Equal(t, "secret", res.Cookies["session"][0].Value)
+	assert.Equal(t, []string{"Bearer secret"}, res.Request.Headers["authorization"])
+}
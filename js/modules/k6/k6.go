@@ -22,15 +22,23 @@
 package k6
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
 	"math/rand"
+	"strings"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/dop251/goja"
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/checkcapture"
 	"go.k6.io/k6/stats"
 )
 
@@ -40,6 +48,10 @@ var (
 
 	// ErrCheckInInitContext is returned when check() are using in the init context.
 	ErrCheckInInitContext = common.NewInitContextError("Using check() in the init context is not supported")
+
+	// ErrTransactionInInitContext is returned when transaction() is used in the init context.
+	ErrTransactionInInitContext = common.NewInitContextError(
+		"Using transaction() in the init context is not supported")
 )
 
 type (
@@ -73,15 +85,48 @@ func (*RootModule) NewModuleInstance(vu modules.VU) modules.Instance {
 func (mi *K6) Exports() modules.Exports {
 	return modules.Exports{
 		Named: map[string]interface{}{
-			"check":      mi.Check,
-			"fail":       mi.Fail,
-			"group":      mi.Group,
-			"randomSeed": mi.RandomSeed,
-			"sleep":      mi.Sleep,
+			"check":       mi.Check,
+			"fail":        mi.Fail,
+			"group":       mi.Group,
+			"randomSeed":  mi.RandomSeed,
+			"sleep":       mi.Sleep,
+			"transaction": mi.Transaction,
+
+			"AbortController": mi.XAbortController,
+			"AbortSignal":     map[string]interface{}{"timeout": mi.AbortSignalTimeout},
 		},
 	}
 }
 
+// XAbortController is the constructor of the controllers of the AbortSignals
+// which can be passed to the requests of k6/http and the connections of
+// k6/ws, like the AbortController of the web platform:
+//
+//	const controller = new AbortController();
+//	ws.connect(url, { signal: controller.signal }, ...);
+//	controller.abort("done");
+func (mi *K6) XAbortController(_ goja.ConstructorCall, rt *goja.Runtime) *goja.Object {
+	ctx, cancel := context.WithCancel(context.Background())
+	signalObj, signal := common.NewAbortSignal(rt, ctx, cancel, "")
+	o := rt.NewObject()
+	if err := o.DefineDataProperty("signal", signalObj, goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE); err != nil {
+		common.Throw(rt, err)
+	}
+	if err := o.Set("abort", signal.Abort); err != nil {
+		common.Throw(rt, err)
+	}
+	return o
+}
+
+// AbortSignalTimeout returns an AbortSignal which is aborted after the
+// timeout in milliseconds, like AbortSignal.timeout() of the web platform.
+func (mi *K6) AbortSignalTimeout(timeoutMs float64) (*goja.Object, error) {
+	if timeoutMs <= 0 || math.IsNaN(timeoutMs) {
+		return nil, fmt.Errorf("the timeout of an AbortSignal should be more than 0, not %v", timeoutMs)
+	}
+	return common.NewTimeoutAbortSignal(mi.vu.Runtime(), time.Duration(timeoutMs*float64(time.Millisecond))), nil
+}
+
 // Fail is a fancy way of saying `throw "something"`.
 func (*K6) Fail(msg string) (goja.Value, error) {
 	return goja.Undefined(), errors.New(msg)
@@ -105,7 +150,9 @@ func (mi *K6) RandomSeed(seed int64) {
 }
 
 // Group wraps a function call and executes it within the provided group name.
-func (mi *K6) Group(name string, fn goja.Callable) (goja.Value, error) {
+// The tags of the optional object after fn are set on all the samples emitted
+// inside the group.
+func (mi *K6) Group(name string, fn goja.Callable, extras ...goja.Value) (goja.Value, error) {
 	state := mi.vu.State()
 	if state == nil {
 		return nil, ErrGroupInInitContext
@@ -124,14 +171,50 @@ func (mi *K6) Group(name string, fn goja.Callable) (goja.Value, error) {
 	state.Group = g
 
 	shouldUpdateTag := state.Options.SystemTags.Has(stats.TagGroup)
+	shouldUpdateLevels := state.Options.SystemTags.Has(stats.TagGroupLevels)
+	shouldUpdateName := state.Options.SystemTags.Has(stats.TagGroupName)
 	if shouldUpdateTag {
 		state.Tags.Set("group", g.Path)
 	}
+	if shouldUpdateLevels {
+		state.Tags.SetGroupLevels(g.Path)
+	}
+	if shouldUpdateName {
+		state.Tags.SetGroupName(g.Path)
+	}
+
+	// The tags of the group apply to all the samples emitted inside it, the
+	// ones it replaced are set back and the others deleted when it's left
+	replaced := make(map[string]*string)
+	if len(extras) > 0 && extras[0] != nil && !goja.IsUndefined(extras[0]) && !goja.IsNull(extras[0]) {
+		obj := extras[0].ToObject(mi.vu.Runtime())
+		for _, k := range obj.Keys() {
+			if prev, ok := state.Tags.Get(k); ok {
+				replaced[k] = &prev
+			} else {
+				replaced[k] = nil
+			}
+			state.Tags.Set(k, obj.Get(k).String())
+		}
+	}
 	defer func() {
 		state.Group = old
+		for k, prev := range replaced {
+			if prev != nil {
+				state.Tags.Set(k, *prev)
+			} else {
+				state.Tags.Delete(k)
+			}
+		}
 		if shouldUpdateTag {
 			state.Tags.Set("group", old.Path)
 		}
+		if shouldUpdateLevels {
+			state.Tags.SetGroupLevels(old.Path)
+		}
+		if shouldUpdateName {
+			state.Tags.SetGroupName(old.Path)
+		}
 	}()
 
 	startTime := time.Now()
@@ -151,6 +234,65 @@ func (mi *K6) Group(name string, fn goja.Callable) (goja.Value, error) {
 	return ret, err
 }
 
+// Transaction calls fn and measures it as the named transaction, with a
+// transaction_duration sample and a transaction_failed sample, which is true
+// if fn has thrown. If fn returns a promise, like an async function does, the
+// transaction lasts until the promise is settled, and a promise is returned.
+func (mi *K6) Transaction(name string, fn goja.Callable) (goja.Value, error) {
+	state := mi.vu.State()
+	if state == nil {
+		return nil, ErrTransactionInInitContext
+	}
+	if fn == nil {
+		return nil, errors.New("transaction() requires a callback as a second argument")
+	}
+
+	startTime := time.Now()
+	ret, err := fn(goja.Undefined())
+	if err != nil {
+		mi.pushTransaction(state, name, startTime, false)
+		return nil, err
+	}
+	if _, isPromise := ret.Export().(*goja.Promise); !isPromise {
+		mi.pushTransaction(state, name, startTime, true)
+		return ret, nil
+	}
+
+	// The callbacks of then() are run on the event loop when the promise is
+	// settled, and the promise they return is settled like the original one.
+	rt := mi.vu.Runtime()
+	then, _ := goja.AssertFunction(ret.ToObject(rt).Get("then"))
+	onFulfilled := func(call goja.FunctionCall) goja.Value {
+		mi.pushTransaction(state, name, startTime, true)
+		return call.Argument(0)
+	}
+	onRejected := func(call goja.FunctionCall) goja.Value {
+		mi.pushTransaction(state, name, startTime, false)
+		panic(call.Argument(0)) // rethrown as the rejection of the returned promise
+	}
+	return then(ret, rt.ToValue(onFulfilled), rt.ToValue(onRejected))
+}
+
+func (mi *K6) pushTransaction(state *lib.State, name string, startTime time.Time, passed bool) {
+	t := time.Now()
+	tags := state.CloneTags()
+	tags["name"] = name
+	sampleTags := stats.IntoSampleTags(&tags)
+
+	failed := 1.0
+	if passed {
+		failed = 0
+	}
+	stats.PushIfNotDone(mi.vu.Context(), state.Samples, stats.ConnectedSamples{
+		Samples: []stats.Sample{
+			{Time: t, Metric: state.BuiltinMetrics.TransactionDuration, Tags: sampleTags, Value: stats.D(t.Sub(startTime))},
+			{Time: t, Metric: state.BuiltinMetrics.TransactionFailed, Tags: sampleTags, Value: failed},
+		},
+		Tags: sampleTags,
+		Time: t,
+	})
+}
+
 // Check will emit check metrics for the provided checks.
 //nolint:cyclop
 func (mi *K6) Check(arg0, checks goja.Value, extras ...goja.Value) (bool, error) {
@@ -216,7 +358,17 @@ func (mi *K6) Check(arg0, checks goja.Value, extras ...goja.Value) (bool, error)
 				stats.PushIfNotDone(ctx, state.Samples,
 					stats.Sample{Time: t, Metric: state.BuiltinMetrics.Checks, Tags: sampleTags, Value: 1})
 			} else {
-				atomic.AddInt64(&check.Fails, 1)
+				fails := atomic.AddInt64(&check.Fails, 1)
+				limit := lib.DefaultCheckFailureMessages
+				if state.Options.CheckFailureMessages.Valid {
+					limit = int(state.Options.CheckFailureMessages.Int64)
+				}
+				if fails <= int64(limit) {
+					check.AddFailureMessage(describeCheckFailure(arg0, exc), limit)
+				}
+				if state.CheckFailureCapturer != nil && arg0 != nil {
+					captureCheckFailure(state, check, t, arg0.Export())
+				}
 				stats.PushIfNotDone(ctx, state.Samples,
 					stats.Sample{Time: t, Metric: state.BuiltinMetrics.Checks, Tags: sampleTags, Value: 0})
 				// A single failure makes the return value false.
@@ -231,3 +383,61 @@ func (mi *K6) Check(arg0, checks goja.Value, extras ...goja.Value) (bool, error)
 
 	return succ, nil
 }
+
+// captureCheckFailure saves the request and response of a failed check, if the
+// checked value can be captured and the limits of the captures aren't reached.
+func captureCheckFailure(state *lib.State, check *lib.Check, t time.Time, value interface{}) {
+	path, err := state.CheckFailureCapturer.CaptureFailure(check.Name, checkcapture.Failure{
+		Check:     check.Path,
+		Time:      t,
+		VU:        state.VUID,
+		Iteration: state.Iteration,
+	}, value)
+	if err != nil {
+		state.Logger.WithError(err).Warn("Couldn't capture the failure of a check")
+		return
+	}
+	if path != "" {
+		check.AddFailureCapture(path)
+	}
+}
+
+// maxCheckFailureMessageLength is the maximum length of a failure message of
+// a check, so they stay short in the summary.
+const maxCheckFailureMessageLength = 200
+
+// describeCheckFailure describes why a check failed: the exception thrown by
+// it or the checked value, which for responses is their status and URL.
+func describeCheckFailure(val goja.Value, exc error) string {
+	var msg string
+	switch obj, isObj := val.(*goja.Object); {
+	case exc != nil:
+		msg = exc.Error()
+		if i := strings.IndexByte(msg, '\n'); i >= 0 {
+			msg = msg[:i]
+		}
+	case val == nil || goja.IsUndefined(val):
+		msg = "undefined"
+	case isObj && obj.Get("status") != nil && !goja.IsUndefined(obj.Get("status")):
+		msg = "status " + obj.Get("status").String()
+		if url := obj.Get("url"); url != nil && !goja.IsUndefined(url) {
+			msg += " from " + url.String()
+		}
+	case isObj:
+		if data, err := json.Marshal(obj.Export()); err == nil {
+			msg = string(data)
+		} else {
+			msg = obj.String()
+		}
+	default:
+		msg = val.String()
+	}
+	if len(msg) > maxCheckFailureMessageLength {
+		end := maxCheckFailureMessageLength
+		for !utf8.RuneStart(msg[end]) {
+			end--
+		}
+		msg = msg[:end] + "..."
+	}
+	return msg
+}
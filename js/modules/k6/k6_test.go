@@ -22,17 +22,23 @@ package k6
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dop251/goja"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modulestest"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/checkcapture"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/stats"
 )
@@ -151,6 +157,74 @@ func TestRandSeed(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestAbortController(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+
+	m, ok := New().NewModuleInstance(
+		&modulestest.VU{
+			RuntimeField: rt,
+			InitEnvField: &common.InitEnvironment{},
+			CtxField:     context.Background(),
+			StateField:   nil,
+		},
+	).(*K6)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("k6", m.Exports().Named))
+
+	t.Run("abort", func(t *testing.T) {
+		_, err := rt.RunString(`
+			var controller = new k6.AbortController();
+			var signal = controller.signal;
+			if (signal.aborted || signal.reason !== undefined) {
+				throw new Error("the signal shouldn't be aborted yet");
+			}
+			signal.throwIfAborted();
+			controller.abort("done");
+			controller.abort("again");
+			if (!signal.aborted || signal.reason !== "done") {
+				throw new Error("wrong signal: " + signal.aborted + " " + signal.reason);
+			}
+			try {
+				signal.throwIfAborted();
+				throw new Error("it should have thrown");
+			} catch (e) {
+				if (String(e).indexOf("the operation was aborted: done") < 0) {
+					throw e;
+				}
+			}
+		`)
+		require.NoError(t, err)
+	})
+
+	t.Run("abort without reason", func(t *testing.T) {
+		v, err := rt.RunString(`
+			var controller = new k6.AbortController();
+			controller.abort();
+			controller.signal.reason;
+		`)
+		require.NoError(t, err)
+		assert.Equal(t, "signal is aborted without reason", v.String())
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		signal, err := rt.RunString(`k6.AbortSignal.timeout(10)`)
+		require.NoError(t, err)
+		s, err := common.GetAbortSignal(signal)
+		require.NoError(t, err)
+		select {
+		case <-s.Context().Done():
+		case <-time.After(time.Second):
+			t.Fatal("the signal wasn't aborted")
+		}
+		assert.Equal(t, "signal timed out", s.Reason().String())
+
+		_, err = rt.RunString(`k6.AbortSignal.timeout(0)`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "the timeout of an AbortSignal should be more than 0")
+	})
+}
+
 func TestGroup(t *testing.T) {
 	t.Parallel()
 
@@ -201,6 +275,70 @@ func TestGroup(t *testing.T) {
 		assert.Equal(t, groupTag, root.Name)
 	})
 
+	t.Run("Levels", func(t *testing.T) {
+		t.Parallel()
+		rt, state, _ := setupGroupTest()
+		state.Options.SystemTags.Add(stats.TagGroupLevels)
+		samples := make(chan stats.SampleContainer, 10)
+		state.Samples = samples
+		require.NoError(t, rt.Set("fn", func() {
+			assert.Equal(t, map[string]string{
+				"group":    "::checkout::payment",
+				"group_l1": "checkout",
+				"group_l2": "payment",
+			}, state.Tags.Clone())
+		}))
+		_, err := rt.RunString(`k6.group("checkout", function() { k6.group("payment", fn) })`)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"group": ""}, state.Tags.Clone())
+
+		close(samples)
+		var sampleTags []map[string]string
+		for sc := range samples {
+			for _, sample := range sc.GetSamples() {
+				sampleTags = append(sampleTags, sample.Tags.CloneTags())
+			}
+		}
+		assert.Equal(t, []map[string]string{
+			{"group": "::checkout::payment", "group_l1": "checkout", "group_l2": "payment"},
+			{"group": "::checkout", "group_l1": "checkout"},
+		}, sampleTags)
+	})
+
+	t.Run("Tags", func(t *testing.T) {
+		t.Parallel()
+		rt, state, _ := setupGroupTest()
+		state.Options.SystemTags.Add(stats.TagGroupName)
+		state.Tags.Set("transaction", "none")
+		samples := make(chan stats.SampleContainer, 10)
+		state.Samples = samples
+		require.NoError(t, rt.Set("fn", func() {
+			assert.Equal(t, map[string]string{
+				"group":       "::checkout::payment",
+				"group_name":  "payment",
+				"transaction": "pay",
+				"step":        "2",
+			}, state.Tags.Clone())
+		}))
+		_, err := rt.RunString(`k6.group("checkout", function() {
+			k6.group("payment", fn, { transaction: "pay", step: 2 });
+		}, { transaction: "buy" })`)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"group": "", "transaction": "none"}, state.Tags.Clone())
+
+		close(samples)
+		var sampleTags []map[string]string
+		for sc := range samples {
+			for _, sample := range sc.GetSamples() {
+				sampleTags = append(sampleTags, sample.Tags.CloneTags())
+			}
+		}
+		assert.Equal(t, []map[string]string{
+			{"group": "::checkout::payment", "group_name": "payment", "transaction": "pay", "step": "2"},
+			{"group": "::checkout", "group_name": "checkout", "transaction": "buy"},
+		}, sampleTags)
+	})
+
 	t.Run("Invalid", func(t *testing.T) {
 		t.Parallel()
 		rt, _, _ := setupGroupTest()
@@ -227,6 +365,12 @@ func checkTestRuntime(t testing.TB) (*goja.Runtime, chan stats.SampleContainer,
 	}
 
 	state.BuiltinMetrics = metrics.RegisterBuiltinMetrics(metrics.NewRegistry())
+	setCheckTestModule(t, rt, state)
+
+	return rt, samples, state.BuiltinMetrics
+}
+
+func setCheckTestModule(t testing.TB, rt *goja.Runtime, state *lib.State) {
 	m, ok := New().NewModuleInstance(
 		&modulestest.VU{
 			RuntimeField: rt,
@@ -237,8 +381,6 @@ func checkTestRuntime(t testing.TB) (*goja.Runtime, chan stats.SampleContainer,
 	).(*K6)
 	require.True(t, ok)
 	require.NoError(t, rt.Set("k6", m.Exports().Named))
-
-	return rt, samples, state.BuiltinMetrics
 }
 
 func TestCheckObject(t *testing.T) {
@@ -367,6 +509,98 @@ func TestCheckThrows(t *testing.T) {
 	}
 }
 
+func TestCheckFailureMessages(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	state := &lib.State{
+		Group: root,
+		Options: lib.Options{
+			SystemTags:           &stats.DefaultSystemTagSet,
+			CheckFailureMessages: null.IntFrom(2),
+		},
+		Samples:        make(chan stats.SampleContainer, 1000),
+		Tags:           lib.NewTagMap(nil),
+		BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+	}
+	setCheckTestModule(t, rt, state)
+
+	_, err = rt.RunString(`
+		var res = { status: 500, url: "https://example.com/" };
+		k6.check(res, { "status is 200": (r) => r.status === 200 });
+		k6.check({ a: [1, 2] }, { "status is 200": (r) => r.status === 200 });
+		k6.check(res, { "status is 200": (r) => r.status === 200 });
+		k6.check("x".repeat(300), { "is short": (s) => s.length < 10 });
+		k6.check(res, { "is short": true });
+		k6.check(res, { "throws": () => { throw new Error("oops") } });
+	`)
+	require.EqualError(t, err, "Error: oops at throws (<eval>:8:43(3))")
+
+	checks := root.Checks
+	assert.Equal(t, int64(3), checks["status is 200"].Fails)
+	assert.Equal(t, []string{"status 500 from https://example.com/", `{"a":[1,2]}`},
+		checks["status is 200"].GetFailureMessages())
+	assert.Equal(t, []string{strings.Repeat("x", 200) + "..."}, checks["is short"].GetFailureMessages())
+	assert.Equal(t, []string{"Error: oops at throws (<eval>:8:43(3))"}, checks["throws"].GetFailureMessages())
+}
+
+// capturableValue is a checkcapture.Capturable like the responses of k6/http.
+type capturableValue struct {
+	status int
+}
+
+func (v capturableValue) CheckFailureCapture() (request, response interface{}) {
+	return map[string]string{"body": "hello"}, map[string]int{"status": v.status}
+}
+
+func TestCheckFailureCaptures(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	root, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	dir := t.TempDir()
+	state := &lib.State{
+		Group:                root,
+		Options:              lib.Options{SystemTags: &stats.DefaultSystemTagSet},
+		Samples:              make(chan stats.SampleContainer, 1000),
+		Tags:                 lib.NewTagMap(nil),
+		BuiltinMetrics:       metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		VUID:                 3,
+		Iteration:            7,
+		CheckFailureCapturer: checkcapture.New(dir, 1, 1<<20),
+	}
+	setCheckTestModule(t, rt, state)
+	require.NoError(t, rt.Set("res", capturableValue{status: 500}))
+
+	_, err = rt.RunString(`
+		k6.check(res, { "status is 200": (r) => r.status === 200 });
+		k6.check(res, { "status is 200": (r) => r.status === 200 });
+		k6.check({ status: 500 }, { "not a response": (r) => r.status === 200 });
+	`)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "0001-status_is_200.json")
+	assert.Equal(t, []string{path}, root.Checks["status is 200"].GetFailureCaptures())
+	assert.Nil(t, root.Checks["not a response"].GetFailureCaptures())
+
+	content, err := ioutil.ReadFile(path) //nolint:gosec
+	require.NoError(t, err)
+	var capture struct {
+		Check     string
+		VU        uint64
+		Iteration int64
+		Request   map[string]string
+		Response  map[string]int
+	}
+	require.NoError(t, json.Unmarshal(content, &capture))
+	assert.Equal(t, "::status is 200", capture.Check)
+	assert.Equal(t, uint64(3), capture.VU)
+	assert.Equal(t, int64(7), capture.Iteration)
+	assert.Equal(t, "hello", capture.Request["body"])
+	assert.Equal(t, 500, capture.Response["status"])
+}
+
 func TestCheckTypes(t *testing.T) {
 	t.Parallel()
 	templates := map[string]string{
@@ -500,3 +734,76 @@ func TestCheckTags(t *testing.T) {
 		}, sample.Tags.CloneTags())
 	}
 }
+
+func TestTransaction(t *testing.T) {
+	t.Parallel()
+
+	getTransactionSamples := func(t *testing.T, samples chan stats.SampleContainer) map[string]float64 {
+		t.Helper()
+		bufSamples := stats.GetBufferedSamples(samples)
+		require.Len(t, bufSamples, 1)
+		values := make(map[string]float64)
+		for _, sample := range bufSamples[0].GetSamples() {
+			assert.Equal(t, "login", sample.Tags.CloneTags()["name"])
+			values[sample.Metric.Name] = sample.Value
+		}
+		return values
+	}
+
+	t.Run("Sync", func(t *testing.T) {
+		t.Parallel()
+		rt, samples, _ := checkTestRuntime(t)
+		v, err := rt.RunString(`k6.transaction("login", () => { k6.sleep(0.05); return "token" })`)
+		require.NoError(t, err)
+		assert.Equal(t, "token", v.String())
+		values := getTransactionSamples(t, samples)
+		assert.GreaterOrEqual(t, values[metrics.TransactionDurationName], 50.0)
+		assert.Equal(t, 0.0, values[metrics.TransactionFailedName])
+	})
+
+	t.Run("SyncThrow", func(t *testing.T) {
+		t.Parallel()
+		rt, samples, _ := checkTestRuntime(t)
+		_, err := rt.RunString(`k6.transaction("login", () => { throw new Error("denied") })`)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "denied")
+		assert.Equal(t, 1.0, getTransactionSamples(t, samples)[metrics.TransactionFailedName])
+	})
+
+	t.Run("Promise", func(t *testing.T) {
+		t.Parallel()
+		rt, samples, _ := checkTestRuntime(t)
+		v, err := rt.RunString(`
+			var result;
+			k6.transaction("login", () => Promise.resolve("token")).then((v) => { result = v });
+			result;
+		`)
+		require.NoError(t, err)
+		assert.True(t, goja.IsUndefined(v), "the promise isn't settled synchronously")
+		v, err = rt.RunString(`result`)
+		require.NoError(t, err)
+		assert.Equal(t, "token", v.String())
+		assert.Equal(t, 0.0, getTransactionSamples(t, samples)[metrics.TransactionFailedName])
+	})
+
+	t.Run("PromiseRejected", func(t *testing.T) {
+		t.Parallel()
+		rt, samples, _ := checkTestRuntime(t)
+		_, err := rt.RunString(`
+			var result;
+			k6.transaction("login", () => Promise.reject("denied")).catch((e) => { result = e });
+		`)
+		require.NoError(t, err)
+		v, err := rt.RunString(`result`)
+		require.NoError(t, err)
+		assert.Equal(t, "denied", v.String())
+		assert.Equal(t, 1.0, getTransactionSamples(t, samples)[metrics.TransactionFailedName])
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		t.Parallel()
+		rt, _, _ := checkTestRuntime(t)
+		_, err := rt.RunString(`k6.transaction("login")`)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,74 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"errors"
+
+	"go.k6.io/k6/stats"
+)
+
+// list returns the descriptions of all the metrics of the test, the builtin
+// and the custom ones, sorted by name.
+func (mi *ModuleInstance) list() ([]map[string]interface{}, error) {
+	if mi.registry == nil {
+		return nil, errors.New("the metrics can't be listed without a registry")
+	}
+	all := mi.registry.All()
+	list := make([]map[string]interface{}, 0, len(all))
+	for _, m := range all {
+		list = append(list, mi.describe(m))
+	}
+	return list, nil
+}
+
+// get returns the description of the metric with the name, or null if there
+// isn't one.
+func (mi *ModuleInstance) get(name string) (interface{}, error) {
+	if mi.registry == nil {
+		return nil, errors.New("the metrics can't be listed without a registry")
+	}
+	m := mi.registry.Get(name)
+	if m == nil {
+		return nil, nil
+	}
+	return mi.describe(m), nil
+}
+
+// describe returns the name, the type, what the values of the metric are and
+// the sources of its thresholds, the ones declared with it and, outside of
+// the init context, the ones of the thresholds option.
+func (mi *ModuleInstance) describe(m *stats.Metric) map[string]interface{} {
+	thresholds := mi.registry.Thresholds()[m.Name]
+	if state := mi.vu.State(); state != nil {
+		thresholds.Merge(state.Options.Thresholds[m.Name])
+	}
+	sources := make([]string, 0, len(thresholds.Thresholds))
+	for _, threshold := range thresholds.Thresholds {
+		sources = append(sources, threshold.Source)
+	}
+	return map[string]interface{}{
+		"name":       m.Name,
+		"type":       m.Type.String(),
+		"contains":   m.Contains.String(),
+		"thresholds": sources,
+	}
+}
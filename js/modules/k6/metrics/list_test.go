@@ -0,0 +1,81 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"context"
+	"testing"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+func TestMetricsList(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	metrics.RegisterBuiltinMetrics(registry)
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+
+	_, err := rt.RunString(`
+		var trend = new metrics.Trend("my_trend", true, { thresholds: ["p(95)<500"] });
+	`)
+	require.NoError(t, err)
+	v, err := rt.RunString(`JSON.stringify(metrics.get("my_trend"))`)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"name": "my_trend", "type": "trend", "contains": "time", "thresholds": ["p(95)<500"]}`,
+		v.String())
+
+	v, err = rt.RunString(`metrics.list().map(function(m) { return m.name + ":" + m.type })`)
+	require.NoError(t, err)
+	list, ok := v.Export().([]interface{})
+	require.True(t, ok)
+	assert.Len(t, list, len(registry.All()))
+	assert.Contains(t, list, "http_req_duration:trend")
+	assert.Contains(t, list, "my_trend:trend")
+	for i := 1; i < len(list); i++ {
+		assert.Less(t, list[i-1], list[i], "the metrics are sorted by name")
+	}
+
+	thresholds := stats.NewThresholds([]string{"p(95)<500", "max<1000"})
+	mii.InitEnvField = nil
+	mii.StateField = &lib.State{Options: lib.Options{Thresholds: map[string]stats.Thresholds{"my_trend": thresholds}}}
+	v, err = rt.RunString(`[metrics.get("my_trend").thresholds, metrics.get("missing")]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{[]string{"p(95)<500", "max<1000"}, nil}, v.Export(),
+		"the thresholds of the option are added after the initialization")
+}
@@ -21,6 +21,7 @@
 package metrics
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
@@ -32,12 +33,83 @@ import (
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/stats"
 )
 
 type Metric struct {
 	metric *stats.Metric
 	vu     modules.VU
+	tags   map[string]interface{}
+}
+
+// metricOptions are the options of a custom metric, after its name and
+// whether it contains times.
+type metricOptions struct {
+	// Thresholds are added to the ones of the metric in the thresholds option.
+	Thresholds stats.Thresholds `json:"thresholds"`
+	// Buckets are the upper bounds of the buckets of a Histogram metric, the
+	// stats.DefaultHistogramBuckets if there are none.
+	Buckets []float64 `json:"buckets"`
+	// Unit is what the values are in, one of metricUnits, so they can be
+	// formatted in the summary and the outputs.
+	Unit string `json:"unit"`
+	// Tags are set on all the samples of the metric, unless the VU has tags
+	// with the same keys. Their values are strings, numbers or booleans.
+	Tags map[string]interface{} `json:"tags"`
+}
+
+// metricUnits are the units a custom metric can have, with the types of the
+// values they are for.
+//nolint:gochecknoglobals
+var metricUnits = map[string]stats.ValueType{
+	"ms":      stats.Time,
+	"bytes":   stats.Data,
+	"percent": stats.Percent,
+}
+
+// valueType returns the type of the values of the metric with the options,
+// the times if isTime is true.
+func (options metricOptions) valueType(name string, isTime bool) (stats.ValueType, error) {
+	if options.Unit == "" {
+		if isTime {
+			return stats.Time, nil
+		}
+		return stats.Default, nil
+	}
+	valueType, ok := metricUnits[options.Unit]
+	if !ok {
+		return 0, fmt.Errorf("the unit of the metric '%s' should be ms, bytes or percent, not '%s'", name, options.Unit)
+	}
+	if isTime && valueType != stats.Time {
+		return 0, fmt.Errorf("the metric '%s' contains times, so its unit can't be '%s'", name, options.Unit)
+	}
+	return valueType, nil
+}
+
+// parseMetricOptions returns the options of a custom metric from the JS
+// object v, with the thresholds in the same format as in options.thresholds.
+func parseMetricOptions(v goja.Value) (metricOptions, error) {
+	var options metricOptions
+	if v == nil || goja.IsUndefined(v) || goja.IsNull(v) {
+		return options, nil
+	}
+	data, err := json.Marshal(v.Export())
+	if err != nil {
+		return options, err
+	}
+	if err = json.Unmarshal(data, &options); err != nil {
+		return options, err
+	}
+	for k, v := range options.Tags {
+		switch v.(type) {
+		case string, float64, bool:
+		default:
+			return options, fmt.Errorf("the value of the tag '%s' should be a String, a Boolean or a Number", k)
+		}
+	}
+	return options, nil
 }
 
 // ErrMetricsAddInInitContext is error returned when adding to metric is done in the init context
@@ -49,16 +121,45 @@ func (mi *ModuleInstance) newMetric(call goja.ConstructorCall, t stats.MetricTyp
 		return nil, errors.New("metrics must be declared in the init context")
 	}
 	rt := mi.vu.Runtime()
-	c, _ := goja.AssertFunction(rt.ToValue(func(name string, isTime ...bool) (*goja.Object, error) {
-		valueType := stats.Default
-		if len(isTime) > 0 && isTime[0] {
-			valueType = stats.Time
+	c, _ := goja.AssertFunction(rt.ToValue(func(name string, args ...goja.Value) (*goja.Object, error) {
+		// The options can be right after the name, if the metric doesn't
+		// contain times, e.g. new Counter("orders", { tags: ... })
+		if len(args) > 0 {
+			if _, isObject := args[0].(*goja.Object); isObject {
+				args = []goja.Value{rt.ToValue(false), args[0]}
+			}
+		}
+		var options metricOptions
+		if len(args) > 1 {
+			var err error
+			if options, err = parseMetricOptions(args[1]); err != nil {
+				return nil, fmt.Errorf("invalid options of the metric '%s': %w", name, err)
+			}
+		}
+		valueType, err := options.valueType(name, len(args) > 0 && args[0].ToBoolean())
+		if err != nil {
+			return nil, err
+		}
+		var m *stats.Metric
+		switch {
+		case t == stats.Histogram && options.Buckets == nil:
+			m, err = initEnv.Registry.NewHistogram(name, stats.DefaultHistogramBuckets, valueType)
+		case t == stats.Histogram:
+			m, err = initEnv.Registry.NewHistogram(name, options.Buckets, valueType)
+		case options.Buckets != nil:
+			err = fmt.Errorf("the metric '%s' can't have buckets, only a Histogram can", name)
+		default:
+			m, err = initEnv.Registry.NewMetric(name, t, valueType)
 		}
-		m, err := initEnv.Registry.NewMetric(name, t, valueType)
 		if err != nil {
 			return nil, err
 		}
-		metric := &Metric{metric: m, vu: mi.vu}
+		if len(options.Thresholds.Thresholds) > 0 {
+			if err = initEnv.Registry.AddThresholds(name, options.Thresholds); err != nil {
+				return nil, err
+			}
+		}
+		metric := &Metric{metric: m, vu: mi.vu, tags: options.Tags}
 		o := rt.NewObject()
 		err = o.DefineDataProperty("name", rt.ToValue(name), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE)
 		if err != nil {
@@ -67,6 +168,13 @@ func (mi *ModuleInstance) newMetric(call goja.ConstructorCall, t stats.MetricTyp
 		if err = o.Set("add", rt.ToValue(metric.add)); err != nil {
 			return nil, err
 		}
+		if err = o.Set("reset", rt.ToValue(metric.reset)); err != nil {
+			return nil, err
+		}
+		err = defineValueAccessors(mi.vu, o, name, readableMethods[t], t == stats.Trend || t == stats.Histogram)
+		if err != nil {
+			return nil, err
+		}
 		return o, nil
 	}))
 	v, err := c(call.This, call.Arguments...)
@@ -92,7 +200,7 @@ func limitValue(v string) string {
 	}, string(omitMsg))
 }
 
-func (m Metric) add(v goja.Value, addTags ...map[string]string) (bool, error) {
+func (m Metric) add(v, addTags, extras goja.Value) (bool, error) {
 	state := m.vu.State()
 	if state == nil {
 		return false, ErrMetricsAddInInitContext
@@ -129,23 +237,167 @@ func (m Metric) add(v goja.Value, addTags ...map[string]string) (bool, error) {
 	}
 
 	tags := state.CloneTags()
-	for _, ts := range addTags {
-		for k, v := range ts {
-			tags[k] = v
+	typed := make(map[string]interface{})
+	for k, v := range m.tags {
+		if _, ok := tags[k]; ok {
+			continue // the tags of the VU take precedence
+		}
+		if str, ok := v.(string); ok {
+			tags[k] = str
+		} else {
+			typed[k] = v
+		}
+	}
+	if addTags != nil && !goja.IsUndefined(addTags) && !goja.IsNull(addTags) {
+		obj := addTags.ToObject(m.vu.Runtime())
+		for _, k := range obj.Keys() {
+			tagValue := obj.Get(k)
+			if value, ok := typedTagValue(tagValue); ok && state.Options.TypedTags.Bool {
+				typed[k] = value
+			} else {
+				tags[k] = tagValue.String()
+				delete(typed, k)
+			}
+		}
+	}
+	if !state.Options.TypedTags.Bool {
+		for k, v := range typed {
+			tags[k] = stats.FormatTagValue(v)
 		}
+		typed = nil
+	}
+	sampleTags, err := stats.IntoTypedSampleTags(&tags, typed)
+	if err != nil {
+		return raiseErr(err)
 	}
 
-	sample := stats.Sample{Time: time.Now(), Metric: m.metric, Value: vfloat, Tags: stats.IntoSampleTags(&tags)}
+	sample := stats.Sample{Time: time.Now(), Metric: m.metric, Value: vfloat, Tags: sampleTags}
+	if extras != nil && !goja.IsUndefined(extras) && !goja.IsNull(extras) {
+		obj := extras.ToObject(m.vu.Runtime())
+		for _, k := range obj.Keys() {
+			if k == "time" {
+				if sample.Time, err = sampleTime(m.vu, obj.Get(k)); err != nil {
+					return raiseErr(err)
+				}
+				continue
+			}
+			if sample.Metadata == nil {
+				sample.Metadata = make(map[string]string)
+			}
+			sample.Metadata[k] = obj.Get(k).String()
+		}
+	}
 	stats.PushIfNotDone(m.vu.Context(), state.Samples, sample)
 	return true, nil
 }
 
+// sampleTimeTolerance is how long before the start of the test, or after the
+// current time, the time of a sample can be, e.g. for the clock skew of the
+// system it was measured on.
+const sampleTimeTolerance = time.Minute
+
+// sampleTime returns the time of a sample from the time of the extras of add(),
+// a Date or the milliseconds since the Unix epoch, e.g. Date.now(). It should
+// be within the test run, from its start, or from now if it hasn't started, to
+// now. The samples don't have to be added in the order of their times, they're
+// aggregated in the order they're added, and the thresholds with a window or
+// over time buckets place them by their times.
+func sampleTime(vu modules.VU, v goja.Value) (time.Time, error) {
+	var t time.Time
+	switch value := v.Export().(type) {
+	case time.Time:
+		t = value
+	case int64:
+		t = time.Unix(0, value*int64(time.Millisecond))
+	case float64:
+		if !math.IsNaN(value) && !math.IsInf(value, 0) {
+			t = time.Unix(0, int64(value*float64(time.Millisecond)))
+		}
+	}
+	if t.IsZero() {
+		return t, fmt.Errorf("'%s' is an invalid time of a sample, a Date or a number of milliseconds is expected",
+			limitValue(v.String()))
+	}
+
+	now := time.Now()
+	start := now
+	if es := lib.GetExecutionState(vu.Context()); es != nil && es.HasStarted() {
+		start = es.GetStartTime()
+	}
+	if t.Before(start.Add(-sampleTimeTolerance)) || t.After(now.Add(sampleTimeTolerance)) {
+		return time.Time{}, fmt.Errorf("the time %s of a sample is outside of the test run, "+
+			"it should be from its start to now, give or take %s", t.Format(time.RFC3339Nano), sampleTimeTolerance)
+	}
+	return t, nil
+}
+
+// typedTagValue returns the value of a tag as a float64 or a bool, for the
+// number and boolean values, which are typed for the outputs supporting them
+// if the typedTags option is enabled.
+// The numbers which aren't finite are strings, like any other value.
+func typedTagValue(v goja.Value) (interface{}, bool) {
+	switch value := v.Export().(type) {
+	case bool:
+		return value, true
+	case int64:
+		return float64(value), true
+	case float64:
+		if math.IsNaN(value) || math.IsInf(value, 0) {
+			return nil, false
+		}
+		return value, true
+	default:
+		return nil, false
+	}
+}
+
+// ErrMetricsResetInInitContext is returned when a metric is reset in the init
+// context, as it has no values yet.
+var ErrMetricsResetInInitContext = common.NewInitContextError("Resetting metrics in the init context is not supported")
+
+// reset resets the values of the metric aggregated so far, for the summary and
+// the thresholds, after the samples the VU emitted before. Only the first VU of
+// a scenario which resets it does, so all of them can reset it at its start,
+// and resetting it again in the same scenario is ignored with a warning.
+func (m Metric) reset() error {
+	return resetMetric(m.vu, m.metric)
+}
+
+func resetMetric(vu modules.VU, m *stats.Metric) error {
+	state := vu.State()
+	if state == nil {
+		return ErrMetricsResetInInitContext
+	}
+	reset := stats.MetricReset{Metric: m, Time: time.Now(), VU: state.VUID}
+	if scenario := lib.GetScenarioState(vu.Context()); scenario != nil {
+		reset.Scenario = scenario.Name
+	}
+	stats.PushIfNotDone(vu.Context(), state.Samples, reset)
+	return nil
+}
+
+// resetByName resets the values of the registered metric with the name, e.g.
+// of a builtin one.
+func (mi *ModuleInstance) resetByName(name string) error {
+	if mi.registry == nil {
+		return errors.New("the metrics can't be reset without a registry")
+	}
+	m := mi.registry.Get(name)
+	if m == nil {
+		return fmt.Errorf("there's no metric '%s' to reset", name)
+	}
+	return resetMetric(mi.vu, m)
+}
+
 type (
 	// RootModule is the root metrics module
 	RootModule struct{}
 	// ModuleInstance represents an instance of the metrics module
 	ModuleInstance struct {
 		vu modules.VU
+		// registry has the metrics of the test, as the init environment
+		// with it is only there in the init context
+		registry *metrics.Registry
 	}
 )
 
@@ -156,7 +408,11 @@ var (
 
 // NewModuleInstance implements modules.Module interface
 func (*RootModule) NewModuleInstance(m modules.VU) modules.Instance {
-	return &ModuleInstance{vu: m}
+	mi := &ModuleInstance{vu: m}
+	if initEnv := m.InitEnv(); initEnv != nil {
+		mi.registry = initEnv.Registry
+	}
+	return mi
 }
 
 // New returns a new RootModule.
@@ -168,10 +424,14 @@ func New() *RootModule {
 func (mi *ModuleInstance) Exports() modules.Exports {
 	return modules.Exports{
 		Named: map[string]interface{}{
-			"Counter": mi.XCounter,
-			"Gauge":   mi.XGauge,
-			"Trend":   mi.XTrend,
-			"Rate":    mi.XRate,
+			"Counter":   mi.XCounter,
+			"Gauge":     mi.XGauge,
+			"Trend":     mi.XTrend,
+			"Rate":      mi.XRate,
+			"Histogram": mi.XHistogram,
+			"list":      mi.list,
+			"get":       mi.get,
+			"reset":     mi.resetByName,
 		},
 	}
 }
@@ -211,3 +471,12 @@ func (mi *ModuleInstance) XRate(call goja.ConstructorCall, rt *goja.Runtime) *go
 	}
 	return v
 }
+
+// XHistogram is a histogram constructor
+func (mi *ModuleInstance) XHistogram(call goja.ConstructorCall, rt *goja.Runtime) *goja.Object {
+	v, err := mi.newMetric(call, stats.Histogram)
+	if err != nil {
+		common.Throw(rt, err)
+	}
+	return v
+}
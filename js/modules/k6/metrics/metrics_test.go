@@ -25,11 +25,13 @@ import (
 	"fmt"
 	"io/ioutil"
 	"testing"
+	"time"
 
 	"github.com/dop251/goja"
 	"github.com/sirupsen/logrus"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/js/modulestest"
@@ -90,10 +92,11 @@ func (a addTest) run(t *testing.T) {
 func TestMetrics(t *testing.T) {
 	t.Parallel()
 	types := map[string]stats.MetricType{
-		"Counter": stats.Counter,
-		"Gauge":   stats.Gauge,
-		"Trend":   stats.Trend,
-		"Rate":    stats.Rate,
+		"Counter":   stats.Counter,
+		"Gauge":     stats.Gauge,
+		"Trend":     stats.Trend,
+		"Rate":      stats.Rate,
+		"Histogram": stats.Histogram,
 	}
 	values := map[string]addTestValue{
 		"Float":                 {JS: `2.5`, Float: 2.5},
@@ -213,6 +216,333 @@ func TestMetricGetName(t *testing.T) {
 	require.Contains(t, err.Error(), "TypeError: Cannot assign to read only property 'name'")
 }
 
+func TestMetricAddMetadata(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: metrics.NewRegistry()},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`var m = new metrics.Counter("my_metric")`)
+	require.NoError(t, err)
+
+	samples := make(chan stats.SampleContainer, 10)
+	mii.InitEnvField = nil
+	mii.StateField = &lib.State{Options: lib.Options{}, Samples: samples, Tags: lib.NewTagMap(nil)}
+	_, err = rt.RunString(`
+		m.add(1, {a: "1"}, {trace_id: "abc"});
+		m.add(2, {a: "2"});
+	`)
+	require.NoError(t, err)
+
+	sample := (<-samples).(stats.Sample)
+	assert.Equal(t, map[string]string{"trace_id": "abc"}, sample.Metadata)
+	assert.Equal(t, map[string]string{"a": "1"}, sample.Tags.CloneTags(), "the metadata aren't tags")
+	sample = (<-samples).(stats.Sample)
+	assert.Nil(t, sample.Metadata)
+}
+
+func TestMetricAddTime(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 1, 1)
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: metrics.NewRegistry()},
+		CtxField:     lib.WithExecutionState(context.Background(), es),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err = rt.RunString(`var m = new metrics.Trend("latency", true)`)
+	require.NoError(t, err)
+
+	samples := make(chan stats.SampleContainer, 10)
+	mii.InitEnvField = nil
+	mii.StateField = &lib.State{
+		Options: lib.Options{Throw: null.BoolFrom(true)},
+		Samples: samples,
+		Tags:    lib.NewTagMap(nil),
+	}
+	es.MarkStarted()
+	started := es.GetStartTime()
+	later := started.Add(time.Millisecond).Truncate(time.Millisecond)
+	earlier := started.Add(-30 * time.Second).Truncate(time.Millisecond)
+	_, err = rt.RunString(fmt.Sprintf(`
+		m.add(10, null, { time: %d, trace_id: "abc" });
+		m.add(20, null, { time: new Date(%d) });
+	`, later.UnixNano()/int64(time.Millisecond), earlier.UnixNano()/int64(time.Millisecond)))
+	require.NoError(t, err)
+
+	sample := (<-samples).(stats.Sample)
+	assert.True(t, later.Equal(sample.Time))
+	assert.Equal(t, map[string]string{"trace_id": "abc"}, sample.Metadata, "the time isn't metadata")
+	sample = (<-samples).(stats.Sample)
+	assert.True(t, earlier.Equal(sample.Time), "the samples can be added out of order, and a bit before the start")
+	assert.Nil(t, sample.Metadata)
+
+	_, err = rt.RunString(`m.add(30, null, { time: "yesterday" })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "'yesterday' is an invalid time of a sample")
+
+	_, err = rt.RunString(`m.add(30, null, { time: Date.now() + 3600000 })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is outside of the test run")
+	_, err = rt.RunString(fmt.Sprintf(`m.add(30, null, { time: %d })`,
+		started.Add(-2*time.Minute).UnixNano()/int64(time.Millisecond)))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "is outside of the test run")
+}
+
+func TestMetricTypedTags(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: metrics.NewRegistry()},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`var m = new metrics.Counter("my_metric")`)
+	require.NoError(t, err)
+
+	samples := make(chan stats.SampleContainer, 10)
+	mii.InitEnvField = nil
+	mii.StateField = &lib.State{Options: lib.Options{}, Samples: samples, Tags: lib.NewTagMap(nil)}
+	_, err = rt.RunString(`m.add(1, {name: "a", items: 3, ratio: 0.5, cached: false, nan: NaN})`)
+	require.NoError(t, err)
+
+	sample := (<-samples).(stats.Sample)
+	assert.Equal(t, map[string]interface{}{
+		"name": "a", "items": "3", "ratio": "0.5", "cached": "false", "nan": "NaN",
+	}, sample.Tags.CloneTypedTags(), "the tags are strings unless the typedTags option is enabled")
+
+	mii.StateField.Options.TypedTags = null.BoolFrom(true)
+	_, err = rt.RunString(`m.add(1, {name: "a", items: 3, ratio: 0.5, cached: false, nan: NaN})`)
+	require.NoError(t, err)
+
+	sample = (<-samples).(stats.Sample)
+	assert.Equal(t, map[string]interface{}{
+		"name": "a", "items": 3.0, "ratio": 0.5, "cached": false, "nan": "NaN",
+	}, sample.Tags.CloneTypedTags())
+	assert.Equal(t, map[string]string{
+		"name": "a", "items": "3", "ratio": "0.5", "cached": "false", "nan": "NaN",
+	}, sample.Tags.CloneTags(), "the string forms of the values are matched by the sub-metrics")
+}
+
+func TestMetricDefaultTags(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: metrics.NewRegistry()},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`
+		var orders = new metrics.Counter("orders", { tags: { team: "checkout", scenario: "none", priority: 1 } });
+	`)
+	require.NoError(t, err)
+	_, err = rt.RunString(`new metrics.Counter("other", { tags: { team: ["a"] } })`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "the value of the tag 'team' should be a String, a Boolean or a Number")
+
+	samples := make(chan stats.SampleContainer, 10)
+	mii.InitEnvField = nil
+	mii.StateField = &lib.State{
+		Options: lib.Options{TypedTags: null.BoolFrom(true)},
+		Samples: samples,
+		Tags:    lib.NewTagMap(map[string]string{"scenario": "default"}),
+	}
+	_, err = rt.RunString(`
+		orders.add(1);
+		orders.add(1, { team: "payments", priority: "high" });
+	`)
+	require.NoError(t, err)
+
+	sample := (<-samples).(stats.Sample)
+	assert.Equal(t, map[string]interface{}{"team": "checkout", "scenario": "default", "priority": 1.0},
+		sample.Tags.CloneTypedTags(), "the tags of the VU take precedence")
+	sample = (<-samples).(stats.Sample)
+	assert.Equal(t, map[string]interface{}{"team": "payments", "scenario": "default", "priority": "high"},
+		sample.Tags.CloneTypedTags(), "the tags of add() take precedence")
+}
+
+func TestMetricReset(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`var m = new metrics.Counter("my_metric")`)
+	require.NoError(t, err)
+	_, err = rt.RunString(`m.reset()`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+
+	samples := make(chan stats.SampleContainer, 10)
+	mii.InitEnvField = nil
+	mii.StateField = &lib.State{Options: lib.Options{}, Samples: samples, Tags: lib.NewTagMap(nil)}
+	mii.CtxField = lib.WithScenarioState(context.Background(), &lib.ScenarioState{Name: "phase_two"})
+	_, err = rt.RunString(`m.add(1); m.reset(); metrics.reset("my_metric");`)
+	require.NoError(t, err)
+
+	assert.IsType(t, stats.Sample{}, <-samples)
+	for i := 0; i < 2; i++ {
+		reset, ok := (<-samples).(stats.MetricReset)
+		require.True(t, ok)
+		assert.Equal(t, registry.Get("my_metric"), reset.Metric)
+		assert.Equal(t, "phase_two", reset.Scenario)
+	}
+
+	_, err = rt.RunString(`metrics.reset("missing")`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "there's no metric 'missing' to reset")
+}
+
+func TestMetricThresholds(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`
+		new metrics.Trend("login_time", true, {
+			thresholds: ["p(95)<800", {threshold: "avg<500", abortOnFail: true}],
+		});
+		new metrics.Counter("logins", false, {});
+		new metrics.Rate("login_failed");
+	`)
+	require.NoError(t, err)
+
+	thresholds := registry.Thresholds()
+	require.Len(t, thresholds, 1)
+	require.Len(t, thresholds["login_time"].Thresholds, 2)
+	assert.Equal(t, "p(95)<800", thresholds["login_time"].Thresholds[0].Source)
+	assert.Equal(t, "avg<500", thresholds["login_time"].Thresholds[1].Source)
+	assert.True(t, thresholds["login_time"].Thresholds[1].AbortOnFail)
+	assert.Equal(t, stats.Time, registry.Get("login_time").Contains)
+
+	_, err = rt.RunString(`new metrics.Trend("bad_options", true, {thresholds: "p(95)<800"})`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid options of the metric 'bad_options'")
+}
+
+func TestMetricUnits(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`
+		new metrics.Counter("uploaded", false, { unit: "bytes" });
+		new metrics.Gauge("cpu", false, { unit: "percent" });
+		new metrics.Trend("login_time", true, { unit: "ms" });
+		new metrics.Trend("render_time", false, { unit: "ms" });
+		new metrics.Trend("items", false, {});
+	`)
+	require.NoError(t, err)
+	for name, valueType := range map[string]stats.ValueType{
+		"uploaded": stats.Data, "cpu": stats.Percent, "login_time": stats.Time, "render_time": stats.Time,
+		"items": stats.Default,
+	} {
+		assert.Equal(t, valueType, registry.Get(name).Contains, name)
+	}
+
+	for src, errStr := range map[string]string{
+		`new metrics.Counter("bad", false, { unit: "MB" })`:      "the unit of the metric 'bad' should be ms, bytes or percent",
+		`new metrics.Trend("bad", true, { unit: "bytes" })`:      "contains times, so its unit can't be 'bytes'",
+		`new metrics.Counter("uploaded", false, { unit: "ms" })`: "already exists but with a value type",
+	} {
+		_, err = rt.RunString(src)
+		require.Error(t, err, src)
+		assert.Contains(t, err.Error(), errStr, src)
+	}
+}
+
+func TestMetricHistogramBuckets(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	registry := metrics.NewRegistry()
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: registry},
+		CtxField:     context.Background(),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err := rt.RunString(`
+		new metrics.Histogram("login_time", true, { buckets: [100, 500, 1000], thresholds: ["p(95)<800"] });
+		new metrics.Histogram("items");
+	`)
+	require.NoError(t, err)
+
+	sink, ok := registry.Get("login_time").Sink.(*stats.HistogramSink)
+	require.True(t, ok)
+	assert.Equal(t, []float64{100, 500, 1000}, sink.Buckets)
+	assert.Equal(t, stats.Time, registry.Get("login_time").Contains)
+	assert.Len(t, registry.Thresholds()["login_time"].Thresholds, 1)
+	sink, ok = registry.Get("items").Sink.(*stats.HistogramSink)
+	require.True(t, ok)
+	assert.Equal(t, stats.DefaultHistogramBuckets, sink.Buckets)
+
+	for src, errStr := range map[string]string{
+		`new metrics.Histogram("login_time", true, { buckets: [100, 1000] })`: "already exists but with the buckets",
+		`new metrics.Histogram("bad", false, { buckets: [] })`:                "a histogram needs at least one bucket",
+		`new metrics.Histogram("bad", false, { buckets: ["a"] })`:             "invalid options of the metric 'bad'",
+		`new metrics.Trend("trend", false, { buckets: [1, 2] })`:              "only a Histogram can",
+	} {
+		_, err = rt.RunString(src)
+		require.Error(t, err, src)
+		assert.Contains(t, err.Error(), errStr, src)
+	}
+}
+
 func TestMetricDuplicates(t *testing.T) {
 	t.Parallel()
 	rt := goja.New()
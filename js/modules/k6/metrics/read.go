@@ -0,0 +1,112 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"errors"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modules"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/stats"
+)
+
+// ErrMetricsReadInInitContext is returned when the value of a metric is read
+// in the init context, as the test hasn't started yet.
+var ErrMetricsReadInInitContext = common.NewInitContextError(
+	"Reading the values of metrics in the init context is not supported")
+
+// readableMethods are the aggregation methods of the values of the metrics of
+// every type which can be read, the ones of their thresholds but for the
+// percentiles, which are read with p().
+//nolint:gochecknoglobals
+var readableMethods = map[stats.MetricType][]string{
+	stats.Counter:   {"count", "rate", "rate_per_second"},
+	stats.Gauge:     {"value", "ewma"},
+	stats.Rate:      {"rate"},
+	stats.Trend:     {"avg", "min", "med", "max"},
+	stats.Histogram: {"count", "avg", "min", "med", "max"},
+}
+
+// readValue returns the value of the aggregation method of the metric with the
+// name, as it's aggregated so far for the thresholds.
+func readValue(vu modules.VU, name, method string) (float64, error) {
+	if vu.State() == nil {
+		return 0, ErrMetricsReadInInitContext
+	}
+	es := lib.GetExecutionState(vu.Context())
+	if es == nil || es.Metrics == nil {
+		return 0, errors.New("the values of the metrics can't be read without a running test")
+	}
+	return es.Metrics.MetricValue(name, method)
+}
+
+// defineValueAccessors defines a getter on o for every one of the methods,
+// which returns the current value of the aggregation method of the metric with
+// the name, and a p() function for its percentiles if withPercentiles is true.
+func defineValueAccessors(
+	vu modules.VU, o *goja.Object, name string, methods []string, withPercentiles bool,
+) error {
+	rt := vu.Runtime()
+	for _, method := range methods {
+		method := method
+		getter := rt.ToValue(func() (float64, error) {
+			return readValue(vu, name, method)
+		})
+		if err := o.DefineAccessorProperty(method, getter, nil, goja.FLAG_FALSE, goja.FLAG_TRUE); err != nil {
+			return err
+		}
+	}
+	if !withPercentiles {
+		return nil
+	}
+	return o.Set("p", func(pct goja.Value) (float64, error) {
+		return readValue(vu, name, "p("+pct.String()+")")
+	})
+}
+
+// NewMetricReader returns a JS object with the current values of the metric
+// with the name, of all the aggregation methods of any type of metric, and a
+// p() function for the percentiles, e.g. for the metrics of k6/execution.
+func NewMetricReader(vu modules.VU, name string) (*goja.Object, error) {
+	o := vu.Runtime().NewObject()
+	seen := make(map[string]bool)
+	var methods []string
+	for _, typ := range []stats.MetricType{stats.Counter, stats.Gauge, stats.Rate, stats.Trend, stats.Histogram} {
+		for _, method := range readableMethods[typ] {
+			if !seen[method] {
+				seen[method] = true
+				methods = append(methods, method)
+			}
+		}
+	}
+	if err := o.DefineDataProperty(
+		"name", vu.Runtime().ToValue(name), goja.FLAG_FALSE, goja.FLAG_FALSE, goja.FLAG_TRUE,
+	); err != nil {
+		return nil, err
+	}
+	if err := defineValueAccessors(vu, o, name, methods, true); err != nil {
+		return nil, err
+	}
+	return o, nil
+}
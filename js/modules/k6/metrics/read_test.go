@@ -0,0 +1,126 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dop251/goja"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/js/modulestest"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+// sinkReader reads the values of the metrics from their sinks, over 10s.
+type sinkReader map[string]stats.Sink
+
+func (r sinkReader) MetricValue(name, method string) (float64, error) {
+	sink, ok := r[name]
+	if !ok {
+		return 0, nil
+	}
+	sink.Calc()
+	return stats.AggregatedValue(sink, method, 10*time.Second)
+}
+
+func TestMetricValues(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 1, 1)
+	mii := &modulestest.VU{
+		RuntimeField: rt,
+		InitEnvField: &common.InitEnvironment{Registry: metrics.NewRegistry()},
+		CtxField:     lib.WithExecutionState(context.Background(), es),
+	}
+	m, ok := New().NewModuleInstance(mii).(*ModuleInstance)
+	require.True(t, ok)
+	require.NoError(t, rt.Set("metrics", m.Exports().Named))
+	_, err = rt.RunString(`
+		var counter = new metrics.Counter("my_counter");
+		var trend = new metrics.Trend("my_trend");
+		var rate = new metrics.Rate("my_rate");
+	`)
+	require.NoError(t, err)
+	_, err = rt.RunString(`counter.count`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "not supported")
+
+	trendSink := &stats.TrendSink{}
+	for i := 1; i <= 100; i++ {
+		trendSink.Add(stats.Sample{Value: float64(i)})
+	}
+	es.Metrics = sinkReader{
+		"my_counter": &stats.CounterSink{Value: 50},
+		"my_trend":   trendSink,
+	}
+	mii.InitEnvField = nil
+	mii.StateField = &lib.State{Options: lib.Options{}, Tags: lib.NewTagMap(nil)}
+
+	v, err := rt.RunString(`[counter.count, counter.rate, trend.avg, trend.max, trend.p(95), rate.rate]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{int64(50), int64(5), 50.5, int64(100), 95.05, int64(0)}, v.Export(),
+		"a metric without samples has values of 0")
+	v, err = rt.RunString(`[typeof counter.avg, typeof counter.p]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"undefined", "undefined"}, v.Export())
+
+	v, err = rt.RunString(`counter.rate = 1; counter.rate`)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), v.Export(), "the values are read-only")
+}
+
+func TestMetricReader(t *testing.T) {
+	t.Parallel()
+	rt := goja.New()
+	rt.SetFieldNameMapper(common.FieldNameMapper{})
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 1, 1)
+	es.Metrics = sinkReader{"http_req_failed": &stats.RateSink{Trues: 1, Total: 4}}
+	vu := &modulestest.VU{
+		RuntimeField: rt,
+		CtxField:     lib.WithExecutionState(context.Background(), es),
+		StateField:   &lib.State{},
+	}
+	o, err := NewMetricReader(vu, "http_req_failed")
+	require.NoError(t, err)
+	require.NoError(t, rt.Set("failed", o))
+
+	v, err := rt.RunString(`[failed.name, failed.rate]`)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{"http_req_failed", 0.25}, v.Export())
+
+	_, err = rt.RunString(`failed.avg`)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `the aggregation method "avg" isn't supported by the metric`)
+}
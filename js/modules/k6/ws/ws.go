@@ -40,6 +40,7 @@ import (
 	"go.k6.io/k6/js/modules"
 	httpModule "go.k6.io/k6/js/modules/k6/http"
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/netext"
 	"go.k6.io/k6/stats"
 )
 
@@ -157,6 +158,7 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 
 	tags := state.CloneTags()
 	jar := state.CookieJar
+	var signal *common.AbortSignal
 
 	// Parse the optional second argument (params)
 	if !goja.IsUndefined(paramsV) && !goja.IsNull(paramsV) {
@@ -195,6 +197,11 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 				if v, ok := jarV.Export().(*httpModule.CookieJar); ok {
 					jar = v.Jar
 				}
+			case "signal":
+				var err error
+				if signal, err = common.GetAbortSignal(params.Get(k)); err != nil {
+					return nil, err
+				}
 			case "compression":
 				// deflate compression algorithm is supported - as defined in RFC7692
 				// compression here relies on the implementation in gorilla/websocket package, usage is
@@ -241,6 +248,16 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 		wsd.Jar = nil
 	}
 
+	// The connection is closed when the signal is aborted, or it isn't even
+	// opened if it was aborted before
+	var aborted <-chan struct{}
+	if signal != nil {
+		if signal.Aborted() {
+			return nil, fmt.Errorf("the connection to %s was aborted: %s", url, signal.Reason().String())
+		}
+		aborted = signal.Context().Done()
+	}
+
 	start := time.Now()
 	conn, httpResponse, connErr := wsd.DialContext(ctx, url, header)
 	connectionEnd := time.Now()
@@ -251,6 +268,11 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 			tags["ip"] = ip
 		}
 	}
+	if state.Options.SystemTags.Has(stats.TagIPFamily) && conn.RemoteAddr() != nil {
+		if family := netext.IPFamily(conn.RemoteAddr()); family != "" {
+			tags[stats.TagIPFamily.String()] = family
+		}
+	}
 
 	if httpResponse != nil {
 		if state.Options.SystemTags.Has(stats.TagStatus) {
@@ -392,6 +414,12 @@ func (mi *WS) Connect(url string, args ...goja.Value) (*WSHTTPResponse, error) {
 			// socket events will not be forwarded to the VU
 			_ = socket.closeConnection(websocket.CloseGoingAway)
 
+		case <-aborted:
+			// The script aborted the connection with the signal, the close
+			// event is emitted like for socket.close()
+			aborted = nil
+			_ = socket.closeConnection(websocket.CloseNormalClosure)
+
 		case <-socket.done:
 			// This is the final exit point normally triggered by closeConnection
 			return wsResponse, nil
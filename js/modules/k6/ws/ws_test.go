@@ -765,7 +765,7 @@ func TestSystemTags(t *testing.T) {
 
 	// TODO: test for actual tag values after removing the dependency on the
 	// external service demos.kaazing.com (https://github.com/k6io/k6/issues/537)
-	testedSystemTags := []string{"group", "status", "subproto", "url", "ip"}
+	testedSystemTags := []string{"group", "status", "subproto", "url", "ip", "ip_family"}
 
 	samples := make(chan stats.SampleContainer, 1000)
 	state := &lib.State{
@@ -1290,3 +1290,47 @@ func TestCookieJar(t *testing.T) {
 
 	assertSessionMetricsEmitted(t, stats.GetBufferedSamples(ts.samples), "", sr("WSBIN_URL/ws-echo-someheader"), statusProtocolSwitch, "")
 }
+
+func TestAbortSignal(t *testing.T) {
+	t.Parallel()
+	ts := newTestState(t)
+	sr := ts.tb.Replacer.Replace
+
+	newSignal := func() *goja.Object {
+		ctx, cancel := context.WithCancel(context.Background())
+		o, _ := common.NewAbortSignal(ts.rt, ctx, cancel, "")
+		return o
+	}
+	require.NoError(t, ts.rt.Set("newSignal", newSignal))
+	require.NoError(t, ts.rt.Set("abort", func(v goja.Value) {
+		s, err := common.GetAbortSignal(v)
+		require.NoError(t, err)
+		s.Abort(goja.Undefined())
+	}))
+
+	t.Run("aborted during", func(t *testing.T) {
+		_, err := ts.rt.RunString(sr(`
+		var closed = false;
+		var signal = newSignal();
+		var res = ws.connect("WSBIN_URL/ws-echo", { signal: signal }, function(socket){
+			socket.on("close", function() { closed = true; });
+			socket.setTimeout(function() { abort(signal); }, 100);
+		});
+		if (res.status != 101) { throw new Error("connection failed with status: " + res.status); }
+		if (!closed) { throw new Error("the close event wasn't emitted"); }
+		`))
+		require.NoError(t, err)
+	})
+
+	t.Run("aborted before", func(t *testing.T) {
+		_, err := ts.rt.RunString(sr(`
+		var signal = newSignal();
+		abort(signal);
+		ws.connect("WSBIN_URL/ws-echo", { signal: signal }, function(socket){
+			throw new Error("the connection shouldn't be opened");
+		});
+		`))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "was aborted: signal is aborted without reason")
+	})
+}
@@ -35,6 +35,7 @@ import (
 	"runtime/debug"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/dop251/goja"
@@ -48,6 +49,7 @@ import (
 	"go.k6.io/k6/errext/exitcodes"
 	"go.k6.io/k6/js/common"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/checkcapture"
 	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/lib/netext"
@@ -74,6 +76,11 @@ type Runner struct {
 
 	console   *console
 	setupData []byte
+
+	checkCapturer *checkcapture.Capturer
+
+	// interfaces are the network interfaces the VUs connect from, by name
+	interfaces sync.Map
 }
 
 // New returns a new Runner for the provide source
@@ -186,6 +193,8 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		Blacklist:        r.Bundle.Options.BlacklistIPs,
 		BlockedHostnames: r.Bundle.Options.BlockedHostnames.Trie,
 		Hosts:            r.Bundle.Options.Hosts,
+		Socket:           r.Bundle.Options.Socket,
+		Interface:        r.getInterface(r.Bundle.Options.Interface.String),
 	}
 	if r.Bundle.Options.LocalIPs.Valid {
 		var ipIndex uint64
@@ -239,6 +248,7 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		BPool:          bpool.NewBufferPool(100),
 		Samples:        samplesOut,
 		scenarioIter:   make(map[string]uint64),
+		prewarmed:      make(map[string]bool),
 		moduleVUImpl:   moduleVUImpl,
 	}
 
@@ -257,6 +267,8 @@ func (r *Runner) newVU(idLocal, idGlobal uint64, samplesOut chan<- stats.SampleC
 		Tags:           lib.NewTagMap(vu.Runner.Bundle.Options.RunTags.CloneTags()),
 		Group:          r.defaultGroup,
 		BuiltinMetrics: r.builtinMetrics,
+
+		CheckFailureCapturer: r.checkCapturer,
 	}
 	vu.moduleVUImpl.state = vu.state
 	_ = vu.Runtime.Set("console", vu.Console)
@@ -398,9 +410,18 @@ func (r *Runner) HandleSummary(ctx context.Context, summary *lib.Summary) (map[s
 		return nil, fmt.Errorf("unexpected error did not get a callable summary wrapper")
 	}
 
+	summaryExports, err := r.Bundle.RuntimeOptions.GetSummaryExports()
+	if err != nil {
+		return nil, err
+	}
+	summaryExportsForJS := make([]map[string]string, len(summaryExports))
+	for i, export := range summaryExports {
+		summaryExportsForJS[i] = map[string]string{"format": export.Format, "path": export.Path}
+	}
+
 	wrapperArgs := []goja.Value{
 		handleSummaryFn,
-		vu.Runtime.ToValue(r.Bundle.RuntimeOptions.SummaryExport.String),
+		vu.Runtime.ToValue(summaryExportsForJS),
 		vu.Runtime.ToValue(summaryDataForJS),
 	}
 	rawResult, _, _, err := vu.runFn(ctx, false, handleSummaryWrapper, nil, wrapperArgs...)
@@ -441,6 +462,18 @@ func (r *Runner) SetOptions(opts lib.Options) error {
 		r.console = c
 	}
 
+	r.checkCapturer = nil
+	if dir := opts.CheckFailureCaptureDir; dir.Valid && dir.String != "" {
+		maxCount, maxSize := int64(lib.DefaultCheckFailureCaptures), int64(lib.DefaultCheckFailureCaptureSize)
+		if opts.CheckFailureCaptures.Valid {
+			maxCount = opts.CheckFailureCaptures.Int64
+		}
+		if opts.CheckFailureCaptureSize.Valid {
+			maxSize = opts.CheckFailureCaptureSize.Int64
+		}
+		r.checkCapturer = checkcapture.New(dir.String, maxCount, maxSize)
+	}
+
 	// FIXME: Resolver probably shouldn't be reset here...
 	// It's done because the js.Runner is created before the full
 	// configuration has been processed, at which point we don't have
@@ -458,6 +491,16 @@ func (r *Runner) SetOptions(opts lib.Options) error {
 	return nil
 }
 
+// getInterface returns the network interface with the name, which is shared by
+// all the VUs, or nil if the name is empty.
+func (r *Runner) getInterface(name string) *netext.Interface {
+	if name == "" {
+		return nil
+	}
+	iface, _ := r.interfaces.LoadOrStore(name, netext.NewInterface(name))
+	return iface.(*netext.Interface) //nolint:forcetypeassert
+}
+
 func (r *Runner) setResolver(dns types.DNSConfig) error {
 	ttl, err := parseTTL(dns.TTL.String)
 	if err != nil {
@@ -533,6 +576,12 @@ func (r *Runner) runPart(ctx context.Context, out chan<- stats.SampleContainer,
 	if r.Bundle.Options.SystemTags.Has(stats.TagGroup) {
 		vu.state.Tags.Set("group", group.Path)
 	}
+	if r.Bundle.Options.SystemTags.Has(stats.TagGroupLevels) {
+		vu.state.Tags.SetGroupLevels(group.Path)
+	}
+	if r.Bundle.Options.SystemTags.Has(stats.TagGroupName) {
+		vu.state.Tags.SetGroupName(group.Path)
+	}
 	vu.state.Group = group
 
 	v, _, _, err := vu.runFn(ctx, false, fn, nil, vu.Runtime.ToValue(arg))
@@ -586,8 +635,14 @@ type VU struct {
 	state *lib.State
 	// count of iterations executed by this VU in each scenario
 	scenarioIter map[string]uint64
+	// the scenarios the connections of the prewarmConnections option were
+	// already opened for
+	prewarmed map[string]bool
 
 	moduleVUImpl *moduleVUImpl
+
+	// globals is set with the --strict-globals flag, see globalsTracker
+	globals *globalsTracker
 }
 
 // Verify that interfaces are implemented
@@ -605,6 +660,9 @@ type ActiveVU struct {
 	scenarioName              string
 	getNextIterationCounters  func() (uint64, uint64)
 	scIterLocal, scIterGlobal uint64
+
+	// the args of the scenario, passed to the exec function after the setup data
+	args goja.Value
 }
 
 // GetID returns the unique VU ID.
@@ -645,10 +703,35 @@ func (u *VU) Activate(params *lib.VUActivationParams) lib.ActiveVU {
 	if opts.SystemTags.Has(stats.TagGroup) {
 		u.state.Tags.Set("group", u.state.Group.Path)
 	}
+	if opts.SystemTags.Has(stats.TagGroupLevels) {
+		u.state.Tags.SetGroupLevels(u.state.Group.Path)
+	}
+	if opts.SystemTags.Has(stats.TagGroupName) {
+		u.state.Tags.SetGroupName(u.state.Group.Path)
+	}
 	if opts.SystemTags.Has(stats.TagScenario) {
 		u.state.Tags.Set("scenario", params.Scenario)
 	}
 
+	// The connections of the scenario are made with its socket options,
+	// from its network interface. The idle ones of the previous scenario of
+	// the VU are closed if they differ, as they would be reused otherwise.
+	socket := opts.Socket.Apply(params.Socket)
+	iface := opts.Interface.String
+	if params.Interface != "" {
+		iface = params.Interface
+	}
+	netIface := u.Runner.getInterface(iface)
+	if socket != u.Dialer.Socket || netIface != u.Dialer.Interface {
+		u.Transport.CloseIdleConnections()
+		u.Dialer.ClosePrewarmed()
+	}
+	u.Dialer.Socket = socket
+	u.Dialer.Interface = netIface
+	if params.PrewarmConnections > 0 && !u.prewarmed[params.Scenario] {
+		u.prewarm(params)
+	}
+
 	ctx := common.WithRuntime(params.RunContext, u.Runtime)
 	ctx = lib.WithState(ctx, u.state)
 	params.RunContext = ctx
@@ -692,6 +775,25 @@ func (u *VU) Activate(params *lib.VUActivationParams) lib.ActiveVU {
 	return avu
 }
 
+// prewarm opens the connections of the prewarmConnections option of the
+// scenario, when the VU is first activated for it, so they're ready before
+// its first iteration. The dialer keeps them until the transport dials the
+// same hosts, and their handshakes aren't counted in the data_sent and
+// data_received metrics. The DialTLSContext of the transport is only set for
+// it, as it otherwise does the TLS handshakes over the dialed connections.
+func (u *VU) prewarm(params *lib.VUActivationParams) {
+	u.prewarmed[params.Scenario] = true
+	if u.Transport.DialTLSContext == nil {
+		u.Transport.DialTLSContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return u.Dialer.DialTLSContext(ctx, network, addr, u.Transport.TLSClientConfig)
+		}
+	}
+	err := u.Dialer.Prewarm(params.RunContext, params.PrewarmURLs, params.PrewarmConnections, u.Transport.TLSClientConfig)
+	if err != nil {
+		u.state.Logger.WithError(err).Warn("The connections of the scenario couldn't be prewarmed")
+	}
+}
+
 // RunOnce runs the configured Exec function once.
 func (u *ActiveVU) RunOnce() error {
 	select {
@@ -718,6 +820,16 @@ func (u *ActiveVU) RunOnce() error {
 		}
 	}
 
+	// The args are unmarshaled once for every activation, so every VU gets its
+	// own copy of them
+	if u.args == nil && len(u.Args) > 0 {
+		var args interface{}
+		if err := json.Unmarshal(u.Args, &args); err != nil {
+			return fmt.Errorf("error unmarshaling the scenario args from JSON: %w", err)
+		}
+		u.args = u.Runtime.ToValue(args)
+	}
+
 	fn, ok := u.exports[u.Exec]
 	if !ok {
 		// Shouldn't happen; this is validated in cmd.validateScenarioConfig()
@@ -729,11 +841,28 @@ func (u *ActiveVU) RunOnce() error {
 		panic(fmt.Errorf("error setting __ITER in goja runtime: %w", err))
 	}
 
+	if u.globals == nil && u.Runner.Bundle.RuntimeOptions.StrictGlobals.Bool {
+		u.globals = newGlobalsTracker(u.Runtime, u.Runner.Bundle.declaredGlobals)
+	}
+
 	ctx, cancel := context.WithCancel(u.RunContext)
 	defer cancel()
 	*u.moduleVUImpl.ctxPtr = ctx
+	iterationCtx, err := u.newIterationContext(ctx)
+	if err != nil {
+		return fmt.Errorf("error creating the iteration context: %w", err)
+	}
+	// The iteration context is always the second argument, so the exec
+	// functions get it at the same place with and without scenario args
+	fnArgs := []goja.Value{u.setupData, iterationCtx}
+	if u.args != nil {
+		fnArgs = append(fnArgs, u.args)
+	}
 	// Call the exported function.
-	_, isFullIteration, totalTime, err := u.runFn(ctx, true, fn, cancel, u.setupData)
+	_, isFullIteration, totalTime, err := u.runFn(ctx, true, fn, cancel, fnArgs...)
+	if u.globals != nil {
+		u.globals.report(u.Runtime, u.state.Logger.WithField("vu", u.ID), u.iteration)
+	}
 	if err != nil {
 		var x *goja.InterruptedError
 		if errors.As(err, &x) {
@@ -802,6 +931,16 @@ func (u *VU) runFn(
 		return err
 	})
 
+	// A skipped iteration isn't an error, and its duration isn't measured
+	var skipped *common.SkipIterationError
+	var interrupted *goja.InterruptedError
+	if errors.As(err, &interrupted) {
+		if s, ok := interrupted.Value().(*common.SkipIterationError); ok {
+			u.Runtime.ClearInterrupt()
+			skipped, err = s, nil
+		}
+	}
+
 	select {
 	case <-ctx.Done():
 		isFullIteration = false
@@ -823,9 +962,31 @@ func (u *VU) runFn(
 		u.Transport.CloseIdleConnections()
 	}
 
-	sampleTags := stats.NewSampleTags(u.state.CloneTags())
+	tags := u.state.CloneTags()
+	if !isFullIteration && opts.SystemTags.Has(stats.TagInterrupted) {
+		tags[stats.TagInterrupted.String()] = "true"
+	}
+	sampleTags := stats.NewSampleTags(tags)
 	u.state.Samples <- u.Dialer.GetTrail(
-		startTime, endTime, isFullIteration, isDefault, sampleTags, u.Runner.builtinMetrics)
+		startTime, endTime, isFullIteration && skipped == nil, isDefault, sampleTags, u.Runner.builtinMetrics)
+	if !isFullIteration && isDefault {
+		u.state.Samples <- stats.Sample{
+			Time:   endTime,
+			Metric: u.Runner.builtinMetrics.IterationsInterrupted,
+			Tags:   sampleTags,
+			Value:  1,
+		}
+	}
+	if skipped != nil {
+		skippedTags := u.state.CloneTags()
+		skippedTags["reason"] = skipped.Reason
+		u.state.Samples <- stats.Sample{
+			Time:   endTime,
+			Metric: u.Runner.builtinMetrics.IterationsSkipped,
+			Tags:   stats.IntoSampleTags(&skippedTags),
+			Value:  1,
+		}
+	}
 
 	return v, isFullIteration, endTime.Sub(startTime), err
 }
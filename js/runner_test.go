@@ -25,6 +25,7 @@ import (
 	"context"
 	"crypto/tls"
 	"crypto/x509"
+	"encoding/json"
 	"fmt"
 	"go/build"
 	"io/ioutil"
@@ -35,6 +36,7 @@ import (
 	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -311,7 +313,7 @@ func TestSetupDataIsolation(t *testing.T) {
 	registry := metrics.NewRegistry()
 	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
 	engine, err := core.NewEngine(
-		execScheduler, options, lib.RuntimeOptions{}, []output.Output{mockOutput}, testutils.NewLogger(t), builtinMetrics,
+		execScheduler, options, lib.RuntimeOptions{}, []output.Output{mockOutput}, testutils.NewLogger(t), registry, builtinMetrics,
 	)
 	require.NoError(t, err)
 
@@ -1896,6 +1898,187 @@ func TestSystemTags(t *testing.T) {
 	}
 }
 
+func TestVUScenarioArgs(t *testing.T) {
+	t.Parallel()
+	r, err := getSimpleRunner(t, "/script.js", `
+		exports.withArgs = function(data, ctx, args) {
+			if (arguments.length !== 3 || ctx.iteration !== __ITER || ctx.scenario !== "with_args") {
+				throw new Error("wrong iteration context: " + JSON.stringify(ctx));
+			}
+			var path = __ITER === 0 ? "/login" : "changed";
+			if (args.path !== path || args.users.length !== 2) {
+				throw new Error("wrong args: " + JSON.stringify(args));
+			}
+			args.path = "changed";
+		};
+		exports.withoutArgs = function(data, ctx) {
+			if (arguments.length !== 2 || ctx.iteration !== __ITER || ctx.scenario !== "without_args") {
+				throw new Error("wrong arguments: " + arguments.length + " " + JSON.stringify(ctx));
+			}
+		};
+	`)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	vu, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+	activeVU := vu.Activate(&lib.VUActivationParams{
+		RunContext: ctx,
+		Scenario:   "with_args",
+		Exec:       "withArgs",
+		Args:       json.RawMessage(`{"path": "/login", "users": ["a", "b"]}`),
+	})
+	require.NoError(t, activeVU.RunOnce())
+	require.NoError(t, activeVU.RunOnce())
+
+	vu, err = r.NewVU(2, 2, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+	activeVU = vu.Activate(&lib.VUActivationParams{
+		RunContext: ctx,
+		Scenario:   "without_args",
+		Exec:       "withoutArgs",
+	})
+	require.NoError(t, activeVU.RunOnce())
+}
+
+func TestVUIterationContext(t *testing.T) {
+	t.Parallel()
+	deadline := time.Now().Add(time.Hour)
+	r, err := getSimpleRunner(t, "/script.js", fmt.Sprintf(`
+		var signal;
+		exports.default = function(data, ctx) {
+			if (__ITER === 0) {
+				signal = ctx.signal;
+				ctx.signal.throwIfAborted();
+				return;
+			}
+			if (!signal.aborted || signal.reason !== "context canceled") {
+				throw new Error("the signal of the previous iteration should have been aborted");
+			}
+			try {
+				signal.throwIfAborted();
+				throw new Error("throwIfAborted() should have thrown");
+			} catch (e) {
+				if (String(e).indexOf("the operation was aborted: context canceled") === -1) {
+					throw e;
+				}
+			}
+			var expected = {
+				scenario: "my_scenario", iteration: 1, iterationInScenario: 1,
+				iterationInInstance: null, iterationInTest: null, deadline: %d,
+			};
+			for (var name in expected) {
+				if (ctx[name] !== expected[name]) {
+					throw new Error(name + " is " + ctx[name] + " instead of " + expected[name]);
+				}
+			}
+			if (ctx.signal.aborted || ctx.signal.reason !== undefined) {
+				throw new Error("the signal of the iteration shouldn't be aborted");
+			}
+		};
+	`, deadline.UnixNano()/int64(time.Millisecond)))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+
+	vu, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+	activeVU := vu.Activate(&lib.VUActivationParams{RunContext: ctx, Scenario: "my_scenario"})
+	require.NoError(t, activeVU.RunOnce())
+	require.NoError(t, activeVU.RunOnce())
+}
+
+func TestVUSkipIteration(t *testing.T) {
+	t.Parallel()
+	r, err := getSimpleRunner(t, "/script.js", `
+		var exec = require("k6/execution");
+		exports.default = function() {
+			if (__ITER === 0) {
+				exec.test.skipIteration("no test data");
+				throw new Error("the iteration should have been stopped");
+			}
+		};
+	`)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	samples := make(chan stats.SampleContainer, 100)
+	vu, err := r.NewVU(1, 1, samples)
+	require.NoError(t, err)
+	activeVU := vu.Activate(&lib.VUActivationParams{RunContext: ctx})
+	require.NoError(t, activeVU.RunOnce())
+
+	var skipped []stats.Sample
+	for _, sample := range stats.GetBufferedSamples(samples) {
+		for _, s := range sample.GetSamples() {
+			assert.NotEqual(t, metrics.IterationDurationName, s.Metric.Name)
+			assert.NotEqual(t, metrics.IterationsName, s.Metric.Name)
+			if s.Metric.Name == metrics.IterationsSkippedName {
+				skipped = append(skipped, s)
+			}
+		}
+	}
+	require.Len(t, skipped, 1)
+	reason, _ := skipped[0].Tags.Get("reason")
+	assert.Equal(t, "no test data", reason)
+
+	// the VU can run the next iterations normally
+	require.NoError(t, activeVU.RunOnce())
+	var iterations float64
+	for _, sample := range stats.GetBufferedSamples(samples) {
+		for _, s := range sample.GetSamples() {
+			if s.Metric.Name == metrics.IterationsName {
+				iterations += s.Value
+			}
+		}
+	}
+	assert.Equal(t, float64(1), iterations)
+}
+
+func TestVUInterruptedIteration(t *testing.T) {
+	t.Parallel()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	r, err := getSimpleRunner(t, "/script.js", tb.Replacer.Replace(`
+		var http = require("k6/http");
+		exports.default = function() {
+			http.get("HTTPBIN_URL/delay/10");
+		};
+	`))
+	require.NoError(t, err)
+	require.NoError(t, r.SetOptions(lib.Options{
+		Hosts:      tb.Dialer.Hosts,
+		SystemTags: stats.NewSystemTagSet(stats.DefaultSystemTagSet),
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	samples := make(chan stats.SampleContainer, 100)
+	vu, err := r.NewVU(1, 1, samples)
+	require.NoError(t, err)
+	activeVU := vu.Activate(&lib.VUActivationParams{RunContext: ctx})
+	time.AfterFunc(200*time.Millisecond, cancel)
+	_ = activeVU.RunOnce()
+
+	counts := make(map[string]int)
+	for _, sample := range stats.GetBufferedSamples(samples) {
+		for _, s := range sample.GetSamples() {
+			interrupted, _ := s.Tags.Get("interrupted")
+			assert.Equal(t, "true", interrupted, s.Metric.Name)
+			counts[s.Metric.Name]++
+		}
+	}
+	assert.Equal(t, 1, counts[metrics.HTTPReqDurationName], "the interrupted request isn't dropped")
+	assert.Equal(t, 1, counts[metrics.IterationsInterruptedName])
+	assert.Equal(t, 0, counts[metrics.IterationsName])
+	assert.Equal(t, 0, counts[metrics.IterationDurationName])
+}
+
 func TestVUPanic(t *testing.T) {
 	t.Parallel()
 	r1, err := getSimpleRunner(t, "/script.js", `
@@ -2288,6 +2471,8 @@ func TestExecutionInfo(t *testing.T) {
 			if (si.progress !== 0.1) throw new Error('unexpected progress: '+si.progress);
 			if (si.iterationInInstance !== 3) throw new Error('unexpected scenario local iteration: '+si.iterationInInstance);
 			if (si.iterationInTest !== 4) throw new Error('unexpected scenario local iteration: '+si.iterationInTest);
+			if (si.stage !== null) throw new Error('unexpected stage: '+JSON.stringify(si.stage));
+			if (si.timeRemaining !== 0) throw new Error('unexpected time remaining: '+si.timeRemaining);
 		}`},
 		{name: "scenario_err", script: `
 		var exec = require('k6/execution');
@@ -2303,6 +2488,8 @@ func TestExecutionInfo(t *testing.T) {
 			if (ti.vusInitialized !== 0) throw new Error('unexpected vusInitialized: '+ti.vusInitialized);
 			if (ti.iterationsCompleted !== 0) throw new Error('unexpected iterationsCompleted: '+ti.iterationsCompleted);
 			if (ti.iterationsInterrupted !== 0) throw new Error('unexpected iterationsInterrupted: '+ti.iterationsInterrupted);
+			if (ti.iterationsDropped !== 0) throw new Error('unexpected iterationsDropped: '+ti.iterationsDropped);
+			if (ti.requestsFailed !== 0) throw new Error('unexpected requestsFailed: '+ti.requestsFailed);
 		}`},
 		{name: "test_err", script: `
 		var exec = require('k6/execution');
@@ -2355,3 +2542,173 @@ func TestExecutionInfo(t *testing.T) {
 		})
 	}
 }
+
+func TestVUStrictGlobals(t *testing.T) {
+	t.Parallel()
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+	hook := testutils.SimpleLogrusHook{HookedLevels: []logrus.Level{logrus.WarnLevel}}
+	logger.AddHook(&hook)
+
+	r, err := getSimpleRunner(t, "/script.js", `
+		var total = 0;
+		let counter = 0;
+		const data = [];
+		exports.default = function() {
+			data.push(1);
+			if (__ITER == 1) {
+				counter++;
+				globalThis.later = true;
+			}
+			let local = 0;
+			local++;
+		}`,
+		lib.RuntimeOptions{CompatibilityMode: null.StringFrom("base"), StrictGlobals: null.BoolFrom(true)}, logger)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"total", "counter", "data"}, r.Bundle.declaredGlobals)
+
+	initVU, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	for i := 0; i < 3; i++ {
+		require.NoError(t, vu.RunOnce())
+	}
+
+	entries := hook.Drain()
+	require.Len(t, entries, 2)
+	assert.Equal(t, "counter", entries[0].Data["global"])
+	assert.Equal(t, int64(1), entries[0].Data["iteration"])
+	assert.Equal(t, uint64(1), entries[0].Data["vu"])
+	assert.Contains(t, entries[0].Message, "The global variable 'counter' of the init context was reassigned")
+	assert.Equal(t, "later", entries[1].Data["global"])
+	assert.Contains(t, entries[1].Message, "The global variable 'later' was created outside of the init context")
+}
+
+func TestVUPrewarmConnections(t *testing.T) {
+	t.Parallel()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	var prewarmRequests int64
+	tb.Mux.HandleFunc("/prewarm", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt64(&prewarmRequests, 1)
+	})
+
+	r, err := getSimpleRunner(t, "/script.js", tb.Replacer.Replace(`
+		var http = require("k6/http");
+		exports.default = function() {
+			var responses = http.batch(["HTTPBIN_URL/get", "HTTPBIN_URL/get"]);
+			for (var i = 0; i < responses.length; i++) {
+				if (responses[i].timings.connecting !== 0) {
+					throw new Error("request " + i + " opened a connection");
+				}
+			}
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, r.SetOptions(lib.Options{
+		Throw:        null.BoolFrom(true),
+		Hosts:        tb.Dialer.Hosts,
+		Batch:        null.IntFrom(2),
+		BatchPerHost: null.IntFrom(2),
+	}))
+
+	initVU, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{
+		RunContext:         ctx,
+		PrewarmConnections: 2,
+		PrewarmURLs:        []string{tb.Replacer.Replace("HTTPBIN_URL/prewarm")},
+	})
+	require.NoError(t, vu.RunOnce())
+	assert.Zero(t, atomic.LoadInt64(&prewarmRequests))
+}
+
+func TestVUSocketOptionsConnectionReuse(t *testing.T) {
+	t.Parallel()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+	var opened int64
+	tb.ServerHTTP.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&opened, 1)
+		}
+	}
+
+	r, err := getSimpleRunner(t, "/script.js", tb.Replacer.Replace(`
+		var http = require("k6/http");
+		exports.default = function() {
+			http.get("HTTPBIN_URL/get");
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, r.SetOptions(lib.Options{Throw: null.BoolFrom(true), Hosts: tb.Dialer.Hosts}))
+
+	initVU, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the idle connections are only reused by the scenarios with the same
+	// socket options
+	for i, socket := range []lib.SocketOptions{
+		{},
+		{},
+		{NoDelay: null.BoolFrom(false), Valid: true},
+		{NoDelay: null.BoolFrom(false), Valid: true},
+		{},
+	} {
+		vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx, Scenario: fmt.Sprint(i), Socket: socket})
+		require.NoError(t, vu.RunOnce())
+	}
+	assert.Equal(t, int64(3), atomic.LoadInt64(&opened))
+}
+
+func TestVUInterface(t *testing.T) {
+	t.Parallel()
+	tb := httpmultibin.NewHTTPMultiBin(t)
+
+	r, err := getSimpleRunner(t, "/script.js", tb.Replacer.Replace(`
+		var http = require("k6/http");
+		exports.default = function() {
+			http.get("HTTPBIN_URL/get");
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, r.SetOptions(lib.Options{
+		Throw:     null.BoolFrom(true),
+		Hosts:     tb.Dialer.Hosts,
+		Interface: null.StringFrom("k6-missing0"),
+	}))
+
+	initVU, err := r.NewVU(1, 1, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	vu := initVU.Activate(&lib.VUActivationParams{RunContext: ctx})
+	err = vu.RunOnce()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `couldn't find the network interface "k6-missing0"`)
+
+	// the interface of the scenario overrides the one of the test
+	loopback := getLoopbackInterface(t)
+	initVU, err = r.NewVU(2, 2, make(chan stats.SampleContainer, 100))
+	require.NoError(t, err)
+	vu = initVU.Activate(&lib.VUActivationParams{RunContext: ctx, Interface: loopback})
+	require.NoError(t, vu.RunOnce())
+}
+
+func getLoopbackInterface(t *testing.T) string {
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("there's no loopback interface")
+	return ""
+}
@@ -45,6 +45,11 @@
                 oldFormatMetric.thresholds = {};
                 forEach(newFormatThresholds, function (thresholdName, threshold) {
                     oldFormatMetric.thresholds[thresholdName] = !threshold.ok;
+                    if (threshold.hasOwnProperty('last_evaluated')) {
+                        // the details are separate, to keep the booleans of the old format
+                        oldFormatMetric.threshold_results = oldFormatMetric.threshold_results || {};
+                        oldFormatMetric.threshold_results[thresholdName] = threshold;
+                    }
                 });
             }
             if (metric.type == 'rate' && oldFormatMetric.hasOwnProperty('rate')) {
@@ -59,7 +64,20 @@
         return JSON.stringify(results, null, 4);
     };
 
-    return function (exportedSummaryCallback, jsonSummaryPath, data) {
+    var exporters = {
+        'json': oldJSONSummary,
+        'junit': function (data) {
+            return jslib.jUnit(data);
+        },
+        'html': function (data) {
+            return jslib.htmlSummary(data);
+        },
+        'markdown': function (data) {
+            return jslib.markdownSummary(data);
+        },
+    };
+
+    return function (exportedSummaryCallback, summaryExports, data) {
         var getDefaultSummary = function () {
             var enableColors = (!data.options.noColor && data.state.isStdOutTTY);
             return {
@@ -82,8 +100,9 @@
         // TODO: ensure we're returning a map of strings or null/undefined...
         // and if not, log an error and generate the default summary?
 
-        if (jsonSummaryPath != '') {
-            result[jsonSummaryPath] = oldJSONSummary(data);
+        // All of the --summary-export files are generated from the same data
+        for (var i = 0; i < summaryExports.length; i++) {
+            result[summaryExports[i].path] = exporters[summaryExports[i].format](data);
         }
 
         return result;
@@ -54,19 +54,17 @@ func metricValueGetter(summaryTrendStats []string) func(stats.Sink, time.Duratio
 		switch sink := sink.(type) {
 		case *stats.CounterSink:
 			result = sink.Format(t)
-			rate := 0.0
-			if t > 0 {
-				rate = sink.Value / (float64(t) / float64(time.Second))
-			}
-			result["rate"] = rate
 		case *stats.GaugeSink:
 			result = sink.Format(t)
 			result["min"] = sink.Min
 			result["max"] = sink.Max
+			result["ewma"] = sink.EWMA
 		case *stats.RateSink:
 			result = sink.Format(t)
 			result["passes"] = float64(sink.Trues)
 			result["fails"] = float64(sink.Total - sink.Trues)
+		case *stats.HistogramSink:
+			result = sink.Format(t)
 		case *stats.TrendSink:
 			result = make(map[string]float64, len(summaryTrendStats))
 			for _, col := range summaryTrendStats {
@@ -78,6 +76,47 @@ func metricValueGetter(summaryTrendStats []string) func(stats.Sink, time.Duratio
 	}
 }
 
+// addThresholdTrendValues adds the values of the aggregation methods which the
+// thresholds of a trend metric use, when they aren't summaryTrendStats, so the
+// summary can be the baseline of the thresholds in a later test.
+func addThresholdTrendValues(values map[string]float64, m *stats.Metric) {
+	sink, ok := m.Sink.(*stats.TrendSink)
+	if !ok {
+		return
+	}
+	for _, method := range m.Thresholds.AggregationMethods() {
+		if _, ok := values[method]; ok {
+			continue
+		}
+		resolvers, err := stats.GetResolversForTrendColumns([]string{method})
+		if err != nil {
+			continue // not a trend aggregation method, so the threshold fails anyway
+		}
+		values[method] = resolvers[method](sink)
+	}
+}
+
+// exportThresholdResult returns the data of the threshold result for the
+// summary. The observed value, the limit, the margin and the last evaluation
+// time are only in it if the threshold was evaluated.
+func exportThresholdResult(result stats.ThresholdResult) map[string]interface{} {
+	data := map[string]interface{}{
+		"ok": result.Passed,
+	}
+	if result.Severity != "" {
+		data["severity"] = result.Severity
+	}
+	if result.Observed.Valid {
+		data["observed"] = result.Observed.Float64
+		data["limit"] = result.Limit
+		data["margin"] = result.Margin.Float64
+	}
+	if result.LastRun.Valid {
+		data["last_evaluated"] = result.LastRun.Time.Format(time.RFC3339Nano)
+	}
+	return data
+}
+
 // summarizeMetricsToObject transforms the summary objects in a way that's
 // suitable to pass to the JS runtime or export to JSON.
 func summarizeMetricsToObject(data *lib.Summary, options lib.Options, setupData []byte) map[string]interface{} {
@@ -94,29 +133,51 @@ func summarizeMetricsToObject(data *lib.Summary, options lib.Options, setupData
 		"isStdErrTTY":       data.UIState.IsStdErrTTY,
 		"testRunDurationMs": float64(data.TestRunDuration) / float64(time.Millisecond),
 	}
+	if len(data.Metadata) > 0 {
+		metadata := make(map[string]interface{}, len(data.Metadata))
+		for k, v := range data.Metadata {
+			metadata[k] = v
+		}
+		m["metadata"] = metadata
+	}
 
 	getMetricValues := metricValueGetter(options.SummaryTrendStats)
 
 	metricsData := make(map[string]interface{})
 	for name, m := range data.Metrics {
+		values := getMetricValues(m.Sink, data.TestRunDuration)
+		addThresholdTrendValues(values, m)
 		metricData := map[string]interface{}{
 			"type":     m.Type.String(),
 			"contains": m.Contains.String(),
-			"values":   getMetricValues(m.Sink, data.TestRunDuration),
+			"values":   values,
 		}
 
 		if len(m.Thresholds.Thresholds) > 0 {
 			thresholds := make(map[string]interface{})
-			for _, threshold := range m.Thresholds.Thresholds {
-				thresholds[threshold.Source] = map[string]interface{}{
-					"ok": !threshold.LastFailed,
-				}
+			for _, result := range m.Thresholds.Results() {
+				thresholds[result.Source] = exportThresholdResult(result)
 			}
 			metricData["thresholds"] = thresholds
 		}
+		if !m.FirstSampleTime.IsZero() {
+			metricData["first_sample_time"] = m.FirstSampleTime.Format(time.RFC3339Nano)
+			metricData["last_sample_time"] = m.LastSampleTime.Format(time.RFC3339Nano)
+		}
+		if n := data.CoordinatedOmission[name]; n > 0 {
+			metricData["coordinated_omission"] = map[string]interface{}{
+				"synthetic_samples": float64(n),
+			}
+		}
 		metricsData[name] = metricData
 	}
 	m["metrics"] = metricsData
+	if data.Timeline != nil {
+		m["timeline"] = exportTimeline(data.Timeline)
+	}
+	if len(data.Anomalies) > 0 {
+		m["anomalies"] = exportAnomalies(data.Anomalies)
+	}
 
 	var setupDataI interface{}
 	if setupData != nil {
@@ -133,6 +194,46 @@ func summarizeMetricsToObject(data *lib.Summary, options lib.Options, setupData
 	return m
 }
 
+func exportTimeline(timeline *lib.SummaryTimeline) map[string]interface{} {
+	metricsData := make(map[string]interface{}, len(timeline.Metrics))
+	for name, points := range timeline.Metrics {
+		pointsData := make([]interface{}, len(points))
+		for i, point := range points {
+			values := make(map[string]interface{}, len(point.Values))
+			for k, v := range point.Values {
+				values[k] = v
+			}
+			pointsData[i] = map[string]interface{}{
+				"time":   point.Time.UTC().Format(time.RFC3339Nano),
+				"values": values,
+			}
+		}
+		metricsData[name] = pointsData
+	}
+	return map[string]interface{}{
+		"interval_ms": float64(timeline.Interval) / float64(time.Millisecond),
+		"metrics":     metricsData,
+	}
+}
+
+// exportAnomalies returns the anomaly events with their data, and the time of
+// the start and the end of their interval as strings.
+func exportAnomalies(anomalies []lib.Event) []interface{} {
+	result := make([]interface{}, len(anomalies))
+	for i, event := range anomalies {
+		data := make(map[string]interface{}, len(event.Data)+1)
+		for k, v := range event.Data {
+			if t, ok := v.(time.Time); ok {
+				v = t.UTC().Format(time.RFC3339Nano)
+			}
+			data[k] = v
+		}
+		data["time"] = event.Time.UTC().Format(time.RFC3339Nano)
+		result[i] = data
+	}
+	return result
+}
+
 func exportGroup(group *lib.Group) map[string]interface{} {
 	subGroups := make([]map[string]interface{}, len(group.OrderedGroups))
 	for i, subGroup := range group.OrderedGroups {
@@ -148,6 +249,12 @@ func exportGroup(group *lib.Group) map[string]interface{} {
 			"passes": check.Passes,
 			"fails":  check.Fails,
 		}
+		if messages := check.GetFailureMessages(); messages != nil {
+			checks[i]["failure_messages"] = messages
+		}
+		if captures := check.GetFailureCaptures(); captures != nil {
+			checks[i]["failure_captures"] = captures
+		}
 	}
 
 	return map[string]interface{}{
@@ -13,6 +13,7 @@ var palette = {
   faint: 2,
   red: 31,
   green: 32,
+  yellow: 33,
   cyan: 36,
   //TODO: add others?
 }
@@ -21,6 +22,7 @@ var groupPrefix = '█'
 var detailsPrefix = '↳'
 var succMark = '✓'
 var failMark = '✗'
+var warnMark = '!'
 var defaultOptions = {
   indent: ' ',
   enableColors: true,
@@ -73,6 +75,15 @@ function summarizeCheck(indent, check, decorate) {
   }
 
   var succPercent = Math.floor((100 * check.passes) / (check.passes + check.fails))
+  var failures = ''
+  var messages = check.failure_messages || []
+  for (var i = 0; i < messages.length; i++) {
+    failures += '\n' + indent + '    ' + failMark + ' ' + messages[i]
+  }
+  var captures = check.failure_captures || []
+  for (var j = 0; j < captures.length; j++) {
+    failures += '\n' + indent + '    saved in ' + captures[j]
+  }
   return decorate(
     indent +
     failMark +
@@ -91,7 +102,8 @@ function summarizeCheck(indent, check, decorate) {
     ' / ' +
     failMark +
     ' ' +
-    check.fails,
+    check.fails +
+    failures,
     palette.red
   )
 }
@@ -212,6 +224,8 @@ function humanizeValue(val, metric, timeUnit) {
       return humanizeBytes(val)
     case 'time':
       return humanizeDuration(val, timeUnit)
+    case 'percent':
+      return toFixedNoTrailingZeros(val, 2) + '%'
     default:
       return toFixedNoTrailingZeros(val, 6)
   }
@@ -229,6 +243,7 @@ function nonTrendMetricValueForSum(metric, timeUnit) {
         humanizeValue(metric.values.value, metric, timeUnit),
         'min=' + humanizeValue(metric.values.min, metric, timeUnit),
         'max=' + humanizeValue(metric.values.max, metric, timeUnit),
+        'ewma=' + humanizeValue(metric.values.ewma, metric, timeUnit),
       ]
     case 'rate':
       return [
@@ -236,11 +251,34 @@ function nonTrendMetricValueForSum(metric, timeUnit) {
         succMark + ' ' + metric.values.passes,
         failMark + ' ' + metric.values.fails,
       ]
+    case 'histogram':
+      return [
+        metric.values.count.toString(),
+        'avg=' + humanizeValue(metric.values.avg, metric, timeUnit),
+        'max=' + humanizeValue(metric.values.max, metric, timeUnit),
+      ]
     default:
       return ['[no data]']
   }
 }
 
+// sortMetricNames sorts all metrics but keeps sub metrics grouped with their
+// parent metrics
+function sortMetricNames(names) {
+  names.sort(function (metric1, metric2) {
+    var parent1 = metric1.split('{', 1)[0]
+    var parent2 = metric2.split('{', 1)[0]
+    var result = parent1.localeCompare(parent2)
+    if (result !== 0) {
+      return result
+    }
+    var sub1 = metric1.substring(parent1.length)
+    var sub2 = metric2.substring(parent2.length)
+    return sub1.localeCompare(sub2)
+  })
+  return names
+}
+
 function summarizeMetrics(options, data, decorate) {
   var indent = options.indent + '  '
   var result = []
@@ -299,18 +337,7 @@ function summarizeMetrics(options, data, decorate) {
     }
   })
 
-  // sort all metrics but keep sub metrics grouped with their parent metrics
-  names.sort(function (metric1, metric2) {
-    var parent1 = metric1.split('{', 1)[0]
-    var parent2 = metric2.split('{', 1)[0]
-    var result = parent1.localeCompare(parent2)
-    if (result !== 0) {
-      return result
-    }
-    var sub1 = metric1.substring(parent1.length)
-    var sub2 = metric2.substring(parent2.length)
-    return sub1.localeCompare(sub2)
-  })
+  sortMetricNames(names)
 
   var getData = function (name) {
     if (trendCols.hasOwnProperty(name)) {
@@ -358,13 +385,21 @@ function summarizeMetrics(options, data, decorate) {
         return decorate(text, palette.green)
       }
       forEach(metric.thresholds, function (name, threshold) {
-        if (!threshold.ok) {
-          mark = failMark
+        if (threshold.ok) {
+          return
+        }
+        if (isWarning(threshold)) {
+          mark = warnMark
           markColor = function (text) {
-            return decorate(text, palette.red)
+            return decorate(text, palette.yellow)
           }
-          return true // break
+          return
         }
+        mark = failMark
+        markColor = function (text) {
+          return decorate(text, palette.red)
+        }
+        return true // break
       })
     }
     var fmtIndent = indentForMetric(name)
@@ -376,12 +411,27 @@ function summarizeMetrics(options, data, decorate) {
         palette.faint
       )
 
-    result.push(indent + fmtIndent + markColor(mark) + ' ' + fmtName + ' ' + getData(name))
+    var fmtCorrection = ''
+    if (metric.coordinated_omission) {
+      fmtCorrection = ' ' + decorate(coordinatedOmissionLabel(metric), palette.faint)
+    }
+
+    result.push(indent + fmtIndent + markColor(mark) + ' ' + fmtName + ' ' + getData(name) + fmtCorrection)
   }
 
   return result
 }
 
+// coordinatedOmissionLabel marks the metrics with back-filled samples, since
+// their values aren't only the measured ones.
+function coordinatedOmissionLabel(metric) {
+  return (
+    '(corrected for coordinated omission, +' +
+    metric.coordinated_omission.synthetic_samples +
+    ' synthetic samples)'
+  )
+}
+
 function generateTextSummary(data, options) {
   var mergedOpts = Object.assign({}, defaultOptions, data.options, options)
   var lines = []
@@ -406,9 +456,228 @@ function generateTextSummary(data, options) {
   )
 
   Array.prototype.push.apply(lines, summarizeMetrics(mergedOpts, data, decorate))
+  Array.prototype.push.apply(lines, summarizeAnomalies(mergedOpts.indent + '  ', data.anomalies, decorate))
 
   return lines.join('\n')
 }
 
+// summarizeAnomalies lists the anomalies detected in the requests during the
+// test, with the start of the interval they were in, if there were any.
+function summarizeAnomalies(indent, anomalies, decorate) {
+  if (!anomalies || anomalies.length == 0) {
+    return []
+  }
+  var result = ['', indent + 'anomalies:']
+  for (var i = 0; i < anomalies.length; i++) {
+    var anomaly = anomalies[i]
+    result.push(
+      indent +
+        '  ' +
+        decorate(warnMark, palette.yellow) +
+        ' ' +
+        decorate(anomaly.time, palette.faint) +
+        ' ' +
+        anomaly.description
+    )
+  }
+  return result
+}
+
+// metricRows returns the name, the humanized values and the thresholds of all
+// metrics, for the summaries which are tables instead of aligned text.
+function metricRows(data, options) {
+  var mergedOpts = Object.assign({}, defaultOptions, data.options, options)
+  var names = sortMetricNames(Object.keys(data.metrics))
+  return names.map(function (name) {
+    var metric = data.metrics[name]
+    var values
+    if (metric.type == 'trend') {
+      values = mergedOpts.summaryTrendStats.map(function (tc) {
+        var value = metric.values[tc]
+        return tc + '=' + (tc === 'count' ? value.toString() : humanizeValue(value, metric, mergedOpts.summaryTimeUnit))
+      })
+    } else {
+      values = nonTrendMetricValueForSum(metric, mergedOpts.summaryTimeUnit)
+    }
+    var thresholds = []
+    forEach(metric.thresholds, function (source, threshold) {
+      thresholds.push({ source: source, ok: threshold.ok, severity: threshold.severity })
+    })
+    if (metric.coordinated_omission) {
+      values.push(coordinatedOmissionLabel(metric))
+    }
+    return { name: name, values: values.join(' '), thresholds: thresholds }
+  })
+}
+
+// checkRows returns all checks, with the names of their groups before theirs.
+function checkRows(group, prefix) {
+  var rows = []
+  if (group.name != '') {
+    prefix = prefix + group.name + ' :: '
+  }
+  for (var i = 0; i < group.checks.length; i++) {
+    var check = group.checks[i]
+    rows.push({ name: prefix + check.name, passes: check.passes, fails: check.fails })
+  }
+  for (var i = 0; i < group.groups.length; i++) {
+    Array.prototype.push.apply(rows, checkRows(group.groups[i], prefix))
+  }
+  return rows
+}
+
+function escapeHTML(str) {
+  return String(str)
+    .replace(/&/g, '&amp;')
+    .replace(/</g, '&lt;')
+    .replace(/>/g, '&gt;')
+    .replace(/"/g, '&quot;')
+    .replace(/'/g, '&#39;')
+}
+
+// isWarning returns true if the threshold has the warn severity, so crossing
+// it didn't fail the test.
+function isWarning(threshold) {
+  return threshold.severity === 'warn'
+}
+
+// thresholdMark returns the mark of a threshold, warnMark if it was crossed
+// but has the warn severity.
+function thresholdMark(threshold, succ, fail) {
+  if (threshold.ok) {
+    return succ
+  }
+  return isWarning(threshold) ? warnMark : fail
+}
+
+// generateJUnitXML returns the thresholds as JUnit test cases, failed if the
+// threshold was crossed, unless it has the warn severity.
+function generateJUnitXML(data, options) {
+  var failures = 0
+  var cases = []
+
+  forEach(data.metrics, function (metricName, metric) {
+    forEach(metric.thresholds, function (thresholdName, threshold) {
+      var name = escapeHTML(metricName + ' - ' + thresholdName)
+      if (threshold.ok) {
+        cases.push('<testcase name="' + name + '" />')
+      } else if (isWarning(threshold)) {
+        cases.push(
+          '<testcase name="' + name + '"><system-out>' + name + ' was crossed, with the warn severity' +
+          '</system-out></testcase>'
+        )
+      } else {
+        failures++
+        cases.push(
+          '<testcase name="' + name + '"><failure message="' + name + ' has failed" /></testcase>'
+        )
+      }
+    })
+  })
+
+  var suiteName = escapeHTML((options && options.name) || 'k6 thresholds')
+  return (
+    '<?xml version="1.0"?>\n<testsuites tests="' + cases.length + '" failures="' + failures + '">\n' +
+    '<testsuite name="' + suiteName + '" tests="' + cases.length + '" failures="' + failures + '">\n' +
+    cases.join('\n') +
+    '\n</testsuite>\n</testsuites>\n'
+  )
+}
+
+function thresholdsText(thresholds, succ, fail) {
+  return thresholds
+    .map(function (threshold) {
+      return thresholdMark(threshold, succ, fail) + ' ' + threshold.source
+    })
+    .join(', ')
+}
+
+function escapeMarkdown(str) {
+  return String(str).replace(/\|/g, '\\|')
+}
+
+function generateMarkdownSummary(data, options) {
+  var lines = ['# k6 summary', '']
+
+  var checks = checkRows(data.root_group, '')
+  if (checks.length > 0) {
+    lines.push('## Checks', '', '| | Check | Passes | Fails |', '|---|---|---|---|')
+    for (var i = 0; i < checks.length; i++) {
+      var check = checks[i]
+      lines.push(
+        '| ' + (check.fails == 0 ? succMark : failMark) + ' | ' + escapeMarkdown(check.name) + ' | ' +
+        check.passes + ' | ' + check.fails + ' |'
+      )
+    }
+    lines.push('')
+  }
+
+  lines.push('## Metrics', '', '| Metric | Values | Thresholds |', '|---|---|---|')
+  var metrics = metricRows(data, options)
+  for (var i = 0; i < metrics.length; i++) {
+    var metric = metrics[i]
+    lines.push(
+      '| ' + escapeMarkdown(metric.name) + ' | ' + escapeMarkdown(metric.values) + ' | ' +
+      escapeMarkdown(thresholdsText(metric.thresholds, succMark, failMark)) + ' |'
+    )
+  }
+
+  return lines.join('\n') + '\n'
+}
+
+function generateHTMLSummary(data, options) {
+  var html = [
+    '<!DOCTYPE html>',
+    '<html>',
+    '<head>',
+    '<meta charset="utf-8">',
+    '<title>k6 summary</title>',
+    '<style>',
+    'body { font-family: sans-serif; } table { border-collapse: collapse; }',
+    'th, td { border: 1px solid #ccc; padding: 4px 8px; text-align: left; }',
+    '.ok { color: #2e7d32; } .failed { color: #c62828; } .warn { color: #ef6c00; }',
+    '</style>',
+    '</head>',
+    '<body>',
+    '<h1>k6 summary</h1>',
+  ]
+
+  var checks = checkRows(data.root_group, '')
+  if (checks.length > 0) {
+    html.push('<h2>Checks</h2>', '<table>', '<tr><th>Check</th><th>Passes</th><th>Fails</th></tr>')
+    for (var i = 0; i < checks.length; i++) {
+      var check = checks[i]
+      html.push(
+        '<tr class="' + (check.fails == 0 ? 'ok' : 'failed') + '"><td>' + escapeHTML(check.name) +
+        '</td><td>' + check.passes + '</td><td>' + check.fails + '</td></tr>'
+      )
+    }
+    html.push('</table>')
+  }
+
+  html.push('<h2>Metrics</h2>', '<table>', '<tr><th>Metric</th><th>Values</th><th>Thresholds</th></tr>')
+  var metrics = metricRows(data, options)
+  for (var i = 0; i < metrics.length; i++) {
+    var metric = metrics[i]
+    var thresholds = metric.thresholds.map(function (threshold) {
+      var cls = threshold.ok ? 'ok' : isWarning(threshold) ? 'warn' : 'failed'
+      return (
+        '<span class="' + cls + '">' +
+        thresholdMark(threshold, succMark, failMark) + ' ' + escapeHTML(threshold.source) + '</span>'
+      )
+    })
+    html.push(
+      '<tr><td>' + escapeHTML(metric.name) + '</td><td>' + escapeHTML(metric.values) + '</td><td>' +
+      thresholds.join('<br>') + '</td></tr>'
+    )
+  }
+  html.push('</table>', '</body>', '</html>')
+
+  return html.join('\n') + '\n'
+}
+
 exports.humanizeValue = humanizeValue
-exports.textSummary = generateTextSummary
\ No newline at end of file
+exports.textSummary = generateTextSummary
+exports.jUnit = generateJUnitXML
+exports.markdownSummary = generateMarkdownSummary
+exports.htmlSummary = generateHTMLSummary
\ No newline at end of file
@@ -45,7 +45,7 @@ const (
 		"       ✗ check2\n        ↳  33% — ✓ 5 / ✗ 10\n\n" +
 		"   ✓ checks......: 75.00% ✓ 45  ✗ 15 \n"
 	countOut = "   ✗ http_reqs...: 3      3/s\n"
-	gaugeOut = "     vus.........: 1      min=1 max=1\n"
+	gaugeOut = "     vus.........: 1      min=1 max=1 ewma=1\n"
 	trendOut = "   ✗ my_trend....: avg=15ms min=10ms med=15ms max=20ms p(90)=19ms " +
 		"p(95)=19.5ms p(99.9)=19.99ms\n"
 )
@@ -146,6 +146,461 @@ func TestTextSummaryWithSubMetrics(t *testing.T) {
 	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
 }
 
+func TestTextSummaryCoordinatedOmission(t *testing.T) {
+	t.Parallel()
+
+	duration := stats.New("iteration_duration", stats.Trend, stats.Time)
+	duration.Sink.Add(stats.Sample{Value: 300})
+	duration.Sink.Add(stats.Sample{Value: 200})
+	duration.Sink.Add(stats.Sample{Value: 100})
+	summary := &lib.Summary{
+		Metrics:             map[string]*stats.Metric{duration.Name: duration},
+		RootGroup:           &lib.Group{},
+		TestRunDuration:     time.Second,
+		CoordinatedOmission: map[string]int64{duration.Name: 2},
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.options = {summaryTrendStats: ["max"]};
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	summaryOut, err := ioutil.ReadAll(result["stdout"])
+	require.NoError(t, err)
+	assert.Equal(t,
+		"\n     iteration_duration...: max=300ms (corrected for coordinated omission, +2 synthetic samples)\n\n",
+		string(summaryOut))
+
+	runner, err = getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			return {"co.json": JSON.stringify(data.metrics.iteration_duration.coordinated_omission)};
+		};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err = runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	exported, err := ioutil.ReadAll(result["co.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"synthetic_samples": 2}`, string(exported))
+}
+
+func TestSummaryWarningThresholds(t *testing.T) {
+	t.Parallel()
+
+	reqs := stats.New("http_reqs", stats.Counter)
+	reqs.Sink.Add(stats.Sample{Value: 3})
+	reqs.Thresholds = stats.Thresholds{Thresholds: []*stats.Threshold{
+		{Source: "count<2", LastFailed: true, Severity: stats.ThresholdSeverityWarn},
+	}}
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{reqs.Name: reqs},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`exports.default = function() {/* we don't run this, metrics are mocked */};`,
+		lib.RuntimeOptions{
+			CompatibilityMode: null.NewString("base", true),
+			SummaryExport:     []string{"junit=junit.xml", "markdown=report.md"},
+		},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	read := func(path string) string {
+		require.NotNil(t, result[path], path)
+		data, err := ioutil.ReadAll(result[path])
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.Contains(t, read("stdout"), "! http_reqs")
+	junit := read("junit.xml")
+	assert.Contains(t, junit, `failures="0"`)
+	assert.Contains(t, junit, "http_reqs - count&lt;2 was crossed, with the warn severity")
+	assert.Contains(t, read("report.md"), "| ! count<2 |")
+
+	runner, err = getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			return {"thresholds.json": JSON.stringify(data.metrics.http_reqs.thresholds)};
+		};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err = runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"count<2": {"ok": false, "severity": "warn"}}`, read("thresholds.json"))
+}
+
+func TestSummaryThresholdResults(t *testing.T) {
+	t.Parallel()
+
+	reqs := stats.New("http_reqs", stats.Counter)
+	reqs.Sink.Add(stats.Sample{Value: 3})
+	reqs.Thresholds = stats.NewThresholds([]string{"count<5", "count>10"})
+	require.NoError(t, reqs.Thresholds.Parse())
+	_, err := reqs.Thresholds.Run(reqs.Sink, time.Second)
+	require.NoError(t, err)
+	lastRun := reqs.Thresholds.Thresholds[0].LastRun.Format(time.RFC3339Nano)
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{reqs.Name: reqs},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			return {"thresholds.json": JSON.stringify(data.metrics.http_reqs.thresholds)};
+		};
+		`,
+		lib.RuntimeOptions{
+			CompatibilityMode: null.NewString("base", true),
+			SummaryExport:     []string{"result.json"},
+		},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	read := func(path string) []byte {
+		require.NotNil(t, result[path], path)
+		data, err := ioutil.ReadAll(result[path])
+		require.NoError(t, err)
+		return data
+	}
+	expected := fmt.Sprintf(`{
+		"count<5": {"ok": true, "observed": 3, "limit": 5, "margin": 2, "last_evaluated": %[1]q},
+		"count>10": {"ok": false, "observed": 3, "limit": 10, "margin": -7, "last_evaluated": %[1]q}
+	}`, lastRun)
+	assert.JSONEq(t, expected, string(read("thresholds.json")))
+
+	var exported struct {
+		Metrics map[string]struct {
+			Thresholds       map[string]bool            `json:"thresholds"`
+			ThresholdResults map[string]json.RawMessage `json:"threshold_results"`
+		} `json:"metrics"`
+	}
+	require.NoError(t, json.Unmarshal(read("result.json"), &exported))
+	metric := exported.Metrics["http_reqs"]
+	assert.Equal(t, map[string]bool{"count<5": false, "count>10": true}, metric.Thresholds)
+	threshold, err := json.Marshal(metric.ThresholdResults)
+	require.NoError(t, err)
+	assert.JSONEq(t, expected, string(threshold))
+}
+
+func TestSummarySampleTimes(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	reqs := stats.New("http_reqs", stats.Counter)
+	reqs.Sink.Add(stats.Sample{Value: 1})
+	reqs.TrackSampleTime(start.Add(90 * time.Second))
+	reqs.TrackSampleTime(start.Add(500 * time.Millisecond))
+	empty := stats.New("data_sent", stats.Counter)
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{reqs.Name: reqs, empty.Name: empty},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: 5 * time.Minute,
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			var times = {};
+			for (var name in data.metrics) {
+				times[name] = [data.metrics[name].first_sample_time, data.metrics[name].last_sample_time];
+			}
+			return {"times.json": JSON.stringify(times)};
+		};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	exported, err := ioutil.ReadAll(result["times.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"http_reqs": ["2021-06-01T12:00:00.5Z", "2021-06-01T12:01:30Z"],
+		"data_sent": [null, null]
+	}`, string(exported))
+}
+
+func TestSummaryThresholdTrendValues(t *testing.T) {
+	t.Parallel()
+
+	duration := stats.New("http_req_duration", stats.Trend, stats.Time)
+	for _, v := range []float64{100, 200, 300, 400} {
+		duration.Sink.Add(stats.Sample{Value: v})
+	}
+	duration.Thresholds = stats.NewThresholds([]string{"p(99) < baseline * 1.1", "med < 1000"})
+	require.NoError(t, duration.Thresholds.Parse())
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{duration.Name: duration},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.options = {summaryTrendStats: ["avg", "max"]};
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			return {"values.json": JSON.stringify(data.metrics.http_req_duration.values)};
+		};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	exported, err := ioutil.ReadAll(result["values.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"avg": 250, "max": 400, "med": 250, "p(99)": 397}`, string(exported))
+}
+
+func TestTextSummaryHistogram(t *testing.T) {
+	t.Parallel()
+
+	histogram := stats.New("my_histogram", stats.Histogram, stats.Time)
+	for _, v := range []float64{20, 120, 400} {
+		histogram.Sink.Add(stats.Sample{Value: v})
+	}
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{histogram.Name: histogram},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`exports.default = function() {/* we don't run this, metrics are mocked */};`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	summaryOut, err := ioutil.ReadAll(result["stdout"])
+	require.NoError(t, err)
+	assert.Equal(t, "\n     my_histogram...: 3 avg=180ms max=400ms\n\n", string(summaryOut))
+}
+
+func TestTextSummaryUnits(t *testing.T) {
+	t.Parallel()
+
+	uploaded := stats.New("uploaded", stats.Counter, stats.Data)
+	uploaded.Sink.Add(stats.Sample{Value: 2400000})
+	cpu := stats.New("cpu", stats.Gauge, stats.Percent)
+	cpu.Sink.Add(stats.Sample{Value: 45.5})
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{uploaded.Name: uploaded, cpu.Name: cpu},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`exports.default = function() {/* we don't run this, metrics are mocked */};`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	summaryOut, err := ioutil.ReadAll(result["stdout"])
+	require.NoError(t, err)
+	assert.Equal(t, "\n"+
+		"     cpu........: 45.5%  min=45.5% max=45.5% ewma=45.5%\n"+
+		"     uploaded...: 2.4 MB 2.4 MB/s\n\n", string(summaryOut))
+}
+
+func TestSummaryTimeline(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: 20 * time.Second,
+		Timeline: &lib.SummaryTimeline{
+			Interval: 10 * time.Second,
+			Metrics: map[string][]lib.SummaryTimelinePoint{
+				"http_reqs": {
+					{Time: start, Values: map[string]float64{"count": 20, "rate": 2}},
+					{Time: start.Add(10 * time.Second), Values: map[string]float64{"count": 5, "rate": 0.5}},
+				},
+			},
+		},
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			return {"timeline.json": JSON.stringify(data.timeline)};
+		};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	exported, err := ioutil.ReadAll(result["timeline.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"interval_ms": 10000,
+		"metrics": {
+			"http_reqs": [
+				{"time": "2021-06-01T12:00:00Z", "values": {"count": 20, "rate": 2}},
+				{"time": "2021-06-01T12:00:10Z", "values": {"count": 5, "rate": 0.5}}
+			]
+		}
+	}`, string(exported))
+}
+
+func TestSummaryAnomalies(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{},
+		RootGroup:       &lib.Group{},
+		TestRunDuration: time.Minute,
+		Anomalies: []lib.Event{{
+			Type: lib.EventAnomalyDetected,
+			Time: start,
+			Data: map[string]interface{}{
+				"kind":        "throughput-collapse",
+				"value":       2.0,
+				"end":         start.Add(10 * time.Second),
+				"description": "The requests collapsed to 2, from 20.0 on average, per 10s",
+			},
+		}},
+	}
+
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`exports.default = function() {/* we don't run this, metrics are mocked */};`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	summaryOut, err := ioutil.ReadAll(result["stdout"])
+	require.NoError(t, err)
+	assert.Equal(t,
+		"\n\n   anomalies:\n     ! 2021-06-01T12:00:00Z The requests collapsed to 2, from 20.0 on average, per 10s\n\n",
+		string(summaryOut))
+
+	runner, err = getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			return {"anomalies.json": JSON.stringify(data.anomalies)};
+		};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err = runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	exported, err := ioutil.ReadAll(result["anomalies.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `[{
+		"time": "2021-06-01T12:00:00Z",
+		"end": "2021-06-01T12:00:10Z",
+		"kind": "throughput-collapse",
+		"value": 2,
+		"description": "The requests collapsed to 2, from 20.0 on average, per 10s"
+	}]`, string(exported))
+}
+
+func TestTextSummaryCheckFailureMessages(t *testing.T) {
+	t.Parallel()
+
+	rootG, err := lib.NewGroup("", nil)
+	require.NoError(t, err)
+	check, err := rootG.Check("status is 200")
+	require.NoError(t, err)
+	check.Passes = 1
+	check.Fails = 3
+	check.AddFailureMessage("status 500 from https://example.com/", 2)
+	check.AddFailureMessage("status 404 from https://example.com/missing", 2)
+	check.AddFailureCapture("captures/0001-status_is_200.json")
+
+	summary := &lib.Summary{
+		Metrics:         map[string]*stats.Metric{},
+		RootGroup:       rootG,
+		TestRunDuration: time.Second,
+	}
+
+	runner, err := getSimpleRunner(
+		t,
+		"/script.js",
+		"exports.default = function() {/* we don't run this, metrics are mocked */};",
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	summaryOut, err := ioutil.ReadAll(result["stdout"])
+	require.NoError(t, err)
+	expected := "     ✗ status is 200\n      ↳  25% — ✓ 1 / ✗ 3\n" +
+		"         ✗ status 500 from https://example.com/\n" +
+		"         ✗ status 404 from https://example.com/missing\n" +
+		"         saved in captures/0001-status_is_200.json\n\n"
+	assert.Equal(t, "\n"+expected+"\n", string(summaryOut))
+
+	runner, err = getSimpleRunner(
+		t,
+		"/script.js",
+		`
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		exports.handleSummary = function(data) {
+			return {
+				'messages.json': JSON.stringify(data.root_group.checks[0].failure_messages),
+				'captures.json': JSON.stringify(data.root_group.checks[0].failure_captures),
+			};
+		};
+		`,
+		lib.RuntimeOptions{CompatibilityMode: null.NewString("base", true)},
+	)
+	require.NoError(t, err)
+	result, err = runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+	messages, err := ioutil.ReadAll(result["messages.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `["status 500 from https://example.com/","status 404 from https://example.com/missing"]`,
+		string(messages))
+	captures, err := ioutil.ReadAll(result["captures.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `["captures/0001-status_is_200.json"]`, string(captures))
+}
+
 func createTestMetrics(t *testing.T) (map[string]*stats.Metric, *lib.Group) {
 	metrics := make(map[string]*stats.Metric)
 	gaugeMetric := stats.New("vus", stats.Gauge)
@@ -292,7 +747,8 @@ const expectedOldJSONExportResult = `{
         "vus": {
             "value": 1,
             "min": 1,
-            "max": 1
+            "max": 1,
+            "ewma": 1
         }
     }
 }
@@ -308,7 +764,7 @@ func TestOldJSONExport(t *testing.T) {
 		`,
 		lib.RuntimeOptions{
 			CompatibilityMode: null.NewString("base", true),
-			SummaryExport:     null.StringFrom("result.json"),
+			SummaryExport:     []string{"result.json"},
 		},
 	)
 
@@ -329,6 +785,51 @@ func TestOldJSONExport(t *testing.T) {
 	assert.JSONEq(t, expectedOldJSONExportResult, string(jsonExport))
 }
 
+func TestMultipleSummaryExports(t *testing.T) {
+	t.Parallel()
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.options = {summaryTrendStats: ["avg", "min", "med", "max", "p(90)", "p(95)", "p(99)", "count"]};
+		exports.default = function() {/* we don't run this, metrics are mocked */};
+		`,
+		lib.RuntimeOptions{
+			CompatibilityMode: null.NewString("base", true),
+			SummaryExport:     []string{"result.json", "junit=thresholds.txt", "html=report.html", "markdown=report.md"},
+		},
+	)
+	require.NoError(t, err)
+
+	result, err := runner.HandleSummary(context.Background(), createTestSummary(t))
+	require.NoError(t, err)
+	require.Len(t, result, 5)
+
+	read := func(path string) string {
+		require.NotNil(t, result[path], path)
+		data, err := ioutil.ReadAll(result[path])
+		require.NoError(t, err)
+		return string(data)
+	}
+	assert.JSONEq(t, expectedOldJSONExportResult, read("result.json"))
+
+	junit := read("thresholds.txt")
+	assert.Contains(t, junit, `<testsuites tests="3" failures="2">`)
+	assert.Contains(t, junit, `<testcase name="checks - rate&gt;70" />`)
+	assert.Contains(t, junit, `<testcase name="my_trend - my_trend&lt;1000">`+
+		`<failure message="my_trend - my_trend&lt;1000 has failed" /></testcase>`)
+
+	html := read("report.html")
+	assert.Contains(t, html, `<tr class="failed"><td>child :: check2</td><td>5</td><td>10</td></tr>`)
+	assert.Contains(t, html, `<tr><td>http_reqs</td><td>3 3/s</td><td>`+
+		`<span class="failed">✗ rate&lt;100</span></td></tr>`)
+
+	markdown := read("report.md")
+	assert.Contains(t, markdown, "| ✓ | child :: check1 | 30 | 0 |\n")
+	assert.Contains(t, markdown, "| vus | 1 min=1 max=1 ewma=1 |  |\n")
+	assert.Contains(t, markdown, "| my_trend | avg=15ms min=10ms med=15ms max=20ms p(90)=19ms p(95)=19.5ms "+
+		"p(99)=19.89ms count=3 | ✗ my_trend<1000 |\n")
+}
+
 const expectedHandleSummaryRawData = `
 {
     "root_group": {
@@ -426,7 +927,8 @@ const expectedHandleSummaryRawData = `
             "values": {
                 "value": 1,
                 "min": 1,
-                "max": 1
+                "max": 1,
+                "ewma": 1
             },
             "type": "gauge"
         },
@@ -544,7 +1046,8 @@ const expectedHandleSummaryDataWithSetup = `
             "values": {
                 "value": 1,
                 "min": 1,
-                "max": 1
+                "max": 1,
+                "ewma": 1
             },
             "type": "gauge"
         },
@@ -578,7 +1081,7 @@ func TestRawHandleSummaryData(t *testing.T) {
 		lib.RuntimeOptions{
 			CompatibilityMode: null.NewString("base", true),
 			// we still want to check this
-			SummaryExport: null.StringFrom("old-export.json"),
+			SummaryExport: []string{"old-export.json"},
 		},
 	)
 
@@ -627,6 +1130,36 @@ func TestRawHandleSummaryDataWithSetupData(t *testing.T) {
 	assert.JSONEq(t, expectedHandleSummaryDataWithSetup, string(dataWithSetup))
 }
 
+func TestHandleSummaryMetadata(t *testing.T) {
+	t.Parallel()
+	runner, err := getSimpleRunner(
+		t, "/script.js",
+		`
+		exports.default = function() { /* we don't run this, metrics are mocked */ };
+		exports.handleSummary = function(data) {
+			return {'metadata.json': JSON.stringify(data.metadata)};
+		};
+		`,
+		lib.RuntimeOptions{SummaryExport: []string{"old-export.json"}},
+	)
+	require.NoError(t, err)
+
+	summary := createTestSummary(t)
+	summary.Metadata = map[string]string{"cloud_provider": "gcp", "k8s_pod": "k6-agent-1"}
+	result, err := runner.HandleSummary(context.Background(), summary)
+	require.NoError(t, err)
+
+	metadata, err := ioutil.ReadAll(result["metadata.json"])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"cloud_provider": "gcp", "k8s_pod": "k6-agent-1"}`, string(metadata))
+
+	oldExport, err := ioutil.ReadAll(result["old-export.json"])
+	require.NoError(t, err)
+	var exported map[string]interface{}
+	require.NoError(t, json.Unmarshal(oldExport, &exported))
+	assert.Equal(t, map[string]interface{}{"cloud_provider": "gcp", "k8s_pod": "k6-agent-1"}, exported["metadata"])
+}
+
 func TestWrongSummaryHandlerExportTypes(t *testing.T) {
 	t.Parallel()
 	testCases := []string{"{}", `"foo"`, "null", "undefined", "123"}
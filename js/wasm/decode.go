@@ -0,0 +1,1017 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package wasm implements a small interpreter of WebAssembly modules, so
+// scripts can import .wasm files that are instantiated for every VU. It
+// supports the MVP instruction set with the sign-extension, non-trapping
+// float-to-int and bulk memory (copy and fill) extensions, without any host
+// imports.
+//
+// The support of WebAssembly modules is experimental, and so is this
+// package: it's meant for the self-contained modules of the scripts, e.g.
+// the ones generating or validating payloads, it's neither a JIT nor a
+// general purpose runtime, and its API may change or be replaced by a
+// vendored runtime in a later release. Its decoding and validation, which
+// every module goes through before any of its code runs, are covered by the
+// FuzzDecode and FuzzValidate fuzz tests.
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// ValueType is the type of a WebAssembly value.
+type ValueType byte
+
+// The value types of WebAssembly.
+const (
+	I32     ValueType = 0x7f
+	I64     ValueType = 0x7e
+	F32     ValueType = 0x7d
+	F64     ValueType = 0x7c
+	FuncRef ValueType = 0x70
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case I32:
+		return "i32"
+	case I64:
+		return "i64"
+	case F32:
+		return "f32"
+	case F64:
+		return "f64"
+	case FuncRef:
+		return "funcref"
+	default:
+		return fmt.Sprintf("0x%x", byte(t))
+	}
+}
+
+// FuncType is the signature of a function.
+type FuncType struct {
+	Params, Results []ValueType
+}
+
+// ExportKind is the kind of an exported entity.
+type ExportKind byte
+
+// The kinds of exports.
+const (
+	ExportFunc ExportKind = iota
+	ExportTable
+	ExportMemory
+	ExportGlobal
+)
+
+// Export is an entity exported by a module.
+type Export struct {
+	Name  string
+	Kind  ExportKind
+	Index uint32
+}
+
+// Import is an entity imported by a module, which is only kept so it can be
+// reported, as there are no host imports.
+type Import struct {
+	Module, Name string
+}
+
+type limits struct {
+	min, max uint32
+	hasMax   bool
+}
+
+type global struct {
+	typ     ValueType
+	mutable bool
+	init    constExpr
+}
+
+// constExpr is a constant expression, either a value or a global.get.
+type constExpr struct {
+	value     uint64
+	global    uint32
+	isGlobal  bool
+	valueType ValueType
+}
+
+type segment struct {
+	offset constExpr
+	active bool
+	funcs  []uint32
+	data   []byte
+}
+
+type function struct {
+	typ      *FuncType
+	locals   []ValueType
+	code     []instr
+	brTables [][]uint32
+}
+
+// Module is a decoded and validated WebAssembly module, which can be
+// instantiated multiple times.
+type Module struct {
+	types     []FuncType
+	imports   []Import
+	functions []function
+	table     *limits
+	memory    *limits
+	globals   []global
+	exports   []Export
+	start     *uint32
+	elements  []segment
+	data      []segment
+}
+
+// Exports returns the entities exported by the module.
+func (m *Module) Exports() []Export {
+	return m.exports
+}
+
+// FuncType returns the signature of the exported function name.
+func (m *Module) FuncType(name string) (*FuncType, bool) {
+	for _, e := range m.exports {
+		if e.Name == name && e.Kind == ExportFunc {
+			return m.functions[e.Index].typ, true
+		}
+	}
+	return nil, false
+}
+
+const (
+	magic   = "\x00asm"
+	version = 1
+
+	maxPages = 65536
+	pageSize = 65536
+)
+
+var errUnexpectedEnd = errors.New("unexpected end of the module")
+
+type reader struct {
+	buf []byte
+	pos int
+}
+
+func (r *reader) eof() bool {
+	return r.pos >= len(r.buf)
+}
+
+func (r *reader) byte() (byte, error) {
+	if r.eof() {
+		return 0, errUnexpectedEnd
+	}
+	b := r.buf[r.pos]
+	r.pos++
+	return b, nil
+}
+
+func (r *reader) bytes(n uint32) ([]byte, error) {
+	if uint64(r.pos)+uint64(n) > uint64(len(r.buf)) {
+		return nil, errUnexpectedEnd
+	}
+	b := r.buf[r.pos : r.pos+int(n)]
+	r.pos += int(n)
+	return b, nil
+}
+
+func (r *reader) u32() (uint32, error) {
+	var result uint32
+	for shift := 0; shift < 35; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		result |= uint32(b&0x7f) << shift
+		if b&0x80 == 0 {
+			return result, nil
+		}
+	}
+	return 0, errors.New("integer representation too long")
+}
+
+func (r *reader) signed(size int) (int64, error) {
+	var result int64
+	for shift := 0; shift < size+7; shift += 7 {
+		b, err := r.byte()
+		if err != nil {
+			return 0, err
+		}
+		result |= int64(b&0x7f) << shift
+		if b&0x80 == 0 {
+			if shift+7 < 64 && b&0x40 != 0 {
+				result |= -1 << (shift + 7)
+			}
+			return result, nil
+		}
+	}
+	return 0, errors.New("integer representation too long")
+}
+
+func (r *reader) name() (string, error) {
+	n, err := r.u32()
+	if err != nil {
+		return "", err
+	}
+	b, err := r.bytes(n)
+	return string(b), err
+}
+
+func (r *reader) valueType() (ValueType, error) {
+	b, err := r.byte()
+	if err != nil {
+		return 0, err
+	}
+	switch t := ValueType(b); t {
+	case I32, I64, F32, F64, FuncRef:
+		return t, nil
+	default:
+		return 0, fmt.Errorf("unsupported value type 0x%x", b)
+	}
+}
+
+func (r *reader) limits() (*limits, error) {
+	flag, err := r.byte()
+	if err != nil {
+		return nil, err
+	}
+	l := &limits{}
+	if l.min, err = r.u32(); err != nil {
+		return nil, err
+	}
+	switch flag {
+	case 0:
+	case 1:
+		l.hasMax = true
+		if l.max, err = r.u32(); err != nil {
+			return nil, err
+		}
+		if l.max < l.min {
+			return nil, errors.New("the maximum size is lower than the minimum")
+		}
+	default:
+		return nil, fmt.Errorf("unsupported limits flag 0x%x", flag)
+	}
+	return l, nil
+}
+
+func (r *reader) constExpr() (constExpr, error) {
+	op, err := r.byte()
+	if err != nil {
+		return constExpr{}, err
+	}
+	var c constExpr
+	switch op {
+	case opI32Const:
+		v, err := r.signed(32)
+		if err != nil {
+			return c, err
+		}
+		c.value, c.valueType = uint64(uint32(v)), I32
+	case opI64Const:
+		v, err := r.signed(64)
+		if err != nil {
+			return c, err
+		}
+		c.value, c.valueType = uint64(v), I64
+	case opF32Const:
+		b, err := r.bytes(4)
+		if err != nil {
+			return c, err
+		}
+		c.value, c.valueType = uint64(binary.LittleEndian.Uint32(b)), F32
+	case opF64Const:
+		b, err := r.bytes(8)
+		if err != nil {
+			return c, err
+		}
+		c.value, c.valueType = binary.LittleEndian.Uint64(b), F64
+	case opGlobalGet:
+		if c.global, err = r.u32(); err != nil {
+			return c, err
+		}
+		c.isGlobal = true
+	default:
+		return c, fmt.Errorf("unsupported constant expression opcode 0x%x", op)
+	}
+	if end, err := r.byte(); err != nil || end != opEnd {
+		return c, errors.New("constant expression isn't terminated with end")
+	}
+	return c, nil
+}
+
+// Decode decodes and validates the binary WebAssembly module in data.
+func Decode(data []byte) (*Module, error) {
+	if len(data) < 8 || !bytes.Equal(data[:4], []byte(magic)) {
+		return nil, errors.New("not a WebAssembly module, the magic header is missing")
+	}
+	if v := binary.LittleEndian.Uint32(data[4:8]); v != version {
+		return nil, fmt.Errorf("unsupported WebAssembly version %d", v)
+	}
+
+	m := &Module{}
+	r := &reader{buf: data, pos: 8}
+	var funcTypes []uint32
+	var bodies [][]byte
+	for !r.eof() {
+		id, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		content, err := r.bytes(size)
+		if err != nil {
+			return nil, err
+		}
+		sr := &reader{buf: content}
+		switch id {
+		case 0, 12: // custom and data count sections
+			continue
+		case 1:
+			err = m.decodeTypes(sr)
+		case 2:
+			err = m.decodeImports(sr)
+		case 3:
+			funcTypes, err = sr.indexes()
+		case 4:
+			err = m.decodeTable(sr)
+		case 5:
+			err = m.decodeMemory(sr)
+		case 6:
+			err = m.decodeGlobals(sr)
+		case 7:
+			err = m.decodeExports(sr)
+		case 8:
+			var start uint32
+			start, err = sr.u32()
+			m.start = &start
+		case 9:
+			err = m.decodeElements(sr)
+		case 10:
+			bodies, err = decodeBodies(sr)
+		case 11:
+			err = m.decodeData(sr)
+		default:
+			return nil, fmt.Errorf("unknown section id %d", id)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid section %d: %w", id, err)
+		}
+		if !sr.eof() {
+			return nil, fmt.Errorf("invalid section %d: unexpected trailing bytes", id)
+		}
+	}
+
+	if len(funcTypes) != len(bodies) {
+		return nil, errors.New("the function and code sections have different lengths")
+	}
+	m.functions = make([]function, len(funcTypes))
+	for i, typeIdx := range funcTypes {
+		if int(typeIdx) >= len(m.types) {
+			return nil, fmt.Errorf("function %d has an invalid type index %d", i, typeIdx)
+		}
+		m.functions[i].typ = &m.types[typeIdx]
+	}
+	for i, body := range bodies {
+		if err := m.decodeFunction(&m.functions[i], body); err != nil {
+			return nil, fmt.Errorf("invalid function %d: %w", i, err)
+		}
+	}
+	return m, m.validateIndexes()
+}
+
+// count reads the length of a vector, which has at least a byte per item.
+func (r *reader) count() (int, error) {
+	n, err := r.u32()
+	if err != nil {
+		return 0, err
+	}
+	if uint64(n) > uint64(len(r.buf)-r.pos) {
+		return 0, errUnexpectedEnd
+	}
+	return int(n), nil
+}
+
+func (r *reader) indexes() ([]uint32, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]uint32, n)
+	for i := range result {
+		if result[i], err = r.u32(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func (r *reader) valueTypes() ([]ValueType, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	result := make([]ValueType, n)
+	for i := range result {
+		if result[i], err = r.valueType(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+func decodeBodies(r *reader) ([][]byte, error) {
+	n, err := r.count()
+	if err != nil {
+		return nil, err
+	}
+	bodies := make([][]byte, n)
+	for i := range bodies {
+		size, err := r.u32()
+		if err != nil {
+			return nil, err
+		}
+		if bodies[i], err = r.bytes(size); err != nil {
+			return nil, err
+		}
+	}
+	return bodies, nil
+}
+
+func (m *Module) decodeTypes(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	m.types = make([]FuncType, n)
+	for i := range m.types {
+		if form, err := r.byte(); err != nil || form != 0x60 {
+			return errors.New("invalid function type")
+		}
+		if m.types[i].Params, err = r.valueTypes(); err != nil {
+			return err
+		}
+		if m.types[i].Results, err = r.valueTypes(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Module) decodeImports(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	m.imports = make([]Import, n)
+	for i := range m.imports {
+		imp := &m.imports[i]
+		if imp.Module, err = r.name(); err != nil {
+			return err
+		}
+		if imp.Name, err = r.name(); err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		switch kind {
+		case 0:
+			_, err = r.u32()
+		case 1:
+			if _, err = r.valueType(); err == nil {
+				_, err = r.limits()
+			}
+		case 2:
+			_, err = r.limits()
+		case 3:
+			if _, err = r.valueType(); err == nil {
+				_, err = r.byte()
+			}
+		default:
+			err = fmt.Errorf("unknown import kind %d", kind)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Module) decodeTable(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	if n > 1 {
+		return errors.New("multiple tables aren't supported")
+	}
+	if n == 1 {
+		if t, err := r.valueType(); err != nil || t != FuncRef {
+			return errors.New("only funcref tables are supported")
+		}
+		m.table, err = r.limits()
+	}
+	return err
+}
+
+func (m *Module) decodeMemory(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	if n > 1 {
+		return errors.New("multiple memories aren't supported")
+	}
+	if n == 1 {
+		if m.memory, err = r.limits(); err != nil {
+			return err
+		}
+		if m.memory.min > maxPages || (m.memory.hasMax && m.memory.max > maxPages) {
+			return errors.New("the memory size must be at most 65536 pages (4GiB)")
+		}
+	}
+	return nil
+}
+
+func (m *Module) decodeGlobals(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	m.globals = make([]global, n)
+	for i := range m.globals {
+		g := &m.globals[i]
+		if g.typ, err = r.valueType(); err != nil {
+			return err
+		}
+		mut, err := r.byte()
+		if err != nil {
+			return err
+		}
+		g.mutable = mut == 1
+		if g.init, err = r.constExpr(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Module) decodeExports(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	m.exports = make([]Export, n)
+	for i := range m.exports {
+		e := &m.exports[i]
+		if e.Name, err = r.name(); err != nil {
+			return err
+		}
+		kind, err := r.byte()
+		if err != nil {
+			return err
+		}
+		if kind > byte(ExportGlobal) {
+			return fmt.Errorf("unknown export kind %d", kind)
+		}
+		e.Kind = ExportKind(kind)
+		if e.Index, err = r.u32(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Module) decodeElements(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	m.elements = make([]segment, n)
+	for i := range m.elements {
+		if m.elements[i], err = decodeElement(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeElement(r *reader) (segment, error) {
+	var s segment
+	flag, err := r.u32()
+	if err != nil {
+		return s, err
+	}
+	switch flag {
+	case 0: // active with the implicit table 0
+		s.active = true
+		if s.offset, err = r.constExpr(); err != nil {
+			return s, err
+		}
+	case 1, 3: // passive or declarative
+		if kind, err := r.byte(); err != nil || kind != 0 {
+			return s, errors.New("invalid element kind")
+		}
+	case 2: // active with an explicit table
+		s.active = true
+		if table, err := r.u32(); err != nil || table != 0 {
+			return s, errors.New("invalid element table index")
+		}
+		if s.offset, err = r.constExpr(); err != nil {
+			return s, err
+		}
+		if kind, err := r.byte(); err != nil || kind != 0 {
+			return s, errors.New("invalid element kind")
+		}
+	default:
+		return s, fmt.Errorf("unsupported element segment flag %d", flag)
+	}
+	s.funcs, err = r.indexes()
+	return s, err
+}
+
+func (m *Module) decodeData(r *reader) error {
+	n, err := r.count()
+	if err != nil {
+		return err
+	}
+	m.data = make([]segment, n)
+	for i := range m.data {
+		if m.data[i], err = decodeDataSegment(r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func decodeDataSegment(r *reader) (segment, error) {
+	var s segment
+	flag, err := r.u32()
+	if err != nil {
+		return s, err
+	}
+	switch flag {
+	case 0:
+		s.active = true
+		s.offset, err = r.constExpr()
+	case 1:
+	case 2:
+		s.active = true
+		if mem, err := r.u32(); err != nil || mem != 0 {
+			return s, errors.New("invalid data memory index")
+		}
+		s.offset, err = r.constExpr()
+	default:
+		return s, fmt.Errorf("unsupported data segment flag %d", flag)
+	}
+	if err != nil {
+		return s, err
+	}
+	n, err := r.u32()
+	if err != nil {
+		return s, err
+	}
+	s.data, err = r.bytes(n)
+	return s, err
+}
+
+func (m *Module) validateIndexes() error {
+	for _, e := range m.exports {
+		var count int
+		switch e.Kind {
+		case ExportFunc:
+			count = len(m.functions)
+		case ExportTable:
+			if m.table != nil {
+				count = 1
+			}
+		case ExportMemory:
+			if m.memory != nil {
+				count = 1
+			}
+		case ExportGlobal:
+			count = len(m.globals)
+		}
+		if int(e.Index) >= count {
+			return fmt.Errorf("export %q has an invalid index %d", e.Name, e.Index)
+		}
+	}
+	if m.start != nil {
+		if int(*m.start) >= len(m.functions) {
+			return fmt.Errorf("invalid start function index %d", *m.start)
+		}
+		if t := m.functions[*m.start].typ; len(t.Params) != 0 || len(t.Results) != 0 {
+			return errors.New("the start function must not have any params or results")
+		}
+	}
+	for _, s := range m.elements {
+		if m.table == nil && s.active {
+			return errors.New("an element segment is defined without a table")
+		}
+		for _, f := range s.funcs {
+			if int(f) >= len(m.functions) {
+				return fmt.Errorf("element segment has an invalid function index %d", f)
+			}
+		}
+	}
+	for _, s := range m.data {
+		if m.memory == nil && s.active {
+			return errors.New("a data segment is defined without a memory")
+		}
+	}
+	for i, g := range m.globals {
+		if g.init.isGlobal {
+			return errors.New("global initializers referring other globals aren't supported")
+		}
+		if g.init.valueType != g.typ {
+			return fmt.Errorf("global %d of type %s is initialized with a %s", i, g.typ, g.init.valueType)
+		}
+	}
+	for _, segments := range [][]segment{m.elements, m.data} {
+		for _, s := range segments {
+			if !s.active {
+				continue
+			}
+			if s.offset.isGlobal && (int(s.offset.global) >= len(m.globals) || m.globals[s.offset.global].typ != I32) ||
+				!s.offset.isGlobal && s.offset.valueType != I32 {
+				return errors.New("the offset of a segment must be an i32")
+			}
+		}
+	}
+	return nil
+}
+
+// instr is a decoded instruction, whose immediates are resolved in advance,
+// including the positions of the ends of the blocks.
+type instr struct {
+	op uint16
+	// a is the index of the end of a block, the label depth of a branch,
+	// the index of a function, local or global, the memory offset or the
+	// index of the br_table labels.
+	a uint32
+	// b is the index of the else of an if block.
+	b uint32
+	// c is a constant value or the number of params and results of a block.
+	c uint64
+}
+
+// blockArity packs the number of params and results of a block.
+func blockArity(t *FuncType) uint64 {
+	return uint64(len(t.Params))<<32 | uint64(len(t.Results))
+}
+
+func (m *Module) blockType(r *reader) (*FuncType, error) {
+	if r.eof() {
+		return nil, errUnexpectedEnd
+	}
+	switch b := r.buf[r.pos]; {
+	case b == 0x40:
+		r.pos++
+		return &FuncType{}, nil
+	case b == byte(I32), b == byte(I64), b == byte(F32), b == byte(F64):
+		r.pos++
+		return &FuncType{Results: []ValueType{ValueType(b)}}, nil
+	}
+	idx, err := r.signed(33)
+	if err != nil {
+		return nil, err
+	}
+	if idx < 0 || idx >= int64(len(m.types)) {
+		return nil, fmt.Errorf("invalid block type %d", idx)
+	}
+	return &m.types[idx], nil
+}
+
+func (m *Module) decodeFunction(f *function, body []byte) error {
+	r := &reader{buf: body}
+	groups, err := r.u32()
+	if err != nil {
+		return err
+	}
+	for ; groups > 0; groups-- {
+		n, err := r.u32()
+		if err != nil {
+			return err
+		}
+		t, err := r.valueType()
+		if err != nil {
+			return err
+		}
+		if uint64(len(f.locals))+uint64(n) > 50000 {
+			return errors.New("too many locals")
+		}
+		for ; n > 0; n-- {
+			f.locals = append(f.locals, t)
+		}
+	}
+
+	v := newValidator(m, f)
+	var blocks []int // the indexes of the open block, loop and if instructions
+	for !r.eof() {
+		op, err := r.byte()
+		if err != nil {
+			return err
+		}
+		in := instr{op: uint16(op)}
+		var bt *FuncType
+		switch {
+		case op == opBlock || op == opLoop || op == opIf:
+			if bt, err = m.blockType(r); err != nil {
+				return err
+			}
+			in.c = blockArity(bt)
+			blocks = append(blocks, len(f.code))
+		case op == opElse:
+			if len(blocks) == 0 || f.code[blocks[len(blocks)-1]].op != opIf {
+				return errors.New("else without if")
+			}
+			f.code[blocks[len(blocks)-1]].b = uint32(len(f.code))
+		case op == opEnd:
+			if len(blocks) == 0 {
+				if !r.eof() {
+					return errors.New("instructions after the end of the function")
+				}
+				f.code = append(f.code, in)
+				return v.instr(in, nil)
+			}
+			start := blocks[len(blocks)-1]
+			blocks = blocks[:len(blocks)-1]
+			f.code[start].a = uint32(len(f.code))
+			if f.code[start].op == opIf && f.code[start].b != 0 {
+				f.code[f.code[start].b].a = uint32(len(f.code))
+			}
+		case op == opBr || op == opBrIf:
+			if in.a, err = r.u32(); err != nil {
+				return err
+			}
+			if int(in.a) > len(blocks) {
+				return fmt.Errorf("invalid branch depth %d", in.a)
+			}
+		case op == opBrTable:
+			labels, err := r.indexes()
+			if err != nil {
+				return err
+			}
+			def, err := r.u32()
+			if err != nil {
+				return err
+			}
+			labels = append(labels, def)
+			for _, l := range labels {
+				if int(l) > len(blocks) {
+					return fmt.Errorf("invalid branch depth %d", l)
+				}
+			}
+			in.a = uint32(len(f.brTables))
+			f.brTables = append(f.brTables, labels)
+		case op == opCall:
+			if in.a, err = r.u32(); err != nil {
+				return err
+			}
+			if int(in.a) >= len(m.functions) {
+				return fmt.Errorf("invalid function index %d", in.a)
+			}
+		case op == opCallIndirect:
+			if in.a, err = r.u32(); err != nil {
+				return err
+			}
+			if int(in.a) >= len(m.types) {
+				return fmt.Errorf("invalid type index %d", in.a)
+			}
+			if table, err := r.u32(); err != nil || table != 0 || m.table == nil {
+				return errors.New("call_indirect requires the table 0")
+			}
+		case op == opSelectT:
+			if n, err := r.u32(); err != nil || n != 1 {
+				return errors.New("invalid select type")
+			}
+			if _, err = r.valueType(); err != nil {
+				return err
+			}
+			in.op = opSelect
+		case op >= opLocalGet && op <= opLocalTee:
+			if in.a, err = r.u32(); err != nil {
+				return err
+			}
+			if int(in.a) >= len(f.typ.Params)+len(f.locals) {
+				return fmt.Errorf("invalid local index %d", in.a)
+			}
+		case op == opGlobalGet || op == opGlobalSet:
+			if in.a, err = r.u32(); err != nil {
+				return err
+			}
+			if int(in.a) >= len(m.globals) {
+				return fmt.Errorf("invalid global index %d", in.a)
+			}
+			if op == opGlobalSet && !m.globals[in.a].mutable {
+				return fmt.Errorf("global %d is immutable", in.a)
+			}
+		case op >= opI32Load && op <= opI64Store32:
+			if m.memory == nil {
+				return errors.New("memory instruction without a memory")
+			}
+			if _, err = r.u32(); err != nil { // alignment, which is only a hint
+				return err
+			}
+			if in.a, err = r.u32(); err != nil {
+				return err
+			}
+		case op == opMemorySize || op == opMemoryGrow:
+			if m.memory == nil {
+				return errors.New("memory instruction without a memory")
+			}
+			if _, err = r.byte(); err != nil {
+				return err
+			}
+		case op == opI32Const:
+			v, err := r.signed(32)
+			if err != nil {
+				return err
+			}
+			in.c = uint64(uint32(v))
+		case op == opI64Const:
+			v, err := r.signed(64)
+			if err != nil {
+				return err
+			}
+			in.c = uint64(v)
+		case op == opF32Const:
+			b, err := r.bytes(4)
+			if err != nil {
+				return err
+			}
+			in.c = uint64(binary.LittleEndian.Uint32(b))
+		case op == opF64Const:
+			b, err := r.bytes(8)
+			if err != nil {
+				return err
+			}
+			in.c = binary.LittleEndian.Uint64(b)
+		case op == opPrefixFC:
+			sub, err := r.u32()
+			if err != nil {
+				return err
+			}
+			in.op = opFCBase + uint16(sub)
+			switch {
+			case sub <= 7:
+			case sub == 10:
+				if _, err = r.bytes(2); err != nil {
+					return err
+				}
+			case sub == 11:
+				if _, err = r.byte(); err != nil {
+					return err
+				}
+			default:
+				return fmt.Errorf("unsupported instruction 0xfc %d", sub)
+			}
+			if sub >= 10 && m.memory == nil {
+				return errors.New("memory instruction without a memory")
+			}
+		case op == opUnreachable, op == opNop, op == opReturn, op == opDrop, op == opSelect,
+			op >= opI32Eqz && op <= opI64Extend32S:
+		default:
+			return fmt.Errorf("unsupported instruction 0x%x", op)
+		}
+		if err := v.instr(in, bt); err != nil {
+			return err
+		}
+		f.code = append(f.code, in)
+	}
+	return errors.New("the function body isn't terminated with end")
+}
+
+func float32Value(v uint64) float32 { return math.Float32frombits(uint32(v)) }
+func float64Value(v uint64) float64 { return math.Float64frombits(v) }
+func float32Bits(f float32) uint64  { return uint64(math.Float32bits(f)) }
+func float64Bits(f float64) uint64  { return math.Float64bits(f) }
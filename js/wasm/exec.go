@@ -0,0 +1,827 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wasm
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// maxCallDepth limits the recursion of the wasm functions, so it traps
+// instead of exhausting the stack of the goroutine.
+const maxCallDepth = 10000
+
+// Trap is the error returned when the execution of a function traps.
+type Trap struct {
+	Reason string
+}
+
+func (t *Trap) Error() string {
+	return "wasm trap: " + t.Reason
+}
+
+func trap(format string, args ...interface{}) {
+	panic(&Trap{Reason: fmt.Sprintf(format, args...)})
+}
+
+type label struct {
+	pc     int
+	height int
+	arity  int
+	loop   bool
+}
+
+// Instance is an instantiated module with its own memory, globals and table.
+// It isn't safe for concurrent use, as it's meant to be used by a single VU.
+type Instance struct {
+	module   *Module
+	memory   []byte
+	maxPages uint32
+	globals  []uint64
+	table    []int32
+
+	stack  []uint64
+	labels []label
+	depth  int
+	ctx    context.Context
+	steps  uint32
+}
+
+// NewInstance instantiates the module, initializing its memory, globals and
+// table and running its start function.
+func NewInstance(ctx context.Context, m *Module) (*Instance, error) {
+	if len(m.imports) > 0 {
+		return nil, fmt.Errorf("the module imports %s.%s, but imports aren't supported",
+			m.imports[0].Module, m.imports[0].Name)
+	}
+	in := &Instance{module: m, globals: make([]uint64, len(m.globals))}
+	for i, g := range m.globals {
+		in.globals[i] = g.init.value
+	}
+	if m.memory != nil {
+		in.memory = make([]byte, int(m.memory.min)*pageSize)
+		in.maxPages = maxPages
+		if m.memory.hasMax {
+			in.maxPages = m.memory.max
+		}
+	}
+	if m.table != nil {
+		in.table = make([]int32, m.table.min)
+		for i := range in.table {
+			in.table[i] = -1
+		}
+	}
+	for _, s := range m.elements {
+		if !s.active {
+			continue
+		}
+		offset := uint64(uint32(in.constValue(s.offset)))
+		if offset+uint64(len(s.funcs)) > uint64(len(in.table)) {
+			return nil, fmt.Errorf("element segment at %d doesn't fit in the table", offset)
+		}
+		for i, f := range s.funcs {
+			in.table[offset+uint64(i)] = int32(f)
+		}
+	}
+	for _, s := range m.data {
+		if !s.active {
+			continue
+		}
+		offset := uint64(uint32(in.constValue(s.offset)))
+		if offset+uint64(len(s.data)) > uint64(len(in.memory)) {
+			return nil, fmt.Errorf("data segment at %d doesn't fit in the memory", offset)
+		}
+		copy(in.memory[offset:], s.data)
+	}
+	if m.start != nil {
+		if _, err := in.invoke(ctx, &m.functions[*m.start], nil); err != nil {
+			return nil, err
+		}
+	}
+	return in, nil
+}
+
+func (in *Instance) constValue(c constExpr) uint64 {
+	if c.isGlobal {
+		return in.globals[c.global]
+	}
+	return c.value
+}
+
+// Memory returns the current linear memory of the instance, which is
+// replaced by a bigger one when it grows.
+func (in *Instance) Memory() []byte {
+	return in.memory
+}
+
+// Global returns the value of the global with the index and its type.
+func (in *Instance) Global(index uint32) (uint64, ValueType) {
+	return in.globals[index], in.module.globals[index].typ
+}
+
+// Call calls the exported function name with the raw bits of the args and
+// returns the raw bits of its results. The context is checked periodically,
+// so long running functions are interrupted when it's done.
+func (in *Instance) Call(ctx context.Context, name string, args ...uint64) ([]uint64, error) {
+	for _, e := range in.module.exports {
+		if e.Name == name && e.Kind == ExportFunc {
+			f := &in.module.functions[e.Index]
+			if len(args) != len(f.typ.Params) {
+				return nil, fmt.Errorf("%s expects %d arguments, but %d were given", name, len(f.typ.Params), len(args))
+			}
+			return in.invoke(ctx, f, args)
+		}
+	}
+	return nil, fmt.Errorf("the module doesn't export a function named %q", name)
+}
+
+func (in *Instance) invoke(ctx context.Context, f *function, args []uint64) (results []uint64, err error) {
+	in.stack = append(in.stack[:0], args...)
+	in.labels, in.depth, in.ctx = in.labels[:0], 0, ctx
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case *Trap:
+			err = r
+		default:
+			// the modules are validated when they are decoded, so anything
+			// else is a bug of the interpreter
+			panic(r)
+		}
+	}()
+	in.call(f)
+	return append([]uint64(nil), in.stack...), nil
+}
+
+func (in *Instance) push(v uint64) {
+	in.stack = append(in.stack, v)
+}
+
+func (in *Instance) pop() uint64 {
+	n := len(in.stack) - 1
+	v := in.stack[n]
+	in.stack = in.stack[:n]
+	return v
+}
+
+func (in *Instance) checkInterrupt() {
+	in.steps++
+	if in.steps&0xffff == 0 && in.ctx != nil && in.ctx.Err() != nil {
+		trap("interrupted, %s", in.ctx.Err())
+	}
+}
+
+// branch unwinds the stack to the label with the depth, keeping its arity
+// values, and returns the position to continue from.
+func (in *Instance) branch(depth uint32) int {
+	idx := len(in.labels) - 1 - int(depth)
+	l := in.labels[idx]
+	n := len(in.stack)
+	copy(in.stack[l.height:], in.stack[n-l.arity:])
+	in.stack = in.stack[:l.height+l.arity]
+	if l.loop {
+		in.labels = in.labels[:idx+1]
+		in.checkInterrupt()
+	} else {
+		in.labels = in.labels[:idx]
+	}
+	return l.pc
+}
+
+func (in *Instance) address(offset uint32, size uint64) uint64 {
+	ea := uint64(uint32(in.pop())) + uint64(offset)
+	if ea+size > uint64(len(in.memory)) {
+		trap("out of bounds memory access")
+	}
+	return ea
+}
+
+func (in *Instance) call(f *function) {
+	in.depth++
+	if in.depth > maxCallDepth {
+		trap("call stack exhausted")
+	}
+	in.checkInterrupt()
+	base := len(in.stack) - len(f.typ.Params)
+	for range f.locals {
+		in.stack = append(in.stack, 0)
+	}
+	labelBase := len(in.labels)
+	code := f.code
+	in.labels = append(in.labels, label{pc: len(code), height: base, arity: len(f.typ.Results)})
+
+	for pc := 0; pc < len(code); {
+		ins := &code[pc]
+		pc++
+		switch ins.op {
+		case opUnreachable:
+			trap("unreachable")
+		case opNop:
+		case opBlock:
+			in.labels = append(in.labels, label{
+				pc: int(ins.a) + 1, height: len(in.stack) - int(ins.c>>32), arity: int(uint32(ins.c)),
+			})
+		case opLoop:
+			params := int(ins.c >> 32)
+			in.labels = append(in.labels, label{pc: pc, height: len(in.stack) - params, arity: params, loop: true})
+		case opIf:
+			l := label{pc: int(ins.a) + 1, arity: int(uint32(ins.c))}
+			cond := uint32(in.pop())
+			l.height = len(in.stack) - int(ins.c>>32)
+			switch {
+			case cond != 0:
+				in.labels = append(in.labels, l)
+			case ins.b != 0:
+				in.labels = append(in.labels, l)
+				pc = int(ins.b) + 1
+			default:
+				pc = int(ins.a) + 1
+			}
+		case opElse:
+			in.labels = in.labels[:len(in.labels)-1]
+			pc = int(ins.a) + 1
+		case opEnd:
+			in.labels = in.labels[:len(in.labels)-1]
+		case opBr:
+			pc = in.branch(ins.a)
+		case opBrIf:
+			if uint32(in.pop()) != 0 {
+				pc = in.branch(ins.a)
+			}
+		case opBrTable:
+			labels := f.brTables[ins.a]
+			i := uint32(in.pop())
+			if i >= uint32(len(labels)-1) {
+				i = uint32(len(labels) - 1)
+			}
+			pc = in.branch(labels[i])
+		case opReturn:
+			pc = in.branch(uint32(len(in.labels) - 1 - labelBase))
+		case opCall:
+			in.call(&in.module.functions[ins.a])
+		case opCallIndirect:
+			i := uint32(in.pop())
+			if i >= uint32(len(in.table)) {
+				trap("undefined table element %d", i)
+			}
+			if in.table[i] < 0 {
+				trap("uninitialized table element %d", i)
+			}
+			callee := &in.module.functions[in.table[i]]
+			if !sameType(callee.typ, &in.module.types[ins.a]) {
+				trap("indirect call type mismatch")
+			}
+			in.call(callee)
+		case opDrop:
+			in.pop()
+		case opSelect:
+			cond := uint32(in.pop())
+			b := in.pop()
+			if cond == 0 {
+				in.stack[len(in.stack)-1] = b
+			}
+		case opLocalGet:
+			in.push(in.stack[base+int(ins.a)])
+		case opLocalSet:
+			in.stack[base+int(ins.a)] = in.pop()
+		case opLocalTee:
+			in.stack[base+int(ins.a)] = in.stack[len(in.stack)-1]
+		case opGlobalGet:
+			in.push(in.globals[ins.a])
+		case opGlobalSet:
+			in.globals[ins.a] = in.pop()
+		case opI32Const, opI64Const, opF32Const, opF64Const:
+			in.push(ins.c)
+		default:
+			if ins.op >= opI32Load && ins.op <= opMemoryGrow || ins.op == opMemoryCopy || ins.op == opMemoryFill {
+				in.execMemory(ins)
+			} else {
+				in.execNumeric(ins.op)
+			}
+		}
+	}
+
+	n := len(f.typ.Results)
+	copy(in.stack[base:], in.stack[len(in.stack)-n:])
+	in.stack = in.stack[:base+n]
+	in.labels = in.labels[:labelBase]
+	in.depth--
+}
+
+func sameType(a, b *FuncType) bool {
+	if len(a.Params) != len(b.Params) || len(a.Results) != len(b.Results) {
+		return false
+	}
+	for i := range a.Params {
+		if a.Params[i] != b.Params[i] {
+			return false
+		}
+	}
+	for i := range a.Results {
+		if a.Results[i] != b.Results[i] {
+			return false
+		}
+	}
+	return true
+}
+
+//nolint:funlen,cyclop
+func (in *Instance) execMemory(ins *instr) {
+	le := binary.LittleEndian
+	switch ins.op {
+	case opI32Load, opF32Load:
+		ea := in.address(ins.a, 4)
+		in.push(uint64(le.Uint32(in.memory[ea:])))
+	case opI64Load, opF64Load:
+		ea := in.address(ins.a, 8)
+		in.push(le.Uint64(in.memory[ea:]))
+	case opI32Load8S:
+		ea := in.address(ins.a, 1)
+		in.push(uint64(uint32(int32(int8(in.memory[ea])))))
+	case opI32Load8U, opI64Load8U:
+		ea := in.address(ins.a, 1)
+		in.push(uint64(in.memory[ea]))
+	case opI32Load16S:
+		ea := in.address(ins.a, 2)
+		in.push(uint64(uint32(int32(int16(le.Uint16(in.memory[ea:]))))))
+	case opI32Load16U, opI64Load16U:
+		ea := in.address(ins.a, 2)
+		in.push(uint64(le.Uint16(in.memory[ea:])))
+	case opI64Load8S:
+		ea := in.address(ins.a, 1)
+		in.push(uint64(int64(int8(in.memory[ea]))))
+	case opI64Load16S:
+		ea := in.address(ins.a, 2)
+		in.push(uint64(int64(int16(le.Uint16(in.memory[ea:])))))
+	case opI64Load32S:
+		ea := in.address(ins.a, 4)
+		in.push(uint64(int64(int32(le.Uint32(in.memory[ea:])))))
+	case opI64Load32U:
+		ea := in.address(ins.a, 4)
+		in.push(uint64(le.Uint32(in.memory[ea:])))
+	case opI32Store, opF32Store, opI64Store32:
+		v := in.pop()
+		ea := in.address(ins.a, 4)
+		le.PutUint32(in.memory[ea:], uint32(v))
+	case opI64Store, opF64Store:
+		v := in.pop()
+		ea := in.address(ins.a, 8)
+		le.PutUint64(in.memory[ea:], v)
+	case opI32Store8, opI64Store8:
+		v := in.pop()
+		ea := in.address(ins.a, 1)
+		in.memory[ea] = byte(v)
+	case opI32Store16, opI64Store16:
+		v := in.pop()
+		ea := in.address(ins.a, 2)
+		le.PutUint16(in.memory[ea:], uint16(v))
+	case opMemorySize:
+		in.push(uint64(len(in.memory) / pageSize))
+	case opMemoryGrow:
+		delta := uint64(uint32(in.pop()))
+		pages := uint64(len(in.memory) / pageSize)
+		if pages+delta > uint64(in.maxPages) {
+			in.push(uint64(math.MaxUint32))
+			return
+		}
+		if delta > 0 {
+			memory := make([]byte, (pages+delta)*pageSize)
+			copy(memory, in.memory)
+			in.memory = memory
+		}
+		in.push(pages)
+	case opMemoryCopy:
+		n, src, dst := uint64(uint32(in.pop())), uint64(uint32(in.pop())), uint64(uint32(in.pop()))
+		if src+n > uint64(len(in.memory)) || dst+n > uint64(len(in.memory)) {
+			trap("out of bounds memory access")
+		}
+		copy(in.memory[dst:dst+n], in.memory[src:src+n])
+	case opMemoryFill:
+		n, v, dst := uint64(uint32(in.pop())), byte(in.pop()), uint64(uint32(in.pop()))
+		if dst+n > uint64(len(in.memory)) {
+			trap("out of bounds memory access")
+		}
+		for i := dst; i < dst+n; i++ {
+			in.memory[i] = v
+		}
+	}
+}
+
+func boolValue(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+//nolint:funlen,gocyclo,cyclop,maintidx
+func (in *Instance) execNumeric(op uint16) {
+	switch {
+	case op == opI32Eqz:
+		in.push(boolValue(uint32(in.pop()) == 0))
+		return
+	case op == opI64Eqz:
+		in.push(boolValue(in.pop() == 0))
+		return
+	case op >= opI32Eq && op <= opF64Ge:
+		b, a := in.pop(), in.pop()
+		in.push(boolValue(compare(op, a, b)))
+		return
+	case op >= opI32Add && op <= opI32Rotr, op >= opI64Add && op <= opI64Rotr,
+		op >= opF32Add && op <= opF32Copysign, op >= opF64Add && op <= opF64Copysign:
+		b, a := in.pop(), in.pop()
+		in.push(binaryOp(op, a, b))
+		return
+	}
+
+	a := in.pop()
+	var r uint64
+	switch op {
+	case opI32Clz:
+		r = uint64(bits.LeadingZeros32(uint32(a)))
+	case opI32Ctz:
+		r = uint64(bits.TrailingZeros32(uint32(a)))
+	case opI32Popcnt:
+		r = uint64(bits.OnesCount32(uint32(a)))
+	case opI64Clz:
+		r = uint64(bits.LeadingZeros64(a))
+	case opI64Ctz:
+		r = uint64(bits.TrailingZeros64(a))
+	case opI64Popcnt:
+		r = uint64(bits.OnesCount64(a))
+
+	case opF32Abs:
+		r = a &^ (1 << 31)
+	case opF32Neg:
+		r = uint64(uint32(a) ^ (1 << 31))
+	case opF32Ceil:
+		r = float32Bits(float32(math.Ceil(float64(float32Value(a)))))
+	case opF32Floor:
+		r = float32Bits(float32(math.Floor(float64(float32Value(a)))))
+	case opF32Trunc:
+		r = float32Bits(float32(math.Trunc(float64(float32Value(a)))))
+	case opF32Nearest:
+		r = float32Bits(float32(math.RoundToEven(float64(float32Value(a)))))
+	case opF32Sqrt:
+		r = float32Bits(float32(math.Sqrt(float64(float32Value(a)))))
+	case opF64Abs:
+		r = a &^ (1 << 63)
+	case opF64Neg:
+		r = a ^ (1 << 63)
+	case opF64Ceil:
+		r = float64Bits(math.Ceil(float64Value(a)))
+	case opF64Floor:
+		r = float64Bits(math.Floor(float64Value(a)))
+	case opF64Trunc:
+		r = float64Bits(math.Trunc(float64Value(a)))
+	case opF64Nearest:
+		r = float64Bits(math.RoundToEven(float64Value(a)))
+	case opF64Sqrt:
+		r = float64Bits(math.Sqrt(float64Value(a)))
+
+	case opI32WrapI64:
+		r = uint64(uint32(a))
+	case opI32TruncF32S:
+		r = uint64(uint32(int32(truncate(float64(float32Value(a)), math.MinInt32, -math.MinInt32))))
+	case opI32TruncF32U:
+		r = uint64(uint32(truncate(float64(float32Value(a)), 0, math.MaxUint32+1)))
+	case opI32TruncF64S:
+		r = uint64(uint32(int32(truncate(float64Value(a), math.MinInt32, -math.MinInt32))))
+	case opI32TruncF64U:
+		r = uint64(uint32(truncate(float64Value(a), 0, math.MaxUint32+1)))
+	case opI64ExtendI32S:
+		r = uint64(int64(int32(a)))
+	case opI64ExtendI32U:
+		r = uint64(uint32(a))
+	case opI64TruncF32S:
+		r = uint64(int64(truncate(float64(float32Value(a)), math.MinInt64, -math.MinInt64)))
+	case opI64TruncF32U:
+		r = uint64(truncate(float64(float32Value(a)), 0, math.MaxUint64))
+	case opI64TruncF64S:
+		r = uint64(int64(truncate(float64Value(a), math.MinInt64, -math.MinInt64)))
+	case opI64TruncF64U:
+		r = uint64(truncate(float64Value(a), 0, math.MaxUint64))
+	case opF32ConvertI32S:
+		r = float32Bits(float32(int32(a)))
+	case opF32ConvertI32U:
+		r = float32Bits(float32(uint32(a)))
+	case opF32ConvertI64S:
+		r = float32Bits(float32(int64(a)))
+	case opF32ConvertI64U:
+		r = float32Bits(float32(a))
+	case opF32DemoteF64:
+		r = float32Bits(float32(float64Value(a)))
+	case opF64ConvertI32S:
+		r = float64Bits(float64(int32(a)))
+	case opF64ConvertI32U:
+		r = float64Bits(float64(uint32(a)))
+	case opF64ConvertI64S:
+		r = float64Bits(float64(int64(a)))
+	case opF64ConvertI64U:
+		r = float64Bits(float64(a))
+	case opF64PromoteF32:
+		r = float64Bits(float64(float32Value(a)))
+	case opI32ReinterpretF32, opI64ReinterpretF64, opF32ReinterpretI32, opF64ReinterpretI64:
+		r = a
+
+	case opI32Extend8S:
+		r = uint64(uint32(int32(int8(a))))
+	case opI32Extend16S:
+		r = uint64(uint32(int32(int16(a))))
+	case opI64Extend8S:
+		r = uint64(int64(int8(a)))
+	case opI64Extend16S:
+		r = uint64(int64(int16(a)))
+	case opI64Extend32S:
+		r = uint64(int64(int32(a)))
+
+	case opI32TruncSatF32S:
+		r = uint64(uint32(int32(saturate(float64(float32Value(a)), math.MinInt32, math.MaxInt32))))
+	case opI32TruncSatF32U:
+		r = uint64(uint32(saturate(float64(float32Value(a)), 0, math.MaxUint32)))
+	case opI32TruncSatF64S:
+		r = uint64(uint32(int32(saturate(float64Value(a), math.MinInt32, math.MaxInt32))))
+	case opI32TruncSatF64U:
+		r = uint64(uint32(saturate(float64Value(a), 0, math.MaxUint32)))
+	case opI64TruncSatF32S:
+		r = uint64(saturateI64(float64(float32Value(a))))
+	case opI64TruncSatF32U:
+		r = saturateU64(float64(float32Value(a)))
+	case opI64TruncSatF64S:
+		r = uint64(saturateI64(float64Value(a)))
+	case opI64TruncSatF64U:
+		r = saturateU64(float64Value(a))
+	default:
+		trap("unsupported instruction 0x%x", op)
+	}
+	in.push(r)
+}
+
+// truncate truncates the float, trapping if it's NaN or if the result is
+// lower than min or not lower than max.
+func truncate(f, min, max float64) float64 {
+	if math.IsNaN(f) {
+		trap("invalid conversion to integer")
+	}
+	t := math.Trunc(f)
+	if t < min || t >= max {
+		trap("integer overflow")
+	}
+	return t
+}
+
+func saturate(f, min, max float64) float64 {
+	switch t := math.Trunc(f); {
+	case math.IsNaN(f):
+		return 0
+	case t < min:
+		return min
+	case t > max:
+		return max
+	default:
+		return t
+	}
+}
+
+func saturateI64(f float64) int64 {
+	switch t := math.Trunc(f); {
+	case math.IsNaN(f):
+		return 0
+	case t < math.MinInt64:
+		return math.MinInt64
+	case t >= -math.MinInt64:
+		return math.MaxInt64
+	default:
+		return int64(t)
+	}
+}
+
+func saturateU64(f float64) uint64 {
+	switch t := math.Trunc(f); {
+	case math.IsNaN(f), t <= 0:
+		return 0
+	case t >= math.MaxUint64:
+		return math.MaxUint64
+	default:
+		return uint64(t)
+	}
+}
+
+//nolint:gocyclo,cyclop
+func compare(op uint16, a, b uint64) bool {
+	switch op {
+	case opI32Eq:
+		return uint32(a) == uint32(b)
+	case opI32Ne:
+		return uint32(a) != uint32(b)
+	case opI32LtS:
+		return int32(a) < int32(b)
+	case opI32LtU:
+		return uint32(a) < uint32(b)
+	case opI32GtS:
+		return int32(a) > int32(b)
+	case opI32GtU:
+		return uint32(a) > uint32(b)
+	case opI32LeS:
+		return int32(a) <= int32(b)
+	case opI32LeU:
+		return uint32(a) <= uint32(b)
+	case opI32GeS:
+		return int32(a) >= int32(b)
+	case opI32GeU:
+		return uint32(a) >= uint32(b)
+	case opI64Eq:
+		return a == b
+	case opI64Ne:
+		return a != b
+	case opI64LtS:
+		return int64(a) < int64(b)
+	case opI64LtU:
+		return a < b
+	case opI64GtS:
+		return int64(a) > int64(b)
+	case opI64GtU:
+		return a > b
+	case opI64LeS:
+		return int64(a) <= int64(b)
+	case opI64LeU:
+		return a <= b
+	case opI64GeS:
+		return int64(a) >= int64(b)
+	case opI64GeU:
+		return a >= b
+	case opF32Eq:
+		return float32Value(a) == float32Value(b)
+	case opF32Ne:
+		return float32Value(a) != float32Value(b)
+	case opF32Lt:
+		return float32Value(a) < float32Value(b)
+	case opF32Gt:
+		return float32Value(a) > float32Value(b)
+	case opF32Le:
+		return float32Value(a) <= float32Value(b)
+	case opF32Ge:
+		return float32Value(a) >= float32Value(b)
+	case opF64Eq:
+		return float64Value(a) == float64Value(b)
+	case opF64Ne:
+		return float64Value(a) != float64Value(b)
+	case opF64Lt:
+		return float64Value(a) < float64Value(b)
+	case opF64Gt:
+		return float64Value(a) > float64Value(b)
+	case opF64Le:
+		return float64Value(a) <= float64Value(b)
+	default: // opF64Ge
+		return float64Value(a) >= float64Value(b)
+	}
+}
+
+//nolint:funlen,gocyclo,cyclop
+func binaryOp(op uint16, a, b uint64) uint64 {
+	x, y := uint32(a), uint32(b)
+	switch op {
+	case opI32Add:
+		return uint64(x + y)
+	case opI32Sub:
+		return uint64(x - y)
+	case opI32Mul:
+		return uint64(x * y)
+	case opI32DivS:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		if int32(x) == math.MinInt32 && int32(y) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(uint32(int32(x) / int32(y)))
+	case opI32DivU:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(x / y)
+	case opI32RemS:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(uint32(int32(x) % int32(y)))
+	case opI32RemU:
+		if y == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(x % y)
+	case opI32And:
+		return uint64(x & y)
+	case opI32Or:
+		return uint64(x | y)
+	case opI32Xor:
+		return uint64(x ^ y)
+	case opI32Shl:
+		return uint64(x << (y & 31))
+	case opI32ShrS:
+		return uint64(uint32(int32(x) >> (y & 31)))
+	case opI32ShrU:
+		return uint64(x >> (y & 31))
+	case opI32Rotl:
+		return uint64(bits.RotateLeft32(x, int(y&31)))
+	case opI32Rotr:
+		return uint64(bits.RotateLeft32(x, -int(y&31)))
+
+	case opI64Add:
+		return a + b
+	case opI64Sub:
+		return a - b
+	case opI64Mul:
+		return a * b
+	case opI64DivS:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			trap("integer overflow")
+		}
+		return uint64(int64(a) / int64(b))
+	case opI64DivU:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a / b
+	case opI64RemS:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return uint64(int64(a) % int64(b))
+	case opI64RemU:
+		if b == 0 {
+			trap("integer divide by zero")
+		}
+		return a % b
+	case opI64And:
+		return a & b
+	case opI64Or:
+		return a | b
+	case opI64Xor:
+		return a ^ b
+	case opI64Shl:
+		return a << (b & 63)
+	case opI64ShrS:
+		return uint64(int64(a) >> (b & 63))
+	case opI64ShrU:
+		return a >> (b & 63)
+	case opI64Rotl:
+		return bits.RotateLeft64(a, int(b&63))
+	case opI64Rotr:
+		return bits.RotateLeft64(a, -int(b&63))
+
+	case opF32Add:
+		return float32Bits(float32Value(a) + float32Value(b))
+	case opF32Sub:
+		return float32Bits(float32Value(a) - float32Value(b))
+	case opF32Mul:
+		return float32Bits(float32Value(a) * float32Value(b))
+	case opF32Div:
+		return float32Bits(float32Value(a) / float32Value(b))
+	case opF32Min:
+		return float32Bits(float32(math.Min(float64(float32Value(a)), float64(float32Value(b)))))
+	case opF32Max:
+		return float32Bits(float32(math.Max(float64(float32Value(a)), float64(float32Value(b)))))
+	case opF32Copysign:
+		return uint64(x&^(1<<31) | y&(1<<31))
+
+	case opF64Add:
+		return float64Bits(float64Value(a) + float64Value(b))
+	case opF64Sub:
+		return float64Bits(float64Value(a) - float64Value(b))
+	case opF64Mul:
+		return float64Bits(float64Value(a) * float64Value(b))
+	case opF64Div:
+		return float64Bits(float64Value(a) / float64Value(b))
+	case opF64Min:
+		return float64Bits(math.Min(float64Value(a), float64Value(b)))
+	case opF64Max:
+		return float64Bits(math.Max(float64Value(a), float64Value(b)))
+	default: // opF64Copysign
+		return a&^(1<<63) | b&(1<<63)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wasm
+
+import (
+	"bytes"
+	"context"
+	"math"
+
+	"github.com/dop251/goja"
+
+	"go.k6.io/k6/js/common"
+)
+
+// IsModule returns whether the data is a binary WebAssembly module.
+func IsModule(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(magic))
+}
+
+// Exports instantiates the module and returns an object with its exports:
+// the functions can be called with numbers, the memory has a buffer property
+// with its current ArrayBuffer and the globals have a value property. The
+// context is taken on every call, so the functions are interrupted when the
+// iteration they are called from is.
+func Exports(rt *goja.Runtime, getCtx func() context.Context, m *Module) (*goja.Object, error) {
+	in, err := NewInstance(getCtx(), m)
+	if err != nil {
+		return nil, err
+	}
+
+	exports := rt.NewObject()
+	for _, e := range m.exports {
+		var v interface{}
+		switch e.Kind {
+		case ExportFunc:
+			v = exportFunc(rt, getCtx, in, e.Name, m.functions[e.Index].typ)
+		case ExportMemory:
+			memory := rt.NewObject()
+			err = memory.DefineAccessorProperty("buffer", rt.ToValue(func() goja.Value {
+				buffer := rt.NewArrayBuffer(in.Memory())
+				return rt.ToValue(&buffer)
+			}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+			v = memory
+		case ExportGlobal:
+			index := e.Index
+			global := rt.NewObject()
+			err = global.DefineAccessorProperty("value", rt.ToValue(func() goja.Value {
+				value, typ := in.Global(index)
+				return toJSValue(rt, value, typ)
+			}), nil, goja.FLAG_FALSE, goja.FLAG_TRUE)
+			v = global
+		case ExportTable:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if err = exports.Set(e.Name, v); err != nil {
+			return nil, err
+		}
+	}
+	return exports, nil
+}
+
+func exportFunc(
+	rt *goja.Runtime, getCtx func() context.Context, in *Instance, name string, typ *FuncType,
+) func(goja.FunctionCall) goja.Value {
+	return func(call goja.FunctionCall) goja.Value {
+		args := make([]uint64, len(typ.Params))
+		for i, t := range typ.Params {
+			args[i] = fromJSValue(call.Argument(i), t)
+		}
+		results, err := in.Call(getCtx(), name, args...)
+		if err != nil {
+			common.Throw(rt, err)
+		}
+		switch len(results) {
+		case 0:
+			return goja.Undefined()
+		case 1:
+			return toJSValue(rt, results[0], typ.Results[0])
+		default:
+			values := make([]interface{}, len(results))
+			for i, r := range results {
+				values[i] = toJSValue(rt, r, typ.Results[i])
+			}
+			return rt.ToValue(values)
+		}
+	}
+}
+
+func fromJSValue(v goja.Value, t ValueType) uint64 {
+	switch t {
+	case I32:
+		return uint64(uint32(v.ToInteger()))
+	case I64:
+		return uint64(v.ToInteger())
+	case F32:
+		return float32Bits(float32(v.ToFloat()))
+	case F64:
+		return float64Bits(v.ToFloat())
+	default:
+		return math.MaxUint64
+	}
+}
+
+func toJSValue(rt *goja.Runtime, v uint64, t ValueType) goja.Value {
+	switch t {
+	case I32:
+		return rt.ToValue(int32(v))
+	case I64:
+		return rt.ToValue(int64(v))
+	case F32:
+		return rt.ToValue(float64(float32Value(v)))
+	case F64:
+		return rt.ToValue(float64Value(v))
+	default:
+		return goja.Null()
+	}
+}
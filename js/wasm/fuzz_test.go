@@ -0,0 +1,118 @@
+//go:build go1.18
+// +build go1.18
+
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wasm
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// canRun returns whether the decoded module is small enough to be
+// instantiated and called by the fuzz tests, whose inputs can otherwise
+// declare gigabytes of memory and tables.
+func canRun(m *Module) bool {
+	if m.memory != nil && (!m.memory.hasMax || m.memory.max > 16) {
+		return false
+	}
+	return m.table == nil || m.table.min <= 1024
+}
+
+// run instantiates the module and calls all of its exported functions with
+// zero args, the only acceptable errors are the traps, the interpreter
+// panics with anything else, e.g. an index out of range, if the validation
+// let an invalid module through.
+func run(t *testing.T, m *Module) {
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	in, err := NewInstance(ctx, m)
+	if err != nil {
+		return
+	}
+	for _, e := range m.Exports() {
+		if e.Kind != ExportFunc {
+			continue
+		}
+		f := m.functions[e.Index]
+		_, err := in.Call(ctx, e.Name, make([]uint64, len(f.typ.Params))...)
+		var trap *Trap
+		if err != nil && !errors.As(err, &trap) {
+			t.Fatalf("calling %s failed with a non-trap error: %s", e.Name, err)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(arithmeticModule())
+	f.Add(module())
+	f.Add(module(
+		section(1, funcType(nil, []byte{byte(I32)})),
+		section(3, leb(0)),
+		section(5, cat([]byte{1}, leb(1), leb(1))),
+		section(7, exportedFunc("grow", 0)),
+		section(10, body(vec(), i32Const(1), []byte{opMemoryGrow, 0})),
+	))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := Decode(data)
+		if err != nil || !canRun(m) {
+			return
+		}
+		run(t, m)
+	})
+}
+
+// FuzzValidate fuzzes the code of a function of (i32, i32) -> i32 with an
+// i32 local, in a module with a memory of a page, a table of a few elements
+// and a mutable i32 global, so the instructions which use them can be
+// validated too.
+func FuzzValidate(f *testing.F) {
+	f.Add([]byte{opLocalGet, 0, opLocalGet, 1, opI32Add})
+	f.Add([]byte{opLocalGet, 0, opI32Load8U, 0, 1})
+	f.Add([]byte{opLocalGet, 0, opLocalGet, 1, opI32Store, 2, 0, opI32Const, 0})
+	f.Add([]byte{opBlock, byte(I32), opI32Const, 1, opLocalGet, 0, opBrIf, 0, opDrop, opI32Const, 2, opEnd})
+	f.Add([]byte{opLocalGet, 0, opLocalGet, 1, opLocalGet, 0, opCallIndirect, 0, 0})
+	f.Add([]byte{opGlobalGet, 0, opI32Const, 1, opI32Add, opGlobalSet, 0, opGlobalGet, 0})
+	f.Add([]byte{opLocalGet, 0, opPrefixFC, 2})
+
+	f.Fuzz(func(t *testing.T, code []byte) {
+		data := module(
+			section(1, funcType([]byte{byte(I32), byte(I32)}, []byte{byte(I32)})),
+			section(3, leb(0)),
+			section(4, cat([]byte{byte(FuncRef), 1}, leb(1), leb(4))),
+			section(5, cat([]byte{1}, leb(1), leb(1))),
+			section(6, cat([]byte{byte(I32), 1}, i32Const(0), []byte{opEnd})),
+			section(7, exportedFunc("f", 0)),
+			section(9, cat(leb(0), i32Const(0), []byte{opEnd}, vec(leb(0)))),
+			section(10, body(vec(cat(leb(1), []byte{byte(I32)})), code)),
+		)
+		m, err := Decode(data)
+		if err != nil {
+			return
+		}
+		run(t, m)
+	})
+}
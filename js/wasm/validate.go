@@ -0,0 +1,389 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wasm
+
+import (
+	"errors"
+	"fmt"
+)
+
+// unknownType is the type of the operands of the unreachable code, after a
+// branch, a return or an unreachable, which matches any other type.
+const unknownType ValueType = 0
+
+// ctrlFrame is a block, loop, if or else being validated, or the body of
+// the function itself.
+type ctrlFrame struct {
+	op              uint16
+	params, results []ValueType
+	// height is the size of the operand stack when the frame started.
+	height      int
+	unreachable bool
+}
+
+// labelTypes are the types of the values a branch to the frame takes.
+func (c *ctrlFrame) labelTypes() []ValueType {
+	if c.op == opLoop {
+		return c.params
+	}
+	return c.results
+}
+
+// validator checks the operand types of the instructions of a function, as
+// they are decoded, following the validation algorithm of the spec. The
+// interpreter relies on it, as it doesn't check the operand stack itself.
+type validator struct {
+	m     *Module
+	f     *function
+	vals  []ValueType
+	ctrls []ctrlFrame
+}
+
+func newValidator(m *Module, f *function) *validator {
+	v := &validator{m: m, f: f}
+	v.pushCtrl(opBlock, nil, f.typ.Results)
+	return v
+}
+
+func (v *validator) push(t ValueType) {
+	v.vals = append(v.vals, t)
+}
+
+func (v *validator) pushAll(types []ValueType) {
+	v.vals = append(v.vals, types...)
+}
+
+func (v *validator) pop() (ValueType, error) {
+	c := &v.ctrls[len(v.ctrls)-1]
+	if len(v.vals) == c.height {
+		if c.unreachable {
+			return unknownType, nil
+		}
+		return 0, errors.New("type mismatch, the operand stack is empty")
+	}
+	t := v.vals[len(v.vals)-1]
+	v.vals = v.vals[:len(v.vals)-1]
+	return t, nil
+}
+
+func (v *validator) popExpect(expected ValueType) (ValueType, error) {
+	t, err := v.pop()
+	if err != nil {
+		return 0, err
+	}
+	if t != expected && t != unknownType && expected != unknownType {
+		return 0, fmt.Errorf("type mismatch, expected %s but got %s", expected, t)
+	}
+	return t, nil
+}
+
+func (v *validator) popAll(types []ValueType) error {
+	for i := len(types) - 1; i >= 0; i-- {
+		if _, err := v.popExpect(types[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (v *validator) pushCtrl(op uint16, params, results []ValueType) {
+	v.ctrls = append(v.ctrls, ctrlFrame{op: op, params: params, results: results, height: len(v.vals)})
+	v.pushAll(params)
+}
+
+func (v *validator) popCtrl() (ctrlFrame, error) {
+	c := v.ctrls[len(v.ctrls)-1]
+	if err := v.popAll(c.results); err != nil {
+		return c, err
+	}
+	if len(v.vals) != c.height {
+		return c, errors.New("type mismatch, values are left on the operand stack at the end of the block")
+	}
+	v.ctrls = v.ctrls[:len(v.ctrls)-1]
+	return c, nil
+}
+
+func (v *validator) setUnreachable() {
+	c := &v.ctrls[len(v.ctrls)-1]
+	v.vals = v.vals[:c.height]
+	c.unreachable = true
+}
+
+// label returns the frame a branch with the depth targets.
+func (v *validator) label(depth uint32) *ctrlFrame {
+	return &v.ctrls[len(v.ctrls)-1-int(depth)]
+}
+
+func (v *validator) local(index uint32) ValueType {
+	if params := v.f.typ.Params; int(index) < len(params) {
+		return params[index]
+	}
+	return v.f.locals[int(index)-len(v.f.typ.Params)]
+}
+
+// instr validates the decoded instruction, bt is the type of a block, loop
+// or if.
+//nolint:funlen,gocyclo,cyclop
+func (v *validator) instr(in instr, bt *FuncType) error {
+	switch op := in.op; op {
+	case opUnreachable:
+		v.setUnreachable()
+	case opNop:
+	case opBlock, opLoop:
+		if err := v.popAll(bt.Params); err != nil {
+			return err
+		}
+		v.pushCtrl(op, bt.Params, bt.Results)
+	case opIf:
+		if _, err := v.popExpect(I32); err != nil {
+			return err
+		}
+		if err := v.popAll(bt.Params); err != nil {
+			return err
+		}
+		v.pushCtrl(op, bt.Params, bt.Results)
+	case opElse:
+		c, err := v.popCtrl()
+		if err != nil {
+			return err
+		}
+		v.pushCtrl(opElse, c.params, c.results)
+	case opEnd:
+		c, err := v.popCtrl()
+		if err != nil {
+			return err
+		}
+		if c.op == opIf && !sameTypes(c.params, c.results) {
+			return errors.New("type mismatch, an if without else must have the same params and results")
+		}
+		v.pushAll(c.results)
+	case opBr:
+		if err := v.popAll(v.label(in.a).labelTypes()); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	case opBrIf:
+		if _, err := v.popExpect(I32); err != nil {
+			return err
+		}
+		types := v.label(in.a).labelTypes()
+		if err := v.popAll(types); err != nil {
+			return err
+		}
+		v.pushAll(types)
+	case opBrTable:
+		if _, err := v.popExpect(I32); err != nil {
+			return err
+		}
+		labels := v.f.brTables[in.a]
+		def := v.label(labels[len(labels)-1]).labelTypes()
+		for _, l := range labels[:len(labels)-1] {
+			types := v.label(l).labelTypes()
+			if len(types) != len(def) {
+				return errors.New("type mismatch, the br_table labels have different arities")
+			}
+			// the values are checked against every label, without being
+			// consumed, as they can be of the unknown type
+			vals := append([]ValueType(nil), v.vals...)
+			if err := v.popAll(types); err != nil {
+				return err
+			}
+			v.vals = vals
+		}
+		if err := v.popAll(def); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	case opReturn:
+		if err := v.popAll(v.ctrls[0].results); err != nil {
+			return err
+		}
+		v.setUnreachable()
+	case opCall:
+		return v.call(v.m.functions[in.a].typ)
+	case opCallIndirect:
+		if _, err := v.popExpect(I32); err != nil {
+			return err
+		}
+		return v.call(&v.m.types[in.a])
+	case opDrop:
+		_, err := v.pop()
+		return err
+	case opSelect:
+		if _, err := v.popExpect(I32); err != nil {
+			return err
+		}
+		t1, err := v.pop()
+		if err != nil {
+			return err
+		}
+		t2, err := v.popExpect(t1)
+		if err != nil {
+			return err
+		}
+		if t1 == unknownType {
+			t1 = t2
+		}
+		v.push(t1)
+	case opLocalGet:
+		v.push(v.local(in.a))
+	case opLocalSet:
+		_, err := v.popExpect(v.local(in.a))
+		return err
+	case opLocalTee:
+		t := v.local(in.a)
+		if _, err := v.popExpect(t); err != nil {
+			return err
+		}
+		v.push(t)
+	case opGlobalGet:
+		v.push(v.m.globals[in.a].typ)
+	case opGlobalSet:
+		_, err := v.popExpect(v.m.globals[in.a].typ)
+		return err
+	case opI32Const:
+		v.push(I32)
+	case opI64Const:
+		v.push(I64)
+	case opF32Const:
+		v.push(F32)
+	case opF64Const:
+		v.push(F64)
+	default:
+		params, results := operandTypes(op)
+		if err := v.popAll(params); err != nil {
+			return err
+		}
+		v.pushAll(results)
+	}
+	return nil
+}
+
+func (v *validator) call(t *FuncType) error {
+	if err := v.popAll(t.Params); err != nil {
+		return err
+	}
+	v.pushAll(t.Results)
+	return nil
+}
+
+func sameTypes(a, b []ValueType) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var (
+	typesI32    = []ValueType{I32}
+	typesI64    = []ValueType{I64}
+	typesF32    = []ValueType{F32}
+	typesF64    = []ValueType{F64}
+	typesI32I32 = []ValueType{I32, I32}
+	typesI64I64 = []ValueType{I64, I64}
+	typesF32F32 = []ValueType{F32, F32}
+	typesF64F64 = []ValueType{F64, F64}
+)
+
+// operandTypes returns the types of the operands and of the results of the
+// memory and numeric instructions.
+//nolint:funlen,gocyclo,cyclop
+func operandTypes(op uint16) (params, results []ValueType) {
+	switch {
+	case op == opI32Load, op >= opI32Load8S && op <= opI32Load16U:
+		return typesI32, typesI32
+	case op == opI64Load, op >= opI64Load8S && op <= opI64Load32U:
+		return typesI32, typesI64
+	case op == opF32Load:
+		return typesI32, typesF32
+	case op == opF64Load:
+		return typesI32, typesF64
+	case op == opI32Store, op == opI32Store8, op == opI32Store16:
+		return typesI32I32, nil
+	case op == opI64Store, op >= opI64Store8 && op <= opI64Store32:
+		return []ValueType{I32, I64}, nil
+	case op == opF32Store:
+		return []ValueType{I32, F32}, nil
+	case op == opF64Store:
+		return []ValueType{I32, F64}, nil
+	case op == opMemorySize:
+		return nil, typesI32
+	case op == opMemoryGrow:
+		return typesI32, typesI32
+	case op == opMemoryCopy, op == opMemoryFill:
+		return []ValueType{I32, I32, I32}, nil
+
+	case op == opI32Eqz, op >= opI32Clz && op <= opI32Popcnt, op == opI32Extend8S, op == opI32Extend16S:
+		return typesI32, typesI32
+	case op >= opI32Eq && op <= opI32GeU, op >= opI32Add && op <= opI32Rotr:
+		return typesI32I32, typesI32
+	case op == opI64Eqz, op == opI32WrapI64:
+		return typesI64, typesI32
+	case op >= opI64Eq && op <= opI64GeU:
+		return typesI64I64, typesI32
+	case op >= opF32Eq && op <= opF32Ge:
+		return typesF32F32, typesI32
+	case op >= opF64Eq && op <= opF64Ge:
+		return typesF64F64, typesI32
+	case op >= opI64Clz && op <= opI64Popcnt, op >= opI64Extend8S && op <= opI64Extend32S:
+		return typesI64, typesI64
+	case op >= opI64Add && op <= opI64Rotr:
+		return typesI64I64, typesI64
+	case op >= opF32Abs && op <= opF32Sqrt:
+		return typesF32, typesF32
+	case op >= opF32Add && op <= opF32Copysign:
+		return typesF32F32, typesF32
+	case op >= opF64Abs && op <= opF64Sqrt:
+		return typesF64, typesF64
+	case op >= opF64Add && op <= opF64Copysign:
+		return typesF64F64, typesF64
+
+	case op == opI32TruncF32S, op == opI32TruncF32U, op == opI32ReinterpretF32,
+		op == opI32TruncSatF32S, op == opI32TruncSatF32U:
+		return typesF32, typesI32
+	case op == opI32TruncF64S, op == opI32TruncF64U, op == opI32TruncSatF64S, op == opI32TruncSatF64U:
+		return typesF64, typesI32
+	case op == opI64ExtendI32S, op == opI64ExtendI32U:
+		return typesI32, typesI64
+	case op == opI64TruncF32S, op == opI64TruncF32U, op == opI64TruncSatF32S, op == opI64TruncSatF32U:
+		return typesF32, typesI64
+	case op == opI64TruncF64S, op == opI64TruncF64U, op == opI64ReinterpretF64,
+		op == opI64TruncSatF64S, op == opI64TruncSatF64U:
+		return typesF64, typesI64
+	case op == opF32ConvertI32S, op == opF32ConvertI32U, op == opF32ReinterpretI32:
+		return typesI32, typesF32
+	case op == opF32ConvertI64S, op == opF32ConvertI64U:
+		return typesI64, typesF32
+	case op == opF32DemoteF64:
+		return typesF64, typesF32
+	case op == opF64ConvertI32S, op == opF64ConvertI32U:
+		return typesI32, typesF64
+	case op == opF64ConvertI64S, op == opF64ConvertI64U, op == opF64ReinterpretI64:
+		return typesI64, typesF64
+	default: // opF64PromoteF32
+		return typesF32, typesF64
+	}
+}
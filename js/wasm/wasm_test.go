@@ -0,0 +1,379 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package wasm
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func leb(v uint64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if v == 0 {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func sleb(v int64) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7f)
+		v >>= 7
+		if (v == 0 && c&0x40 == 0) || (v == -1 && c&0x40 != 0) {
+			return append(b, c)
+		}
+		b = append(b, c|0x80)
+	}
+}
+
+func cat(parts ...[]byte) []byte {
+	var b []byte
+	for _, p := range parts {
+		b = append(b, p...)
+	}
+	return b
+}
+
+func vec(items ...[]byte) []byte {
+	return cat(leb(uint64(len(items))), cat(items...))
+}
+
+func str(s string) []byte {
+	return cat(leb(uint64(len(s))), []byte(s))
+}
+
+func section(id byte, items ...[]byte) []byte {
+	content := vec(items...)
+	return cat([]byte{id}, leb(uint64(len(content))), content)
+}
+
+func funcType(params, results []byte) []byte {
+	return cat([]byte{0x60}, leb(uint64(len(params))), params, leb(uint64(len(results))), results)
+}
+
+func body(locals []byte, code ...[]byte) []byte {
+	content := cat(locals, cat(code...), []byte{opEnd})
+	return cat(leb(uint64(len(content))), content)
+}
+
+func i32Const(v int32) []byte {
+	return cat([]byte{opI32Const}, sleb(int64(v)))
+}
+
+func module(sections ...[]byte) []byte {
+	return cat([]byte(magic), []byte{1, 0, 0, 0}, cat(sections...))
+}
+
+func exportedFunc(name string, index uint32) []byte {
+	return cat(str(name), []byte{byte(ExportFunc)}, leb(uint64(index)))
+}
+
+func instantiate(t *testing.T, data []byte) *Instance {
+	t.Helper()
+	m, err := Decode(data)
+	require.NoError(t, err)
+	in, err := NewInstance(context.Background(), m)
+	require.NoError(t, err)
+	return in
+}
+
+func call(t *testing.T, in *Instance, name string, args ...uint64) uint64 {
+	t.Helper()
+	results, err := in.Call(context.Background(), name, args...)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	return results[0]
+}
+
+func i32(v int32) uint64 {
+	return uint64(uint32(v))
+}
+
+// arithmeticModule exports add and div_s with (i32, i32) -> i32, a recursive
+// fac with (i64) -> i64 and sum with (i32) -> i32, which sums the numbers up
+// to its param with a loop.
+func arithmeticModule() []byte {
+	return module(
+		section(1,
+			funcType([]byte{byte(I32), byte(I32)}, []byte{byte(I32)}),
+			funcType([]byte{byte(I64)}, []byte{byte(I64)}),
+			funcType([]byte{byte(I32)}, []byte{byte(I32)}),
+		),
+		section(3, leb(0), leb(0), leb(1), leb(2)),
+		section(7, exportedFunc("add", 0), exportedFunc("div_s", 1), exportedFunc("fac", 2), exportedFunc("sum", 3)),
+		section(10,
+			body(vec(), []byte{opLocalGet, 0, opLocalGet, 1, opI32Add}),
+			body(vec(), []byte{opLocalGet, 0, opLocalGet, 1, opI32DivS}),
+			body(vec(),
+				[]byte{opLocalGet, 0, opI64Eqz, opIf, byte(I64), opI64Const, 1, opElse},
+				[]byte{opLocalGet, 0, opLocalGet, 0, opI64Const, 1, opI64Sub, opCall, 2, opI64Mul, opEnd},
+			),
+			body(vec(cat(leb(1), []byte{byte(I32)})),
+				[]byte{opBlock, 0x40, opLoop, 0x40},
+				[]byte{opLocalGet, 0, opI32Eqz, opBrIf, 1},
+				[]byte{opLocalGet, 1, opLocalGet, 0, opI32Add, opLocalSet, 1},
+				[]byte{opLocalGet, 0}, i32Const(1), []byte{opI32Sub, opLocalSet, 0},
+				[]byte{opBr, 0, opEnd, opEnd, opLocalGet, 1},
+			),
+		),
+	)
+}
+
+func TestArithmetic(t *testing.T) {
+	t.Parallel()
+	in := instantiate(t, arithmeticModule())
+
+	assert.Equal(t, i32(5), call(t, in, "add", 2, 3))
+	assert.Equal(t, i32(-1), call(t, in, "add", i32(math.MaxInt32), i32(math.MinInt32)))
+	assert.Equal(t, i32(-3), call(t, in, "div_s", i32(-7), 2))
+	assert.Equal(t, uint64(3628800), call(t, in, "fac", 10))
+	assert.Equal(t, i32(5050), call(t, in, "sum", 100))
+
+	_, err := in.Call(context.Background(), "div_s", 1, 0)
+	assert.EqualError(t, err, "wasm trap: integer divide by zero")
+	_, err = in.Call(context.Background(), "div_s", i32(math.MinInt32), i32(-1))
+	assert.EqualError(t, err, "wasm trap: integer overflow")
+	// the instance can still be used after a trap
+	assert.Equal(t, i32(7), call(t, in, "add", 3, 4))
+
+	_, err = in.Call(context.Background(), "add", 1)
+	assert.EqualError(t, err, "add expects 2 arguments, but 1 were given")
+	_, err = in.Call(context.Background(), "nope")
+	assert.EqualError(t, err, `the module doesn't export a function named "nope"`)
+}
+
+func TestMemory(t *testing.T) {
+	t.Parallel()
+	data := module(
+		section(1,
+			funcType([]byte{byte(I32)}, []byte{byte(I32)}),
+			funcType([]byte{byte(I32), byte(I32)}, nil),
+		),
+		section(3, leb(0), leb(1), leb(0)),
+		section(5, []byte{1, 1, 2}),
+		section(7,
+			exportedFunc("load", 0), exportedFunc("store", 1), exportedFunc("grow", 2),
+			cat(str("memory"), []byte{byte(ExportMemory), 0}),
+		),
+		section(10,
+			body(vec(), []byte{opLocalGet, 0, opI32Load8U, 0, 1}),
+			body(vec(), []byte{opLocalGet, 0, opLocalGet, 1, opI32Store, 2, 0}),
+			body(vec(), []byte{opLocalGet, 0, opMemoryGrow, 0}),
+		),
+		section(11, cat([]byte{0}, i32Const(16), []byte{opEnd}, str("hello"))),
+	)
+	in := instantiate(t, data)
+
+	assert.Equal(t, "hello", string(in.Memory()[16:21]))
+	assert.Equal(t, uint64('e'), call(t, in, "load", 16))
+
+	_, err := in.Call(context.Background(), "store", 0, 0x04030201)
+	require.NoError(t, err)
+	assert.Equal(t, []byte{1, 2, 3, 4}, in.Memory()[:4])
+
+	_, err = in.Call(context.Background(), "load", pageSize-1)
+	assert.EqualError(t, err, "wasm trap: out of bounds memory access")
+	assert.Equal(t, uint64(1), call(t, in, "grow", 1))
+	assert.Len(t, in.Memory(), 2*pageSize)
+	assert.Equal(t, uint64(0), call(t, in, "load", pageSize-1))
+	assert.Equal(t, i32(-1), call(t, in, "grow", 1), "the maximum is 2 pages")
+}
+
+func TestCallIndirect(t *testing.T) {
+	t.Parallel()
+	data := module(
+		section(1,
+			funcType(nil, []byte{byte(I32)}),
+			funcType([]byte{byte(I32)}, []byte{byte(I32)}),
+		),
+		section(3, leb(0), leb(0), leb(1)),
+		section(4, []byte{byte(FuncRef), 0, 3}),
+		section(7, exportedFunc("dispatch", 2)),
+		section(9, cat([]byte{0}, i32Const(0), []byte{opEnd}, vec(leb(0), leb(1)))),
+		section(10,
+			body(vec(), i32Const(42)),
+			body(vec(), i32Const(-42)),
+			body(vec(), []byte{opLocalGet, 0, opCallIndirect, 0, 0}),
+		),
+	)
+	in := instantiate(t, data)
+
+	assert.Equal(t, i32(42), call(t, in, "dispatch", 0))
+	assert.Equal(t, i32(-42), call(t, in, "dispatch", 1))
+	_, err := in.Call(context.Background(), "dispatch", 2)
+	assert.EqualError(t, err, "wasm trap: uninitialized table element 2")
+	_, err = in.Call(context.Background(), "dispatch", 3)
+	assert.EqualError(t, err, "wasm trap: undefined table element 3")
+}
+
+func TestFloatConversions(t *testing.T) {
+	t.Parallel()
+	data := module(
+		section(1, funcType([]byte{byte(F64)}, []byte{byte(I32)})),
+		section(3, leb(0), leb(0)),
+		section(7, exportedFunc("trunc", 0), exportedFunc("trunc_sat", 1)),
+		section(10,
+			body(vec(), []byte{opLocalGet, 0, opI32TruncF64S}),
+			body(vec(), []byte{opLocalGet, 0, opPrefixFC, 2}),
+		),
+	)
+	in := instantiate(t, data)
+
+	assert.Equal(t, i32(-3), call(t, in, "trunc", math.Float64bits(-3.9)))
+	assert.Equal(t, i32(math.MaxInt32), call(t, in, "trunc_sat", math.Float64bits(1e20)))
+	assert.Equal(t, uint64(0), call(t, in, "trunc_sat", math.Float64bits(math.NaN())))
+	_, err := in.Call(context.Background(), "trunc", math.Float64bits(1e20))
+	assert.EqualError(t, err, "wasm trap: integer overflow")
+	_, err = in.Call(context.Background(), "trunc", math.Float64bits(math.NaN()))
+	assert.EqualError(t, err, "wasm trap: invalid conversion to integer")
+}
+
+func TestInterrupt(t *testing.T) {
+	t.Parallel()
+	data := module(
+		section(1, funcType(nil, nil)),
+		section(3, leb(0)),
+		section(7, exportedFunc("spin", 0)),
+		section(10, body(vec(), []byte{opLoop, 0x40, opBr, 0, opEnd})),
+	)
+	in := instantiate(t, data)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := in.Call(ctx, "spin")
+	assert.EqualError(t, err, "wasm trap: interrupted, context canceled")
+}
+
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+	testCases := map[string]struct {
+		data []byte
+		err  string
+	}{
+		"no magic": {
+			data: []byte("export default function() {}"),
+			err:  "not a WebAssembly module, the magic header is missing",
+		},
+		"version": {
+			data: cat([]byte(magic), []byte{2, 0, 0, 0}),
+			err:  "unsupported WebAssembly version 2",
+		},
+		"truncated section": {
+			data: cat(module(), []byte{1, 10, 1}),
+			err:  "unexpected end of the module",
+		},
+		"missing code": {
+			data: module(section(1, funcType(nil, nil)), section(3, leb(0))),
+			err:  "the function and code sections have different lengths",
+		},
+		"invalid local": {
+			data: module(section(1, funcType(nil, nil)), section(3, leb(0)),
+				section(10, body(vec(), []byte{opLocalGet, 0, opDrop}))),
+			err: "invalid function 0: invalid local index 0",
+		},
+		"invalid export": {
+			data: module(section(7, exportedFunc("f", 0))),
+			err:  `export "f" has an invalid index 0`,
+		},
+		"stack underflow": {
+			data: module(section(1, funcType(nil, []byte{byte(I32)})), section(3, leb(0)),
+				section(10, body(vec(), i32Const(1), []byte{opI32Add}))),
+			err: "invalid function 0: type mismatch, the operand stack is empty",
+		},
+		"operand type": {
+			data: module(section(1, funcType([]byte{byte(I64)}, []byte{byte(I32)})), section(3, leb(0)),
+				section(10, body(vec(), []byte{opLocalGet, 0}, i32Const(1), []byte{opI32Add}))),
+			err: "invalid function 0: type mismatch, expected i32 but got i64",
+		},
+		"missing result": {
+			data: module(section(1, funcType(nil, []byte{byte(I32)})), section(3, leb(0)),
+				section(10, body(vec()))),
+			err: "invalid function 0: type mismatch, the operand stack is empty",
+		},
+		"extra value": {
+			data: module(section(1, funcType(nil, nil)), section(3, leb(0)),
+				section(10, body(vec(), i32Const(1)))),
+			err: "invalid function 0: type mismatch, values are left on the operand stack at the end of the block",
+		},
+		"branch value": {
+			data: module(section(1, funcType(nil, []byte{byte(I32)})), section(3, leb(0)),
+				section(10, body(vec(), []byte{opBlock, byte(I32), opBr, 0, opEnd}))),
+			err: "invalid function 0: type mismatch, the operand stack is empty",
+		},
+		"if without else": {
+			data: module(section(1, funcType(nil, []byte{byte(I32)})), section(3, leb(0)),
+				section(10, body(vec(), i32Const(1), []byte{opIf, byte(I32)}, i32Const(2), []byte{opEnd}))),
+			err: "invalid function 0: type mismatch, an if without else must have the same params and results",
+		},
+		"global type": {
+			data: module(section(6, cat([]byte{byte(I64), 0}, i32Const(1), []byte{opEnd}))),
+			err:  "global 0 of type i64 is initialized with a i32",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, err := Decode(tc.data)
+			assert.EqualError(t, err, tc.err)
+		})
+	}
+}
+
+func TestUnreachableCode(t *testing.T) {
+	t.Parallel()
+	// the operands after a return or an unreachable can be of any type
+	data := module(
+		section(1, funcType(nil, []byte{byte(I32)})),
+		section(3, leb(0), leb(0)),
+		section(7, exportedFunc("ret", 0), exportedFunc("trap", 1)),
+		section(10,
+			body(vec(), i32Const(7), []byte{opReturn, opI64Add, opDrop}),
+			body(vec(), []byte{opUnreachable, opI32Add}),
+		),
+	)
+	in := instantiate(t, data)
+
+	assert.Equal(t, i32(7), call(t, in, "ret"))
+	_, err := in.Call(context.Background(), "trap")
+	assert.EqualError(t, err, "wasm trap: unreachable")
+}
+
+func TestImportsAreRejected(t *testing.T) {
+	t.Parallel()
+	data := module(
+		section(1, funcType(nil, nil)),
+		section(2, cat(str("wasi_snapshot_preview1"), str("fd_write"), []byte{0}, leb(0))),
+	)
+	m, err := Decode(data)
+	require.NoError(t, err)
+	_, err = NewInstance(context.Background(), m)
+	assert.EqualError(t, err, "the module imports wasi_snapshot_preview1.fd_write, but imports aren't supported")
+}
@@ -89,12 +89,20 @@ type Archive struct {
 	// Environment variables
 	Env map[string]string `json:"env"`
 
+	// The arguments of the script, see RuntimeOptions.ScriptArgs
+	ScriptArgs map[string]string `json:"scriptArgs,omitempty"`
+
 	CompatibilityMode string `json:"compatibilityMode"`
 
 	K6Version string `json:"k6version"`
 	Goos      string `json:"goos"`
 }
 
+// archivedFilesystems are the filesystems that are saved in archives: the
+// local files, the remote modules and the remote data files, see
+// loader.LoadData.
+var archivedFilesystems = [...]string{"file", "https", "s3", "gs"} //nolint:gochecknoglobals
+
 func (arc *Archive) getFs(name string) afero.Fs {
 	fs, ok := arc.Filesystems[name]
 	if !ok {
@@ -133,7 +141,8 @@ func (arc *Archive) loadMetadataJSON(data []byte) (err error) {
 func ReadArchive(in io.Reader) (*Archive, error) {
 	r := tar.NewReader(in)
 	arc := &Archive{Filesystems: make(map[string]afero.Fs, 2)}
-	// initialize both fses
+	// initialize both fses, the ones of the remote data files are only
+	// created if they have files
 	_ = arc.getFs("https")
 	_ = arc.getFs("file")
 	for {
@@ -182,7 +191,7 @@ func ReadArchive(in io.Reader) (*Archive, error) {
 				name = name[2:]
 			}
 			fallthrough
-		case "https", "file":
+		case "https", "file", "s3", "gs":
 			fs := arc.getFs(pfx)
 			name = filepath.FromSlash(name)
 			err = afero.WriteFile(fs, name, data, os.FileMode(hdr.Mode))
@@ -281,7 +290,7 @@ func (arc *Archive) Write(out io.Writer) error {
 	if _, err = w.Write(arc.Data); err != nil {
 		return err
 	}
-	for _, name := range [...]string{"file", "https"} {
+	for _, name := range archivedFilesystems {
 		filesystem, ok := arc.Filesystems[name]
 		if !ok {
 			continue
@@ -0,0 +1,175 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package checkcapture saves the requests and responses that made checks fail
+// to files, so intermittent failures can be debugged after the test run.
+package checkcapture
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxNameLength is the maximum length of the part of the file names that's
+// made from the check name.
+const maxNameLength = 64
+
+// MaxBodySize is the maximum number of bytes of the request and response
+// bodies that are captured, the rest is cut.
+const MaxBodySize = 64 << 10
+
+// Redacted replaces the values of the sensitive headers and of the cookies in
+// the captures, so no credentials are written to the files.
+const Redacted = "[REDACTED]"
+
+//nolint:gochecknoglobals
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+	"X-Auth-Token":        true,
+}
+
+// IsSensitiveHeader returns whether the values of the header have to be
+// redacted in the captures.
+func IsSensitiveHeader(name string) bool {
+	return sensitiveHeaders[http.CanonicalHeaderKey(name)]
+}
+
+// CapBody cuts the body to MaxBodySize bytes.
+func CapBody(body string) string {
+	if len(body) <= MaxBodySize {
+		return body
+	}
+	return body[:MaxBodySize] + "... (cut)"
+}
+
+// Capturable is implemented by the values checks are run on which can be
+// captured when a check fails, like the responses of k6/http.
+type Capturable interface {
+	// CheckFailureCapture returns the request and the response, with the
+	// sensitive headers and the cookies redacted and the bodies capped.
+	CheckFailureCapture() (request, response interface{})
+}
+
+// Failure is what's saved about a failed check of a Capturable value.
+type Failure struct {
+	Check     string      `json:"check"`
+	Time      time.Time   `json:"time"`
+	VU        uint64      `json:"vu"`
+	Iteration int64       `json:"iteration"`
+	Request   interface{} `json:"request"`
+	Response  interface{} `json:"response"`
+}
+
+// Capturer writes the captures to a directory, until either the maximum number
+// of files or the maximum number of bytes is reached. It's shared by all the
+// VUs, so the limits are for the whole test run.
+type Capturer struct {
+	dir               string
+	maxCount, maxSize int64
+
+	mutex       sync.Mutex
+	count, size int64
+}
+
+// New returns a Capturer writing to dir, which is only created with the first
+// capture.
+func New(dir string, maxCount, maxSize int64) *Capturer {
+	return &Capturer{dir: dir, maxCount: maxCount, maxSize: maxSize}
+}
+
+// Full returns whether the limit of files was reached, so nothing more will be
+// captured.
+func (c *Capturer) Full() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.count >= c.maxCount
+}
+
+// Capture writes the JSON of data to a new file named after the check and
+// returns its path. The path is empty if the capture was skipped because it
+// would exceed the limits.
+func (c *Capturer) Capture(checkName string, data interface{}) (string, error) {
+	if c.Full() {
+		return "", nil
+	}
+	content, err := json.MarshalIndent(data, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.count >= c.maxCount || c.size+int64(len(content)) > c.maxSize {
+		return "", nil
+	}
+	if err = os.MkdirAll(c.dir, 0o750); err != nil {
+		return "", fmt.Errorf("couldn't create the directory for the check failure captures: %w", err)
+	}
+	path := filepath.Join(c.dir, fmt.Sprintf("%04d-%s.json", c.count+1, fileName(checkName)))
+	if err = ioutil.WriteFile(path, content, 0o640); err != nil {
+		return "", fmt.Errorf("couldn't save the check failure capture: %w", err)
+	}
+	c.count++
+	c.size += int64(len(content))
+	return path, nil
+}
+
+// CaptureFailure captures the failure of the check with the name on the value,
+// if it's Capturable, and returns the path of the file. Like with Capture, the
+// path is empty if nothing was captured.
+func (c *Capturer) CaptureFailure(checkName string, failure Failure, value interface{}) (string, error) {
+	capturable, ok := value.(Capturable)
+	if !ok || c.Full() {
+		return "", nil
+	}
+	failure.Request, failure.Response = capturable.CheckFailureCapture()
+	if failure.Response == nil {
+		return "", nil
+	}
+	return c.Capture(checkName, failure)
+}
+
+// fileName replaces the characters of a check name which aren't safe in file
+// names.
+func fileName(checkName string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_':
+			return r
+		default:
+			return '_'
+		}
+	}, checkName)
+	if len(name) > maxNameLength {
+		name = name[:maxNameLength]
+	}
+	return name
+}
@@ -0,0 +1,111 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package checkcapture
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureCountLimit(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "captures")
+	c := New(dir, 2, 1<<20)
+
+	path, err := c.Capture("status is 200", map[string]int{"status": 500})
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0001-status_is_200.json"), path)
+	content, err := ioutil.ReadFile(path) //nolint:gosec
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"status": 500}`, string(content))
+
+	path, err = c.Capture("héllo/../world", "second")
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0002-h_llo____world.json"), path)
+	assert.True(t, c.Full())
+
+	path, err = c.Capture("status is 200", "third")
+	require.NoError(t, err)
+	assert.Empty(t, path)
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, files, 2)
+}
+
+func TestCaptureSizeLimit(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "captures")
+	c := New(dir, 10, 100)
+
+	path, err := c.Capture("big", strings.Repeat("x", 200))
+	require.NoError(t, err)
+	assert.Empty(t, path)
+	assert.NoDirExists(t, dir, "the directory is only created with the first capture")
+
+	path, err = c.Capture("small", strings.Repeat("x", 50))
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(dir, "0001-small.json"), path)
+	path, err = c.Capture("small", strings.Repeat("x", 50))
+	require.NoError(t, err)
+	assert.Empty(t, path, "the two captures would be over 100 bytes")
+	assert.False(t, c.Full())
+}
+
+type capturable struct{ response interface{} }
+
+func (c capturable) CheckFailureCapture() (request, response interface{}) {
+	return nil, c.response
+}
+
+func TestCaptureFailure(t *testing.T) {
+	t.Parallel()
+	dir := filepath.Join(t.TempDir(), "captures")
+	c := New(dir, 10, 1<<20)
+
+	path, err := c.CaptureFailure("status", Failure{Check: "::status", VU: 2}, map[string]int{"status": 500})
+	require.NoError(t, err)
+	assert.Empty(t, path, "only Capturable values are captured")
+	path, err = c.CaptureFailure("status", Failure{Check: "::status", VU: 2}, capturable{})
+	require.NoError(t, err)
+	assert.Empty(t, path, "there's no response")
+
+	path, err = c.CaptureFailure("status", Failure{Check: "::status", VU: 2}, capturable{map[string]int{"status": 500}})
+	require.NoError(t, err)
+	content, err := ioutil.ReadFile(path) //nolint:gosec
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"check": "::status", "time": "0001-01-01T00:00:00Z", "vu": 2, "iteration": 0,
+		"request": null, "response": {"status": 500}}`, string(content))
+}
+
+func TestRedaction(t *testing.T) {
+	t.Parallel()
+	assert.True(t, IsSensitiveHeader("authorization"))
+	assert.True(t, IsSensitiveHeader("Set-Cookie"))
+	assert.False(t, IsSensitiveHeader("Content-Type"))
+
+	assert.Equal(t, "short", CapBody("short"))
+	assert.Equal(t, strings.Repeat("x", MaxBodySize)+"... (cut)", CapBody(strings.Repeat("x", MaxBodySize+1)))
+}
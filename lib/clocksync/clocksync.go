@@ -0,0 +1,136 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package clocksync measures the offset of the local clock, so the timestamps
+// of the samples of the different instances of k6 running a test can be
+// aligned, even if their clocks are skewed.
+package clocksync
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+)
+
+// MetadataKey is the key of the run-level metadata with the clock offset, so
+// it's known even if the sample times are adjusted.
+const MetadataKey = "clock_offset"
+
+const (
+	defaultPort = "123"
+	packetSize  = 48
+	// ntpEpochOffset is the number of seconds between the NTP epoch, in 1900,
+	// and the unix one.
+	ntpEpochOffset = 2208988800
+
+	// the first byte of a SNTPv4 client request: no leap indicator, version 4
+	// and mode 3 (client)
+	clientHeader = 0<<6 | 4<<3 | 3
+	modeServer   = 4
+)
+
+// Offset is the measured offset of the local clock, which has to be added to
+// the local time to get the time of the server.
+type Offset struct {
+	Offset, RoundTrip time.Duration
+}
+
+// QueryNTP measures the offset of the local clock with a SNTP request to the
+// NTP server, whose port is 123 if it's not set.
+func QueryNTP(ctx context.Context, server string) (Offset, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, defaultPort)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "udp", server)
+	if err != nil {
+		return Offset{}, err
+	}
+	defer func() { _ = conn.Close() }()
+	if deadline, ok := ctx.Deadline(); ok {
+		if err = conn.SetDeadline(deadline); err != nil {
+			return Offset{}, err
+		}
+	}
+
+	req := make([]byte, packetSize)
+	req[0] = clientHeader
+	sent := time.Now()
+	// the server copies the transmit timestamp of the request to the origin
+	// one of its response, which is how responses to other requests are told
+	// apart
+	binary.BigEndian.PutUint64(req[40:], toNTPTime(sent))
+	if _, err = conn.Write(req); err != nil {
+		return Offset{}, err
+	}
+
+	resp := make([]byte, packetSize)
+	for {
+		n, err := conn.Read(resp)
+		if err != nil {
+			return Offset{}, err
+		}
+		received := time.Now()
+		if n < packetSize || binary.BigEndian.Uint64(resp[24:]) != binary.BigEndian.Uint64(req[40:]) {
+			continue
+		}
+		return parseResponse(resp, sent, received)
+	}
+}
+
+// parseResponse returns the offset of a NTP response, with the usual
+// ((T2 - T1) + (T3 - T4)) / 2 formula, where T1 and T4 are when the request was
+// sent and the response received, and T2 and T3 when the server received the
+// request and sent the response.
+func parseResponse(resp []byte, sent, received time.Time) (Offset, error) {
+	if mode := resp[0] & 0x7; mode != modeServer {
+		return Offset{}, fmt.Errorf("invalid NTP response mode %d", mode)
+	}
+	if stratum := resp[1]; stratum == 0 {
+		// a kiss-o'-death packet, whose reference ID is the reason
+		return Offset{}, fmt.Errorf("the NTP server refused the request: %s", resp[12:16])
+	}
+	serverReceived := fromNTPTime(binary.BigEndian.Uint64(resp[32:]))
+	serverSent := fromNTPTime(binary.BigEndian.Uint64(resp[40:]))
+	if serverSent.Before(serverReceived) {
+		return Offset{}, errors.New("invalid NTP response timestamps")
+	}
+	return Offset{
+		Offset:    (serverReceived.Sub(sent) + serverSent.Sub(received)) / 2,
+		RoundTrip: received.Sub(sent) - serverSent.Sub(serverReceived),
+	}, nil
+}
+
+// toNTPTime returns the 64 bit NTP timestamp of t, whose high 32 bits are the
+// seconds since the NTP epoch, and the low ones the fraction of a second.
+func toNTPTime(t time.Time) uint64 {
+	secs := uint64(t.Unix() + ntpEpochOffset)
+	frac := uint64(t.Nanosecond()) << 32 / uint64(time.Second)
+	return secs<<32 | frac
+}
+
+func fromNTPTime(ts uint64) time.Time {
+	secs := int64(ts>>32) - ntpEpochOffset
+	nanos := int64((ts & 0xffffffff) * uint64(time.Second) >> 32)
+	return time.Unix(secs, nanos)
+}
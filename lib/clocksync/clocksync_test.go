@@ -0,0 +1,117 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clocksync
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startNTPServer starts a NTP server whose clock is skewed by skew, and which
+// replies to each request with the responses modified by modify.
+func startNTPServer(t *testing.T, skew time.Duration, modify func(resp []byte)) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go func() {
+		req := make([]byte, packetSize)
+		for {
+			n, addr, err := conn.ReadFrom(req)
+			if err != nil {
+				return
+			}
+			received := time.Now().Add(skew)
+			if n != packetSize || req[0] != clientHeader {
+				continue
+			}
+			resp := make([]byte, packetSize)
+			resp[0] = 4<<3 | modeServer
+			resp[1] = 2 // stratum
+			copy(resp[24:32], req[40:48])
+			binary.BigEndian.PutUint64(resp[32:], toNTPTime(received))
+			binary.BigEndian.PutUint64(resp[40:], toNTPTime(time.Now().Add(skew)))
+			if modify != nil {
+				modify(resp)
+			}
+			_, _ = conn.WriteTo(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().String()
+}
+
+func TestNTPTime(t *testing.T) {
+	t.Parallel()
+	now := time.Date(2022, 2, 3, 4, 5, 6, 789012345, time.UTC)
+	assert.WithinDuration(t, now, fromNTPTime(toNTPTime(now)), time.Nanosecond)
+	assert.Equal(t, uint64(ntpEpochOffset)<<32, toNTPTime(time.Unix(0, 0)))
+}
+
+func TestQueryNTP(t *testing.T) {
+	t.Parallel()
+
+	t.Run("skewed", func(t *testing.T) {
+		t.Parallel()
+		for _, skew := range []time.Duration{0, 2 * time.Second, -1500 * time.Millisecond} {
+			server := startNTPServer(t, skew, nil)
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			offset, err := QueryNTP(ctx, server)
+			cancel()
+			require.NoError(t, err)
+			assert.InDelta(t, float64(skew), float64(offset.Offset), float64(50*time.Millisecond), skew)
+			assert.True(t, offset.RoundTrip >= 0, offset.RoundTrip)
+		}
+	})
+
+	t.Run("kiss of death", func(t *testing.T) {
+		t.Parallel()
+		server := startNTPServer(t, 0, func(resp []byte) {
+			resp[1] = 0
+			copy(resp[12:16], "RATE")
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := QueryNTP(ctx, server)
+		require.Error(t, err)
+		assert.Equal(t, "the NTP server refused the request: RATE", err.Error())
+	})
+
+	t.Run("other response", func(t *testing.T) {
+		t.Parallel()
+		server := startNTPServer(t, 0, func(resp []byte) {
+			binary.BigEndian.PutUint64(resp[24:], 42)
+		})
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		defer cancel()
+		_, err := QueryNTP(ctx, server)
+		require.Error(t, err)
+		var netErr net.Error
+		require.ErrorAs(t, err, &netErr)
+		assert.True(t, netErr.Timeout())
+	})
+}
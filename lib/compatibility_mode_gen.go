@@ -7,9 +7,9 @@ import (
 	"fmt"
 )
 
-const _CompatibilityModeName = "extendedbase"
+const _CompatibilityModeName = "extendedbaseexperimental_enhanced"
 
-var _CompatibilityModeIndex = [...]uint8{0, 8, 12}
+var _CompatibilityModeIndex = [...]uint8{0, 8, 12, 33}
 
 func (i CompatibilityMode) String() string {
 	i -= 1
@@ -19,11 +19,12 @@ func (i CompatibilityMode) String() string {
 	return _CompatibilityModeName[_CompatibilityModeIndex[i]:_CompatibilityModeIndex[i+1]]
 }
 
-var _CompatibilityModeValues = []CompatibilityMode{1, 2}
+var _CompatibilityModeValues = []CompatibilityMode{1, 2, 3}
 
 var _CompatibilityModeNameToValueMap = map[string]CompatibilityMode{
-	_CompatibilityModeName[0:8]:  1,
-	_CompatibilityModeName[8:12]: 2,
+	_CompatibilityModeName[0:8]:   1,
+	_CompatibilityModeName[8:12]:  2,
+	_CompatibilityModeName[12:33]: 3,
 }
 
 // CompatibilityModeString retrieves an enum value from the enum constants string name.
@@ -27,4 +27,5 @@ const (
 	SetupFn         = "setup"
 	TeardownFn      = "teardown"
 	HandleSummaryFn = "handleSummary"
+	EnvSchema       = "envSchema"
 )
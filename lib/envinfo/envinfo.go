@@ -0,0 +1,297 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package envinfo detects facts about the environment k6 runs in, like its
+// cloud provider, instance type, region and Kubernetes pod, so the results of
+// the different instances of k6 running a test can be told apart.
+package envinfo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// The keys of the detected metadata, only the ones that could be detected are
+// set.
+const (
+	KeyHostname      = "hostname"
+	KeyCloudProvider = "cloud_provider"
+	KeyInstanceType  = "instance_type"
+	KeyRegion        = "region"
+	KeyZone          = "zone"
+	KeyK8sNamespace  = "k8s_namespace"
+	KeyK8sPod        = "k8s_pod"
+	KeyK8sNode       = "k8s_node"
+)
+
+// The values of the KeyCloudProvider metadata.
+const (
+	ProviderAWS   = "aws"
+	ProviderGCP   = "gcp"
+	ProviderAzure = "azure"
+)
+
+const (
+	// metadataTimeout is the time all the requests to the instance metadata
+	// service have to finish in, so the start of the test isn't delayed much.
+	metadataTimeout = time.Second
+	metadataHost    = "http://169.254.169.254"
+	maxResponseSize = 64 << 10
+
+	dmiDir           = "/sys/class/dmi/id/"
+	hypervisorUUID   = "/sys/hypervisor/uuid"
+	k8sNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	// azureAssetTag is the chassis asset tag of all the Azure VMs.
+	azureAssetTag = "7783-7084-3265-9085-8269-3286-77"
+)
+
+// Detect returns the metadata of the environment, from the env vars, the
+// files of the system and the instance metadata service of the cloud
+// provider. The latter is only queried if the system says it's a VM of that
+// provider. Nothing can fail, the metadata that couldn't be detected is just
+// missing.
+func Detect(ctx context.Context, logger logrus.FieldLogger, env map[string]string) map[string]string {
+	// the instance metadata services are link-local, so never behind proxies
+	transport := http.DefaultTransport.(*http.Transport).Clone() //nolint:forcetypeassert
+	transport.Proxy = nil
+	d := &detector{
+		logger:       logger,
+		env:          env,
+		fs:           afero.NewOsFs(),
+		client:       &http.Client{Transport: transport},
+		metadataHost: metadataHost,
+		hostname:     os.Hostname,
+	}
+	return d.detect(ctx)
+}
+
+type detector struct {
+	logger       logrus.FieldLogger
+	env          map[string]string
+	fs           afero.Fs
+	client       *http.Client
+	metadataHost string
+	hostname     func() (string, error)
+}
+
+func (d *detector) detect(ctx context.Context) map[string]string {
+	metadata := make(map[string]string)
+	if hostname, err := d.hostname(); err == nil {
+		setIfNotEmpty(metadata, KeyHostname, hostname)
+	}
+	d.detectKubernetes(metadata)
+	d.detectCloud(ctx, metadata)
+	return metadata
+}
+
+// detectKubernetes detects the pod, which is named like its hostname unless
+// the downward API says otherwise, its node and its namespace. The node is
+// only known if it's in the env, with the downward API.
+func (d *detector) detectKubernetes(metadata map[string]string) {
+	if d.env["KUBERNETES_SERVICE_HOST"] == "" {
+		return
+	}
+	pod := envValue(d.env, "K8S_POD_NAME", "POD_NAME")
+	if pod == "" {
+		pod = metadata[KeyHostname]
+	}
+	setIfNotEmpty(metadata, KeyK8sPod, pod)
+	setIfNotEmpty(metadata, KeyK8sNode, envValue(d.env, "K8S_NODE_NAME", "NODE_NAME"))
+	namespace := envValue(d.env, "K8S_NAMESPACE", "POD_NAMESPACE")
+	if namespace == "" {
+		namespace = d.readFile(k8sNamespaceFile)
+	}
+	setIfNotEmpty(metadata, KeyK8sNamespace, namespace)
+}
+
+func (d *detector) detectCloud(ctx context.Context, metadata map[string]string) {
+	provider := d.vmProvider()
+	if provider == "" {
+		// containers of services like ECS or Lambda don't have the instance
+		// metadata service, but they have the env vars of the AWS tools
+		if d.env["AWS_EXECUTION_ENV"] != "" {
+			metadata[KeyCloudProvider] = ProviderAWS
+			setIfNotEmpty(metadata, KeyRegion, envValue(d.env, "AWS_REGION", "AWS_DEFAULT_REGION"))
+		}
+		return
+	}
+	metadata[KeyCloudProvider] = provider
+
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+	var err error
+	switch provider {
+	case ProviderAWS:
+		err = d.queryAWS(ctx, metadata)
+	case ProviderGCP:
+		err = d.queryGCP(ctx, metadata)
+	case ProviderAzure:
+		err = d.queryAzure(ctx, metadata)
+	}
+	if err != nil {
+		d.logger.WithError(err).Debugf("Couldn't query the %s instance metadata service", provider)
+	}
+}
+
+// vmProvider returns the cloud provider of the VM, from the DMI information of
+// the system, like cloud-init does it.
+func (d *detector) vmProvider() string {
+	vendor := d.readFile(dmiDir + "sys_vendor")
+	switch {
+	case vendor == "Amazon EC2", strings.HasPrefix(strings.ToLower(d.readFile(hypervisorUUID)), "ec2"):
+		return ProviderAWS
+	case vendor == "Google", d.readFile(dmiDir+"product_name") == "Google Compute Engine":
+		return ProviderGCP
+	case d.readFile(dmiDir+"chassis_asset_tag") == azureAssetTag:
+		return ProviderAzure
+	default:
+		return ""
+	}
+}
+
+// queryAWS queries the EC2 instance metadata service, with a IMDSv2 token.
+func (d *detector) queryAWS(ctx context.Context, metadata map[string]string) error {
+	token, err := d.query(ctx, http.MethodPut, "/latest/api/token",
+		map[string]string{"X-aws-ec2-metadata-token-ttl-seconds": "60"})
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"X-aws-ec2-metadata-token": token}
+	for _, item := range []struct{ key, path string }{
+		{KeyInstanceType, "/latest/meta-data/instance-type"},
+		{KeyRegion, "/latest/meta-data/placement/region"},
+		{KeyZone, "/latest/meta-data/placement/availability-zone"},
+	} {
+		value, err := d.query(ctx, http.MethodGet, item.path, headers)
+		if err != nil {
+			return err
+		}
+		setIfNotEmpty(metadata, item.key, value)
+	}
+	return nil
+}
+
+// queryGCP queries the Compute Engine metadata server, whose values are like
+// projects/123/machineTypes/e2-medium and projects/123/zones/us-central1-a.
+func (d *detector) queryGCP(ctx context.Context, metadata map[string]string) error {
+	headers := map[string]string{"Metadata-Flavor": "Google"}
+	machineType, err := d.query(ctx, http.MethodGet, "/computeMetadata/v1/instance/machine-type", headers)
+	if err != nil {
+		return err
+	}
+	setIfNotEmpty(metadata, KeyInstanceType, lastPathSegment(machineType))
+	zone, err := d.query(ctx, http.MethodGet, "/computeMetadata/v1/instance/zone", headers)
+	if err != nil {
+		return err
+	}
+	zone = lastPathSegment(zone)
+	setIfNotEmpty(metadata, KeyZone, zone)
+	if i := strings.LastIndexByte(zone, '-'); i > 0 {
+		metadata[KeyRegion] = zone[:i]
+	}
+	return nil
+}
+
+// queryAzure queries the Azure Instance Metadata Service.
+func (d *detector) queryAzure(ctx context.Context, metadata map[string]string) error {
+	body, err := d.query(ctx, http.MethodGet, "/metadata/instance/compute?api-version=2021-02-01",
+		map[string]string{"Metadata": "true"})
+	if err != nil {
+		return err
+	}
+	var compute struct {
+		VMSize   string `json:"vmSize"`
+		Location string `json:"location"`
+		Zone     string `json:"zone"`
+	}
+	if err = json.Unmarshal([]byte(body), &compute); err != nil {
+		return fmt.Errorf("invalid compute metadata: %w", err)
+	}
+	setIfNotEmpty(metadata, KeyInstanceType, compute.VMSize)
+	setIfNotEmpty(metadata, KeyRegion, compute.Location)
+	setIfNotEmpty(metadata, KeyZone, compute.Zone)
+	return nil
+}
+
+func (d *detector) query(ctx context.Context, method, path string, headers map[string]string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, method, d.metadataHost+path, nil)
+	if err != nil {
+		return "", err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s %s returned the status %d", method, path, resp.StatusCode)
+	}
+	return strings.TrimSpace(string(body)), nil
+}
+
+// readFile returns the trimmed content of a file, or an empty string if it
+// can't be read, e.g. because the system isn't linux or a pod.
+func (d *detector) readFile(name string) string {
+	data, err := afero.ReadFile(d.fs, name)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			d.logger.WithError(err).Debugf("Couldn't read %s", name)
+		}
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+func envValue(env map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := env[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func lastPathSegment(s string) string {
+	return s[strings.LastIndexByte(s, '/')+1:]
+}
+
+func setIfNotEmpty(metadata map[string]string, key, value string) {
+	if value != "" {
+		metadata[key] = value
+	}
+}
@@ -0,0 +1,184 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package envinfo
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/testutils"
+)
+
+func newTestDetector(t *testing.T, env map[string]string, files map[string]string, h http.Handler) *detector {
+	t.Helper()
+	fs := afero.NewMemMapFs()
+	for name, data := range files {
+		require.NoError(t, afero.WriteFile(fs, name, []byte(data+"\n"), 0o644))
+	}
+	d := &detector{
+		logger:   testutils.NewLogger(t),
+		env:      env,
+		fs:       fs,
+		client:   http.DefaultClient,
+		hostname: func() (string, error) { return "k6-agent-1", nil },
+	}
+	if h != nil {
+		srv := httptest.NewServer(h)
+		t.Cleanup(srv.Close)
+		d.metadataHost = srv.URL
+	} else {
+		d.metadataHost = "http://127.0.0.1:1"
+	}
+	return d
+}
+
+func TestDetect(t *testing.T) {
+	t.Parallel()
+
+	t.Run("bare", func(t *testing.T) {
+		t.Parallel()
+		d := newTestDetector(t, nil, nil, nil)
+		assert.Equal(t, map[string]string{KeyHostname: "k6-agent-1"}, d.detect(context.Background()))
+
+		d.hostname = func() (string, error) { return "", errors.New("no hostname") }
+		assert.Empty(t, d.detect(context.Background()))
+	})
+
+	t.Run("kubernetes", func(t *testing.T) {
+		t.Parallel()
+		env := map[string]string{"KUBERNETES_SERVICE_HOST": "10.0.0.1", "NODE_NAME": "node-a"}
+		files := map[string]string{k8sNamespaceFile: "load-tests"}
+		d := newTestDetector(t, env, files, nil)
+		assert.Equal(t, map[string]string{
+			KeyHostname:     "k6-agent-1",
+			KeyK8sPod:       "k6-agent-1",
+			KeyK8sNode:      "node-a",
+			KeyK8sNamespace: "load-tests",
+		}, d.detect(context.Background()))
+
+		env["POD_NAME"], env["POD_NAMESPACE"] = "k6-pod", "other"
+		metadata := d.detect(context.Background())
+		assert.Equal(t, "k6-pod", metadata[KeyK8sPod])
+		assert.Equal(t, "other", metadata[KeyK8sNamespace])
+	})
+
+	t.Run("aws", func(t *testing.T) {
+		t.Parallel()
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/latest/api/token" {
+				assert.Equal(t, http.MethodPut, r.Method)
+				assert.Equal(t, "60", r.Header.Get("X-aws-ec2-metadata-token-ttl-seconds"))
+				_, _ = w.Write([]byte("secret-token"))
+				return
+			}
+			if r.Header.Get("X-aws-ec2-metadata-token") != "secret-token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			values := map[string]string{
+				"/latest/meta-data/instance-type":               "c5.large",
+				"/latest/meta-data/placement/region":            "eu-west-1",
+				"/latest/meta-data/placement/availability-zone": "eu-west-1b",
+			}
+			_, _ = w.Write([]byte(values[r.URL.Path]))
+		})
+		d := newTestDetector(t, nil, map[string]string{dmiDir + "sys_vendor": "Amazon EC2"}, h)
+		assert.Equal(t, map[string]string{
+			KeyHostname:      "k6-agent-1",
+			KeyCloudProvider: ProviderAWS,
+			KeyInstanceType:  "c5.large",
+			KeyRegion:        "eu-west-1",
+			KeyZone:          "eu-west-1b",
+		}, d.detect(context.Background()))
+	})
+
+	t.Run("aws container", func(t *testing.T) {
+		t.Parallel()
+		env := map[string]string{"AWS_EXECUTION_ENV": "AWS_ECS_FARGATE", "AWS_REGION": "us-east-2"}
+		d := newTestDetector(t, env, nil, nil)
+		assert.Equal(t, map[string]string{
+			KeyHostname:      "k6-agent-1",
+			KeyCloudProvider: ProviderAWS,
+			KeyRegion:        "us-east-2",
+		}, d.detect(context.Background()))
+	})
+
+	t.Run("gcp", func(t *testing.T) {
+		t.Parallel()
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata-Flavor") != "Google" {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			values := map[string]string{
+				"/computeMetadata/v1/instance/machine-type": "projects/123/machineTypes/e2-standard-4",
+				"/computeMetadata/v1/instance/zone":         "projects/123/zones/europe-west4-a",
+			}
+			_, _ = w.Write([]byte(values[r.URL.Path]))
+		})
+		d := newTestDetector(t, nil, map[string]string{dmiDir + "product_name": "Google Compute Engine"}, h)
+		assert.Equal(t, map[string]string{
+			KeyHostname:      "k6-agent-1",
+			KeyCloudProvider: ProviderGCP,
+			KeyInstanceType:  "e2-standard-4",
+			KeyRegion:        "europe-west4",
+			KeyZone:          "europe-west4-a",
+		}, d.detect(context.Background()))
+	})
+
+	t.Run("azure", func(t *testing.T) {
+		t.Parallel()
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Metadata") != "true" || r.URL.Query().Get("api-version") == "" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			_, _ = w.Write([]byte(`{"location": "westeurope", "vmSize": "Standard_D2s_v3", "zone": "2"}`))
+		})
+		d := newTestDetector(t, nil, map[string]string{dmiDir + "chassis_asset_tag": azureAssetTag}, h)
+		assert.Equal(t, map[string]string{
+			KeyHostname:      "k6-agent-1",
+			KeyCloudProvider: ProviderAzure,
+			KeyInstanceType:  "Standard_D2s_v3",
+			KeyRegion:        "westeurope",
+			KeyZone:          "2",
+		}, d.detect(context.Background()))
+	})
+
+	t.Run("metadata service error", func(t *testing.T) {
+		t.Parallel()
+		h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+		d := newTestDetector(t, nil, map[string]string{dmiDir + "sys_vendor": "Google"}, h)
+		assert.Equal(t, map[string]string{
+			KeyHostname:      "k6-agent-1",
+			KeyCloudProvider: ProviderGCP,
+		}, d.detect(context.Background()))
+	})
+}
@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"sync"
+	"time"
+)
+
+// EventType is the type of an Event.
+type EventType string
+
+// The events emitted during a test run.
+const (
+	EventTestStarted      EventType = "test-started"
+	EventStageChanged     EventType = "stage-changed"
+	EventThresholdCrossed EventType = "threshold-crossed"
+	EventScenarioFinished EventType = "scenario-finished"
+	EventAbortInitiated   EventType = "abort-initiated"
+	EventTestFinished     EventType = "test-finished"
+	EventAnomalyDetected  EventType = "anomaly-detected"
+)
+
+// eventSubscriberBufSize is how many events a subscriber can fall behind,
+// before it starts missing events.
+const eventSubscriberBufSize = 100
+
+// Event is something which happened during the test run, like a change of the
+// execution status or a threshold which started failing.
+type Event struct {
+	Type EventType              `json:"type"`
+	Time time.Time              `json:"time"`
+	Data map[string]interface{} `json:"data,omitempty"`
+}
+
+// EventEmitter sends the emitted events to all of its subscribers. It never
+// blocks, so a subscriber which doesn't keep up misses some of the events.
+type EventEmitter struct {
+	mx          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+// NewEventEmitter returns an EventEmitter without any subscribers.
+func NewEventEmitter() *EventEmitter {
+	return &EventEmitter{subscribers: make(map[chan Event]struct{})}
+}
+
+// Subscribe returns a channel with the events emitted from now on, and a
+// function which unsubscribes and closes the channel.
+func (e *EventEmitter) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventSubscriberBufSize)
+	e.mx.Lock()
+	e.subscribers[ch] = struct{}{}
+	e.mx.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			e.mx.Lock()
+			delete(e.subscribers, ch)
+			e.mx.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// Emit sends an event with the given type and data to all of the subscribers.
+func (e *EventEmitter) Emit(typ EventType, data map[string]interface{}) {
+	e.EmitEvent(Event{Type: typ, Time: time.Now(), Data: data})
+}
+
+// EmitEvent sends the event to all of the subscribers, for an event which
+// happened at another time than now, like an anomaly in an interval.
+func (e *EventEmitter) EmitEvent(event Event) {
+	e.mx.Lock()
+	defer e.mx.Unlock()
+	for ch := range e.subscribers {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+}
@@ -0,0 +1,84 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventEmitter(t *testing.T) {
+	t.Parallel()
+	emitter := NewEventEmitter()
+	emitter.Emit(EventTestStarted, nil) // no subscribers yet
+
+	first, unsubscribeFirst := emitter.Subscribe()
+	second, unsubscribeSecond := emitter.Subscribe()
+	defer unsubscribeSecond()
+
+	emitter.Emit(EventScenarioFinished, map[string]interface{}{"scenario": "default"})
+	for _, ch := range []<-chan Event{first, second} {
+		require.Len(t, ch, 1)
+		event := <-ch
+		assert.Equal(t, EventScenarioFinished, event.Type)
+		assert.Equal(t, map[string]interface{}{"scenario": "default"}, event.Data)
+		assert.False(t, event.Time.IsZero())
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	_, ok := <-first
+	assert.False(t, ok, "the channel should be closed")
+
+	// A subscriber which doesn't keep up misses events, instead of blocking
+	for i := 0; i < eventSubscriberBufSize+10; i++ {
+		emitter.Emit(EventStageChanged, nil)
+	}
+	assert.Len(t, second, eventSubscriberBufSize)
+}
+
+func TestExecutionStateEvents(t *testing.T) {
+	t.Parallel()
+	et, err := NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := NewExecutionState(Options{}, et, 1, 1)
+	events, unsubscribe := es.Events.Subscribe()
+	defer unsubscribe()
+
+	es.SetExecutionStatus(ExecutionStatusInitVUs)
+	es.SetExecutionStatus(ExecutionStatusInitVUs)
+	es.MarkStarted()
+	es.MarkEnded()
+
+	var types []EventType
+	for len(events) > 0 {
+		event := <-events
+		types = append(types, event.Type)
+		if len(types) == 1 {
+			assert.Equal(t, map[string]interface{}{"stage": "InitVUs", "previous": "Created"}, event.Data)
+		}
+	}
+	assert.Equal(t, []EventType{
+		EventStageChanged, EventTestStarted, EventStageChanged, EventStageChanged, EventTestFinished,
+	}, types)
+}
@@ -67,6 +67,10 @@ type ExecutionScheduler interface {
 		builtinMetrics *metrics.BuiltinMetrics,
 	) error
 
+	// Stop a single scenario, interrupting its iterations, while the others
+	// keep running. It does nothing if the scenario isn't running.
+	StopScenario(name string)
+
 	// Pause a test, or start/resume it. To check if a test is paused, use
 	// GetState().IsPaused().
 	//
@@ -126,6 +130,16 @@ const (
 	ExecutionStatusInterrupted
 )
 
+// MetricsReader reads the values of the metrics of the test run, as they're
+// aggregated for the thresholds.
+type MetricsReader interface {
+	// MetricValue returns the value of the aggregation method of the metric
+	// with the name, one of the ones of the threshold expressions, e.g.
+	// "count" or "p(95)". Like in the thresholds, a metric without samples
+	// yet has values of 0, and it returns an error for the unknown metrics.
+	MetricValue(name, method string) (float64, error)
+}
+
 // ExecutionState contains a few different things:
 //  -  Some convenience items, that are needed by all executors, like the
 //     execution segment and the unique VU ID generator. By keeping those here,
@@ -156,6 +170,14 @@ type ExecutionState struct {
 
 	ExecutionTuple *ExecutionTuple // TODO Rename, possibly move
 
+	// Events emits the events of the test run, like the changes of the
+	// execution status, to the subscribers of the REST API.
+	Events *EventEmitter
+
+	// Metrics reads the values of the metrics of the test run so far, for the
+	// scripts, nil until the engine is created.
+	Metrics MetricsReader
+
 	// vus is the shared channel buffer that contains all of the VUs that have
 	// been initialized and aren't currently being used by a executor.
 	//
@@ -228,6 +250,15 @@ type ExecutionState struct {
 	// API, etc.
 	interruptedIterationsCount *uint64
 
+	// The total number of iterations that weren't started, because there
+	// weren't enough VUs or because the maxDuration of the executor was
+	// reached, as emitted in the dropped_iterations metric.
+	droppedIterationsCount *uint64
+
+	// The total number of HTTP requests with unexpected responses, as emitted
+	// in the http_req_failed metric.
+	failedRequestsCount *uint64
+
 	// A machine-readable indicator in which the current state of the test
 	// execution is currently stored. Useful for the REST API and external
 	// observability of the k6 test run progress.
@@ -296,6 +327,8 @@ func NewExecutionState(options Options, et *ExecutionTuple, maxPlannedVUs, maxPo
 		activeVUs:                  new(int64),
 		fullIterationsCount:        new(uint64),
 		interruptedIterationsCount: new(uint64),
+		droppedIterationsCount:     new(uint64),
+		failedRequestsCount:        new(uint64),
 		startTime:                  new(int64),
 		endTime:                    new(int64),
 		currentPauseTime:           new(int64),
@@ -303,6 +336,7 @@ func NewExecutionState(options Options, et *ExecutionTuple, maxPlannedVUs, maxPo
 		totalPausedDuration:        0, // Accessed only behind the pauseStateLock
 		resumeNotify:               resumeNotify,
 		ExecutionTuple:             et,
+		Events:                     NewEventEmitter(),
 	}
 }
 
@@ -382,10 +416,48 @@ func (es *ExecutionState) AddInterruptedIterations(count uint64) uint64 {
 	return atomic.AddUint64(es.interruptedIterationsCount, count)
 }
 
+// GetDroppedIterationCount returns the total of iterations that were dropped
+// so far.
+//
+// IMPORTANT: for UI/information purposes only, don't use for synchronization.
+func (es *ExecutionState) GetDroppedIterationCount() uint64 {
+	return atomic.LoadUint64(es.droppedIterationsCount)
+}
+
+// AddDroppedIterations increments the number of dropped iterations by the
+// provided amount.
+//
+// IMPORTANT: for UI/information purposes only, don't use for synchronization.
+func (es *ExecutionState) AddDroppedIterations(count uint64) uint64 {
+	return atomic.AddUint64(es.droppedIterationsCount, count)
+}
+
+// GetFailedRequestCount returns the total of HTTP requests that failed so far.
+//
+// IMPORTANT: for UI/information purposes only, don't use for synchronization.
+func (es *ExecutionState) GetFailedRequestCount() uint64 {
+	return atomic.LoadUint64(es.failedRequestsCount)
+}
+
+// AddFailedRequests increments the number of failed HTTP requests by the
+// provided amount.
+//
+// IMPORTANT: for UI/information purposes only, don't use for synchronization.
+func (es *ExecutionState) AddFailedRequests(count uint64) uint64 {
+	return atomic.AddUint64(es.failedRequestsCount, count)
+}
+
 // SetExecutionStatus changes the current execution status to the supplied value
 // and returns the current value.
 func (es *ExecutionState) SetExecutionStatus(newStatus ExecutionStatus) (oldStatus ExecutionStatus) {
-	return ExecutionStatus(atomic.SwapUint32(es.executionStatus, uint32(newStatus)))
+	oldStatus = ExecutionStatus(atomic.SwapUint32(es.executionStatus, uint32(newStatus)))
+	if oldStatus != newStatus {
+		es.Events.Emit(EventStageChanged, map[string]interface{}{
+			"stage":    newStatus.String(),
+			"previous": oldStatus.String(),
+		})
+	}
+	return oldStatus
 }
 
 // GetCurrentExecutionStatus returns the current execution status. Don't use
@@ -403,6 +475,7 @@ func (es *ExecutionState) MarkStarted() {
 	if !atomic.CompareAndSwapInt64(es.startTime, 0, time.Now().UnixNano()) {
 		panic("the execution scheduler was started a second time")
 	}
+	es.Events.Emit(EventTestStarted, nil)
 	es.SetExecutionStatus(ExecutionStatusStarted)
 }
 
@@ -415,6 +488,7 @@ func (es *ExecutionState) MarkEnded() {
 		panic("the execution scheduler was stopped a second time")
 	}
 	es.SetExecutionStatus(ExecutionStatusEnded)
+	es.Events.Emit(EventTestFinished, nil)
 }
 
 // HasStarted returns true if the test has actually started executing.
@@ -426,6 +500,16 @@ func (es *ExecutionState) HasStarted() bool {
 	return atomic.LoadInt64(es.startTime) != 0
 }
 
+// GetStartTime returns the time the test started executing at, or the zero
+// time if it hasn't started yet.
+func (es *ExecutionState) GetStartTime() time.Time {
+	startTime := atomic.LoadInt64(es.startTime)
+	if startTime == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, startTime)
+}
+
 // HasEnded returns true if the test has finished executing. It will return
 // false until MarkEnded() is called.
 func (es *ExecutionState) HasEnded() bool {
@@ -0,0 +1,451 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+	"go.k6.io/k6/ui/pb"
+)
+
+const accessLogReplayType = "access-log-replay"
+
+func init() {
+	lib.RegisterExecutorConfigType(
+		accessLogReplayType,
+		func(name string, rawJSON []byte) (lib.ExecutorConfig, error) {
+			config := NewAccessLogReplayConfig(name)
+			err := lib.StrictJSONUnmarshal(rawJSON, &config)
+			if err == nil {
+				config.requests, config.offsets, config.logErr = parseAccessLog(config.Log.String)
+			}
+			return config, err
+		},
+	)
+}
+
+// AccessLogReplayConfig stores the config for the access-log-replay executor,
+// which starts an iteration for every request of an access log, at the time
+// it was recorded, so the iterations can reproduce the production traffic
+// with the request of exec.scenario.request.
+type AccessLogReplayConfig struct {
+	BaseConfig
+	// Log is the CSV of the requests, with their method, path and the time
+	// since the previous request, in seconds or as a duration, in each row.
+	// A header row is skipped. It's usually open()-ed in the init context.
+	Log null.String `json:"log"`
+	// Speed is how many times faster than recorded the requests are replayed.
+	Speed null.Float `json:"speed"`
+	Loops null.Int   `json:"loops"`
+
+	PreAllocatedVUs null.Int `json:"preAllocatedVUs"`
+	MaxVUs          null.Int `json:"maxVUs"`
+
+	requests []lib.ScenarioRequest
+	// offsets are the times of the requests since the start of the log.
+	offsets []time.Duration
+	logErr  error
+}
+
+// NewAccessLogReplayConfig returns an AccessLogReplayConfig with default values
+func NewAccessLogReplayConfig(name string) *AccessLogReplayConfig {
+	return &AccessLogReplayConfig{
+		BaseConfig: NewBaseConfig(name, accessLogReplayType),
+		Speed:      null.NewFloat(1, false),
+		Loops:      null.NewInt(1, false),
+	}
+}
+
+// Make sure we implement the lib.ExecutorConfig interface
+var _ lib.ExecutorConfig = &AccessLogReplayConfig{}
+
+// parseAccessLog returns the requests of the CSV access log, with their times
+// since its start.
+func parseAccessLog(log string) ([]lib.ScenarioRequest, []time.Duration, error) {
+	reader := csv.NewReader(strings.NewReader(log))
+	reader.FieldsPerRecord = 3
+	reader.TrimLeadingSpace = true
+	var (
+		requests []lib.ScenarioRequest
+		offsets  []time.Duration
+		offset   time.Duration
+	)
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return requests, offsets, nil
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("the log isn't a valid CSV of methods, paths and delays: %w", err)
+		}
+		delay, err := parseAccessLogDelay(strings.TrimSpace(record[2]))
+		switch {
+		case err != nil && row == 1:
+			continue // the header
+		case err != nil || delay < 0:
+			return nil, nil, fmt.Errorf("the row %d of the log has the invalid delay %q", row, record[2])
+		case record[0] == "" || record[1] == "":
+			return nil, nil, fmt.Errorf("the row %d of the log doesn't have a method and a path", row)
+		}
+		offset += delay
+		requests = append(requests, lib.ScenarioRequest{Method: strings.ToUpper(record[0]), Path: record[1]})
+		offsets = append(offsets, offset)
+	}
+}
+
+// parseAccessLogDelay parses a delay of the log, in seconds or as a duration.
+func parseAccessLogDelay(delay string) (time.Duration, error) {
+	if seconds, err := strconv.ParseFloat(delay, 64); err == nil {
+		if math.IsNaN(seconds) || math.IsInf(seconds, 0) {
+			return 0, fmt.Errorf("invalid delay %q", delay)
+		}
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	return time.ParseDuration(delay)
+}
+
+// SetLog sets the CSV of the requests, as in the log option.
+func (alrc *AccessLogReplayConfig) SetLog(log string) {
+	alrc.Log = null.StringFrom(log)
+	alrc.requests, alrc.offsets, alrc.logErr = parseAccessLog(log)
+}
+
+// GetPreAllocatedVUs is just a helper method that returns the scaled pre-allocated VUs.
+func (alrc AccessLogReplayConfig) GetPreAllocatedVUs(et *lib.ExecutionTuple) int64 {
+	return et.Segment.Scale(alrc.PreAllocatedVUs.Int64)
+}
+
+// GetMaxVUs is just a helper method that returns the scaled max VUs.
+func (alrc AccessLogReplayConfig) GetMaxVUs(et *lib.ExecutionTuple) int64 {
+	return et.Segment.Scale(alrc.MaxVUs.Int64)
+}
+
+// iterations returns how many iterations replay the log in all the loops.
+func (alrc AccessLogReplayConfig) iterations() uint64 {
+	if alrc.Loops.Int64 < 1 {
+		return 0
+	}
+	return uint64(alrc.Loops.Int64) * uint64(len(alrc.requests))
+}
+
+// startOffset returns when the iteration of the test starts, since the start
+// of the scenario. Every loop starts after the last request of the previous one
+// and the delay of the first request.
+func (alrc AccessLogReplayConfig) startOffset(iteration uint64) time.Duration {
+	n := uint64(len(alrc.requests))
+	loop, i := iteration/n, iteration%n
+	offset := time.Duration(loop)*alrc.offsets[n-1] + alrc.offsets[i]
+	if alrc.Speed.Float64 > 0 {
+		offset = time.Duration(float64(offset) / alrc.Speed.Float64)
+	}
+	return offset
+}
+
+// getDuration returns when the last iteration starts.
+func (alrc AccessLogReplayConfig) getDuration() time.Duration {
+	if alrc.iterations() == 0 {
+		return 0
+	}
+	return alrc.startOffset(alrc.iterations() - 1)
+}
+
+// GetDescription returns a human-readable description of the executor options
+func (alrc AccessLogReplayConfig) GetDescription(et *lib.ExecutionTuple) string {
+	maxVUsRange := fmt.Sprintf("maxVUs: %d", et.Segment.Scale(alrc.PreAllocatedVUs.Int64))
+	if alrc.MaxVUs.Int64 > alrc.PreAllocatedVUs.Int64 {
+		maxVUsRange += fmt.Sprintf("-%d", et.Segment.Scale(alrc.MaxVUs.Int64))
+	}
+
+	return fmt.Sprintf("%d recorded requests over %s at %gx speed%s",
+		et.Segment.Scale(int64(alrc.iterations())), alrc.getDuration(), alrc.Speed.Float64,
+		alrc.getBaseInfo(maxVUsRange))
+}
+
+// Validate makes sure all options are configured and valid
+func (alrc *AccessLogReplayConfig) Validate() []error {
+	errors := alrc.BaseConfig.Validate()
+
+	switch {
+	case alrc.logErr != nil:
+		errors = append(errors, alrc.logErr)
+	case len(alrc.requests) == 0:
+		errors = append(errors, fmt.Errorf("the log doesn't have any requests"))
+	}
+
+	if alrc.Speed.Float64 <= 0 {
+		errors = append(errors, fmt.Errorf("the speed should be more than 0"))
+	}
+	if alrc.Loops.Int64 < 1 {
+		errors = append(errors, fmt.Errorf("the log should be replayed at least once"))
+	}
+
+	if !alrc.PreAllocatedVUs.Valid {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs isn't specified"))
+	} else if alrc.PreAllocatedVUs.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs shouldn't be negative"))
+	}
+
+	if !alrc.MaxVUs.Valid {
+		// TODO: don't change the config while validating
+		alrc.MaxVUs.Int64 = alrc.PreAllocatedVUs.Int64
+	} else if alrc.MaxVUs.Int64 < alrc.PreAllocatedVUs.Int64 {
+		errors = append(errors, fmt.Errorf("maxVUs shouldn't be less than preAllocatedVUs"))
+	}
+
+	return errors
+}
+
+// GetExecutionRequirements returns the number of required VUs to run the
+// executor for its whole duration, including the maximum waiting time for any
+// iterations to gracefully stop.
+func (alrc AccessLogReplayConfig) GetExecutionRequirements(et *lib.ExecutionTuple) []lib.ExecutionStep {
+	return []lib.ExecutionStep{
+		{
+			TimeOffset:      0,
+			PlannedVUs:      uint64(et.Segment.Scale(alrc.PreAllocatedVUs.Int64)),
+			MaxUnplannedVUs: uint64(et.Segment.Scale(alrc.MaxVUs.Int64 - alrc.PreAllocatedVUs.Int64)),
+		}, {
+			TimeOffset:      alrc.getDuration() + alrc.GracefulStop.TimeDuration(),
+			PlannedVUs:      0,
+			MaxUnplannedVUs: 0,
+		},
+	}
+}
+
+// NewExecutor creates a new AccessLogReplay executor
+func (alrc AccessLogReplayConfig) NewExecutor(es *lib.ExecutionState, logger *logrus.Entry) (lib.Executor, error) {
+	return &AccessLogReplay{
+		BaseExecutor: NewBaseExecutor(&alrc, es, logger),
+		config:       alrc,
+	}, nil
+}
+
+// HasWork reports whether there is any work to be done for the given execution segment.
+func (alrc AccessLogReplayConfig) HasWork(et *lib.ExecutionTuple) bool {
+	return alrc.GetMaxVUs(et) > 0
+}
+
+// AccessLogReplay starts an iteration at the time of every request of an
+// access log, like the arrival-rate executors do on their schedule.
+type AccessLogReplay struct {
+	*BaseExecutor
+	config AccessLogReplayConfig
+	et     *lib.ExecutionTuple
+}
+
+// Make sure we implement the lib.Executor interface.
+var _ lib.Executor = &AccessLogReplay{}
+
+// Init values needed for the execution
+func (alr *AccessLogReplay) Init(ctx context.Context) error {
+	// err should always be nil, because Init() won't be called for executors
+	// with no work, as determined by their config's HasWork() method.
+	et, err := alr.BaseExecutor.executionState.ExecutionTuple.GetNewExecutionTupleFromValue(alr.config.MaxVUs.Int64)
+	alr.et = et
+	alr.iterSegIndex = lib.NewSegmentedIndex(et)
+
+	return err
+}
+
+// replayIteration is the local and global numbers of an iteration, which are
+// the ones of its request, so they're set when it's started instead of when a
+// VU runs it.
+type replayIteration struct {
+	local, global uint64
+}
+
+// Run starts the iterations of the requests of this instance's segment at the
+// times they were recorded, scaled by the speed. An iteration is dropped if
+// there isn't a free VU for it, as for the arrival-rate executors.
+//nolint:funlen
+func (alr AccessLogReplay) Run(
+	parentCtx context.Context, out chan<- stats.SampleContainer, builtinMetrics *metrics.BuiltinMetrics,
+) (err error) {
+	gracefulStop := alr.config.GetGracefulStop()
+	duration := alr.config.getDuration()
+	total := alr.config.iterations()
+	preAllocatedVUs := alr.config.GetPreAllocatedVUs(alr.executionState.ExecutionTuple)
+	maxVUs := alr.config.GetMaxVUs(alr.executionState.ExecutionTuple)
+
+	alr.logger.WithFields(logrus.Fields{
+		"maxVUs": maxVUs, "preAllocatedVUs": preAllocatedVUs, "duration": duration,
+		"requests": total, "type": alr.config.GetType(),
+	}).Debug("Starting executor run...")
+
+	activeVUsWg := &sync.WaitGroup{}
+	iterationsWg := &sync.WaitGroup{}
+	iterations := make(chan replayIteration)
+	returnedVUs := make(chan struct{})
+	// The regular duration is extended by a millisecond, so the last
+	// request isn't raced by its end.
+	startTime, maxDurationCtx, regDurationCtx, cancel := getDurationContexts(
+		parentCtx, duration+time.Millisecond, gracefulStop)
+	defer func() {
+		<-returnedVUs
+		close(iterations)
+		iterationsWg.Wait()
+		cancel()
+		activeVUsWg.Wait()
+	}()
+
+	var activeVUsCount, runningVUsCount, startedCount uint64
+	vusFmt := pb.GetFixedLengthIntFormat(maxVUs)
+	itersFmt := pb.GetFixedLengthIntFormat(int64(total))
+	progressFn := func() (float64, []string) {
+		spent := time.Since(startTime)
+		progVUs := fmt.Sprintf(vusFmt+"/"+vusFmt+" VUs",
+			atomic.LoadUint64(&runningVUsCount), atomic.LoadUint64(&activeVUsCount))
+		progIters := fmt.Sprintf(itersFmt+"/"+itersFmt+" requests", atomic.LoadUint64(&startedCount), total)
+		right := []string{progVUs, duration.String(), progIters}
+		if spent > duration {
+			return 1, right
+		}
+		right[1] = fmt.Sprintf("%s/%s", pb.GetFixedLengthDuration(spent, duration), duration)
+		if duration == 0 {
+			return 1, right
+		}
+		return math.Min(1, float64(spent)/float64(duration)), right
+	}
+	alr.progress.Modify(pb.WithProgress(progressFn))
+	go trackProgress(parentCtx, maxDurationCtx, regDurationCtx, &alr, progressFn)
+
+	maxDurationCtx = lib.WithScenarioState(maxDurationCtx, &lib.ScenarioState{
+		Name:       alr.config.Name,
+		Executor:   alr.config.Type,
+		StartTime:  startTime,
+		ProgressFn: progressFn,
+		Duration:   duration,
+		Requests:   alr.config.requests,
+	})
+
+	returnVU := func(u lib.InitializedVU) {
+		alr.executionState.ReturnVU(u, true)
+		activeVUsWg.Done()
+	}
+
+	runIteration := getIterationRunner(alr.executionState, alr.logger)
+	activateVU := func(initVU lib.InitializedVU) {
+		var next replayIteration
+		activeVUsWg.Add(1)
+		activeVU := initVU.Activate(getVUActivationParams(
+			maxDurationCtx, alr.config.BaseConfig, returnVU,
+			func() (uint64, uint64) { return next.local, next.global },
+		))
+		alr.executionState.ModCurrentlyActiveVUsCount(+1)
+		atomic.AddUint64(&activeVUsCount, 1)
+
+		iterationsWg.Add(1)
+		started := make(chan struct{})
+		go func() {
+			defer iterationsWg.Done()
+			close(started)
+			for next = range iterations {
+				atomic.AddUint64(&runningVUsCount, 1)
+				runIteration(maxDurationCtx, activeVU)
+				atomic.AddUint64(&runningVUsCount, ^uint64(0))
+			}
+		}()
+		<-started
+	}
+
+	remainingUnplannedVUs := maxVUs - preAllocatedVUs
+	makeUnplannedVUCh := make(chan struct{})
+	defer close(makeUnplannedVUCh)
+	go func() {
+		defer close(returnedVUs)
+		for range makeUnplannedVUCh {
+			alr.logger.Debug("Starting initialization of an unplanned VU...")
+			initVU, err := alr.executionState.GetUnplannedVU(maxDurationCtx, alr.logger)
+			if err != nil {
+				alr.logger.WithError(err).Error("Error while allocating unplanned VU")
+			} else {
+				alr.logger.Debug("The unplanned VU finished initializing successfully!")
+				activateVU(initVU)
+			}
+		}
+	}()
+
+	for i := int64(0); i < preAllocatedVUs; i++ {
+		initVU, err := alr.executionState.GetPlannedVU(alr.logger, false)
+		if err != nil {
+			return err
+		}
+		activateVU(initVU)
+	}
+
+	start, offsets, _ := alr.et.GetStripedOffsets()
+	timer := time.NewTimer(time.Hour * 24)
+	droppedIterationMetric := builtinMetrics.DroppedIterations
+	shownWarning := false
+	metricTags := alr.getMetricTags(nil)
+	for li, gi := 0, start; uint64(gi) < total; li, gi = li+1, gi+offsets[li%len(offsets)] {
+		timer.Reset(alr.config.startOffset(uint64(gi)) - time.Since(startTime))
+		select {
+		case <-timer.C:
+			atomic.AddUint64(&startedCount, 1)
+			select {
+			case iterations <- replayIteration{local: uint64(li), global: uint64(gi)}:
+				continue
+			default:
+			}
+
+			stats.PushIfNotDone(parentCtx, out, stats.Sample{
+				Value: 1, Metric: droppedIterationMetric,
+				Tags: metricTags, Time: time.Now(),
+			})
+			alr.executionState.AddDroppedIterations(1)
+
+			if remainingUnplannedVUs == 0 {
+				if !shownWarning {
+					alr.logger.Warningf("Insufficient VUs, reached %d active VUs and cannot initialize more", maxVUs)
+					shownWarning = true
+				}
+				continue
+			}
+
+			select {
+			case makeUnplannedVUCh <- struct{}{}:
+				remainingUnplannedVUs--
+			default: // we're already allocating a new VU
+			}
+
+		case <-regDurationCtx.Done():
+			return nil
+		}
+	}
+	return nil
+}
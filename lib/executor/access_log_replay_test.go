@@ -0,0 +1,110 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/stats"
+)
+
+const testAccessLog = "method,path,delay\nGET,/,0\npost,/login,200ms\nGET,/home,0.3\n"
+
+func TestAccessLogReplayConfig(t *testing.T) {
+	t.Parallel()
+	config := NewAccessLogReplayConfig("replay")
+	config.SetLog(testAccessLog)
+	config.Speed = null.FloatFrom(2)
+	config.Loops = null.IntFrom(2)
+	config.PreAllocatedVUs = null.IntFrom(5)
+	require.Empty(t, config.Validate())
+
+	assert.Equal(t, []lib.ScenarioRequest{
+		{Method: "GET", Path: "/"}, {Method: "POST", Path: "/login"}, {Method: "GET", Path: "/home"},
+	}, config.requests)
+	var starts []time.Duration
+	for i := uint64(0); i < config.iterations(); i++ {
+		starts = append(starts, config.startOffset(i))
+	}
+	assert.Equal(t, []time.Duration{
+		0, 100 * time.Millisecond, 250 * time.Millisecond,
+		250 * time.Millisecond, 350 * time.Millisecond, 500 * time.Millisecond,
+	}, starts)
+
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "6 recorded requests over 500ms at 2x speed (maxVUs: 5, gracefulStop: 30s)",
+		config.GetDescription(et))
+	endOffset, isFinal := lib.GetEndOffset(config.GetExecutionRequirements(et))
+	assert.Equal(t, 30500*time.Millisecond, endOffset)
+	assert.True(t, isFinal)
+}
+
+func TestAccessLogReplayRun(t *testing.T) {
+	t.Parallel()
+	config := NewAccessLogReplayConfig("replay")
+	config.SetLog(testAccessLog)
+	config.Speed = null.FloatFrom(2)
+	config.Loops = null.IntFrom(2)
+	config.PreAllocatedVUs = null.IntFrom(3)
+	require.Empty(t, config.Validate())
+
+	var (
+		mx      sync.Mutex
+		globals []uint64
+		started []time.Duration
+	)
+	start := time.Now()
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 3, 3)
+	ctx, cancel, executor, logHook := setupExecutor(
+		t, config, es,
+		simpleRunner(func(ctx context.Context, state *lib.State) error {
+			mx.Lock()
+			defer mx.Unlock()
+			globals = append(globals, state.GetScenarioGlobalVUIter())
+			started = append(started, time.Since(start))
+			return nil
+		}),
+	)
+	defer cancel()
+	engineOut := make(chan stats.SampleContainer, 1000)
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	require.NoError(t, executor.Run(ctx, engineOut, builtinMetrics))
+	require.Empty(t, logHook.Drain())
+
+	sort.Slice(globals, func(i, j int) bool { return globals[i] < globals[j] })
+	assert.Equal(t, []uint64{0, 1, 2, 3, 4, 5}, globals)
+	assert.InDelta(t, 500*time.Millisecond, started[len(started)-1], float64(100*time.Millisecond))
+	assert.Equal(t, uint64(6), es.GetFullIterationCount())
+}
@@ -0,0 +1,231 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+const arrivalRateProfileType = "arrival-rate-profile"
+
+func init() {
+	lib.RegisterExecutorConfigType(
+		arrivalRateProfileType,
+		func(name string, rawJSON []byte) (lib.ExecutorConfig, error) {
+			config := NewArrivalRateProfileConfig(name)
+			err := lib.StrictJSONUnmarshal(rawJSON, &config)
+			if err == nil {
+				config.rates, config.profileErr = parseArrivalRateProfile(config.Profile.String)
+			}
+			return config, err
+		},
+	)
+}
+
+// ArrivalRateProfileConfig stores the config for the arrival-rate-profile
+// executor, which replays a time series of target arrival rates, for instance
+// the requests per minute of a day of production traffic, optionally scaled
+// and looped.
+type ArrivalRateProfileConfig struct {
+	BaseConfig
+	// Profile is the CSV of the rates, one per row in its last column, so
+	// exports with a timestamp in the first one can be used as they are. A
+	// header row is skipped. It's usually open()-ed in the init context.
+	Profile null.String `json:"profile"`
+	// TimeUnit is the period of the rates, a minute by default.
+	TimeUnit types.NullDuration `json:"timeUnit"`
+	// Interval is how long each rate of the profile lasts, a minute by
+	// default. The rate ramps linearly to the next one over the interval.
+	Interval types.NullDuration `json:"interval"`
+	Scale    null.Float         `json:"scale"`
+	Loops    null.Int           `json:"loops"`
+
+	PreAllocatedVUs null.Int `json:"preAllocatedVUs"`
+	MaxVUs          null.Int `json:"maxVUs"`
+
+	rates      []float64
+	profileErr error
+}
+
+// NewArrivalRateProfileConfig returns an ArrivalRateProfileConfig with default values
+func NewArrivalRateProfileConfig(name string) *ArrivalRateProfileConfig {
+	return &ArrivalRateProfileConfig{
+		BaseConfig: NewBaseConfig(name, arrivalRateProfileType),
+		TimeUnit:   types.NewNullDuration(1*time.Minute, false),
+		Interval:   types.NewNullDuration(1*time.Minute, false),
+		Scale:      null.NewFloat(1, false),
+		Loops:      null.NewInt(1, false),
+	}
+}
+
+// Make sure we implement the lib.ExecutorConfig interface
+var _ lib.ExecutorConfig = &ArrivalRateProfileConfig{}
+
+// parseArrivalRateProfile returns the rates of the CSV profile.
+func parseArrivalRateProfile(profile string) ([]float64, error) {
+	reader := csv.NewReader(strings.NewReader(profile))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	var rates []float64
+	for row := 1; ; row++ {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return rates, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("the profile isn't a valid CSV: %w", err)
+		}
+		value := strings.TrimSpace(record[len(record)-1])
+		rate, err := strconv.ParseFloat(value, 64)
+		switch {
+		case err != nil && row == 1:
+			continue // the header
+		case err != nil || rate < 0 || math.IsInf(rate, 0) || math.IsNaN(rate):
+			return nil, fmt.Errorf("the row %d of the profile has the invalid rate %q", row, value)
+		}
+		rates = append(rates, rate)
+	}
+}
+
+// SetProfile sets the CSV of the rates, as in the profile option.
+func (arpc *ArrivalRateProfileConfig) SetProfile(profile string) {
+	arpc.Profile = null.StringFrom(profile)
+	arpc.rates, arpc.profileErr = parseArrivalRateProfile(profile)
+}
+
+// scaledRate returns the i-th rate of the profile, scaled and rounded.
+func (arpc ArrivalRateProfileConfig) scaledRate(i int) null.Int {
+	return null.IntFrom(int64(math.Round(arpc.rates[i] * arpc.Scale.Float64)))
+}
+
+// rampingConfig returns the config of the ramping-arrival-rate executor which
+// the profile is replayed with. The rate starts at the first one of the
+// profile and ramps to the next one over each interval, and the last one is
+// kept over its interval before the next loop or the end.
+func (arpc ArrivalRateProfileConfig) rampingConfig() RampingArrivalRateConfig {
+	config := RampingArrivalRateConfig{
+		BaseConfig:      arpc.BaseConfig,
+		TimeUnit:        arpc.TimeUnit,
+		PreAllocatedVUs: arpc.PreAllocatedVUs,
+		MaxVUs:          arpc.MaxVUs,
+	}
+	if len(arpc.rates) == 0 {
+		return config
+	}
+
+	interval := types.NullDurationFrom(arpc.Interval.TimeDuration())
+	config.StartRate = arpc.scaledRate(0)
+	for loop := int64(0); loop < arpc.Loops.Int64; loop++ {
+		for i := range arpc.rates {
+			if loop > 0 || i > 0 {
+				config.Stages = append(config.Stages, Stage{Duration: interval, Target: arpc.scaledRate(i)})
+			}
+		}
+	}
+	config.Stages = append(config.Stages, Stage{Duration: interval, Target: arpc.scaledRate(len(arpc.rates) - 1)})
+	return config
+}
+
+// GetDescription returns a human-readable description of the executor options
+func (arpc ArrivalRateProfileConfig) GetDescription(et *lib.ExecutionTuple) string {
+	config := arpc.rampingConfig()
+	maxVUsRange := fmt.Sprintf("maxVUs: %d", et.Segment.Scale(arpc.PreAllocatedVUs.Int64))
+	if arpc.MaxVUs.Int64 > arpc.PreAllocatedVUs.Int64 {
+		maxVUsRange += fmt.Sprintf("-%d", et.Segment.Scale(arpc.MaxVUs.Int64))
+	}
+	maxUnscaledRate := getStagesUnscaledMaxTarget(config.StartRate.Int64, config.Stages)
+	maxArrRatePerSec, _ := getArrivalRatePerSec(
+		getScaledArrivalRate(et.Segment, maxUnscaledRate, arpc.TimeUnit.TimeDuration()),
+	).Float64()
+
+	return fmt.Sprintf("Up to %.2f iterations/s for %s following a profile of %d rates%s",
+		maxArrRatePerSec, sumStagesDuration(config.Stages), len(arpc.rates), arpc.getBaseInfo(maxVUsRange))
+}
+
+// Validate makes sure all options are configured and valid
+func (arpc *ArrivalRateProfileConfig) Validate() []error {
+	errors := arpc.BaseConfig.Validate()
+
+	switch {
+	case arpc.profileErr != nil:
+		errors = append(errors, arpc.profileErr)
+	case len(arpc.rates) == 0:
+		errors = append(errors, fmt.Errorf("the profile doesn't have any rates"))
+	}
+
+	if arpc.TimeUnit.TimeDuration() <= 0 {
+		errors = append(errors, fmt.Errorf("the timeUnit should be more than 0"))
+	}
+	if arpc.Interval.TimeDuration() <= 0 {
+		errors = append(errors, fmt.Errorf("the interval should be more than 0"))
+	}
+	if arpc.Scale.Float64 < 0 {
+		errors = append(errors, fmt.Errorf("the scale shouldn't be negative"))
+	}
+	if arpc.Loops.Int64 < 1 {
+		errors = append(errors, fmt.Errorf("the profile should be replayed at least once"))
+	}
+
+	if !arpc.PreAllocatedVUs.Valid {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs isn't specified"))
+	} else if arpc.PreAllocatedVUs.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the number of preAllocatedVUs shouldn't be negative"))
+	}
+
+	if !arpc.MaxVUs.Valid {
+		// TODO: don't change the config while validating
+		arpc.MaxVUs.Int64 = arpc.PreAllocatedVUs.Int64
+	} else if arpc.MaxVUs.Int64 < arpc.PreAllocatedVUs.Int64 {
+		errors = append(errors, fmt.Errorf("maxVUs shouldn't be less than preAllocatedVUs"))
+	}
+
+	return errors
+}
+
+// GetExecutionRequirements returns the execution requirements of the
+// ramping-arrival-rate executor which replays the profile.
+func (arpc ArrivalRateProfileConfig) GetExecutionRequirements(et *lib.ExecutionTuple) []lib.ExecutionStep {
+	return arpc.rampingConfig().GetExecutionRequirements(et)
+}
+
+// NewExecutor creates a new RampingArrivalRate executor which replays the
+// profile.
+func (arpc ArrivalRateProfileConfig) NewExecutor(es *lib.ExecutionState, logger *logrus.Entry) (lib.Executor, error) {
+	return arpc.rampingConfig().NewExecutor(es, logger)
+}
+
+// HasWork reports whether there is any work to be done for the given execution segment.
+func (arpc ArrivalRateProfileConfig) HasWork(et *lib.ExecutionTuple) bool {
+	return et.Segment.Scale(arpc.MaxVUs.Int64) > 0
+}
@@ -0,0 +1,91 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package executor
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+func TestArrivalRateProfileRampingConfig(t *testing.T) {
+	t.Parallel()
+	config := NewArrivalRateProfileConfig("profile")
+	config.SetProfile("2021-06-01T00:00:00Z,10\n2021-06-01T00:01:00Z,20.4\n2021-06-01T00:02:00Z,5\n")
+	config.Scale = null.FloatFrom(1.5)
+	config.Loops = null.IntFrom(2)
+	config.PreAllocatedVUs = null.IntFrom(5)
+
+	interval := types.NullDurationFrom(time.Minute)
+	ramping := config.rampingConfig()
+	assert.Equal(t, null.IntFrom(15), ramping.StartRate)
+	assert.Equal(t, []Stage{
+		{Duration: interval, Target: null.IntFrom(31)},
+		{Duration: interval, Target: null.IntFrom(8)},
+		{Duration: interval, Target: null.IntFrom(15)},
+		{Duration: interval, Target: null.IntFrom(31)},
+		{Duration: interval, Target: null.IntFrom(8)},
+		{Duration: interval, Target: null.IntFrom(8)},
+	}, ramping.Stages)
+	assert.Equal(t, arrivalRateProfileType, ramping.GetType())
+	assert.Empty(t, config.Validate())
+}
+
+func TestArrivalRateProfileRun(t *testing.T) {
+	t.Parallel()
+	config := NewArrivalRateProfileConfig("profile")
+	config.SetProfile("rps\n10\n50\n50")
+	config.TimeUnit = types.NullDurationFrom(time.Second)
+	config.Interval = types.NullDurationFrom(time.Second)
+	config.PreAllocatedVUs = null.IntFrom(10)
+	config.MaxVUs = null.IntFrom(20)
+	require.Empty(t, config.Validate())
+
+	var count int64
+	et, err := lib.NewExecutionTuple(nil, nil)
+	require.NoError(t, err)
+	es := lib.NewExecutionState(lib.Options{}, et, 10, 20)
+	ctx, cancel, executor, logHook := setupExecutor(
+		t, config, es,
+		simpleRunner(func(ctx context.Context, _ *lib.State) error {
+			atomic.AddInt64(&count, 1)
+			return nil
+		}),
+	)
+	defer cancel()
+	engineOut := make(chan stats.SampleContainer, 1000)
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	require.NoError(t, executor.Run(ctx, engineOut, builtinMetrics))
+	require.Empty(t, logHook.Drain())
+	// 30 iterations ramping from 10 to 50/s, then 50 and 50 more
+	assert.InDelta(t, 130, atomic.LoadInt64(&count), 5)
+}
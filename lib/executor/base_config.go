@@ -21,13 +21,16 @@
 package executor
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/url"
 	"regexp"
 	"strings"
 	"time"
 
 	"gopkg.in/guregu/null.v3"
 
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/consts"
 	"go.k6.io/k6/lib/types"
 )
@@ -40,16 +43,113 @@ var DefaultGracefulStopValue = 30 * time.Second //nolint:gochecknoglobals
 var executorNameWhitelist = regexp.MustCompile(`^[0-9a-zA-Z_-]+$`) //nolint:gochecknoglobals
 const executorNameErr = "the executor name should contain only numbers, latin letters, underscores, and dashes"
 
+// startTimeAfterRegex matches a startTime relative to the end of another
+// scenario, like "seed.end + 30s".
+var startTimeAfterRegex = regexp.MustCompile( //nolint:gochecknoglobals
+	`^\s*([0-9a-zA-Z_-]+)\.end\s*(?:([+-])\s*(\S+))?\s*$`)
+
+// StartTime is the startTime of a scenario, a duration from the start of the
+// test, or an offset from the end of another scenario, like "seed.end + 30s",
+// which is resolved to the duration by DeriveScenariosFromShortcuts().
+//
+// The end of the other scenario is its planned end, when it has run for its
+// longest possible duration and its gracefulStop, the same as the one the
+// execution plan has. It isn't when the scenario actually finishes, so a
+// scenario like shared-iterations, which can finish its iterations before its
+// maxDuration, is followed by a pause until its planned end.
+type StartTime struct {
+	types.NullDuration
+	// After is the name of the scenario the start time is relative to the
+	// end of, and Offset is the offset from it.
+	After  string
+	Offset types.Duration
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (st *StartTime) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		if m := startTimeAfterRegex.FindStringSubmatch(str); m != nil {
+			var offset types.Duration
+			if m[3] != "" {
+				if err := offset.UnmarshalText([]byte(m[3])); err != nil {
+					return fmt.Errorf("invalid startTime %q: %w", str, err)
+				}
+			}
+			if m[2] == "-" {
+				offset = -offset
+			}
+			*st = StartTime{After: m[1], Offset: offset}
+			return nil
+		}
+	}
+	st.After, st.Offset = "", 0
+	return st.NullDuration.UnmarshalJSON(data)
+}
+
+// MarshalJSON implements the json.Marshaler interface, a start time relative
+// to the end of another scenario is kept as an expression.
+func (st StartTime) MarshalJSON() ([]byte, error) {
+	if st.After == "" {
+		return st.NullDuration.MarshalJSON()
+	}
+	return json.Marshal(st.expression())
+}
+
+// expression returns the start time relative to the end of another scenario
+// like it's specified.
+func (st StartTime) expression() string {
+	switch {
+	case st.Offset > 0:
+		return fmt.Sprintf("%s.end + %s", st.After, st.Offset)
+	case st.Offset < 0:
+		return fmt.Sprintf("%s.end - %s", st.After, -st.Offset)
+	default:
+		return st.After + ".end"
+	}
+}
+
 // BaseConfig contains the common config fields for all executors
 type BaseConfig struct {
 	Name         string             `json:"-"` // set via the JS object key
 	Type         string             `json:"executor"`
-	StartTime    types.NullDuration `json:"startTime"`
+	StartTime    StartTime          `json:"startTime"`
 	GracefulStop types.NullDuration `json:"gracefulStop"`
 	Env          map[string]string  `json:"env"`
 	Exec         null.String        `json:"exec"` // function name, externally validated
+	Args         json.RawMessage    `json:"args"`
 	Tags         map[string]string  `json:"tags"`
 
+	AbortOnErrorRate *types.ErrorRateLimit `json:"abortOnErrorRate"`
+
+	// StopOnFailures is how many failed checks or requests the scenario can
+	// have before it's stopped, so it doesn't keep hammering a broken target.
+	StopOnFailures *types.FailureLimit `json:"stopOnFailures"`
+
+	// CorrectCoordinatedOmission is the intended interval between the starts
+	// of the iterations of a VU, used to back-fill the http_req_duration and
+	// iteration_duration metrics with the samples of the iterations a slow
+	// iteration has delayed.
+	CorrectCoordinatedOmission types.NullDuration `json:"correctCoordinatedOmission"`
+
+	// PrewarmConnections is how many connections every VU opens to the host
+	// of each of the PrewarmURLs when it's first activated for the scenario,
+	// so the measured requests use them instead of waiting for the TCP and
+	// TLS handshakes. No request is sent to the URLs. The requests through a
+	// proxy don't use them, and with HTTP/2 only one of them is used.
+	PrewarmConnections null.Int `json:"prewarmConnections"`
+	PrewarmURLs        []string `json:"prewarmURLs"`
+
+	// Socket overrides the socket options of the test for the connections
+	// the VUs make in the scenario. The idle connections a VU kept from its
+	// previous scenario are closed when it's activated with other options.
+	Socket lib.SocketOptions `json:"socket"`
+
+	// Interface overrides the network interface of the test the VUs make
+	// their connections from in the scenario. It can't be set along with the
+	// local IPs of the test.
+	Interface null.String `json:"interface"`
+
 	// TODO: future extensions like distribution, others?
 }
 
@@ -75,6 +175,12 @@ func (bc BaseConfig) Validate() (errors []error) {
 	if bc.Exec.Valid && bc.Exec.String == "" {
 		errors = append(errors, fmt.Errorf("exec value cannot be empty"))
 	}
+	if len(bc.Args) > 0 {
+		var args map[string]interface{}
+		if err := json.Unmarshal(bc.Args, &args); err != nil {
+			errors = append(errors, fmt.Errorf("args should be an object"))
+		}
+	}
 	if bc.Type == "" {
 		errors = append(errors, fmt.Errorf("missing or empty type field"))
 	}
@@ -85,6 +191,40 @@ func (bc BaseConfig) Validate() (errors []error) {
 	if bc.GracefulStop.Duration < 0 {
 		errors = append(errors, fmt.Errorf("the gracefulStop timeout can't be negative"))
 	}
+	if bc.CorrectCoordinatedOmission.Valid {
+		if bc.CorrectCoordinatedOmission.Duration <= 0 {
+			errors = append(errors, fmt.Errorf("the correctCoordinatedOmission interval should be positive"))
+		}
+		if bc.Type == constantArrivalRateType || bc.Type == rampingArrivalRateType ||
+			bc.Type == arrivalRateProfileType || bc.Type == accessLogReplayType {
+			errors = append(errors, fmt.Errorf(
+				"the correctCoordinatedOmission option is only supported by the closed-model executors, "+
+					"the %s executor starts its iterations on schedule", bc.Type))
+		}
+	}
+	if bc.StopOnFailures != nil {
+		errors = append(errors, bc.StopOnFailures.Validate()...)
+	}
+	errors = append(errors, bc.validatePrewarm()...)
+	errors = append(errors, bc.Socket.Validate()...)
+	return errors
+}
+
+func (bc BaseConfig) validatePrewarm() (errors []error) {
+	if bc.PrewarmConnections.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the prewarmConnections can't be negative"))
+	}
+	if bc.PrewarmConnections.Int64 > 0 && len(bc.PrewarmURLs) == 0 {
+		errors = append(errors, fmt.Errorf("the prewarmURLs of the hosts to open the prewarmConnections to are missing"))
+	}
+	if bc.PrewarmConnections.Int64 == 0 && len(bc.PrewarmURLs) > 0 {
+		errors = append(errors, fmt.Errorf("the prewarmURLs are only used with prewarmConnections"))
+	}
+	for _, u := range bc.PrewarmURLs {
+		if parsed, err := url.Parse(u); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") {
+			errors = append(errors, fmt.Errorf("the prewarm URL %q should be an http or https URL", u))
+		}
+	}
 	return errors
 }
 
@@ -129,11 +269,52 @@ func (bc BaseConfig) GetExec() string {
 	return exec
 }
 
+// GetArgs returns the JSON of the args object configured for the executor, if
+// any, which is passed to the exec function after the setup data.
+func (bc BaseConfig) GetArgs() json.RawMessage {
+	return bc.Args
+}
+
 // GetTags returns any custom tags configured for the executor.
 func (bc BaseConfig) GetTags() map[string]string {
 	return bc.Tags
 }
 
+// GetAbortOnErrorRate returns the limit of the rate of failed requests after
+// which the scenario is stopped, if any.
+func (bc BaseConfig) GetAbortOnErrorRate() *types.ErrorRateLimit {
+	return bc.AbortOnErrorRate
+}
+
+// GetStopOnFailures returns how many failed checks or requests the scenario
+// is stopped after, if it has a limit.
+func (bc BaseConfig) GetStopOnFailures() *types.FailureLimit {
+	return bc.StopOnFailures
+}
+
+// GetCoordinatedOmissionInterval returns the intended interval between the
+// iterations of a VU, which the http_req_duration and iteration_duration
+// metrics are corrected for, or 0 if they aren't corrected. The other Trend
+// metrics are never corrected.
+func (bc BaseConfig) GetCoordinatedOmissionInterval() time.Duration {
+	if !bc.CorrectCoordinatedOmission.Valid {
+		return 0
+	}
+	return bc.CorrectCoordinatedOmission.TimeDuration()
+}
+
+// GetPrewarmConnections returns how many connections every VU opens to the
+// host of each of the returned URLs before its first iteration, if any.
+func (bc BaseConfig) GetPrewarmConnections() (int, []string) {
+	return int(bc.PrewarmConnections.Int64), bc.PrewarmURLs
+}
+
+// GetInterface returns the name of the network interface the VUs make their
+// connections from in the scenario, or an empty string if it isn't set.
+func (bc BaseConfig) GetInterface() string {
+	return bc.Interface.String
+}
+
 // IsDistributable returns true since by default all executors could be run in
 // a distributed manner.
 func (bc BaseConfig) IsDistributable() bool {
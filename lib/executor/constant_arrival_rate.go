@@ -276,6 +276,7 @@ func (car ConstantArrivalRate) Run(
 		Executor:   car.config.Type,
 		StartTime:  startTime,
 		ProgressFn: progressFn,
+		Duration:   duration,
 	})
 
 	returnVU := func(u lib.InitializedVU) {
@@ -351,6 +352,7 @@ func (car ConstantArrivalRate) Run(
 				Value: 1, Metric: droppedIterationMetric,
 				Tags: metricTags, Time: time.Now(),
 			})
+			car.executionState.AddDroppedIterations(1)
 
 			// We'll try to start allocating another VU in the background,
 			// non-blockingly, if we have remainingUnplannedVUs...
@@ -349,6 +349,7 @@ func TestConstantArrivalRateDroppedIterations(t *testing.T) {
 	assert.Contains(t, logs[0].Message, "cannot initialize more")
 	assert.Equal(t, int64(5), count)
 	assert.Equal(t, float64(5), sumMetricValues(engineOut, metrics.DroppedIterationsName))
+	assert.Equal(t, uint64(5), es.GetDroppedIterationCount())
 }
 
 func TestConstantArrivalRateGlobalIters(t *testing.T) {
@@ -184,6 +184,7 @@ func (clv ConstantVUs) Run(
 		Executor:   clv.config.Type,
 		StartTime:  startTime,
 		ProgressFn: progressFn,
+		Duration:   duration,
 	})
 
 	returnVU := func(u lib.InitializedVU) {
@@ -21,6 +21,11 @@
 package executor
 
 import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
 	"github.com/sirupsen/logrus"
 	"gopkg.in/guregu/null.v3"
 
@@ -68,8 +73,152 @@ func getSharedIterationsScenario(iters null.Int, duration types.NullDuration, vu
 
 // DeriveScenariosFromShortcuts checks for conflicting options and turns any
 // shortcut options (i.e. duration, iterations, stages) into the proper
-// long-form scenario/executor configuration in the scenarios property.
+// long-form scenario/executor configuration in the scenarios property. The
+// scenarios inherit the options of the scenarioDefaults they don't have, and
+// their start times relative to the end of other scenarios are resolved.
 func DeriveScenariosFromShortcuts(opts lib.Options, logger logrus.FieldLogger) (lib.Options, error) {
+	result, err := deriveScenariosFromShortcuts(opts, logger)
+	if err != nil {
+		return result, err
+	}
+
+	if result.ScenarioDefaults != nil {
+		scenarios := make(lib.ScenarioConfigs, len(result.Scenarios))
+		for name, config := range result.Scenarios {
+			scenarios[name] = withScenarioDefaults(config, *result.ScenarioDefaults)
+		}
+		result.Scenarios = scenarios
+	}
+
+	result.Scenarios, err = resolveStartTimes(result.Scenarios)
+	return result, err
+}
+
+// resolveStartTimes returns the scenarios with their start times relative to
+// the end of other scenarios resolved. The end of a scenario is its planned
+// one, its start time plus the end of its execution requirements, with its
+// gracefulStop, since the start times are fixed before the test starts.
+func resolveStartTimes(scenarios lib.ScenarioConfigs) (lib.ScenarioConfigs, error) {
+	et, err := lib.NewExecutionTuple(nil, nil)
+	if err != nil {
+		return scenarios, err
+	}
+
+	resolved := make(lib.ScenarioConfigs, len(scenarios))
+	var resolve func(name string, chain []string) error
+	resolve = func(name string, chain []string) error {
+		if _, ok := resolved[name]; ok {
+			return nil
+		}
+		config := scenarios[name]
+		startTime, ok := scenarioStartTime(config)
+		if !ok || startTime.After == "" {
+			resolved[name] = config
+			return nil
+		}
+
+		chain = append(chain, name)
+		for _, previous := range chain[:len(chain)-1] {
+			if previous == name {
+				return fmt.Errorf("the startTimes of the scenarios %s depend on each other", strings.Join(chain, " -> "))
+			}
+		}
+		if _, ok = scenarios[startTime.After]; !ok {
+			return fmt.Errorf("the startTime of the scenario %s is relative to the end of the scenario %s, "+
+				"which doesn't exist", name, startTime.After)
+		}
+		if err := resolve(startTime.After, chain); err != nil {
+			return err
+		}
+
+		after := resolved[startTime.After]
+		end, _ := lib.GetEndOffset(after.GetExecutionRequirements(et))
+		start := after.GetStartTime() + end + time.Duration(startTime.Offset)
+		if start < 0 {
+			return fmt.Errorf("the startTime %s of the scenario %s is negative", startTime.expression(), name)
+		}
+		startTime.NullDuration = types.NullDurationFrom(start)
+		resolved[name] = updateConfig(config, func(v reflect.Value) {
+			v.FieldByName("StartTime").Set(reflect.ValueOf(startTime))
+		})
+		return nil
+	}
+
+	for name := range scenarios {
+		if err := resolve(name, nil); err != nil {
+			return scenarios, err
+		}
+	}
+	return resolved, nil
+}
+
+// scenarioStartTime returns the StartTime of the config, if it has one like
+// the configs with a BaseConfig.
+func scenarioStartTime(config lib.ExecutorConfig) (StartTime, bool) {
+	v := reflect.Indirect(reflect.ValueOf(config))
+	if v.Kind() != reflect.Struct {
+		return StartTime{}, false
+	}
+	field := v.FieldByName("StartTime")
+	if !field.IsValid() {
+		return StartTime{}, false
+	}
+	startTime, ok := field.Interface().(StartTime)
+	return startTime, ok
+}
+
+// updateConfig returns a copy of the config updated by update, which is
+// called with the struct of the config. The configs are structs or pointers
+// to them, and they're copied so the ones of the options aren't changed.
+func updateConfig(config lib.ExecutorConfig, update func(reflect.Value)) lib.ExecutorConfig {
+	configVal := reflect.Indirect(reflect.ValueOf(config))
+	if configVal.Kind() != reflect.Struct {
+		return config
+	}
+	ptr := reflect.New(configVal.Type())
+	ptr.Elem().Set(configVal)
+	update(ptr.Elem())
+	if reflect.TypeOf(config).Kind() == reflect.Ptr {
+		return ptr.Interface().(lib.ExecutorConfig) //nolint:forcetypeassert
+	}
+	return ptr.Elem().Interface().(lib.ExecutorConfig) //nolint:forcetypeassert
+}
+
+// withScenarioDefaults returns the config with the fields of the defaults it
+// didn't set, matched by their names, and with the env and the tags of the
+// defaults it doesn't override. The externally-controlled executor doesn't
+// support a gracefulStop, so it doesn't inherit it.
+func withScenarioDefaults(config lib.ExecutorConfig, defaults lib.ScenarioDefaults) lib.ExecutorConfig {
+	return updateConfig(config, func(result reflect.Value) {
+		defaultsVal := reflect.ValueOf(defaults)
+		for i := 0; i < defaultsVal.NumField(); i++ {
+			name := defaultsVal.Type().Field(i).Name
+			value := defaultsVal.Field(i)
+			field := result.FieldByName(name)
+			if !field.IsValid() || field.Type() != value.Type() ||
+				(name == "GracefulStop" && config.GetType() == externallyControlledType) {
+				continue
+			}
+			if value.Kind() == reflect.Map {
+				if value.Len() == 0 {
+					continue
+				}
+				merged := reflect.MakeMap(value.Type())
+				for _, key := range value.MapKeys() {
+					merged.SetMapIndex(key, value.MapIndex(key))
+				}
+				for _, key := range field.MapKeys() {
+					merged.SetMapIndex(key, field.MapIndex(key))
+				}
+				field.Set(merged)
+			} else if value.FieldByName("Valid").Bool() && !field.FieldByName("Valid").Bool() {
+				field.Set(value)
+			}
+		}
+	})
+}
+
+func deriveScenariosFromShortcuts(opts lib.Options, logger logrus.FieldLogger) (lib.Options, error) {
 	result := opts
 
 	switch {
@@ -31,6 +31,7 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/lib/types"
 )
 
@@ -73,7 +74,7 @@ var configMapTestCases = []configMapTestCase{
 			sched.VUs = null.IntFrom(10)
 			sched.Duration = types.NullDurationFrom(1 * time.Minute)
 			sched.GracefulStop = types.NullDurationFrom(10 * time.Second)
-			sched.StartTime = types.NullDurationFrom(70 * time.Second)
+			sched.StartTime.NullDuration = types.NullDurationFrom(70 * time.Second)
 			sched.Exec = null.StringFrom("someFunc")
 			sched.Env = map[string]string{"test": "mest"}
 			require.Equal(t, cm, lib.ScenarioConfigs{"someKey": sched})
@@ -118,7 +119,67 @@ var configMapTestCases = []configMapTestCase{
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "0s"}}`, exp{validationError: true}},
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "startTime": "-10s"}}`, exp{validationError: true}},
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "exec": ""}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "args": [1, 2]}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "args": {"users": [1, 2]}}}`, exp{
+		custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm.Validate())
+			assert.JSONEq(t, `{"users": [1, 2]}`, string(cm["aname"].GetArgs()))
+		},
+	}},
 	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "gracefulStop": "-2s"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "abortOnErrorRate": {"window": "30s"}}}`, exp{parseError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "abortOnErrorRate": {"threshold": "5%", "window": "30s"}}}`, exp{
+		custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm.Validate())
+			assert.Equal(t, "5% errors in 30s", cm["aname"].GetAbortOnErrorRate().String())
+		},
+	}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "stopOnFailures": {"checks": 100, "requests": 500}}}`, exp{
+		custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm.Validate())
+			assert.Equal(t, "100 failed checks or 500 failed requests", cm["aname"].GetStopOnFailures().String())
+		},
+	}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "stopOnFailures": {}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "stopOnFailures": {"requests": 0}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "correctCoordinatedOmission": "500ms"}}`, exp{
+		custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm.Validate())
+			assert.Equal(t, 500*time.Millisecond, cm["aname"].GetCoordinatedOmissionInterval())
+		},
+	}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "correctCoordinatedOmission": "0s"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-arrival-rate", "rate": 10, "duration": "10s", "preAllocatedVUs": 10, "correctCoordinatedOmission": "1s"}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "prewarmConnections": 4, "prewarmURLs": ["https://test.k6.io"]}}`, exp{
+		custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm.Validate())
+			n, urls := cm["aname"].(ConstantVUsConfig).GetPrewarmConnections()
+			assert.Equal(t, 4, n)
+			assert.Equal(t, []string{"https://test.k6.io"}, urls)
+		},
+	}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "prewarmConnections": 4}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "socket": {"noDelay": false, "sendBuffer": 8192}}}`, exp{
+		custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm.Validate())
+			socket := cm["aname"].(ConstantVUsConfig).Socket
+			assert.Equal(t, null.BoolFrom(false), socket.NoDelay)
+			assert.Equal(t, null.IntFrom(8192), socket.SendBuffer)
+		},
+	}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "socket": {"sendBuffer": -1}}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "interface": "eth1"}}`, exp{
+		custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			assert.Empty(t, cm.Validate())
+			assert.Equal(t, "eth1", cm["aname"].(ConstantVUsConfig).GetInterface())
+			pool, err := types.NewIPPool("10.0.0.1")
+			require.NoError(t, err)
+			assert.Len(t, lib.Options{Scenarios: cm, LocalIPs: types.NullIPPool{Pool: pool, Valid: true}}.Validate(), 1)
+		},
+	}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "prewarmURLs": ["https://test.k6.io"]}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "prewarmConnections": -1, "prewarmURLs": ["https://test.k6.io"]}}`, exp{validationError: true}},
+	{`{"aname": {"executor": "constant-vus", "vus": 10, "duration": "10s", "prewarmConnections": 2, "prewarmURLs": ["test.k6.io"]}}`, exp{validationError: true}},
 	// ramping-vus
 	{
 		`{"varloops": {"executor": "ramping-vus", "startVUs": 20, "gracefulStop": "15s", "gracefulRampDown": "10s",
@@ -128,7 +189,7 @@ var configMapTestCases = []configMapTestCase{
 			sched.GracefulStop = types.NullDurationFrom(15 * time.Second)
 			sched.GracefulRampDown = types.NullDurationFrom(10 * time.Second)
 			sched.StartVUs = null.IntFrom(20)
-			sched.StartTime = types.NullDurationFrom(23 * time.Second)
+			sched.StartTime.NullDuration = types.NullDurationFrom(23 * time.Second)
 			sched.Stages = []Stage{
 				{Target: null.IntFrom(30), Duration: types.NullDurationFrom(60 * time.Second)},
 				{Target: null.IntFrom(10), Duration: types.NullDurationFrom(130 * time.Second)},
@@ -420,6 +481,48 @@ var configMapTestCases = []configMapTestCase{
 	{`{"varrival": {"executor": "ramping-arrival-rate", "preAllocatedVUs": 20, "maxVUs": 50, "stages": []}}`, exp{validationError: true}},
 	{`{"varrival": {"executor": "ramping-arrival-rate", "preAllocatedVUs": 20, "maxVUs": 50, "stages": [{"duration": "5m", "target": 10}], "timeUnit": "-1s"}}`, exp{validationError: true}},
 	{`{"varrival": {"executor": "ramping-arrival-rate", "preAllocatedVUs": 30, "maxVUs": 20, "stages": [{"duration": "5m", "target": 10}]}}`, exp{validationError: true}},
+	// arrival-rate-profile
+	{
+		`{"profile": {"executor": "arrival-rate-profile", "profile": "time,rpm\n00:00,60\n00:01,120\n00:02,30",
+		"scale": 2, "loops": 2, "preAllocatedVUs": 10}}`,
+		exp{custom: func(t *testing.T, cm lib.ScenarioConfigs) {
+			sched := NewArrivalRateProfileConfig("profile")
+			sched.SetProfile("time,rpm\n00:00,60\n00:01,120\n00:02,30")
+			sched.Scale = null.FloatFrom(2)
+			sched.Loops = null.IntFrom(2)
+			sched.PreAllocatedVUs = null.IntFrom(10)
+			sched.MaxVUs = null.NewInt(10, false)
+			require.Equal(t, lib.ScenarioConfigs{"profile": sched}, cm)
+
+			et, err := lib.NewExecutionTuple(nil, nil)
+			require.NoError(t, err)
+			assert.Equal(t, "Up to 4.00 iterations/s for 6m0s following a profile of 3 rates (maxVUs: 10, gracefulStop: 30s)",
+				cm["profile"].GetDescription(et))
+
+			schedReqs := cm["profile"].GetExecutionRequirements(et)
+			endOffset, isFinal := lib.GetEndOffset(schedReqs)
+			assert.Equal(t, 390*time.Second, endOffset)
+			assert.Equal(t, true, isFinal)
+			assert.Equal(t, uint64(10), lib.GetMaxPossibleVUs(schedReqs))
+		}},
+	},
+	{`{"profile": {"executor": "arrival-rate-profile", "profile": "10\n20", "interval": "10s", "preAllocatedVUs": 5}}`, exp{}},
+	{`{"profile": {"executor": "arrival-rate-profile", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"profile": {"executor": "arrival-rate-profile", "profile": "rpm\n10\n-20", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"profile": {"executor": "arrival-rate-profile", "profile": "10\nten", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"profile": {"executor": "arrival-rate-profile", "profile": "10", "loops": 0, "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"profile": {"executor": "arrival-rate-profile", "profile": "10", "interval": "0s", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"profile": {"executor": "arrival-rate-profile", "profile": "10", "scale": -1, "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"profile": {"executor": "arrival-rate-profile", "profile": "10"}}`, exp{validationError: true}},
+	// access-log-replay
+	{`{"replay": {"executor": "access-log-replay", "log": "GET,/,0\nGET,/a,1.5", "speed": 3, "preAllocatedVUs": 5}}`, exp{}},
+	{`{"replay": {"executor": "access-log-replay", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"replay": {"executor": "access-log-replay", "log": "GET,/,0\nGET,/a", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"replay": {"executor": "access-log-replay", "log": "GET,/,0\nGET,/a,-1s", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"replay": {"executor": "access-log-replay", "log": "GET,/,0\n,/a,1", "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"replay": {"executor": "access-log-replay", "log": "GET,/,0", "speed": 0, "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"replay": {"executor": "access-log-replay", "log": "GET,/,0", "loops": 0, "preAllocatedVUs": 5}}`, exp{validationError: true}},
+	{`{"replay": {"executor": "access-log-replay", "log": "GET,/,0"}}`, exp{validationError: true}},
 	// TODO: more tests of mixed executors and execution plans
 }
 
@@ -450,3 +553,112 @@ func TestConfigMapParsingAndValidation(t *testing.T) {
 		})
 	}
 }
+
+func TestScenarioDefaults(t *testing.T) {
+	t.Parallel()
+	var opts lib.Options
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"scenarioDefaults": {
+			"exec": "browse", "gracefulStop": "5s", "maxDuration": "1m",
+			"env": {"BASE_URL": "https://test.k6.io", "DEBUG": "false"}, "tags": {"team": "a"}
+		},
+		"scenarios": {
+			"browse": {"executor": "per-vu-iterations", "env": {"DEBUG": "true"}},
+			"buy": {
+				"executor": "constant-arrival-rate", "exec": "buy", "gracefulStop": "1s",
+				"rate": 1, "duration": "10s", "preAllocatedVUs": 1
+			},
+			"manual": {"executor": "externally-controlled", "vus": 1, "maxVUs": 1, "duration": "10s"}
+		}
+	}`), &opts))
+
+	opts, err := DeriveScenariosFromShortcuts(opts, testutils.NewLogger(t))
+	require.NoError(t, err)
+	require.Empty(t, opts.Scenarios.Validate())
+
+	browse, ok := opts.Scenarios["browse"].(PerVUIterationsConfig)
+	require.True(t, ok)
+	assert.Equal(t, "browse", browse.GetExec())
+	assert.Equal(t, types.NullDurationFrom(5*time.Second), browse.GracefulStop)
+	assert.Equal(t, types.NullDurationFrom(time.Minute), browse.MaxDuration)
+	assert.Equal(t, map[string]string{"BASE_URL": "https://test.k6.io", "DEBUG": "true"}, browse.GetEnv())
+	assert.Equal(t, map[string]string{"team": "a"}, browse.GetTags())
+
+	buy, ok := opts.Scenarios["buy"].(*ConstantArrivalRateConfig)
+	require.True(t, ok)
+	assert.Equal(t, "buy", buy.GetExec())
+	assert.Equal(t, types.NullDurationFrom(time.Second), buy.GracefulStop)
+
+	manual, ok := opts.Scenarios["manual"].(ExternallyControlledConfig)
+	require.True(t, ok)
+	assert.False(t, manual.GracefulStop.Valid)
+	assert.Equal(t, "browse", manual.GetExec())
+
+	err = json.Unmarshal([]byte(`{"scenarioDefaults": {"gracefullStop": "5s"}}`), &opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `unknown field "gracefullStop"`)
+}
+
+func TestScenarioStartTimeAfter(t *testing.T) {
+	t.Parallel()
+	var opts lib.Options
+	require.NoError(t, json.Unmarshal([]byte(`{"scenarios": {
+		"seed": {"executor": "constant-vus", "vus": 1, "duration": "10s", "gracefulStop": "5s"},
+		"soak": {"executor": "constant-vus", "vus": 1, "duration": "1m", "startTime": "seed.end + 30s"},
+		"spike": {"executor": "constant-vus", "vus": 1, "duration": "10s", "startTime": "soak.end"},
+		"cleanup": {"executor": "constant-vus", "vus": 1, "duration": "10s", "startTime": " spike.end-1s "}
+	}}`), &opts))
+
+	derived, err := DeriveScenariosFromShortcuts(opts, testutils.NewLogger(t))
+	require.NoError(t, err)
+	require.Empty(t, derived.Scenarios.Validate())
+	assert.Equal(t, time.Duration(0), derived.Scenarios["seed"].GetStartTime())
+	assert.Equal(t, 45*time.Second, derived.Scenarios["soak"].GetStartTime())
+	assert.Equal(t, 135*time.Second, derived.Scenarios["spike"].GetStartTime())
+	assert.Equal(t, 174*time.Second, derived.Scenarios["cleanup"].GetStartTime())
+	assert.Equal(t, time.Duration(0), opts.Scenarios["soak"].GetStartTime())
+
+	// The end is the planned one, with the maxDuration of the iterations.
+	var plannedOpts lib.Options
+	require.NoError(t, json.Unmarshal([]byte(`{"scenarios": {
+		"seed": {"executor": "shared-iterations", "iterations": 1, "maxDuration": "2m"},
+		"soak": {"executor": "constant-vus", "vus": 1, "duration": "1m", "startTime": "seed.end"}
+	}}`), &plannedOpts))
+	planned, err := DeriveScenariosFromShortcuts(plannedOpts, testutils.NewLogger(t))
+	require.NoError(t, err)
+	assert.Equal(t, 150*time.Second, planned.Scenarios["soak"].GetStartTime())
+
+	data, err := json.Marshal(derived.Scenarios["cleanup"])
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"startTime":"spike.end - 1s"`)
+
+	testCases := []struct {
+		scenarios, expErr string
+	}{
+		{
+			`"a": {"executor": "constant-vus", "duration": "1s", "startTime": "b.end"}`,
+			"the startTime of the scenario a is relative to the end of the scenario b, which doesn't exist",
+		},
+		{
+			`"a": {"executor": "constant-vus", "duration": "1s", "startTime": "b.end"},
+			"b": {"executor": "constant-vus", "duration": "1s", "startTime": "a.end"}`,
+			"depend on each other",
+		},
+		{
+			`"a": {"executor": "constant-vus", "duration": "1s"},
+			"b": {"executor": "constant-vus", "duration": "1s", "startTime": "a.end - 1m"}`,
+			"the startTime a.end - 1m0s of the scenario b is negative",
+		},
+	}
+	for _, tc := range testCases {
+		var opts lib.Options
+		require.NoError(t, json.Unmarshal([]byte(`{"scenarios": {`+tc.scenarios+`}}`), &opts))
+		_, err := DeriveScenariosFromShortcuts(opts, testutils.NewLogger(t))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), tc.expErr)
+	}
+
+	err = json.Unmarshal([]byte(`{"scenarios": {"a": {"executor": "constant-vus", "startTime": "b.end + soon"}}}`), &opts)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `invalid startTime "b.end + soon"`)
+}
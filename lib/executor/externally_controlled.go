@@ -529,6 +529,7 @@ func (mex *ExternallyControlled) Run(
 		Name:      mex.config.Name,
 		Executor:  mex.config.Type,
 		StartTime: time.Now(),
+		Duration:  duration,
 	}
 	ctx = lib.WithScenarioState(ctx, ss)
 
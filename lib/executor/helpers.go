@@ -43,6 +43,15 @@ func sumStagesDuration(stages []Stage) (result time.Duration) {
 	return
 }
 
+// getScenarioStages returns the stages for the state of the scenario.
+func getScenarioStages(stages []Stage) []lib.ScenarioStage {
+	result := make([]lib.ScenarioStage, len(stages))
+	for i, s := range stages {
+		result[i] = lib.ScenarioStage{Duration: s.Duration.TimeDuration(), Target: s.Target.Int64}
+	}
+	return result
+}
+
 func getStagesUnscaledMaxTarget(unscaledStartValue int64, stages []Stage) int64 {
 	max := unscaledStartValue
 	for _, s := range stages {
@@ -289,13 +298,19 @@ func getVUActivationParams(
 	ctx context.Context, conf BaseConfig, deactivateCallback func(lib.InitializedVU),
 	nextIterationCounters func() (uint64, uint64),
 ) *lib.VUActivationParams {
+	prewarmConnections, prewarmURLs := conf.GetPrewarmConnections()
 	return &lib.VUActivationParams{
 		RunContext:               ctx,
 		Scenario:                 conf.Name,
 		Exec:                     conf.GetExec(),
+		Args:                     conf.GetArgs(),
 		Env:                      conf.GetEnv(),
 		Tags:                     conf.GetTags(),
 		DeactivateCallback:       deactivateCallback,
 		GetNextIterationCounters: nextIterationCounters,
+		PrewarmConnections:       prewarmConnections,
+		PrewarmURLs:              prewarmURLs,
+		Socket:                   conf.Socket,
+		Interface:                conf.GetInterface(),
 	}
 }
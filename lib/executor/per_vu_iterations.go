@@ -207,6 +207,7 @@ func (pvi PerVUIterations) Run(
 		Executor:   pvi.config.Type,
 		StartTime:  startTime,
 		ProgressFn: progressFn,
+		Duration:   duration,
 	})
 
 	returnVU := func(u lib.InitializedVU) {
@@ -232,6 +233,7 @@ func (pvi PerVUIterations) Run(
 					Value: float64(iterations - i), Metric: droppedIterationMetric,
 					Tags: pvi.getMetricTags(&vuID), Time: time.Now(),
 				})
+				pvi.executionState.AddDroppedIterations(uint64(iterations - i))
 				return // don't make more iterations
 			default:
 				// continue looping
@@ -398,6 +398,8 @@ func (varr RampingArrivalRate) Run(
 		Executor:   varr.config.Type,
 		StartTime:  startTime,
 		ProgressFn: progressFn,
+		Duration:   duration,
+		Stages:     getScenarioStages(varr.config.Stages),
 	})
 
 	returnVU := func(u lib.InitializedVU) {
@@ -483,6 +485,7 @@ func (varr RampingArrivalRate) Run(
 		// dropped - we aren't going to try to recover it, but
 
 		stats.PushIfNotDone(parentCtx, out, droppedIterationMetric.Sample(time.Now(), metricTags, 1))
+		varr.executionState.AddDroppedIterations(1)
 
 		// We'll try to start allocating another VU in the background,
 		// non-blockingly, if we have remainingUnplannedVUs...
@@ -546,6 +546,8 @@ func (vlv *RampingVUs) Run(ctx context.Context, _ chan<- stats.SampleContainer,
 		Executor:   vlv.config.Type,
 		StartTime:  runState.started,
 		ProgressFn: progressFn,
+		Duration:   regularDuration,
+		Stages:     getScenarioStages(vlv.config.Stages),
 	})
 	vlv.progress.Modify(pb.WithProgress(progressFn))
 	go trackProgress(ctx, maxDurationCtx, regularDurationCtx, vlv, progressFn)
@@ -230,6 +230,7 @@ func (si SharedIterations) Run(
 				Value: float64(totalIters - attemptedIters), Metric: builtinMetrics.DroppedIterations,
 				Tags: si.getMetricTags(nil), Time: time.Now(),
 			})
+			si.executionState.AddDroppedIterations(totalIters - attemptedIters)
 		}
 	}()
 
@@ -241,6 +242,7 @@ func (si SharedIterations) Run(
 		Executor:   si.config.Type,
 		StartTime:  startTime,
 		ProgressFn: progressFn,
+		Duration:   duration,
 	})
 
 	returnVU := func(u lib.InitializedVU) {
@@ -148,6 +148,7 @@ func TestSharedIterationsEmitDroppedIterations(t *testing.T) {
 	assert.Empty(t, logHook.Drain())
 	assert.Equal(t, int64(5), count)
 	assert.Equal(t, float64(95), sumMetricValues(engineOut, metrics.DroppedIterationsName))
+	assert.Equal(t, uint64(95), es.GetDroppedIterationCount())
 }
 
 func TestSharedIterationsGlobalIters(t *testing.T) {
@@ -30,8 +30,10 @@ import (
 	"time"
 
 	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/lib/types"
 	"go.k6.io/k6/stats"
 	"go.k6.io/k6/ui/pb"
 )
@@ -94,7 +96,19 @@ type ExecutorConfig interface {
 	//
 	// TODO: use interface{} so plain http requests can be specified?
 	GetExec() string
+	// The JSON of the object passed to the exec function after the setup data.
+	GetArgs() json.RawMessage
 	GetTags() map[string]string
+	// The limit of the rate of failed requests after which the scenario is
+	// stopped, or nil.
+	GetAbortOnErrorRate() *types.ErrorRateLimit
+	// How many failed checks or requests the scenario is stopped after, or
+	// nil.
+	GetStopOnFailures() *types.FailureLimit
+	// The intended interval between the iterations of a VU, which the
+	// http_req_duration and iteration_duration metrics are corrected for
+	// coordinated omission with, or 0.
+	GetCoordinatedOmissionInterval() time.Duration
 
 	// Calculates the VU requirements in different stages of the executor's
 	// execution, including any extensions caused by waiting for iterations to
@@ -116,6 +130,26 @@ type ScenarioState struct {
 	Name, Executor string
 	StartTime      time.Time
 	ProgressFn     func() (float64, []string)
+	// The duration of the scenario without the graceful stop, or its maximum
+	// duration for the executors that run a number of iterations.
+	Duration time.Duration
+	// The stages of the executors that have them.
+	Stages []ScenarioStage
+	// The recorded requests the iterations of the access-log-replay executor
+	// reproduce, in the order of the iterations in the test, as they loop.
+	Requests []ScenarioRequest
+}
+
+// ScenarioRequest is a recorded request, replayed by an iteration.
+type ScenarioRequest struct {
+	Method, Path string
+}
+
+// ScenarioStage is a stage of the load profile of a scenario, in which the
+// number of VUs or the iteration rate changes linearly to the target.
+type ScenarioStage struct {
+	Duration time.Duration
+	Target   int64
 }
 
 // InitVUFunc is just a shorthand so we don't have to type the function
@@ -206,6 +240,27 @@ func (scs *ScenarioConfigs) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// ScenarioDefaults are the options of the scenarioDefaults, which all the
+// scenarios inherit unless they have their own, for the executors which have
+// them. The env and the tags are merged with the ones of the scenarios.
+type ScenarioDefaults struct {
+	GracefulStop     types.NullDuration `json:"gracefulStop"`
+	GracefulRampDown types.NullDuration `json:"gracefulRampDown"`
+	MaxDuration      types.NullDuration `json:"maxDuration"`
+	Env              map[string]string  `json:"env"`
+	Exec             null.String        `json:"exec"`
+	Tags             map[string]string  `json:"tags"`
+}
+
+// used internally for JSON unmarshalling
+type rawScenarioDefaults ScenarioDefaults
+
+// UnmarshalJSON implements the json.Unmarshaler interface, with an error for
+// the unknown fields like for the scenarios.
+func (sd *ScenarioDefaults) UnmarshalJSON(data []byte) error {
+	return StrictJSONUnmarshal(data, (*rawScenarioDefaults)(sd))
+}
+
 // Validate checks if all of the specified executor options make sense
 func (scs ScenarioConfigs) Validate() (errors []error) {
 	for name, exec := range scs {
@@ -24,8 +24,15 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
 )
 
 // StrictJSONUnmarshal decodes a JSON in a strict manner, emitting an error if there
@@ -45,6 +52,87 @@ func StrictJSONUnmarshal(data []byte, v interface{}) error {
 	return nil
 }
 
+// UnknownJSONKeys returns the keys of the JSON object in data which don't match
+// a field of v, a struct or a pointer to one, sorted. Like with encoding/json,
+// the fields of the embedded structs are included, and the keys are matched
+// case-insensitively.
+func UnknownJSONKeys(data []byte, v interface{}) ([]string, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	known := make(map[string]bool)
+	addJSONFieldNames(reflect.TypeOf(v), known)
+
+	var unknown []string
+	for key := range raw {
+		if !known[strings.ToLower(key)] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown, nil
+}
+
+func addJSONFieldNames(t reflect.Type, names map[string]bool) {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" || (field.PkgPath != "" && !field.Anonymous) {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		switch {
+		case name == "" && field.Anonymous:
+			addJSONFieldNames(field.Type, names)
+		case name == "":
+			names[strings.ToLower(field.Name)] = true
+		default:
+			names[strings.ToLower(name)] = true
+		}
+	}
+}
+
+// CheckUnknownOptions reports the keys of the JSON object of options in data
+// which aren't fields of v, as they are likely misspelled options, or the
+// first unknown key of its nested objects if there are none.
+// They are an error with the InvalidConfig exit code if strict is true, and a
+// warning otherwise. The source is where the options come from, for the
+// messages.
+func CheckUnknownOptions(
+	logger logrus.FieldLogger, data []byte, v interface{}, source string, strict bool,
+) error {
+	unknown, err := UnknownJSONKeys(data, v)
+	if err != nil {
+		return err
+	}
+	if len(unknown) > 0 {
+		fields := make([]string, len(unknown))
+		for i, key := range unknown {
+			fields[i] = fmt.Sprintf("unknown field %q", key)
+		}
+		err = fmt.Errorf("%s", strings.Join(fields, ", "))
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err = dec.Decode(reflect.New(reflect.TypeOf(v)).Interface()); err == nil {
+			return nil
+		}
+	}
+	if strict {
+		err = fmt.Errorf("there were unknown fields in %s: %w", source, err)
+		return errext.WithExitCodeIfNone(err, exitcodes.InvalidConfig)
+	}
+	logger.WithError(err).Warnf("There were unknown fields in %s", source)
+	return nil
+}
+
 // GetMaxPlannedVUs returns the maximum number of planned VUs at any stage of
 // the execution plan.
 func GetMaxPlannedVUs(steps []ExecutionStep) (result uint64) {
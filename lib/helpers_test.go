@@ -21,11 +21,16 @@
 package lib
 
 import (
+	"errors"
 	"fmt"
 	"testing"
 
+	logtest "github.com/sirupsen/logrus/hooks/test"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
 )
 
 func TestStrictJSONUnmarshal(t *testing.T) {
@@ -65,4 +70,73 @@ func TestStrictJSONUnmarshal(t *testing.T) {
 	}
 }
 
+func TestUnknownJSONKeys(t *testing.T) {
+	t.Parallel()
+	type embedded struct {
+		Embedded int `json:"embedded"`
+	}
+	type someOptions struct {
+		embedded
+		Data    int `json:"data"`
+		NoTag   int
+		Ignored int `json:"-"`
+	}
+
+	unknown, err := UnknownJSONKeys([]byte(`{"data": 1, "DATA": 2, "notag": 3, "embedded": 4}`), someOptions{})
+	require.NoError(t, err)
+	assert.Empty(t, unknown)
+
+	unknown, err = UnknownJSONKeys([]byte(`{"dat": 1, "ignored": 2, "data": 3}`), &someOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dat", "ignored"}, unknown)
+
+	_, err = UnknownJSONKeys([]byte(`[]`), someOptions{})
+	assert.Error(t, err)
+}
+
+func TestCheckUnknownOptions(t *testing.T) {
+	t.Parallel()
+	type nested struct {
+		Target int `json:"target"`
+	}
+	type someOptions struct {
+		Data   int      `json:"data"`
+		Stages []nested `json:"stages"`
+	}
+
+	testCases := []struct {
+		data, expectedError string
+	}{
+		{`{"data": 1, "stages": [{"target": 1}]}`, ""},
+		{`{"dta": 1, "stagse": []}`, `unknown field "dta", unknown field "stagse"`},
+		{`{"data": 1, "stages": [{"targte": 1}]}`, `unknown field "targte"`},
+	}
+	for i, tc := range testCases {
+		tc := tc
+		t.Run(fmt.Sprintf("TestCase#%d", i), func(t *testing.T) {
+			t.Parallel()
+			logger, hook := logtest.NewNullLogger()
+			require.NoError(t, CheckUnknownOptions(logger, []byte(tc.data), someOptions{}, "the test", false))
+			err := CheckUnknownOptions(logger, []byte(tc.data), someOptions{}, "the test", true)
+			if tc.expectedError == "" {
+				require.NoError(t, err)
+				assert.Empty(t, hook.AllEntries())
+				return
+			}
+
+			require.Len(t, hook.AllEntries(), 1)
+			entry := hook.LastEntry()
+			assert.Equal(t, "There were unknown fields in the test", entry.Message)
+			assert.Contains(t, entry.Data["error"].(error).Error(), tc.expectedError) //nolint:forcetypeassert
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), "there were unknown fields in the test: ")
+			assert.Contains(t, err.Error(), tc.expectedError)
+			var ecerr errext.HasExitCode
+			require.True(t, errors.As(err, &ecerr))
+			assert.Equal(t, exitcodes.InvalidConfig, ecerr.ExitCode())
+		})
+	}
+}
+
 //TODO: test EventStream very thoroughly
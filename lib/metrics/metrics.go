@@ -30,10 +30,16 @@ const (
 	IterationsName        = "iterations"
 	IterationDurationName = "iteration_duration"
 	DroppedIterationsName = "dropped_iterations"
+	IterationsSkippedName = "iterations_skipped"
+
+	IterationsInterruptedName = "iterations_interrupted"
 
 	ChecksName        = "checks"
 	GroupDurationName = "group_duration"
 
+	TransactionDurationName = "transaction_duration"
+	TransactionFailedName   = "transaction_failed"
+
 	HTTPReqsName              = "http_reqs"
 	HTTPReqFailedName         = "http_req_failed"
 	HTTPReqDurationName       = "http_req_duration"
@@ -53,6 +59,23 @@ const (
 
 	GRPCReqDurationName = "grpc_req_duration"
 
+	ThriftReqDurationName = "thrift_req_duration"
+
+	LDAPReqDurationName = "ldap_req_duration"
+
+	DNSQueryDurationName = "dns_query_duration"
+
+	SSHReqDurationName = "ssh_req_duration"
+
+	CallbackDurationName = "callback_duration"
+
+	AsyncJobDurationName = "async_job_duration"
+
+	FTPReqDurationName         = "ftp_req_duration"
+	FileTransferThroughputName = "file_transfer_throughput"
+
+	WorkloadMixName = "workload_mix"
+
 	DataSentName     = "data_sent"
 	DataReceivedName = "data_received"
 )
@@ -64,11 +87,17 @@ type BuiltinMetrics struct {
 	Iterations        *stats.Metric
 	IterationDuration *stats.Metric
 	DroppedIterations *stats.Metric
+	IterationsSkipped *stats.Metric
+
+	IterationsInterrupted *stats.Metric
 
 	// Runner-emitted.
 	Checks        *stats.Metric
 	GroupDuration *stats.Metric
 
+	TransactionDuration *stats.Metric
+	TransactionFailed   *stats.Metric
+
 	// HTTP-related.
 	HTTPReqs              *stats.Metric
 	HTTPReqFailed         *stats.Metric
@@ -91,6 +120,31 @@ type BuiltinMetrics struct {
 	// gRPC-related
 	GRPCReqDuration *stats.Metric
 
+	// Thrift-related
+	ThriftReqDuration *stats.Metric
+
+	// LDAP-related
+	LDAPReqDuration *stats.Metric
+
+	// DNS-related
+	DNSQueryDuration *stats.Metric
+
+	// SSH-related
+	SSHReqDuration *stats.Metric
+
+	// Mock server-related; the time until an awaited callback arrived.
+	CallbackDuration *stats.Metric
+
+	// Polling-related; the time until a polled asynchronous job completed.
+	AsyncJobDuration *stats.Metric
+
+	// File transfer-related; the throughput is in bytes per second.
+	FTPReqDuration         *stats.Metric
+	FileTransferThroughput *stats.Metric
+
+	// Workload-related; whether each endpoint of a mix was picked.
+	WorkloadMix *stats.Metric
+
 	// Network-related; used for future protocols as well.
 	DataSent     *stats.Metric
 	DataReceived *stats.Metric
@@ -104,10 +158,16 @@ func RegisterBuiltinMetrics(registry *Registry) *BuiltinMetrics {
 		Iterations:        registry.MustNewMetric(IterationsName, stats.Counter),
 		IterationDuration: registry.MustNewMetric(IterationDurationName, stats.Trend, stats.Time),
 		DroppedIterations: registry.MustNewMetric(DroppedIterationsName, stats.Counter),
+		IterationsSkipped: registry.MustNewMetric(IterationsSkippedName, stats.Counter),
+
+		IterationsInterrupted: registry.MustNewMetric(IterationsInterruptedName, stats.Counter),
 
 		Checks:        registry.MustNewMetric(ChecksName, stats.Rate),
 		GroupDuration: registry.MustNewMetric(GroupDurationName, stats.Trend, stats.Time),
 
+		TransactionDuration: registry.MustNewMetric(TransactionDurationName, stats.Trend, stats.Time),
+		TransactionFailed:   registry.MustNewMetric(TransactionFailedName, stats.Rate),
+
 		HTTPReqs:              registry.MustNewMetric(HTTPReqsName, stats.Counter),
 		HTTPReqFailed:         registry.MustNewMetric(HTTPReqFailedName, stats.Rate),
 		HTTPReqDuration:       registry.MustNewMetric(HTTPReqDurationName, stats.Trend, stats.Time),
@@ -127,6 +187,23 @@ func RegisterBuiltinMetrics(registry *Registry) *BuiltinMetrics {
 
 		GRPCReqDuration: registry.MustNewMetric(GRPCReqDurationName, stats.Trend, stats.Time),
 
+		ThriftReqDuration: registry.MustNewMetric(ThriftReqDurationName, stats.Trend, stats.Time),
+
+		LDAPReqDuration: registry.MustNewMetric(LDAPReqDurationName, stats.Trend, stats.Time),
+
+		DNSQueryDuration: registry.MustNewMetric(DNSQueryDurationName, stats.Trend, stats.Time),
+
+		SSHReqDuration: registry.MustNewMetric(SSHReqDurationName, stats.Trend, stats.Time),
+
+		CallbackDuration: registry.MustNewMetric(CallbackDurationName, stats.Trend, stats.Time),
+
+		AsyncJobDuration: registry.MustNewMetric(AsyncJobDurationName, stats.Trend, stats.Time),
+
+		FTPReqDuration:         registry.MustNewMetric(FTPReqDurationName, stats.Trend, stats.Time),
+		FileTransferThroughput: registry.MustNewMetric(FileTransferThroughputName, stats.Trend, stats.Data),
+
+		WorkloadMix: registry.MustNewMetric(WorkloadMixName, stats.Rate),
+
 		DataSent:     registry.MustNewMetric(DataSentName, stats.Counter, stats.Data),
 		DataReceived: registry.MustNewMetric(DataReceivedName, stats.Counter, stats.Data),
 	}
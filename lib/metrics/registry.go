@@ -23,6 +23,7 @@ package metrics
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"sync"
 
 	"go.k6.io/k6/stats"
@@ -31,13 +32,16 @@ import (
 // Registry is what can create metrics
 type Registry struct {
 	metrics map[string]*stats.Metric
-	l       sync.RWMutex
+	// thresholds were declared with the metrics in the script, by metric name
+	thresholds map[string]stats.Thresholds
+	l          sync.RWMutex
 }
 
 // NewRegistry returns a new registry
 func NewRegistry() *Registry {
 	return &Registry{
-		metrics: make(map[string]*stats.Metric),
+		metrics:    make(map[string]*stats.Metric),
+		thresholds: make(map[string]stats.Thresholds),
 	}
 }
 
@@ -54,7 +58,50 @@ func checkName(name string) bool {
 func (r *Registry) NewMetric(name string, typ stats.MetricType, t ...stats.ValueType) (*stats.Metric, error) {
 	r.l.Lock()
 	defer r.l.Unlock()
+	return r.newMetric(name, typ, t...)
+}
+
+// NewHistogram returns a new Histogram metric registered to this registry,
+// with the upper bounds of its buckets. An existing one must have the same
+// buckets, since all of the samples of a metric are counted in one sink.
+func (r *Registry) NewHistogram(name string, buckets []float64, t ...stats.ValueType) (*stats.Metric, error) {
+	sink, err := stats.NewHistogramSink(buckets)
+	if err != nil {
+		return nil, fmt.Errorf("invalid buckets of the histogram '%s': %w", name, err)
+	}
+
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	_, existed := r.metrics[name]
+	m, err := r.newMetric(name, stats.Histogram, t...)
+	if err != nil {
+		return nil, err
+	}
+	if !existed {
+		m.Sink = sink
+		return m, nil
+	}
+	if oldBuckets := m.Sink.(*stats.HistogramSink).Buckets; !equalBuckets(oldBuckets, sink.Buckets) {
+		return nil, fmt.Errorf("metric '%s' already exists but with the buckets %v, instead of %v",
+			name, oldBuckets, sink.Buckets)
+	}
+	return m, nil
+}
+
+func equalBuckets(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
 
+func (r *Registry) newMetric(name string, typ stats.MetricType, t ...stats.ValueType) (*stats.Metric, error) {
 	if !checkName(name) {
 		return nil, fmt.Errorf("Invalid metric name: '%s'", name) //nolint:golint,stylecheck
 	}
@@ -77,6 +124,25 @@ func (r *Registry) NewMetric(name string, typ stats.MetricType, t ...stats.Value
 	return oldMetric, nil
 }
 
+// Get returns the metric with the name, or nil if it isn't registered.
+func (r *Registry) Get(name string) *stats.Metric {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	return r.metrics[name]
+}
+
+// All returns all the registered metrics, sorted by name.
+func (r *Registry) All() []*stats.Metric {
+	r.l.RLock()
+	defer r.l.RUnlock()
+	all := make([]*stats.Metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		all = append(all, m)
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].Name < all[j].Name })
+	return all
+}
+
 // MustNewMetric is like NewMetric, but will panic if there is an error
 func (r *Registry) MustNewMetric(name string, typ stats.MetricType, t ...stats.ValueType) *stats.Metric {
 	m, err := r.NewMetric(name, typ, t...)
@@ -85,3 +151,32 @@ func (r *Registry) MustNewMetric(name string, typ stats.MetricType, t ...stats.V
 	}
 	return m
 }
+
+// AddThresholds adds the thresholds declared with the named metric in the
+// script, apart from the ones with the same sources as the ones it already has
+// since the init context is run for every VU.
+func (r *Registry) AddThresholds(name string, ts stats.Thresholds) error {
+	r.l.Lock()
+	defer r.l.Unlock()
+
+	if _, ok := r.metrics[name]; !ok {
+		return fmt.Errorf("can't add thresholds to the unknown metric '%s'", name)
+	}
+	merged := r.thresholds[name]
+	merged.Merge(ts)
+	r.thresholds[name] = merged
+	return nil
+}
+
+// Thresholds returns the thresholds declared with the metrics in the script,
+// by metric name.
+func (r *Registry) Thresholds() map[string]stats.Thresholds {
+	r.l.RLock()
+	defer r.l.RUnlock()
+
+	thresholds := make(map[string]stats.Thresholds, len(r.thresholds))
+	for name, ts := range r.thresholds {
+		thresholds[name] = ts
+	}
+	return thresholds
+}
@@ -48,6 +48,56 @@ func TestRegistryNewMetric(t *testing.T) {
 	require.Error(t, err)
 }
 
+func TestRegistryNewHistogram(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+
+	histogram, err := r.NewHistogram("something", []float64{100, 10, 50}, stats.Time)
+	require.NoError(t, err)
+	assert.Equal(t, stats.Histogram, histogram.Type)
+	require.IsType(t, &stats.HistogramSink{}, histogram.Sink)
+	assert.Equal(t, []float64{10, 50, 100}, histogram.Sink.(*stats.HistogramSink).Buckets)
+
+	histogramAgain, err := r.NewHistogram("something", []float64{10, 50, 100}, stats.Time)
+	require.NoError(t, err)
+	require.Same(t, histogram, histogramAgain)
+
+	_, err = r.NewHistogram("something", []float64{10, 100}, stats.Time)
+	require.Error(t, err)
+
+	_, err = r.NewHistogram("other", []float64{10, 10})
+	require.Error(t, err)
+
+	_, err = r.NewMetric("something", stats.Trend, stats.Time)
+	require.Error(t, err)
+}
+
+func TestRegistryThresholds(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	require.Error(t, r.AddThresholds("unknown", stats.NewThresholds([]string{"count>1"})))
+
+	_, err := r.NewMetric("something", stats.Counter)
+	require.NoError(t, err)
+	require.NoError(t, r.AddThresholds("something", stats.NewThresholds([]string{"count>1"})))
+	require.NoError(t, r.AddThresholds("something", stats.NewThresholds([]string{"count>1", "rate<10"})))
+
+	thresholds := r.Thresholds()
+	require.Contains(t, thresholds, "something")
+	require.Len(t, thresholds["something"].Thresholds, 2)
+	assert.Equal(t, "count>1", thresholds["something"].Thresholds[0].Source)
+	assert.Equal(t, "rate<10", thresholds["something"].Thresholds[1].Source)
+}
+
+func TestRegistryAll(t *testing.T) {
+	t.Parallel()
+	r := NewRegistry()
+	assert.Empty(t, r.All())
+	b := r.MustNewMetric("b", stats.Counter)
+	a := r.MustNewMetric("a", stats.Trend)
+	assert.Equal(t, []*stats.Metric{a, b}, r.All())
+}
+
 func TestMetricNames(t *testing.T) {
 	t.Parallel()
 	testMap := map[string]bool{
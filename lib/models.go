@@ -28,6 +28,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/guregu/null.v3"
@@ -205,6 +206,73 @@ type Check struct {
 	// Counters for how many times this check has passed and failed respectively.
 	Passes int64 `json:"passes"`
 	Fails  int64 `json:"fails"`
+
+	// Messages describing what was checked in the first failures, so it's
+	// known what went wrong and not only how often, and the paths of the files
+	// with the requests and responses of failures. They are only accessed with
+	// the failuresMutex held.
+	failureMessages []string
+	failureCaptures []string
+	failuresMutex   sync.Mutex
+}
+
+// MarshalJSON encodes the check with its failure messages and captures, with
+// the same keys as in the summary.
+func (c *Check) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Name            string   `json:"name"`
+		Path            string   `json:"path"`
+		ID              string   `json:"id"`
+		Passes          int64    `json:"passes"`
+		Fails           int64    `json:"fails"`
+		FailureMessages []string `json:"failure_messages,omitempty"`
+		FailureCaptures []string `json:"failure_captures,omitempty"`
+	}{
+		Name:            c.Name,
+		Path:            c.Path,
+		ID:              c.ID,
+		Passes:          atomic.LoadInt64(&c.Passes),
+		Fails:           atomic.LoadInt64(&c.Fails),
+		FailureMessages: c.GetFailureMessages(),
+		FailureCaptures: c.GetFailureCaptures(),
+	})
+}
+
+// AddFailureMessage records the message of a failure, if there are less than
+// limit messages already.
+func (c *Check) AddFailureMessage(message string, limit int) {
+	c.failuresMutex.Lock()
+	defer c.failuresMutex.Unlock()
+	if len(c.failureMessages) < limit {
+		c.failureMessages = append(c.failureMessages, message)
+	}
+}
+
+// GetFailureMessages returns a copy of the recorded failure messages.
+func (c *Check) GetFailureMessages() []string {
+	c.failuresMutex.Lock()
+	defer c.failuresMutex.Unlock()
+	if len(c.failureMessages) == 0 {
+		return nil
+	}
+	return append([]string(nil), c.failureMessages...)
+}
+
+// AddFailureCapture records the path of the file with the capture of a failure.
+func (c *Check) AddFailureCapture(path string) {
+	c.failuresMutex.Lock()
+	defer c.failuresMutex.Unlock()
+	c.failureCaptures = append(c.failureCaptures, path)
+}
+
+// GetFailureCaptures returns a copy of the paths of the failure captures.
+func (c *Check) GetFailureCaptures() []string {
+	c.failuresMutex.Lock()
+	defer c.failuresMutex.Unlock()
+	if len(c.failureCaptures) == 0 {
+		return nil
+	}
+	return append([]string(nil), c.failureCaptures...)
 }
 
 // Creates a new check with the given name and parent group. The group may not be nil.
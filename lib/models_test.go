@@ -44,6 +44,21 @@ func TestStageJSON(t *testing.T) {
 	assert.Equal(t, s, s2)
 }
 
+func TestCheckJSON(t *testing.T) {
+	group, err := NewGroup("", nil)
+	assert.NoError(t, err)
+	check, err := group.Check("is ok")
+	assert.NoError(t, err)
+	check.Fails = 2
+	check.AddFailureMessage("first", 1)
+	check.AddFailureMessage("second", 1)
+
+	data, err := json.Marshal(check)
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"name":"is ok","path":"::is ok","id":"`+check.ID+`","passes":0,"fails":2,`+
+		`"failure_messages":["first"]}`, string(data))
+}
+
 // Suggested by @nkovacs in https://github.com/k6io/k6/issues/207#issuecomment-330545467
 func TestDataRaces(t *testing.T) {
 	t.Run("Check race", func(t *testing.T) {
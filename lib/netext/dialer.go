@@ -25,6 +25,7 @@ import (
 	"fmt"
 	"net"
 	"strconv"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -43,9 +44,18 @@ type Dialer struct {
 	Blacklist        []*lib.IPNet
 	BlockedHostnames *types.HostnameTrie
 	Hosts            map[string]*lib.HostAddress
+	// Socket are the options set on the sockets of the TCP connections
+	Socket lib.SocketOptions
+	// Interface is the network interface the connections are opened from,
+	// instead of the LocalAddr of the Dialer, if it's set
+	Interface *Interface
 
 	BytesRead    int64
 	BytesWritten int64
+
+	// prewarmed are the idle connections opened by Prewarm
+	prewarmedMu sync.Mutex
+	prewarmed   map[prewarmKey][]prewarmedConn
 }
 
 // NewDialer constructs a new Dialer with the given DNS resolver.
@@ -76,18 +86,40 @@ func (b BlockedHostError) Error() string {
 	return fmt.Sprintf("hostname (%s) is in a blocked pattern (%s)", b.hostname, b.match)
 }
 
+// ConnectionAttemptDelay is how long the Dialer waits for a connection
+// attempt before it starts the next one in parallel, when it races them to
+// several addresses, as recommended by RFC 8305.
+const ConnectionAttemptDelay = 250 * time.Millisecond
+
 // DialContext wraps the net.Dialer.DialContext and handles the k6 specifics
 func (d *Dialer) DialContext(ctx context.Context, proto, addr string) (net.Conn, error) {
-	dialAddr, err := d.getDialAddr(addr)
+	if conn := d.takePrewarmed(addr, false); conn != nil {
+		return conn, nil
+	}
+	return d.dialCounted(ctx, proto, addr, &d.BytesRead, &d.BytesWritten)
+}
+
+// dialCounted dials addr, and counts the data the connection exchanges in
+// bytesRead and bytesWritten.
+func (d *Dialer) dialCounted(ctx context.Context, proto, addr string, bytesRead, bytesWritten *int64) (*Conn, error) {
+	dialAddrs, err := d.getDialAddrs(addr)
 	if err != nil {
 		return nil, err
 	}
-	conn, err := d.Dialer.DialContext(ctx, proto, dialAddr)
+	var conn net.Conn
+	if len(dialAddrs) == 1 {
+		conn, err = d.dial(ctx, proto, dialAddrs[0])
+	} else {
+		conn, err = d.raceDial(ctx, proto, dialAddrs)
+	}
 	if err != nil {
 		return nil, err
 	}
-	conn = &Conn{conn, &d.BytesRead, &d.BytesWritten}
-	return conn, err
+	if err = setSocketOptions(conn, d.Socket); err != nil {
+		_ = conn.Close()
+		return nil, err
+	}
+	return &Conn{conn, bytesRead, bytesWritten}, nil
 }
 
 // GetTrail creates a new NetTrail instance with the Dialer
@@ -142,22 +174,148 @@ func (d *Dialer) GetTrail(
 	}
 }
 
+// raceDial dials the addresses in order, like the Happy Eyeballs of RFC 8305:
+// the next attempt is started when the previous one failed, or after the
+// ConnectionAttemptDelay if it's still pending. The first connection to be
+// established is returned and the others are closed.
+func (d *Dialer) raceDial(ctx context.Context, proto string, addrs []string) (net.Conn, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type attempt struct {
+		conn net.Conn
+		err  error
+	}
+	attempts := make(chan attempt, len(addrs))
+	started, finished := 0, 0
+	start := func() {
+		go func(addr string) {
+			conn, err := d.dial(ctx, proto, addr)
+			attempts <- attempt{conn, err}
+		}(addrs[started])
+		started++
+	}
+
+	start()
+	timer := time.NewTimer(ConnectionAttemptDelay)
+	defer timer.Stop()
+	var firstErr error
+	for {
+		select {
+		case <-timer.C:
+			if started < len(addrs) {
+				start()
+				timer.Reset(ConnectionAttemptDelay)
+			}
+		case a := <-attempts:
+			finished++
+			if a.err == nil {
+				// The pending attempts are canceled, but they could still
+				// have been established in the meantime
+				go func(pending int) {
+					for ; pending > 0; pending-- {
+						if late := <-attempts; late.conn != nil {
+							_ = late.conn.Close()
+						}
+					}
+				}(started - finished)
+				return a.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = a.err
+			}
+			if started < len(addrs) {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				start()
+				timer.Reset(ConnectionAttemptDelay)
+			} else if finished == started {
+				return nil, firstErr
+			}
+		}
+	}
+}
+
+// dial connects to the IP address addr, from the address of the Interface if
+// there's one, with the socket bound to it on Linux.
+func (d *Dialer) dial(ctx context.Context, proto, addr string) (net.Conn, error) {
+	if d.Interface == nil {
+		return d.Dialer.DialContext(ctx, proto, addr)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	localAddr, err := d.Interface.localAddr(proto, net.ParseIP(host))
+	if err != nil {
+		return nil, err
+	}
+	dialer := d.Dialer
+	dialer.LocalAddr = localAddr
+	dialer.Control = d.Interface.control
+	return dialer.DialContext(ctx, proto, addr)
+}
+
+// setSocketOptions sets the socket options which are set in opts on the
+// socket of conn, if it's a TCP connection.
+func setSocketOptions(conn net.Conn, opts lib.SocketOptions) error {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+	var err error
+	if opts.NoDelay.Valid {
+		err = tcpConn.SetNoDelay(opts.NoDelay.Bool)
+	}
+	if err == nil && opts.SendBuffer.Valid {
+		err = tcpConn.SetWriteBuffer(int(opts.SendBuffer.Int64))
+	}
+	if err == nil && opts.ReceiveBuffer.Valid {
+		err = tcpConn.SetReadBuffer(int(opts.ReceiveBuffer.Int64))
+	}
+	if err == nil && opts.KeepAlive.Valid {
+		err = tcpConn.SetKeepAlive(opts.KeepAlive.Duration > 0)
+		if err == nil && opts.KeepAlive.Duration > 0 {
+			err = tcpConn.SetKeepAlivePeriod(opts.KeepAlive.TimeDuration())
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("couldn't set the socket options: %w", err)
+	}
+	return nil
+}
+
 func (d *Dialer) getDialAddr(addr string) (string, error) {
-	remote, err := d.findRemote(addr)
+	dialAddrs, err := d.getDialAddrs(addr)
 	if err != nil {
 		return "", err
 	}
+	return dialAddrs[0], nil
+}
+
+// getDialAddrs returns the addresses the connections to addr should be raced
+// to, more than one only with a MultiIPResolver.
+func (d *Dialer) getDialAddrs(addr string) ([]string, error) {
+	remotes, err := d.findRemotes(addr)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, ipnet := range d.Blacklist {
-		if ipnet.Contains(remote.IP) {
-			return "", BlackListedIPError{ip: remote.IP, net: ipnet}
+	dialAddrs := make([]string, len(remotes))
+	for i, remote := range remotes {
+		for _, ipnet := range d.Blacklist {
+			if ipnet.Contains(remote.IP) {
+				return nil, BlackListedIPError{ip: remote.IP, net: ipnet}
+			}
 		}
+		dialAddrs[i] = remote.String()
 	}
 
-	return remote.String(), nil
+	return dialAddrs, nil
 }
 
-func (d *Dialer) findRemote(addr string) (*lib.HostAddress, error) {
+func (d *Dialer) findRemotes(addr string) ([]*lib.HostAddress, error) {
 	host, port, err := net.SplitHostPort(addr)
 	if err != nil {
 		return nil, err
@@ -172,23 +330,35 @@ func (d *Dialer) findRemote(addr string) (*lib.HostAddress, error) {
 
 	remote, err := d.getConfiguredHost(addr, host, port)
 	if err != nil || remote != nil {
-		return remote, err
+		return []*lib.HostAddress{remote}, err
 	}
 
 	if ip != nil {
-		return lib.NewHostAddress(ip, port)
+		remote, err = lib.NewHostAddress(ip, port)
+		return []*lib.HostAddress{remote}, err
 	}
 
-	ip, err = d.Resolver.LookupIP(host)
+	var ips []net.IP
+	if resolver, ok := d.Resolver.(MultiIPResolver); ok {
+		ips, err = resolver.LookupIPs(host)
+	} else if ip, err = d.Resolver.LookupIP(host); ip != nil {
+		ips = []net.IP{ip}
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	if ip == nil {
+	if len(ips) == 0 {
 		return nil, fmt.Errorf("lookup %s: no such host", host)
 	}
 
-	return lib.NewHostAddress(ip, port)
+	remotes := make([]*lib.HostAddress, len(ips))
+	for i, ip := range ips {
+		if remotes[i], err = lib.NewHostAddress(ip, port); err != nil {
+			return nil, err
+		}
+	}
+	return remotes, nil
 }
 
 func (d *Dialer) getConfiguredHost(addr, host, port string) (*lib.HostAddress, error) {
@@ -215,6 +385,24 @@ func (d *Dialer) getConfiguredHost(addr, host, port string) (*lib.HostAddress, e
 	return nil, nil
 }
 
+// IPFamily returns the value of the ip_family system tag for the IP of the
+// remote address of a connection: ipv4 or ipv6, or an empty string if it
+// isn't an IP address.
+func IPFamily(addr net.Addr) string {
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return ""
+	}
+	switch ip := net.ParseIP(host); {
+	case ip == nil:
+		return ""
+	case ip.To4() != nil:
+		return "ipv4"
+	default:
+		return "ipv6"
+	}
+}
+
 // NetTrail contains information about the exchanged data size and length of a
 // series of connections from a particular netext.Dialer
 type NetTrail struct {
@@ -0,0 +1,142 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"errors"
+	"net"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/sys/unix"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/types"
+)
+
+func TestDialerSocketOptionsValues(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	getsockopt := func(t *testing.T, conn net.Conn, level, opt int) int {
+		rawConn, err := conn.(*Conn).Conn.(*net.TCPConn).SyscallConn()
+		require.NoError(t, err)
+		var value int
+		var sockErr error
+		require.NoError(t, rawConn.Control(func(fd uintptr) {
+			value, sockErr = syscall.GetsockoptInt(int(fd), level, opt)
+		}))
+		require.NoError(t, sockErr)
+		return value
+	}
+	dial := func(t *testing.T, opts lib.SocketOptions) net.Conn {
+		dialer := NewDialer(net.Dialer{KeepAlive: -1}, newResolver())
+		dialer.Socket = opts
+		conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+		return conn
+	}
+
+	t.Run("set", func(t *testing.T) {
+		t.Parallel()
+		conn := dial(t, lib.SocketOptions{
+			NoDelay:       null.BoolFrom(false),
+			SendBuffer:    null.IntFrom(8192),
+			ReceiveBuffer: null.IntFrom(8192),
+			KeepAlive:     types.NullDurationFrom(30 * time.Second),
+			Valid:         true,
+		})
+		assert.Equal(t, 0, getsockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+		// Linux doubles the buffer sizes for its bookkeeping
+		assert.Equal(t, 2*8192, getsockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_SNDBUF))
+		assert.Equal(t, 2*8192, getsockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_RCVBUF))
+		assert.Equal(t, 1, getsockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE))
+		assert.Equal(t, 30, getsockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE))
+	})
+
+	t.Run("disabled keep-alive", func(t *testing.T) {
+		t.Parallel()
+		conn := dial(t, lib.SocketOptions{KeepAlive: types.NullDurationFrom(0), Valid: true})
+		assert.Equal(t, 1, getsockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+		assert.Equal(t, 0, getsockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE))
+	})
+
+	t.Run("unset", func(t *testing.T) {
+		t.Parallel()
+		conn := dial(t, lib.SocketOptions{})
+		assert.Equal(t, 1, getsockopt(t, conn, syscall.IPPROTO_TCP, syscall.TCP_NODELAY))
+		assert.Equal(t, 0, getsockopt(t, conn, syscall.SOL_SOCKET, syscall.SO_KEEPALIVE))
+	})
+}
+
+func TestDialerInterfaceBindToDevice(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	dialer.Interface = NewInterface("lo")
+	conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	if errors.Is(err, syscall.EPERM) {
+		t.Skip("binding the sockets to a device isn't permitted")
+	}
+	require.NoError(t, err)
+	defer func() { _ = conn.Close() }()
+
+	rawConn, err := conn.(*Conn).Conn.(*net.TCPConn).SyscallConn()
+	require.NoError(t, err)
+	var device string
+	var sockErr error
+	require.NoError(t, rawConn.Control(func(fd uintptr) {
+		device, sockErr = unix.GetsockoptString(int(fd), unix.SOL_SOCKET, unix.SO_BINDTODEVICE)
+	}))
+	require.NoError(t, sockErr)
+	assert.Equal(t, "lo", device)
+}
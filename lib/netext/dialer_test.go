@@ -21,10 +21,13 @@
 package netext
 
 import (
+	"context"
 	"net"
 	"testing"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/testutils/mockresolver"
@@ -125,6 +128,91 @@ func TestDialerAddrBlockHostnamesStar(t *testing.T) {
 	}
 }
 
+func TestDialerHappyEyeballs(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+	_, port, err := net.SplitHostPort(listener.Addr().String())
+	require.NoError(t, err)
+
+	mr := mockresolver.New(map[string][]net.IP{
+		"dual-stack.com": {net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
+	}, nil)
+	dialer := NewDialer(net.Dialer{}, NewResolver(mr.LookupIPAll, 0, types.DNSfirst, types.DNShappyEyeballs))
+
+	addrs, err := dialer.getDialAddrs("dual-stack.com:" + port)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"[::1]:" + port, "127.0.0.1:" + port}, addrs)
+
+	// Only the IPv4 address is listened on, so the IPv6 attempt fails or
+	// times out and the IPv4 one wins
+	conn, err := dialer.DialContext(context.Background(), "tcp", "dual-stack.com:"+port)
+	require.NoError(t, err)
+	assert.Equal(t, "ipv4", IPFamily(conn.RemoteAddr()))
+	require.NoError(t, conn.Close())
+
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedAddr := closed.Addr().String()
+	require.NoError(t, closed.Close())
+	_, err = dialer.raceDial(context.Background(), "tcp", []string{closedAddr, closedAddr})
+	assert.Error(t, err)
+}
+
+func TestDialerSocketOptions(t *testing.T) {
+	t.Parallel()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	dialer.Socket = lib.SocketOptions{
+		NoDelay:       null.BoolFrom(false),
+		SendBuffer:    null.IntFrom(8192),
+		ReceiveBuffer: null.IntFrom(8192),
+		KeepAlive:     types.NullDurationFrom(0),
+		Valid:         true,
+	}
+	conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	require.NoError(t, conn.Close())
+
+	// the options are only set on TCP connections
+	client, server := net.Pipe()
+	defer func() { _ = server.Close() }()
+	assert.NoError(t, setSocketOptions(client, dialer.Socket))
+	require.NoError(t, client.Close())
+}
+
+func TestIPFamily(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "ipv4", IPFamily(&net.TCPAddr{IP: net.ParseIP("1.2.3.4"), Port: 80}))
+	assert.Equal(t, "ipv6", IPFamily(&net.TCPAddr{IP: net.ParseIP("2001:db8::68"), Port: 443}))
+	assert.Equal(t, "", IPFamily(&net.UnixAddr{Name: "/tmp/k6.sock", Net: "unix"}))
+}
+
 func newResolver() *mockresolver.MockResolver {
 	return mockresolver.New(
 		map[string][]net.IP{
@@ -134,3 +222,49 @@ func newResolver() *mockresolver.MockResolver {
 		}, nil,
 	)
 }
+
+func TestDialerInterface(t *testing.T) {
+	t.Parallel()
+
+	var loopback string
+	ifaces, err := net.Interfaces()
+	require.NoError(t, err)
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 {
+			loopback = iface.Name
+			break
+		}
+	}
+	if loopback == "" {
+		t.Skip("there's no loopback interface")
+	}
+
+	listener, err := net.Listen("tcp4", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() { _ = listener.Close() }()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			_ = conn.Close()
+		}
+	}()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	dialer.Interface = NewInterface(loopback)
+	conn, err := dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.NoError(t, err)
+	assert.True(t, conn.LocalAddr().(*net.TCPAddr).IP.IsLoopback()) //nolint:forcetypeassert
+	require.NoError(t, conn.Close())
+
+	addr, err := dialer.Interface.localAddr("udp", net.ParseIP("127.0.0.2"))
+	require.NoError(t, err)
+	assert.IsType(t, &net.UDPAddr{}, addr)
+
+	dialer.Interface = NewInterface("k6-missing0")
+	_, err = dialer.DialContext(context.Background(), "tcp", listener.Addr().String())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), `couldn't find the network interface "k6-missing0"`)
+}
@@ -48,6 +48,7 @@ const (
 	defaultNetNonTCPErrorCode errCode = 1010
 	invalidURLErrorCode       errCode = 1020
 	requestTimeoutErrorCode   errCode = 1050
+	requestAbortedErrorCode   errCode = 1060
 	// DNS errors
 	defaultDNSErrorCode      errCode = 1100
 	dnsNoSuchHostErrorCode   errCode = 1101
@@ -102,6 +103,7 @@ const (
 	x509HostnameErrorCodeMsg    = "x509: certificate doesn't match hostname"
 	x509UnknownAuthority        = "x509: unknown authority"
 	requestTimeoutErrorCodeMsg  = "request timeout"
+	requestAbortedErrorCodeMsg  = "request aborted"
 	invalidURLErrorCodeMsg      = "invalid URL"
 )
 
@@ -72,6 +72,14 @@ type ParsedHTTPRequest struct {
 	ActiveJar        *cookiejar.Jar
 	Cookies          map[string]*HTTPRequestCookie
 	Tags             map[string]string
+	// Metadata are attached to the samples of the request, without being tags
+	Metadata map[string]string
+	// Signal is done when the request should be aborted, it's nil if the
+	// request has no AbortSignal.
+	Signal context.Context
+	// DiscardResponseMetrics is true if the http_req_* metrics of the request
+	// shouldn't be emitted, e.g. for health probes, its data are still counted.
+	DiscardResponseMetrics bool
 }
 
 // Matches non-compliant io.Closer implementations (e.g. zstd.Decoder)
@@ -207,7 +215,7 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 		}
 	}
 
-	tracerTransport := newTransport(ctx, state, tags, preq.ResponseCallback)
+	tracerTransport := newTransport(ctx, state, tags, preq.Metadata, preq.ResponseCallback, preq.DiscardResponseMetrics)
 	var transport http.RoundTripper = tracerTransport
 
 	// Combine tags with common log fields
@@ -251,6 +259,15 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 	}
 
 	resp := &Response{URL: preq.URL.URL, Request: respReq}
+	if preq.Signal != nil && preq.Signal.Err() != nil {
+		// The request isn't sent at all if it was aborted before
+		err := NewK6Error(requestAbortedErrorCode, requestAbortedErrorCodeMsg, preq.Signal.Err())
+		if preq.Throw {
+			return nil, err
+		}
+		resp.Error, resp.ErrorCode = err.Message, int(err.Code)
+		return resp, nil
+	}
 	client := http.Client{
 		Transport: transport,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
@@ -283,6 +300,16 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 
 	reqCtx, cancelFunc := context.WithTimeout(ctx, preq.Timeout)
 	defer cancelFunc()
+	if preq.Signal != nil {
+		reqCtx = context.WithValue(reqCtx, abortSignalKey{}, preq.Signal)
+		go func() {
+			select {
+			case <-preq.Signal.Done():
+				cancelFunc()
+			case <-reqCtx.Done():
+			}
+		}()
+	}
 	mreq := preq.Req.WithContext(reqCtx)
 	res, resErr := client.Do(mreq)
 
@@ -297,7 +324,9 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 
 	if resErr == nil {
 		resp.Body, resErr = readResponseBody(state, preq.ResponseType, res, resErr)
-		if resErr != nil && errors.Is(resErr, context.DeadlineExceeded) {
+		if resErr != nil && requestAborted(reqCtx) {
+			resErr = NewK6Error(requestAbortedErrorCode, requestAbortedErrorCodeMsg, resErr)
+		} else if resErr != nil && errors.Is(resErr, context.DeadlineExceeded) {
 			// TODO This can be more specific that the timeout happened in the middle of the reading of the body
 			resErr = NewK6Error(requestTimeoutErrorCode, requestTimeoutErrorCodeMsg, resErr)
 		}
@@ -349,17 +378,31 @@ func MakeRequest(ctx context.Context, state *lib.State, preq *ParsedHTTPRequest)
 			return nil, resErr
 		}
 
-		// Do *not* log errors about the context being cancelled.
+		// Do *not* log errors about the context being cancelled, or the
+		// request being aborted by the script.
 		select {
 		case <-ctx.Done():
 		default:
-			state.Logger.WithField("error", resErr).Warn("Request Failed")
+			if !requestAborted(reqCtx) {
+				state.Logger.WithField("error", resErr).Warn("Request Failed")
+			}
 		}
 	}
 
 	return resp, nil
 }
 
+// abortSignalKey is the key of the AbortSignal context in the context of the
+// requests which have one.
+type abortSignalKey struct{}
+
+// requestAborted returns whether the request with the context was aborted by
+// its AbortSignal.
+func requestAborted(ctx context.Context) bool {
+	signal, ok := ctx.Value(abortSignalKey{}).(context.Context)
+	return ok && signal.Err() != nil
+}
+
 // SetRequestCookies sets the cookies of the requests getting those cookies both from the jar and
 // from the reqCookies map. The Replace field of the HTTPRequestCookie will be taken into account
 func SetRequestCookies(req *http.Request, jar *cookiejar.Jar, reqCookies map[string]*HTTPRequestCookie) {
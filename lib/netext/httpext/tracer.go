@@ -55,6 +55,8 @@ type Trail struct {
 	ConnRemoteAddr net.Addr
 
 	Failed null.Bool
+	// Metadata are attached to the samples by SaveSamples()
+	Metadata map[string]string
 	// Populated by SaveSamples()
 	Tags    *stats.SampleTags
 	Samples []stats.Sample
@@ -74,6 +76,11 @@ func (tr *Trail) SaveSamples(builtinMetrics *metrics.BuiltinMetrics, tags *stats
 		{Metric: builtinMetrics.HTTPReqWaiting, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Waiting)},
 		{Metric: builtinMetrics.HTTPReqReceiving, Time: tr.EndTime, Tags: tags, Value: stats.D(tr.Receiving)},
 	}...)
+	if tr.Metadata != nil {
+		for i := range tr.Samples {
+			tr.Samples[i].Metadata = tr.Metadata
+		}
+	}
 }
 
 // GetSamples implements the stats.SampleContainer interface.
@@ -91,8 +98,19 @@ func (tr *Trail) GetTime() time.Time {
 	return tr.EndTime
 }
 
+// ShiftTime implements the stats.ShiftableSampleContainer interface.
+func (tr *Trail) ShiftTime(offset time.Duration) {
+	tr.EndTime = tr.EndTime.Add(offset)
+	for i := range tr.Samples {
+		tr.Samples[i].Time = tr.Samples[i].Time.Add(offset)
+	}
+}
+
 // Ensure that interfaces are implemented correctly
-var _ stats.ConnectedSampleContainer = &Trail{}
+var (
+	_ stats.ConnectedSampleContainer = &Trail{}
+	_ stats.ShiftableSampleContainer = &Trail{}
+)
 
 // A Tracer wraps "net/http/httptrace" to collect granular timings for HTTP requests.
 // Note that since there is not yet an event for the end of a request (there's a PR to
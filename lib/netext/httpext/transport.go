@@ -28,6 +28,7 @@ import (
 	"net/http/httptrace"
 	"strconv"
 	"sync"
+	"time"
 
 	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/netext"
@@ -40,7 +41,9 @@ type transport struct {
 	ctx              context.Context
 	state            *lib.State
 	tags             map[string]string
+	metadata         map[string]string
 	responseCallback func(int) bool
+	discardMetrics   bool
 
 	lastRequest     *unfinishedRequest
 	lastRequestLock *sync.Mutex
@@ -54,6 +57,9 @@ type unfinishedRequest struct {
 	request  *http.Request
 	response *http.Response
 	err      error
+
+	// whether the VU was still running when the request was sent
+	sentBeforeInterrupt bool
 }
 
 // finishedRequest is produced once the request has been finalized; it is
@@ -74,17 +80,25 @@ var _ http.RoundTripper = &transport{}
 // the provided state's Transport. It uses a httpext.Tracer to measure all HTTP
 // requests made through it and annotates and emits the recorded metric samples
 // through the state.Samples channel.
+// interruptedSamplesTimeout is how long the samples of the requests cut short
+// by the interruption of their iteration can wait to be sent.
+const interruptedSamplesTimeout = time.Second
+
 func newTransport(
 	ctx context.Context,
 	state *lib.State,
 	tags map[string]string,
+	metadata map[string]string,
 	responseCallback func(int) bool,
+	discardMetrics bool,
 ) *transport {
 	return &transport{
 		ctx:              ctx,
 		state:            state,
 		tags:             tags,
+		metadata:         metadata,
 		responseCallback: responseCallback,
+		discardMetrics:   discardMetrics,
 		lastRequestLock:  new(sync.Mutex),
 	}
 }
@@ -139,8 +153,12 @@ func (t *transport) measureAndEmitMetrics(unfReq *unfinishedRequest) *finishedRe
 			tags["status"] = "0"
 		}
 	} else {
+		status := strconv.Itoa(unfReq.response.StatusCode)
 		if enabledTags.Has(stats.TagStatus) {
-			tags["status"] = strconv.Itoa(unfReq.response.StatusCode)
+			tags["status"] = status
+		}
+		if enabledTags.Has(stats.TagStatusClass) {
+			tags["status_class"] = stats.StatusClass(status)
 		}
 		if unfReq.response.StatusCode >= 400 {
 			if enabledTags.Has(stats.TagErrorCode) {
@@ -163,11 +181,28 @@ func (t *transport) measureAndEmitMetrics(unfReq *unfinishedRequest) *finishedRe
 			result.tlsInfo = tlsInfo
 		}
 	}
+	// The request was cut short because the VU was interrupted, e.g. at the
+	// end of gracefulRampDown, so its samples are tagged to be told apart.
+	// Requests which were only attempted after that are still dropped.
+	interrupted := unfReq.sentBeforeInterrupt && t.ctx.Err() != nil
+	if interrupted && enabledTags.Has(stats.TagInterrupted) {
+		tags[stats.TagInterrupted.String()] = "true"
+	}
 	if enabledTags.Has(stats.TagIP) && trail.ConnRemoteAddr != nil {
 		if ip, _, err := net.SplitHostPort(trail.ConnRemoteAddr.String()); err == nil {
 			tags["ip"] = ip
 		}
 	}
+	if enabledTags.Has(stats.TagIPFamily) && trail.ConnRemoteAddr != nil {
+		if family := netext.IPFamily(trail.ConnRemoteAddr); family != "" {
+			tags[stats.TagIPFamily.String()] = family
+		}
+	}
+	if t.discardMetrics {
+		// The data sent and received are still counted, by the connections
+		return result
+	}
+
 	var failed float64
 	if t.responseCallback != nil {
 		var statusCode int
@@ -186,19 +221,37 @@ func (t *transport) measureAndEmitMetrics(unfReq *unfinishedRequest) *finishedRe
 
 	finalTags := stats.IntoSampleTags(&tags)
 	builtinMetrics := t.state.BuiltinMetrics
+	trail.Metadata = t.metadata
 	trail.SaveSamples(builtinMetrics, finalTags)
 	if t.responseCallback != nil {
 		trail.Failed.Valid = true
 		if failed == 1 {
 			trail.Failed.Bool = true
+			if es := lib.GetExecutionState(t.ctx); es != nil {
+				es.AddFailedRequests(1)
+			}
 		}
 		trail.Samples = append(trail.Samples,
 			stats.Sample{
 				Metric: builtinMetrics.HTTPReqFailed, Time: trail.EndTime, Tags: finalTags, Value: failed,
+				Metadata: trail.Metadata,
 			},
 		)
 	}
-	stats.PushIfNotDone(t.ctx, t.state.Samples, trail)
+	if interrupted {
+		// Like the samples of the interrupted iteration itself, these are
+		// still sent, instead of being silently dropped, but without waiting
+		// forever for them to be read, as the context is done already.
+		timer := time.NewTimer(interruptedSamplesTimeout)
+		defer timer.Stop()
+		select {
+		case t.state.Samples <- trail:
+		case <-timer.C:
+			t.state.Logger.Debug("Dropped the samples of an interrupted request, as they weren't read in time")
+		}
+	} else {
+		stats.PushIfNotDone(t.ctx, t.state.Samples, trail)
+	}
 
 	return result
 }
@@ -240,12 +293,15 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 	t.processLastSavedRequest(nil)
 
 	ctx := req.Context()
+	sentBeforeInterrupt := t.ctx.Err() == nil
 	tracer := &Tracer{}
 	reqWithTracer := req.WithContext(httptrace.WithClientTrace(ctx, tracer.Trace()))
 	resp, err := t.state.Transport.RoundTrip(reqWithTracer)
 
 	var netError net.Error
-	if errors.As(err, &netError) && netError.Timeout() {
+	if err != nil && requestAborted(ctx) {
+		err = NewK6Error(requestAbortedErrorCode, requestAbortedErrorCodeMsg, err)
+	} else if errors.As(err, &netError) && netError.Timeout() {
 		var netOpError *net.OpError
 		if errors.As(err, &netOpError) && netOpError.Op == "dial" {
 			err = NewK6Error(tcpDialTimeoutErrorCode, tcpDialTimeoutErrorCodeMsg, netError)
@@ -260,6 +316,8 @@ func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
 		request:  req,
 		response: resp,
 		err:      err,
+
+		sentBeforeInterrupt: sentBeforeInterrupt,
 	})
 
 	return resp, err
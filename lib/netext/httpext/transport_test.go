@@ -25,9 +25,12 @@ import (
 	"net/http"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
 	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/stats"
 )
 
@@ -84,3 +87,29 @@ func BenchmarkMeasureAndEmitMetrics(b *testing.B) {
 		}
 	})
 }
+
+func TestMeasureAndEmitMetricsInterrupted(t *testing.T) {
+	t.Parallel()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	samples := make(chan stats.SampleContainer) // nothing reads it
+	tr := transport{
+		state: &lib.State{
+			Options: lib.Options{RunTags: &stats.SampleTags{}, SystemTags: &stats.DefaultSystemTagSet},
+			Samples:        samples,
+			Logger:         logrus.New(),
+			BuiltinMetrics: metrics.RegisterBuiltinMetrics(metrics.NewRegistry()),
+		},
+		ctx: ctx,
+	}
+
+	start := time.Now()
+	tr.measureAndEmitMetrics(&unfinishedRequest{
+		tracer:              &Tracer{},
+		response:            &http.Response{StatusCode: 200},
+		request:             &http.Request{URL: &url.URL{Host: "example.com", Scheme: "https"}},
+		sentBeforeInterrupt: true,
+	})
+	assert.Less(t, int64(time.Since(start)), int64(interruptedSamplesTimeout+time.Second),
+		"the samples of an interrupted request don't block forever")
+}
@@ -0,0 +1,104 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// Interface is a network interface the connections of a Dialer are opened
+// from, by binding them to its address of the family of the remote address.
+// Its addresses are looked up the first time they are needed, and shared by
+// all the Dialers using it.
+type Interface struct {
+	Name string
+
+	once  sync.Once
+	addrs []net.IP
+	err   error
+}
+
+// NewInterface returns the network interface with the name, without checking
+// that it exists.
+func NewInterface(name string) *Interface {
+	return &Interface{Name: name}
+}
+
+// Addrs returns the IP addresses of the interface, without the IPv6 link-local
+// ones, which can't be used without a zone. It returns an error if there's no
+// interface with the name, or if it has no addresses.
+func (i *Interface) Addrs() ([]net.IP, error) {
+	i.once.Do(func() {
+		i.addrs, i.err = interfaceAddrs(i.Name)
+	})
+	return i.addrs, i.err
+}
+
+func interfaceAddrs(name string) ([]net.IP, error) {
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't find the network interface %q: %w", name, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get the addresses of the network interface %q: %w", name, err)
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok || (ipnet.IP.To4() == nil && ipnet.IP.IsLinkLocalUnicast()) {
+			continue
+		}
+		ips = append(ips, ipnet.IP)
+	}
+	if len(ips) == 0 {
+		return nil, fmt.Errorf("the network interface %q has no IP addresses", name)
+	}
+	return ips, nil
+}
+
+// localAddr returns the address of the interface the connections to the
+// remote address should be opened from, of the same family, with the type of
+// the network.
+func (i *Interface) localAddr(network string, remote net.IP) (net.Addr, error) {
+	addrs, err := i.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	isIPv4 := remote.To4() != nil
+	for _, ip := range addrs {
+		if (ip.To4() != nil) != isIPv4 {
+			continue
+		}
+		if strings.HasPrefix(network, "udp") {
+			return &net.UDPAddr{IP: ip}, nil
+		}
+		return &net.TCPAddr{IP: ip}, nil
+	}
+	family := "IPv6"
+	if isIPv4 {
+		family = "IPv4"
+	}
+	return nil, fmt.Errorf("the network interface %q has no %s address to connect to %s from", i.Name, family, remote)
+}
@@ -0,0 +1,187 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"sync"
+)
+
+// prewarmKey is the address the prewarmed connections are opened to, and
+// whether their TLS handshake is done.
+type prewarmKey struct {
+	addr string
+	tls  bool
+}
+
+// prewarmedConn is a connection opened by Prewarm. The data exchanged by the
+// counted connection under it is only counted in the BytesRead and
+// BytesWritten of the Dialer once it's returned by the Dialer.
+type prewarmedConn struct {
+	conn    net.Conn
+	counted *Conn
+}
+
+// Prewarm opens n connections to the host of every URL, with the TLS
+// handshake done with the config for the https ones, and keeps them idle until
+// DialContext, or DialTLSContext for the TLS ones, returns them instead of
+// opening new ones. No request is sent over them, and the data of their
+// handshakes isn't counted in BytesRead and BytesWritten.
+func (d *Dialer) Prewarm(ctx context.Context, urls []string, n int, config *tls.Config) error {
+	for _, u := range urls {
+		key, err := getPrewarmKey(u)
+		if err == nil {
+			err = d.prewarm(ctx, key, n, config)
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't prewarm the connections to %s: %w", u, err)
+		}
+	}
+	return nil
+}
+
+// DialTLSContext returns one of the TLS connections to addr opened by Prewarm,
+// or dials a new one with the config. The handshake of the new connections is
+// left to the caller, as http.Transport does it for the connections returned
+// by its DialTLSContext, so it's traced like the ones it dials itself.
+func (d *Dialer) DialTLSContext(ctx context.Context, proto, addr string, config *tls.Config) (net.Conn, error) {
+	if conn := d.takePrewarmed(addr, true); conn != nil {
+		return conn, nil
+	}
+	conn, err := d.DialContext(ctx, proto, addr)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Client(conn, getClientTLSConfig(config, addr)), nil
+}
+
+// ClosePrewarmed closes the connections opened by Prewarm which weren't used.
+func (d *Dialer) ClosePrewarmed() {
+	d.prewarmedMu.Lock()
+	defer d.prewarmedMu.Unlock()
+	for _, conns := range d.prewarmed {
+		for _, pc := range conns {
+			_ = pc.conn.Close()
+		}
+	}
+	d.prewarmed = nil
+}
+
+func (d *Dialer) prewarm(ctx context.Context, key prewarmKey, n int, config *tls.Config) error {
+	conns := make(chan prewarmedConn, n)
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			pc, err := d.dialPrewarmed(ctx, key, config)
+			if err != nil {
+				errs <- err
+				return
+			}
+			conns <- pc
+		}()
+	}
+	wg.Wait()
+	close(conns)
+	close(errs)
+
+	d.prewarmedMu.Lock()
+	if d.prewarmed == nil {
+		d.prewarmed = make(map[prewarmKey][]prewarmedConn)
+	}
+	for pc := range conns {
+		d.prewarmed[key] = append(d.prewarmed[key], pc)
+	}
+	d.prewarmedMu.Unlock()
+	return <-errs
+}
+
+func (d *Dialer) dialPrewarmed(ctx context.Context, key prewarmKey, config *tls.Config) (prewarmedConn, error) {
+	var discarded int64
+	counted, err := d.dialCounted(ctx, "tcp", key.addr, &discarded, &discarded)
+	if err != nil {
+		return prewarmedConn{}, err
+	}
+	if !key.tls {
+		return prewarmedConn{conn: counted, counted: counted}, nil
+	}
+	tlsConn := tls.Client(counted, getClientTLSConfig(config, key.addr))
+	if err = tlsConn.HandshakeContext(ctx); err != nil {
+		_ = tlsConn.Close()
+		return prewarmedConn{}, err
+	}
+	return prewarmedConn{conn: tlsConn, counted: counted}, nil
+}
+
+// takePrewarmed returns one of the connections opened by Prewarm to addr, whose
+// data is counted from now on, or nil if there's none left.
+func (d *Dialer) takePrewarmed(addr string, isTLS bool) net.Conn {
+	key := prewarmKey{addr: addr, tls: isTLS}
+	d.prewarmedMu.Lock()
+	defer d.prewarmedMu.Unlock()
+	conns := d.prewarmed[key]
+	if len(conns) == 0 {
+		return nil
+	}
+	pc := conns[len(conns)-1]
+	d.prewarmed[key] = conns[:len(conns)-1]
+	pc.counted.BytesRead, pc.counted.BytesWritten = &d.BytesRead, &d.BytesWritten
+	return pc.conn
+}
+
+// getPrewarmKey returns the address of the host of the URL, the same way as
+// http.Transport dials it.
+func getPrewarmKey(rawURL string) (prewarmKey, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return prewarmKey{}, err
+	}
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	return prewarmKey{addr: net.JoinHostPort(u.Hostname(), port), tls: u.Scheme == "https"}, nil
+}
+
+// getClientTLSConfig returns a copy of the config with the host of addr as the
+// ServerName, if it doesn't have one.
+func getClientTLSConfig(config *tls.Config, addr string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{} //nolint:gosec
+	} else {
+		config = config.Clone()
+	}
+	if config.ServerName == "" {
+		if host, _, err := net.SplitHostPort(addr); err == nil {
+			config.ServerName = host
+		}
+	}
+	return config
+}
@@ -0,0 +1,101 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package netext
+
+import (
+	"context"
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialerPrewarm(t *testing.T) {
+	t.Parallel()
+
+	var accepted int64
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	}))
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt64(&accepted, 1)
+		}
+	}
+	srv.StartTLS()
+	defer srv.Close()
+	config := srv.Client().Transport.(*http.Transport).TLSClientConfig
+	ctx := context.Background()
+
+	dialer := NewDialer(net.Dialer{}, newResolver())
+	defer dialer.ClosePrewarmed()
+	require.NoError(t, dialer.Prewarm(ctx, []string{srv.URL + "/path"}, 2, config))
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&accepted) == 2 }, time.Second, time.Millisecond)
+	assert.Zero(t, atomic.LoadInt64(&dialer.BytesRead))
+	assert.Zero(t, atomic.LoadInt64(&dialer.BytesWritten))
+
+	transport := &http.Transport{
+		DialContext: dialer.DialContext,
+		DialTLSContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			return dialer.DialTLSContext(ctx, network, addr, config)
+		},
+	}
+	defer transport.CloseIdleConnections()
+	resp, err := (&http.Client{Transport: transport}).Get(srv.URL)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.NoError(t, resp.Body.Close())
+	assert.Equal(t, "ok", string(body))
+	assert.Equal(t, int64(2), atomic.LoadInt64(&accepted))
+	assert.NotZero(t, atomic.LoadInt64(&dialer.BytesRead))
+	assert.NotZero(t, atomic.LoadInt64(&dialer.BytesWritten))
+
+	addr := srv.Listener.Addr().String()
+	conn, err := dialer.DialTLSContext(ctx, "tcp", addr, config)
+	require.NoError(t, err)
+	assert.True(t, conn.(*tls.Conn).ConnectionState().HandshakeComplete)
+	require.NoError(t, conn.Close())
+
+	// the handshake of the connections which weren't prewarmed is left to the caller
+	conn, err = dialer.DialTLSContext(ctx, "tcp", addr, config)
+	require.NoError(t, err)
+	assert.False(t, conn.(*tls.Conn).ConnectionState().HandshakeComplete)
+	require.NoError(t, conn.Close())
+
+	// the TLS connections aren't returned for plain TCP, and the other way around
+	require.NoError(t, dialer.Prewarm(ctx, []string{"http://" + addr}, 1, nil))
+	assert.Nil(t, dialer.takePrewarmed(addr, true))
+	conn, err = dialer.DialContext(ctx, "tcp", addr)
+	require.NoError(t, err)
+	assert.IsType(t, &Conn{}, conn)
+	require.NoError(t, conn.Close())
+	assert.Eventually(t, func() bool { return atomic.LoadInt64(&accepted) == 4 }, time.Second, time.Millisecond)
+
+	assert.Error(t, dialer.Prewarm(ctx, []string{"https://" + addr}, 1, &tls.Config{})) //nolint:gosec
+}
@@ -37,6 +37,14 @@ type Resolver interface {
 	LookupIP(host string) (net.IP, error)
 }
 
+// MultiIPResolver is a Resolver which can return more than one IP for a host,
+// which the Dialer races the connections to, e.g. the IPv6 and IPv4 ones of
+// the happyEyeballs DNS policy.
+type MultiIPResolver interface {
+	Resolver
+	LookupIPs(host string) ([]net.IP, error)
+}
+
 type resolver struct {
 	resolve     MultiResolver
 	selectIndex types.DNSSelect
@@ -96,11 +104,44 @@ func (r *resolver) LookupIP(host string) (net.IP, error) {
 	return r.selectOne(host, ips), nil
 }
 
+// LookupIPs returns the IPs resolved for host which the connections should be
+// raced to, in the order they should be tried. It's the one IP of LookupIP,
+// except with the happyEyeballs policy.
+func (r *resolver) LookupIPs(host string) ([]net.IP, error) {
+	ips, err := r.resolve(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.selectAll(host, r.applyPolicy(ips)), nil
+}
+
 // LookupIP returns a single IP resolved for host, selected according to the
 // configured select and policy options. Results are cached per host and will be
 // refreshed if the last lookup time exceeds the configured TTL (not the TTL
 // returned in the DNS record).
 func (r *cacheResolver) LookupIP(host string) (net.IP, error) {
+	ips, err := r.lookup(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.selectOne(host, ips), nil
+}
+
+// LookupIPs is like the LookupIPs of the resolver, with the cached IPs.
+func (r *cacheResolver) LookupIPs(host string) ([]net.IP, error) {
+	ips, err := r.lookup(host)
+	if err != nil {
+		return nil, err
+	}
+
+	return r.selectAll(host, ips), nil
+}
+
+// lookup returns the IPs of host with the policy applied, from the cache if
+// they were looked up less than the TTL ago.
+func (r *cacheResolver) lookup(host string) ([]net.IP, error) {
 	r.cm.Lock()
 
 	var ips []net.IP
@@ -121,14 +162,22 @@ func (r *cacheResolver) LookupIP(host string) (net.IP, error) {
 
 	r.cm.Unlock()
 
-	return r.selectOne(host, ips), nil
+	return ips, nil
 }
 
 func (r *resolver) selectOne(host string, ips []net.IP) net.IP {
 	if len(ips) == 0 {
 		return nil
 	}
+	if r.policy == types.DNShappyEyeballs {
+		return r.selectAll(host, ips)[0]
+	}
+	return r.pick(host, ips)
+}
 
+// pick returns the IP selected from ips according to the select option, with
+// the round robin index of key.
+func (r *resolver) pick(key string, ips []net.IP) net.IP {
 	var ip net.IP
 	switch r.selectIndex {
 	case types.DNSfirst:
@@ -137,8 +186,8 @@ func (r *resolver) selectOne(host string, ips []net.IP) net.IP {
 		r.rrm.Lock()
 		// NOTE: This index approach is not stable and might result in returning
 		// repeated or skipped IPs if the records change during a test run.
-		ip = ips[int(r.roundRobin[host])%len(ips)]
-		r.roundRobin[host]++
+		ip = ips[int(r.roundRobin[key])%len(ips)]
+		r.roundRobin[key]++
 		r.rrm.Unlock()
 	case types.DNSrandom:
 		r.rrm.Lock()
@@ -149,8 +198,32 @@ func (r *resolver) selectOne(host string, ips []net.IP) net.IP {
 	return ip
 }
 
+// selectAll returns the IPs the connections should be raced to: one selected
+// from ips with the happyEyeballs policy for every IP family, IPv6 first as
+// RFC 8305 recommends, or only the selected one otherwise.
+func (r *resolver) selectAll(host string, ips []net.IP) []net.IP {
+	if r.policy != types.DNShappyEyeballs {
+		if ip := r.selectOne(host, ips); ip != nil {
+			return []net.IP{ip}
+		}
+		return nil
+	}
+
+	ip4, ip6 := groupByVersion(ips)
+	var selected []net.IP
+	// The families are selected from separately, so e.g. the round robin of
+	// one doesn't skip the addresses of the other
+	if len(ip6) > 0 {
+		selected = append(selected, r.pick(host+"/ipv6", ip6))
+	}
+	if len(ip4) > 0 {
+		selected = append(selected, r.pick(host+"/ipv4", ip4))
+	}
+	return selected
+}
+
 func (r *resolver) applyPolicy(ips []net.IP) (retIPs []net.IP) {
-	if r.policy == types.DNSany {
+	if r.policy == types.DNSany || r.policy == types.DNShappyEyeballs {
 		return ips
 	}
 	ip4, ip6 := groupByVersion(ips)
@@ -170,7 +243,7 @@ func (r *resolver) applyPolicy(ips []net.IP) (retIPs []net.IP) {
 	case types.DNSonlyIPv6:
 		retIPs = ip6
 	// Already checked above, but added to satisfy 'exhaustive' linter.
-	case types.DNSany:
+	case types.DNSany, types.DNShappyEyeballs:
 		retIPs = ips
 	}
 
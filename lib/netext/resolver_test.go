@@ -113,4 +113,25 @@ func TestResolver(t *testing.T) {
 			})
 		}
 	})
+
+	t.Run("LookupIPs", func(t *testing.T) {
+		r := NewResolver(mr.LookupIPAll, time.Minute, types.DNSroundRobin, types.DNShappyEyeballs)
+		require.Implements(t, (*MultiIPResolver)(nil), r)
+		mipr := r.(MultiIPResolver)
+
+		ips, err := mipr.LookupIPs(host)
+		require.NoError(t, err)
+		assert.Equal(t, []net.IP{net.ParseIP("2001:db8::10"), net.ParseIP("127.0.0.10")}, ips)
+		ips, err = mipr.LookupIPs(host)
+		require.NoError(t, err)
+		assert.Equal(t, []net.IP{net.ParseIP("2001:db8::11"), net.ParseIP("127.0.0.11")}, ips)
+		ip, err := r.LookupIP(host)
+		require.NoError(t, err)
+		assert.Equal(t, net.ParseIP("2001:db8::12"), ip)
+
+		r = NewResolver(mr.LookupIPAll, 0, types.DNSfirst, types.DNSpreferIPv4)
+		ips, err = r.(MultiIPResolver).LookupIPs(host)
+		require.NoError(t, err)
+		assert.Equal(t, []net.IP{net.ParseIP("127.0.0.10")}, ips)
+	})
 }
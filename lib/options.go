@@ -38,6 +38,18 @@ import (
 // iterations+vus, or stages)
 const DefaultScenarioName = "default"
 
+// DefaultCheckFailureMessages is how many failures of every check have a
+// message in the summary, if the checkFailureMessages option isn't set
+const DefaultCheckFailureMessages = 3
+
+// DefaultCheckFailureCaptures and DefaultCheckFailureCaptureSize are the
+// maximum number of files and bytes of the captures of the requests and
+// responses that made checks fail, if they aren't set
+const (
+	DefaultCheckFailureCaptures    = 10
+	DefaultCheckFailureCaptureSize = 10 << 20
+)
+
 // DefaultSummaryTrendStats are the default trend columns shown in the test summary output
 // nolint: gochecknoglobals
 var DefaultSummaryTrendStats = []string{"avg", "min", "med", "max", "p(90)", "p(95)"}
@@ -275,6 +287,98 @@ func ParseCIDR(s string) (*IPNet, error) {
 	return &parsedIPNet, nil
 }
 
+// Hooks are shell commands that k6 runs on the lifecycle events of a test,
+// outside of the script.
+type Hooks struct {
+	// Run before the init of the VUs and setup()
+	BeforeTest null.String `json:"beforeTest"`
+	// Run after the end-of-test summary was handled, whatever the outcome
+	AfterTest null.String `json:"afterTest"`
+	// Run when the test was aborted or it failed, before afterTest
+	OnAbort null.String `json:"onAbort"`
+	// Valid is only needed by ForEachSpecified(), like types.DNSConfig.Valid
+	Valid bool `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (h *Hooks) UnmarshalJSON(data []byte) error {
+	type hooks Hooks
+	var v hooks
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*h = Hooks(v)
+	h.Valid = h.BeforeTest.Valid || h.AfterTest.Valid || h.OnAbort.Valid
+	return nil
+}
+
+// IsEmpty returns whether no hook is set.
+func (h Hooks) IsEmpty() bool {
+	return h.BeforeTest.String == "" && h.AfterTest.String == "" && h.OnAbort.String == ""
+}
+
+// SocketOptions are the options of the TCP sockets of the connections the VUs
+// make, e.g. for high-throughput workloads of small messages. The ones which
+// aren't set are left to the defaults of Go and of the OS.
+type SocketOptions struct {
+	// Send the small writes right away, with TCP_NODELAY, which Go does by
+	// default, or coalesce them with Nagle's algorithm if it's false
+	NoDelay null.Bool `json:"noDelay"`
+	// The sizes of the send and receive buffers in bytes, with SO_SNDBUF and
+	// SO_RCVBUF
+	SendBuffer    null.Int `json:"sendBuffer"`
+	ReceiveBuffer null.Int `json:"receiveBuffer"`
+	// The interval of the TCP keep-alive probes, 0 disables them
+	KeepAlive types.NullDuration `json:"keepAlive"`
+	// Valid is only needed by ForEachSpecified(), like types.DNSConfig.Valid
+	Valid bool `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (so *SocketOptions) UnmarshalJSON(data []byte) error {
+	type socketOptions SocketOptions
+	var v socketOptions
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*so = SocketOptions(v)
+	so.Valid = so.NoDelay.Valid || so.SendBuffer.Valid || so.ReceiveBuffer.Valid || so.KeepAlive.Valid
+	return nil
+}
+
+// Apply returns the options with the ones set in other overriding them.
+func (so SocketOptions) Apply(other SocketOptions) SocketOptions {
+	if other.NoDelay.Valid {
+		so.NoDelay = other.NoDelay
+	}
+	if other.SendBuffer.Valid {
+		so.SendBuffer = other.SendBuffer
+	}
+	if other.ReceiveBuffer.Valid {
+		so.ReceiveBuffer = other.ReceiveBuffer
+	}
+	if other.KeepAlive.Valid {
+		so.KeepAlive = other.KeepAlive
+	}
+	so.Valid = so.Valid || other.Valid
+	return so
+}
+
+// Validate checks that the buffer sizes are positive and that the keep-alive
+// interval isn't negative.
+func (so SocketOptions) Validate() (errors []error) {
+	if so.SendBuffer.Valid && so.SendBuffer.Int64 <= 0 {
+		errors = append(errors, fmt.Errorf("the sendBuffer of the socket options should be more than 0"))
+	}
+	if so.ReceiveBuffer.Valid && so.ReceiveBuffer.Int64 <= 0 {
+		errors = append(errors, fmt.Errorf("the receiveBuffer of the socket options should be more than 0"))
+	}
+	if so.KeepAlive.Duration < 0 {
+		errors = append(errors, fmt.Errorf("the keepAlive of the socket options can't be negative"))
+	}
+	return errors
+}
+
 type Options struct {
 	// Should the test start in a paused state?
 	Paused null.Bool `json:"paused" envconfig:"K6_PAUSED"`
@@ -293,6 +397,7 @@ type Options struct {
 	// variables, but we currently can't, because envconfig has this nasty bug
 	// (among others): https://github.com/kelseyhightower/envconfig/issues/113
 	Scenarios                ScenarioConfigs           `json:"scenarios" ignored:"true"`
+	ScenarioDefaults         *ScenarioDefaults         `json:"scenarioDefaults" ignored:"true"`
 	ExecutionSegment         *ExecutionSegment         `json:"executionSegment" ignored:"true"`
 	ExecutionSegmentSequence *ExecutionSegmentSequence `json:"executionSegmentSequence" ignored:"true"`
 
@@ -308,6 +413,10 @@ type Options struct {
 	// DNS handling configuration.
 	DNS types.DNSConfig `json:"dns" envconfig:"K6_DNS"`
 
+	// Options of the TCP sockets, which scenarios can override. Can't be set
+	// through env vars.
+	Socket SocketOptions `json:"socket" ignored:"true"`
+
 	// How many HTTP redirects do we follow?
 	MaxRedirects null.Int `json:"maxRedirects" envconfig:"K6_MAX_REDIRECTS"`
 
@@ -368,6 +477,34 @@ type Options struct {
 	// Summary time unit for summary metrics (response times) in CLI output
 	SummaryTimeUnit null.String `json:"summaryTimeUnit" envconfig:"K6_SUMMARY_TIME_UNIT"`
 
+	// The length of the intervals the key metrics are aggregated over for the
+	// timeline in the summary; 0, the default, disables the timeline
+	SummaryTimelineInterval types.NullDuration `json:"summaryTimelineInterval" envconfig:"K6_SUMMARY_TIMELINE_INTERVAL"`
+
+	// The length of the intervals the error rate, latency and throughput of
+	// the requests are compared over to detect anomalies; 0 disables it
+	AnomalyDetectionInterval types.NullDuration `json:"anomalyDetectionInterval" envconfig:"K6_ANOMALY_DETECTION_INTERVAL"` //nolint:lll
+
+	// How often the thresholds are evaluated during the test, 2s by default
+	ThresholdsEvaluationInterval types.NullDuration `json:"thresholdsEvaluationInterval" envconfig:"K6_THRESHOLDS_EVALUATION_INTERVAL"` //nolint:lll
+
+	// When the thresholds are first evaluated, instead of after one interval,
+	// e.g. to not abort on the noisy samples of the warm-up
+	ThresholdsEvaluationDelay types.NullDuration `json:"thresholdsEvaluationDelay" envconfig:"K6_THRESHOLDS_EVALUATION_DELAY"`
+
+	// The significant digits the values of the Trend metrics are rounded to
+	// for the summary and the thresholds, with bounded memory; they are kept
+	// as they are if it's not set or 0
+	TrendPrecision null.Int `json:"trendPrecision" envconfig:"K6_TREND_PRECISION"`
+
+	// How the percentiles of the Trend metrics are calculated, "linear"
+	// (interpolation, the default) or "nearest-rank"
+	TrendPercentileMethod null.String `json:"trendPercentileMethod" envconfig:"K6_TREND_PERCENTILE_METHOD"`
+
+	// The half-life of the exponentially weighted moving average of the Gauge
+	// metrics, 10s by default
+	GaugeEWMAHalfLife types.NullDuration `json:"gaugeEWMAHalfLife" envconfig:"K6_GAUGE_EWMA_HALF_LIFE"`
+
 	// Which system tags to include with metrics ("method", "vu" etc.)
 	// Use pointer for identifying whether user provide any tag or not.
 	SystemTags *stats.SystemTagSet `json:"systemTags" envconfig:"K6_SYSTEM_TAGS"`
@@ -375,20 +512,63 @@ type Options struct {
 	// Tags to be applied to all samples for this running
 	RunTags *stats.SampleTags `json:"tags" envconfig:"K6_TAGS"`
 
+	// Rules for transforming tag values (e.g. hashing or truncating them) before
+	// the samples reach the thresholds and outputs.
+	TagTransforms stats.TagTransforms `json:"tagTransforms" ignored:"true"`
+
+	// How many distinct combinations of tag values every metric can have,
+	// the values of the new ones are replaced past it; 0, the default,
+	// disables the limit
+	MaxTagCombinations null.Int `json:"maxTagCombinations" envconfig:"K6_MAX_TAG_COMBINATIONS"`
+
+	// Commands to run on the lifecycle events of the test, they need the
+	// --allow-exec flag. Can't be set through env vars.
+	Hooks Hooks `json:"hooks" ignored:"true"`
+
 	// Buffer size of the channel for metric samples; 0 means unbuffered
 	MetricSamplesBufferSize null.Int `json:"metricSamplesBufferSize" envconfig:"K6_METRIC_SAMPLES_BUFFER_SIZE"`
 
 	// Do not reset cookies after a VU iteration
 	NoCookiesReset null.Bool `json:"noCookiesReset" envconfig:"K6_NO_COOKIES_RESET"`
 
+	// How many failures of every check have a message describing the checked
+	// value in the summary, DefaultCheckFailureMessages if it's not set
+	CheckFailureMessages null.Int `json:"checkFailureMessages" envconfig:"K6_CHECK_FAILURE_MESSAGES"`
+
+	// Save the requests and responses that made checks fail to this directory
+	CheckFailureCaptureDir null.String `json:"-" envconfig:"K6_CHECK_FAILURE_CAPTURE_DIR"`
+
+	// The maximum number of files and bytes of the saved requests and responses
+	CheckFailureCaptures    null.Int `json:"checkFailureCaptures" envconfig:"K6_CHECK_FAILURE_CAPTURES"`
+	CheckFailureCaptureSize null.Int `json:"checkFailureCaptureSize" envconfig:"K6_CHECK_FAILURE_CAPTURE_SIZE"`
+
+	// Stop the test when the rate of failed requests in a recent window of
+	// time is over the limit, scenarios can have their own limits too
+	AbortOnErrorRate *types.ErrorRateLimit `json:"abortOnErrorRate" envconfig:"-"`
+
 	// Discard Http Responses Body
 	DiscardResponseBodies null.Bool `json:"discardResponseBodies" envconfig:"K6_DISCARD_RESPONSE_BODIES"`
 
+	// Aggregate the samples of setup() and teardown(), and of the groups
+	// within them, only in the sub-metrics of their groups, e.g.
+	// http_req_duration{group:::setup}, instead of in the metrics, their
+	// other sub-metrics and thresholds, which needs the group system tag
+	IsolateSetupTeardownMetrics null.Bool `json:"isolateSetupTeardownMetrics" envconfig:"K6_ISOLATE_SETUP_TEARDOWN_METRICS"`
+
+	// Keep the number and boolean values of the tags of the custom metrics
+	// typed for the outputs, e.g. in JSON and as InfluxDB fields, instead
+	// of their string forms
+	TypedTags null.Bool `json:"typedTags" envconfig:"K6_TYPED_TAGS"`
+
 	// Redirect console logging to a file
 	ConsoleOutput null.String `json:"-" envconfig:"K6_CONSOLE_OUTPUT"`
 
 	// Specify client IP ranges and/or CIDR from which VUs will make requests
 	LocalIPs types.NullIPPool `json:"-" envconfig:"K6_LOCAL_IPS"`
+
+	// The name of the network interface the connections are opened from, it
+	// can't be set along with the LocalIPs
+	Interface null.String `json:"interface" envconfig:"K6_INTERFACE"`
 }
 
 // Returns the result of overwriting any fields with any that are set on the argument.
@@ -440,6 +620,9 @@ func (o Options) Apply(opts Options) Options {
 	if opts.Scenarios != nil {
 		o.Scenarios = opts.Scenarios
 	}
+	if opts.ScenarioDefaults != nil {
+		o.ScenarioDefaults = opts.ScenarioDefaults
+	}
 	if opts.ExecutionSegment != nil {
 		o.ExecutionSegment = opts.ExecutionSegment
 	}
@@ -516,6 +699,21 @@ func (o Options) Apply(opts Options) Options {
 	if opts.NoCookiesReset.Valid {
 		o.NoCookiesReset = opts.NoCookiesReset
 	}
+	if opts.CheckFailureMessages.Valid {
+		o.CheckFailureMessages = opts.CheckFailureMessages
+	}
+	if opts.CheckFailureCaptureDir.Valid {
+		o.CheckFailureCaptureDir = opts.CheckFailureCaptureDir
+	}
+	if opts.CheckFailureCaptures.Valid {
+		o.CheckFailureCaptures = opts.CheckFailureCaptures
+	}
+	if opts.CheckFailureCaptureSize.Valid {
+		o.CheckFailureCaptureSize = opts.CheckFailureCaptureSize
+	}
+	if opts.AbortOnErrorRate != nil {
+		o.AbortOnErrorRate = opts.AbortOnErrorRate
+	}
 	if opts.External != nil {
 		o.External = opts.External
 	}
@@ -525,24 +723,70 @@ func (o Options) Apply(opts Options) Options {
 	if opts.SummaryTimeUnit.Valid {
 		o.SummaryTimeUnit = opts.SummaryTimeUnit
 	}
+	if opts.SummaryTimelineInterval.Valid {
+		o.SummaryTimelineInterval = opts.SummaryTimelineInterval
+	}
+	if opts.AnomalyDetectionInterval.Valid {
+		o.AnomalyDetectionInterval = opts.AnomalyDetectionInterval
+	}
+	if opts.ThresholdsEvaluationInterval.Valid {
+		o.ThresholdsEvaluationInterval = opts.ThresholdsEvaluationInterval
+	}
+	if opts.ThresholdsEvaluationDelay.Valid {
+		o.ThresholdsEvaluationDelay = opts.ThresholdsEvaluationDelay
+	}
+	if opts.TrendPrecision.Valid {
+		o.TrendPrecision = opts.TrendPrecision
+	}
+	if opts.TrendPercentileMethod.Valid {
+		o.TrendPercentileMethod = opts.TrendPercentileMethod
+	}
+	if opts.GaugeEWMAHalfLife.Valid {
+		o.GaugeEWMAHalfLife = opts.GaugeEWMAHalfLife
+	}
 	if opts.SystemTags != nil {
 		o.SystemTags = opts.SystemTags
 	}
 	if !opts.RunTags.IsEmpty() {
 		o.RunTags = opts.RunTags
 	}
+	if opts.TagTransforms != nil {
+		o.TagTransforms = opts.TagTransforms
+	}
+	if opts.MaxTagCombinations.Valid {
+		o.MaxTagCombinations = opts.MaxTagCombinations
+	}
+	if opts.Hooks.BeforeTest.Valid {
+		o.Hooks.BeforeTest = opts.Hooks.BeforeTest
+	}
+	if opts.Hooks.AfterTest.Valid {
+		o.Hooks.AfterTest = opts.Hooks.AfterTest
+	}
+	if opts.Hooks.OnAbort.Valid {
+		o.Hooks.OnAbort = opts.Hooks.OnAbort
+	}
+	o.Hooks.Valid = o.Hooks.Valid || opts.Hooks.Valid
 	if opts.MetricSamplesBufferSize.Valid {
 		o.MetricSamplesBufferSize = opts.MetricSamplesBufferSize
 	}
 	if opts.DiscardResponseBodies.Valid {
 		o.DiscardResponseBodies = opts.DiscardResponseBodies
 	}
+	if opts.IsolateSetupTeardownMetrics.Valid {
+		o.IsolateSetupTeardownMetrics = opts.IsolateSetupTeardownMetrics
+	}
+	if opts.TypedTags.Valid {
+		o.TypedTags = opts.TypedTags
+	}
 	if opts.ConsoleOutput.Valid {
 		o.ConsoleOutput = opts.ConsoleOutput
 	}
 	if opts.LocalIPs.Valid {
 		o.LocalIPs = opts.LocalIPs
 	}
+	if opts.Interface.Valid {
+		o.Interface = opts.Interface
+	}
 	if opts.DNS.TTL.Valid {
 		o.DNS.TTL = opts.DNS.TTL
 	}
@@ -552,6 +796,7 @@ func (o Options) Apply(opts Options) Options {
 	if opts.DNS.Policy.Valid {
 		o.DNS.Policy = opts.DNS.Policy
 	}
+	o.Socket = o.Socket.Apply(opts.Socket)
 
 	return o
 }
@@ -575,9 +820,71 @@ func (o Options) Validate() []error {
 					o.ExecutionSegment, o.ExecutionSegmentSequence))
 		}
 	}
+	if o.MaxTagCombinations.Int64 < 0 {
+		errors = append(errors, fmt.Errorf("the maxTagCombinations can't be negative"))
+	}
+	if o.SummaryTimelineInterval.Duration < 0 {
+		errors = append(errors, fmt.Errorf("the summaryTimelineInterval can't be negative"))
+	}
+	if o.AnomalyDetectionInterval.Duration < 0 {
+		errors = append(errors, fmt.Errorf("the anomalyDetectionInterval can't be negative"))
+	}
+	if o.ThresholdsEvaluationInterval.Valid && o.ThresholdsEvaluationInterval.Duration <= 0 {
+		errors = append(errors, fmt.Errorf("the thresholdsEvaluationInterval should be more than 0"))
+	}
+	if o.ThresholdsEvaluationDelay.Duration < 0 {
+		errors = append(errors, fmt.Errorf("the thresholdsEvaluationDelay can't be negative"))
+	}
+	if o.TrendPercentileMethod.Valid {
+		if _, err := stats.ParsePercentileMethod(o.TrendPercentileMethod.String); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if o.GaugeEWMAHalfLife.Valid && o.GaugeEWMAHalfLife.Duration <= 0 {
+		errors = append(errors, fmt.Errorf("the gaugeEWMAHalfLife should be more than 0"))
+	}
+	if o.TrendPrecision.Int64 < 0 || o.TrendPrecision.Int64 > stats.MaxTrendPrecision {
+		errors = append(errors, fmt.Errorf("the trendPrecision should be between 0 and %d significant digits",
+			stats.MaxTrendPrecision))
+	} else {
+		for name, thresholds := range o.Thresholds {
+			if err := thresholds.ValidatePercentiles(int(o.TrendPrecision.Int64)); err != nil {
+				errors = append(errors, fmt.Errorf("invalid threshold for %s: %w", name, err))
+			}
+		}
+	}
+	for name := range o.Thresholds {
+		if _, _, err := stats.ParseSubmetric(name); err != nil {
+			errors = append(errors, err)
+		}
+	}
+	if o.IsolateSetupTeardownMetrics.Bool && o.SystemTags != nil && !o.SystemTags.Has(stats.TagGroup) {
+		errors = append(errors, fmt.Errorf("the isolateSetupTeardownMetrics option needs the group system tag"))
+	}
+	errors = append(errors, o.Socket.Validate()...)
+	errors = append(errors, o.validateInterface()...)
 	return append(errors, o.Scenarios.Validate()...)
 }
 
+// validateInterface returns an error for the network interface of the test,
+// and the ones of the scenarios, if the LocalIPs are set too, as the
+// connections are opened from either of them.
+func (o Options) validateInterface() (errors []error) {
+	if !o.LocalIPs.Valid {
+		return nil
+	}
+	if o.Interface.String != "" {
+		errors = append(errors, fmt.Errorf("the interface can't be set along with the local IPs"))
+	}
+	for _, sc := range o.Scenarios.GetSortedConfigs() {
+		if ic, ok := sc.(interface{ GetInterface() string }); ok && ic.GetInterface() != "" {
+			errors = append(errors, fmt.Errorf(
+				"the interface of the scenario %s can't be set along with the local IPs", sc.GetName()))
+		}
+	}
+	return errors
+}
+
 // ForEachSpecified enumerates all struct fields and calls the supplied function with each
 // element that is valid. It panics for any unfamiliar or unexpected fields, so make sure
 // new fields in Options are accounted for.
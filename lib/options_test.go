@@ -301,6 +301,21 @@ func TestOptions(t *testing.T) {
 		assert.True(t, opts.NoCookiesReset.Valid)
 		assert.True(t, opts.NoCookiesReset.Bool)
 	})
+	t.Run("CheckFailureMessages", func(t *testing.T) {
+		opts := Options{}.Apply(Options{CheckFailureMessages: null.IntFrom(5)})
+		assert.True(t, opts.CheckFailureMessages.Valid)
+		assert.Equal(t, int64(5), opts.CheckFailureMessages.Int64)
+	})
+	t.Run("CheckFailureCaptures", func(t *testing.T) {
+		opts := Options{}.Apply(Options{
+			CheckFailureCaptureDir:  null.StringFrom("captures"),
+			CheckFailureCaptures:    null.IntFrom(5),
+			CheckFailureCaptureSize: null.IntFrom(1024),
+		})
+		assert.Equal(t, null.StringFrom("captures"), opts.CheckFailureCaptureDir)
+		assert.Equal(t, null.IntFrom(5), opts.CheckFailureCaptures)
+		assert.Equal(t, null.IntFrom(1024), opts.CheckFailureCaptureSize)
+	})
 	t.Run("BlacklistIPs", func(t *testing.T) {
 		opts := Options{}.Apply(Options{
 			BlacklistIPs: []*IPNet{{
@@ -362,6 +377,13 @@ func TestOptions(t *testing.T) {
 		}})
 		assert.NotNil(t, opts.Thresholds)
 		assert.NotEmpty(t, opts.Thresholds)
+
+		opts.Thresholds = map[string]stats.Thresholds{
+			"http_req_duration{name~:/api/v1/.*}": stats.NewThresholds([]string{"p(95)<500"}),
+		}
+		assert.Empty(t, opts.Validate())
+		opts.Thresholds["http_req_duration{name~:/api/(v1}"] = stats.NewThresholds([]string{"p(95)<500"})
+		assert.Len(t, opts.Validate(), 1)
 	})
 	t.Run("External", func(t *testing.T) {
 		ext := map[string]json.RawMessage{"a": json.RawMessage("1")}
@@ -416,6 +438,178 @@ func TestOptions(t *testing.T) {
 		opts := Options{}.Apply(Options{RunTags: tags})
 		assert.Equal(t, tags, opts.RunTags)
 	})
+	t.Run("TagTransforms", func(t *testing.T) {
+		tt, err := stats.NewTagTransform("truncate(10)")
+		require.NoError(t, err)
+		transforms := stats.TagTransforms{"url": tt}
+		opts := Options{}.Apply(Options{TagTransforms: transforms})
+		assert.Equal(t, transforms, opts.TagTransforms)
+
+		t.Run("JSON", func(t *testing.T) {
+			var opts Options
+			jsonStr := `{"tagTransforms":{"url":"truncate(10)|hash","status":"class"}}`
+			require.NoError(t, json.Unmarshal([]byte(jsonStr), &opts))
+			require.Len(t, opts.TagTransforms, 2)
+			assert.Equal(t, "truncate(10)|hash", opts.TagTransforms["url"].String())
+			assert.Equal(t, "class", opts.TagTransforms["status"].String())
+
+			data, err := json.Marshal(opts.TagTransforms)
+			require.NoError(t, err)
+			assert.JSONEq(t, `{"url":"truncate(10)|hash","status":"class"}`, string(data))
+
+			assert.Error(t, json.Unmarshal([]byte(`{"tagTransforms":{"url":"shorten"}}`), &opts))
+		})
+	})
+	t.Run("AbortOnErrorRate", func(t *testing.T) {
+		limit := &types.ErrorRateLimit{Threshold: 0.05, Window: types.Duration(30 * time.Second), MinRequests: 10}
+		opts := Options{}.Apply(Options{AbortOnErrorRate: limit})
+		assert.Equal(t, limit, opts.AbortOnErrorRate)
+		opts = opts.Apply(Options{})
+		assert.Equal(t, limit, opts.AbortOnErrorRate)
+
+		t.Run("JSON", func(t *testing.T) {
+			var opts Options
+			jsonStr := `{"abortOnErrorRate":{"threshold":"5%","window":"30s"}}`
+			require.NoError(t, json.Unmarshal([]byte(jsonStr), &opts))
+			assert.Equal(t, limit, opts.AbortOnErrorRate)
+		})
+	})
+	t.Run("Socket", func(t *testing.T) {
+		opts := Options{Socket: SocketOptions{NoDelay: null.BoolFrom(false), SendBuffer: null.IntFrom(1024), Valid: true}}
+		opts = opts.Apply(Options{Socket: SocketOptions{
+			SendBuffer: null.IntFrom(4096), KeepAlive: types.NullDurationFrom(time.Minute), Valid: true,
+		}})
+		assert.Equal(t, SocketOptions{
+			NoDelay:    null.BoolFrom(false),
+			SendBuffer: null.IntFrom(4096),
+			KeepAlive:  types.NullDurationFrom(time.Minute),
+			Valid:      true,
+		}, opts.Socket)
+		assert.Empty(t, opts.Validate())
+
+		opts = opts.Apply(Options{Socket: SocketOptions{ReceiveBuffer: null.IntFrom(0), Valid: true}})
+		assert.Len(t, opts.Validate(), 1)
+
+		t.Run("JSON", func(t *testing.T) {
+			var opts Options
+			jsonStr := `{"socket":{"noDelay":false,"receiveBuffer":65536,"keepAlive":"0s"}}`
+			require.NoError(t, json.Unmarshal([]byte(jsonStr), &opts))
+			assert.Equal(t, SocketOptions{
+				NoDelay:       null.BoolFrom(false),
+				ReceiveBuffer: null.IntFrom(65536),
+				KeepAlive:     types.NullDurationFrom(0),
+				Valid:         true,
+			}, opts.Socket)
+		})
+	})
+	t.Run("Hooks", func(t *testing.T) {
+		opts := Options{Hooks: Hooks{BeforeTest: null.StringFrom("make env"), OnAbort: null.StringFrom("notify")}}
+		opts = opts.Apply(Options{Hooks: Hooks{
+			BeforeTest: null.StringFrom("make up"), AfterTest: null.StringFrom("make down"), Valid: true,
+		}})
+		assert.Equal(t, Hooks{
+			BeforeTest: null.StringFrom("make up"),
+			AfterTest:  null.StringFrom("make down"),
+			OnAbort:    null.StringFrom("notify"),
+			Valid:      true,
+		}, opts.Hooks)
+		assert.False(t, opts.Hooks.IsEmpty())
+		assert.True(t, Options{}.Hooks.IsEmpty())
+
+		t.Run("JSON", func(t *testing.T) {
+			var opts Options
+			jsonStr := `{"hooks":{"beforeTest":"./prepare.sh","onAbort":"./cleanup.sh"}}`
+			require.NoError(t, json.Unmarshal([]byte(jsonStr), &opts))
+			assert.Equal(t, Hooks{
+				BeforeTest: null.StringFrom("./prepare.sh"),
+				OnAbort:    null.StringFrom("./cleanup.sh"),
+				Valid:      true,
+			}, opts.Hooks)
+		})
+	})
+	t.Run("SummaryTimelineInterval", func(t *testing.T) {
+		opts := Options{}.Apply(Options{SummaryTimelineInterval: types.NullDurationFrom(time.Minute)})
+		assert.Equal(t, types.NullDurationFrom(time.Minute), opts.SummaryTimelineInterval)
+		assert.Empty(t, opts.Validate())
+		opts.SummaryTimelineInterval = types.NullDurationFrom(-time.Second)
+		assert.Len(t, opts.Validate(), 1)
+	})
+	t.Run("AnomalyDetectionInterval", func(t *testing.T) {
+		opts := Options{}.Apply(Options{AnomalyDetectionInterval: types.NullDurationFrom(time.Minute)})
+		assert.Equal(t, types.NullDurationFrom(time.Minute), opts.AnomalyDetectionInterval)
+		assert.Empty(t, opts.Validate())
+		opts.AnomalyDetectionInterval = types.NullDurationFrom(-time.Second)
+		assert.Len(t, opts.Validate(), 1)
+	})
+	t.Run("MaxTagCombinations", func(t *testing.T) {
+		opts := Options{}.Apply(Options{MaxTagCombinations: null.IntFrom(1000)})
+		assert.Equal(t, null.IntFrom(1000), opts.MaxTagCombinations)
+		assert.Empty(t, opts.Validate())
+		opts.MaxTagCombinations = null.IntFrom(-1)
+		assert.Len(t, opts.Validate(), 1)
+	})
+	t.Run("ThresholdsEvaluation", func(t *testing.T) {
+		opts := Options{}.Apply(Options{
+			ThresholdsEvaluationInterval: types.NullDurationFrom(500 * time.Millisecond),
+			ThresholdsEvaluationDelay:    types.NullDurationFrom(time.Minute),
+		})
+		assert.Equal(t, types.NullDurationFrom(500*time.Millisecond), opts.ThresholdsEvaluationInterval)
+		assert.Equal(t, types.NullDurationFrom(time.Minute), opts.ThresholdsEvaluationDelay)
+		assert.Empty(t, opts.Validate())
+		opts.ThresholdsEvaluationInterval = types.NullDurationFrom(0)
+		opts.ThresholdsEvaluationDelay = types.NullDurationFrom(-time.Second)
+		assert.Len(t, opts.Validate(), 2)
+	})
+	t.Run("ThresholdPercentiles", func(t *testing.T) {
+		opts := Options{Thresholds: map[string]stats.Thresholds{
+			"http_req_duration": stats.NewThresholds([]string{"p(99.99)<500"}),
+		}}
+		assert.Empty(t, opts.Validate())
+		opts.Thresholds["http_req_duration"] = stats.NewThresholds([]string{"p(101)<500"})
+		assert.Len(t, opts.Validate(), 1)
+	})
+	t.Run("TrendPrecision", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TrendPrecision: null.IntFrom(4)})
+		assert.Equal(t, null.IntFrom(4), opts.TrendPrecision)
+
+		opts.Thresholds = map[string]stats.Thresholds{
+			"http_req_duration": stats.NewThresholds([]string{"p(99.99)<500"}),
+		}
+		assert.Empty(t, opts.Validate())
+		opts.TrendPrecision = null.IntFrom(3)
+		assert.Len(t, opts.Validate(), 1)
+		opts.TrendPrecision = null.IntFrom(stats.MaxTrendPrecision + 1)
+		assert.Len(t, opts.Validate(), 1)
+	})
+	t.Run("GaugeEWMAHalfLife", func(t *testing.T) {
+		opts := Options{}.Apply(Options{GaugeEWMAHalfLife: types.NullDurationFrom(time.Minute)})
+		assert.Equal(t, types.NullDurationFrom(time.Minute), opts.GaugeEWMAHalfLife)
+		assert.Empty(t, opts.Validate())
+		opts.GaugeEWMAHalfLife = types.NullDurationFrom(0)
+		assert.Len(t, opts.Validate(), 1)
+	})
+	t.Run("TrendPercentileMethod", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TrendPercentileMethod: null.StringFrom("nearest-rank")})
+		assert.Equal(t, null.StringFrom("nearest-rank"), opts.TrendPercentileMethod)
+		assert.Empty(t, opts.Validate())
+		opts.TrendPercentileMethod = null.StringFrom("nearest")
+		assert.Len(t, opts.Validate(), 1)
+	})
+	t.Run("IsolateSetupTeardownMetrics", func(t *testing.T) {
+		opts := Options{}.Apply(Options{IsolateSetupTeardownMetrics: null.BoolFrom(true)})
+		assert.True(t, opts.IsolateSetupTeardownMetrics.Valid)
+		assert.True(t, opts.IsolateSetupTeardownMetrics.Bool)
+		assert.Empty(t, opts.Validate())
+		opts.SystemTags = stats.ToSystemTagSet([]string{"method", "status"})
+		errs := opts.Validate()
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "needs the group system tag")
+	})
+	t.Run("TypedTags", func(t *testing.T) {
+		opts := Options{}.Apply(Options{TypedTags: null.BoolFrom(true)})
+		assert.True(t, opts.TypedTags.Valid)
+		assert.True(t, opts.TypedTags.Bool)
+	})
 	t.Run("DiscardResponseBodies", func(t *testing.T) {
 		opts := Options{}.Apply(Options{DiscardResponseBodies: null.BoolFrom(true)})
 		assert.True(t, opts.DiscardResponseBodies.Valid)
@@ -426,6 +620,9 @@ func TestOptions(t *testing.T) {
 		require.NoError(t, err)
 		opts := Options{}.Apply(Options{LocalIPs: types.NullIPPool{Pool: clientIPRanges, Valid: true}})
 		assert.NotNil(t, opts.LocalIPs)
+		assert.Empty(t, opts.Validate())
+		opts.Interface = null.StringFrom("eth1")
+		assert.Len(t, opts.Validate(), 1)
 	})
 }
 
@@ -521,6 +718,19 @@ func TestOptionsEnv(t *testing.T) {
 			"true":  null.BoolFrom(true),
 			"false": null.BoolFrom(false),
 		},
+		{"CheckFailureMessages", "K6_CHECK_FAILURE_MESSAGES"}: {
+			"":  null.Int{},
+			"0": null.IntFrom(0),
+			"5": null.IntFrom(5),
+		},
+		{"CheckFailureCaptureDir", "K6_CHECK_FAILURE_CAPTURE_DIR"}: {
+			"":         null.String{},
+			"captures": null.StringFrom("captures"),
+		},
+		{"CheckFailureCaptures", "K6_CHECK_FAILURE_CAPTURES"}: {
+			"":   null.Int{},
+			"20": null.IntFrom(20),
+		},
 		// Thresholds
 		// External
 	}
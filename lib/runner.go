@@ -22,6 +22,7 @@ package lib
 
 import (
 	"context"
+	"encoding/json"
 	"io"
 	"time"
 
@@ -55,7 +56,18 @@ type VUActivationParams struct {
 	DeactivateCallback       func(InitializedVU)
 	Env, Tags                map[string]string
 	Exec, Scenario           string
+	Args                     json.RawMessage
 	GetNextIterationCounters func() (uint64, uint64)
+	// PrewarmConnections is how many connections the VU should open to the
+	// host of each of the PrewarmURLs before its first iteration.
+	PrewarmConnections int
+	PrewarmURLs        []string
+	// Socket are the socket options of the scenario, which override the
+	// ones of the test.
+	Socket SocketOptions
+	// Interface is the name of the network interface of the scenario, which
+	// overrides the one of the test if it's set.
+	Interface string
 }
 
 // A Runner is a factory for VUs. It should precompute as much as possible upon
@@ -118,4 +130,31 @@ type Summary struct {
 	TestRunDuration time.Duration // TODO: use lib.ExecutionState-based interface instead?
 	NoColor         bool          // TODO: drop this when noColor is part of the (runtime) options
 	UIState         UIState
+	Metadata        map[string]string // the run-level metadata of the environment, see envinfo
+
+	// CoordinatedOmission is how many synthetic samples were back-filled in
+	// the metrics corrected for coordinated omission.
+	CoordinatedOmission map[string]int64
+
+	// Timeline is the coarse time series of the key metrics, nil if the
+	// summaryTimelineInterval option is 0.
+	Timeline *SummaryTimeline
+
+	// Anomalies are the EventAnomalyDetected events of the test run, in
+	// order, empty if the anomalyDetectionInterval option is 0.
+	Anomalies []Event
+}
+
+// SummaryTimeline has the values of the key metrics over fixed intervals.
+type SummaryTimeline struct {
+	Interval time.Duration
+	// Metrics are the intervals with samples of every metric, in order.
+	Metrics map[string][]SummaryTimelinePoint
+}
+
+// SummaryTimelinePoint has the values of a metric during the interval which
+// starts at Time, as formatted by its sink.
+type SummaryTimelinePoint struct {
+	Time   time.Time
+	Values map[string]float64
 }
@@ -21,10 +21,13 @@
 package lib
 
 import (
+	"encoding/json"
 	"fmt"
 	"strings"
 
 	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
 )
 
 // CompatibilityMode specifies the JS compatibility mode
@@ -37,6 +40,10 @@ const (
 	CompatibilityModeExtended CompatibilityMode = iota + 1
 	// CompatibilityModeBase is standard goja ES5.1+
 	CompatibilityModeBase
+	// CompatibilityModeExperimentalEnhanced runs the ES modules with goja by
+	// rewriting only their imports and exports, and falls back to Babel, like
+	// CompatibilityModeExtended, for the rest
+	CompatibilityModeExperimentalEnhanced
 )
 
 // RuntimeOptions are settings passed onto the goja JS runtime
@@ -44,7 +51,8 @@ type RuntimeOptions struct {
 	// Whether to pass the actual system environment variables to the JS runtime
 	IncludeSystemEnvVars null.Bool `json:"includeSystemEnvVars"`
 
-	// JS compatibility mode: "extended" (Goja+Babel) or "base" (plain Goja)
+	// JS compatibility mode: "extended" (Goja+Babel), "base" (plain Goja) or
+	// "experimental_enhanced" (Goja, with Babel only when it's needed)
 	//
 	// TODO: when we resolve https://github.com/k6io/k6/issues/883, we probably
 	// should use the CompatibilityMode type directly... but by then, we'd need to have
@@ -55,9 +63,49 @@ type RuntimeOptions struct {
 	// Environment variables passed onto the runner
 	Env map[string]string `json:"env"`
 
-	NoThresholds  null.Bool   `json:"noThresholds"`
-	NoSummary     null.Bool   `json:"noSummary"`
-	SummaryExport null.String `json:"summaryExport"`
+	// The arguments of the script, given with --script-arg, which it gets
+	// with their types in exec.test.args of k6/execution
+	ScriptArgs map[string]string `json:"scriptArgs"`
+
+	NoThresholds null.Bool `json:"noThresholds"`
+	NoSummary    null.Bool `json:"noSummary"`
+
+	// The files the end-of-test summary is saved to, as [format=]path, see
+	// ParseSummaryExport
+	SummaryExport SummaryExportPaths `json:"summaryExport"`
+
+	// Whether the script is allowed to execute local commands with the
+	// k6/experimental/exec module
+	AllowExec null.Bool `json:"allowExec"`
+
+	// Whether to detect the metadata of the environment, like the cloud
+	// provider and the Kubernetes pod, see the envinfo package. It's off by
+	// default, as it queries the instance metadata service of the cloud
+	// provider and exposes the hostname.
+	EnvMetadata null.Bool `json:"envMetadata"`
+
+	// The offset of the local clock, added to the timestamps of all the
+	// samples so they're aligned with the ones of the other instances of k6.
+	// It's either given, e.g. by whatever coordinates the instances, or it's
+	// measured with the NTPServer.
+	ClockOffset types.NullDuration `json:"clockOffset"`
+	NTPServer   null.String        `json:"ntpServer"`
+
+	// Whether to log the time and memory each module took to compile and
+	// evaluate in the init context
+	VerboseInit null.Bool `json:"verboseInit"`
+
+	// Whether the unknown keys in the options of the script and of the
+	// config file are an error, instead of a warning
+	StrictOptions null.Bool `json:"strictOptions"`
+
+	// Whether the global variables created by the iterations of a VU, which
+	// are shared by all of its iterations, are reported
+	StrictGlobals null.Bool `json:"strictGlobals"`
+
+	// Whether the test doesn't start if it may need more sockets than the
+	// limit of open files or the ephemeral ports allow, instead of a warning
+	StrictPreflight null.Bool `json:"strictPreflight"`
 }
 
 // ValidateCompatibilityMode checks if the provided val is a valid compatibility mode
@@ -75,3 +123,86 @@ func ValidateCompatibilityMode(val string) (cm CompatibilityMode, err error) {
 	}
 	return
 }
+
+// SummaryExportFormats are the formats the end-of-test summary can be saved in
+// with --summary-export, besides the handleSummary() callback.
+//nolint:gochecknoglobals
+var SummaryExportFormats = []string{"json", "junit", "html", "markdown"}
+
+// SummaryExport is a file the end-of-test summary is saved to.
+type SummaryExport struct {
+	Format string
+	Path   string
+}
+
+// SummaryExportPaths are the values of --summary-export. It was a single
+// path before, saved as a string in the config files and the archives, so a
+// single value is still marshaled as a string, and a string is unmarshaled.
+type SummaryExportPaths []string
+
+// MarshalJSON saves a single value as a string, as the older versions did.
+func (p SummaryExportPaths) MarshalJSON() ([]byte, error) {
+	if len(p) == 1 {
+		return json.Marshal(p[0])
+	}
+	return json.Marshal([]string(p))
+}
+
+// UnmarshalJSON accepts either a string, as the older versions saved, or an
+// array of strings.
+func (p *SummaryExportPaths) UnmarshalJSON(data []byte) error {
+	var path null.String
+	if err := json.Unmarshal(data, &path); err == nil {
+		*p = nil
+		if path.Valid && path.String != "" {
+			*p = SummaryExportPaths{path.String}
+		}
+		return nil
+	}
+	var paths []string
+	if err := json.Unmarshal(data, &paths); err != nil {
+		return err
+	}
+	*p = paths
+	return nil
+}
+
+// ParseSummaryExport parses a summary export given as format=path, or just as
+// a path, which is saved as json, whatever its extension is. The part before
+// the first = is only the format if it's one of the SummaryExportFormats, so
+// the paths with a = in them, e.g. out=summary.json, are still paths.
+func ParseSummaryExport(s string) (SummaryExport, error) {
+	if i := strings.IndexByte(s, '='); i > 0 && isSummaryExportFormat(s[:i]) {
+		format, path := s[:i], s[i+1:]
+		if path == "" {
+			return SummaryExport{}, fmt.Errorf("the %s summary export is missing the path", format)
+		}
+		return SummaryExport{Format: format, Path: path}, nil
+	}
+	if s == "" {
+		return SummaryExport{}, fmt.Errorf("the summary export path is empty")
+	}
+	return SummaryExport{Format: "json", Path: s}, nil
+}
+
+// GetSummaryExports parses all of the SummaryExport values.
+func (o RuntimeOptions) GetSummaryExports() ([]SummaryExport, error) {
+	exports := make([]SummaryExport, 0, len(o.SummaryExport))
+	for _, s := range o.SummaryExport {
+		export, err := ParseSummaryExport(s)
+		if err != nil {
+			return nil, err
+		}
+		exports = append(exports, export)
+	}
+	return exports, nil
+}
+
+func isSummaryExportFormat(format string) bool {
+	for _, f := range SummaryExportFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,81 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package lib
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSummaryExport(t *testing.T) {
+	t.Parallel()
+	testCases := map[string]SummaryExport{
+		"summary.json":        {Format: "json", Path: "summary.json"},
+		"summary":             {Format: "json", Path: "summary"},
+		"results/junit.xml":   {Format: "json", Path: "results/junit.xml"},
+		"report.html":         {Format: "json", Path: "report.html"},
+		"junit=junit.xml":     {Format: "junit", Path: "junit.xml"},
+		"markdown=report.txt": {Format: "markdown", Path: "report.txt"},
+		"json=a=b.json":       {Format: "json", Path: "a=b.json"},
+		"run-id=5.json":       {Format: "json", Path: "run-id=5.json"},
+		"out=summary.json":    {Format: "json", Path: "out=summary.json"},
+		"pdf=summary.pdf":     {Format: "json", Path: "pdf=summary.pdf"},
+	}
+	for input, expected := range testCases {
+		export, err := ParseSummaryExport(input)
+		require.NoError(t, err, input)
+		assert.Equal(t, expected, export, input)
+	}
+
+	for _, input := range []string{"", "junit="} {
+		_, err := ParseSummaryExport(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestSummaryExportPathsJSON(t *testing.T) {
+	t.Parallel()
+	testCases := map[string]SummaryExportPaths{
+		`null`:                      nil,
+		`""`:                        nil,
+		`"summary.json"`:            {"summary.json"},
+		`["summary.json"]`:          {"summary.json"},
+		`["a.json", "junit=b.xml"]`: {"a.json", "junit=b.xml"},
+	}
+	for data, expected := range testCases {
+		var paths SummaryExportPaths
+		require.NoError(t, json.Unmarshal([]byte(data), &paths), data)
+		assert.Equal(t, expected, paths, data)
+	}
+
+	data, err := json.Marshal(SummaryExportPaths{"summary.json"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `"summary.json"`, string(data))
+	data, err = json.Marshal(SummaryExportPaths{"a.json", "junit=b.xml"})
+	require.NoError(t, err)
+	assert.JSONEq(t, `["a.json", "junit=b.xml"]`, string(data))
+
+	var paths SummaryExportPaths
+	assert.Error(t, json.Unmarshal([]byte(`5`), &paths))
+}
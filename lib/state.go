@@ -26,12 +26,15 @@ import (
 	"net"
 	"net/http"
 	"net/http/cookiejar"
+	"strconv"
+	"strings"
 	"sync"
 
 	"github.com/oxtoacart/bpool"
 	"github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 
+	"go.k6.io/k6/lib/checkcapture"
 	"go.k6.io/k6/lib/metrics"
 	"go.k6.io/k6/stats"
 )
@@ -88,6 +91,10 @@ type State struct {
 	GetScenarioGlobalVUIter func() uint64
 
 	BuiltinMetrics *metrics.BuiltinMetrics
+
+	// Saves the requests and responses that made checks fail, nil if that's
+	// not enabled.
+	CheckFailureCapturer *checkcapture.Capturer
 }
 
 // CloneTags makes a copy of the tags map and returns it.
@@ -144,6 +151,44 @@ func (tg *TagMap) Delete(k string) {
 	delete(tg.m, k)
 }
 
+// SetGroupLevels sets a group_l1, group_l2, etc. tag to every group of the
+// path, from the outermost one, and deletes the ones of deeper groups, e.g.
+// the ones set for a group which was left.
+func (tg *TagMap) SetGroupLevels(path string) {
+	tg.mutex.Lock()
+	defer tg.mutex.Unlock()
+
+	level := 0
+	for _, name := range strings.Split(path, GroupSeparator) {
+		if name == "" {
+			continue // the root group
+		}
+		level++
+		tg.m["group_l"+strconv.Itoa(level)] = name
+	}
+	for {
+		level++
+		key := "group_l" + strconv.Itoa(level)
+		if _, ok := tg.m[key]; !ok {
+			return
+		}
+		delete(tg.m, key)
+	}
+}
+
+// SetGroupName sets the group_name tag to the name of the innermost group of
+// the path, or deletes it for the root group.
+func (tg *TagMap) SetGroupName(path string) {
+	tg.mutex.Lock()
+	defer tg.mutex.Unlock()
+
+	if path == "" {
+		delete(tg.m, "group_name")
+		return
+	}
+	tg.m["group_name"] = path[strings.LastIndex(path, GroupSeparator)+len(GroupSeparator):]
+}
+
 // Clone returns a map with the entire set of items.
 func (tg *TagMap) Clone() map[string]string {
 	tg.mutex.RLock()
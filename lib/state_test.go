@@ -102,3 +102,36 @@ func TestTagMapClone(t *testing.T) {
 		"key2": "value2",
 	}, m)
 }
+
+func TestTagMapSetGroupLevels(t *testing.T) {
+	t.Parallel()
+	tm := NewTagMap(map[string]string{"group": "::checkout::payment"})
+	tm.SetGroupLevels("::checkout::payment")
+	assert.Equal(t, map[string]string{
+		"group":    "::checkout::payment",
+		"group_l1": "checkout",
+		"group_l2": "payment",
+	}, tm.Clone())
+
+	tm.SetGroupLevels("::login")
+	assert.Equal(t, map[string]string{
+		"group":    "::checkout::payment",
+		"group_l1": "login",
+	}, tm.Clone())
+
+	tm.SetGroupLevels("")
+	assert.Equal(t, map[string]string{"group": "::checkout::payment"}, tm.Clone())
+}
+
+func TestTagMapSetGroupName(t *testing.T) {
+	t.Parallel()
+	tm := NewTagMap(nil)
+	tm.SetGroupName("::checkout::payment")
+	assert.Equal(t, map[string]string{"group_name": "payment"}, tm.Clone())
+
+	tm.SetGroupName("::login")
+	assert.Equal(t, map[string]string{"group_name": "login"}, tm.Clone())
+
+	tm.SetGroupName("")
+	assert.Equal(t, map[string]string{}, tm.Clone())
+}
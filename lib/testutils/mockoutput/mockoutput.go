@@ -37,13 +37,17 @@ type MockOutput struct {
 	SampleContainers []stats.SampleContainer
 	Samples          []stats.Sample
 	RunStatus        lib.RunStatus
+	Events           []lib.Event
 
 	DescFn  func() string
 	StartFn func() error
 	StopFn  func() error
 }
 
-var _ output.WithRunStatusUpdates = &MockOutput{}
+var (
+	_ output.WithRunStatusUpdates = &MockOutput{}
+	_ output.WithEvents           = &MockOutput{}
+)
 
 // AddMetricSamples just saves the results in memory.
 func (mo *MockOutput) AddMetricSamples(scs []stats.SampleContainer) {
@@ -53,6 +57,11 @@ func (mo *MockOutput) AddMetricSamples(scs []stats.SampleContainer) {
 	}
 }
 
+// AddEvent just saves the event in memory.
+func (mo *MockOutput) AddEvent(event lib.Event) {
+	mo.Events = append(mo.Events, event)
+}
+
 // SetRunStatus updates the RunStatus property.
 func (mo *MockOutput) SetRunStatus(latestStatus lib.RunStatus) {
 	mo.RunStatus = latestStatus
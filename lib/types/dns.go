@@ -69,6 +69,9 @@ const (
 	DNSonlyIPv6
 	// DNSany returns any resolved address regardless of version.
 	DNSany
+	// DNShappyEyeballs returns the IPv6 and IPv4 addresses, which the
+	// connections are raced to as in RFC 8305, starting with IPv6.
+	DNShappyEyeballs
 )
 
 // UnmarshalJSON converts JSON data to a valid DNSPolicy
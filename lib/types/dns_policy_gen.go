@@ -7,9 +7,9 @@ import (
 	"fmt"
 )
 
-const _DNSPolicyName = "preferIPv4preferIPv6onlyIPv4onlyIPv6any"
+const _DNSPolicyName = "preferIPv4preferIPv6onlyIPv4onlyIPv6anyhappyEyeballs"
 
-var _DNSPolicyIndex = [...]uint8{0, 10, 20, 28, 36, 39}
+var _DNSPolicyIndex = [...]uint8{0, 10, 20, 28, 36, 39, 52}
 
 func (i DNSPolicy) String() string {
 	i -= 1
@@ -19,7 +19,7 @@ func (i DNSPolicy) String() string {
 	return _DNSPolicyName[_DNSPolicyIndex[i]:_DNSPolicyIndex[i+1]]
 }
 
-var _DNSPolicyValues = []DNSPolicy{1, 2, 3, 4, 5}
+var _DNSPolicyValues = []DNSPolicy{1, 2, 3, 4, 5, 6}
 
 var _DNSPolicyNameToValueMap = map[string]DNSPolicy{
 	_DNSPolicyName[0:10]:  1,
@@ -27,6 +27,7 @@ var _DNSPolicyNameToValueMap = map[string]DNSPolicy{
 	_DNSPolicyName[20:28]: 3,
 	_DNSPolicyName[28:36]: 4,
 	_DNSPolicyName[36:39]: 5,
+	_DNSPolicyName[39:52]: 6,
 }
 
 // DNSPolicyString retrieves an enum value from the enum constants string name.
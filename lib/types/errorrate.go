@@ -0,0 +1,122 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// DefaultErrorRateMinRequests is how many requests there have to be in the
+// window before the error rate is checked, if minRequests isn't specified, so
+// the first failures don't stop the test.
+const DefaultErrorRateMinRequests = 10
+
+// ErrorRateLimit is the maximum rate of failed HTTP requests in a sliding
+// window of time, after which a scenario or the whole test is stopped.
+type ErrorRateLimit struct {
+	// The rate of failed requests, between 0 and 1.
+	Threshold   float64
+	Window      Duration
+	MinRequests int64
+}
+
+type errorRateLimitJSON struct {
+	Threshold   json.RawMessage `json:"threshold"`
+	Window      Duration        `json:"window"`
+	MinRequests null.Int        `json:"minRequests"`
+}
+
+// UnmarshalJSON parses a JSON object with the threshold, as a number or a
+// percentage like "5%", the window and optionally minRequests.
+func (l *ErrorRateLimit) UnmarshalJSON(data []byte) error {
+	var raw errorRateLimitJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return err
+	}
+	threshold, err := parseErrorRateThreshold(raw.Threshold)
+	if err != nil {
+		return err
+	}
+	if raw.Window <= 0 {
+		return errors.New("the window of the error rate limit should be a positive duration")
+	}
+	minRequests := raw.MinRequests.ValueOrZero()
+	if !raw.MinRequests.Valid {
+		minRequests = DefaultErrorRateMinRequests
+	}
+	*l = ErrorRateLimit{Threshold: threshold, Window: raw.Window, MinRequests: minRequests}
+	return nil
+}
+
+func parseErrorRateThreshold(data json.RawMessage) (float64, error) {
+	var threshold float64
+	var str string
+	switch {
+	case len(data) == 0:
+		return 0, errors.New("the error rate limit is missing the threshold")
+	case json.Unmarshal(data, &str) == nil:
+		var err error
+		if strings.HasSuffix(str, "%") {
+			threshold, err = strconv.ParseFloat(strings.TrimSuffix(str, "%"), 64)
+			threshold /= 100
+		} else {
+			threshold, err = strconv.ParseFloat(str, 64)
+		}
+		if err != nil {
+			return 0, fmt.Errorf("invalid error rate threshold %q", str)
+		}
+	default:
+		if err := json.Unmarshal(data, &threshold); err != nil {
+			return 0, fmt.Errorf("invalid error rate threshold %s", data)
+		}
+	}
+	if threshold <= 0 || threshold > 1 {
+		return 0, fmt.Errorf("the error rate threshold should be between 0%% and 100%%, but it's %s", data)
+	}
+	return threshold, nil
+}
+
+// MarshalJSON returns the JSON object of the limit, with the threshold as a
+// number.
+func (l ErrorRateLimit) MarshalJSON() ([]byte, error) {
+	threshold, err := json.Marshal(l.Threshold)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(errorRateLimitJSON{
+		Threshold:   threshold,
+		Window:      l.Window,
+		MinRequests: null.IntFrom(l.MinRequests),
+	})
+}
+
+// String returns a human-readable description of the limit.
+func (l ErrorRateLimit) String() string {
+	return fmt.Sprintf("%s%% errors in %s", strconv.FormatFloat(l.Threshold*100, 'f', -1, 64),
+		time.Duration(l.Window))
+}
@@ -0,0 +1,63 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package types
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/guregu/null.v3"
+)
+
+// FailureLimit is how many failed checks or failed HTTP requests there can be,
+// after which a scenario is stopped, without failing the whole test.
+type FailureLimit struct {
+	Checks   null.Int `json:"checks"`
+	Requests null.Int `json:"requests"`
+}
+
+// Validate returns the errors of a limit without any counts, or with counts
+// which aren't positive.
+func (l FailureLimit) Validate() (errs []error) {
+	if !l.Checks.Valid && !l.Requests.Valid {
+		errs = append(errs, errors.New("the failure limit should have the failed checks or requests"))
+	}
+	if l.Checks.Valid && l.Checks.Int64 <= 0 {
+		errs = append(errs, errors.New("the failed checks of the failure limit should be positive"))
+	}
+	if l.Requests.Valid && l.Requests.Int64 <= 0 {
+		errs = append(errs, errors.New("the failed requests of the failure limit should be positive"))
+	}
+	return errs
+}
+
+// String returns a human-readable description of the limit.
+func (l FailureLimit) String() string {
+	var limits []string
+	if l.Checks.Valid {
+		limits = append(limits, fmt.Sprintf("%d failed checks", l.Checks.Int64))
+	}
+	if l.Requests.Valid {
+		limits = append(limits, fmt.Sprintf("%d failed requests", l.Requests.Int64))
+	}
+	return strings.Join(limits, " or ")
+}
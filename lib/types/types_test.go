@@ -28,6 +28,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseExtendedDuration(t *testing.T) {
@@ -229,3 +230,53 @@ func TestGetDurationValue(t *testing.T) {
 		})
 	}
 }
+
+func TestErrorRateLimit(t *testing.T) {
+	t.Parallel()
+	testCases := map[string]struct {
+		data     string
+		expected ErrorRateLimit
+		err      string
+	}{
+		"percent": {
+			data:     `{"threshold": "5%", "window": "30s"}`,
+			expected: ErrorRateLimit{Threshold: 0.05, Window: Duration(30 * time.Second), MinRequests: 10},
+		},
+		"number": {
+			data:     `{"threshold": 0.5, "window": 1000, "minRequests": 1}`,
+			expected: ErrorRateLimit{Threshold: 0.5, Window: Duration(time.Second), MinRequests: 1},
+		},
+		"no threshold": {data: `{"window": "30s"}`, err: "the error rate limit is missing the threshold"},
+		"threshold over 100%": {
+			data: `{"threshold": "150%", "window": "30s"}`,
+			err:  `the error rate threshold should be between 0% and 100%, but it's "150%"`,
+		},
+		"invalid threshold": {data: `{"threshold": "lots", "window": "30s"}`, err: `invalid error rate threshold "lots"`},
+		"no window": {
+			data: `{"threshold": "5%"}`,
+			err:  "the window of the error rate limit should be a positive duration",
+		},
+	}
+	for name, tc := range testCases {
+		tc := tc
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			var limit ErrorRateLimit
+			err := json.Unmarshal([]byte(tc.data), &limit)
+			if tc.err != "" {
+				assert.EqualError(t, err, tc.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, limit)
+
+			data, err := json.Marshal(limit)
+			require.NoError(t, err)
+			var again ErrorRateLimit
+			require.NoError(t, json.Unmarshal(data, &again))
+			assert.Equal(t, limit, again)
+		})
+	}
+	assert.Equal(t, "5% errors in 30s",
+		ErrorRateLimit{Threshold: 0.05, Window: Duration(30 * time.Second)}.String())
+}
@@ -33,7 +33,7 @@ func CreateFilesystems() map[string]afero.Fs {
 	// We want to eliminate disk access at runtime, so we set up a memory mapped cache that's
 	// written every time something is read from the real filesystem. This cache is then used for
 	// successive spawns to read from (they have no access to the real disk).
-	// Also initialize the same for `https` but the caching is handled manually in the loader package
+	// Also initialize the same for `https`, `s3` and `gs` but the caching is handled manually in the loader package
 	osfs := afero.NewOsFs()
 	if runtime.GOOS == "windows" {
 		// This is done so that we can continue to use paths with /|"\" through the code but also to
@@ -41,8 +41,12 @@ func CreateFilesystems() map[string]afero.Fs {
 		// volumes
 		osfs = fsext.NewTrimFilePathSeparatorFs(osfs)
 	}
-	return map[string]afero.Fs{
-		"file":  fsext.NewCacheOnReadFs(osfs, afero.NewMemMapFs(), 0),
-		"https": afero.NewMemMapFs(),
+	filesystems := map[string]afero.Fs{
+		"file": fsext.NewCacheOnReadFs(osfs, afero.NewMemMapFs(), 0),
 	}
+	// the data files loaded with LoadData are cached like the https modules
+	for _, scheme := range RemoteDataSchemes {
+		filesystems[scheme] = afero.NewMemMapFs()
+	}
+	return filesystems
 }
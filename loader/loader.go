@@ -58,7 +58,7 @@ var (
 		{"github", github, regexp.MustCompile(`^github\.com/([^/]+)/([^/]+)/(.*)$`)},
 	}
 	httpsSchemeCouldntBeLoadedMsg = `The moduleSpecifier "%s" couldn't be retrieved from` +
-		` the resolved url "%s". Error : "%s"`
+		` the resolved url "%s". Error : "%w"`
 	fileSchemeCouldntBeLoadedMsg = `The moduleSpecifier "%s" couldn't be found on ` +
 		`local disk. Make sure that you've specified the right path to the file. If you're ` +
 		`running k6 using the Docker image make sure you have mounted the ` +
@@ -296,15 +296,21 @@ func pickLoader(path string) (string, loaderFunc, []string) {
 }
 
 func fetch(logger logrus.FieldLogger, u string) ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, u, nil) //nolint:noctx // the context is set by fetchRequest
+	if err != nil {
+		return nil, err
+	}
+	return fetchRequest(logger, req)
+}
+
+// fetchRequest does a GET request and returns the body of the response.
+func fetchRequest(logger logrus.FieldLogger, req *http.Request) ([]byte, error) {
+	u := req.URL.String()
 	logger.WithField("url", u).Debug("Fetching source...")
 	startTime := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
 	defer cancel()
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
-	if err != nil {
-		return nil, err
-	}
-	res, err := http.DefaultClient.Do(req)
+	res, err := http.DefaultClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
 	}
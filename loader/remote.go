@@ -0,0 +1,266 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package loader
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+)
+
+// The schemes of the remote data sources, whose files are cached in the
+// filesystems of the same name, and so in the archives.
+const (
+	schemeHTTPS = "https"
+	schemeS3    = "s3"
+	schemeGCS   = "gs"
+)
+
+// RemoteDataSchemes are the schemes that can be used with LoadData.
+var RemoteDataSchemes = []string{schemeHTTPS, schemeS3, schemeGCS} //nolint:gochecknoglobals
+
+const (
+	defaultAWSRegion = "us-east-1"
+	gcsEndpoint      = "https://storage.googleapis.com"
+	// emptySHA256 is the hash of an empty payload, for the signing of requests
+	emptySHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// RemoteDataOptions are the options of the loading of remote data files.
+type RemoteDataOptions struct {
+	// Headers are added to the requests of https sources, e.g. to
+	// authenticate them.
+	Headers map[string]string
+	// Env is where the credentials and endpoints of the s3 and gs sources
+	// are read from, with the same variables as their official tools:
+	//  - AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN,
+	//    AWS_REGION or AWS_DEFAULT_REGION, and AWS_ENDPOINT_URL for S3
+	//    compatible services
+	//  - GOOGLE_OAUTH_ACCESS_TOKEN and STORAGE_EMULATOR_HOST for GCS
+	Env map[string]string
+}
+
+// IsRemoteData returns whether name is the URL of a remote data file, which
+// needs to be loaded with LoadData.
+func IsRemoteData(name string) bool {
+	for _, scheme := range RemoteDataSchemes {
+		if strings.HasPrefix(name, scheme+"://") {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadData loads a data file from a https URL, a s3://bucket/key or a
+// gs://bucket/object URL. Files are only fetched once, and then read from the
+// filesystem of their scheme, which is also how they're read from archives.
+func LoadData(
+	logger logrus.FieldLogger, filesystems map[string]afero.Fs, rawURL string, opts RemoteDataOptions,
+) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Host == "" || (u.Scheme != schemeHTTPS && strings.Trim(u.Path, "/") == "") {
+		return nil, fmt.Errorf("invalid remote data URL %q, it needs a host or a bucket and a path", rawURL)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf(
+			"remote data URL %q can't have credentials, they need to be in headers or env vars", u.Redacted())
+	}
+	// archives only have the filesystems of the data sources they use
+	fs, ok := filesystems[u.Scheme]
+	if !ok {
+		fs = afero.NewMemMapFs()
+	}
+
+	pathOnFs, err := url.PathUnescape(filepath.FromSlash(path.Clean(u.String()[len(u.Scheme)+len(":/"):])))
+	if err != nil {
+		return nil, err
+	}
+	data, err := afero.ReadFile(fs, pathOnFs)
+	if err == nil {
+		return data, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	var req *http.Request
+	switch u.Scheme {
+	case schemeS3:
+		req, err = newS3Request(u, opts.Env, time.Now())
+	case schemeGCS:
+		req, err = newGCSRequest(u, opts.Env)
+	default:
+		req, err = http.NewRequest(http.MethodGet, u.String(), nil) //nolint:noctx // the context is set by fetch
+		for k, v := range opts.Headers {
+			req.Header.Set(k, v)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	if data, err = fetchRequest(logger, req); err != nil {
+		return nil, fmt.Errorf("couldn't load the data file %q: %w", rawURL, err)
+	}
+	_ = afero.WriteFile(fs, pathOnFs, data, 0o644)
+	return data, nil
+}
+
+func envValue(env map[string]string, keys ...string) string {
+	for _, k := range keys {
+		if v := env[k]; v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+// newS3Request returns the request of a S3 object, signed with the AWS
+// Signature Version 4 when there are credentials. The virtual-hosted style is
+// used with AWS, and the path style with AWS_ENDPOINT_URL.
+func newS3Request(u *url.URL, env map[string]string, now time.Time) (*http.Request, error) {
+	region := envValue(env, "AWS_REGION", "AWS_DEFAULT_REGION")
+	if region == "" {
+		region = defaultAWSRegion
+	}
+	key := strings.TrimPrefix(u.Path, "/")
+	objectURL := fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", u.Host, region, uriEncode(key, false))
+	if endpoint := env["AWS_ENDPOINT_URL"]; endpoint != "" {
+		objectURL = fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(endpoint, "/"), u.Host, uriEncode(key, false))
+	}
+	req, err := http.NewRequest(http.MethodGet, objectURL, nil) //nolint:noctx // the context is set by fetch
+	if err != nil {
+		return nil, err
+	}
+	if accessKey := env["AWS_ACCESS_KEY_ID"]; accessKey != "" {
+		if token := env["AWS_SESSION_TOKEN"]; token != "" {
+			req.Header.Set("X-Amz-Security-Token", token)
+		}
+		signAWSRequest(req, accessKey, env["AWS_SECRET_ACCESS_KEY"], region, "s3", now)
+	}
+	return req, nil
+}
+
+// signAWSRequest signs a request without a body with the AWS Signature
+// Version 4, all of its headers are signed.
+func signAWSRequest(req *http.Request, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", emptySHA256)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		uriEncode(req.URL.Path, false),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		emptySHA256,
+	}, "\n")
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	signingKey := []byte("AWS4" + secretKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		signingKey = hmacSHA256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	_, _ = h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode encodes everything but the unreserved characters of RFC 3986, as
+// AWS requires it, and the slashes unless encodeSlash is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z', c >= '0' && c <= '9',
+			c == '-', c == '_', c == '.', c == '~', c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// newGCSRequest returns the request of a GCS object with the JSON API,
+// authenticated with an OAuth 2.0 access token, like the one returned by
+// `gcloud auth print-access-token`, when there's one.
+func newGCSRequest(u *url.URL, env map[string]string) (*http.Request, error) {
+	endpoint := gcsEndpoint
+	if host := env["STORAGE_EMULATOR_HOST"]; host != "" {
+		endpoint = host
+		if !strings.Contains(host, "://") {
+			endpoint = "http://" + host
+		}
+	}
+	object := strings.TrimPrefix(u.Path, "/")
+	objectURL := fmt.Sprintf("%s/storage/v1/b/%s/o/%s?alt=media",
+		strings.TrimSuffix(endpoint, "/"), url.PathEscape(u.Host), uriEncode(object, true))
+	req, err := http.NewRequest(http.MethodGet, objectURL, nil) //nolint:noctx // the context is set by fetch
+	if err != nil {
+		return nil, err
+	}
+	if token := env["GOOGLE_OAUTH_ACCESS_TOKEN"]; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req, nil
+}
@@ -686,7 +686,7 @@ func (out *Output) testFinished() error {
 		thresholdResults[name] = make(map[string]bool)
 		for _, t := range thresholds {
 			thresholdResults[name][t.Source] = t.LastFailed
-			if t.LastFailed {
+			if t.LastFailed && !t.IsWarning() {
 				testTainted = true
 			}
 		}
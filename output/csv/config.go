@@ -31,6 +31,7 @@ import (
 	"github.com/mstoykov/envconfig"
 	"github.com/sirupsen/logrus"
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
 )
 
 // Config is the config for the csv output
@@ -38,6 +39,10 @@ type Config struct {
 	// Samples.
 	FileName     null.String        `json:"file_name" envconfig:"K6_CSV_FILENAME"`
 	SaveInterval types.NullDuration `json:"save_interval" envconfig:"K6_CSV_SAVE_INTERVAL"`
+
+	// SystemTags overrides the global systemTags option for this output, any
+	// system tags that are not in it will be omitted from the file.
+	SystemTags *stats.SystemTagSet `json:"systemTags" ignored:"true"`
 }
 
 // NewConfig creates a new Config instance with default values for some fields.
@@ -56,6 +61,9 @@ func (c Config) Apply(cfg Config) Config {
 	if cfg.SaveInterval.Valid {
 		c.SaveInterval = cfg.SaveInterval
 	}
+	if cfg.SystemTags != nil {
+		c.SystemTags = cfg.SystemTags
+	}
 	return c
 }
 
@@ -119,6 +127,13 @@ func GetConsolidatedConfig(
 		// TODO: get rid of envconfig and actually use the env parameter...
 		return result, err
 	}
+	// envconfig leaves nil pointers to non-struct types alone
+	if systemTags, ok := env["K6_CSV_SYSTEM_TAGS"]; ok {
+		envConfig.SystemTags = new(stats.SystemTagSet)
+		if err := envConfig.SystemTags.UnmarshalText([]byte(systemTags)); err != nil {
+			return result, err
+		}
+	}
 	result = result.Apply(envConfig)
 
 	if arg != "" {
@@ -62,20 +62,6 @@ func New(params output.Params) (output.Output, error) {
 }
 
 func newOutput(params output.Params) (*Output, error) {
-	resTags := []string{}
-	ignoredTags := []string{}
-	tags := params.ScriptOptions.SystemTags.Map()
-	for tag, flag := range tags {
-		if flag {
-			resTags = append(resTags, tag)
-		} else {
-			ignoredTags = append(ignoredTags, tag)
-		}
-	}
-
-	sort.Strings(resTags)
-	sort.Strings(ignoredTags)
-
 	logger := params.Logger.WithFields(logrus.Fields{
 		"output":   "csv",
 		"filename": params.ConfigArgument,
@@ -85,6 +71,30 @@ func newOutput(params output.Params) (*Output, error) {
 		return nil, err
 	}
 
+	resTags := []string{}
+	ignoredTags := []string{}
+	if config.SystemTags != nil {
+		for _, tag := range stats.SystemTagSetValues() {
+			if config.SystemTags.Has(tag) {
+				resTags = append(resTags, tag.String())
+			} else {
+				ignoredTags = append(ignoredTags, tag.String())
+			}
+		}
+	} else {
+		tags := params.ScriptOptions.SystemTags.Map()
+		for tag, flag := range tags {
+			if flag {
+				resTags = append(resTags, tag)
+			} else {
+				ignoredTags = append(ignoredTags, tag)
+			}
+		}
+	}
+
+	sort.Strings(resTags)
+	sort.Strings(ignoredTags)
+
 	saveInterval := config.SaveInterval.TimeDuration()
 	fname := config.FileName.String
 
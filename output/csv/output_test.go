@@ -24,6 +24,7 @@ import (
 	"bytes"
 	"compress/gzip"
 	"encoding/csv"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"sort"
@@ -301,6 +302,39 @@ func TestRun(t *testing.T) {
 	}
 }
 
+func TestRunWithOutputSystemTags(t *testing.T) {
+	t.Parallel()
+	mem := afero.NewMemMapFs()
+	output, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		FS:             mem,
+		ConfigArgument: "test",
+		JSONConfig:     json.RawMessage(`{"systemTags": ["check"]}`),
+		ScriptOptions: lib.Options{
+			SystemTags: stats.NewSystemTagSet(stats.TagError | stats.TagCheck),
+		},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, output.Start())
+	output.AddMetricSamples([]stats.SampleContainer{stats.Sample{
+		Time:   time.Unix(1562324643, 0),
+		Metric: stats.New("my_metric", stats.Gauge),
+		Value:  1,
+		Tags: stats.NewSampleTags(map[string]string{
+			"check": "val1",
+			"url":   "val2",
+			"error": "val3",
+			"tag4":  "val4",
+		}),
+	}})
+	require.NoError(t, output.Stop())
+
+	assert.Equal(t,
+		"metric_name,timestamp,metric_value,check,extra_tags\n"+"my_metric,1562324643,1.000000,val1,tag4=val4\n",
+		readUnCompressedFile("test", mem))
+}
+
 func sortExtraTagsForTest(t *testing.T, input string) string {
 	t.Helper()
 	r := csv.NewReader(strings.NewReader(input))
@@ -32,6 +32,7 @@ import (
 	"gopkg.in/guregu/null.v3"
 
 	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
 )
 
 type Config struct {
@@ -50,6 +51,10 @@ type Config struct {
 	Retention    null.String `json:"retention,omitempty" envconfig:"K6_INFLUXDB_RETENTION"`
 	Consistency  null.String `json:"consistency,omitempty" envconfig:"K6_INFLUXDB_CONSISTENCY"`
 	TagsAsFields []string    `json:"tagsAsFields,omitempty" envconfig:"K6_INFLUXDB_TAGS_AS_FIELDS"`
+
+	// SystemTags limits the system tags sent to InfluxDB, independently of
+	// the global systemTags option.
+	SystemTags *stats.SystemTagSet `json:"systemTags,omitempty" ignored:"true"`
 }
 
 // NewConfig creates a new InfluxDB output config with some default values.
@@ -104,6 +109,9 @@ func (c Config) Apply(cfg Config) Config {
 	if len(cfg.TagsAsFields) > 0 {
 		c.TagsAsFields = cfg.TagsAsFields
 	}
+	if cfg.SystemTags != nil {
+		c.SystemTags = cfg.SystemTags
+	}
 	if cfg.PushInterval.Valid {
 		c.PushInterval = cfg.PushInterval
 	}
@@ -179,6 +187,8 @@ func ParseURL(text string) (Config, error) {
 			c.ConcurrentWrites = null.IntFrom(int64(writes))
 		case "tagsAsFields":
 			c.TagsAsFields = vs
+		case "systemTags":
+			c.SystemTags = stats.ToSystemTagSet(vs)
 		default:
 			return c, fmt.Errorf("unknown query parameter: %s", k)
 		}
@@ -206,6 +216,13 @@ func GetConsolidatedConfig(jsonRawConf json.RawMessage, env map[string]string, u
 		// TODO: get rid of envconfig and actually use the env parameter...
 		return result, err
 	}
+	// envconfig leaves nil pointers to non-struct types alone
+	if systemTags, ok := env["K6_INFLUXDB_SYSTEM_TAGS"]; ok {
+		envConfig.SystemTags = new(stats.SystemTagSet)
+		if err := envConfig.SystemTags.UnmarshalText([]byte(systemTags)); err != nil {
+			return result, err
+		}
+	}
 	result = result.Apply(envConfig)
 
 	if url != "" {
@@ -25,6 +25,8 @@ import (
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/stats"
 )
 
 func TestParseURL(t *testing.T) {
@@ -46,6 +48,9 @@ func TestParseURL(t *testing.T) {
 		"?insecure=ture":   {Config{}, "insecure must be true or false, not ture"},
 		"?payload_size=69": {Config{PayloadSize: null.IntFrom(69)}, ""},
 		"?payload_size=a":  {Config{}, "strconv.Atoi: parsing \"a\": invalid syntax"},
+		"?systemTags=status&systemTags=name": {
+			Config{SystemTags: stats.NewSystemTagSet(stats.TagStatus, stats.TagName)}, "",
+		},
 	}
 	for str, data := range testdata {
 		t.Run(str, func(t *testing.T) {
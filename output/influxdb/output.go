@@ -31,10 +31,15 @@ import (
 	client "github.com/influxdata/influxdb1-client/v2"
 	"github.com/sirupsen/logrus"
 
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/output"
 	"go.k6.io/k6/stats"
 )
 
+// eventsMeasurement is the measurement of the events of the test run, like the
+// anomalies detected in its metrics, e.g. for the annotations of Grafana.
+const eventsMeasurement = "k6_events"
+
 // FieldKind defines Enum for tag-to-field type conversion
 type FieldKind int
 
@@ -63,6 +68,9 @@ type Output struct {
 	periodicFlusher *output.PeriodicFlusher
 	semaphoreCh     chan struct{}
 	wg              sync.WaitGroup
+
+	eventsMx sync.Mutex
+	events   []lib.Event
 }
 
 // New returns new influxdb output
@@ -124,6 +132,20 @@ func (o *Output) extractTagsToValues(tags map[string]string, values map[string]i
 	return values
 }
 
+// extractTypedTagsToValues moves the typed tags, the number and boolean ones
+// of the typedTags option, to the values, as the tags of InfluxDB are always
+// strings, unlike its fields.
+func extractTypedTagsToValues(sampleTags *stats.SampleTags, tags map[string]string, values map[string]interface{}) {
+	for tag := range tags {
+		if v, ok := sampleTags.TypedValue(tag); ok {
+			if _, isString := v.(string); !isString {
+				values[tag] = v
+				delete(tags, tag)
+			}
+		}
+	}
+}
+
 func (o *Output) batchFromSamples(containers []stats.SampleContainer) (client.BatchPoints, error) {
 	batch, err := client.NewBatchPoints(o.BatchConf)
 	if err != nil {
@@ -146,11 +168,18 @@ func (o *Output) batchFromSamples(containers []stats.SampleContainer) (client.Ba
 					values[k] = v
 				}
 			} else {
-				tags = sample.Tags.CloneTags()
+				tags = o.Config.SystemTags.FilterTags(sample.Tags.CloneTags())
 				o.extractTagsToValues(tags, values)
+				extractTypedTagsToValues(sample.Tags, tags, values)
 				cache[sample.Tags] = cacheItem{tags, values}
 			}
 			values["value"] = sample.Value
+			// the metadata are fields, which aren't indexed, unlike the tags
+			for k, v := range sample.Metadata {
+				if _, ok := values[k]; !ok {
+					values[k] = v
+				}
+			}
 			var p *client.Point
 			p, err = client.NewPoint(
 				sample.Metric.Name,
@@ -168,6 +197,46 @@ func (o *Output) batchFromSamples(containers []stats.SampleContainer) (client.Ba
 	return batch, nil
 }
 
+// addEventPoints adds a point of the eventsMeasurement to the batch for every
+// event, with its type and its string data as tags, except the description,
+// which is the text field with the rest of its data. A time in its data, like
+// the end of the interval of an anomaly, is in milliseconds since the epoch.
+func addEventPoints(batch client.BatchPoints, events []lib.Event) error {
+	for _, event := range events {
+		tags := map[string]string{"type": string(event.Type)}
+		fields := map[string]interface{}{"text": string(event.Type)}
+		for k, v := range event.Data {
+			switch v := v.(type) {
+			case string:
+				if k == "description" {
+					fields["text"] = v
+				} else {
+					tags[k] = v
+				}
+			case time.Time:
+				fields[k] = v.UnixNano() / int64(time.Millisecond)
+			case float64, int64, bool:
+				fields[k] = v
+			default:
+				fields[k] = fmt.Sprint(v)
+			}
+		}
+		p, err := client.NewPoint(eventsMeasurement, tags, fields, event.Time)
+		if err != nil {
+			return fmt.Errorf("couldn't make point from event: %w", err)
+		}
+		batch.AddPoint(p)
+	}
+	return nil
+}
+
+// AddEvent buffers the event until the next flush.
+func (o *Output) AddEvent(event lib.Event) {
+	o.eventsMx.Lock()
+	o.events = append(o.events, event)
+	o.eventsMx.Unlock()
+}
+
 // Description returns a human-readable description of the output.
 func (o *Output) Description() string {
 	return fmt.Sprintf("InfluxDBv1 (%s)", o.Config.Addr.String)
@@ -205,7 +274,11 @@ func (o *Output) Stop() error {
 
 func (o *Output) flushMetrics() {
 	samples := o.GetBufferedSamples()
-	if len(samples) < 1 {
+	o.eventsMx.Lock()
+	events := o.events
+	o.events = nil
+	o.eventsMx.Unlock()
+	if len(samples) < 1 && len(events) < 1 {
 		return
 	}
 
@@ -225,6 +298,10 @@ func (o *Output) flushMetrics() {
 			o.logger.WithError(err).Error("Couldn't create batch from samples")
 			return
 		}
+		if err = addEventPoints(batch, events); err != nil {
+			o.logger.WithError(err).Error("Couldn't add the events to the batch")
+			return
+		}
 
 		o.logger.WithField("points", len(batch.Points())).Debug("Writing...")
 		startTime := time.Now()
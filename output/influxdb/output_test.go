@@ -32,9 +32,11 @@ import (
 	"testing"
 	"time"
 
+	client "github.com/influxdata/influxdb1-client/v2"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/output"
 	"go.k6.io/k6/stats"
@@ -219,3 +221,109 @@ func TestExtractTagsToValues(t *testing.T) {
 	require.Equal(t, 3.14, values["floatField"])
 	require.Equal(t, int64(12345), values["intField"])
 }
+
+func TestBatchFromSamplesSystemTags(t *testing.T) {
+	t.Parallel()
+	o, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "?systemTags=status&systemTags=name",
+	})
+	require.NoError(t, err)
+
+	batch, err := o.batchFromSamples([]stats.SampleContainer{stats.Sample{
+		Metric: stats.New("testCounter", stats.Counter),
+		Time:   time.Now(),
+		Tags: stats.NewSampleTags(map[string]string{
+			"status": "200",
+			"name":   "something",
+			"method": "GET",
+			"custom": "value",
+		}),
+		Value: 1.0,
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.Points(), 1)
+	assert.Equal(t,
+		map[string]string{"status": "200", "name": "something", "custom": "value"},
+		batch.Points()[0].Tags())
+}
+
+func TestBatchFromSamplesMetadata(t *testing.T) {
+	t.Parallel()
+	o, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "?systemTags=status",
+	})
+	require.NoError(t, err)
+
+	batch, err := o.batchFromSamples([]stats.SampleContainer{stats.Sample{
+		Metric:   stats.New("testCounter", stats.Counter),
+		Time:     time.Now(),
+		Tags:     stats.NewSampleTags(map[string]string{"status": "200"}),
+		Metadata: map[string]string{"trace_id": "abc", "value": "ignored"},
+		Value:    1.0,
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.Points(), 1)
+	point := batch.Points()[0]
+	assert.Equal(t, map[string]string{"status": "200"}, point.Tags())
+	fields, err := point.Fields()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"value": 1.0, "trace_id": "abc"}, fields)
+}
+
+func TestBatchFromSamplesTypedTags(t *testing.T) {
+	t.Parallel()
+	o, err := newOutput(output.Params{
+		Logger:         testutils.NewLogger(t),
+		ConfigArgument: "?systemTags=status",
+	})
+	require.NoError(t, err)
+
+	tags := map[string]string{"status": "200"}
+	sampleTags, err := stats.IntoTypedSampleTags(&tags, map[string]interface{}{"items": 3.0, "cached": true})
+	require.NoError(t, err)
+	batch, err := o.batchFromSamples([]stats.SampleContainer{stats.Sample{
+		Metric: stats.New("testCounter", stats.Counter),
+		Time:   time.Now(),
+		Tags:   sampleTags,
+		Value:  1.0,
+	}})
+	require.NoError(t, err)
+	require.Len(t, batch.Points(), 1)
+	point := batch.Points()[0]
+	assert.Equal(t, map[string]string{"status": "200"}, point.Tags())
+	fields, err := point.Fields()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"value": 1.0, "items": 3.0, "cached": true}, fields)
+}
+
+func TestAddEventPoints(t *testing.T) {
+	t.Parallel()
+	batch, err := client.NewBatchPoints(client.BatchPointsConfig{})
+	require.NoError(t, err)
+
+	start := time.Unix(1600000000, 0)
+	require.NoError(t, addEventPoints(batch, []lib.Event{{
+		Type: lib.EventAnomalyDetected,
+		Time: start,
+		Data: map[string]interface{}{
+			"kind":        "latency-step",
+			"value":       300.0,
+			"end":         start.Add(10 * time.Second),
+			"description": "The average duration of the requests stepped up",
+		},
+	}}))
+	require.Len(t, batch.Points(), 1)
+	point := batch.Points()[0]
+	assert.Equal(t, eventsMeasurement, point.Name())
+	assert.Equal(t, start, point.Time())
+	assert.Equal(t, map[string]string{"type": "anomaly-detected", "kind": "latency-step"}, point.Tags())
+	fields, err := point.Fields()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"text":  "The average duration of the requests stepped up",
+		"value": 300.0,
+		"end":   int64(1600000010000),
+	}, fields)
+}
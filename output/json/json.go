@@ -25,10 +25,12 @@ import (
 	stdlibjson "encoding/json"
 	"fmt"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/sirupsen/logrus"
 
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/output"
 	"go.k6.io/k6/stats"
 )
@@ -49,6 +51,9 @@ type Output struct {
 	closeFn     func() error
 	seenMetrics map[string]struct{}
 	thresholds  map[string][]*stats.Threshold
+
+	eventsMx sync.Mutex
+	events   []lib.Event
 }
 
 // New returns a new JSON output.
@@ -103,6 +108,11 @@ func (o *Output) Start() error {
 	}
 
 	o.encoder.SetEscapeHTML(false)
+	if len(o.params.Metadata) > 0 {
+		if err := o.encoder.Encode(wrapMetadata(o.params.Metadata)); err != nil {
+			o.logger.WithError(err).Error("Error writing the environment metadata")
+		}
+	}
 
 	pf, err := output.NewPeriodicFlusher(flushPeriod, o.flushMetrics)
 	if err != nil {
@@ -131,6 +141,13 @@ func (o *Output) SetThresholds(thresholds map[string]stats.Thresholds) {
 	o.thresholds = ths
 }
 
+// AddEvent buffers the event until the next flush, after the samples before it.
+func (o *Output) AddEvent(event lib.Event) {
+	o.eventsMx.Lock()
+	o.events = append(o.events, event)
+	o.eventsMx.Unlock()
+}
+
 func (o *Output) flushMetrics() {
 	samples := o.GetBufferedSamples()
 	start := time.Now()
@@ -152,6 +169,16 @@ func (o *Output) flushMetrics() {
 	if count > 0 {
 		o.logger.WithField("t", time.Since(start)).WithField("count", count).Debug("Wrote metrics to JSON")
 	}
+
+	o.eventsMx.Lock()
+	events := o.events
+	o.events = nil
+	o.eventsMx.Unlock()
+	for _, event := range events {
+		if err := o.encoder.Encode(wrapEvent(event)); err != nil {
+			o.logger.WithError(err).Error("Event couldn't be marshalled to JSON")
+		}
+	}
 }
 
 func (o *Output) handleMetric(m *stats.Metric) {
@@ -24,6 +24,7 @@ import (
 	"bufio"
 	"bytes"
 	"compress/gzip"
+	stdlibjson "encoding/json"
 	"io"
 	"testing"
 	"time"
@@ -32,6 +33,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"go.k6.io/k6/lib"
 	"go.k6.io/k6/lib/testutils"
 	"go.k6.io/k6/output"
 	"go.k6.io/k6/stats"
@@ -104,6 +106,45 @@ func TestJsonOutputStdout(t *testing.T) {
 	validateResults(stdout)
 }
 
+func TestJsonOutputMetadata(t *testing.T) {
+	t.Parallel()
+
+	stdout := new(bytes.Buffer)
+	out, err := New(output.Params{
+		Logger:   testutils.NewLogger(t),
+		StdOut:   stdout,
+		Metadata: map[string]string{"cloud_provider": "aws", "region": "eu-west-1"},
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Start())
+	require.NoError(t, out.Stop())
+
+	assert.JSONEq(t, `{"type":"Metadata","data":{"cloud_provider":"aws","region":"eu-west-1"}}`, stdout.String())
+}
+
+func TestJsonOutputEvents(t *testing.T) {
+	t.Parallel()
+
+	stdout := new(bytes.Buffer)
+	out, err := New(output.Params{
+		Logger: testutils.NewLogger(t),
+		StdOut: stdout,
+	})
+	require.NoError(t, err)
+	require.NoError(t, out.Start())
+	eventsOut, ok := out.(output.WithEvents)
+	require.True(t, ok)
+	eventsOut.AddEvent(lib.Event{
+		Type: lib.EventAnomalyDetected,
+		Time: time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC),
+		Data: map[string]interface{}{"kind": "latency-step", "value": 300},
+	})
+	require.NoError(t, out.Stop())
+
+	assert.JSONEq(t, `{"type":"Event","data":{"type":"anomaly-detected","time":"2021-06-01T12:00:00Z",`+
+		`"data":{"kind":"latency-step","value":300}}}`, stdout.String())
+}
+
 func TestJsonOutputFileError(t *testing.T) {
 	t.Parallel()
 
@@ -185,6 +226,21 @@ func TestWrapSampleWithSamplePointer(t *testing.T) {
 	assert.NotEqual(t, out, (*Envelope)(nil))
 }
 
+func TestWrapSampleMetadata(t *testing.T) {
+	t.Parallel()
+	out := WrapSample(stats.Sample{
+		Metric:   &stats.Metric{},
+		Metadata: map[string]string{"trace_id": "abc"},
+	})
+	data, err := stdlibjson.Marshal(out)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"metadata":{"trace_id":"abc"}`)
+
+	data, err = stdlibjson.Marshal(WrapSample(stats.Sample{Metric: &stats.Metric{}}))
+	require.NoError(t, err)
+	assert.NotContains(t, string(data), "metadata")
+}
+
 func TestWrapMetricWithMetricPointer(t *testing.T) {
 	t.Parallel()
 	out := wrapMetric(&stats.Metric{})
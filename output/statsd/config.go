@@ -33,17 +33,18 @@ import (
 
 // config defines the StatsD configuration.
 type config struct {
-	Addr         null.String        `json:"addr,omitempty" envconfig:"K6_STATSD_ADDR"`
-	BufferSize   null.Int           `json:"bufferSize,omitempty" envconfig:"K6_STATSD_BUFFER_SIZE"`
-	Namespace    null.String        `json:"namespace,omitempty" envconfig:"K6_STATSD_NAMESPACE"`
-	PushInterval types.NullDuration `json:"pushInterval,omitempty" envconfig:"K6_STATSD_PUSH_INTERVAL"`
-	TagBlocklist stats.TagSet       `json:"tagBlocklist,omitempty" envconfig:"K6_STATSD_TAG_BLOCKLIST"`
-	EnableTags   null.Bool          `json:"enableTags,omitempty" envconfig:"K6_STATSD_ENABLE_TAGS"`
+	Addr         null.String         `json:"addr,omitempty" envconfig:"K6_STATSD_ADDR"`
+	BufferSize   null.Int            `json:"bufferSize,omitempty" envconfig:"K6_STATSD_BUFFER_SIZE"`
+	Namespace    null.String         `json:"namespace,omitempty" envconfig:"K6_STATSD_NAMESPACE"`
+	PushInterval types.NullDuration  `json:"pushInterval,omitempty" envconfig:"K6_STATSD_PUSH_INTERVAL"`
+	TagBlocklist stats.TagSet        `json:"tagBlocklist,omitempty" envconfig:"K6_STATSD_TAG_BLOCKLIST"`
+	EnableTags   null.Bool           `json:"enableTags,omitempty" envconfig:"K6_STATSD_ENABLE_TAGS"`
+	SystemTags   *stats.SystemTagSet `json:"systemTags,omitempty" ignored:"true"`
 }
 
-func processTags(t stats.TagSet, tags map[string]string) []string {
+func processTags(t stats.TagSet, systemTags *stats.SystemTagSet, tags map[string]string) []string {
 	var res []string
-	for key, value := range tags {
+	for key, value := range systemTags.FilterTags(tags) {
 		if value != "" && !t[key] {
 			res = append(res, key+":"+value)
 		}
@@ -71,6 +72,9 @@ func (c config) Apply(cfg config) config {
 	if cfg.EnableTags.Valid {
 		c.EnableTags = cfg.EnableTags
 	}
+	if cfg.SystemTags != nil {
+		c.SystemTags = cfg.SystemTags
+	}
 
 	return c
 }
@@ -107,6 +111,14 @@ func getConsolidatedConfig(jsonRawConf json.RawMessage, env map[string]string, _
 	}); err != nil {
 		return result, err
 	}
+	// envconfig leaves nil pointers to non-struct types alone, so the system
+	// tags have to be handled manually
+	if systemTags, ok := env["K6_STATSD_SYSTEM_TAGS"]; ok {
+		envConfig.SystemTags = new(stats.SystemTagSet)
+		if err := envConfig.SystemTags.UnmarshalText([]byte(systemTags)); err != nil {
+			return result, err
+		}
+	}
 	result = result.Apply(envConfig)
 
 	return result, nil
@@ -66,7 +66,7 @@ type Output struct {
 func (o *Output) dispatch(entry stats.Sample) error {
 	var tagList []string
 	if o.config.EnableTags.Bool {
-		tagList = processTags(o.config.TagBlocklist, entry.Tags.CloneTags())
+		tagList = processTags(o.config.TagBlocklist, o.config.SystemTags, entry.Tags.CloneTags())
 	}
 
 	switch entry.Metric.Type {
@@ -76,6 +76,8 @@ func (o *Output) dispatch(entry stats.Sample) error {
 		return o.client.TimeInMilliseconds(entry.Metric.Name, entry.Value, tagList, 1)
 	case stats.Gauge:
 		return o.client.Gauge(entry.Metric.Name, entry.Value, tagList, 1)
+	case stats.Histogram:
+		return o.client.Histogram(entry.Metric.Name, entry.Value, tagList, 1)
 	case stats.Rate:
 		if check, ok := entry.Tags.Get("check"); ok {
 			return o.client.Count(
@@ -88,7 +88,7 @@ func TestStatsdEnabledTags(t *testing.T) {
 			for j, sample := range container.GetSamples() {
 				lines++
 				var (
-					expectedTagList    = processTags(tagMap, sample.GetTags().CloneTags())
+					expectedTagList    = processTags(tagMap, nil, sample.GetTags().CloneTags())
 					expectedOutputLine = expectedOutputLines[i*j+i]
 					outputLine         = outputLines[i*j+i]
 					outputWithoutTags  = outputLine
@@ -139,3 +139,23 @@ func TestLinkReturnAddress(t *testing.T) {
 	}
 	require.Equal(t, fmt.Sprintf("statsd (%s)", bogusValue), c.Description())
 }
+
+func TestSystemTagsConfig(t *testing.T) {
+	t.Parallel()
+	conf, err := getConsolidatedConfig(
+		json.RawMessage(`{"systemTags": ["status", "name"]}`),
+		map[string]string{}, "")
+	require.NoError(t, err)
+	require.NotNil(t, conf.SystemTags)
+	assert.Equal(t, stats.TagStatus|stats.TagName, *conf.SystemTags)
+
+	conf, err = getConsolidatedConfig(nil, map[string]string{"K6_STATSD_SYSTEM_TAGS": "status"}, "")
+	require.NoError(t, err)
+	require.NotNil(t, conf.SystemTags)
+	assert.Equal(t, stats.TagStatus, *conf.SystemTags)
+
+	tags := map[string]string{"status": "200", "name": "n", "method": "GET", "custom": "val"}
+	assert.ElementsMatch(t,
+		[]string{"status:200", "custom:val"},
+		processTags(stats.TagSet{}, conf.SystemTags, tags))
+}
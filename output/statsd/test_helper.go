@@ -97,6 +97,7 @@ func baseTest(t *testing.T,
 	myTrend := stats.New("my_trend", stats.Trend)
 	myRate := stats.New("my_rate", stats.Rate)
 	myCheck := stats.New("my_check", stats.Rate)
+	myHistogram := stats.New("my_histogram", stats.Histogram)
 	testMatrix := []struct {
 		input  []stats.SampleContainer
 		output string
@@ -137,6 +138,15 @@ func baseTest(t *testing.T,
 			},
 			output: "testing.things.my_rate:15|c",
 		},
+		{
+			input: []stats.SampleContainer{
+				newSample(myHistogram, 17, map[string]string{
+					"tag1": "value1",
+					"tag3": "value3",
+				}),
+			},
+			output: "testing.things.my_histogram:17.000000|h",
+		},
 		{
 			input: []stats.SampleContainer{
 				newSample(myCheck, 16, map[string]string{
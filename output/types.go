@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"io"
 	"net/url"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/afero"
@@ -48,6 +49,11 @@ type Params struct {
 	ScriptOptions  lib.Options
 	RuntimeOptions lib.RuntimeOptions
 	ExecutionPlan  []lib.ExecutionStep
+
+	// Metadata is the run-level metadata of the environment k6 runs in, like
+	// its cloud provider, region and Kubernetes pod, see the envinfo package.
+	// It's empty unless --env-metadata is given.
+	Metadata map[string]string
 }
 
 // An Output abstracts the process of funneling samples to an external storage
@@ -82,6 +88,32 @@ type WithThresholds interface {
 	SetThresholds(map[string]stats.Thresholds)
 }
 
+// ThresholdEvaluation is the outcome of a run of all of the thresholds, which
+// the Engine does periodically during the test and once more at its end.
+type ThresholdEvaluation struct {
+	TestRunDuration time.Duration
+	Passed          bool
+	// Results are the results of the thresholds, by the name of their metric.
+	Results map[string][]stats.ThresholdResult
+}
+
+// WithThresholdEvaluations means the output can receive the outcome of every
+// run of the thresholds. Like AddMetricSamples(), the method is called by the
+// Engine while it processes the metrics, so it shouldn't block.
+type WithThresholdEvaluations interface {
+	Output
+	AddThresholdEvaluation(evaluation ThresholdEvaluation)
+}
+
+// WithEvents means the output can receive the events of the test run which
+// are about its metrics, like the anomalies detected in them, e.g. to show
+// them as annotations. Like AddMetricSamples(), the method is called by the
+// Engine while it processes the metrics, so it shouldn't block.
+type WithEvents interface {
+	Output
+	AddEvent(event lib.Event)
+}
+
 // WithTestRunStop is an output that can stop the Engine mid-test, interrupting
 // the whole test run execution if some internal condition occurs, completely
 // independently from the thresholds. It requires a callback function which
@@ -0,0 +1,95 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testrun
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+
+	"go.k6.io/k6/core"
+	"go.k6.io/k6/errext"
+	"go.k6.io/k6/errext/exitcodes"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/output"
+)
+
+// Engine is a core.Engine with the steps of running a test, which are the same
+// in TestRun.Run and in the k6 run command: Init initializes the VUs, Run runs
+// the test and Wait waits for the metrics to be processed, once the globalCtx
+// given to Init is cancelled. The outputs have to be started before Init, with
+// StartOutputs, and stopped after Wait, with StopOutputs.
+type Engine struct {
+	*core.Engine
+
+	run  func() error
+	wait func()
+}
+
+// NewEngine creates the engine which runs the test with the execution
+// scheduler.
+func NewEngine(
+	execScheduler lib.ExecutionScheduler, opts lib.Options, rtOpts lib.RuntimeOptions, outputs []output.Output,
+	logger *logrus.Logger, registry *metrics.Registry, builtinMetrics *metrics.BuiltinMetrics,
+) (*Engine, error) {
+	engine, err := core.NewEngine(execScheduler, opts, rtOpts, outputs, logger, registry, builtinMetrics)
+	if err != nil {
+		return nil, err
+	}
+	return &Engine{Engine: engine}, nil
+}
+
+// Init initializes the VUs. The test stops when runCtx is cancelled, and the
+// metrics stop being processed when globalCtx is.
+func (e *Engine) Init(globalCtx, runCtx context.Context) error {
+	run, wait, err := e.Engine.Init(globalCtx, runCtx)
+	if err != nil {
+		// Add a generic engine exit code if we don't have a more specific one
+		return errext.WithExitCodeIfNone(common.UnwrapGojaInterruptedError(err), exitcodes.GenericEngine)
+	}
+	e.run, e.wait = run, wait
+	return nil
+}
+
+// Run runs the test until it finishes or the runCtx given to Init is
+// cancelled, and returns the error which interrupted it.
+func (e *Engine) Run() error {
+	return common.UnwrapGojaInterruptedError(e.run())
+}
+
+// Wait waits for the metrics to be processed, after the globalCtx given to
+// Init is cancelled.
+func (e *Engine) Wait() {
+	e.wait()
+}
+
+// Result returns the result of the test, once it has finished.
+func (e *Engine) Result() *Result {
+	e.MetricsLock.Lock()
+	defer e.MetricsLock.Unlock()
+	return &Result{
+		ThresholdsPassed: !e.IsTainted(),
+		Duration:         e.ExecutionScheduler.GetState().GetCurrentTestRunDuration(),
+		Metrics:          e.Metrics,
+	}
+}
@@ -0,0 +1,119 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testrun
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/consts"
+	"go.k6.io/k6/lib/executor"
+	"go.k6.io/k6/lib/types"
+	"go.k6.io/k6/stats"
+)
+
+// DefaultOptions returns the options with the default values of the flags of
+// the k6 run command, which uses them for its flags. They aren't valid, so any
+// other value overrides them.
+func DefaultOptions() lib.Options {
+	return lib.Options{
+		VUs:                          null.NewInt(1, false),
+		MaxRedirects:                 null.NewInt(10, false),
+		Batch:                        null.NewInt(20, false),
+		BatchPerHost:                 null.NewInt(6, false),
+		UserAgent:                    null.NewString(fmt.Sprintf("k6/%s (https://k6.io/)", consts.Version), false),
+		ThresholdsEvaluationInterval: types.NewNullDuration(2*time.Second, false),
+		TrendPercentileMethod:        null.NewString(string(stats.PercentileLinear), false),
+		GaugeEWMAHalfLife:            types.NewNullDuration(stats.DefaultGaugeHalfLife, false),
+		SetupTimeout:                 types.NewNullDuration(60*time.Second, false),
+		TeardownTimeout:              types.NewNullDuration(60*time.Second, false),
+
+		MetricSamplesBufferSize: null.NewInt(1000, false),
+	}
+}
+
+// ApplyDefaults applies the default values of the options which weren't
+// specified. This happens with types which are not supported by
+// "gopkg.in/guregu/null.v3".
+//
+// Note that if you add option default value here, also add it in command line argument help text.
+func ApplyDefaults(opts lib.Options) lib.Options {
+	if opts.SystemTags == nil {
+		opts.SystemTags = &stats.DefaultSystemTagSet
+	}
+	if opts.SummaryTrendStats == nil {
+		opts.SummaryTrendStats = lib.DefaultSummaryTrendStats
+	}
+	defDNS := types.DefaultDNSConfig()
+	if !opts.DNS.TTL.Valid {
+		opts.DNS.TTL = defDNS.TTL
+	}
+	if !opts.DNS.Select.Valid {
+		opts.DNS.Select = defDNS.Select
+	}
+	if !opts.DNS.Policy.Valid {
+		opts.DNS.Policy = defDNS.Policy
+	}
+	return opts
+}
+
+// ParseThresholds parses the expressions of the thresholds.
+func ParseThresholds(thresholds map[string]stats.Thresholds) error {
+	for _, ts := range thresholds {
+		if err := ts.Parse(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeriveAndValidateOptions derives the scenarios from the shortcut options,
+// like vus and duration, and checks that the options make sense and that the
+// functions of the scenarios are exported by the script.
+func DeriveAndValidateOptions(
+	opts lib.Options, isExecutable func(string) bool, logger logrus.FieldLogger,
+) (lib.Options, error) {
+	opts, err := executor.DeriveScenariosFromShortcuts(opts, logger)
+	if err != nil {
+		return opts, err
+	}
+
+	errList := opts.Validate()
+	for _, conf := range opts.Scenarios {
+		if execFn := conf.GetExec(); !isExecutable(execFn) {
+			errList = append(errList, fmt.Errorf("executor %s: function '%s' not found in exports", conf.GetName(), execFn))
+		}
+	}
+	if len(errList) == 0 {
+		return opts, nil
+	}
+	errMsgParts := []string{"There were problems with the specified script configuration:"}
+	for _, err := range errList {
+		errMsgParts = append(errMsgParts, fmt.Sprintf("\t- %s", err.Error()))
+	}
+	return opts, errors.New(strings.Join(errMsgParts, "\n"))
+}
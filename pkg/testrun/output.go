@@ -0,0 +1,70 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testrun
+
+import (
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+// CallbackOutput is an in-process output, which calls its functions with the
+// metric samples and the threshold evaluations as soon as the engine has
+// them. Both functions are called from the goroutine which processes the
+// metrics, so they should return quickly, e.g. by sending to a buffered
+// channel. Either of them can be nil.
+type CallbackOutput struct {
+	OnSamples    func(samples []stats.SampleContainer)
+	OnThresholds func(evaluation output.ThresholdEvaluation)
+}
+
+var (
+	_ output.Output                   = &CallbackOutput{}
+	_ output.WithThresholdEvaluations = &CallbackOutput{}
+)
+
+// Description returns a human-readable description of the output.
+func (o *CallbackOutput) Description() string {
+	return "callback (in-process)"
+}
+
+// Start doesn't do anything, since the samples aren't buffered.
+func (o *CallbackOutput) Start() error {
+	return nil
+}
+
+// AddMetricSamples calls OnSamples with the samples.
+func (o *CallbackOutput) AddMetricSamples(samples []stats.SampleContainer) {
+	if o.OnSamples != nil && len(samples) > 0 {
+		o.OnSamples(samples)
+	}
+}
+
+// AddThresholdEvaluation calls OnThresholds with the evaluation.
+func (o *CallbackOutput) AddThresholdEvaluation(evaluation output.ThresholdEvaluation) {
+	if o.OnThresholds != nil {
+		o.OnThresholds(evaluation)
+	}
+}
+
+// Stop doesn't do anything, since the samples aren't buffered.
+func (o *CallbackOutput) Stop() error {
+	return nil
+}
@@ -0,0 +1,91 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testrun
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+func TestCallbackOutput(t *testing.T) {
+	t.Parallel()
+	fs := newTestFS(t, map[string]string{
+		"/test.js": `
+			import { Counter } from "k6/metrics";
+			const counter = new Counter("my_counter");
+			export const options = {
+				iterations: 3,
+				thresholds: { my_counter: ["count<2"] },
+			};
+			export default function() { counter.add(1) }
+		`,
+	})
+
+	var mx sync.Mutex
+	var counted float64
+	var evaluations []output.ThresholdEvaluation
+	out := &CallbackOutput{
+		OnSamples: func(samples []stats.SampleContainer) {
+			mx.Lock()
+			defer mx.Unlock()
+			for _, sc := range samples {
+				for _, s := range sc.GetSamples() {
+					if s.Metric.Name == "my_counter" {
+						counted += s.Value
+					}
+				}
+			}
+		},
+		OnThresholds: func(evaluation output.ThresholdEvaluation) {
+			mx.Lock()
+			defer mx.Unlock()
+			evaluations = append(evaluations, evaluation)
+		},
+	}
+	tr, err := Configure(Config{
+		Script:  "/test.js",
+		FS:      fs,
+		Logger:  testutils.NewLogger(t),
+		Outputs: []output.Output{out},
+	})
+	require.NoError(t, err)
+	result, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	assert.False(t, result.ThresholdsPassed)
+
+	mx.Lock()
+	defer mx.Unlock()
+	assert.Equal(t, 3.0, counted)
+	require.NotEmpty(t, evaluations, "the thresholds are evaluated at least at the end")
+	last := evaluations[len(evaluations)-1]
+	assert.False(t, last.Passed)
+	require.Len(t, last.Results["my_counter"], 1)
+	assert.Equal(t, "count<2", last.Results["my_counter"][0].Source)
+	assert.False(t, last.Results["my_counter"][0].Passed)
+}
@@ -0,0 +1,79 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testrun
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/js"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/loader"
+)
+
+// The types of the tests k6 can run.
+const (
+	TypeJS      = "js"
+	TypeArchive = "archive"
+)
+
+// DetectType returns whether the data is an archive or a JS script.
+func DetectType(data []byte) string {
+	if _, err := tar.NewReader(bytes.NewReader(data)).Next(); err == nil {
+		return TypeArchive
+	}
+	return TypeJS
+}
+
+// NewRunner creates the runner of a script or an archive, as told by typ. The
+// type is detected from the data of the source if typ is empty.
+func NewRunner(
+	logger *logrus.Logger, src *loader.SourceData, typ string, filesystems map[string]afero.Fs, rtOpts lib.RuntimeOptions,
+	builtinMetrics *metrics.BuiltinMetrics, registry *metrics.Registry,
+) (runner lib.Runner, err error) {
+	switch typ {
+	case "":
+		runner, err = NewRunner(logger, src, DetectType(src.Data), filesystems, rtOpts, builtinMetrics, registry)
+	case TypeJS:
+		runner, err = js.New(logger, src, filesystems, rtOpts, builtinMetrics, registry)
+	case TypeArchive:
+		var arc *lib.Archive
+		arc, err = lib.ReadArchive(bytes.NewReader(src.Data))
+		if err != nil {
+			return nil, err
+		}
+		switch arc.Type {
+		case TypeJS:
+			runner, err = js.NewFromArchive(logger, arc, rtOpts, builtinMetrics, registry)
+		default:
+			return nil, fmt.Errorf("archive requests unsupported runner: %s", arc.Type)
+		}
+	default:
+		return nil, fmt.Errorf("unknown -t/--type: %s", typ)
+	}
+
+	return runner, err
+}
@@ -0,0 +1,231 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package testrun runs k6 tests from Go code, so k6 can be embedded in other
+// services without running the k6 binary.
+//
+// A test run is configured once and run once:
+//
+//	tr, err := testrun.Configure(testrun.Config{
+//		Script:  "/scripts/test.js",
+//		FS:      fs,
+//		Logger:  logger,
+//		Options: lib.Options{VUs: null.IntFrom(10), Duration: types.NullDurationFrom(time.Minute)},
+//	})
+//	if err != nil {
+//		return err
+//	}
+//	result, err := tr.Run(ctx)
+//
+// The test can be stopped early with Stop, from another goroutine, or by
+// cancelling the context given to Run. The live metrics and the threshold
+// evaluations can be received in process with a CallbackOutput.
+package testrun
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/afero"
+
+	"go.k6.io/k6/core/local"
+	"go.k6.io/k6/js/common"
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/fsext"
+	"go.k6.io/k6/lib/metrics"
+	"go.k6.io/k6/loader"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+// Config is the configuration of a test run.
+type Config struct {
+	// Script is the path of the script or the archive to run, or its URL.
+	Script string
+	// FS is the filesystem the script, its modules and the files it opens are
+	// read from. The filesystem of the OS is used if it's nil.
+	FS afero.Fs
+	// Pwd is the directory the relative paths are resolved from. It's the
+	// working directory if FS is nil, and the root directory otherwise.
+	Pwd string
+	// Logger receives the logs of k6 and of the script. The standard logger
+	// of logrus is used if it's nil.
+	Logger *logrus.Logger
+	// Options are applied on top of the options exported by the script.
+	Options lib.Options
+	// RuntimeOptions are the options which can't be set by the script.
+	RuntimeOptions lib.RuntimeOptions
+	// Outputs receive the metric samples of the test. They are started and
+	// stopped by Run.
+	Outputs []output.Output
+}
+
+// Result is the outcome of a finished test run.
+type Result struct {
+	// ThresholdsPassed is false if any of the thresholds has failed.
+	ThresholdsPassed bool
+	// Duration is how long the test ran, without the time it was paused.
+	Duration time.Duration
+	// Metrics are the metrics of the test, with their sinks and thresholds.
+	Metrics map[string]*stats.Metric
+}
+
+// TestRun is a configured test, which can be run once.
+type TestRun struct {
+	conf           Config
+	runner         lib.Runner
+	options        lib.Options
+	registry       *metrics.Registry
+	builtinMetrics *metrics.BuiltinMetrics
+
+	mx      sync.Mutex
+	started bool
+	stopped bool
+	engine  *Engine
+}
+
+// Configure loads the script and consolidates its options with the ones of
+// conf, without running anything yet.
+func Configure(conf Config) (*TestRun, error) {
+	if conf.Logger == nil {
+		conf.Logger = logrus.StandardLogger()
+	}
+
+	filesystems := loader.CreateFilesystems()
+	pwd := conf.Pwd
+	if conf.FS != nil {
+		filesystems["file"] = fsext.NewCacheOnReadFs(conf.FS, afero.NewMemMapFs(), 0)
+		if pwd == "" {
+			pwd = "/"
+		}
+	} else if pwd == "" {
+		var err error
+		if pwd, err = os.Getwd(); err != nil {
+			return nil, err
+		}
+	}
+	// The script can't be read from stdin, which belongs to the embedding service.
+	src, err := loader.ReadSource(conf.Logger, conf.Script, pwd, filesystems, bytes.NewReader(nil))
+	if err != nil {
+		return nil, err
+	}
+
+	registry := metrics.NewRegistry()
+	builtinMetrics := metrics.RegisterBuiltinMetrics(registry)
+	runner, err := NewRunner(conf.Logger, src, "", filesystems, conf.RuntimeOptions, builtinMetrics, registry)
+	if err != nil {
+		return nil, common.UnwrapGojaInterruptedError(err)
+	}
+
+	opts := ApplyDefaults(DefaultOptions().Apply(runner.GetOptions()).Apply(conf.Options))
+	if !conf.RuntimeOptions.NoThresholds.Bool {
+		if err = ParseThresholds(opts.Thresholds); err != nil {
+			return nil, err
+		}
+	}
+	opts, err = DeriveAndValidateOptions(opts, runner.IsExecutable, conf.Logger)
+	if err != nil {
+		return nil, err
+	}
+	if err = runner.SetOptions(opts); err != nil {
+		return nil, err
+	}
+
+	return &TestRun{
+		conf:           conf,
+		runner:         runner,
+		options:        opts,
+		registry:       registry,
+		builtinMetrics: builtinMetrics,
+	}, nil
+}
+
+// Options returns the consolidated options of the test.
+func (tr *TestRun) Options() lib.Options {
+	return tr.options
+}
+
+// Run runs the test until it finishes, ctx is cancelled or Stop is called. The
+// result is returned even if the script was interrupted, together with the
+// error which interrupted it.
+func (tr *TestRun) Run(ctx context.Context) (*Result, error) {
+	logger := tr.conf.Logger
+	tr.mx.Lock()
+	if tr.started {
+		tr.mx.Unlock()
+		return nil, errors.New("a test run can only be run once")
+	}
+	tr.started = true
+
+	execScheduler, err := local.NewExecutionScheduler(tr.runner, logger)
+	if err != nil {
+		tr.mx.Unlock()
+		return nil, err
+	}
+	engine, err := NewEngine(
+		execScheduler, tr.options, tr.conf.RuntimeOptions, tr.conf.Outputs, logger, tr.registry, tr.builtinMetrics)
+	if err != nil {
+		tr.mx.Unlock()
+		return nil, err
+	}
+	tr.engine = engine
+	if tr.stopped {
+		engine.Stop()
+	}
+	tr.mx.Unlock()
+
+	// The runCtx stops the test, while the globalCtx stops the processing of
+	// the metrics, which has to continue until the test has finished.
+	globalCtx, globalCancel := context.WithCancel(ctx)
+	defer globalCancel()
+	runCtx, runCancel := context.WithCancel(globalCtx)
+	defer runCancel()
+
+	if err = engine.StartOutputs(); err != nil {
+		return nil, err
+	}
+	defer engine.StopOutputs()
+
+	if err = engine.Init(globalCtx, runCtx); err != nil {
+		return nil, err
+	}
+	runErr := engine.Run()
+	runCancel()
+	globalCancel()
+	engine.Wait()
+
+	return engine.Result(), runErr
+}
+
+// Stop stops the test, if it's running, or makes Run return right away if
+// it hasn't started yet.
+func (tr *TestRun) Stop() {
+	tr.mx.Lock()
+	defer tr.mx.Unlock()
+	tr.stopped = true
+	if tr.engine != nil {
+		tr.engine.Stop()
+	}
+}
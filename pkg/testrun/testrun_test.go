@@ -0,0 +1,161 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package testrun
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib"
+	"go.k6.io/k6/lib/testutils"
+	"go.k6.io/k6/lib/testutils/mockoutput"
+	"go.k6.io/k6/output"
+	"go.k6.io/k6/stats"
+)
+
+func newTestFS(t *testing.T, files map[string]string) afero.Fs {
+	t.Helper()
+	fs := afero.NewMemMapFs()
+	for path, data := range files {
+		require.NoError(t, afero.WriteFile(fs, path, []byte(data), 0o644))
+	}
+	return fs
+}
+
+func TestTestRun(t *testing.T) {
+	t.Parallel()
+	fs := newTestFS(t, map[string]string{
+		"/scripts/lib.js": `export const value = 5;`,
+		"/scripts/test.js": `
+			import { Counter } from "k6/metrics";
+			import { value } from "./lib.js";
+			const counter = new Counter("my_counter");
+			export const options = {
+				iterations: 2,
+				thresholds: { my_counter: ["count<5"] },
+			};
+			export default function() { counter.add(value) }
+		`,
+	})
+
+	mockOutput := mockoutput.New()
+	tr, err := Configure(Config{
+		Script:  "test.js",
+		Pwd:     "/scripts",
+		FS:      fs,
+		Logger:  testutils.NewLogger(t),
+		Options: lib.Options{Iterations: null.IntFrom(3)},
+		Outputs: []output.Output{mockOutput},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, null.IntFrom(3), tr.Options().Iterations, "the given options override the script's")
+	assert.NotNil(t, tr.Options().SystemTags, "the defaults are applied")
+
+	result, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	assert.False(t, result.ThresholdsPassed)
+	require.Contains(t, result.Metrics, "my_counter")
+	assert.Equal(t, 15.0, result.Metrics["my_counter"].Sink.Format(0)["count"])
+	assert.NotEmpty(t, mockOutput.Samples)
+	assert.Equal(t, lib.RunStatusFinished, mockOutput.RunStatus)
+
+	_, err = tr.Run(context.Background())
+	assert.Error(t, err, "a test can only be run once")
+}
+
+func TestStop(t *testing.T) {
+	t.Parallel()
+	fs := newTestFS(t, map[string]string{
+		"/test.js": `
+			import { sleep } from "k6";
+			export const options = { vus: 1, duration: "1m" };
+			export default function() { sleep(0.05) }
+		`,
+	})
+	tr, err := Configure(Config{Script: "/test.js", FS: fs, Logger: testutils.NewLogger(t)})
+	require.NoError(t, err)
+
+	go func() {
+		time.Sleep(500 * time.Millisecond)
+		tr.Stop()
+	}()
+	start := time.Now()
+	result, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+	assert.True(t, result.ThresholdsPassed)
+	assert.Less(t, result.Duration, 10*time.Second)
+}
+
+func TestConfigureErrors(t *testing.T) {
+	t.Parallel()
+	fs := newTestFS(t, map[string]string{
+		"/syntax.js":     `export default function() {`,
+		"/thresholds.js": `export const options = { thresholds: { checks: ["rate>>1"] } }; export default function() {}`,
+		"/exec.js":       `export const options = { scenarios: { s: { executor: "shared-iterations", exec: "nope" } } }; export default function() {}`,
+	})
+	for _, script := range []string{"/missing.js", "/syntax.js", "/thresholds.js", "/exec.js"} {
+		_, err := Configure(Config{Script: script, FS: fs, Logger: testutils.NewLogger(t)})
+		assert.Error(t, err, script)
+	}
+}
+
+func TestTestRunMockServerFromSetup(t *testing.T) {
+	t.Parallel()
+	fs := newTestFS(t, map[string]string{
+		"/scripts/test.js": `
+			import http from "k6/http";
+			import { check } from "k6";
+			import mockserver from "k6/experimental/mockserver";
+			export const options = {
+				iterations: 3,
+				thresholds: { checks: ["rate==1"] },
+			};
+			export function setup() {
+				return mockserver.start({ routes: [{ path: "/hook", body: "ok" }] }).url;
+			}
+			export default function(url) {
+				check(http.get(url + "/hook"), { "the mock server responds": (r) => r.body === "ok" });
+			}
+		`,
+	})
+
+	tr, err := Configure(Config{
+		Script: "test.js",
+		Pwd:    "/scripts",
+		FS:     fs,
+		Logger: testutils.NewLogger(t),
+	})
+	require.NoError(t, err)
+	result, err := tr.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, result.ThresholdsPassed)
+	checks, ok := result.Metrics["checks"].Sink.(*stats.RateSink)
+	require.True(t, ok)
+	assert.Equal(t, int64(3), checks.Trues)
+	assert.Equal(t, int64(3), checks.Total)
+}
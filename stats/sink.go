@@ -22,6 +22,7 @@ package stats
 
 import (
 	"errors"
+	"fmt"
 	"math"
 	"sort"
 	"time"
@@ -32,6 +33,7 @@ var (
 	_ Sink = &GaugeSink{}
 	_ Sink = &TrendSink{}
 	_ Sink = &RateSink{}
+	_ Sink = &HistogramSink{}
 	_ Sink = &DummySink{}
 )
 
@@ -44,6 +46,9 @@ type Sink interface {
 type CounterSink struct {
 	Value float64
 	First time.Time
+	// ResetAt is the time in the test the values of the metric were reset
+	// at, which its rate is over the time since.
+	ResetAt time.Duration
 }
 
 func (c *CounterSink) Add(s Sample) {
@@ -58,17 +63,55 @@ func (c *CounterSink) Calc() {}
 func (c *CounterSink) Format(t time.Duration) map[string]float64 {
 	return map[string]float64{
 		"count": c.Value,
-		"rate":  c.Value / (float64(t) / float64(time.Second)),
+		"rate":  c.Rate(t),
 	}
 }
 
+// Rate returns the value per second of the counter at the time in the test,
+// over the time since the values were reset, or 0 if none has passed.
+func (c *CounterSink) Rate(t time.Duration) float64 {
+	elapsed := t - c.ResetAt
+	if elapsed <= 0 {
+		return 0
+	}
+	return c.Value / (float64(elapsed) / float64(time.Second))
+}
+
+// DefaultGaugeHalfLife is the half-life of the exponentially weighted moving
+// average of a GaugeSink, if it isn't set.
+const DefaultGaugeHalfLife = 10 * time.Second
+
 type GaugeSink struct {
 	Value    float64
 	Max, Min float64
 	minSet   bool
+
+	// EWMA is the exponentially weighted moving average of the values, in
+	// which the weight of a value halves every halfLife after it, so it's
+	// smoother than the last value of a noisy gauge.
+	EWMA     float64
+	halfLife time.Duration
+	last     time.Time
+}
+
+// NewGaugeSink returns a GaugeSink with the half-life of its moving average,
+// or the DefaultGaugeHalfLife if it's 0.
+func NewGaugeSink(halfLife time.Duration) *GaugeSink {
+	return &GaugeSink{halfLife: halfLife}
+}
+
+// HalfLife returns the half-life of the moving average.
+func (g *GaugeSink) HalfLife() time.Duration {
+	if g.halfLife <= 0 {
+		return DefaultGaugeHalfLife
+	}
+	return g.halfLife
 }
 
 func (g *GaugeSink) Add(s Sample) {
+	if !g.minSet {
+		g.EWMA, g.last = s.Value, s.Time
+	}
 	g.Value = s.Value
 	if s.Value > g.Max {
 		g.Max = s.Value
@@ -77,6 +120,18 @@ func (g *GaugeSink) Add(s Sample) {
 		g.Min = s.Value
 		g.minSet = true
 	}
+
+	// The weight of the value is the one the previous ones lost since the
+	// last one, so a value at the same time as it, or before it, doesn't
+	// move the average.
+	elapsed := s.Time.Sub(g.last)
+	if elapsed < 0 {
+		elapsed = 0
+	} else {
+		g.last = s.Time
+	}
+	weight := 1 - math.Exp2(-float64(elapsed)/float64(g.HalfLife()))
+	g.EWMA += weight * (s.Value - g.EWMA)
 }
 
 func (g *GaugeSink) Calc() {}
@@ -85,6 +140,42 @@ func (g *GaugeSink) Format(t time.Duration) map[string]float64 {
 	return map[string]float64{"value": g.Value}
 }
 
+// MaxTrendPrecision is the most significant digits a TrendSink can round its
+// values to.
+const MaxTrendPrecision = 6
+
+// PercentileMethod is how the percentiles of a TrendSink which fall between
+// two of its values are calculated.
+type PercentileMethod string
+
+const (
+	// PercentileLinear interpolates linearly between the two values, it's the
+	// default.
+	PercentileLinear PercentileMethod = "linear"
+	// PercentileNearestRank is the smallest value which at least the
+	// percentage of the values are less than or equal to, without
+	// interpolating.
+	PercentileNearestRank PercentileMethod = "nearest-rank"
+)
+
+// ParsePercentileMethod returns the PercentileMethod with the name.
+func ParsePercentileMethod(name string) (PercentileMethod, error) {
+	switch method := PercentileMethod(name); method {
+	case PercentileLinear, PercentileNearestRank:
+		return method, nil
+	default:
+		return "", fmt.Errorf("the percentile method should be %q or %q, not %q",
+			PercentileLinear, PercentileNearestRank, name)
+	}
+}
+
+// TrendSink keeps the values of a Trend metric for its percentiles. By default
+// all of the values are kept as they are, and the percentiles are exact. With
+// a precision, like a HDR histogram, the values are rounded to that many
+// significant digits and only counted, so the memory doesn't grow with the
+// number of samples, and every percentile is within a relative error of
+// 0.5*10^(1-precision) of the exact one, e.g. 0.05% with 4 digits. Min, max
+// and avg are always exact.
 type TrendSink struct {
 	Values  []float64
 	jumbled bool
@@ -93,10 +184,58 @@ type TrendSink struct {
 	Min, Max float64
 	Sum, Avg float64
 	Med      float64
+
+	method    PercentileMethod
+	precision int
+	buckets   map[float64]uint64
+	// nans are counted on their own, as NaN can't be a key of buckets.
+	nans uint64
+	// rounded are the keys of buckets in order, with their counts.
+	rounded []float64
+	counts  []uint64
+}
+
+// NewTrendSink returns a TrendSink which rounds its values to the significant
+// digits of the precision, or keeps them as they are if it's 0.
+func NewTrendSink(precision int) *TrendSink {
+	if precision <= 0 {
+		return &TrendSink{}
+	}
+	if precision > MaxTrendPrecision {
+		precision = MaxTrendPrecision
+	}
+	return &TrendSink{precision: precision, buckets: make(map[float64]uint64)}
+}
+
+// Precision returns the significant digits the values are rounded to, or 0
+// if they are kept as they are.
+func (t *TrendSink) Precision() int {
+	return t.precision
+}
+
+// SetPercentileMethod sets how the percentiles, and the median, are calculated.
+func (t *TrendSink) SetPercentileMethod(method PercentileMethod) {
+	t.method = method
+	t.jumbled = true
+}
+
+// PercentileMethod returns how the percentiles are calculated.
+func (t *TrendSink) PercentileMethod() PercentileMethod {
+	if t.method == "" {
+		return PercentileLinear
+	}
+	return t.method
 }
 
 func (t *TrendSink) Add(s Sample) {
-	t.Values = append(t.Values, s.Value)
+	switch {
+	case t.precision > 0 && math.IsNaN(s.Value):
+		t.nans++
+	case t.precision > 0:
+		t.buckets[roundToSignificant(s.Value, t.precision)]++
+	default:
+		t.Values = append(t.Values, s.Value)
+	}
 	t.jumbled = true
 	t.Count += 1
 	t.Sum += s.Value
@@ -110,39 +249,116 @@ func (t *TrendSink) Add(s Sample) {
 	}
 }
 
+// roundToSignificant rounds v to the significant digits.
+func roundToSignificant(v float64, digits int) float64 {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	scale := math.Pow(10, math.Floor(math.Log10(math.Abs(v)))-float64(digits)+1)
+	return math.Round(v/scale) * scale
+}
+
 // P calculates the given percentile from sink values.
 func (t *TrendSink) P(pct float64) float64 {
 	switch t.Count {
 	case 0:
 		return 0
 	case 1:
+		if t.precision > 0 {
+			return t.Min
+		}
 		return t.Values[0]
 	default:
+		if t.method == PercentileNearestRank {
+			t.Calc()
+			return t.nearestRank(pct)
+		}
 		// If percentile falls on a value in Values slice, we return that value.
 		// If percentile does not fall on a value in Values slice, we calculate (linear interpolation)
 		// the value that would fall at percentile, given the values above and below that percentile.
 		t.Calc()
 		i := pct * (float64(t.Count) - 1.0)
-		j := t.Values[int(math.Floor(i))]
-		k := t.Values[int(math.Ceil(i))]
+		j := t.valueAt(uint64(math.Floor(i)))
+		k := t.valueAt(uint64(math.Ceil(i)))
 		f := i - math.Floor(i)
 		return j + (k-j)*f
 	}
 }
 
+// nearestRank returns the value with the rank ceil(pct * Count) in the sorted
+// values, or the smallest one for the 0th percentile.
+func (t *TrendSink) nearestRank(pct float64) float64 {
+	rank := math.Ceil(pct * float64(t.Count))
+	if rank < 1 {
+		return t.valueAt(0)
+	}
+	if rank > float64(t.Count) {
+		return t.valueAt(t.Count - 1)
+	}
+	return t.valueAt(uint64(rank) - 1)
+}
+
+// valueAt returns the value with the index in the sorted values. The rounded
+// values are kept within the exact min and max.
+func (t *TrendSink) valueAt(i uint64) float64 {
+	if t.precision == 0 {
+		return t.Values[i]
+	}
+	var seen uint64
+	for n, count := range t.counts {
+		seen += count
+		if i < seen {
+			// compared rather than math.Min/Max, so a NaN min or max doesn't
+			// spread to every value
+			v := t.rounded[n]
+			if v < t.Min {
+				v = t.Min
+			}
+			if v > t.Max {
+				v = t.Max
+			}
+			return v
+		}
+	}
+	return t.Max
+}
+
 func (t *TrendSink) Calc() {
 	if !t.jumbled {
 		return
 	}
 
-	sort.Float64s(t.Values)
+	if t.precision > 0 {
+		t.rounded = t.rounded[:0]
+		for v := range t.buckets {
+			t.rounded = append(t.rounded, v)
+		}
+		sort.Float64s(t.rounded)
+		t.counts = t.counts[:0]
+		for _, v := range t.rounded {
+			t.counts = append(t.counts, t.buckets[v])
+		}
+		// NaNs are sorted first, like sort.Float64s does with the exact values
+		if t.nans > 0 {
+			t.rounded = append([]float64{math.NaN()}, t.rounded...)
+			t.counts = append([]uint64{t.nans}, t.counts...)
+		}
+	} else {
+		sort.Float64s(t.Values)
+	}
 	t.jumbled = false
 
-	// The median of an even number of values is the average of the middle two.
-	if (t.Count & 0x01) == 0 {
-		t.Med = (t.Values[(t.Count/2)-1] + t.Values[(t.Count/2)]) / 2
-	} else {
-		t.Med = t.Values[t.Count/2]
+	// The median of an even number of values is the average of the middle two,
+	// unless the percentiles aren't interpolated.
+	switch {
+	case t.Count == 0:
+		t.Med = 0
+	case t.method == PercentileNearestRank:
+		t.Med = t.nearestRank(0.5)
+	case (t.Count & 0x01) == 0:
+		t.Med = (t.valueAt((t.Count/2)-1) + t.valueAt(t.Count/2)) / 2
+	default:
+		t.Med = t.valueAt(t.Count / 2)
 	}
 }
 
@@ -159,6 +375,119 @@ func (t *TrendSink) Format(tt time.Duration) map[string]float64 {
 	}
 }
 
+// DefaultHistogramBuckets are the upper bounds of the buckets of a Histogram
+// metric which wasn't given any, the default ones of Prometheus in
+// milliseconds, which is what k6 measures times in.
+//nolint:gochecknoglobals
+var DefaultHistogramBuckets = []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000}
+
+// HistogramSink counts the values of a Histogram metric in buckets, so
+// outputs can send them as native histograms, instead of percentiles which
+// can't be aggregated. A value is counted in the first bucket whose upper
+// bound is greater than or equal to it, or in the last one, which has no
+// upper bound. Only the counts are kept, so the percentiles are estimated
+// like in Prometheus, by interpolating linearly within their bucket.
+type HistogramSink struct {
+	// Buckets are the upper bounds of the buckets, in ascending order.
+	Buckets []float64
+	// Counts are the numbers of values in each bucket, with one more for
+	// the values greater than the last upper bound; they aren't cumulative.
+	Counts []uint64
+
+	Count    uint64
+	Min, Max float64
+	Sum, Avg float64
+}
+
+// NewHistogramSink returns a HistogramSink with the upper bounds of the
+// buckets, which are sorted and must be distinct finite numbers.
+func NewHistogramSink(buckets []float64) (*HistogramSink, error) {
+	if len(buckets) == 0 {
+		return nil, errors.New("a histogram needs at least one bucket")
+	}
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+	for i, b := range sorted {
+		if math.IsNaN(b) || math.IsInf(b, 0) {
+			return nil, fmt.Errorf("the upper bound of a bucket should be a finite number, not %v", b)
+		}
+		if i > 0 && b == sorted[i-1] {
+			return nil, fmt.Errorf("the upper bound %v of a bucket is repeated", b)
+		}
+	}
+	return &HistogramSink{Buckets: sorted, Counts: make([]uint64, len(sorted)+1)}, nil
+}
+
+func (h *HistogramSink) Add(s Sample) {
+	h.Counts[sort.SearchFloat64s(h.Buckets, s.Value)]++
+	h.Count++
+	h.Sum += s.Value
+	h.Avg = h.Sum / float64(h.Count)
+
+	if s.Value > h.Max || h.Count == 1 {
+		h.Max = s.Value
+	}
+	if s.Value < h.Min || h.Count == 1 {
+		h.Min = s.Value
+	}
+}
+
+// Cumulative returns the numbers of values less than or equal to the upper
+// bound of every bucket, like the buckets of Prometheus, without the last one
+// which is Count.
+func (h *HistogramSink) Cumulative() []uint64 {
+	cumulative := make([]uint64, len(h.Buckets))
+	var seen uint64
+	for i := range h.Buckets {
+		seen += h.Counts[i]
+		cumulative[i] = seen
+	}
+	return cumulative
+}
+
+// P estimates the given percentile from the buckets. The values in a bucket
+// are assumed to be spread evenly between its bounds, which are narrowed to
+// the exact min and max.
+func (h *HistogramSink) P(pct float64) float64 {
+	if h.Count == 0 {
+		return 0
+	}
+	rank := pct * float64(h.Count)
+	var seen uint64
+	for i, count := range h.Counts {
+		if count == 0 || float64(seen+count) < rank {
+			seen += count
+			continue
+		}
+		lower, upper := h.Min, h.Max
+		if i > 0 && h.Buckets[i-1] > lower {
+			lower = h.Buckets[i-1]
+		}
+		if i < len(h.Buckets) && h.Buckets[i] < upper {
+			upper = h.Buckets[i]
+		}
+		f := (rank - float64(seen)) / float64(count)
+		if f < 0 {
+			f = 0
+		}
+		return lower + (upper-lower)*f
+	}
+	return h.Max
+}
+
+func (h *HistogramSink) Calc() {}
+
+func (h *HistogramSink) Format(t time.Duration) map[string]float64 {
+	return map[string]float64{
+		"count": float64(h.Count),
+		"sum":   h.Sum,
+		"min":   h.Min,
+		"max":   h.Max,
+		"avg":   h.Avg,
+	}
+}
+
 type RateSink struct {
 	Trues int64
 	Total int64
@@ -21,6 +21,7 @@
 package stats
 
 import (
+	"math"
 	"testing"
 	"time"
 
@@ -85,6 +86,26 @@ func TestGaugeSink(t *testing.T) {
 			assert.Equal(t, true, sink.minSet)
 			assert.Equal(t, 10.0, sink.Max)
 		})
+		t.Run("ewma", func(t *testing.T) {
+			sink := NewGaugeSink(time.Second)
+			start := time.Unix(1600000000, 0)
+			sink.Add(Sample{Metric: &Metric{}, Time: start, Value: 100})
+			assert.Equal(t, 100.0, sink.EWMA)
+
+			// the weight of 100 halved, so the average is halfway to 0
+			sink.Add(Sample{Metric: &Metric{}, Time: start.Add(time.Second), Value: 0})
+			assert.Equal(t, 50.0, sink.EWMA)
+			sink.Add(Sample{Metric: &Metric{}, Time: start.Add(3 * time.Second), Value: 0})
+			assert.Equal(t, 12.5, sink.EWMA)
+
+			// values at the same time as the last one, or before it, don't move it
+			sink.Add(Sample{Metric: &Metric{}, Time: start.Add(3 * time.Second), Value: 1000})
+			sink.Add(Sample{Metric: &Metric{}, Time: start, Value: 1000})
+			assert.Equal(t, 12.5, sink.EWMA)
+			assert.Equal(t, 1000.0, sink.Value)
+
+			assert.Equal(t, DefaultGaugeHalfLife, (&GaugeSink{}).HalfLife())
+		})
 	})
 	t.Run("calc", func(t *testing.T) {
 		sink := GaugeSink{}
@@ -223,6 +244,126 @@ func TestTrendSink(t *testing.T) {
 	})
 }
 
+func TestTrendSinkPrecision(t *testing.T) {
+	t.Parallel()
+
+	t.Run("exact", func(t *testing.T) {
+		t.Parallel()
+		sink := NewTrendSink(0)
+		assert.Equal(t, 0, sink.Precision())
+		sink.Add(Sample{Value: 1.23456789})
+		assert.Equal(t, []float64{1.23456789}, sink.Values)
+	})
+	t.Run("capped", func(t *testing.T) {
+		t.Parallel()
+		assert.Equal(t, MaxTrendPrecision, NewTrendSink(MaxTrendPrecision+3).Precision())
+	})
+	t.Run("one value", func(t *testing.T) {
+		t.Parallel()
+		sink := NewTrendSink(2)
+		sink.Add(Sample{Value: 1234})
+		assert.Equal(t, 1234.0, sink.P(0.99))
+		sink.Calc()
+		assert.Equal(t, 1234.0, sink.Med)
+	})
+	t.Run("NaN", func(t *testing.T) {
+		t.Parallel()
+		sink := NewTrendSink(3)
+		for i := 0; i < 1000; i++ {
+			sink.Add(Sample{Value: math.NaN()})
+		}
+		sink.Add(Sample{Value: 5})
+		assert.Empty(t, sink.Values)
+		assert.Len(t, sink.buckets, 1)
+		assert.Equal(t, uint64(1000), sink.nans)
+		assert.True(t, math.IsNaN(sink.P(0.5)))
+		assert.Equal(t, 5.0, sink.P(1))
+	})
+	t.Run("high percentiles", func(t *testing.T) {
+		t.Parallel()
+		exact := TrendSink{}
+		for precision := 1; precision <= MaxTrendPrecision; precision++ {
+			sink := NewTrendSink(precision)
+			for i := 1; i <= 100000; i++ {
+				// a long tail, from 1ms to ~1h
+				v := math.Pow(1.00015, float64(i))
+				sink.Add(Sample{Value: v})
+				if precision == 1 {
+					exact.Add(Sample{Value: v})
+				}
+			}
+			assert.Empty(t, sink.Values)
+			assert.LessOrEqual(t, len(sink.buckets), 9*int(math.Pow10(precision-1))*7)
+			assert.Equal(t, exact.Min, sink.Min)
+			assert.Equal(t, exact.Max, sink.Max)
+			assert.Equal(t, exact.Avg, sink.Avg)
+
+			relErr := 0.5 * math.Pow10(1-precision)
+			for _, pct := range []float64{0, 0.5, 0.9, 0.95, 0.99, 0.999, 0.9999, 1} {
+				expected := exact.P(pct)
+				assert.InEpsilon(t, expected, sink.P(pct), relErr, "p(%g) with %d digits", pct*100, precision)
+			}
+		}
+	})
+}
+
+func TestTrendSinkPercentileMethod(t *testing.T) {
+	t.Parallel()
+
+	newSink := func(precision int, method PercentileMethod, values ...float64) *TrendSink {
+		sink := NewTrendSink(precision)
+		sink.SetPercentileMethod(method)
+		for _, v := range values {
+			sink.Add(Sample{Value: v})
+		}
+		return sink
+	}
+
+	t.Run("linear", func(t *testing.T) {
+		t.Parallel()
+		sink := newSink(0, PercentileLinear, 15, 20, 35, 40, 50)
+		assert.Equal(t, PercentileLinear, sink.PercentileMethod())
+		assert.Equal(t, 29.0, sink.P(0.4))
+		assert.Equal(t, 48.0, sink.P(0.95))
+		assert.Equal(t, PercentileLinear, (&TrendSink{}).PercentileMethod())
+	})
+	t.Run("nearest rank", func(t *testing.T) {
+		t.Parallel()
+		for _, precision := range []int{0, 3} {
+			sink := newSink(precision, PercentileNearestRank, 50, 15, 40, 20, 35)
+			assert.Equal(t, PercentileNearestRank, sink.PercentileMethod())
+			assert.Equal(t, 15.0, sink.P(0))
+			assert.Equal(t, 15.0, sink.P(0.05))
+			assert.Equal(t, 20.0, sink.P(0.3))
+			assert.Equal(t, 20.0, sink.P(0.4))
+			assert.Equal(t, 35.0, sink.P(0.5))
+			assert.Equal(t, 50.0, sink.P(0.95))
+			assert.Equal(t, 50.0, sink.P(1))
+			sink.Calc()
+			assert.Equal(t, 35.0, sink.Med)
+		}
+
+		sink := newSink(0, PercentileNearestRank, 1, 2, 3, 4)
+		sink.Calc()
+		assert.Equal(t, 2.0, sink.Med, "the median isn't interpolated")
+	})
+	t.Run("empty", func(t *testing.T) {
+		t.Parallel()
+		sink := newSink(0, PercentileNearestRank)
+		sink.Calc()
+		assert.Equal(t, 0.0, sink.P(0.5))
+		assert.Equal(t, 0.0, sink.Med)
+	})
+	t.Run("parse", func(t *testing.T) {
+		t.Parallel()
+		method, err := ParsePercentileMethod("nearest-rank")
+		require.NoError(t, err)
+		assert.Equal(t, PercentileNearestRank, method)
+		_, err = ParsePercentileMethod("nearest")
+		assert.Error(t, err)
+	})
+}
+
 func TestRateSink(t *testing.T) {
 	samples6 := []float64{1.0, 0.0, 1.0, 0.0, 0.0, 1.0}
 
@@ -263,6 +404,53 @@ func TestRateSink(t *testing.T) {
 	})
 }
 
+func TestHistogramSink(t *testing.T) {
+	t.Run("new", func(t *testing.T) {
+		sink, err := NewHistogramSink([]float64{50, 10, 20})
+		require.NoError(t, err)
+		assert.Equal(t, []float64{10, 20, 50}, sink.Buckets)
+		assert.Equal(t, []uint64{0, 0, 0, 0}, sink.Counts)
+
+		for _, buckets := range [][]float64{nil, {1, 2, 1}, {1, math.NaN()}, {1, math.Inf(1)}} {
+			_, err = NewHistogramSink(buckets)
+			assert.Error(t, err, buckets)
+		}
+	})
+
+	newSink := func(t *testing.T) *HistogramSink {
+		sink, err := NewHistogramSink([]float64{10, 20, 50})
+		require.NoError(t, err)
+		for _, v := range []float64{5, 10, 15, 20, 30, 60} {
+			sink.Add(Sample{Metric: &Metric{}, Value: v})
+		}
+		return sink
+	}
+	t.Run("add", func(t *testing.T) {
+		sink := newSink(t)
+		assert.Equal(t, []uint64{2, 2, 1, 1}, sink.Counts)
+		assert.Equal(t, []uint64{2, 4, 5}, sink.Cumulative())
+		assert.Equal(t, uint64(6), sink.Count)
+		assert.Equal(t, 5.0, sink.Min)
+		assert.Equal(t, 60.0, sink.Max)
+		assert.Equal(t, 140.0, sink.Sum)
+		assert.InDelta(t, 23.333, sink.Avg, 0.001)
+	})
+	t.Run("percentiles", func(t *testing.T) {
+		sink := newSink(t)
+		assert.Equal(t, 5.0, sink.P(0))
+		assert.Equal(t, 15.0, sink.P(0.5))
+		assert.Equal(t, 60.0, sink.P(1))
+
+		empty, err := NewHistogramSink([]float64{10})
+		require.NoError(t, err)
+		assert.Equal(t, 0.0, empty.P(0.5))
+	})
+	t.Run("format", func(t *testing.T) {
+		sink := newSink(t)
+		assert.Equal(t, map[string]float64{"count": 6, "sum": 140, "min": 5, "max": 60, "avg": sink.Avg}, sink.Format(0))
+	})
+}
+
 func TestDummySinkAddPanics(t *testing.T) {
 	assert.Panics(t, func() {
 		DummySink{}.Add(Sample{})
@@ -25,6 +25,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -34,22 +35,25 @@ import (
 )
 
 const (
-	counterString = "counter"
-	gaugeString   = "gauge"
-	trendString   = "trend"
-	rateString    = "rate"
+	counterString   = "counter"
+	gaugeString     = "gauge"
+	trendString     = "trend"
+	rateString      = "rate"
+	histogramString = "histogram"
 
 	defaultString = "default"
 	timeString    = "time"
 	dataString    = "data"
+	percentString = "percent"
 )
 
 // Possible values for MetricType.
 const (
-	Counter = MetricType(iota) // A counter that sums its data points
-	Gauge                      // A gauge that displays the latest value
-	Trend                      // A trend, min/max/avg/med are interesting
-	Rate                       // A rate, displays % of values that aren't 0
+	Counter   = MetricType(iota) // A counter that sums its data points
+	Gauge                        // A gauge that displays the latest value
+	Trend                        // A trend, min/max/avg/med are interesting
+	Rate                         // A rate, displays % of values that aren't 0
+	Histogram                    // A histogram, counts the values in buckets
 )
 
 // Possible values for ValueType.
@@ -57,6 +61,7 @@ const (
 	Default = ValueType(iota) // Values are presented as-is
 	Time                      // Values are timestamps (nanoseconds)
 	Data                      // Values are data amounts (bytes)
+	Percent                   // Values are percentages (0-100)
 )
 
 // The serialized metric type is invalid.
@@ -88,6 +93,8 @@ func (t MetricType) MarshalText() ([]byte, error) {
 		return []byte(trendString), nil
 	case Rate:
 		return []byte(rateString), nil
+	case Histogram:
+		return []byte(histogramString), nil
 	default:
 		return nil, ErrInvalidMetricType
 	}
@@ -104,6 +111,8 @@ func (t *MetricType) UnmarshalText(data []byte) error {
 		*t = Trend
 	case rateString:
 		*t = Rate
+	case histogramString:
+		*t = Histogram
 	default:
 		return ErrInvalidMetricType
 	}
@@ -121,6 +130,8 @@ func (t MetricType) String() string {
 		return trendString
 	case Rate:
 		return rateString
+	case Histogram:
+		return histogramString
 	default:
 		return "[INVALID]"
 	}
@@ -147,6 +158,8 @@ func (t ValueType) MarshalText() ([]byte, error) {
 		return []byte(timeString), nil
 	case Data:
 		return []byte(dataString), nil
+	case Percent:
+		return []byte(percentString), nil
 	default:
 		return nil, ErrInvalidValueType
 	}
@@ -161,6 +174,8 @@ func (t *ValueType) UnmarshalText(data []byte) error {
 		*t = Time
 	case dataString:
 		*t = Data
+	case percentString:
+		*t = Percent
 	default:
 		return ErrInvalidValueType
 	}
@@ -176,6 +191,8 @@ func (t ValueType) String() string {
 		return timeString
 	case Data:
 		return dataString
+	case Percent:
+		return percentString
 	default:
 		return "[INVALID]"
 	}
@@ -186,10 +203,15 @@ func (t ValueType) String() string {
 // copy-on-write semantics and uses pointers for faster comparison
 // between maps, since the same tag set is often used for multiple samples.
 // All methods should not panic, even if they are called on a nil pointer.
+//
+// The values of some tags can be typed, numbers or booleans, for the outputs
+// which support them, if the typedTags option is enabled. Their string forms
+// are the values of the tags for everything else, e.g. the sub-metrics.
 //easyjson:skip
 type SampleTags struct {
-	tags map[string]string
-	json []byte
+	tags  map[string]string
+	typed map[string]interface{}
+	json  []byte
 }
 
 // Get returns an empty string and false if the the requested key is not
@@ -213,7 +235,7 @@ func (st *SampleTags) IsEqual(other *SampleTags) bool {
 	if st == other {
 		return true
 	}
-	if st == nil || other == nil || len(st.tags) != len(other.tags) {
+	if st == nil || other == nil || len(st.tags) != len(other.tags) || len(st.typed) != len(other.typed) {
 		return false
 	}
 	for k, v := range st.tags {
@@ -221,6 +243,11 @@ func (st *SampleTags) IsEqual(other *SampleTags) bool {
 			return false
 		}
 	}
+	for k := range st.typed {
+		if _, ok := other.typed[k]; !ok {
+			return false
+		}
+	}
 	return true
 }
 
@@ -252,7 +279,13 @@ func (st *SampleTags) MarshalJSON() ([]byte, error) {
 	if st.json != nil {
 		return st.json, nil
 	}
-	res, err := json.Marshal(st.tags)
+	var res []byte
+	var err error
+	if len(st.typed) == 0 {
+		res, err = json.Marshal(st.tags)
+	} else {
+		res, err = json.Marshal(st.CloneTypedTags())
+	}
 	if err != nil {
 		return res, err
 	}
@@ -272,17 +305,83 @@ func (st *SampleTags) MarshalEasyJSON(w *jwriter.Writer) {
 		}
 		w.String(k)
 		w.RawByte(':')
-		w.String(v)
+		switch tv := st.typed[k].(type) {
+		case float64:
+			w.Float64(tv)
+		case bool:
+			w.Bool(tv)
+		default:
+			w.String(v)
+		}
 	}
 	w.RawByte('}')
 }
 
-// UnmarshalJSON deserializes SampleTags from a JSON string.
+// UnmarshalJSON deserializes SampleTags from a JSON string, the number and
+// boolean values are typed.
 func (st *SampleTags) UnmarshalJSON(data []byte) error {
 	if st == nil {
 		*st = SampleTags{}
 	}
-	return json.Unmarshal(data, &st.tags)
+	var values map[string]interface{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+	if values == nil {
+		st.tags, st.typed = nil, nil
+		return nil
+	}
+	st.tags = make(map[string]string, len(values))
+	st.typed = nil
+	for k, v := range values {
+		switch tv := v.(type) {
+		case string:
+			st.tags[k] = tv
+		case float64, bool:
+			if st.typed == nil {
+				st.typed = make(map[string]interface{})
+			}
+			st.typed[k] = tv
+			st.tags[k] = FormatTagValue(tv)
+		default:
+			return fmt.Errorf("the value of the tag '%s' should be a string, a number or a boolean", k)
+		}
+	}
+	return nil
+}
+
+// TypedValue returns the value of the tag with the key, a float64 or a bool if
+// it's typed or else a string, and false if it isn't present.
+func (st *SampleTags) TypedValue(key string) (interface{}, bool) {
+	if st == nil {
+		return nil, false
+	}
+	if v, ok := st.typed[key]; ok {
+		return v, true
+	}
+	v, ok := st.tags[key]
+	if !ok {
+		return nil, false
+	}
+	return v, true
+}
+
+// CloneTypedTags returns a copy of the tags, with the values of the typed ones
+// as float64 or bool and the others as strings. If the receiver is nil, it
+// returns an empty non-nil map.
+func (st *SampleTags) CloneTypedTags() map[string]interface{} {
+	if st == nil {
+		return map[string]interface{}{}
+	}
+	res := make(map[string]interface{}, len(st.tags))
+	for k, v := range st.tags {
+		if tv, ok := st.typed[k]; ok {
+			res[k] = tv
+		} else {
+			res[k] = v
+		}
+	}
+	return res
 }
 
 // CloneTags copies the underlying set of a sample tags and
@@ -326,12 +425,73 @@ func IntoSampleTags(data *map[string]string) *SampleTags {
 	return &res
 }
 
+// IntoSampleTagsLike is like IntoSampleTags, but the tags with the same values
+// as in like keep their types, e.g. when only some of them were changed.
+func IntoSampleTagsLike(data *map[string]string, like *SampleTags) *SampleTags {
+	res := IntoSampleTags(data)
+	if res == nil || like == nil {
+		return res
+	}
+	for k, v := range like.typed {
+		if value, ok := res.tags[k]; !ok || value != like.tags[k] {
+			continue
+		}
+		if res.typed == nil {
+			res.typed = make(map[string]interface{})
+		}
+		res.typed[k] = v
+	}
+	return res
+}
+
+// IntoTypedSampleTags is like IntoSampleTags, but the tags of typed, which can
+// be float64 or bool values, are typed. Their string forms are set in data,
+// replacing any string values with the same keys.
+func IntoTypedSampleTags(data *map[string]string, typed map[string]interface{}) (*SampleTags, error) {
+	if len(typed) == 0 {
+		return IntoSampleTags(data), nil
+	}
+	if *data == nil {
+		*data = make(map[string]string, len(typed))
+	}
+	types := make(map[string]interface{}, len(typed))
+	for k, v := range typed {
+		switch v.(type) {
+		case float64, bool:
+		default:
+			return nil, fmt.Errorf("the typed value of the tag '%s' should be a number or a boolean, not %T", k, v)
+		}
+		types[k] = v
+		(*data)[k] = FormatTagValue(v)
+	}
+	res := IntoSampleTags(data)
+	res.typed = types
+	return res, nil
+}
+
+// FormatTagValue returns the string form of the value of a typed tag, as it's
+// matched by the sub-metrics, e.g. "1" for 1.0 and "true" for true.
+func FormatTagValue(v interface{}) string {
+	switch tv := v.(type) {
+	case float64:
+		return strconv.FormatFloat(tv, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(tv)
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
 // A Sample is a single measurement.
 type Sample struct {
 	Metric *Metric
 	Time   time.Time
 	Tags   *SampleTags
 	Value  float64
+	// Metadata are values which are attached to the sample, like trace IDs,
+	// without being tags, so they aren't used for the submetrics and the
+	// thresholds. The outputs can forward them, it's nil if there are none.
+	Metadata map[string]string
 }
 
 // SampleContainer is a simple abstraction that allows sample
@@ -349,6 +509,32 @@ func (s Samples) GetSamples() []Sample {
 	return s
 }
 
+// MetricReset is a SampleContainer without samples, which resets the values
+// of the metric and of its submetrics aggregated so far, when it's processed
+// after the samples emitted before it, e.g. for a phase of the test to report
+// its own values. The metric is only reset once per scenario, by the first of
+// its VUs which does it, the VU is the ID of the one which emitted it.
+type MetricReset struct {
+	Metric   *Metric
+	Time     time.Time
+	Scenario string
+	VU       uint64
+}
+
+// GetSamples implements the SampleContainer interface, with no samples.
+func (MetricReset) GetSamples() []Sample {
+	return nil
+}
+
+// ShiftableSampleContainer is an extension of the SampleContainer interface
+// that should be implemented by the containers which keep a time of their own
+// besides the ones of their samples, so all of them can be shifted in place,
+// e.g. by the clock offset of the instance.
+type ShiftableSampleContainer interface {
+	SampleContainer
+	ShiftTime(offset time.Duration)
+}
+
 // ConnectedSampleContainer is an extension of the SampleContainer
 // interface that should be implemented when emitted samples
 // are connected and share the same time and tags.
@@ -448,6 +634,26 @@ type Metric struct {
 	Submetrics []*Submetric `json:"submetrics"`
 	Sub        Submetric    `json:"sub,omitempty"`
 	Sink       Sink         `json:"-"`
+
+	// FirstSampleTime and LastSampleTime are the times of the earliest and
+	// the latest samples added to the metric, zero if it has none yet.
+	FirstSampleTime time.Time `json:"-"`
+	LastSampleTime  time.Time `json:"-"`
+}
+
+// TrackSampleTime widens the FirstSampleTime and LastSampleTime of the metric
+// to include t. Samples can arrive out of order, so it doesn't just keep the
+// first and the last of them.
+func (m *Metric) TrackSampleTime(t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	if m.FirstSampleTime.IsZero() || t.Before(m.FirstSampleTime) {
+		m.FirstSampleTime = t
+	}
+	if t.After(m.LastSampleTime) {
+		m.LastSampleTime = t
+	}
 }
 
 // Sample samples the metric at the given time, with the provided tags and value
@@ -475,6 +681,8 @@ func New(name string, typ MetricType, t ...ValueType) *Metric {
 		sink = &TrendSink{}
 	case Rate:
 		sink = &RateSink{}
+	case Histogram:
+		sink, _ = NewHistogramSink(DefaultHistogramBuckets)
 	default:
 		return nil
 	}
@@ -483,38 +691,100 @@ func New(name string, typ MetricType, t ...ValueType) *Metric {
 
 // A Submetric represents a filtered dataset based on a parent metric.
 type Submetric struct {
-	Name   string      `json:"name"`
-	Parent string      `json:"parent"`
-	Suffix string      `json:"suffix"`
+	Name   string `json:"name"`
+	Parent string `json:"parent"`
+	Suffix string `json:"suffix"`
+	// Tags are the tags with an exact value, which aren't in patterns.
 	Tags   *SampleTags `json:"tags"`
 	Metric *Metric     `json:"-"`
+
+	// patterns are the expressions the values of the other tags match.
+	patterns map[string]*regexp.Regexp
 }
 
-// Creates a submetric from a name.
+// Creates a submetric from a name. The values of the tags are exact, unless
+// the name of the tag is followed by a ~, which makes the value a pattern: a
+// regular expression the whole value matches, like in
+// http_req_duration{name~:/api/v1/.*}, or a group path after a ^, like in
+// group_duration{group~:^::checkout}, which matches the group and all of the
+// groups in it. The patterns can't contain commas. An invalid regular
+// expression never matches, and ParseSubmetric returns an error for it.
 func NewSubmetric(name string) (parentName string, sm *Submetric) {
+	parentName, sm, _ = ParseSubmetric(name)
+	return parentName, sm
+}
+
+// ParseSubmetric is like NewSubmetric, with an error if a regular expression
+// of the name is invalid.
+func ParseSubmetric(name string) (parentName string, sm *Submetric, err error) {
 	parts := strings.SplitN(strings.TrimSuffix(name, "}"), "{", 2)
 	if len(parts) == 1 {
-		return parts[0], &Submetric{Name: name}
+		return parts[0], &Submetric{Name: name}, nil
 	}
 
 	kvs := strings.Split(parts[1], ",")
 	tags := make(map[string]string, len(kvs))
+	var patterns map[string]*regexp.Regexp
 	for _, kv := range kvs {
 		if kv == "" {
 			continue
 		}
 		parts := strings.SplitN(kv, ":", 2)
 
-		key := strings.TrimSpace(strings.Trim(parts[0], `"'`))
+		key := strings.TrimSpace(parts[0])
+		isPattern := len(parts) == 2 && strings.HasSuffix(key, "~")
+		if isPattern {
+			key = strings.TrimSpace(strings.TrimSuffix(key, "~"))
+		}
+		key = strings.TrimSpace(strings.Trim(key, `"'`))
 		if len(parts) != 2 {
 			tags[key] = ""
 			continue
 		}
 
 		value := strings.TrimSpace(strings.Trim(parts[1], `"'`))
-		tags[key] = value
+		if !isPattern {
+			tags[key] = value
+			continue
+		}
+		pattern, perr := parseTagPattern(value)
+		if perr != nil {
+			pattern = regexp.MustCompile(`$.^`) // never matches
+			if err == nil {
+				err = fmt.Errorf("invalid regular expression of the tag %s of the submetric %s: %w", key, name, perr)
+			}
+		}
+		if patterns == nil {
+			patterns = make(map[string]*regexp.Regexp)
+		}
+		patterns[key] = pattern
+	}
+	sm = &Submetric{Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags), patterns: patterns}
+	return parts[0], sm, err
+}
+
+// parseTagPattern returns the expression the whole tag value of a submetric
+// matches, for a value marked as a pattern.
+func parseTagPattern(value string) (*regexp.Regexp, error) {
+	if strings.HasPrefix(value, "^") {
+		return regexp.Compile("^" + regexp.QuoteMeta(value[1:]) + "(?:::.*)?$")
+	}
+	return regexp.Compile("^(?:" + value + ")$")
+}
+
+// Matches returns true if the tags have the exact ones of the submetric, and
+// values matching its patterns.
+func (sm *Submetric) Matches(tags *SampleTags) bool {
+	if !tags.Contains(sm.Tags) {
+		return false
 	}
-	return parts[0], &Submetric{Name: name, Parent: parts[0], Suffix: parts[1], Tags: IntoSampleTags(&tags)}
+	for key, pattern := range sm.patterns {
+		value, ok := tags.Get(key)
+		if !ok || !pattern.MatchString(value) {
+			return false
+		}
+	}
+	return true
 }
 
 // parsePercentile is a helper function to parse and validate percentile notations
@@ -26,7 +26,9 @@ import (
 	"testing"
 	"time"
 
+	"github.com/mailru/easyjson/jwriter"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNew(t *testing.T) {
@@ -52,6 +54,22 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestMetricTrackSampleTime(t *testing.T) {
+	t.Parallel()
+	m := New("my_metric", Counter)
+	assert.True(t, m.FirstSampleTime.IsZero())
+	assert.True(t, m.LastSampleTime.IsZero())
+
+	start := time.Unix(1600000000, 0)
+	m.TrackSampleTime(start.Add(time.Minute))
+	m.TrackSampleTime(start)
+	m.TrackSampleTime(start.Add(2 * time.Minute))
+	m.TrackSampleTime(start.Add(30 * time.Second))
+	m.TrackSampleTime(time.Time{})
+	assert.Equal(t, start, m.FirstSampleTime)
+	assert.Equal(t, start.Add(2*time.Minute), m.LastSampleTime)
+}
+
 func TestNewSubmetric(t *testing.T) {
 	t.Parallel()
 	testdata := map[string]struct {
@@ -83,6 +101,70 @@ func TestNewSubmetric(t *testing.T) {
 	}
 }
 
+func TestSubmetricMatches(t *testing.T) {
+	t.Parallel()
+	tags := NewSampleTags(map[string]string{"name": "/api/v1/users", "method": "GET", "status": "200"})
+	testdata := map[string]bool{
+		"my_metric":                            true,
+		"my_metric{method:GET}":                true,
+		"my_metric{method:POST}":               false,
+		"my_metric{name~:/api/v1/.*}":          true,
+		"my_metric{name~:/api/v2/.*}":          false,
+		"my_metric{name~:/api}":                false,
+		"my_metric{name ~ :/api/.*/users}":     true,
+		"my_metric{'name'~:/api/.*}":           true,
+		"my_metric{name~:.*users,status:2..}":  false,
+		"my_metric{name~:.*users,status~:2..}": true,
+		"my_metric{group~:.*}":                 false,
+		"my_metric{method:GET,name~:/api/.*}":  true,
+		"my_metric{name:/api/*}":               false,
+		"my_metric{name:~/api/v1/.*}":          false,
+		"my_metric{name:^/api}":                false,
+	}
+
+	for name, matches := range testdata {
+		name, matches := name, matches
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			_, sm, err := ParseSubmetric(name)
+			require.NoError(t, err)
+			assert.Equal(t, matches, sm.Matches(tags))
+		})
+	}
+
+	_, sm, err := ParseSubmetric("my_metric{name~:(}")
+	assert.Error(t, err)
+	assert.False(t, sm.Matches(tags))
+	_, sm = NewSubmetric("my_metric{name~:(}")
+	assert.False(t, sm.Matches(tags))
+
+	// Without the marker, the values which look like patterns are exact.
+	exact := NewSampleTags(map[string]string{"name": "~/api/*", "group": "^::checkout"})
+	for _, name := range []string{"my_metric{name:~/api/*}", "my_metric{group:^::checkout}"} {
+		_, sm, err := ParseSubmetric(name)
+		require.NoError(t, err)
+		assert.True(t, sm.Matches(exact), name)
+		assert.False(t, sm.Matches(tags), name)
+	}
+}
+
+func TestSubmetricMatchesGroupSubtree(t *testing.T) {
+	t.Parallel()
+	_, sm, err := ParseSubmetric("group_duration{group~:^::checkout}")
+	require.NoError(t, err)
+	testdata := map[string]bool{
+		"::checkout":               true,
+		"::checkout::payment":      true,
+		"::checkout::payment::3ds": true,
+		"::checkouts":              false,
+		"::cart::checkout":         false,
+		"":                         false,
+	}
+	for group, matches := range testdata {
+		assert.Equal(t, matches, sm.Matches(NewSampleTags(map[string]string{"group": group})), group)
+	}
+}
+
 func TestSampleTags(t *testing.T) {
 	t.Parallel()
 
@@ -143,6 +225,41 @@ func TestSampleTags(t *testing.T) {
 	assert.Equal(t, tagMap, tagsUnmarshaled.CloneTags())
 }
 
+func TestTypedSampleTags(t *testing.T) {
+	t.Parallel()
+
+	tagMap := map[string]string{"key1": "val1", "count": "replaced"}
+	tags, err := IntoTypedSampleTags(&tagMap, map[string]interface{}{"count": 3.0, "cached": true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"key1": "val1", "count": "3", "cached": "true"}, tags.CloneTags())
+	assert.Equal(t, map[string]interface{}{"key1": "val1", "count": 3.0, "cached": true}, tags.CloneTypedTags())
+	v, ok := tags.TypedValue("count")
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, v)
+	v, ok = tags.TypedValue("key1")
+	assert.True(t, ok)
+	assert.Equal(t, "val1", v)
+	assert.False(t, tags.IsEqual(NewSampleTags(map[string]string{"key1": "val1", "count": "3", "cached": "true"})),
+		"the types of the values are compared too")
+	assert.True(t, tags.Contains(NewSampleTags(map[string]string{"count": "3"})))
+
+	expJSON := `{"key1":"val1","count":3,"cached":true}`
+	tagsJSON, err := json.Marshal(tags)
+	require.NoError(t, err)
+	assert.JSONEq(t, expJSON, string(tagsJSON))
+	w := &jwriter.Writer{}
+	tags.MarshalEasyJSON(w)
+	assert.JSONEq(t, expJSON, string(w.Buffer.BuildBytes()))
+
+	var tagsUnmarshaled *SampleTags
+	require.NoError(t, json.Unmarshal(tagsJSON, &tagsUnmarshaled))
+	assert.True(t, tagsUnmarshaled.IsEqual(tags))
+	assert.Equal(t, tags.CloneTypedTags(), tagsUnmarshaled.CloneTypedTags())
+
+	_, err = IntoTypedSampleTags(&map[string]string{}, map[string]interface{}{"count": 3})
+	assert.Error(t, err)
+}
+
 func TestSampleImplementations(t *testing.T) {
 	tagMap := map[string]string{"key1": "val1", "key2": "val2"}
 	now := time.Now()
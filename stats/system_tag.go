@@ -104,13 +104,33 @@ const (
 	TagVU
 	TagOCSPStatus
 	TagIP
+
+	// TagStatusClass isn't enabled by default, it's the class of the status
+	// of the response, e.g. 2xx, and it's only set when there was a response.
+	TagStatusClass
+	// TagInterrupted is enabled by default, it's only set on the samples
+	// of the requests and iterations which were interrupted, e.g. at the end
+	// of gracefulRampDown.
+	TagInterrupted
+	// TagIPFamily isn't enabled by default, it's ipv4 or ipv6, the family of
+	// the address the connection was made to, e.g. the one which won the race
+	// of the happyEyeballs DNS policy.
+	TagIPFamily
+	// TagGroupLevels isn't enabled by default, it adds a group_l1, group_l2,
+	// etc. tag for every level of the group path, besides the group one.
+	TagGroupLevels
+	// TagGroupName isn't enabled by default, it's the name of the innermost
+	// group, e.g. for the thresholds of a group whatever its parents are.
+	TagGroupName
 )
 
 // DefaultSystemTagSet includes all of the system tags emitted with metrics by default.
-// Other tags that are not enabled by default include: iter, vu, ocsp_status, ip
+// Other tags that are not enabled by default include: iter, vu, ocsp_status, ip, ip_family,
+// status_class, group_levels, group_name
 //nolint:gochecknoglobals
 var DefaultSystemTagSet = TagProto | TagSubproto | TagStatus | TagMethod | TagURL | TagName | TagGroup |
-	TagCheck | TagError | TagErrorCode | TagTLSVersion | TagScenario | TagService | TagExpectedResponse
+	TagCheck | TagError | TagErrorCode | TagTLSVersion | TagScenario | TagService | TagExpectedResponse |
+	TagInterrupted
 
 // Add adds a tag to tag set.
 func (i *SystemTagSet) Add(tag SystemTagSet) {
@@ -150,6 +170,21 @@ func (i SystemTagSet) SetString() string {
 	return strings.Join(keys, ",")
 }
 
+// FilterTags removes, in place, all system tags from the given tag map that
+// are not included in the set. Non-system (e.g. user-defined) tags are kept.
+// A nil set doesn't filter anything.
+func (i *SystemTagSet) FilterTags(tags map[string]string) map[string]string {
+	if i == nil {
+		return tags
+	}
+	for key := range tags {
+		if v, err := SystemTagSetString(key); err == nil && !i.Has(v) {
+			delete(tags, key)
+		}
+	}
+	return tags
+}
+
 // ToSystemTagSet converts list of tags to SystemTagSet
 // TODO: emit error instead of discarding invalid values.
 func ToSystemTagSet(tags []string) *SystemTagSet {
@@ -7,27 +7,32 @@ import (
 	"fmt"
 )
 
-const _SystemTagSetName = "protosubprotostatusmethodurlnamegroupcheckerrorerror_codetls_versionscenarioserviceexpected_responseitervuocsp_statusip"
+const _SystemTagSetName = "protosubprotostatusmethodurlnamegroupcheckerrorerror_codetls_versionscenarioserviceexpected_responseitervuocsp_statusipstatus_classinterruptedip_familygroup_levelsgroup_name"
 
 var _SystemTagSetMap = map[SystemTagSet]string{
-	1:      _SystemTagSetName[0:5],
-	2:      _SystemTagSetName[5:13],
-	4:      _SystemTagSetName[13:19],
-	8:      _SystemTagSetName[19:25],
-	16:     _SystemTagSetName[25:28],
-	32:     _SystemTagSetName[28:32],
-	64:     _SystemTagSetName[32:37],
-	128:    _SystemTagSetName[37:42],
-	256:    _SystemTagSetName[42:47],
-	512:    _SystemTagSetName[47:57],
-	1024:   _SystemTagSetName[57:68],
-	2048:   _SystemTagSetName[68:76],
-	4096:   _SystemTagSetName[76:83],
-	8192:   _SystemTagSetName[83:100],
-	16384:  _SystemTagSetName[100:104],
-	32768:  _SystemTagSetName[104:106],
-	65536:  _SystemTagSetName[106:117],
-	131072: _SystemTagSetName[117:119],
+	1:       _SystemTagSetName[0:5],
+	2:       _SystemTagSetName[5:13],
+	4:       _SystemTagSetName[13:19],
+	8:       _SystemTagSetName[19:25],
+	16:      _SystemTagSetName[25:28],
+	32:      _SystemTagSetName[28:32],
+	64:      _SystemTagSetName[32:37],
+	128:     _SystemTagSetName[37:42],
+	256:     _SystemTagSetName[42:47],
+	512:     _SystemTagSetName[47:57],
+	1024:    _SystemTagSetName[57:68],
+	2048:    _SystemTagSetName[68:76],
+	4096:    _SystemTagSetName[76:83],
+	8192:    _SystemTagSetName[83:100],
+	16384:   _SystemTagSetName[100:104],
+	32768:   _SystemTagSetName[104:106],
+	65536:   _SystemTagSetName[106:117],
+	131072:  _SystemTagSetName[117:119],
+	262144:  _SystemTagSetName[119:131],
+	524288:  _SystemTagSetName[131:142],
+	1048576: _SystemTagSetName[142:151],
+	2097152: _SystemTagSetName[151:163],
+	4194304: _SystemTagSetName[163:173],
 }
 
 func (i SystemTagSet) String() string {
@@ -37,7 +42,7 @@ func (i SystemTagSet) String() string {
 	return fmt.Sprintf("SystemTagSet(%d)", i)
 }
 
-var _SystemTagSetValues = []SystemTagSet{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536, 131072}
+var _SystemTagSetValues = []SystemTagSet{1, 2, 4, 8, 16, 32, 64, 128, 256, 512, 1024, 2048, 4096, 8192, 16384, 32768, 65536, 131072, 262144, 524288, 1048576, 2097152, 4194304}
 
 var _SystemTagSetNameToValueMap = map[string]SystemTagSet{
 	_SystemTagSetName[0:5]:     1,
@@ -58,6 +63,11 @@ var _SystemTagSetNameToValueMap = map[string]SystemTagSet{
 	_SystemTagSetName[104:106]: 32768,
 	_SystemTagSetName[106:117]: 65536,
 	_SystemTagSetName[117:119]: 131072,
+	_SystemTagSetName[119:131]: 262144,
+	_SystemTagSetName[131:142]: 524288,
+	_SystemTagSetName[142:151]: 1048576,
+	_SystemTagSetName[151:163]: 2097152,
+	_SystemTagSetName[163:173]: 4194304,
 }
 
 // SystemTagSetString retrieves an enum value from the enum constants string name.
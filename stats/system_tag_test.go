@@ -135,3 +135,15 @@ func TestTagSetTextUnmarshal(t *testing.T) {
 		require.Equal(t, expected, *set)
 	}
 }
+
+func TestSystemTagSetFilterTags(t *testing.T) {
+	t.Parallel()
+
+	tags := map[string]string{"status": "200", "url": "http://example.com", "name": "n", "custom": "value"}
+	set := NewSystemTagSet(TagStatus, TagName)
+	assert.Equal(t, map[string]string{"status": "200", "name": "n", "custom": "value"}, set.FilterTags(tags))
+
+	var nilSet *SystemTagSet
+	tags = map[string]string{"status": "200", "custom": "value"}
+	assert.Equal(t, map[string]string{"status": "200", "custom": "value"}, nilSet.FilterTags(tags))
+}
@@ -0,0 +1,188 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// TagTransforms contains the rules, keyed by tag name, that are used to
+// transform tag values before the samples reach the metric sinks and outputs.
+//
+// Each rule is a pipeline of transformation steps separated by `|`, e.g.
+// `truncate(64)|hash`. The supported steps are:
+//   - hash: replace the value with its hex-encoded SHA-256 hash
+//   - truncate(n): keep only the first n characters of the value
+//   - class: map a numeric status code to its class, e.g. 404 -> 4xx
+//   - map(from=to,...): replace the listed values, leave the rest unchanged
+type TagTransforms map[string]*TagTransform
+
+// TagTransform is a single parsed tag transformation rule.
+type TagTransform struct {
+	source string
+	steps  []func(string) string
+}
+
+// NewTagTransform parses the given rule and returns a new TagTransform.
+func NewTagTransform(rule string) (*TagTransform, error) {
+	tt := &TagTransform{}
+	if err := tt.UnmarshalText([]byte(rule)); err != nil {
+		return nil, err
+	}
+	return tt, nil
+}
+
+// Apply runs all of the steps of the rule over the given tag value.
+func (tt *TagTransform) Apply(value string) string {
+	for _, step := range tt.steps {
+		value = step(value)
+	}
+	return value
+}
+
+// String returns the original source of the rule.
+func (tt *TagTransform) String() string {
+	return tt.source
+}
+
+// MarshalText returns the original source of the rule.
+func (tt *TagTransform) MarshalText() ([]byte, error) {
+	return []byte(tt.source), nil
+}
+
+// UnmarshalText parses a tag transformation rule.
+func (tt *TagTransform) UnmarshalText(data []byte) error {
+	source := strings.TrimSpace(string(data))
+	if source == "" {
+		return fmt.Errorf("empty tag transformation rule")
+	}
+
+	var steps []func(string) string
+	for _, rawStep := range strings.Split(source, "|") {
+		step, err := parseTagTransformStep(strings.TrimSpace(rawStep))
+		if err != nil {
+			return fmt.Errorf("invalid tag transformation rule %q: %w", source, err)
+		}
+		steps = append(steps, step)
+	}
+
+	*tt = TagTransform{source: source, steps: steps}
+	return nil
+}
+
+func parseTagTransformStep(step string) (func(string) string, error) {
+	name, arg := step, ""
+	if idx := strings.IndexRune(step, '('); idx != -1 {
+		if !strings.HasSuffix(step, ")") {
+			return nil, fmt.Errorf("unterminated argument list for step '%s'", step)
+		}
+		name, arg = strings.TrimSpace(step[:idx]), strings.TrimSpace(step[idx+1:len(step)-1])
+	}
+
+	switch name {
+	case "hash":
+		return func(v string) string {
+			sum := sha256.Sum256([]byte(v))
+			return hex.EncodeToString(sum[:])
+		}, nil
+	case "truncate":
+		n, err := strconv.Atoi(arg)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("truncate expects a non-negative integer argument, got '%s'", arg)
+		}
+		return func(v string) string {
+			// n counts the characters, not the bytes, so that the
+			// multi-byte ones are never cut in half
+			count := 0
+			for i := range v {
+				if count == n {
+					return v[:i]
+				}
+				count++
+			}
+			return v
+		}, nil
+	case "class":
+		return StatusClass, nil
+	case "map":
+		mapping := make(map[string]string)
+		for _, pair := range strings.Split(arg, ",") {
+			kv := strings.SplitN(pair, "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("map expects 'from=to' pairs, got '%s'", pair)
+			}
+			mapping[strings.TrimSpace(kv[0])] = strings.TrimSpace(kv[1])
+		}
+		return func(v string) string {
+			if mapped, ok := mapping[v]; ok {
+				return mapped
+			}
+			return v
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown step '%s'", name)
+	}
+}
+
+// StatusClass returns the class of the given numeric status code, i.e.
+// "2xx" for "200". Values that aren't 3-digit status codes are returned as-is.
+func StatusClass(status string) string {
+	if len(status) != 3 || status[0] < '1' || status[0] > '9' {
+		return status
+	}
+	if _, err := strconv.Atoi(status); err != nil {
+		return status
+	}
+	return status[:1] + "xx"
+}
+
+// Apply returns the given sample tags with all of the transformation rules
+// applied. If none of the rules concern the tags, they are returned as-is.
+func (tts TagTransforms) Apply(tags *SampleTags) *SampleTags {
+	if len(tts) == 0 || tags.IsEmpty() {
+		return tags
+	}
+
+	var result map[string]string
+	for key, tt := range tts {
+		value, ok := tags.tags[key]
+		if !ok {
+			continue
+		}
+		newValue := tt.Apply(value)
+		if newValue == value {
+			continue
+		}
+		if result == nil {
+			result = tags.CloneTags()
+		}
+		result[key] = newValue
+	}
+
+	if result == nil {
+		return tags
+	}
+	return IntoSampleTagsLike(&result, tags)
+}
@@ -0,0 +1,92 @@
+/*
+ *
+ * k6 - a next-generation load testing tool
+ * Copyright (C) 2016 Load Impact
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Affero General Public License as
+ * published by the Free Software Foundation, either version 3 of the
+ * License, or (at your option) any later version.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Affero General Public License for more details.
+ *
+ * You should have received a copy of the GNU Affero General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package stats
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTagTransform(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		rule, input, expected string
+	}{
+		{"truncate(5)", "http://example.com", "http:"},
+		{"truncate(50)", "http://example.com", "http://example.com"},
+		{"truncate(3)", "日本語のURL", "日本語"},
+		{"truncate(4)", "żółw", "żółw"},
+		{"truncate(0)", "żółw", ""},
+		{"hash", "user1", "0a041b9462caa4a31bac3567e0b6e6fd9100787db2ab433d96f6d178cabfce90"},
+		{"hash | truncate(8)", "user1", "0a041b94"},
+		{"class", "404", "4xx"},
+		{"class", "0", "0"},
+		{"class", "abc", "abc"},
+		{"map(200=ok, 404=missing)", "404", "missing"},
+		{"map(200=ok, 404=missing)", "500", "500"},
+		{"class|map(2xx=good)", "201", "good"},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.rule+"/"+tc.input, func(t *testing.T) {
+			t.Parallel()
+			tt, err := NewTagTransform(tc.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, tt.Apply(tc.input))
+		})
+	}
+}
+
+func TestTagTransformInvalid(t *testing.T) {
+	t.Parallel()
+
+	rules := []string{"", "unknown", "truncate", "truncate(-1)", "truncate(5", "map(a)", "hash|"}
+	for _, rule := range rules {
+		_, err := NewTagTransform(rule)
+		assert.Error(t, err, rule)
+	}
+}
+
+func TestTagTransformsApply(t *testing.T) {
+	t.Parallel()
+
+	class, err := NewTagTransform("class")
+	require.NoError(t, err)
+	tts := TagTransforms{"status": class}
+
+	tags := NewSampleTags(map[string]string{"status": "503", "name": "n"})
+	assert.Equal(t, map[string]string{"status": "5xx", "name": "n"}, tts.Apply(tags).CloneTags())
+	assert.Equal(t, map[string]string{"status": "503", "name": "n"}, tags.CloneTags())
+
+	typedMap := map[string]string{"name": "n"}
+	typed, err := IntoTypedSampleTags(&typedMap, map[string]interface{}{"status": 503.0, "retry": true})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"status": "5xx", "name": "n", "retry": true},
+		tts.Apply(typed).CloneTypedTags(), "the tags which weren't transformed keep their types")
+
+	unaffected := NewSampleTags(map[string]string{"name": "n"})
+	assert.Same(t, unaffected, tts.Apply(unaffected))
+	assert.Nil(t, tts.Apply(nil))
+}
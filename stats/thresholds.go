@@ -22,26 +22,67 @@ package stats
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"gopkg.in/guregu/null.v3"
+
 	"go.k6.io/k6/lib/types"
 )
 
+// The severities of a threshold. A crossed threshold of the warn severity is
+// reported, but it doesn't fail the test.
+const (
+	ThresholdSeverityError = "error"
+	ThresholdSeverityWarn  = "warn"
+)
+
 // Threshold is a representation of a single threshold for a single metric
 type Threshold struct {
 	// Source is the text based source of the threshold
 	Source string
 	// LastFailed is a marker if the last testing of this threshold failed
 	LastFailed bool
+	// LastRun is when the threshold was last run, or zero if it never was
+	LastRun time.Time
 	// AbortOnFail marks if a given threshold fails that the whole test should be aborted
 	AbortOnFail bool
 	// AbortGracePeriod is a the minimum amount of time a test should be running before a failing
 	// this threshold will abort the test
 	AbortGracePeriod types.NullDuration
+	// ConsecutiveBreaches is how many runs in a row this threshold should
+	// fail before it aborts the test, 0 is the same as 1
+	ConsecutiveBreaches int64
+	// Severity is ThresholdSeverityWarn if the threshold doesn't fail the
+	// test, and empty or ThresholdSeverityError otherwise
+	Severity string
+	// Window is how long before the last sample the samples the threshold is
+	// evaluated with are, if it's set, instead of all of them
+	Window types.NullDuration
+	// Derivative is the duration the threshold is evaluated with the rate of
+	// change of its left hand side per, if it's set, instead of its value
+	Derivative types.NullDuration
 	// parsed is the threshold expression parsed from the Source
 	parsed *thresholdExpression
+	// breaches is how many of the last runs of the threshold failed in a row
+	breaches int64
+	// trend are the values of the left hand side at the runs of a threshold
+	// with a derivative, from the one the last derivative was measured from
+	trend []thresholdPoint
+	// derivative is the last rate of change of a threshold with a derivative,
+	// which is null until it was run twice at different times
+	derivative null.Float
+}
+
+// thresholdPoint is the value of the left hand side of a threshold at a run.
+type thresholdPoint struct {
+	at    time.Duration
+	value float64
 }
 
 func newThreshold(src string, abortOnFail bool, gracePeriod types.NullDuration) *Threshold {
@@ -55,13 +96,57 @@ func newThreshold(src string, abortOnFail bool, gracePeriod types.NullDuration)
 
 func (t *Threshold) runNoTaint(sinks map[string]float64) (bool, error) {
 	// Extract the sink value for the aggregation method used in the threshold
-	// expression
-	lhs, ok := sinks[t.parsed.AggregationMethod]
-	if !ok {
-		return false, fmt.Errorf("unable to apply threshold %s over metrics; reason: "+
-			"no metric supporting the %s aggregation method found",
-			t.Source,
-			t.parsed.AggregationMethod)
+	// expression, or evaluate the arithmetic expression on the left hand side
+	lhs, err := t.parsed.observed(sinks)
+	if errors.Is(err, errNoBuckets) {
+		return true, nil // no bucket could cross the limit yet
+	}
+	if err != nil {
+		return false, fmt.Errorf("unable to apply threshold %s over metrics; reason: %w", t.Source, err)
+	}
+	return t.compare(lhs, sinks)
+}
+
+// runDerivative is like runNoTaint, with the rate of change of the left hand
+// side per the Derivative, between the run at the time in the test and the
+// last one at least the Derivative before it, or the first one if there's
+// none. It passes until the rate of change can be measured.
+func (t *Threshold) runDerivative(sinks map[string]float64, at time.Duration) (bool, error) {
+	lhs, err := t.parsed.observed(sinks)
+	if err != nil {
+		return false, fmt.Errorf("unable to apply threshold %s over metrics; reason: %w", t.Source, err)
+	}
+
+	if n := len(t.trend); n > 0 && t.trend[n-1].at >= at {
+		t.trend = t.trend[:n-1]
+	}
+	t.trend = append(t.trend, thresholdPoint{at: at, value: lhs})
+	from := 0
+	for i, point := range t.trend[:len(t.trend)-1] {
+		if at-point.at >= time.Duration(t.Derivative.Duration) {
+			from = i
+		}
+	}
+	t.trend = t.trend[from:]
+	if len(t.trend) < 2 {
+		t.derivative = null.Float{}
+		return true, nil
+	}
+
+	first := t.trend[0]
+	rate := (lhs - first.value) / float64(at-first.at) * float64(t.Derivative.Duration)
+	t.derivative = null.FloatFrom(rate)
+	return t.compare(rate, sinks)
+}
+
+// compare returns whether the value of the left hand side passes the
+// threshold, with the operator and the value of its right hand side.
+func (t *Threshold) compare(lhs float64, sinks map[string]float64) (bool, error) {
+	// Evaluate the right hand side, which can be an arithmetic expression
+	// over the other aggregation methods of the metric, or of other metrics
+	rhs, err := t.parsed.limit(sinks)
+	if err != nil {
+		return false, fmt.Errorf("unable to apply threshold %s over metrics; reason: %w", t.Source, err)
 	}
 
 	// Apply the threshold expression operator to the left and
@@ -69,19 +154,19 @@ func (t *Threshold) runNoTaint(sinks map[string]float64) (bool, error) {
 	var passes bool
 	switch t.parsed.Operator {
 	case ">":
-		passes = lhs > t.parsed.Value
+		passes = lhs > rhs
 	case ">=":
-		passes = lhs >= t.parsed.Value
+		passes = lhs >= rhs
 	case "<=":
-		passes = lhs <= t.parsed.Value
+		passes = lhs <= rhs
 	case "<":
-		passes = lhs < t.parsed.Value
+		passes = lhs < rhs
 	case "==", "===":
 		// Considering a sink always maps to float64 values,
 		// strictly equal is equivalent to loosely equal
-		passes = lhs == t.parsed.Value
+		passes = lhs == rhs
 	case "!=":
-		passes = lhs != t.parsed.Value
+		passes = lhs != rhs
 	default:
 		// The parseThresholdExpression function should ensure that no invalid
 		// operator gets through, but let's protect our future selves anyhow.
@@ -96,16 +181,46 @@ func (t *Threshold) runNoTaint(sinks map[string]float64) (bool, error) {
 	return passes, nil
 }
 
-func (t *Threshold) run(sinks map[string]float64) (bool, error) {
-	passes, err := t.runNoTaint(sinks)
+// IsWarning returns true if crossing the threshold doesn't fail the test.
+func (t *Threshold) IsWarning() bool {
+	return t.Severity == ThresholdSeverityWarn
+}
+
+func (t *Threshold) run(sinks map[string]float64, at time.Duration) (bool, error) {
+	var passes bool
+	var err error
+	if t.Derivative.Valid {
+		passes, err = t.runDerivative(sinks, at)
+	} else {
+		passes, err = t.runNoTaint(sinks)
+	}
 	t.LastFailed = !passes
+	if passes {
+		t.breaches = 0
+	} else {
+		t.breaches++
+	}
 	return passes, err
 }
 
+// breachedEnough returns true if the threshold failed as many runs in a row
+// as it should before aborting the test.
+func (t *Threshold) breachedEnough() bool {
+	return t.breaches >= t.ConsecutiveBreaches
+}
+
 type thresholdConfig struct {
 	Threshold        string             `json:"threshold"`
 	AbortOnFail      bool               `json:"abortOnFail"`
 	AbortGracePeriod types.NullDuration `json:"delayAbortEval"`
+	Severity         string             `json:"severity,omitempty"`
+	Window           *types.Duration    `json:"window,omitempty"`
+	// ConsecutiveBreaches is how many runs in a row the threshold should
+	// fail before it aborts the test
+	ConsecutiveBreaches int64 `json:"consecutiveBreaches,omitempty"`
+	// Derivative is the duration the threshold is evaluated with the rate of
+	// change per
+	Derivative *types.Duration `json:"derivative,omitempty"`
 }
 
 // used internally for JSON marshalling
@@ -118,12 +233,31 @@ func (tc *thresholdConfig) UnmarshalJSON(data []byte) error {
 	}
 
 	rawConfig := (*rawThresholdConfig)(tc)
-	return json.Unmarshal(data, rawConfig)
+	if err := json.Unmarshal(data, rawConfig); err != nil {
+		return err
+	}
+	if tc.Window != nil && *tc.Window <= 0 {
+		return fmt.Errorf("the window of the threshold %q should be more than 0", tc.Threshold)
+	}
+	if tc.Derivative != nil && *tc.Derivative <= 0 {
+		return fmt.Errorf("the derivative of the threshold %q should be more than 0", tc.Threshold)
+	}
+	if tc.ConsecutiveBreaches < 0 {
+		return fmt.Errorf("the consecutiveBreaches of the threshold %q shouldn't be negative", tc.Threshold)
+	}
+	switch tc.Severity {
+	case "", ThresholdSeverityError, ThresholdSeverityWarn:
+		return nil
+	default:
+		return fmt.Errorf("the severity of the threshold %q should be %q or %q, but it's %q",
+			tc.Threshold, ThresholdSeverityError, ThresholdSeverityWarn, tc.Severity)
+	}
 }
 
 func (tc thresholdConfig) MarshalJSON() ([]byte, error) {
 	var data interface{} = tc.Threshold
-	if tc.AbortOnFail {
+	if tc.AbortOnFail || tc.Severity != "" || tc.Window != nil || tc.Derivative != nil ||
+		tc.ConsecutiveBreaches != 0 {
 		data = rawThresholdConfig(tc)
 	}
 
@@ -135,6 +269,14 @@ type Thresholds struct {
 	Thresholds []*Threshold
 	Abort      bool
 	sinked     map[string]float64
+	// windowSinked are the values of the thresholds with a window, by window
+	windowSinked map[time.Duration]map[string]float64
+	window       *thresholdWindow
+	// buckets aggregate the samples of the thresholds over time buckets,
+	// by the length of the buckets
+	buckets map[time.Duration]*thresholdBuckets
+	// baseline are the values of the metric in the baseline, by aggregation method
+	baseline map[string]float64
 }
 
 // NewThresholds returns Thresholds objects representing the provided source strings
@@ -153,24 +295,295 @@ func newThresholdsWithConfig(configs []thresholdConfig) Thresholds {
 
 	for i, config := range configs {
 		t := newThreshold(config.Threshold, config.AbortOnFail, config.AbortGracePeriod)
+		t.Severity = config.Severity
+		t.ConsecutiveBreaches = config.ConsecutiveBreaches
+		if config.Window != nil {
+			t.Window = types.NullDurationFrom(time.Duration(*config.Window))
+		}
+		if config.Derivative != nil {
+			t.Derivative = types.NullDurationFrom(time.Duration(*config.Derivative))
+		}
 		thresholds[i] = t
 	}
 
-	return Thresholds{thresholds, false, sinked}
+	return Thresholds{Thresholds: thresholds, sinked: sinked}
+}
+
+// Merge adds the thresholds of other which ts doesn't have one with the same
+// source of yet.
+func (ts *Thresholds) Merge(other Thresholds) {
+	for _, t := range other.Thresholds {
+		found := false
+		for _, existing := range ts.Thresholds {
+			if existing.Source == t.Source {
+				found = true
+				break
+			}
+		}
+		if !found {
+			ts.Thresholds = append(ts.Thresholds[:len(ts.Thresholds):len(ts.Thresholds)], t)
+		}
+	}
+}
+
+// UsesBaseline returns true if one of the parsed thresholds compares with the
+// value of its aggregation method in the baseline.
+func (ts *Thresholds) UsesBaseline() bool {
+	for _, t := range ts.Thresholds {
+		if t.parsed != nil && t.parsed.usesBaseline() {
+			return true
+		}
+	}
+	return false
+}
+
+// SetBaseline sets the values of the metric in the baseline, by aggregation
+// method, which the thresholds are compared with. It returns an error if one
+// of the aggregation methods they compare isn't in it.
+func (ts *Thresholds) SetBaseline(values map[string]float64) error {
+	for _, t := range ts.Thresholds {
+		if t.parsed == nil || !t.parsed.usesBaseline() {
+			continue
+		}
+		if _, ok := values[t.parsed.AggregationMethod]; !ok {
+			return fmt.Errorf("the threshold %s compares with the baseline, which has no %s value",
+				t.Source, t.parsed.AggregationMethod)
+		}
+	}
+	ts.baseline = values
+	return nil
+}
+
+// AggregationMethods returns the aggregation methods of the metric which the
+// parsed thresholds use, in order.
+func (ts *Thresholds) AggregationMethods() []string {
+	seen := make(map[string]bool)
+	for _, t := range ts.Thresholds {
+		if t.parsed == nil {
+			continue
+		}
+		t.parsed.eachAggregation(func(metric, method string, _ null.Float) {
+			if metric == "" && method != "" {
+				seen[method] = true
+			}
+		})
+	}
+	methods := make([]string, 0, len(seen))
+	for method := range seen {
+		methods = append(methods, method)
+	}
+	sort.Strings(methods)
+	return methods
+}
+
+// AddSample keeps the sample for the thresholds with a window, if there are
+// any, as they are evaluated with only the samples within it, and adds it to
+// the time buckets of the ones with an aggregation method over them.
+func (ts *Thresholds) AddSample(sample Sample) {
+	if ts.buckets == nil {
+		for _, t := range ts.Thresholds {
+			if t.parsed == nil || t.parsed.Bucket == 0 {
+				continue
+			}
+			if ts.buckets == nil {
+				ts.buckets = make(map[time.Duration]*thresholdBuckets)
+			}
+			ts.buckets[t.parsed.Bucket] = &thresholdBuckets{
+				length:  t.parsed.Bucket,
+				buckets: make(map[int64]*thresholdBucket),
+			}
+		}
+	}
+	for _, b := range ts.buckets {
+		b.add(sample)
+	}
+
+	if ts.window == nil {
+		var length time.Duration
+		for _, t := range ts.Thresholds {
+			if t.Window.Valid && time.Duration(t.Window.Duration) > length {
+				length = time.Duration(t.Window.Duration)
+			}
+		}
+		if length == 0 {
+			return
+		}
+		ts.window = &thresholdWindow{length: length}
+	}
+	ts.window.add(sample)
+}
+
+// Reset drops the samples of the windows and the time buckets of the
+// thresholds, and the state of their runs, e.g. the rate of change of the ones
+// with a derivative and their breaches in a row, when the values of the metric
+// are reset. The baseline is kept.
+func (ts *Thresholds) Reset() {
+	ts.sinked = make(map[string]float64)
+	ts.window = nil
+	ts.windowSinked = nil
+	ts.buckets = nil
+	for _, t := range ts.Thresholds {
+		t.LastFailed = false
+		t.breaches = 0
+		t.trend = nil
+		t.derivative = null.Float{}
+	}
+}
+
+// sinksOf returns the values the threshold is evaluated with, the ones of its
+// window if it has one.
+func (ts *Thresholds) sinksOf(t *Threshold) map[string]float64 {
+	if !t.Window.Valid {
+		return ts.sinked
+	}
+	if sinked, ok := ts.windowSinked[time.Duration(t.Window.Duration)]; ok {
+		return sinked
+	}
+	return ts.sinked
+}
+
+// minWindowPrune is the fewest samples a thresholdWindow drops the old ones at.
+const minWindowPrune = 1024
+
+// thresholdWindow keeps the samples of a metric within the longest window of
+// its thresholds, before the time of the last sample.
+type thresholdWindow struct {
+	length  time.Duration
+	samples []Sample
+	last    time.Time
+	// pruneAt is how many samples there are when the old ones are dropped,
+	// so they aren't at every sample.
+	pruneAt int
+}
+
+func (w *thresholdWindow) add(sample Sample) {
+	if sample.Time.After(w.last) {
+		w.last = sample.Time
+	}
+	w.samples = append(w.samples, sample)
+	if len(w.samples) < w.pruneAt {
+		return
+	}
+	start := w.last.Add(-w.length)
+	kept := w.samples[:0]
+	for _, s := range w.samples {
+		if s.Time.After(start) {
+			kept = append(kept, s)
+		}
+	}
+	w.samples = kept
+	w.pruneAt = 2 * len(kept)
+	if w.pruneAt < minWindowPrune {
+		w.pruneAt = minWindowPrune
+	}
+}
+
+// sink returns a new sink of the type of like, with the samples within the
+// window before the last one. A DummySink can't be windowed, so it's like.
+func (w *thresholdWindow) sink(like Sink, window time.Duration) Sink {
+	var sink Sink
+	switch likeImpl := like.(type) {
+	case *CounterSink:
+		sink = &CounterSink{}
+	case *GaugeSink:
+		sink = NewGaugeSink(likeImpl.HalfLife())
+	case *TrendSink:
+		trendSink := NewTrendSink(likeImpl.Precision())
+		trendSink.SetPercentileMethod(likeImpl.PercentileMethod())
+		sink = trendSink
+	case *RateSink:
+		sink = &RateSink{}
+	case *HistogramSink:
+		sink, _ = NewHistogramSink(likeImpl.Buckets)
+	default:
+		return like
+	}
+	if w == nil {
+		return sink
+	}
+	start := w.last.Add(-window)
+	for _, s := range w.samples {
+		if s.Time.After(start) {
+			sink.Add(s)
+		}
+	}
+	return sink
+}
+
+// thresholdBucket is the aggregation of the samples of a time bucket.
+type thresholdBucket struct {
+	min, max, sum float64
+	count         int64
+}
+
+// thresholdBuckets aggregates the samples of a metric over fixed time buckets
+// of the length, which start at the times of the samples truncated to it.
+type thresholdBuckets struct {
+	length  time.Duration
+	buckets map[int64]*thresholdBucket
+}
+
+func (b *thresholdBuckets) add(sample Sample) {
+	start := sample.Time.Truncate(b.length).UnixNano()
+	bucket, ok := b.buckets[start]
+	if !ok {
+		bucket = &thresholdBucket{min: sample.Value, max: sample.Value}
+		b.buckets[start] = bucket
+	}
+	if sample.Value < bucket.min {
+		bucket.min = sample.Value
+	}
+	if sample.Value > bucket.max {
+		bucket.max = sample.Value
+	}
+	bucket.sum += sample.Value
+	bucket.count++
+}
+
+// extremes returns the lowest and the highest value of the aggregation method
+// of the buckets, or false if there are no buckets.
+func (b *thresholdBuckets) extremes(method string) (lowest, highest float64, ok bool) {
+	if b == nil {
+		return 0, 0, false
+	}
+	for _, bucket := range b.buckets {
+		var value float64
+		switch method {
+		case tokenMin:
+			value = bucket.min
+		case tokenMax:
+			value = bucket.max
+		default:
+			value = bucket.sum / float64(bucket.count)
+		}
+		if !ok || value < lowest {
+			lowest = value
+		}
+		if !ok || value > highest {
+			highest = value
+		}
+		ok = true
+	}
+	return lowest, highest, ok
 }
 
 func (ts *Thresholds) runAll(timeSpentInTest time.Duration) (bool, error) {
 	succeeded := true
+	now := time.Now()
 	for i, threshold := range ts.Thresholds {
-		b, err := threshold.run(ts.sinked)
+		b, err := threshold.run(ts.sinksOf(threshold), timeSpentInTest)
 		if err != nil {
 			return false, fmt.Errorf("threshold %d run error: %w", i, err)
 		}
+		threshold.LastRun = now
 
 		if !b {
+			if threshold.IsWarning() {
+				continue
+			}
 			succeeded = false
 
-			if ts.Abort || !threshold.AbortOnFail {
+			if ts.Abort || !threshold.AbortOnFail || !threshold.breachedEnough() {
 				continue
 			}
 
@@ -185,9 +598,118 @@ func (ts *Thresholds) runAll(timeSpentInTest time.Duration) (bool, error) {
 // Run processes all the thresholds with the provided Sink at the provided time and returns if any
 // of them fails
 func (ts *Thresholds) Run(sink Sink, duration time.Duration) (bool, error) {
+	return ts.RunWithMetrics(sink, duration, nil)
+}
+
+// RunWithMetrics is like Run, with the metrics the threshold expressions can
+// refer to besides the one of the Sink. A referenced metric which isn't in
+// metrics, as it had no samples yet, has values of 0, like a counter which
+// was never incremented.
+func (ts *Thresholds) RunWithMetrics(sink Sink, duration time.Duration, metrics map[string]*Metric) (bool, error) {
 	// Initialize the sinks store
 	ts.sinked = make(map[string]float64)
 
+	// Gather the aggregation methods used by the threshold expressions, by
+	// metric, with their percentiles
+	methods := make(map[string]map[string]null.Float)
+	for _, threshold := range ts.Thresholds {
+		threshold.parsed.eachAggregation(func(metric, method string, value null.Float) {
+			if methods[metric] == nil {
+				methods[metric] = make(map[string]null.Float)
+			}
+			methods[metric][method] = value
+		})
+	}
+
+	if err := addSinkAggregations(ts.sinked, "", sink, duration, methods[""]); err != nil {
+		return false, err
+	}
+	for name, metricMethods := range methods {
+		if name == "" {
+			continue
+		}
+		m, ok := metrics[name]
+		if !ok {
+			for method := range metricMethods {
+				ts.sinked[sinkKey(name, method)] = 0
+			}
+			continue
+		}
+		if err := addSinkAggregations(ts.sinked, name, m.Sink, duration, metricMethods); err != nil {
+			return false, err
+		}
+		if _, ok := metricMethods[""]; ok {
+			ts.sinked[name] = ts.sinked[sinkKey(name, defaultAggregationMethod(m.Type))]
+		}
+	}
+
+	for method, value := range ts.baseline {
+		ts.sinked[baselineKey(method)] = value
+	}
+
+	// The thresholds over time buckets are evaluated with the bucket which
+	// is the closest to crossing their limit
+	for _, threshold := range ts.Thresholds {
+		if threshold.parsed.Bucket == 0 {
+			continue
+		}
+		method := threshold.parsed.AggregationMethod
+		lowest, highest, ok := ts.buckets[threshold.parsed.Bucket].extremes(threshold.parsed.BucketMethod)
+		if ok {
+			ts.sinked[bucketKey(method, tokenGreater)] = lowest
+			ts.sinked[bucketKey(method, tokenLess)] = highest
+		}
+	}
+
+	// The thresholds with a window are evaluated with the values of the
+	// metric's samples within it, and the cumulative ones of the others
+	ts.windowSinked = nil
+	for _, threshold := range ts.Thresholds {
+		window := time.Duration(threshold.Window.Duration)
+		if _, ok := ts.windowSinked[window]; ok || !threshold.Window.Valid {
+			continue
+		}
+		sinked := make(map[string]float64, len(ts.sinked))
+		for k, v := range ts.sinked {
+			sinked[k] = v
+		}
+		rateDuration := window
+		if duration > 0 && duration < window {
+			rateDuration = duration
+		}
+		err := addSinkAggregations(sinked, "", ts.window.sink(sink, window), rateDuration, methods[""])
+		if err != nil {
+			return false, err
+		}
+		if ts.windowSinked == nil {
+			ts.windowSinked = make(map[time.Duration]map[string]float64)
+		}
+		ts.windowSinked[window] = sinked
+	}
+
+	return ts.runAll(duration)
+}
+
+// defaultAggregationMethod is the aggregation method of a metric referenced
+// only by name in a threshold expression.
+func defaultAggregationMethod(typ MetricType) string {
+	switch typ {
+	case Counter:
+		return tokenCount
+	case Gauge:
+		return tokenValue
+	case Rate:
+		return tokenRate
+	default:
+		return tokenAvg
+	}
+}
+
+// addSinkAggregations adds the values of the aggregation methods of the sink
+// of the metric to sinked, by their sinkKey, with the percentiles of methods.
+func addSinkAggregations(
+	sinked map[string]float64, metric string, sink Sink, duration time.Duration, methods map[string]null.Float,
+) error {
 	// FIXME: Remove this comment as soon as the stats.Sink does not expose Format anymore.
 	//
 	// As of December 2021, this block reproduces the behavior of the
@@ -197,36 +719,156 @@ func (ts *Thresholds) Run(sink Sink, duration time.Duration) (bool, error) {
 	// For more details, see https://github.com/grafana/k6/issues/2320
 	switch sinkImpl := sink.(type) {
 	case *CounterSink:
-		ts.sinked["count"] = sinkImpl.Value
-		ts.sinked["rate"] = sinkImpl.Value / (float64(duration) / float64(time.Second))
+		sinked[sinkKey(metric, "count")] = sinkImpl.Value
+		sinked[sinkKey(metric, "rate")] = sinkImpl.Rate(duration)
+		sinked[sinkKey(metric, tokenRatePerSecond)] = sinked[sinkKey(metric, "rate")]
 	case *GaugeSink:
-		ts.sinked["value"] = sinkImpl.Value
+		sinked[sinkKey(metric, "value")] = sinkImpl.Value
+		sinked[sinkKey(metric, tokenEWMA)] = sinkImpl.EWMA
 	case *TrendSink:
-		ts.sinked["min"] = sinkImpl.Min
-		ts.sinked["max"] = sinkImpl.Max
-		ts.sinked["avg"] = sinkImpl.Avg
-		ts.sinked["med"] = sinkImpl.Med
+		sinked[sinkKey(metric, "min")] = sinkImpl.Min
+		sinked[sinkKey(metric, "max")] = sinkImpl.Max
+		sinked[sinkKey(metric, "avg")] = sinkImpl.Avg
+		sinked[sinkKey(metric, "med")] = sinkImpl.Med
 
 		// Parse the percentile thresholds and insert them in
 		// the sinks mapping.
-		for _, threshold := range ts.Thresholds {
-			if !strings.HasPrefix(threshold.parsed.AggregationMethod, "p(") {
-				continue
+		for method, value := range methods {
+			if value.Valid {
+				sinked[sinkKey(metric, method)] = sinkImpl.P(value.Float64 / 100)
 			}
-
-			ts.sinked[threshold.parsed.AggregationMethod] = sinkImpl.P(threshold.parsed.AggregationValue.Float64 / 100)
 		}
 	case *RateSink:
-		ts.sinked["rate"] = float64(sinkImpl.Trues) / float64(sinkImpl.Total)
+		sinked[sinkKey(metric, "rate")] = float64(sinkImpl.Trues) / float64(sinkImpl.Total)
+	case *HistogramSink:
+		sinked[sinkKey(metric, "count")] = float64(sinkImpl.Count)
+		sinked[sinkKey(metric, "min")] = sinkImpl.Min
+		sinked[sinkKey(metric, "max")] = sinkImpl.Max
+		sinked[sinkKey(metric, "avg")] = sinkImpl.Avg
+		sinked[sinkKey(metric, "med")] = sinkImpl.P(0.5)
+
+		// The percentiles are estimated from the buckets.
+		for method, value := range methods {
+			if value.Valid {
+				sinked[sinkKey(metric, method)] = sinkImpl.P(value.Float64 / 100)
+			}
+		}
 	case DummySink:
 		for k, v := range sinkImpl {
-			ts.sinked[k] = v
+			sinked[sinkKey(metric, k)] = v
 		}
 	default:
-		return false, fmt.Errorf("unable to run Thresholds; reason: unknown sink type")
+		return fmt.Errorf("unable to run Thresholds; reason: unknown sink type")
 	}
 
-	return ts.runAll(duration)
+	return nil
+}
+
+// AggregatedValue returns the value of the aggregation method of the sink,
+// one of the ones of the threshold expressions, e.g. "count" or "p(95)", with
+// its rates over the duration. The sink should be calculated already.
+func AggregatedValue(sink Sink, method string, duration time.Duration) (float64, error) {
+	method, percentile, err := parseThresholdAggregationMethod(method)
+	if err != nil {
+		return 0, err
+	}
+	sinked := make(map[string]float64)
+	if err := addSinkAggregations(sinked, "", sink, duration, map[string]null.Float{method: percentile}); err != nil {
+		return 0, err
+	}
+	value, ok := sinked[method]
+	if !ok {
+		return 0, fmt.Errorf("the aggregation method %q isn't supported by the metric", method)
+	}
+	return value, nil
+}
+
+// ThresholdResult is the outcome of the last run of a threshold. The limit is
+// the value of the right hand side at the last run, or 0 if it's an arithmetic
+// expression which couldn't be evaluated. The margin is how far the observed
+// value is from crossing the limit, it's negative if it crossed it. A
+// threshold of the warn severity which didn't pass didn't fail the test.
+type ThresholdResult struct {
+	Source      string     `json:"threshold"`
+	Aggregation string     `json:"aggregation"`
+	Operator    string     `json:"operator"`
+	Limit       float64    `json:"limit"`
+	Observed    null.Float `json:"observed"`
+	Margin      null.Float `json:"margin"`
+	Passed      bool       `json:"passed"`
+	AbortOnFail bool       `json:"abortOnFail"`
+	Severity    string     `json:"severity,omitempty"`
+	// Window is the window of the threshold, or 0 if it has none
+	Window types.Duration `json:"window,omitempty"`
+	// Derivative is the derivative of the threshold, or 0 if it has none
+	Derivative types.Duration `json:"derivative,omitempty"`
+	// LastRun is when the threshold was last run, or null if it never was
+	LastRun null.Time `json:"lastRun"`
+}
+
+// Results returns the outcomes of the last run of the thresholds, with the
+// value of the metric each one was compared to. The observed value is null if
+// the thresholds were never run, or the metric didn't have the aggregation.
+// The observed value of a threshold with a derivative is its rate of change,
+// which is null until it was measured.
+// The one of a threshold over time buckets is the value of the bucket which is
+// the closest to crossing its limit.
+func (ts *Thresholds) Results() []ThresholdResult {
+	results := make([]ThresholdResult, 0, len(ts.Thresholds))
+	for _, t := range ts.Thresholds {
+		result := ThresholdResult{
+			Source: t.Source, Passed: !t.LastFailed, AbortOnFail: t.AbortOnFail, Severity: t.Severity,
+		}
+		if t.Window.Valid {
+			result.Window = t.Window.Duration
+		}
+		if t.Derivative.Valid {
+			result.Derivative = t.Derivative.Duration
+		}
+		if !t.LastRun.IsZero() {
+			result.LastRun = null.TimeFrom(t.LastRun)
+		}
+		sinked := ts.sinksOf(t)
+		if t.parsed != nil {
+			result.Aggregation = t.parsed.AggregationMethod
+			if t.parsed.Left != nil {
+				result.Aggregation, _, _, _ = scanThresholdExpression(t.Source)
+			}
+			result.Operator = t.parsed.Operator
+			if limit, err := t.parsed.limit(sinked); err == nil && !math.IsNaN(limit) && !math.IsInf(limit, 0) {
+				result.Limit = limit
+			}
+			observed, err := t.parsed.observed(sinked)
+			if t.Derivative.Valid {
+				observed, err = t.derivative.Float64, nil
+				if !t.derivative.Valid {
+					err = errors.New("the rate of change wasn't measured yet")
+				}
+			}
+			if err == nil && !math.IsNaN(observed) && !math.IsInf(observed, 0) {
+				result.Observed = null.FloatFrom(observed)
+				result.Margin = null.FloatFrom(thresholdMargin(result.Operator, observed, result.Limit))
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// thresholdMargin returns how far the observed value is from crossing the
+// limit with the operator, or from passing it if it's negative. With == it's
+// never positive, as any difference crosses the limit.
+func thresholdMargin(operator string, observed, limit float64) float64 {
+	switch operator {
+	case "<", "<=":
+		return limit - observed
+	case ">", ">=":
+		return observed - limit
+	case "!=":
+		return math.Abs(observed - limit)
+	default: // == and ===
+		return -math.Abs(observed - limit)
+	}
 }
 
 // Parse parses the Thresholds and fills each Threshold.parsed field with the result.
@@ -244,6 +886,44 @@ func (ts *Thresholds) Parse() error {
 	return nil
 }
 
+// ValidatePercentiles returns an error if a threshold has a percentile out of
+// the 0-100 range, or one which a TrendSink with the precision can't give,
+// the thresholds which can't be parsed are left to Parse. A percentile can't
+// have more significant digits than the values are rounded to, so e.g.
+// p(99.99) needs a precision of at least 4; any percentile can be given when
+// the values are kept as they are, with a precision of 0.
+func (ts Thresholds) ValidatePercentiles(precision int) error {
+	for _, t := range ts.Thresholds {
+		parsed, err := parseThresholdExpression(t.Source)
+		if err != nil {
+			continue
+		}
+		parsed.eachAggregation(func(_, _ string, value null.Float) {
+			if err != nil || !value.Valid {
+				return
+			}
+			p := value.Float64
+			if p < 0 || p > 100 {
+				err = fmt.Errorf("the percentile of %q should be between 0 and 100", t.Source)
+			} else if digits := percentileDigits(p); precision > 0 && digits > precision {
+				err = fmt.Errorf("the percentile of %q needs a trendPrecision of at least %d significant digits, "+
+					"but it's %d", t.Source, digits, precision)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// percentileDigits returns the significant digits of the percentile, e.g. 2
+// for 95 and 5 for 99.999.
+func percentileDigits(p float64) int {
+	digits := strings.Replace(strconv.FormatFloat(p, 'f', -1, 64), ".", "", 1)
+	return len(strings.Trim(digits, "0"))
+}
+
 // UnmarshalJSON is implementation of json.Unmarshaler
 func (ts *Thresholds) UnmarshalJSON(data []byte) error {
 	var configs []thresholdConfig
@@ -263,6 +943,16 @@ func (ts Thresholds) MarshalJSON() ([]byte, error) {
 		configs[i].Threshold = t.Source
 		configs[i].AbortOnFail = t.AbortOnFail
 		configs[i].AbortGracePeriod = t.AbortGracePeriod
+		configs[i].Severity = t.Severity
+		configs[i].ConsecutiveBreaches = t.ConsecutiveBreaches
+		if t.Window.Valid {
+			window := t.Window.Duration
+			configs[i].Window = &window
+		}
+		if t.Derivative.Valid {
+			derivative := t.Derivative.Duration
+			configs[i].Derivative = &derivative
+		}
 	}
 
 	return MarshalJSONWithoutHTMLEscape(configs)
@@ -21,11 +21,15 @@
 package stats
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/guregu/null.v3"
+
+	"go.k6.io/k6/lib/types"
 )
 
 // thresholdExpression holds the parsed result of a threshold expression,
@@ -33,7 +37,8 @@ import (
 type thresholdExpression struct {
 	// AggregationMethod holds the aggregation method parsed
 	// from the threshold expression. Possible values are described
-	// by `aggregationMethodTokens`.
+	// by `aggregationMethodTokens`. It's empty when the left hand side
+	// is an arithmetic expression, which is then in Left.
 	AggregationMethod string
 
 	// AggregationValue will hold the aggregation method's pivot value
@@ -47,22 +52,105 @@ type thresholdExpression struct {
 
 	// Value holds the value parsed from the threshold expression.
 	Value float64
+
+	// Left holds the left hand side when it's an arithmetic expression,
+	// for instance `checks_failed / http_reqs`, and is nil otherwise.
+	Left *thresholdArithmetic
+
+	// Right holds the right hand side when it's an arithmetic expression
+	// over aggregation methods, for instance `avg * 2`. It's nil when the
+	// right hand side is a number, which is then in Value.
+	Right *thresholdArithmetic
+
+	// Bucket is the length of the time buckets when the aggregation method
+	// is over them, for instance `max_over(10s)`, and is 0 otherwise. The
+	// BucketMethod is then the aggregation method of every bucket.
+	Bucket       time.Duration
+	BucketMethod string
+}
+
+// errNoBuckets is returned as the observed value of an aggregation method
+// over time buckets before the metric had any samples in a bucket.
+var errNoBuckets = errors.New("there are no time buckets with samples yet")
+
+// eachAggregation calls fn with every aggregation method of the expression,
+// on both sides, with its percentile if it has one. The metric is empty for
+// the aggregation methods of the metric of the threshold, and the method is
+// empty for the metrics referenced without one.
+func (te *thresholdExpression) eachAggregation(fn func(metric, method string, value null.Float)) {
+	if te.Left != nil {
+		te.Left.eachAggregation(fn)
+	} else {
+		fn("", te.AggregationMethod, te.AggregationValue)
+	}
+	if te.Right != nil {
+		te.Right.eachAggregation(fn)
+	}
+}
+
+// observed returns the value of the left hand side with the sinks.
+func (te *thresholdExpression) observed(sinks map[string]float64) (float64, error) {
+	if te.Left != nil {
+		return te.Left.eval(sinks)
+	}
+	if te.Bucket > 0 {
+		value, ok := sinks[bucketKey(te.AggregationMethod, te.Operator)]
+		if !ok {
+			return 0, errNoBuckets
+		}
+		return value, nil
+	}
+	value, ok := sinks[te.AggregationMethod]
+	if !ok {
+		return 0, fmt.Errorf("no metric supporting the %s aggregation method found", te.AggregationMethod)
+	}
+	return value, nil
+}
+
+// usesBaseline returns true if the right hand side compares with the value
+// of the aggregation method in the baseline.
+func (te *thresholdExpression) usesBaseline() bool {
+	return te.Right != nil && te.Right.hasBaseline()
+}
+
+// limit returns the value of the right hand side with the sinks.
+func (te *thresholdExpression) limit(sinks map[string]float64) (float64, error) {
+	if te.Right == nil {
+		return te.Value, nil
+	}
+	return te.Right.eval(sinks)
 }
 
 // parseThresholdAssertion parses a threshold condition expression,
 // as defined in a JS script (for instance p(95)<1000), into a thresholdExpression
 // instance.
 //
-// It is expected to be of the form: `aggregation_method operator value`.
+// It is expected to be of the form: `aggregation_method operator value`,
+// where the value can be an arithmetic expression over aggregation methods of
+// the same metric, for instance `p(95) < avg * 2`. Both sides can also refer
+// to other metrics by name, as `metric/aggregation_method`, or only by name in
+// arithmetic expressions for their count, value, rate or avg, depending on
+// their type, for instance `checks_failed / http_reqs < 0.01`. The right hand
+// side can refer to the value of the aggregation method of the left hand side
+// in the summary of a previous test as `baseline`, for instance
+// `p(95) < baseline * 1.1`. The left hand side can also be the min, max or avg
+// over fixed time buckets, for instance `max_over(10s) < 500`, which every
+// bucket should pass, compared with <, <=, > or >=.
 // As defined by the following BNF:
 // ```
-// assertion           -> aggregation_method whitespace* operator whitespace* float
+// assertion           -> (aggregation_method | bucket_method | expression) whitespace* operator whitespace* expression
+// expression          -> term (whitespace* ("+" | "-") whitespace* term)*
+// term                -> operand (whitespace* ("*" | "/") whitespace* operand)*
+// operand             -> float | aggregation_method | metric | "baseline" | ("+" | "-") operand | "(" expression ")"
+// metric              -> name ("/" aggregation_method)?
+// name                -> (letter | "_") (letter | digit | "_")*
 // aggregation_method  -> trend | rate | gauge | counter
-// counter             -> "count" | "rate"
-// gauge               -> "value"
+// counter             -> "count" | "rate" | "rate_per_second"
+// gauge               -> "value" | "ewma"
 // rate                -> "rate"
 // trend               -> "avg" | "min" | "max" | "med" | percentile
 // percentile          -> "p(" float ")"
+// bucket_method       -> ("min" | "max" | "avg") "_over(" duration ")"
 // operator            -> ">" | ">=" | "<=" | "<" | "==" | "===" | "!="
 // float               -> digit+ ("." digit+)?
 // digit               -> "0" | "1" | "2" | "3" | "4" | "5" | "6" | "7" | "8" | "9"
@@ -76,27 +164,65 @@ func parseThresholdExpression(input string) (*thresholdExpression, error) {
 		return nil, fmt.Errorf("failed parsing threshold expression %q; reason: %w", input, err)
 	}
 
+	condition := &thresholdExpression{Operator: operator}
 	parsedMethod, parsedMethodValue, err := parseThresholdAggregationMethod(method)
-	if err != nil {
+	bucketMethod, bucket, isBucketed, berr := parseThresholdBucketMethod(method)
+	if isBucketed {
+		if berr != nil {
+			return nil, fmt.Errorf("failed parsing threshold expression's %q left hand side; "+
+				"reason: %w", input, berr)
+		}
+		if operator != tokenLess && operator != tokenLessEqual &&
+			operator != tokenGreater && operator != tokenGreaterEqual {
+			return nil, fmt.Errorf("failed parsing threshold expression %q; reason: an aggregation "+
+				"over time buckets can only be compared with <, <=, > or >=", input)
+		}
+		condition.AggregationMethod = method
+		condition.Bucket, condition.BucketMethod = bucket, bucketMethod
+	} else if err == nil {
+		condition.AggregationMethod = parsedMethod
+		condition.AggregationValue = parsedMethodValue
+	} else if left, lerr := parseThresholdArithmetic(method); lerr == nil && !left.isNumber() && !left.isBareMetric() {
+		if left.hasBaseline() {
+			return nil, fmt.Errorf("failed parsing threshold expression's %q left hand side; "+
+				"reason: the baseline can only be on the right hand side", input)
+		}
+		condition.Left = left
+	} else {
 		err = fmt.Errorf("failed parsing threshold expression's %q left hand side; "+
 			"reason: %w", input, err,
 		)
 		return nil, err
 	}
 
-	parsedValue, err := strconv.ParseFloat(value, 64)
+	// Most thresholds compare with a number, which doesn't need the
+	// arithmetic parser.
+	if parsedValue, err := strconv.ParseFloat(value, 64); err == nil {
+		condition.Value = parsedValue
+		return condition, nil
+	}
+
+	arithmetic, err := parseThresholdArithmetic(value)
+	if err == nil && arithmetic.isBareMetric() {
+		err = fmt.Errorf("%q is neither a number nor an aggregation method", value)
+	}
 	if err != nil {
 		err = fmt.Errorf("failed parsing threshold expresion's %q right hand side; "+
 			"reason: %w", input, err,
 		)
 		return nil, err
 	}
-
-	condition := &thresholdExpression{
-		AggregationMethod: parsedMethod,
-		AggregationValue:  parsedMethodValue,
-		Operator:          operator,
-		Value:             parsedValue,
+	if arithmetic.hasBaseline() {
+		if condition.Left != nil || condition.Bucket > 0 {
+			return nil, fmt.Errorf("failed parsing threshold expresion's %q right hand side; "+
+				"reason: the baseline requires an aggregation method on the left hand side", input)
+		}
+		arithmetic.setBaselineMethod(condition.AggregationMethod, condition.AggregationValue)
+	}
+	if arithmetic.isNumber() {
+		condition.Value = arithmetic.Value
+	} else {
+		condition.Right = arithmetic
 	}
 
 	return condition, nil
@@ -155,6 +281,7 @@ func scanThresholdExpression(input string) (string, string, string, error) {
 // Percentile token `p(..)` is accepted too but handled separately.
 const (
 	tokenValue      = "value"
+	tokenEWMA       = "ewma"
 	tokenCount      = "count"
 	tokenRate       = "rate"
 	tokenAvg        = "avg"
@@ -164,16 +291,26 @@ const (
 	tokenPercentile = "p"
 )
 
+// tokenRatePerSecond is the count of a Counter per second, like its rate, but
+// explicitly so, e.g. for the minimum throughput of a test.
+const tokenRatePerSecond = "rate_per_second"
+
+// tokenBaseline is the value of the aggregation method of the left hand side
+// in the baseline, in the right hand side of a threshold expression.
+const tokenBaseline = "baseline"
+
 // aggregationMethodTokens defines the list of aggregation method
 // used in the parsing of threshold expressions.
 //
 // It is meant to be used during the parsing of threshold expressions.
 // Although declared as a `var`, being an array, it is effectively
 // immutable and can be considered constant.
-var aggregationMethodTokens = [8]string{ // nolint:gochecknoglobals
+var aggregationMethodTokens = [10]string{ // nolint:gochecknoglobals
 	tokenValue,
+	tokenEWMA,
 	tokenCount,
 	tokenRate,
+	tokenRatePerSecond,
 	tokenAvg,
 	tokenMin,
 	tokenMed,
@@ -209,6 +346,356 @@ func parseThresholdAggregationMethod(input string) (string, null.Float, error) {
 	return "", null.Float{}, fmt.Errorf("failed parsing method from expression")
 }
 
+// tokenOverSuffix is the suffix of the aggregation methods over fixed time
+// buckets, like `max_over(10s)`.
+const tokenOverSuffix = "_over"
+
+// parseThresholdBucketMethod parses an aggregation method over fixed time
+// buckets, like `max_over(10s)`, into the aggregation method of every bucket
+// and their length. It returns false if the input isn't one, and an error if
+// it is one which is invalid.
+func parseThresholdBucketMethod(input string) (string, time.Duration, bool, error) {
+	open := strings.Index(input, tokenOverSuffix+"(")
+	if open < 0 || !strings.HasSuffix(input, ")") {
+		return "", 0, false, nil
+	}
+	method := input[:open]
+	if method != tokenMin && method != tokenMax && method != tokenAvg {
+		return "", 0, true, fmt.Errorf("the aggregation method over time buckets should be min, max or avg, not %q",
+			method)
+	}
+	bucket, err := types.ParseExtendedDuration(input[open+len(tokenOverSuffix)+1 : len(input)-1])
+	if err != nil {
+		return "", 0, true, fmt.Errorf("malformed length of the time buckets; reason: %w", err)
+	}
+	if bucket <= 0 {
+		return "", 0, true, fmt.Errorf("the length of the time buckets should be more than 0")
+	}
+	return method, bucket, true, nil
+}
+
 func trimDelimited(prefix, input, suffix string) string {
 	return strings.TrimSuffix(strings.TrimPrefix(input, prefix), suffix)
 }
+
+// thresholdArithmetic is a node of an arithmetic expression in a threshold
+// expression. It's either an operation on its Left and Right nodes, an
+// aggregation method of a metric, or a number.
+type thresholdArithmetic struct {
+	// Operator is one of `+`, `-`, `*` and `/` for an operation, and is 0
+	// for an aggregation method or a number.
+	Operator    byte
+	Left, Right *thresholdArithmetic
+
+	// Metric is the name of the metric of the aggregation method, or empty
+	// for the metric of the threshold.
+	Metric string
+
+	// AggregationMethod and AggregationValue are the aggregation method,
+	// as in thresholdExpression. The method is empty for a number, or for
+	// a metric referenced only by name.
+	AggregationMethod string
+	AggregationValue  null.Float
+
+	// Value is the value of a number.
+	Value float64
+
+	// Baseline is true for the value in the baseline of the aggregation
+	// method of the left hand side, which AggregationMethod and
+	// AggregationValue are set to.
+	Baseline bool
+}
+
+func (a *thresholdArithmetic) isNumber() bool {
+	return a.Operator == 0 && a.Metric == "" && a.AggregationMethod == "" && !a.Baseline
+}
+
+func (a *thresholdArithmetic) hasBaseline() bool {
+	if a.Operator != 0 {
+		return a.Left.hasBaseline() || a.Right.hasBaseline()
+	}
+	return a.Baseline
+}
+
+// setBaselineMethod sets the aggregation method of the baseline operands.
+func (a *thresholdArithmetic) setBaselineMethod(method string, value null.Float) {
+	switch {
+	case a.Operator != 0:
+		a.Left.setBaselineMethod(method, value)
+		a.Right.setBaselineMethod(method, value)
+	case a.Baseline:
+		a.AggregationMethod, a.AggregationValue = method, value
+	}
+}
+
+// isBareMetric returns true if the expression is only the name of a metric,
+// which is rather a mistyped aggregation method than a comparison with the
+// value of another metric.
+func (a *thresholdArithmetic) isBareMetric() bool {
+	return a.Operator == 0 && a.Metric != "" && a.AggregationMethod == ""
+}
+
+func (a *thresholdArithmetic) eachAggregation(fn func(metric, method string, value null.Float)) {
+	switch {
+	case a.Operator != 0:
+		a.Left.eachAggregation(fn)
+		a.Right.eachAggregation(fn)
+	case !a.isNumber() && !a.Baseline:
+		fn(a.Metric, a.AggregationMethod, a.AggregationValue)
+	}
+}
+
+// eval returns the value of the expression with the values of the
+// aggregation methods in sinks, by their sinkKey.
+func (a *thresholdArithmetic) eval(sinks map[string]float64) (float64, error) {
+	if a.Operator == 0 {
+		if a.isNumber() {
+			return a.Value, nil
+		}
+		if a.Baseline {
+			value, ok := sinks[baselineKey(a.AggregationMethod)]
+			if !ok {
+				return 0, fmt.Errorf("the baseline has no value of the %s aggregation method", a.AggregationMethod)
+			}
+			return value, nil
+		}
+		value, ok := sinks[sinkKey(a.Metric, a.AggregationMethod)]
+		switch {
+		case ok:
+			return value, nil
+		case a.Metric == "":
+			return 0, fmt.Errorf("no metric supporting the %s aggregation method found", a.AggregationMethod)
+		default:
+			return 0, fmt.Errorf("the metric %s doesn't support the %s aggregation method", a.Metric, a.AggregationMethod)
+		}
+	}
+
+	left, err := a.Left.eval(sinks)
+	if err != nil {
+		return 0, err
+	}
+	right, err := a.Right.eval(sinks)
+	if err != nil {
+		return 0, err
+	}
+	return applyArithmeticOperator(a.Operator, left, right), nil
+}
+
+// sinkKey returns the key of the value of the aggregation method of the metric
+// in the sinks of a threshold run. The ones of the metric of the threshold
+// are by method, and the others are prefixed by the name of their metric.
+func sinkKey(metric, method string) string {
+	switch {
+	case metric == "":
+		return method
+	case method == "":
+		return metric
+	default:
+		return metric + "/" + method
+	}
+}
+
+// baselineKey returns the key of the value of the aggregation method in the
+// baseline, in the sinks of a threshold run.
+func baselineKey(method string) string {
+	return tokenBaseline + ":" + method
+}
+
+// bucketKey returns the key of the value of the aggregation method over time
+// buckets in the sinks of a threshold run, the one of the bucket which is the
+// closest to crossing a limit with the operator: the lowest for > and >=, and
+// the highest otherwise.
+func bucketKey(method, operator string) string {
+	if operator == tokenGreater || operator == tokenGreaterEqual {
+		return "lowest:" + method
+	}
+	return "highest:" + method
+}
+
+func applyArithmeticOperator(operator byte, left, right float64) float64 {
+	switch operator {
+	case '+':
+		return left + right
+	case '-':
+		return left - right
+	case '*':
+		return left * right
+	default:
+		return left / right
+	}
+}
+
+// newArithmeticOperation returns the operation on left and right, or its
+// result if they are both numbers.
+func newArithmeticOperation(operator byte, left, right *thresholdArithmetic) *thresholdArithmetic {
+	if left.isNumber() && right.isNumber() {
+		return &thresholdArithmetic{Value: applyArithmeticOperator(operator, left.Value, right.Value)}
+	}
+	return &thresholdArithmetic{Operator: operator, Left: left, Right: right}
+}
+
+// parseThresholdArithmetic parses an arithmetic expression of a threshold
+// expression, as described by the BNF of parseThresholdExpression.
+func parseThresholdArithmetic(input string) (*thresholdArithmetic, error) {
+	p := &arithmeticParser{input: input}
+	expression, err := p.parseExpression()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != 0 {
+		return nil, fmt.Errorf("unexpected %q in arithmetic expression", p.input[p.pos:])
+	}
+	return expression, nil
+}
+
+// arithmeticParser is a recursive descent parser of arithmetic expressions.
+type arithmeticParser struct {
+	input string
+	pos   int
+}
+
+// peek skips the whitespaces and returns the next character, or 0 at the end
+// of the input.
+func (p *arithmeticParser) peek() byte {
+	for p.pos < len(p.input) && p.input[p.pos] == ' ' {
+		p.pos++
+	}
+	if p.pos == len(p.input) {
+		return 0
+	}
+	return p.input[p.pos]
+}
+
+func (p *arithmeticParser) parseExpression() (*thresholdArithmetic, error) {
+	return p.parseOperation("+-", p.parseTerm)
+}
+
+func (p *arithmeticParser) parseTerm() (*thresholdArithmetic, error) {
+	return p.parseOperation("*/", p.parseOperand)
+}
+
+// parseOperation parses a sequence of operands separated by the operators,
+// which are left associative.
+func (p *arithmeticParser) parseOperation(
+	operators string, parseOperand func() (*thresholdArithmetic, error),
+) (*thresholdArithmetic, error) {
+	left, err := parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		operator := p.peek()
+		if operator == 0 || strings.IndexByte(operators, operator) < 0 {
+			return left, nil
+		}
+		p.pos++
+		right, err := parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		left = newArithmeticOperation(operator, left, right)
+	}
+}
+
+func (p *arithmeticParser) parseOperand() (*thresholdArithmetic, error) {
+	switch c := p.peek(); c {
+	case 0:
+		return nil, fmt.Errorf("unexpected end of arithmetic expression")
+	case '(':
+		p.pos++
+		expression, err := p.parseExpression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis in arithmetic expression")
+		}
+		p.pos++
+		return expression, nil
+	case '+', '-':
+		p.pos++
+		operand, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		return newArithmeticOperation(c, &thresholdArithmetic{}, operand), nil
+	}
+
+	token := p.scanOperand()
+	if token == "" {
+		return nil, fmt.Errorf("unexpected %q in arithmetic expression", p.input[p.pos:])
+	}
+	if value, err := strconv.ParseFloat(token, 64); err == nil {
+		return &thresholdArithmetic{Value: value}, nil
+	}
+	if method, methodValue, err := parseThresholdAggregationMethod(token); err == nil {
+		return &thresholdArithmetic{AggregationMethod: method, AggregationValue: methodValue}, nil
+	}
+	if token == tokenBaseline {
+		return &thresholdArithmetic{Baseline: true}, nil
+	}
+	if !isMetricName(token) {
+		return nil, fmt.Errorf("%q is neither a number nor an aggregation method", token)
+	}
+
+	// The aggregation method of another metric directly follows its name
+	// and a slash, while a slash between spaces is a division.
+	operand := &thresholdArithmetic{Metric: token}
+	if start := p.pos; start < len(p.input) && p.input[start] == '/' {
+		p.pos++
+		method, methodValue, err := parseThresholdAggregationMethod(p.scanOperand())
+		if err != nil {
+			p.pos = start
+		} else {
+			operand.AggregationMethod, operand.AggregationValue = method, methodValue
+		}
+	}
+	return operand, nil
+}
+
+// isMetricName returns true if the token is a metric name which can be used in
+// a threshold expression, made of letters, digits and underscores.
+func isMetricName(token string) bool {
+	for i, c := range token {
+		if !(c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || i > 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return token != ""
+}
+
+// scanOperand returns the number or the aggregation method at the position,
+// including the parenthesis of a percentile, and the sign of the exponent of
+// a number such as 1e-3.
+func (p *arithmeticParser) scanOperand() string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		switch {
+		case c == '.' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z':
+			p.pos++
+		case (c == '-' || c == '+') && p.pos > start && isExponent(p.input[start:p.pos]):
+			p.pos++
+		case c == '(' && p.input[start:p.pos] == tokenPercentile:
+			end := strings.IndexByte(p.input[p.pos:], ')')
+			if end < 0 {
+				return p.input[start:]
+			}
+			p.pos += end + 1
+			return p.input[start:p.pos]
+		default:
+			return p.input[start:p.pos]
+		}
+	}
+	return p.input[start:p.pos]
+}
+
+// isExponent returns true if the token is a number up to the e of its exponent.
+func isExponent(token string) bool {
+	last := token[len(token)-1]
+	if last != 'e' && last != 'E' {
+		return false
+	}
+	_, err := strconv.ParseFloat(token[:len(token)-1], 64)
+	return err == nil
+}
@@ -22,6 +22,7 @@ package stats
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"gopkg.in/guregu/null.v3"
@@ -60,6 +61,150 @@ func TestParseThresholdExpression(t *testing.T) {
 			wantExpression: &thresholdExpression{AggregationMethod: "count", Operator: ">", Value: 20},
 			wantErr:        false,
 		},
+		{
+			name:           "constant arithmetic expression is evaluated",
+			input:          "avg<(1+2)*100/4",
+			wantExpression: &thresholdExpression{AggregationMethod: "avg", Operator: "<", Value: 75},
+			wantErr:        false,
+		},
+		{
+			name:  "arithmetic expression over aggregation methods",
+			input: "p(95) < avg * 2 - 1e-3",
+			wantExpression: &thresholdExpression{
+				AggregationMethod: "p(95)",
+				AggregationValue:  null.FloatFrom(95),
+				Operator:          "<",
+				Right: &thresholdArithmetic{
+					Operator: '-',
+					Left: &thresholdArithmetic{
+						Operator: '*',
+						Left:     &thresholdArithmetic{AggregationMethod: "avg"},
+						Right:    &thresholdArithmetic{Value: 2},
+					},
+					Right: &thresholdArithmetic{Value: 0.001},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:           "arithmetic expression with an invalid operand fails",
+			input:          "max < 1.5 * 9lives",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:  "cross-metric threshold expression",
+			input: "checks_failed / http_reqs < http_req_duration/p(95) / 1000",
+			wantExpression: &thresholdExpression{
+				Operator: "<",
+				Left: &thresholdArithmetic{
+					Operator: '/',
+					Left:     &thresholdArithmetic{Metric: "checks_failed"},
+					Right:    &thresholdArithmetic{Metric: "http_reqs"},
+				},
+				Right: &thresholdArithmetic{
+					Operator: '/',
+					Left: &thresholdArithmetic{
+						Metric:            "http_req_duration",
+						AggregationMethod: "p(95)",
+						AggregationValue:  null.FloatFrom(95),
+					},
+					Right: &thresholdArithmetic{Value: 1000},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:  "other metric with an aggregation method",
+			input: "my_errors/count<5",
+			wantExpression: &thresholdExpression{
+				Operator: "<",
+				Left:     &thresholdArithmetic{Metric: "my_errors", AggregationMethod: "count"},
+				Value:    5,
+			},
+			wantErr: false,
+		},
+		{
+			name:           "other metric alone on the right hand side fails",
+			input:          "count < http_reqs",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:  "comparison with the baseline",
+			input: "p(95) < baseline * 1.1",
+			wantExpression: &thresholdExpression{
+				AggregationMethod: "p(95)",
+				AggregationValue:  null.FloatFrom(95),
+				Operator:          "<",
+				Right: &thresholdArithmetic{
+					Operator: '*',
+					Left: &thresholdArithmetic{
+						Baseline:          true,
+						AggregationMethod: "p(95)",
+						AggregationValue:  null.FloatFrom(95),
+					},
+					Right: &thresholdArithmetic{Value: 1.1},
+				},
+			},
+			wantErr: false,
+		},
+		{
+			name:           "baseline on the left hand side fails",
+			input:          "baseline > avg",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:           "baseline with an arithmetic left hand side fails",
+			input:          "avg / 2 < baseline",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:           "arithmetic expression with unbalanced parentheses fails",
+			input:          "max < (med + 1",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:           "arithmetic expression with a missing operand fails",
+			input:          "max < med *",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:  "aggregation over time buckets",
+			input: "max_over(10s) < 500",
+			wantExpression: &thresholdExpression{
+				AggregationMethod: "max_over(10s)", Operator: "<", Value: 500,
+				Bucket: 10 * time.Second, BucketMethod: "max",
+			},
+		},
+		{
+			name:           "aggregation over time buckets with an unsupported method fails",
+			input:          "p(95)_over(10s) < 500",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:           "aggregation over time buckets with an invalid length fails",
+			input:          "avg_over(0s) < 500",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:           "aggregation over time buckets compared with == fails",
+			input:          "min_over(1m) == 5",
+			wantExpression: nil,
+			wantErr:        true,
+		},
+		{
+			name:           "aggregation over time buckets compared with the baseline fails",
+			input:          "max_over(1m) < baseline",
+			wantExpression: nil,
+			wantErr:        true,
+		},
 	}
 	for _, testCase := range tests {
 		testCase := testCase
@@ -114,6 +259,13 @@ func TestParseThresholdAggregationMethod(t *testing.T) {
 			wantMethodValue: null.Float{},
 			wantErr:         false,
 		},
+		{
+			name:            "rate_per_second method is parsed",
+			input:           "rate_per_second",
+			wantMethod:      "rate_per_second",
+			wantMethodValue: null.Float{},
+			wantErr:         false,
+		},
 		{
 			name:            "value method is parsed",
 			input:           "value",
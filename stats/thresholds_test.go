@@ -60,7 +60,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 	}{
 		{
 			name:             "valid expression using the > operator over passing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 1},
 			wantOk:           true,
@@ -68,7 +68,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression using the > operator over passing threshold and defined abort grace period",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(2 * time.Second),
 			sinks:            map[string]float64{"rate": 1},
 			wantOk:           true,
@@ -76,7 +76,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression using the >= operator over passing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreaterEqual, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreaterEqual, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.01},
 			wantOk:           true,
@@ -84,7 +84,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression using the <= operator over passing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenLessEqual, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenLessEqual, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.01},
 			wantOk:           true,
@@ -92,7 +92,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression using the < operator over passing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenLess, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenLess, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.00001},
 			wantOk:           true,
@@ -100,7 +100,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression using the == operator over passing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenLooselyEqual, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenLooselyEqual, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.01},
 			wantOk:           true,
@@ -108,7 +108,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression using the === operator over passing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenStrictlyEqual, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenStrictlyEqual, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.01},
 			wantOk:           true,
@@ -116,7 +116,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression using != operator over passing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenBangEqual, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenBangEqual, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.02},
 			wantOk:           true,
@@ -124,7 +124,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression over failing threshold",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.00001},
 			wantOk:           false,
@@ -132,7 +132,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 		},
 		{
 			name:             "valid expression over non-existing sink",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"med": 27.2},
 			wantOk:           false,
@@ -142,7 +142,7 @@ func TestThreshold_runNoTaint(t *testing.T) {
 			// The ParseThresholdCondition constructor should ensure that no invalid
 			// operator gets through, but let's protect our future selves anyhow.
 			name:             "invalid expression operator",
-			parsed:           &thresholdExpression{tokenRate, null.Float{}, "&", 0.01},
+			parsed:           &thresholdExpression{tokenRate, null.Float{}, "&", 0.01, nil, nil, 0, ""},
 			abortGracePeriod: types.NullDurationFrom(0 * time.Second),
 			sinks:            map[string]float64{"rate": 0.00001},
 			wantOk:           false,
@@ -185,7 +185,7 @@ func BenchmarkRunNoTaint(b *testing.B) {
 		LastFailed:       false,
 		AbortOnFail:      false,
 		AbortGracePeriod: types.NullDurationFrom(2 * time.Second),
-		parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01},
+		parsed:           &thresholdExpression{tokenRate, null.Float{}, tokenGreater, 0.01, nil, nil, 0, ""},
 	}
 
 	sinks := map[string]float64{"rate": 1}
@@ -219,7 +219,7 @@ func TestThresholdRun(t *testing.T) {
 		t.Run("taint", func(t *testing.T) {
 			t.Parallel()
 
-			b, err := threshold.run(sinks)
+			b, err := threshold.run(sinks, 0)
 			assert.NoError(t, err)
 			assert.True(t, b)
 			assert.False(t, threshold.LastFailed)
@@ -243,7 +243,7 @@ func TestThresholdRun(t *testing.T) {
 		})
 
 		t.Run("taint", func(t *testing.T) {
-			b, err := threshold.run(sinks)
+			b, err := threshold.run(sinks, 0)
 			assert.NoError(t, err)
 			assert.False(t, b)
 			assert.True(t, threshold.LastFailed)
@@ -357,6 +357,50 @@ func TestNewThresholds(t *testing.T) {
 	})
 }
 
+func TestThresholdsMerge(t *testing.T) {
+	t.Parallel()
+	ts := NewThresholds([]string{"avg<500"})
+	other := NewThresholds([]string{"p(95)<800", "avg<500"})
+	ts.Merge(other)
+	require.Len(t, ts.Thresholds, 2)
+	assert.Equal(t, "avg<500", ts.Thresholds[0].Source)
+	assert.Equal(t, "p(95)<800", ts.Thresholds[1].Source)
+
+	var empty Thresholds
+	empty.Merge(other)
+	assert.Equal(t, other.Thresholds, empty.Thresholds)
+}
+
+func TestThresholdsValidatePercentiles(t *testing.T) {
+	t.Parallel()
+
+	testCases := []struct {
+		source    string
+		precision int
+		valid     bool
+	}{
+		{source: "p(95)<200", precision: 0, valid: true},
+		{source: "p(99.99)<200", precision: 0, valid: true},
+		{source: "p(95)<200", precision: 2, valid: true},
+		{source: "p(90)<200", precision: 1, valid: true},
+		{source: "p(99.9)<200", precision: 2, valid: false},
+		{source: "p(99.99)<200", precision: 3, valid: false},
+		{source: "p(99.99)<200", precision: 4, valid: true},
+		{source: "p(0.01)<200", precision: 1, valid: true},
+		{source: "p(101)<200", precision: 0, valid: false},
+		{source: "avg<200", precision: 1, valid: true},
+		{source: "rate>>1", precision: 1, valid: true},
+	}
+	for _, tc := range testCases {
+		err := NewThresholds([]string{tc.source}).ValidatePercentiles(tc.precision)
+		if tc.valid {
+			assert.NoError(t, err, "%s with %d digits", tc.source, tc.precision)
+		} else {
+			assert.Error(t, err, "%s with %d digits", tc.source, tc.precision)
+		}
+	}
+}
+
 func TestNewThresholdsWithConfig(t *testing.T) {
 	t.Parallel()
 
@@ -370,8 +414,8 @@ func TestNewThresholdsWithConfig(t *testing.T) {
 		t.Parallel()
 
 		configs := []thresholdConfig{
-			{`rate<0.01`, false, types.NullDuration{}},
-			{`p(95)<200`, true, types.NullDuration{}},
+			{`rate<0.01`, false, types.NullDuration{}, "", nil, 0, nil},
+			{`p(95)<200`, true, types.NullDuration{}, ThresholdSeverityWarn, nil, 3, nil},
 		}
 		ts := newThresholdsWithConfig(configs)
 		assert.Len(t, ts.Thresholds, 2)
@@ -379,6 +423,8 @@ func TestNewThresholdsWithConfig(t *testing.T) {
 			assert.Equal(t, configs[i].Threshold, th.Source)
 			assert.False(t, th.LastFailed)
 			assert.Equal(t, configs[i].AbortOnFail, th.AbortOnFail)
+			assert.Equal(t, configs[i].Severity, th.Severity)
+			assert.Equal(t, configs[i].ConsecutiveBreaches, th.ConsecutiveBreaches)
 		}
 	})
 }
@@ -442,6 +488,58 @@ func TestThresholdsRunAll(t *testing.T) {
 	}
 }
 
+func TestThresholdsRunAllWarning(t *testing.T) {
+	t.Parallel()
+
+	thresholds := NewThresholds([]string{`rate<0.01`, `p(95)<200`})
+	require.NoError(t, thresholds.Parse())
+	thresholds.sinked = map[string]float64{"rate": 0.0001, "p(95)": 500}
+	thresholds.Thresholds[1].Severity = ThresholdSeverityWarn
+	thresholds.Thresholds[1].AbortOnFail = true
+
+	succeeded, err := thresholds.runAll(time.Second)
+	require.NoError(t, err)
+	assert.True(t, succeeded)
+	assert.False(t, thresholds.Abort)
+	assert.True(t, thresholds.Thresholds[1].LastFailed)
+
+	results := thresholds.Results()
+	require.Len(t, results, 2)
+	assert.Equal(t, "", results[0].Severity)
+	assert.False(t, results[1].Passed)
+	assert.Equal(t, ThresholdSeverityWarn, results[1].Severity)
+
+	thresholds.Thresholds[1].Severity = ThresholdSeverityError
+	succeeded, err = thresholds.runAll(time.Second)
+	require.NoError(t, err)
+	assert.False(t, succeeded)
+	assert.True(t, thresholds.Abort)
+}
+
+func TestThresholdsRunAllConsecutiveBreaches(t *testing.T) {
+	t.Parallel()
+
+	thresholds := NewThresholds([]string{`p(95)<200`})
+	require.NoError(t, thresholds.Parse())
+	thresholds.Thresholds[0].AbortOnFail = true
+	thresholds.Thresholds[0].ConsecutiveBreaches = 3
+
+	run := func(p95 float64) {
+		thresholds.sinked = map[string]float64{"p(95)": p95}
+		_, err := thresholds.runAll(time.Second)
+		require.NoError(t, err)
+	}
+
+	run(500)
+	run(500)
+	run(100)
+	run(500)
+	run(500)
+	assert.False(t, thresholds.Abort, "the breaches weren't consecutive")
+	run(500)
+	assert.True(t, thresholds.Abort)
+}
+
 func TestThresholds_Run(t *testing.T) {
 	t.Parallel()
 
@@ -490,6 +588,411 @@ func TestThresholds_Run(t *testing.T) {
 	}
 }
 
+func TestThresholdsRunArithmetic(t *testing.T) {
+	t.Parallel()
+
+	thresholds := NewThresholds([]string{
+		"p(75) < avg * 2", "max < 1.5 * med", "p(99) <= p(90) + 100", "min > -(avg - 50) / 2",
+	})
+	require.NoError(t, thresholds.Parse())
+
+	sink := &TrendSink{}
+	for _, v := range []float64{10, 20, 30, 40, 100} {
+		sink.Add(Sample{Value: v})
+	}
+	sink.Calc()
+	ok, err := thresholds.Run(sink, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Contains(t, thresholds.sinked, "p(90)")
+
+	results := thresholds.Results()
+	assert.True(t, results[0].Passed)
+	assert.Equal(t, 80.0, results[0].Limit)
+	assert.False(t, results[1].Passed)
+	assert.Equal(t, 45.0, results[1].Limit)
+	assert.True(t, results[2].Passed)
+	assert.True(t, results[3].Passed)
+
+	thresholds = NewThresholds([]string{"rate < count / 2"})
+	require.NoError(t, thresholds.Parse())
+	_, err = thresholds.Run(DummySink{"rate": 1}, 0)
+	assert.Error(t, err)
+}
+
+func TestThresholdsRunRatePerSecond(t *testing.T) {
+	t.Parallel()
+
+	var ts Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`[
+		"rate_per_second>=10",
+		{"threshold": "rate_per_second>=10", "window": "10s"},
+		"rate_per_second == rate"
+	]`), &ts))
+	require.NoError(t, ts.Parse())
+
+	sink := &CounterSink{}
+	start := time.Now()
+	for i := 0; i < 60; i++ {
+		s := Sample{Time: start.Add(time.Duration(i) * time.Second / 2), Value: 10}
+		if i >= 40 {
+			s.Value = 1
+		}
+		sink.Add(s)
+		ts.AddSample(s)
+	}
+	ok, err := ts.Run(sink, 30*time.Second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	results := ts.Results()
+	assert.True(t, results[0].Passed, "420 in 30s")
+	assert.Equal(t, null.FloatFrom(14.0), results[0].Observed)
+	assert.False(t, results[1].Passed, "only 20 in the last 10s")
+	assert.Equal(t, null.FloatFrom(2.0), results[1].Observed)
+	assert.True(t, results[2].Passed)
+}
+
+func TestThresholdsRunHistogram(t *testing.T) {
+	t.Parallel()
+
+	thresholds := NewThresholds([]string{"p(50) == 15", "count == 6", "max < 50", "med < avg"})
+	require.NoError(t, thresholds.Parse())
+
+	sink, err := NewHistogramSink([]float64{10, 20, 50})
+	require.NoError(t, err)
+	for _, v := range []float64{5, 10, 15, 20, 30, 60} {
+		sink.Add(Sample{Value: v})
+	}
+	ok, err := thresholds.Run(sink, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	results := thresholds.Results()
+	assert.True(t, results[0].Passed)
+	assert.True(t, results[1].Passed)
+	assert.False(t, results[2].Passed)
+	assert.True(t, results[3].Passed)
+}
+
+func TestThresholdsRunGaugeEWMA(t *testing.T) {
+	t.Parallel()
+
+	thresholds := NewThresholds([]string{"value<100", "ewma<100", "ewma<value"})
+	require.NoError(t, thresholds.Parse())
+
+	sink := NewGaugeSink(time.Second)
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		sink.Add(Sample{Time: start.Add(time.Duration(i) * time.Second), Value: 50})
+	}
+	// a single spike is over the threshold of the value, but not of the average
+	sink.Add(Sample{Time: start.Add(10 * time.Second), Value: 130})
+	ok, err := thresholds.Run(sink, 10*time.Second)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	results := thresholds.Results()
+	assert.False(t, results[0].Passed)
+	assert.True(t, results[1].Passed)
+	assert.Equal(t, null.FloatFrom(90.0), results[1].Observed, "halfway from 50 to 130")
+	assert.True(t, results[2].Passed)
+}
+
+func TestThresholdsRunWithMetrics(t *testing.T) {
+	t.Parallel()
+
+	reqs := New("http_reqs", Counter)
+	reqs.Sink.Add(Sample{Value: 200})
+	duration := New("http_req_duration", Trend, Time)
+	for _, v := range []float64{100, 200, 300} {
+		duration.Sink.Add(Sample{Value: v})
+	}
+	duration.Sink.Calc()
+	metrics := map[string]*Metric{reqs.Name: reqs, duration.Name: duration}
+
+	thresholds := NewThresholds([]string{
+		"count / http_reqs < 0.01",
+		"my_errors/count < http_reqs/count * 0.05",
+		"count < http_req_duration/max / 10",
+		"count * 2 <= http_req_duration / 10",
+	})
+	require.NoError(t, thresholds.Parse())
+
+	ok, err := thresholds.RunWithMetrics(DummySink{"count": 4}, time.Second, metrics)
+	require.NoError(t, err)
+	assert.False(t, ok)
+	results := thresholds.Results()
+	assert.False(t, results[0].Passed)
+	assert.Equal(t, null.FloatFrom(0), results[1].Observed, "my_errors has no samples")
+	assert.Equal(t, "my_errors/count", results[1].Aggregation)
+	assert.Equal(t, 10.0, results[1].Limit)
+	assert.True(t, results[1].Passed)
+	assert.Equal(t, 30.0, results[2].Limit)
+	assert.True(t, results[2].Passed)
+	assert.True(t, results[3].Passed, "the avg is the default of a trend")
+
+	thresholds = NewThresholds([]string{"count < http_req_duration/rate"})
+	require.NoError(t, thresholds.Parse())
+	_, err = thresholds.RunWithMetrics(DummySink{"count": 4}, time.Second, metrics)
+	assert.Error(t, err)
+}
+
+func TestThresholdsRunBaseline(t *testing.T) {
+	t.Parallel()
+
+	thresholds := NewThresholds([]string{"p(95) < baseline * 1.1", "avg <= baseline", "max < 1000"})
+	require.NoError(t, thresholds.Parse())
+	assert.True(t, thresholds.UsesBaseline())
+	assert.Equal(t, []string{"avg", "max", "p(95)"}, thresholds.AggregationMethods())
+
+	assert.Error(t, thresholds.SetBaseline(map[string]float64{"p(95)": 100}), "the avg is missing")
+	require.NoError(t, thresholds.SetBaseline(map[string]float64{"p(95)": 100, "avg": 20}))
+
+	sink := &TrendSink{}
+	for _, v := range []float64{10, 20, 30, 40, 100} {
+		sink.Add(Sample{Value: v})
+	}
+	sink.Calc()
+	ok, err := thresholds.Run(sink, 0)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	results := thresholds.Results()
+	assert.True(t, results[0].Passed)
+	assert.InDelta(t, 110.0, results[0].Limit, 1e-9)
+	assert.False(t, results[1].Passed)
+	assert.Equal(t, 20.0, results[1].Limit)
+	assert.True(t, results[2].Passed)
+
+	thresholds = NewThresholds([]string{"max < 1000"})
+	require.NoError(t, thresholds.Parse())
+	assert.False(t, thresholds.UsesBaseline())
+	assert.NoError(t, thresholds.SetBaseline(nil))
+}
+
+func TestThresholdsRunWindow(t *testing.T) {
+	t.Parallel()
+
+	var ts Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`[
+		"max<100",
+		{"threshold": "max<100", "window": "10s"},
+		{"threshold": "avg<20", "window": "30s"}
+	]`), &ts))
+	require.NoError(t, ts.Parse())
+
+	sink := &TrendSink{}
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, value := range []float64{500, 10, 10, 30, 5, 5} {
+		sample := Sample{Time: start.Add(time.Duration(i) * 10 * time.Second), Value: value}
+		sink.Add(sample)
+		ts.AddSample(sample)
+	}
+
+	succeeded, err := ts.RunWithMetrics(sink, time.Minute, nil)
+	require.NoError(t, err)
+	assert.False(t, succeeded)
+	assert.True(t, ts.Thresholds[0].LastFailed)
+	assert.False(t, ts.Thresholds[1].LastFailed)
+	assert.False(t, ts.Thresholds[2].LastFailed)
+
+	results := ts.Results()
+	assert.Equal(t, null.FloatFrom(500), results[0].Observed)
+	assert.Equal(t, types.Duration(0), results[0].Window)
+	assert.Equal(t, null.FloatFrom(5), results[1].Observed)
+	assert.Equal(t, types.Duration(10*time.Second), results[1].Window)
+	assert.Equal(t, null.FloatFrom(float64(30+5+5)/3), results[2].Observed)
+
+	counter := &CounterSink{}
+	var counterTs Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`[{"threshold": "rate<=1", "window": "10s"}]`), &counterTs))
+	require.NoError(t, counterTs.Parse())
+	for i := 0; i < 30; i++ {
+		sample := Sample{Time: start.Add(time.Duration(i) * time.Second), Value: 1}
+		if i < 10 {
+			sample.Value = 10
+		}
+		counter.Add(sample)
+		counterTs.AddSample(sample)
+	}
+	succeeded, err = counterTs.RunWithMetrics(counter, 30*time.Second, nil)
+	require.NoError(t, err)
+	assert.True(t, succeeded)
+}
+
+func TestThresholdsRunBuckets(t *testing.T) {
+	t.Parallel()
+
+	ts := NewThresholds([]string{"avg<100", "max_over(10s)<400", "avg_over(10s)<100", "min_over(10s)>=10"})
+	require.NoError(t, ts.Parse())
+
+	sink := &TrendSink{}
+	succeeded, err := ts.RunWithMetrics(sink, 0, nil)
+	require.NoError(t, err)
+	assert.True(t, succeeded, "there are no buckets yet")
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, bucket := range [][]float64{{10, 20, 30}, {300, 10, 20}, {30, 40, 50}} {
+		for j, value := range bucket {
+			sample := Sample{Time: start.Add(time.Duration(i)*10*time.Second + time.Duration(j)*time.Second), Value: value}
+			sink.Add(sample)
+			ts.AddSample(sample)
+		}
+	}
+
+	succeeded, err = ts.RunWithMetrics(sink, 30*time.Second, nil)
+	require.NoError(t, err)
+	assert.False(t, succeeded)
+	assert.False(t, ts.Thresholds[0].LastFailed, "the short spike is hidden in the average of the test")
+	assert.False(t, ts.Thresholds[1].LastFailed)
+	assert.True(t, ts.Thresholds[2].LastFailed)
+	assert.False(t, ts.Thresholds[3].LastFailed)
+
+	results := ts.Results()
+	assert.Equal(t, "max_over(10s)", results[1].Aggregation)
+	assert.Equal(t, null.FloatFrom(300), results[1].Observed)
+	assert.Equal(t, null.FloatFrom(float64(300+10+20)/3), results[2].Observed)
+	assert.Equal(t, null.FloatFrom(10), results[3].Observed)
+}
+
+func TestThresholdsRunDerivative(t *testing.T) {
+	t.Parallel()
+
+	var ts Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`[
+		"rate<0.1",
+		{"threshold": "rate<0.01", "derivative": "1m"}
+	]`), &ts))
+	require.NoError(t, ts.Parse())
+
+	run := func(rate float64, at time.Duration) bool {
+		succeeded, err := ts.Run(DummySink{"rate": rate}, at)
+		require.NoError(t, err)
+		return succeeded
+	}
+	assert.True(t, run(0.05, 0), "the rate of change can't be measured with one run")
+	assert.Equal(t, null.Float{}, ts.Results()[1].Observed)
+
+	assert.True(t, run(0.05, 30*time.Second))
+	assert.Equal(t, null.FloatFrom(0), ts.Results()[1].Observed)
+
+	// 0.02 more in 1m, from the first run, as it's the last one 1m before
+	assert.False(t, run(0.07, time.Minute))
+	results := ts.Results()
+	assert.True(t, results[0].Passed)
+	assert.False(t, results[1].Passed)
+	assert.InDelta(t, 0.02, results[1].Observed.Float64, 1e-9)
+	assert.Equal(t, types.Duration(time.Minute), results[1].Derivative)
+
+	// from the run at 1m, as it's the last one at least 1m before
+	assert.True(t, run(0.0705, 2*time.Minute))
+	assert.InDelta(t, 0.0005, ts.Results()[1].Observed.Float64, 1e-9)
+
+	// a run at the same time replaces the last one
+	assert.False(t, run(0.09, 2*time.Minute))
+	assert.InDelta(t, 0.02, ts.Results()[1].Observed.Float64, 1e-9)
+	assert.Len(t, ts.Thresholds[1].trend, 2)
+}
+
+func TestThresholdsReset(t *testing.T) {
+	t.Parallel()
+
+	var ts Thresholds
+	require.NoError(t, json.Unmarshal([]byte(`[
+		{"threshold": "max<100", "window": "10s"},
+		"max_over(10s)<100",
+		{"threshold": "max<1", "derivative": "1m"}
+	]`), &ts))
+	require.NoError(t, ts.Parse())
+
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	sink := &TrendSink{}
+	for i, value := range []float64{500, 10, 600} {
+		sample := Sample{Time: start.Add(time.Duration(i) * time.Second), Value: value}
+		sink.Add(sample)
+		ts.AddSample(sample)
+		_, err := ts.Run(sink, time.Duration(i)*time.Minute)
+		require.NoError(t, err)
+	}
+	require.True(t, ts.Thresholds[2].LastFailed)
+	require.NotZero(t, ts.Thresholds[2].breaches)
+
+	ts.Reset()
+	for _, threshold := range ts.Thresholds {
+		assert.False(t, threshold.LastFailed)
+		assert.Zero(t, threshold.breaches)
+	}
+	assert.Equal(t, null.Float{}, ts.Results()[2].Observed)
+
+	sink = &TrendSink{}
+	sample := Sample{Time: start.Add(3 * time.Second), Value: 50}
+	sink.Add(sample)
+	ts.AddSample(sample)
+	succeeded, err := ts.Run(sink, 3*time.Minute)
+	require.NoError(t, err)
+	assert.True(t, succeeded, "the samples before the reset aren't in the window or the buckets")
+	results := ts.Results()
+	assert.Equal(t, null.FloatFrom(50), results[0].Observed)
+	assert.Equal(t, null.FloatFrom(50), results[1].Observed)
+	assert.Equal(t, null.Float{}, results[2].Observed, "the rate of change is measured from the reset")
+}
+
+func TestThresholdsResults(t *testing.T) {
+	t.Parallel()
+
+	thresholds := NewThresholds([]string{"p(95)<2000", "avg<100"})
+	require.NoError(t, thresholds.Parse())
+	thresholds.Thresholds[1].AbortOnFail = true
+
+	results := thresholds.Results()
+	require.Len(t, results, 2)
+	assert.False(t, results[0].Observed.Valid, "the thresholds weren't run yet")
+
+	before := time.Now()
+	_, err := thresholds.Run(DummySink{"p(95)": 1234.5, "avg": 150}, 0)
+	require.NoError(t, err)
+	results = thresholds.Results()
+	require.Len(t, results, 2)
+	for i, result := range results {
+		require.True(t, result.LastRun.Valid)
+		assert.False(t, result.LastRun.Time.Before(before))
+		results[i].LastRun = null.Time{}
+	}
+	assert.Equal(t, []ThresholdResult{
+		{
+			Source: "p(95)<2000", Aggregation: "p(95)", Operator: "<", Limit: 2000,
+			Observed: null.FloatFrom(1234.5), Margin: null.FloatFrom(765.5), Passed: true,
+		},
+		{
+			Source: "avg<100", Aggregation: "avg", Operator: "<", Limit: 100,
+			Observed: null.FloatFrom(150), Margin: null.FloatFrom(-50), Passed: false, AbortOnFail: true,
+		},
+	}, results)
+}
+
+func TestThresholdMargin(t *testing.T) {
+	t.Parallel()
+
+	testdata := []struct {
+		operator        string
+		observed, limit float64
+		margin          float64
+	}{
+		{"<", 80, 100, 20},
+		{"<=", 120, 100, -20},
+		{">", 0.99, 0.95, 0.04},
+		{">=", 0.9, 0.95, -0.05},
+		{"!=", 3, 5, 2},
+		{"==", 3, 5, -2},
+		{"===", 5, 5, 0},
+	}
+	for _, data := range testdata {
+		assert.InDelta(t, data.margin, thresholdMargin(data.operator, data.observed, data.limit), 1e-9,
+			"%v %s %v", data.observed, data.operator, data.limit)
+	}
+}
+
 func TestThresholdsJSON(t *testing.T) {
 	t.Parallel()
 
@@ -556,6 +1059,34 @@ func TestThresholdsJSON(t *testing.T) {
 			types.NullDuration{},
 			`["rate<0.01"]`,
 		},
+		{
+			`[{"threshold":"rate<0.01","abortOnFail":false,"delayAbortEval":null,"severity":"warn"}]`,
+			[]string{"rate<0.01"},
+			false,
+			types.NullDuration{},
+			"",
+		},
+		{
+			`[{"threshold":"rate<0.01","abortOnFail":false,"delayAbortEval":null,"window":"1m0s"}]`,
+			[]string{"rate<0.01"},
+			false,
+			types.NullDuration{},
+			"",
+		},
+		{
+			`[{"threshold":"rate<0.01","abortOnFail":false,"delayAbortEval":null,"derivative":"1m0s"}]`,
+			[]string{"rate<0.01"},
+			false,
+			types.NullDuration{},
+			"",
+		},
+		{
+			`[{"threshold":"rate<0.01","abortOnFail":true,"delayAbortEval":null,"consecutiveBreaches":3}]`,
+			[]string{"rate<0.01"},
+			true,
+			types.NullDuration{},
+			"",
+		},
 		{
 			`[{"threshold":"rate<0.01"}, "p(95)<200"]`,
 			[]string{"rate<0.01", "p(95)<200"},
@@ -608,4 +1139,40 @@ func TestThresholdsJSON(t *testing.T) {
 		assert.Nil(t, ts.Thresholds)
 		assert.False(t, ts.Abort)
 	})
+
+	t.Run("bad window", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		err := json.Unmarshal([]byte(`[{"threshold":"rate<0.01","window":"0s"}]`), &ts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `the window of the threshold "rate<0.01" should be more than 0`)
+	})
+
+	t.Run("bad derivative", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		err := json.Unmarshal([]byte(`[{"threshold":"rate<0.01","derivative":"-1m"}]`), &ts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `the derivative of the threshold "rate<0.01" should be more than 0`)
+	})
+
+	t.Run("bad consecutiveBreaches", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		err := json.Unmarshal([]byte(`[{"threshold":"rate<0.01","consecutiveBreaches":-1}]`), &ts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `the consecutiveBreaches of the threshold "rate<0.01" shouldn't be negative`)
+	})
+
+	t.Run("bad severity", func(t *testing.T) {
+		t.Parallel()
+
+		var ts Thresholds
+		err := json.Unmarshal([]byte(`[{"threshold":"rate<0.01","severity":"info"}]`), &ts)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), `should be "error" or "warn", but it's "info"`)
+	})
 }